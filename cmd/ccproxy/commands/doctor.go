@@ -0,0 +1,322 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/process"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// Doctor check results
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// keylessProviders run locally and do not need an API key
+var keylessProviders = map[string]bool{
+	"ollama":                true,
+	config.MockProviderName: true,
+}
+
+// doctorCheck is one line of the doctor report
+type doctorCheck struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// doctorReport collects the results of all checks
+type doctorReport struct {
+	Checks []doctorCheck `json:"checks"`
+}
+
+// add records a check result
+func (r *doctorReport) add(section, name, status, message, hint string) {
+	r.Checks = append(r.Checks, doctorCheck{
+		Section: section,
+		Name:    name,
+		Status:  status,
+		Message: message,
+		Hint:    hint,
+	})
+}
+
+// count returns the number of checks with status
+func (r *doctorReport) count(status string) int {
+	n := 0
+	for _, check := range r.Checks {
+		if check.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// envDoctorCmd returns the env doctor subcommand
+func envDoctorCmd() *cobra.Command {
+	var configPath string
+	var jsonOutput bool
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the environment, configuration and providers",
+		Long: `Validate environment variables and configuration, check that enabled
+providers have API keys and are reachable, and check that the server port is
+free. Exits non-zero if any check fails.`,
+		SilenceUsage: true, // Failures are reported checks, not usage errors
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report := &doctorReport{}
+
+			checkDoctorEnvironment(report)
+			cfg := checkDoctorConfig(report, configPath)
+			if cfg != nil {
+				checkDoctorProviders(report, cfg, offline)
+				checkDoctorRoutes(report, cfg)
+				checkDoctorPort(report, cfg)
+			}
+
+			if jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				printDoctorReport(report)
+			}
+
+			if failures := report.count(doctorFail); failures > 0 {
+				return fmt.Errorf("%d checks failed", failures)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to config file")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	cmd.Flags().BoolVar(&offline, "offline", false, "Skip provider reachability checks")
+
+	return cmd
+}
+
+// checkDoctorEnvironment validates the CCProxy environment variables
+func checkDoctorEnvironment(report *doctorReport) {
+	envReport := utils.ValidateEnvironmentVariablesWithReport()
+
+	names := make([]string, 0, len(envReport.Details))
+	for name := range envReport.Details {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	set := 0
+	for _, name := range names {
+		detail := envReport.Details[name]
+		if detail.Value != "" {
+			set++
+		}
+		if !detail.Valid {
+			report.add("Environment", name, doctorFail, detail.Error,
+				fmt.Sprintf("Fix or unset %s", name))
+		}
+	}
+
+	if envReport.Valid {
+		report.add("Environment", "variables", doctorPass,
+			fmt.Sprintf("%d CCProxy variables set, all valid", set), "")
+	}
+}
+
+// checkDoctorConfig loads and validates the configuration, returning nil if
+// it cannot be used
+func checkDoctorConfig(report *doctorReport, configPath string) *config.Config {
+	if configPath != "" {
+		cfg, err := config.LoadFromFile(configPath)
+		if err != nil {
+			report.add("Configuration", configPath, doctorFail, err.Error(),
+				"Fix the reported field, or check the file is valid JSON")
+			return nil
+		}
+		report.add("Configuration", configPath, doctorPass, "Loaded and valid", "")
+		return cfg
+	}
+
+	configService := config.NewService()
+	if err := configService.Load(); err != nil {
+		report.add("Configuration", "config", doctorFail, err.Error(),
+			"Fix the reported field in ~/.ccproxy/config.json, or pass --config to check another file")
+		return nil
+	}
+	report.add("Configuration", "config", doctorPass, "Loaded and valid", "")
+	return configService.Get()
+}
+
+// checkDoctorProviders checks that enabled providers have API keys and, unless
+// offline, that they are reachable
+func checkDoctorProviders(report *doctorReport, cfg *config.Config, offline bool) {
+	var probe []*config.Provider
+	enabled := 0
+	for i := range cfg.Providers {
+		provider := &cfg.Providers[i]
+		if !provider.Enabled {
+			continue
+		}
+		enabled++
+
+		name := strings.ToLower(provider.Name)
+		if provider.APIKey == "" && !keylessProviders[name] && !hasServiceAccount(provider) {
+			report.add("Providers", provider.Name, doctorFail, "No API key configured", apiKeyHint(provider.Name))
+			continue
+		}
+		probe = append(probe, provider)
+	}
+
+	if enabled == 0 {
+		report.add("Providers", "providers", doctorFail, "No enabled providers",
+			"Add a provider to the config and set \"enabled\": true")
+		return
+	}
+	if offline || len(probe) == 0 {
+		return
+	}
+
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	service := providers.NewService(configService)
+
+	errs := make([]error, len(probe))
+	var wg sync.WaitGroup
+	for i, provider := range probe {
+		wg.Add(1)
+		go func(i int, provider *config.Provider) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			errs[i] = service.ProbeProvider(ctx, provider)
+		}(i, provider)
+	}
+	wg.Wait()
+
+	for i, provider := range probe {
+		if errs[i] != nil {
+			hint := fmt.Sprintf("Check that %s is reachable from this machine, and any HTTPS_PROXY settings", provider.APIBaseURL)
+			if strings.Contains(errs[i].Error(), "authentication failed") {
+				hint = fmt.Sprintf("The API key for %s was rejected, check it is current", provider.Name)
+			}
+			report.add("Providers", provider.Name, doctorFail, "Unreachable: "+errs[i].Error(), hint)
+			continue
+		}
+		if provider.Name == config.MockProviderName {
+			report.add("Providers", provider.Name, doctorPass, "Answers requests locally", "")
+			continue
+		}
+		report.add("Providers", provider.Name, doctorPass, "Reachable at "+provider.APIBaseURL, "")
+	}
+}
+
+// hasServiceAccount reports whether a Vertex AI provider mints its access
+// tokens from a service account instead of using an API key
+func hasServiceAccount(provider *config.Provider) bool {
+	return strings.EqualFold(provider.Name, "vertex") && provider.ServiceAccountFile != ""
+}
+
+// apiKeyHint explains how to supply a provider's missing API key
+func apiKeyHint(name string) string {
+	if strings.EqualFold(name, "vertex") {
+		return "Set \"service_account_file\" for the vertex provider, or \"api_key\" to a pre-issued access token"
+	}
+	envVar, ok := config.ProviderAPIKeyEnvVars[strings.ToLower(name)]
+	if !ok {
+		return fmt.Sprintf("Set \"api_key\" for the %s provider in the config", name)
+	}
+	if os.Getenv(envVar) == "" {
+		return fmt.Sprintf("%s not set but %s provider enabled", envVar, name)
+	}
+	return fmt.Sprintf("%s is set but not used by this config file, reference it with \"api_key\": \"${%s}\"", envVar, envVar)
+}
+
+// checkDoctorRoutes warns about routes that point at disabled providers
+func checkDoctorRoutes(report *doctorReport, cfg *config.Config) {
+	enabled := make(map[string]bool)
+	for _, provider := range cfg.Providers {
+		enabled[provider.Name] = provider.Enabled
+	}
+
+	names := make([]string, 0, len(cfg.Routes))
+	for name := range cfg.Routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		route := cfg.Routes[name]
+		if route.Provider != "" && !enabled[route.Provider] {
+			report.add("Routes", name, doctorWarn,
+				fmt.Sprintf("Routes to disabled provider %s", route.Provider),
+				fmt.Sprintf("Enable %s or point the %s route at an enabled provider", route.Provider, name))
+		}
+	}
+}
+
+// checkDoctorPort checks that the server can bind its address, or that
+// CCProxy itself is the one using it
+func checkDoctorPort(report *doctorReport, cfg *config.Config) {
+	address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+
+	listener, err := net.Listen("tcp", address)
+	if err == nil {
+		_ = listener.Close() // Safe to ignore: only checking availability
+		report.add("Network", address, doctorPass, "Port is available", "")
+		return
+	}
+
+	if pidManager, pidErr := process.NewPIDManager(); pidErr == nil {
+		if pid, _ := pidManager.GetRunningPID(); pid > 0 {
+			report.add("Network", address, doctorPass, fmt.Sprintf("In use by the running CCProxy (PID %d)", pid), "")
+			return
+		}
+	}
+
+	report.add("Network", address, doctorFail, "Cannot bind: "+err.Error(),
+		fmt.Sprintf("Stop the process using port %d, or choose another port with CCPROXY_PORT", cfg.Port))
+}
+
+// printDoctorReport writes the report grouped by section to stdout
+func printDoctorReport(report *doctorReport) {
+	icons := map[string]string{doctorPass: "✅", doctorWarn: "⚠️ ", doctorFail: "❌"}
+
+	fmt.Println("🩺 CCProxy Doctor")
+	section := ""
+	for _, check := range report.Checks {
+		if check.Section != section {
+			section = check.Section
+			fmt.Println()
+			fmt.Println(section + ":")
+		}
+		fmt.Printf("  %s %s: %s\n", icons[check.Status], check.Name, check.Message)
+		if check.Hint != "" {
+			fmt.Printf("     💡 %s\n", check.Hint)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("%d passed, %d warnings, %d failed\n",
+		report.count(doctorPass), report.count(doctorWarn), report.count(doctorFail))
+}
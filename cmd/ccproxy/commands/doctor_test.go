@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+)
+
+// closedURL returns the URL of a local port nothing listens on
+func closedURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	url := "http://" + listener.Addr().String()
+	_ = listener.Close()
+	return url
+}
+
+func TestCheckDoctorProviders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer rejecting.Close()
+
+	tests := []struct {
+		name       string
+		provider   config.Provider
+		wantStatus string
+		wantText   string
+	}{
+		{
+			name:       "reachable",
+			provider:   config.Provider{Name: "openai", APIBaseURL: upstream.URL, APIKey: "sk-test", Enabled: true},
+			wantStatus: doctorPass,
+			wantText:   "Reachable at " + upstream.URL,
+		},
+		{
+			name:       "unreachable",
+			provider:   config.Provider{Name: "openai", APIBaseURL: closedURL(t), APIKey: "sk-test", Enabled: true},
+			wantStatus: doctorFail,
+			wantText:   "Unreachable: request failed",
+		},
+		{
+			name:       "key rejected",
+			provider:   config.Provider{Name: "openai", APIBaseURL: rejecting.URL, APIKey: "sk-test", Enabled: true},
+			wantStatus: doctorFail,
+			wantText:   "Unreachable: authentication failed",
+		},
+		{
+			name:       "missing key",
+			provider:   config.Provider{Name: "openai", APIBaseURL: upstream.URL, Enabled: true},
+			wantStatus: doctorFail,
+			wantText:   "No API key configured",
+		},
+		{
+			name:       "keyless local provider",
+			provider:   config.Provider{Name: "ollama", APIBaseURL: upstream.URL, Enabled: true},
+			wantStatus: doctorPass,
+			wantText:   "Reachable at",
+		},
+		{
+			name:       "mock provider",
+			provider:   config.Provider{Name: config.MockProviderName, Enabled: true},
+			wantStatus: doctorPass,
+			wantText:   "Answers requests locally",
+		},
+		{
+			name:       "disabled provider",
+			provider:   config.Provider{Name: "openai", APIBaseURL: upstream.URL, APIKey: "sk-test"},
+			wantStatus: doctorFail,
+			wantText:   "No enabled providers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &doctorReport{}
+			checkDoctorProviders(report, &config.Config{Providers: []config.Provider{tt.provider}}, false)
+
+			if len(report.Checks) != 1 {
+				t.Fatalf("Expected 1 check, got %+v", report.Checks)
+			}
+			check := report.Checks[0]
+			if check.Status != tt.wantStatus || !strings.Contains(check.Message, tt.wantText) {
+				t.Errorf("Expected %s check containing %q, got %s: %s", tt.wantStatus, tt.wantText, check.Status, check.Message)
+			}
+			if check.Status == doctorFail && check.Hint == "" {
+				t.Error("Expected a hint for a failed check")
+			}
+		})
+	}
+
+	t.Run("offline skips reachability", func(t *testing.T) {
+		report := &doctorReport{}
+		cfg := &config.Config{Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: closedURL(t), APIKey: "sk-test", Enabled: true},
+		}}
+		checkDoctorProviders(report, cfg, true)
+
+		if len(report.Checks) != 0 {
+			t.Errorf("Expected no checks offline, got %+v", report.Checks)
+		}
+	})
+}
+
+func TestCheckDoctorConfig(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(valid, []byte(`{"providers": [{"name": "mock", "models": ["mock-model"], "enabled": true}]}`), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := os.WriteFile(invalid, []byte(`{"port": 70000}`), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus string
+		wantConfig bool
+	}{
+		{name: "valid", path: valid, wantStatus: doctorPass, wantConfig: true},
+		{name: "invalid", path: invalid, wantStatus: doctorFail},
+		{name: "missing", path: filepath.Join(dir, "missing.json"), wantStatus: doctorFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &doctorReport{}
+			cfg := checkDoctorConfig(report, tt.path)
+
+			if (cfg != nil) != tt.wantConfig {
+				t.Errorf("Expected config returned %v, got %v", tt.wantConfig, cfg != nil)
+			}
+			if len(report.Checks) != 1 || report.Checks[0].Status != tt.wantStatus {
+				t.Errorf("Expected one %s check, got %+v", tt.wantStatus, report.Checks)
+			}
+		})
+	}
+}
+
+func TestCheckDoctorRoutes(t *testing.T) {
+	report := &doctorReport{}
+	checkDoctorRoutes(report, &config.Config{
+		Providers: []config.Provider{{Name: "openai", Enabled: true}, {Name: "groq"}},
+		Routes: map[string]config.Route{
+			"default":    {Provider: "openai", Model: "gpt-4o"},
+			"background": {Provider: "groq", Model: "llama3"},
+		},
+	})
+
+	if len(report.Checks) != 1 {
+		t.Fatalf("Expected 1 check, got %+v", report.Checks)
+	}
+	if check := report.Checks[0]; check.Status != doctorWarn || check.Name != "background" {
+		t.Errorf("Expected a warning for the background route, got %+v", check)
+	}
+}
+
+func TestCheckDoctorPort(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // No PID file of a running CCProxy
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	busyPort := listener.Addr().(*net.TCPAddr).Port
+
+	freeListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	freePort := freeListener.Addr().(*net.TCPAddr).Port
+	_ = freeListener.Close()
+
+	tests := []struct {
+		name       string
+		port       int
+		wantStatus string
+	}{
+		{name: "free", port: freePort, wantStatus: doctorPass},
+		{name: "in use", port: busyPort, wantStatus: doctorFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &doctorReport{}
+			checkDoctorPort(report, &config.Config{Host: "127.0.0.1", Port: tt.port})
+
+			if len(report.Checks) != 1 {
+				t.Fatalf("Expected 1 check, got %+v", report.Checks)
+			}
+			check := report.Checks[0]
+			if check.Status != tt.wantStatus || check.Name != net.JoinHostPort("127.0.0.1", strconv.Itoa(tt.port)) {
+				t.Errorf("Expected %s check for port %d, got %+v", tt.wantStatus, tt.port, check)
+			}
+		})
+	}
+}
@@ -8,7 +8,7 @@ import (
 
 // EnvCmd returns the env command
 func EnvCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "env",
 		Short: "Show CCProxy environment variables",
 		Long:  "Display the environment variables used by CCProxy and their current values",
@@ -33,6 +33,7 @@ func EnvCmd() *cobra.Command {
 			fmt.Println("Provider Configuration:")
 			fmt.Println("  ANTHROPIC_API_KEY   - Anthropic API key")
 			fmt.Println("  OPENAI_API_KEY      - OpenAI API key")
+			fmt.Println("  AZURE_OPENAI_API_KEY - Azure OpenAI API key")
 			fmt.Println("  GOOGLE_API_KEY      - Google AI API key")
 			fmt.Println("  DEEPSEEK_API_KEY    - DeepSeek API key")
 			fmt.Println("  OPENROUTER_API_KEY  - OpenRouter API key")
@@ -42,6 +43,12 @@ func EnvCmd() *cobra.Command {
 			fmt.Println("  HTTP_PROXY          - HTTP proxy URL")
 			fmt.Println("  HTTPS_PROXY         - HTTPS proxy URL")
 			fmt.Println("  NO_PROXY            - Hosts to bypass proxy")
+			fmt.Println()
+			fmt.Println("Run 'ccproxy env doctor' to check the environment, configuration and providers")
 		},
 	}
+
+	cmd.AddCommand(envDoctorCmd())
+
+	return cmd
 }
@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// providerSummary is the listing entry for a configured provider
+type providerSummary struct {
+	Name       string `json:"name"`
+	APIBaseURL string `json:"api_base_url"`
+	Enabled    bool   `json:"enabled"`
+	Models     int    `json:"models"`
+	APIKey     string `json:"api_key"`
+	Reachable  *bool  `json:"reachable,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ProvidersCmd returns the providers command
+func ProvidersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "providers",
+		Short: "Inspect configured providers",
+		Long:  "Inspect the LLM providers configured for CCProxy",
+	}
+
+	cmd.AddCommand(providersListCmd())
+
+	return cmd
+}
+
+// providersListCmd returns the providers list subcommand
+func providersListCmd() *cobra.Command {
+	var configPath string
+	var jsonOutput bool
+	var check bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List configured providers",
+		Long:  "Display the configured providers with their base URL, status, model count and API key",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configService := config.NewService()
+			if configPath != "" {
+				cfg, err := config.LoadFromFile(configPath)
+				if err != nil {
+					return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+				}
+				configService.SetConfig(cfg)
+			} else if err := configService.Load(); err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			cfg := configService.Get()
+			summaries := make([]providerSummary, len(cfg.Providers))
+			for i, provider := range cfg.Providers {
+				summaries[i] = providerSummary{
+					Name:       provider.Name,
+					APIBaseURL: provider.APIBaseURL,
+					Enabled:    provider.Enabled,
+					Models:     len(provider.Models),
+					APIKey:     utils.MaskAPIKey(provider.APIKey),
+				}
+			}
+
+			if check {
+				checkProviders(providers.NewService(configService), cfg.Providers, summaries)
+			}
+
+			if jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(summaries)
+			}
+
+			printProviders(summaries, check)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to config file")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	cmd.Flags().BoolVar(&check, "check", false, "Probe each enabled provider for reachability")
+
+	return cmd
+}
+
+// checkProviders probes enabled providers concurrently and records the results
+func checkProviders(service *providers.Service, configured []config.Provider, summaries []providerSummary) {
+	var wg sync.WaitGroup
+	for i := range configured {
+		if !configured[i].Enabled {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := service.ProbeProvider(ctx, &configured[i])
+			reachable := err == nil
+			summaries[i].Reachable = &reachable
+			if err != nil {
+				summaries[i].Error = err.Error()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// printProviders writes the provider table to stdout
+func printProviders(summaries []providerSummary, check bool) {
+	if len(summaries) == 0 {
+		fmt.Println("No providers configured")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "NAME\tBASE URL\tENABLED\tMODELS\tAPI KEY"
+	if check {
+		header += "\tREACHABLE"
+	}
+	fmt.Fprintln(w, header)
+
+	for _, s := range summaries {
+		row := fmt.Sprintf("%s\t%s\t%t\t%d\t%s", s.Name, s.APIBaseURL, s.Enabled, s.Models, s.APIKey)
+		if check {
+			switch {
+			case s.Reachable == nil:
+				row += "\t-"
+			case *s.Reachable:
+				row += "\tyes"
+			default:
+				row += "\tno (" + s.Error + ")"
+			}
+		}
+		fmt.Fprintln(w, row)
+	}
+
+	_ = w.Flush() // Safe to ignore: writing to stdout
+}
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/pipeline"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/spf13/cobra"
+)
+
+// ReplayCmd returns the replay command
+func ReplayCmd() *cobra.Command {
+	var configPath string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "replay <request-id>",
+		Short: "Send a stored request again",
+		Long: `Send a request stored with replay.store_requests through the full pipeline
+again, using the current configuration, and print the fresh response with its
+timing next to the original's. The request id is the X-Request-ID of the
+original response, also found in the access log.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true, // Failures are provider errors, not usage errors
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configService := config.NewService()
+			if configPath != "" {
+				cfg, err := config.LoadFromFile(configPath)
+				if err != nil {
+					return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+				}
+				configService.SetConfig(cfg)
+			} else if err := configService.Load(); err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+			cfg := configService.Get()
+
+			store, err := pipeline.NewRequestStore(cfg.Replay.StoreDir(), false)
+			if err != nil {
+				return err
+			}
+			stored, err := store.Load(args[0])
+			if err != nil {
+				return err
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(stored.Body, &body); err != nil {
+				return fmt.Errorf("failed to parse stored request body: %w", err)
+			}
+
+			providerService := providers.NewService(configService)
+			if err := providerService.Initialize(); err != nil {
+				return fmt.Errorf("failed to initialize provider service: %w", err)
+			}
+			transformerService := transformer.GetRegistry()
+			if err := transformerService.ConfigureProviderChains(cfg.Providers); err != nil {
+				return fmt.Errorf("failed to configure transformer chains: %w", err)
+			}
+			p := pipeline.NewPipeline(cfg, providerService, transformerService, router.New(cfg))
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			fmt.Printf("🔁 Replaying request %s from %s", stored.ID, stored.Timestamp.Format(time.RFC3339))
+			if stored.Streaming {
+				fmt.Print(" (streaming)")
+			}
+			fmt.Println()
+			if stored.Redacted {
+				fmt.Println("⚠️  The request was stored redacted, so masked values are sent as [REDACTED_...]")
+			}
+
+			start := time.Now()
+			respCtx, err := p.ProcessRequest(ctx, &pipeline.RequestContext{
+				Body:        body,
+				Headers:     map[string]string{},
+				IsStreaming: stored.Streaming,
+				Metadata:    make(map[string]interface{}),
+			})
+			if err != nil {
+				return fmt.Errorf("replayed request failed after %v: %w", time.Since(start).Round(time.Millisecond), err)
+			}
+			defer respCtx.Response.Body.Close()
+
+			statusCode := respCtx.Response.StatusCode
+			if statusCode >= http.StatusBadRequest {
+				data, _ := io.ReadAll(respCtx.Response.Body)
+				fmt.Printf("❌ Provider returned status %d: %s\n", statusCode, testErrorMessage(data))
+			} else if stored.Streaming {
+				firstByte, err := printTestStream(respCtx.Response.Body, start)
+				if err != nil {
+					return fmt.Errorf("failed to read stream: %w", err)
+				}
+				fmt.Printf("⏱️  First token: %v\n", firstByte.Round(time.Millisecond))
+			} else {
+				data, err := io.ReadAll(respCtx.Response.Body)
+				if err != nil {
+					return fmt.Errorf("failed to read response: %w", err)
+				}
+				fmt.Printf("💬 %s\n", replayResponseText(data))
+			}
+
+			elapsed := time.Since(start)
+			fmt.Printf("⏱️  Original: %v via %s/%s, status %d\n",
+				time.Duration(stored.DurationMS)*time.Millisecond, stored.Provider, stored.Model, stored.StatusCode)
+			fmt.Printf("⏱️  Replay:   %v via %s/%s, status %d (%s)\n",
+				elapsed.Round(time.Millisecond), respCtx.Provider, respCtx.Model, statusCode,
+				replayTimingDiff(elapsed, time.Duration(stored.DurationMS)*time.Millisecond))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to config file")
+	cmd.Flags().DurationVarP(&timeout, "timeout", "t", 5*time.Minute, "Request timeout")
+
+	return cmd
+}
+
+// replayResponseText returns a response body indented for reading, or as it
+// is when it is not JSON
+func replayResponseText(body []byte) string {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return string(body)
+	}
+	return indented.String()
+}
+
+// replayTimingDiff describes how much slower or faster the replay was
+func replayTimingDiff(replay, original time.Duration) string {
+	diff := (replay - original).Round(time.Millisecond)
+	switch {
+	case original <= 0:
+		return "no original timing"
+	case diff > 0:
+		return fmt.Sprintf("%v slower", diff)
+	case diff < 0:
+		return fmt.Sprintf("%v faster", -diff)
+	}
+	return "same as original"
+}
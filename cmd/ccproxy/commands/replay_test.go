@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplayTimingDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		replay   time.Duration
+		original time.Duration
+		want     string
+	}{
+		{name: "slower", replay: 1500 * time.Millisecond, original: time.Second, want: "500ms slower"},
+		{name: "faster", replay: 250 * time.Millisecond, original: time.Second, want: "750ms faster"},
+		{name: "same", replay: time.Second, original: time.Second, want: "same as original"},
+		{name: "within a millisecond", replay: time.Second + 200*time.Microsecond, original: time.Second, want: "same as original"},
+		{name: "no original timing", replay: time.Second, original: 0, want: "no original timing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replayTimingDiff(tt.replay, tt.original); got != tt.want {
+				t.Errorf("replayTimingDiff(%v, %v) = %q, want %q", tt.replay, tt.original, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayResponseText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "indents JSON", body: `{"type":"message","content":[]}`, want: "{\n  \"type\": \"message\",\n  \"content\": []\n}"},
+		{name: "keeps text", body: "upstream unavailable", want: "upstream unavailable"},
+		{name: "keeps an event stream", body: "event: ping\ndata: {}\n\n", want: "event: ping\ndata: {}\n\n"},
+		{name: "empty", body: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replayResponseText([]byte(tt.body)); got != tt.want {
+				t.Errorf("replayResponseText(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
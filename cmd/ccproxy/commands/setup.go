@@ -0,0 +1,607 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// setupPreset holds the defaults offered for a known provider
+type setupPreset struct {
+	Name    string
+	BaseURL string
+	Model   string
+}
+
+// setupPresets lists the providers offered by the setup wizard
+var setupPresets = []setupPreset{
+	{Name: "anthropic", BaseURL: "https://api.anthropic.com", Model: "claude-sonnet-4-20250514"},
+	{Name: "openai", BaseURL: "https://api.openai.com", Model: "gpt-4o"},
+	{Name: "gemini", BaseURL: "https://generativelanguage.googleapis.com", Model: "gemini-2.5-pro"},
+	{Name: "deepseek", BaseURL: "https://api.deepseek.com", Model: "deepseek-chat"},
+	{Name: "openrouter", BaseURL: "https://openrouter.ai", Model: "anthropic/claude-sonnet-4"},
+	{Name: "groq", BaseURL: "https://api.groq.com", Model: "moonshotai/kimi-k2-instruct"},
+	{Name: "mistral", BaseURL: "https://api.mistral.ai", Model: "mistral-large-latest"},
+	{Name: "xai", BaseURL: "https://api.x.ai", Model: "grok-4"},
+	{Name: "ollama", BaseURL: "http://localhost:11434", Model: "qwen2.5-coder"},
+}
+
+// setupProvider is the provider entry written by the setup wizard
+type setupProvider struct {
+	Name       string   `json:"name"`
+	APIBaseURL string   `json:"api_base_url"`
+	APIKey     string   `json:"api_key,omitempty"` // Blank reads the provider's key variable at startup
+	Models     []string `json:"models"`
+	Enabled    bool     `json:"enabled"`
+}
+
+// setupRoute is the route entry written by the setup wizard
+type setupRoute struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// setupConfig is the subset of config.json written by the setup wizard
+type setupConfig struct {
+	Host      string                `json:"host"`
+	Port      int                   `json:"port"`
+	APIKey    string                `json:"apikey,omitempty"`
+	Providers []setupProvider       `json:"providers"`
+	Routes    map[string]setupRoute `json:"routes"`
+}
+
+// SetupCmd returns the setup command
+func SetupCmd() *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "setup",
+		Short: "Create a configuration file interactively",
+		Long: `Walk through choosing providers, API keys, models and a default route,
+then write a validated config.json. An existing file is backed up first.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if configPath == "" {
+				homeDir, err := os.UserHomeDir()
+				if err != nil {
+					return fmt.Errorf("failed to get home directory: %w", err)
+				}
+				configPath = filepath.Join(homeDir, ".ccproxy", "config.json")
+			}
+
+			out := cmd.OutOrStdout()
+			prompter := &setupPrompter{in: bufio.NewReader(cmd.InOrStdin()), out: out}
+
+			fmt.Fprintln(out, "🧙 CCProxy Setup")
+			fmt.Fprintln(out, "================")
+			fmt.Fprintf(out, "Press Enter to accept the default shown in brackets.\n\n")
+
+			existing, err := os.ReadFile(configPath) // #nosec G304 - path is provided by the user
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to read existing config: %w", err)
+			}
+			if existing != nil {
+				overwrite, err := prompter.confirm(fmt.Sprintf("%s already exists. Your answers are merged into it and it is backed up first. Continue?", configPath), true)
+				if err != nil {
+					return err
+				}
+				if !overwrite {
+					fmt.Fprintln(out, "Setup canceled, nothing was written")
+					return nil
+				}
+			}
+
+			setup, err := runSetupWizard(prompter, existing)
+			if err != nil {
+				return err
+			}
+
+			data, err := json.MarshalIndent(setup, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode config: %w", err)
+			}
+			if existing != nil {
+				if data, err = mergeSetupConfig(existing, setup); err != nil {
+					return fmt.Errorf("failed to merge with %s: %w", configPath, err)
+				}
+			}
+			if err := validateSetupConfig(data); err != nil {
+				return fmt.Errorf("generated config is invalid: %w", err)
+			}
+
+			backupPath, err := writeSetupConfig(configPath, data)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(out)
+			if backupPath != "" {
+				fmt.Fprintf(out, "📦 Previous config backed up to %s\n", backupPath)
+			}
+			fmt.Fprintf(out, "✅ Configuration written to %s\n\n", configPath)
+			fmt.Fprintln(out, "Next steps:")
+			for _, provider := range setup.Providers {
+				if provider.APIKey == "" && !keylessProviders[provider.Name] {
+					if envVar, ok := config.ProviderAPIKeyEnvVars[provider.Name]; ok {
+						fmt.Fprintf(out, "  export %s=<your key>\n", envVar)
+					}
+				}
+			}
+			fmt.Fprintln(out, "  ccproxy env doctor")
+			fmt.Fprintln(out, "  ccproxy start")
+			fmt.Fprintln(out, "  ccproxy code")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to write the configuration file (default ~/.ccproxy/config.json)")
+
+	return cmd
+}
+
+// runSetupWizard prompts for providers, the default route and server settings.
+// Server settings default to those in the existing config, if any.
+func runSetupWizard(p *setupPrompter, existing []byte) (*setupConfig, error) {
+	setup := &setupConfig{
+		Host:   "127.0.0.1",
+		Port:   3456,
+		Routes: map[string]setupRoute{},
+	}
+	if existing != nil {
+		var current map[string]json.RawMessage
+		if json.Unmarshal(existing, &current) == nil {
+			_ = json.Unmarshal(current["port"], &setup.Port)
+		}
+	}
+
+	for {
+		provider, err := promptSetupProvider(p, setup.Providers)
+		if err != nil {
+			return nil, err
+		}
+		setup.Providers = append(setup.Providers, provider)
+
+		more, err := p.confirm("Add another provider?", false)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+	}
+
+	// Default route
+	fmt.Fprintln(p.out, "\n🧭 Default route")
+	names := make([]string, len(setup.Providers))
+	for i, provider := range setup.Providers {
+		names[i] = provider.Name
+	}
+	index, err := p.choose("Provider for the default route", names, 0)
+	if err != nil {
+		return nil, err
+	}
+	routeProvider := setup.Providers[index]
+	model, err := p.ask("Model for the default route", routeProvider.Models[0])
+	if err != nil {
+		return nil, err
+	}
+	setup.Routes["default"] = setupRoute{Provider: routeProvider.Name, Model: model}
+
+	// Server settings
+	fmt.Fprintln(p.out, "\n⚙️  Server")
+	for {
+		answer, err := p.ask("Port", strconv.Itoa(setup.Port))
+		if err != nil {
+			return nil, err
+		}
+		port, err := strconv.Atoi(answer)
+		if err == nil && port >= 1 && port <= 65535 {
+			setup.Port = port
+			break
+		}
+		fmt.Fprintf(p.out, "  %q is not a valid port\n", answer)
+	}
+	setup.APIKey, err = p.ask("API key clients must send to CCProxy (blank for none)", "")
+	if err != nil {
+		return nil, err
+	}
+
+	return setup, nil
+}
+
+// promptSetupProvider prompts for a single provider entry
+func promptSetupProvider(p *setupPrompter, existing []setupProvider) (setupProvider, error) {
+	fmt.Fprintln(p.out, "\n🔌 Provider")
+	options := make([]string, 0, len(setupPresets)+1)
+	for _, preset := range setupPresets {
+		options = append(options, preset.Name)
+	}
+	options = append(options, "custom (OpenAI-compatible)")
+
+	index, err := p.choose("Choose a provider", options, 0)
+	if err != nil {
+		return setupProvider{}, err
+	}
+
+	var preset setupPreset
+	if index < len(setupPresets) {
+		preset = setupPresets[index]
+	}
+
+	var provider setupProvider
+	for {
+		provider.Name, err = p.ask("Provider name", preset.Name)
+		if err != nil {
+			return setupProvider{}, err
+		}
+		if provider.Name == "" {
+			fmt.Fprintln(p.out, "  A provider name is required")
+			continue
+		}
+		if setupProviderExists(existing, provider.Name) {
+			fmt.Fprintf(p.out, "  Provider %q was already added\n", provider.Name)
+			continue
+		}
+		break
+	}
+
+	for {
+		provider.APIBaseURL, err = p.ask("API base URL", preset.BaseURL)
+		if err != nil {
+			return setupProvider{}, err
+		}
+		if strings.HasPrefix(provider.APIBaseURL, "http://") || strings.HasPrefix(provider.APIBaseURL, "https://") {
+			break
+		}
+		fmt.Fprintln(p.out, "  The base URL must start with http:// or https://")
+	}
+
+	if !keylessProviders[provider.Name] {
+		question := "API key"
+		if envVar, ok := config.ProviderAPIKeyEnvVars[provider.Name]; ok {
+			question = fmt.Sprintf("API key (blank to read %s at startup)", envVar)
+		}
+		provider.APIKey, err = p.ask(question, "")
+		if err != nil {
+			return setupProvider{}, err
+		}
+	}
+
+	for {
+		answer, err := p.ask("Models (comma-separated)", preset.Model)
+		if err != nil {
+			return setupProvider{}, err
+		}
+		provider.Models = splitSetupList(answer)
+		if len(provider.Models) > 0 {
+			break
+		}
+		fmt.Fprintln(p.out, "  At least one model is required")
+	}
+
+	provider.Enabled = true
+	return provider, nil
+}
+
+// setupProviderExists reports whether name was already added
+func setupProviderExists(providers []setupProvider, name string) bool {
+	for _, provider := range providers {
+		if strings.EqualFold(provider.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSetupList splits a comma-separated answer, dropping empty entries
+func splitSetupList(answer string) []string {
+	var items []string
+	for _, item := range strings.Split(answer, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// mergeSetupConfig merges the wizard's answers into an existing config file.
+// Settings the wizard does not ask about are kept in their order, and the
+// host is only added when missing. Providers are matched by name and routes
+// by key, so only the fields the wizard sets change, and blank API keys keep
+// the existing ones.
+func mergeSetupConfig(existing []byte, setup *setupConfig) ([]byte, error) {
+	top := map[string]interface{}{"port": setup.Port}
+	if setup.APIKey != "" {
+		top["apikey"] = setup.APIKey
+	}
+	merged, err := mergeJSONObject(existing, top)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := decodeJSONObject(merged)
+	if err != nil {
+		return nil, err
+	}
+	hasHost := false
+	for _, field := range fields {
+		hasHost = hasHost || field.key == "host"
+	}
+	if !hasHost {
+		host, _ := json.Marshal(setup.Host)
+		fields = setJSONField(fields, "host", host)
+	}
+	var providers []json.RawMessage
+	var routes json.RawMessage = []byte("{}")
+	for _, field := range fields {
+		switch field.key {
+		case "providers":
+			if err := json.Unmarshal(field.value, &providers); err != nil {
+				return nil, fmt.Errorf("invalid providers: %w", err)
+			}
+		case "routes":
+			routes = field.value
+		}
+	}
+
+	for _, provider := range setup.Providers {
+		index := -1
+		for i, entry := range providers {
+			var named struct {
+				Name string `json:"name"`
+			}
+			if json.Unmarshal(entry, &named) == nil && named.Name == provider.Name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			data, err := json.Marshal(provider)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, data)
+			continue
+		}
+		if providers[index], err = mergeJSONObject(providers[index], provider); err != nil {
+			return nil, fmt.Errorf("invalid provider %s: %w", provider.Name, err)
+		}
+	}
+
+	routeFields, err := decodeJSONObject(routes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid routes: %w", err)
+	}
+	for name, route := range setup.Routes {
+		base := json.RawMessage("{}")
+		for _, field := range routeFields {
+			if field.key == name {
+				base = field.value
+			}
+		}
+		data, err := mergeJSONObject(base, route)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route %s: %w", name, err)
+		}
+		routeFields = setJSONField(routeFields, name, data)
+	}
+
+	providersData, err := json.Marshal(providers)
+	if err != nil {
+		return nil, err
+	}
+	fields = setJSONField(fields, "providers", providersData)
+	fields = setJSONField(fields, "routes", encodeJSONObject(routeFields))
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, encodeJSONObject(fields), "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// jsonField is one member of a JSON object
+type jsonField struct {
+	key   string
+	value json.RawMessage
+}
+
+// decodeJSONObject returns the members of a JSON object in the order they
+// appear
+func decodeJSONObject(data []byte) ([]jsonField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var fields []jsonField
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = setJSONField(fields, key, value)
+	}
+	return fields, nil
+}
+
+// encodeJSONObject encodes members as a compact JSON object
+func encodeJSONObject(fields []jsonField) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(field.value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// setJSONField replaces the value of a member in place, or appends it
+func setJSONField(fields []jsonField, key string, value json.RawMessage) []jsonField {
+	for i := range fields {
+		if fields[i].key == key {
+			fields[i].value = value
+			return fields
+		}
+	}
+	return append(fields, jsonField{key: key, value: value})
+}
+
+// mergeJSONObject sets the members of update, encoded as a JSON object, on
+// the object base
+func mergeJSONObject(base []byte, update interface{}) ([]byte, error) {
+	fields, err := decodeJSONObject(base)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+	updates, err := decodeJSONObject(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range updates {
+		fields = setJSONField(fields, field.key, field.value)
+	}
+	return encodeJSONObject(fields), nil
+}
+
+// validateSetupConfig loads the generated file from a temporary copy with the
+// config service, the way the server loads config.json, and runs the service
+// validation on it
+func validateSetupConfig(data []byte) error {
+	dir, err := os.MkdirTemp("", "ccproxy-setup-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write temporary config: %w", err)
+	}
+
+	service := config.NewService()
+	service.SetConfigFile(path)
+	if err := service.Load(); err != nil {
+		return err
+	}
+	return service.Validate()
+}
+
+// writeSetupConfig writes data to path, backing up any existing file first.
+// It returns the backup path, or an empty string when there was no file.
+func writeSetupConfig(path string, data []byte) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	backupPath := ""
+	existing, err := os.ReadFile(path) // #nosec G304 - path is provided by the user
+	if err == nil {
+		backupPath = fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102-150405"))
+		if err := utils.WriteFileAtomic(backupPath, existing, 0600); err != nil {
+			return "", fmt.Errorf("failed to back up existing config: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read existing config: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return "", fmt.Errorf("failed to write config: %w", err)
+	}
+	return backupPath, nil
+}
+
+// setupPrompter reads answers to setup questions line by line
+type setupPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prints question and returns the trimmed answer, or def when it is empty
+func (p *setupPrompter) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", fmt.Errorf("setup canceled: %w", err)
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// confirm asks a yes/no question
+func (p *setupPrompter) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		answer, err := p.ask(fmt.Sprintf("%s (%s)", question, hint), "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(p.out, "  Please answer y or n")
+	}
+}
+
+// choose lists options and returns the index of the selected one
+func (p *setupPrompter) choose(question string, options []string, def int) (int, error) {
+	for i, option := range options {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, option)
+	}
+	for {
+		answer, err := p.ask(question, strconv.Itoa(def+1))
+		if err != nil {
+			return 0, err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+		for i, option := range options {
+			if strings.EqualFold(answer, option) {
+				return i, nil
+			}
+		}
+		fmt.Fprintf(p.out, "  Enter a number between 1 and %d\n", len(options))
+	}
+}
@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWriteSetupConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "config.json")
+
+	backup, err := writeSetupConfig(path, []byte(`{"port": 3456}`))
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if backup != "" {
+		t.Errorf("Expected no backup for a new file, got %s", backup)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected the config to be written: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+
+	backup, err = writeSetupConfig(path, []byte(`{"port": 4000}`))
+	if err != nil {
+		t.Fatalf("Failed to overwrite config: %v", err)
+	}
+	if backup == "" {
+		t.Fatal("Expected the existing file to be backed up")
+	}
+	saved, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if string(saved) != "{\"port\": 3456}\n" {
+		t.Errorf("Expected the backup to hold the previous config, got %q", saved)
+	}
+	if info, err := os.Stat(backup); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected backup mode 0600, got %o", info.Mode().Perm())
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if string(written) != "{\"port\": 4000}\n" {
+		t.Errorf("Expected the new config, got %q", written)
+	}
+}
+
+func TestMergeSetupConfig(t *testing.T) {
+	existing := []byte(`{
+  "log": true,
+  "host": "0.0.0.0",
+  "port": 3456,
+  "apikey": "existing-key",
+  "providers": [
+    {"name": "anthropic", "api_base_url": "https://api.anthropic.com", "api_key": "${ANTHROPIC_API_KEY}", "models": ["claude-3-haiku"], "enabled": false, "headers": {"anthropic-beta": "tools"}},
+    {"name": "groq", "api_base_url": "https://api.groq.com/openai", "api_key": "gsk", "models": ["llama3"], "enabled": true}
+  ],
+  "routes": {
+    "longContext": {"provider": "groq", "model": "llama3"},
+    "default": {"provider": "groq", "model": "llama3", "parameters": {"temperature": 0.2}}
+  },
+  "security": {"allowed_origins": ["https://app.example.com"]},
+  "shutdown_timeout": "30s"
+}`)
+	setup := &setupConfig{
+		Host: "127.0.0.1",
+		Port: 4000,
+		Providers: []setupProvider{
+			{Name: "anthropic", APIBaseURL: "https://api.anthropic.com", Models: []string{"claude-3-sonnet"}, Enabled: true},
+			{Name: "openai", APIBaseURL: "https://api.openai.com", APIKey: "sk-new", Models: []string{"gpt-4o"}, Enabled: true},
+		},
+		Routes: map[string]setupRoute{"default": {Provider: "anthropic", Model: "claude-3-sonnet"}},
+	}
+
+	data, err := mergeSetupConfig(existing, setup)
+	if err != nil {
+		t.Fatalf("Failed to merge config: %v", err)
+	}
+	if err := validateSetupConfig(data); err != nil {
+		t.Fatalf("Expected a valid merged config, got: %v\n%s", err, data)
+	}
+
+	// Settings keep their place in the file
+	text := string(data)
+	order := []string{`"log"`, `"host"`, `"port"`, `"apikey"`, `"providers"`, `"routes"`, `"security"`, `"shutdown_timeout"`}
+	for i := 1; i < len(order); i++ {
+		if strings.Index(text, order[i-1]) > strings.Index(text, order[i]) {
+			t.Errorf("Expected %s before %s, got:\n%s", order[i-1], order[i], text)
+		}
+	}
+
+	var merged struct {
+		Log       bool   `json:"log"`
+		Host      string `json:"host"`
+		Port      int    `json:"port"`
+		APIKey    string `json:"apikey"`
+		Providers []struct {
+			Name    string            `json:"name"`
+			APIKey  string            `json:"api_key"`
+			Models  []string          `json:"models"`
+			Enabled bool              `json:"enabled"`
+			Headers map[string]string `json:"headers"`
+		} `json:"providers"`
+		Routes map[string]struct {
+			Provider   string                 `json:"provider"`
+			Model      string                 `json:"model"`
+			Parameters map[string]interface{} `json:"parameters"`
+		} `json:"routes"`
+		Security struct {
+			AllowedOrigins []string `json:"allowed_origins"`
+		} `json:"security"`
+	}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		t.Fatalf("Failed to parse merged config: %v", err)
+	}
+
+	if !merged.Log || merged.Host != "0.0.0.0" || merged.Port != 4000 || merged.APIKey != "existing-key" {
+		t.Errorf("Unexpected top-level settings: %+v", merged)
+	}
+	if len(merged.Security.AllowedOrigins) != 1 {
+		t.Errorf("Expected security settings to be kept, got %+v", merged.Security)
+	}
+
+	if len(merged.Providers) != 3 {
+		t.Fatalf("Expected 3 providers, got %+v", merged.Providers)
+	}
+	anthropic, groq, openai := merged.Providers[0], merged.Providers[1], merged.Providers[2]
+	if anthropic.Name != "anthropic" || !anthropic.Enabled || anthropic.Models[0] != "claude-3-sonnet" {
+		t.Errorf("Expected anthropic to be updated, got %+v", anthropic)
+	}
+	if anthropic.APIKey != "${ANTHROPIC_API_KEY}" || anthropic.Headers["anthropic-beta"] != "tools" {
+		t.Errorf("Expected anthropic's key and headers to be kept, got %+v", anthropic)
+	}
+	if groq.Name != "groq" || groq.APIKey != "gsk" {
+		t.Errorf("Expected groq to be kept, got %+v", groq)
+	}
+	if openai.Name != "openai" || openai.APIKey != "sk-new" {
+		t.Errorf("Expected openai to be added, got %+v", openai)
+	}
+
+	if route := merged.Routes["default"]; route.Provider != "anthropic" || route.Model != "claude-3-sonnet" || route.Parameters["temperature"] != 0.2 {
+		t.Errorf("Expected the default route to be updated in place, got %+v", route)
+	}
+	if route := merged.Routes["longContext"]; route.Provider != "groq" {
+		t.Errorf("Expected other routes to be kept, got %+v", merged.Routes)
+	}
+
+	// The wizard does not ask for the host, so it is only added when missing
+	data, err = mergeSetupConfig([]byte(`{"log": true}`), setup)
+	if err != nil {
+		t.Fatalf("Failed to merge config: %v", err)
+	}
+	if !strings.Contains(string(data), `"host": "127.0.0.1"`) {
+		t.Errorf("Expected the default host to be added, got:\n%s", data)
+	}
+
+	if _, err := mergeSetupConfig([]byte(`["not", "an", "object"]`), setup); err == nil {
+		t.Error("Expected an error merging into a file that is not a JSON object")
+	}
+}
+
+func TestValidateSetupConfig(t *testing.T) {
+	valid := `{
+  "host": "127.0.0.1",
+  "port": 3456,
+  "providers": [{"name": "openai", "api_base_url": "https://api.openai.com", "api_key": "sk-test", "models": ["gpt-4o"], "enabled": true}],
+  "routes": {"default": {"provider": "openai", "model": "gpt-4o"}}
+}`
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: valid},
+		{name: "duration string", data: strings.Replace(valid, `"port": 3456,`, `"port": 3456, "shutdown_timeout": "30s",`, 1)},
+		{name: "not JSON", data: `{"port": `, wantErr: "error reading config file"},
+		{name: "invalid port", data: strings.Replace(valid, "3456", "70000", 1), wantErr: "port"},
+		{name: "invalid base URL", data: strings.Replace(valid, "https://api.openai.com", "api.openai.com", 1), wantErr: "http or https"},
+		{name: "unknown route provider", data: strings.Replace(valid, `"provider": "openai"`, `"provider": "missing"`, 1), wantErr: "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSetupConfig([]byte(tt.data))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRunSetupWizardKeepsPort(t *testing.T) {
+	// Accept every default: the first preset, one provider and no client key
+	answers := strings.Repeat("\n", 10)
+	prompter := &setupPrompter{in: bufio.NewReader(strings.NewReader(answers)), out: io.Discard}
+
+	setup, err := runSetupWizard(prompter, []byte(`{"host": "0.0.0.0", "port": 4000}`))
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if setup.Port != 4000 {
+		t.Errorf("Expected the existing port to be the default, got %d", setup.Port)
+	}
+	if route := setup.Routes["default"]; route.Provider != setupPresets[0].Name || route.Model != setupPresets[0].Model {
+		t.Errorf("Expected the default route to use the first preset, got %+v", route)
+	}
+}
@@ -53,11 +53,15 @@ func StartCmd() *cobra.Command {
 			}
 
 			// Initialize logger
+			logFormat := cfg.Logging.Format
+			if logFormat == "" {
+				logFormat = "json"
+			}
 			if err := utils.InitLogger(&utils.LogConfig{
 				Enabled:  cfg.Log,
 				FilePath: cfg.LogFile,
 				Level:    "info",
-				Format:   "json",
+				Format:   logFormat,
 			}); err != nil {
 				return fmt.Errorf("failed to initialize logger: %w", err)
 			}
@@ -99,11 +103,11 @@ func StartCmd() *cobra.Command {
 			}
 
 			// Start in background
-			return startInBackground(cfg)
+			return startInBackground(cfg, configPath)
 		},
 	}
 
-	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to config file")
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path or http(s) URL of config file, - reads stdin")
 	cmd.Flags().BoolVarP(&foreground, "foreground", "f", false, "Run in foreground")
 
 	return cmd
@@ -163,8 +167,14 @@ func runInForeground(cfg *config.Config, pidManager *process.PIDManager, configP
 	}
 }
 
-// startInBackground starts the server in the background
-func startInBackground(cfg *config.Config) error {
+// startInBackground starts the server in the background, passing configPath
+// on to the background process
+func startInBackground(cfg *config.Config, configPath string) error {
+	// The background process cannot read the parent's stdin
+	if configPath == config.StdinPath {
+		return fmt.Errorf("reading the config from stdin requires --foreground")
+	}
+
 	// Check if we're already running in foreground mode to prevent infinite spawning
 	if os.Getenv("CCPROXY_FOREGROUND") == "1" {
 		return fmt.Errorf("cannot start background process from foreground mode")
@@ -231,7 +241,11 @@ func startInBackground(cfg *config.Config) error {
 	}
 
 	// Prepare the background process command
-	cmd := exec.Command(execPath, "start", "--foreground") // #nosec G204 - execPath comes from utils.GetExecutablePath() which is trusted
+	args := []string{"start", "--foreground"}
+	if configPath != "" {
+		args = append(args, "--config", configPath)
+	}
+	cmd := exec.Command(execPath, args...) // #nosec G204 - execPath comes from utils.GetExecutablePath() which is trusted
 	cmd.Env = append(os.Environ(),
 		"CCPROXY_FOREGROUND=1",
 		fmt.Sprintf("CCPROXY_SPAWN_DEPTH=%d", spawnDepth+1),
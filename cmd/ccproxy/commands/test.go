@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/pipeline"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/spf13/cobra"
+)
+
+// testPrompt is the canned prompt sent by the test command
+const testPrompt = "Say hello in one short sentence."
+
+// TestCmd returns the test command
+func TestCmd() *cobra.Command {
+	var configPath string
+	var model string
+	var stream bool
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "test <provider>",
+		Short: "Send a test completion to a provider",
+		Long: `Send a tiny "say hello" request to a provider through the full pipeline
+(routing, transformation and HTTP) and print the response with timing`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true, // Failures are provider errors, not usage errors
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configService := config.NewService()
+			if configPath != "" {
+				cfg, err := config.LoadFromFile(configPath)
+				if err != nil {
+					return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+				}
+				configService.SetConfig(cfg)
+			} else if err := configService.Load(); err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+			cfg := configService.Get()
+
+			provider, err := findTestProvider(cfg, args[0])
+			if err != nil {
+				return err
+			}
+			if model == "" {
+				if len(provider.Models) == 0 {
+					return fmt.Errorf("provider %s has no models configured, use --model to choose one", provider.Name)
+				}
+				model = provider.Models[0]
+			}
+
+			providerService := providers.NewService(configService)
+			if err := providerService.Initialize(); err != nil {
+				return fmt.Errorf("failed to initialize provider service: %w", err)
+			}
+			transformerService := transformer.GetRegistry()
+			if err := transformerService.ConfigureProviderChains(cfg.Providers); err != nil {
+				return fmt.Errorf("failed to configure transformer chains: %w", err)
+			}
+			p := pipeline.NewPipeline(cfg, providerService, transformerService, router.New(cfg))
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			fmt.Printf("🧪 Testing %s with model %s", provider.Name, model)
+			if stream {
+				fmt.Print(" (streaming)")
+			}
+			fmt.Println()
+
+			start := time.Now()
+			respCtx, err := p.ProcessRequest(ctx, &pipeline.RequestContext{
+				Body: map[string]interface{}{
+					// The explicit provider,model form bypasses the routing rules
+					"model":      provider.Name + "," + model,
+					"max_tokens": 64,
+					"stream":     stream,
+					"messages": []interface{}{
+						map[string]interface{}{"role": "user", "content": testPrompt},
+					},
+				},
+				Headers:     map[string]string{},
+				IsStreaming: stream,
+				Metadata:    make(map[string]interface{}),
+			})
+			if err != nil {
+				return fmt.Errorf("test request failed after %v: %w", time.Since(start).Round(time.Millisecond), err)
+			}
+			defer respCtx.Response.Body.Close()
+
+			if respCtx.Response.StatusCode >= http.StatusBadRequest {
+				body, _ := io.ReadAll(respCtx.Response.Body)
+				return fmt.Errorf("provider returned status %d after %v: %s",
+					respCtx.Response.StatusCode, time.Since(start).Round(time.Millisecond), testErrorMessage(body))
+			}
+
+			if stream {
+				firstByte, err := printTestStream(respCtx.Response.Body, start)
+				if err != nil {
+					return fmt.Errorf("failed to read stream: %w", err)
+				}
+				fmt.Printf("⏱️  First token: %v\n", firstByte.Round(time.Millisecond))
+			} else {
+				body, err := io.ReadAll(respCtx.Response.Body)
+				if err != nil {
+					return fmt.Errorf("failed to read response: %w", err)
+				}
+				fmt.Printf("💬 %s\n", testResponseText(body))
+			}
+
+			fmt.Printf("⏱️  Total: %v\n", time.Since(start).Round(time.Millisecond))
+			fmt.Printf("✅ %s is working (routed to %s/%s)\n", provider.Name, respCtx.Provider, respCtx.Model)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to config file")
+	cmd.Flags().StringVarP(&model, "model", "m", "", "Model to test (defaults to the provider's first model)")
+	cmd.Flags().BoolVar(&stream, "stream", false, "Exercise the streaming path")
+	cmd.Flags().DurationVarP(&timeout, "timeout", "t", time.Minute, "Request timeout")
+
+	return cmd
+}
+
+// findTestProvider returns the named provider, which must be enabled
+func findTestProvider(cfg *config.Config, name string) (*config.Provider, error) {
+	for i := range cfg.Providers {
+		if cfg.Providers[i].Name != name {
+			continue
+		}
+		if !cfg.Providers[i].Enabled {
+			return nil, fmt.Errorf("provider %s is disabled", name)
+		}
+		return &cfg.Providers[i], nil
+	}
+	return nil, fmt.Errorf("provider %s is not configured", name)
+}
+
+// printTestStream prints streamed text as it arrives and returns the time to
+// the first event
+func printTestStream(body io.ReadCloser, start time.Time) (time.Duration, error) {
+	reader := transformer.NewSSEReader(body)
+	defer reader.Close()
+
+	var firstByte time.Duration
+	fmt.Print("💬 ")
+	for {
+		event, err := reader.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return firstByte, err
+		}
+		if firstByte == 0 {
+			firstByte = time.Since(start)
+		}
+
+		var data struct {
+			Delta struct {
+				Text string `json:"text"`
+			} `json:"delta"`
+		}
+		if event.Event == "content_block_delta" && json.Unmarshal([]byte(event.Data), &data) == nil {
+			fmt.Print(data.Delta.Text)
+		}
+	}
+	fmt.Println()
+	return firstByte, nil
+}
+
+// testResponseText extracts the text of an Anthropic or OpenAI-style
+// response, falling back to the raw body
+func testResponseText(body []byte) string {
+	var message struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil {
+		return string(body)
+	}
+
+	var text string
+	for _, block := range message.Content {
+		if block.Type == "text" {
+			text += block.Text
+		}
+	}
+	if text == "" && len(message.Choices) > 0 {
+		text = message.Choices[0].Message.Content
+	}
+	if text == "" {
+		return string(body)
+	}
+	return text
+}
+
+// testErrorMessage extracts the message from an error response body
+func testErrorMessage(body []byte) string {
+	var envelope struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error.Message != "" {
+		return fmt.Sprintf("%s (%s)", envelope.Error.Message, envelope.Error.Type)
+	}
+	return string(body)
+}
@@ -53,6 +53,10 @@ func init() {
 	rootCmd.AddCommand(commands.ClaudeCmd())
 	rootCmd.AddCommand(commands.VersionCmd())
 	rootCmd.AddCommand(commands.EnvCmd())
+	rootCmd.AddCommand(commands.ProvidersCmd())
+	rootCmd.AddCommand(commands.TestCmd())
+	rootCmd.AddCommand(commands.ReplayCmd())
+	rootCmd.AddCommand(commands.SetupCmd())
 }
 
 func main() {
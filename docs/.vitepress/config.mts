@@ -114,6 +114,7 @@ export default withMermaid(defineConfig({
             { text: 'Overview', link: '/api/' },
             { text: 'Architecture', link: '/api/architecture' },
             { text: 'Messages API', link: '/api/messages' },
+            { text: 'Embeddings API', link: '/api/embeddings' },
             { text: 'Claude Code Integration', link: '/api/claude-code' },
             { text: 'Health API', link: '/api/health' },
             { text: 'Status API', link: '/api/status' },
@@ -132,7 +133,8 @@ export default withMermaid(defineConfig({
             { text: 'Groq', link: '/providers/groq' },
             { text: 'OpenRouter', link: '/providers/openrouter' },
             { text: 'xAI', link: '/providers/xai' },
-            { text: 'Ollama', link: '/providers/ollama' }
+            { text: 'Ollama', link: '/providers/ollama' },
+            { text: 'Mock', link: '/providers/mock' }
           ]
         }
       ],
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -54,6 +55,12 @@ func NewService() *Service {
 	}
 }
 
+// SetConfigFile makes Load read path instead of searching the default
+// locations for config.json
+func (s *Service) SetConfigFile(path string) {
+	s.viper.SetConfigFile(path)
+}
+
 // Load reads and parses the configuration from all sources
 func (s *Service) Load() error {
 	// Step 1: Load defaults (already set in NewService)
@@ -81,6 +88,7 @@ func (s *Service) Load() error {
 		DecodeHook: mapstructure.ComposeDecodeHookFunc(
 			mapstructure.StringToTimeHookFunc(time.RFC3339),
 			mapstructure.StringToTimeDurationHookFunc(),
+			stringToTransformerConfigHook,
 		),
 		Result:           s.config,
 		WeaklyTypedInput: true,
@@ -91,7 +99,13 @@ func (s *Service) Load() error {
 		return fmt.Errorf("error creating decoder: %w", err)
 	}
 
-	if err := decoder.Decode(s.viper.AllSettings()); err != nil {
+	// Substitute ${VAR} references in string values
+	settings, err := expandEnv(s.viper.AllSettings())
+	if err != nil {
+		return fmt.Errorf("error expanding config: %w", err)
+	}
+
+	if err := decoder.Decode(settings); err != nil {
 		return fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
@@ -103,6 +117,11 @@ func (s *Service) Load() error {
 	// Step 7: Apply special environment variable mappings
 	s.applyEnvironmentMappings()
 
+	// Step 8: Read keys mounted as files for providers still without one
+	if err := resolveAPIKeyFiles(s.config.Providers); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	return nil
 }
 
@@ -152,7 +171,7 @@ func (s *Service) Validate() error {
 		}
 		providerNames[provider.Name] = true
 
-		if provider.APIBaseURL == "" {
+		if provider.APIBaseURL == "" && provider.Name != MockProviderName {
 			return fmt.Errorf("provider %s: api_base_url cannot be empty", provider.Name)
 		}
 	}
@@ -179,6 +198,8 @@ func (s *Service) Reload() error {
 	}
 
 	// Update the configuration atomically
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.config = newService.config
 	s.viper = newService.viper
 
@@ -244,6 +265,24 @@ func (s *Service) UpdateProvider(name string, provider *Provider) error {
 	return fmt.Errorf("provider not found: %s", name)
 }
 
+// SetProviderEnabled enables or disables a provider in the live configuration
+// without saving it, and returns the updated provider
+func (s *Service) SetProviderEnabled(name string, enabled bool) (*Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.config.Providers {
+		if s.config.Providers[i].Name == name {
+			s.config.Providers[i].Enabled = enabled
+			s.config.Providers[i].UpdatedAt = time.Now()
+			provider := s.config.Providers[i]
+			return &provider, nil
+		}
+	}
+
+	return nil, fmt.Errorf("provider not found: %s", name)
+}
+
 // SaveProvider saves a new provider
 func (s *Service) SaveProvider(provider *Provider) error {
 	s.mu.Lock()
@@ -337,6 +376,24 @@ func (s *Service) loadEnvFile() error {
 	return os.ErrNotExist
 }
 
+// ProviderAPIKeyEnvVars maps provider names to the environment variable that
+// supplies their API key
+var ProviderAPIKeyEnvVars = map[string]string{
+	"anthropic":  "ANTHROPIC_API_KEY",
+	"openai":     "OPENAI_API_KEY",
+	"azure":      "AZURE_OPENAI_API_KEY",
+	"gemini":     "GEMINI_API_KEY",
+	"google":     "GOOGLE_API_KEY", // Alternate for Gemini
+	"deepseek":   "DEEPSEEK_API_KEY",
+	"openrouter": "OPENROUTER_API_KEY",
+	"groq":       "GROQ_API_KEY",
+	"mistral":    "MISTRAL_API_KEY",
+	"xai":        "XAI_API_KEY",
+	"grok":       "GROK_API_KEY", // Alternate for XAI
+	"ollama":     "OLLAMA_API_KEY",
+	"bedrock":    "AWS_ACCESS_KEY_ID", // AWS Bedrock uses AWS credentials
+}
+
 // applyEnvironmentMappings applies special environment variable mappings
 func (s *Service) applyEnvironmentMappings() {
 	// Map common environment variables to config
@@ -360,31 +417,33 @@ func (s *Service) applyEnvironmentMappings() {
 		}
 	}
 
-	// Map provider-specific API keys
-	providerEnvMap := map[string]string{
-		"anthropic":  "ANTHROPIC_API_KEY",
-		"openai":     "OPENAI_API_KEY",
-		"gemini":     "GEMINI_API_KEY",
-		"google":     "GOOGLE_API_KEY", // Alternate for Gemini
-		"deepseek":   "DEEPSEEK_API_KEY",
-		"openrouter": "OPENROUTER_API_KEY",
-		"groq":       "GROQ_API_KEY",
-		"mistral":    "MISTRAL_API_KEY",
-		"xai":        "XAI_API_KEY",
-		"grok":       "GROK_API_KEY", // Alternate for XAI
-		"ollama":     "OLLAMA_API_KEY",
-		"bedrock":    "AWS_ACCESS_KEY_ID", // AWS Bedrock uses AWS credentials
-	}
-
 	// Apply provider-specific environment variables
 	for i := range s.config.Providers {
-		// Check if there's a provider-specific environment variable
-		if envVar, exists := providerEnvMap[strings.ToLower(s.config.Providers[i].Name)]; exists {
+		// Check if there's a provider-specific environment variable. A key
+		// file configured for the provider takes precedence over it.
+		if envVar, exists := ProviderAPIKeyEnvVars[strings.ToLower(s.config.Providers[i].Name)]; exists &&
+			s.config.Providers[i].APIKeyFile == "" {
 			if apiKey := os.Getenv(envVar); apiKey != "" {
 				s.config.Providers[i].APIKey = apiKey
 			}
 		}
 
+		// OpenRouter app attribution headers
+		if strings.ToLower(s.config.Providers[i].Name) == "openrouter" {
+			openRouterHeaders := map[string]string{
+				"OPENROUTER_SITE_URL":  "HTTP-Referer",
+				"OPENROUTER_SITE_NAME": "X-Title",
+			}
+			for envVar, header := range openRouterHeaders {
+				if value := os.Getenv(envVar); value != "" {
+					if s.config.Providers[i].Headers == nil {
+						s.config.Providers[i].Headers = make(map[string]string)
+					}
+					s.config.Providers[i].Headers[header] = value
+				}
+			}
+		}
+
 		// Special handling for AWS Bedrock which needs two credentials
 		if strings.ToLower(s.config.Providers[i].Name) == "bedrock" {
 			if accessKey := os.Getenv("AWS_ACCESS_KEY_ID"); accessKey != "" {
@@ -406,3 +465,12 @@ func (s *Service) applyEnvironmentMappings() {
 		}
 	}
 }
+
+// stringToTransformerConfigHook decodes a bare transformer name into a
+// TransformerConfig when loading through viper
+func stringToTransformerConfigHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(TransformerConfig{}) {
+		return data, nil
+	}
+	return TransformerConfig{Name: data.(string)}, nil
+}
@@ -405,6 +405,29 @@ func TestService_Load_ComprehensiveScenarios(t *testing.T) {
 		}
 	})
 
+	t.Run("Load transformer names", func(t *testing.T) {
+		service := NewService()
+		service.viper.Set("providers", []interface{}{
+			map[string]interface{}{
+				"name":         "openai",
+				"api_base_url": "https://api.openai.com/v1",
+				"transformers": []interface{}{
+					"tooluse",
+					map[string]interface{}{"name": "maxtoken", "config": map[string]interface{}{"max_tokens": 4096}},
+				},
+			},
+		})
+
+		if err := service.Load(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		transformers := service.Get().Providers[0].Transformers
+		if len(transformers) != 2 || transformers[0].Name != "tooluse" || transformers[1].Name != "maxtoken" {
+			t.Errorf("Expected tooluse and maxtoken transformers, got %+v", transformers)
+		}
+	})
+
 	t.Run("Load with config validation failure", func(t *testing.T) {
 		tempDir := t.TempDir()
 
@@ -1282,6 +1305,30 @@ func TestService_ApplyProviderEnvironmentMappings(t *testing.T) {
 		}
 	})
 
+	t.Run("OpenRouter attribution headers", func(t *testing.T) {
+		t.Setenv("OPENROUTER_SITE_URL", "https://yourapp.com")
+		t.Setenv("OPENROUTER_SITE_NAME", "Your App Name")
+
+		service := NewService()
+		service.config.Providers = []Provider{
+			{Name: "openrouter", Headers: map[string]string{"X-Custom": "kept"}},
+			{Name: "openai"},
+		}
+
+		service.applyEnvironmentMappings()
+
+		headers := service.Get().Providers[0].Headers
+		if headers["HTTP-Referer"] != "https://yourapp.com" || headers["X-Title"] != "Your App Name" {
+			t.Errorf("Expected OpenRouter attribution headers, got %v", headers)
+		}
+		if headers["X-Custom"] != "kept" {
+			t.Errorf("Expected configured headers to be kept, got %v", headers)
+		}
+		if service.Get().Providers[1].Headers != nil {
+			t.Errorf("Expected other providers to be untouched, got %v", service.Get().Providers[1].Headers)
+		}
+	})
+
 	t.Run("AWS Bedrock special handling", func(t *testing.T) {
 		originalAccessKey := os.Getenv("AWS_ACCESS_KEY_ID")
 		originalSecretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
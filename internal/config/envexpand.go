@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// envReferencePattern matches ${VAR} and ${VAR:-default} references
+var envReferencePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces environment variable references in every string value
+// of a decoded JSON tree. ${VAR:-default} uses the default when VAR is unset
+// or empty, and ${VAR} fails when VAR is unset.
+func expandEnv(value interface{}) (interface{}, error) {
+	return expandEnvAt(value, "")
+}
+
+// expandEnvAt expands value, naming path in errors
+func expandEnvAt(value interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return expandEnvString(v, path)
+	case map[string]interface{}:
+		// Expand in key order so the first error is deterministic
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			expanded, err := expandEnvAt(v[key], childPath)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = expanded
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			expanded, err := expandEnvAt(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+// expandEnvString expands the references in a single string
+func expandEnvString(s, path string) (string, error) {
+	var missing string
+	expanded := envReferencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		match := envReferencePattern.FindStringSubmatch(ref)
+		name, hasDefault, fallback := match[1], match[2] != "", match[3]
+
+		value, set := os.LookupEnv(name)
+		switch {
+		case hasDefault && value == "":
+			return fallback
+		case !set:
+			if missing == "" {
+				missing = name
+			}
+			return ref
+		}
+		return value
+	})
+
+	if missing != "" {
+		return "", fmt.Errorf("environment variable %s referenced by %s is not set", missing, path)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnvString(t *testing.T) {
+	t.Setenv("CCPROXY_TEST_KEY", "sk-secret")
+	t.Setenv("CCPROXY_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{"Literal", "sk-literal", "sk-literal", ""},
+		{"Variable", "${CCPROXY_TEST_KEY}", "sk-secret", ""},
+		{"Embedded", "Bearer ${CCPROXY_TEST_KEY}!", "Bearer sk-secret!", ""},
+		{"DefaultUnused", "${CCPROXY_TEST_KEY:-fallback}", "sk-secret", ""},
+		{"DefaultWhenUnset", "${CCPROXY_TEST_UNSET:-fallback}", "fallback", ""},
+		{"DefaultWhenEmpty", "${CCPROXY_TEST_EMPTY:-fallback}", "fallback", ""},
+		{"EmptyDefault", "${CCPROXY_TEST_UNSET:-}", "", ""},
+		{"EmptyValueKept", "${CCPROXY_TEST_EMPTY}", "", ""},
+		{"BareDollarUntouched", "$CCPROXY_TEST_KEY and $5", "$CCPROXY_TEST_KEY and $5", ""},
+		{"Unset", "${CCPROXY_TEST_UNSET}", "", "CCPROXY_TEST_UNSET referenced by api_key is not set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnvString(tt.input, "api_key")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadFromFile_EnvExpansion(t *testing.T) {
+	t.Setenv("CCPROXY_TEST_OPENAI_KEY", "sk-from-env")
+
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		return path
+	}
+
+	t.Run("Expanded", func(t *testing.T) {
+		path := write(t, `{
+			"port": 3456,
+			"apikey": "${CCPROXY_TEST_PROXY_KEY:-local-key}",
+			"providers": [{
+				"name": "openai",
+				"api_base_url": "https://api.openai.com",
+				"api_key": "${CCPROXY_TEST_OPENAI_KEY}",
+				"models": ["gpt-4"],
+				"enabled": true,
+				"max_jitter": 250000000
+			}]
+		}`)
+
+		cfg, err := LoadFromFile(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.APIKey != "local-key" {
+			t.Errorf("Expected default apikey, got %q", cfg.APIKey)
+		}
+		if cfg.Providers[0].APIKey != "sk-from-env" {
+			t.Errorf("Expected provider key from environment, got %q", cfg.Providers[0].APIKey)
+		}
+		if cfg.Providers[0].MaxJitter != 250000000 {
+			t.Errorf("Expected numbers to be kept, got %v", cfg.Providers[0].MaxJitter)
+		}
+	})
+
+	t.Run("UnsetVariable", func(t *testing.T) {
+		path := write(t, `{
+			"port": 3456,
+			"providers": [{"name": "openai", "api_base_url": "https://api.openai.com", "api_key": "${CCPROXY_TEST_UNSET}"}]
+		}`)
+
+		_, err := LoadFromFile(path)
+		if err == nil || !strings.Contains(err.Error(), "CCPROXY_TEST_UNSET referenced by providers[0].api_key is not set") {
+			t.Errorf("Expected unset variable error, got %v", err)
+		}
+	})
+}
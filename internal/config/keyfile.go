@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// resolveAPIKeyFiles reads the API key of every provider without one from a
+// file, such as a Docker or Kubernetes secret. The provider's api_key_file
+// comes first, then the file named by its key variable with a _FILE suffix,
+// for example ANTHROPIC_API_KEY_FILE or CCPROXY_PROVIDERS_0_API_KEY_FILE.
+func resolveAPIKeyFiles(providers []Provider) error {
+	for i := range providers {
+		provider := &providers[i]
+		if provider.APIKey != "" {
+			continue
+		}
+
+		path, source := provider.APIKeyFile, "api_key_file"
+		if path == "" {
+			path, source = apiKeyFileFromEnv(provider.Name, i)
+		}
+		if path == "" {
+			continue
+		}
+
+		key, err := readAPIKeyFile(path)
+		if err != nil {
+			return fmt.Errorf("provider %s: failed to read %s: %w", provider.Name, source, err)
+		}
+		provider.APIKey = key
+	}
+	return nil
+}
+
+// apiKeyFileFromEnv returns the key file named in the environment for the
+// provider at index, and the variable naming it
+func apiKeyFileFromEnv(name string, index int) (string, string) {
+	var envVars []string
+	if envVar, exists := ProviderAPIKeyEnvVars[strings.ToLower(name)]; exists {
+		envVars = append(envVars, envVar+"_FILE")
+	}
+	envVars = append(envVars, fmt.Sprintf("CCPROXY_PROVIDERS_%d_API_KEY_FILE", index))
+
+	for _, envVar := range envVars {
+		if path := os.Getenv(envVar); path != "" {
+			return path, envVar
+		}
+	}
+	return "", ""
+}
+
+// readAPIKeyFile reads a key file, trimming the trailing newline secret
+// files usually have
+func readAPIKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- The path comes from the operator's configuration
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return key, nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKeyFile writes a secret file into a temporary directory
+func writeKeyFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	return path
+}
+
+func TestResolveAPIKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+	configFile := writeKeyFile(t, dir, "config-key", "sk-from-file\n")
+	envFile := writeKeyFile(t, dir, "env-key", "  sk-from-env-file  \n")
+	t.Setenv("ANTHROPIC_API_KEY_FILE", envFile)
+	t.Setenv("CCPROXY_PROVIDERS_3_API_KEY_FILE", envFile)
+
+	providers := []Provider{
+		{Name: "anthropic", APIKey: "sk-explicit", APIKeyFile: configFile},
+		{Name: "anthropic", APIKeyFile: configFile},
+		{Name: "anthropic"},
+		{Name: "custom"},
+		{Name: "openai"},
+	}
+	if err := resolveAPIKeyFiles(providers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []string{"sk-explicit", "sk-from-file", "sk-from-env-file", "sk-from-env-file", ""}
+	for i, provider := range providers {
+		if provider.APIKey != want[i] {
+			t.Errorf("Provider %d: expected key %q, got %q", i, want[i], provider.APIKey)
+		}
+	}
+
+	t.Run("MissingFile", func(t *testing.T) {
+		err := resolveAPIKeyFiles([]Provider{{Name: "openai", APIKeyFile: filepath.Join(dir, "missing")}})
+		if err == nil || !strings.Contains(err.Error(), "provider openai: failed to read api_key_file") {
+			t.Errorf("Expected api_key_file error, got %v", err)
+		}
+	})
+
+	t.Run("EmptyFile", func(t *testing.T) {
+		empty := writeKeyFile(t, dir, "empty", "\n")
+		t.Setenv("OPENAI_API_KEY_FILE", empty)
+		err := resolveAPIKeyFiles([]Provider{{Name: "openai"}})
+		if err == nil || !strings.Contains(err.Error(), "OPENAI_API_KEY_FILE") {
+			t.Errorf("Expected OPENAI_API_KEY_FILE error, got %v", err)
+		}
+	})
+}
+
+func TestLoadFromFile_APIKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := writeKeyFile(t, dir, "openai-key", "sk-first\n")
+	configPath := writeKeyFile(t, dir, "config.json", `{
+		"providers": [{"name": "openai", "api_base_url": "https://api.openai.com", "api_key_file": "`+keyFile+`", "models": ["gpt-4o"], "enabled": true}]
+	}`)
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Providers[0].APIKey != "sk-first" {
+		t.Errorf("Expected key from file, got %q", cfg.Providers[0].APIKey)
+	}
+
+	// Loading again, as a reload does, picks up a rotated key
+	writeKeyFile(t, dir, "openai-key", "sk-rotated\n")
+	cfg, err = LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Providers[0].APIKey != "sk-rotated" {
+		t.Errorf("Expected rotated key, got %q", cfg.Providers[0].APIKey)
+	}
+}
+
+func TestService_Load_APIKeyFilePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := writeKeyFile(t, dir, "openai-key", "sk-from-file\n")
+	writeKeyFile(t, dir, "config.json", `{
+		"providers": [
+			{"name": "openai", "api_base_url": "https://api.openai.com", "api_key_file": "`+keyFile+`", "models": ["gpt-4o"], "enabled": true},
+			{"name": "anthropic", "api_base_url": "https://api.anthropic.com", "models": ["claude-3-opus"], "enabled": true}
+		]
+	}`)
+	t.Setenv("OPENAI_API_KEY", "sk-openai-from-env")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-anthropic-from-env")
+
+	originalWd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+	defer func() {
+		if err := os.Chdir(originalWd); err != nil {
+			t.Errorf("Failed to restore original working directory: %v", err)
+		}
+	}()
+
+	service := NewService()
+	if err := service.Load(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The key file wins over the key variable, which still applies to
+	// providers without one
+	providers := service.Get().Providers
+	if providers[0].APIKey != "sk-from-file" {
+		t.Errorf("Expected key from api_key_file, got %q", providers[0].APIKey)
+	}
+	if providers[1].APIKey != "sk-anthropic-from-env" {
+		t.Errorf("Expected key from ANTHROPIC_API_KEY, got %q", providers[1].APIKey)
+	}
+}
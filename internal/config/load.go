@@ -1,20 +1,50 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 )
 
-// LoadFromFile loads configuration from a specific file
+// LoadFromFile loads configuration from a specific file, an http(s) URL or
+// stdin when path is StdinPath
 func LoadFromFile(path string) (*Config, error) {
-	// Read the file
-	data, err := os.ReadFile(path) // #nosec G304 -- Path is provided by the user via CLI flag and is expected to be a trusted configuration file
+	data, err := readConfigSource(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
+	}
+
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep a copy of valid remote configurations for offline restarts
+	if IsRemotePath(path) {
+		cacheRemoteConfig(path, data)
+	}
+	return cfg, nil
+}
+
+// parseConfig decodes, expands and validates a JSON configuration
+func parseConfig(data []byte) (*Config, error) {
+	// Parse JSON, keeping numbers exact for the second decode
+	var raw interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	// Substitute ${VAR} references in string values
+	expanded, err := expandEnv(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand config file: %w", err)
+	}
+	if data, err = json.Marshal(expanded); err != nil {
+		return nil, fmt.Errorf("failed to expand config file: %w", err)
 	}
 
-	// Parse JSON
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
@@ -33,5 +63,10 @@ func LoadFromFile(path string) (*Config, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	// Read keys mounted as files, on every load so reloads pick up rotations
+	if err := resolveAPIKeyFiles(cfg.Providers); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	return &cfg, nil
 }
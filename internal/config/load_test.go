@@ -1,7 +1,9 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -203,3 +205,57 @@ func TestLoadFromFile(t *testing.T) {
 		}
 	})
 }
+
+func TestLoadFromFile_TransformerNames(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	data := `{
+		"providers": [{
+			"name": "gemini",
+			"api_base_url": "https://generativelanguage.googleapis.com",
+			"transformers": ["gemini", {"name": "maxtoken", "config": {"max_tokens": 8192}}]
+		}]
+	}`
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatalf("Should write config file: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	transformers := cfg.Providers[0].Transformers
+	if len(transformers) != 2 || transformers[0].Name != "gemini" || transformers[1].Name != "maxtoken" {
+		t.Fatalf("Expected gemini and maxtoken transformers, got %+v", transformers)
+	}
+	if transformers[1].Config["max_tokens"] != float64(8192) {
+		t.Errorf("Expected maxtoken config to be kept, got %v", transformers[1].Config)
+	}
+}
+
+func TestLoadFromFile_CapabilityWarnings(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	data := `{
+		"providers": [{
+			"name": "ollama",
+			"api_base_url": "http://localhost:11434",
+			"models": ["llama3"],
+			"model_capabilities": {"llama3": {"tools": false}}
+		}],
+		"routes": {"default": {"provider": "ollama", "model": "llama3"}}
+	}`
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatalf("Should write config file: %v", err)
+	}
+
+	if _, err := LoadFromFile(configPath); err != nil {
+		t.Fatalf("Should load config: %v", err)
+	}
+	if want := "Warning: route default targets ollama model llama3, which does not support tools"; !strings.Contains(logged.String(), want) {
+		t.Errorf("Expected log to contain %q, got %q", want, logged.String())
+	}
+}
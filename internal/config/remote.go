@@ -0,0 +1,121 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StdinPath is the config path that reads the configuration from stdin
+const StdinPath = "-"
+
+// RemoteAuthEnvVar holds an optional Authorization header value sent when
+// fetching a remote configuration
+const RemoteAuthEnvVar = "CCPROXY_CONFIG_AUTHORIZATION"
+
+// remoteFetchTimeout bounds a remote configuration fetch
+const remoteFetchTimeout = 10 * time.Second
+
+// stdin is where StdinPath reads from, replaced in tests
+var stdin io.Reader = os.Stdin
+
+// IsRemotePath reports whether a config path is an http(s) URL
+func IsRemotePath(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// readConfigSource reads the raw configuration from a file, a URL or stdin
+func readConfigSource(path string) ([]byte, error) {
+	switch {
+	case path == StdinPath:
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config from stdin: %w", err)
+		}
+		return data, nil
+	case IsRemotePath(path):
+		return fetchRemoteConfig(path)
+	default:
+		data, err := os.ReadFile(path) // #nosec G304 -- Path is provided by the user via CLI flag and is expected to be a trusted configuration file
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// fetchRemoteConfig downloads a configuration, falling back to the last
+// cached copy when the server cannot be reached or returns an error
+func fetchRemoteConfig(url string) ([]byte, error) {
+	data, err := downloadConfig(url)
+	if err == nil {
+		return data, nil
+	}
+
+	cached, cacheErr := os.ReadFile(remoteCachePath(url))
+	if cacheErr != nil {
+		return nil, err
+	}
+	// The application logger is not set up before the config is loaded
+	log.Printf("Warning: using cached configuration for %s: %v", url, err)
+	return cached, nil
+}
+
+// downloadConfig performs the HTTP request for a remote configuration
+func downloadConfig(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config URL: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if auth := os.Getenv(RemoteAuthEnvVar); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	client := &http.Client{Timeout: remoteFetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch config: server returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	return data, nil
+}
+
+// cacheRemoteConfig stores a fetched configuration for use when the server
+// is unreachable on a later start. Failures only lose the fallback.
+func cacheRemoteConfig(url string, data []byte) {
+	path := remoteCachePath(url)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		log.Printf("Warning: failed to create config cache directory: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		log.Printf("Warning: failed to cache configuration for %s: %v", url, err)
+	}
+}
+
+// remoteCachePath returns the cache file for a config URL. The URL is
+// hashed since it may carry credentials.
+func remoteCachePath(url string) string {
+	dir := os.TempDir()
+	if home, err := os.UserHomeDir(); err == nil {
+		dir = filepath.Join(home, ".ccproxy")
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, "cache", "config-"+hex.EncodeToString(sum[:8])+".json")
+}
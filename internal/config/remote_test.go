@@ -0,0 +1,108 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const remoteTestConfig = `{
+	"port": 4000,
+	"providers": [{"name": "openai", "api_base_url": "https://api.openai.com/v1", "api_key": "sk-test", "models": ["gpt-4"], "enabled": true}],
+	"routes": {"default": {"provider": "openai", "model": "gpt-4"}}
+}`
+
+func TestLoadFromFile_Stdin(t *testing.T) {
+	original := stdin
+	defer func() { stdin = original }()
+
+	stdin = strings.NewReader(remoteTestConfig)
+	cfg, err := LoadFromFile(StdinPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Port != 4000 {
+		t.Errorf("Expected port 4000, got %d", cfg.Port)
+	}
+
+	stdin = strings.NewReader(`{"port": 70000}`)
+	if _, err := LoadFromFile(StdinPath); err == nil || !strings.Contains(err.Error(), "invalid configuration") {
+		t.Errorf("Expected stdin config to be validated, got: %v", err)
+	}
+}
+
+func TestLoadFromFile_Remote(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	body := remoteTestConfig
+	status := http.StatusOK
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	url := server.URL + "/ccproxy.json"
+
+	t.Run("FetchesAndCaches", func(t *testing.T) {
+		t.Setenv(RemoteAuthEnvVar, "Bearer config-token")
+
+		cfg, err := LoadFromFile(url)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.Port != 4000 {
+			t.Errorf("Expected port 4000, got %d", cfg.Port)
+		}
+		if authorization != "Bearer config-token" {
+			t.Errorf("Expected auth header to be sent, got %q", authorization)
+		}
+
+		info, err := os.Stat(remoteCachePath(url))
+		if err != nil {
+			t.Fatalf("Expected config to be cached: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected cache mode 0600, got %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("FallsBackToCache", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		defer func() { status = http.StatusOK }()
+
+		cfg, err := LoadFromFile(url)
+		if err != nil {
+			t.Fatalf("Expected cached config, got error: %v", err)
+		}
+		if cfg.Port != 4000 {
+			t.Errorf("Expected port 4000, got %d", cfg.Port)
+		}
+	})
+
+	t.Run("InvalidConfigIsNotCached", func(t *testing.T) {
+		body = `{"port": 70000}`
+		defer func() { body = remoteTestConfig }()
+
+		if _, err := LoadFromFile(url); err == nil {
+			t.Fatal("Expected invalid remote config to fail")
+		}
+		cached, err := os.ReadFile(remoteCachePath(url))
+		if err != nil || string(cached) != remoteTestConfig {
+			t.Errorf("Expected the last valid config to stay cached, got %q (%v)", cached, err)
+		}
+	})
+
+	t.Run("NoCache", func(t *testing.T) {
+		status = http.StatusNotFound
+		defer func() { status = http.StatusOK }()
+
+		_, err := LoadFromFile(server.URL + "/missing.json")
+		if err == nil || !strings.Contains(err.Error(), "404") {
+			t.Errorf("Expected fetch error, got: %v", err)
+		}
+	})
+}
@@ -1,34 +1,493 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 )
 
 // Config represents the main configuration structure for CCProxy
 type Config struct {
-	Providers       []Provider        `json:"providers" mapstructure:"providers"`
-	Routes          map[string]Route  `json:"routes" mapstructure:"routes"`
-	Log             bool              `json:"log" mapstructure:"log"`
-	LogFile         string            `json:"log_file" mapstructure:"log_file"`
-	Host            string            `json:"host" mapstructure:"host"`
-	Port            int               `json:"port" mapstructure:"port"`
-	APIKey          string            `json:"apikey" mapstructure:"apikey"`
-	ProxyURL        string            `json:"proxy_url" mapstructure:"proxy_url"`
-	Performance     PerformanceConfig `json:"performance" mapstructure:"performance"`
-	ShutdownTimeout time.Duration     `json:"shutdown_timeout" mapstructure:"shutdown_timeout"`
+	Providers           []Provider         `json:"providers" mapstructure:"providers"`
+	Routes              map[string]Route   `json:"routes" mapstructure:"routes"`
+	HeaderRules         []HeaderRule       `json:"header_rules,omitempty" mapstructure:"header_rules"`             // Inbound header routes, checked in order before content rules
+	ContentRules        []ContentRule      `json:"content_rules,omitempty" mapstructure:"content_rules"`           // Prompt regex routes, checked in order
+	AllowRouteHeader    bool               `json:"allow_route_header,omitempty" mapstructure:"allow_route_header"` // Let clients force a named route with X-CCProxy-Route
+	Log                 bool               `json:"log" mapstructure:"log"`
+	LogFile             string             `json:"log_file" mapstructure:"log_file"`
+	Host                string             `json:"host" mapstructure:"host"`
+	Port                int                `json:"port" mapstructure:"port"`
+	APIKey              string             `json:"apikey" mapstructure:"apikey"`
+	InboundAPIKeys      []string           `json:"inbound_api_keys,omitempty" mapstructure:"inbound_api_keys"` // Shared secrets accepted from clients, empty keeps apikey-only auth
+	ProxyURL            string             `json:"proxy_url" mapstructure:"proxy_url"`
+	UserAgent           string             `json:"user_agent,omitempty" mapstructure:"user_agent"` // Product sent upstream before ccproxy/<version>, empty sends only ccproxy/<version>
+	Performance         PerformanceConfig  `json:"performance" mapstructure:"performance"`
+	ShutdownTimeout     time.Duration      `json:"shutdown_timeout" mapstructure:"shutdown_timeout"`
+	ValidateKeysOnStart bool               `json:"validate_keys_on_start,omitempty" mapstructure:"validate_keys_on_start"` // Probe each provider key at startup and warn when it is rejected
+	StreamRecordDir     string             `json:"stream_record_dir,omitempty" mapstructure:"stream_record_dir"`           // Empty disables stream recording
+	Logging             LoggingConfig      `json:"logging,omitempty" mapstructure:"logging"`
+	Streaming           StreamingConfig    `json:"streaming,omitempty" mapstructure:"streaming"`
+	Tracing             TracingConfig      `json:"tracing,omitempty" mapstructure:"tracing"`
+	Retry               RetryConfig        `json:"retry,omitempty" mapstructure:"retry"`
+	Budget              BudgetConfig       `json:"budget,omitempty" mapstructure:"budget"`
+	Security            SecurityConfig     `json:"security,omitempty" mapstructure:"security"`
+	ModelMasking        ModelMaskingConfig `json:"model_masking,omitempty" mapstructure:"model_masking"`
+	Replay              ReplayConfig       `json:"replay,omitempty" mapstructure:"replay"`
+
+	// Parameters are request defaults for every provider, overridden by
+	// provider and route parameters
+	Parameters map[string]interface{} `json:"parameters,omitempty" mapstructure:"parameters"`
+}
+
+// ModelMaskingConfig hides the upstream model from clients by rewriting the
+// model named in responses
+type ModelMaskingConfig struct {
+	Enabled bool   `json:"enabled" mapstructure:"enabled"`
+	Alias   string `json:"alias,omitempty" mapstructure:"alias"` // Model reported to clients, empty reports the model the client asked for
+}
+
+// ReplayConfig stores each request so `ccproxy replay` can send it again.
+// Stored bodies hold prompts and whatever else clients send, so storing is
+// off unless enabled.
+type ReplayConfig struct {
+	StoreRequests bool   `json:"store_requests" mapstructure:"store_requests"`
+	Dir           string `json:"dir,omitempty" mapstructure:"dir"`       // Defaults to ~/.ccproxy/requests
+	Redact        bool   `json:"redact,omitempty" mapstructure:"redact"` // Mask secrets such as API keys and email addresses in stored bodies
+
+	MaxRequests int           `json:"max_requests,omitempty" mapstructure:"max_requests"` // Stored requests kept, 0 uses DefaultReplayMaxRequests
+	MaxAge      time.Duration `json:"max_age,omitempty" mapstructure:"max_age"`           // Stored requests older than this are removed, 0 keeps them regardless of age
+}
+
+// DefaultReplayMaxRequests is how many stored requests are kept when
+// replay.max_requests is unset
+const DefaultReplayMaxRequests = 1000
+
+// RetainedRequests returns how many stored requests are kept
+func (r ReplayConfig) RetainedRequests() int {
+	if r.MaxRequests > 0 {
+		return r.MaxRequests
+	}
+	return DefaultReplayMaxRequests
+}
+
+// StoreDir returns the directory requests are stored in
+func (r ReplayConfig) StoreDir() string {
+	if r.Dir != "" {
+		return r.Dir
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".ccproxy", "requests")
+	}
+	return filepath.Join(".ccproxy", "requests")
+}
+
+// SecurityConfig controls browser access to the server, which paths it
+// exposes and what inbound API keys may use
+type SecurityConfig struct {
+	AllowedOrigins []string          `json:"allowed_origins,omitempty" mapstructure:"allowed_origins"` // Origins allowed to make cross-origin requests, "*" allows any, empty allows none
+	Paths          PathAccessConfig  `json:"paths,omitempty" mapstructure:"paths"`
+	ModelAccess    []ModelAccessRule `json:"model_access,omitempty" mapstructure:"model_access"` // Target models each inbound API key may use
+	RateLimit      RateLimitConfig   `json:"rate_limit,omitempty" mapstructure:"rate_limit"`
+
+	PromptInjection PromptInjectionConfig `json:"prompt_injection,omitempty" mapstructure:"prompt_injection"`
+	Audit           AuditConfig           `json:"audit,omitempty" mapstructure:"audit"`
+}
+
+// Audit sinks
+const (
+	AuditSinkFile    = "file"
+	AuditSinkStdout  = "stdout"
+	AuditSinkSyslog  = "syslog"
+	AuditSinkWebhook = "webhook"
+)
+
+// AuditConfig records security events, such as model access denials and
+// prompt injection detections, to an audit sink. Entries are buffered and
+// written in the background.
+type AuditConfig struct {
+	Enabled bool   `json:"enabled" mapstructure:"enabled"`
+	Sink    string `json:"sink,omitempty" mapstructure:"sink"` // AuditSinkFile (default), AuditSinkStdout, AuditSinkSyslog or AuditSinkWebhook
+	Path    string `json:"path,omitempty" mapstructure:"path"` // File sink log, defaults to ~/.ccproxy/audit.log
+
+	SyslogNetwork string `json:"syslog_network,omitempty" mapstructure:"syslog_network"` // Empty uses the local syslog daemon
+	SyslogAddress string `json:"syslog_address,omitempty" mapstructure:"syslog_address"`
+	SyslogTag     string `json:"syslog_tag,omitempty" mapstructure:"syslog_tag"` // Defaults to ccproxy-audit
+
+	WebhookURL           string            `json:"webhook_url,omitempty" mapstructure:"webhook_url"`
+	WebhookHeaders       map[string]string `json:"webhook_headers,omitempty" mapstructure:"webhook_headers"`
+	WebhookBatchSize     int               `json:"webhook_batch_size,omitempty" mapstructure:"webhook_batch_size"`         // Entries per request, 0 uses 100
+	WebhookFlushInterval time.Duration     `json:"webhook_flush_interval,omitempty" mapstructure:"webhook_flush_interval"` // Longest an entry waits, 0 uses 5s
+	WebhookMaxRetries    int               `json:"webhook_max_retries,omitempty" mapstructure:"webhook_max_retries"`       // 0 uses 3
+
+	// File sink rotation, checked after each flush. Zero disables each limit.
+	MaxSizeMB  int  `json:"max_size_mb,omitempty" mapstructure:"max_size_mb"`   // Rotate once the log reaches this size
+	MaxAgeDays int  `json:"max_age_days,omitempty" mapstructure:"max_age_days"` // Rotate once the log's first entry is this old
+	MaxBackups int  `json:"max_backups,omitempty" mapstructure:"max_backups"`   // Rotated logs to keep, zero keeps them all
+	Compress   bool `json:"compress,omitempty" mapstructure:"compress"`         // Gzip rotated logs
+}
+
+// LogPath returns the file the file sink writes to
+func (a AuditConfig) LogPath() string {
+	if a.Path != "" {
+		return a.Path
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".ccproxy", "audit.log")
+	}
+	return filepath.Join(".ccproxy", "audit.log")
+}
+
+// PromptInjectionConfig scans /v1/messages requests for known prompt
+// injection and jailbreak phrases. Matches at or above the threshold are
+// rejected with 400 when blocking, and otherwise flagged with the
+// X-CCProxy-Prompt-Injection response header.
+type PromptInjectionConfig struct {
+	Enabled   bool                     `json:"enabled" mapstructure:"enabled"`
+	Block     bool                     `json:"block,omitempty" mapstructure:"block"`
+	Threshold string                   `json:"threshold,omitempty" mapstructure:"threshold"` // "low", "medium" or "high", empty means medium
+	Patterns  []PromptInjectionPattern `json:"patterns,omitempty" mapstructure:"patterns"`   // Empty uses the built-in patterns
+}
+
+// PromptInjectionPattern is a regular expression matched against request
+// text, case-insensitively
+type PromptInjectionPattern struct {
+	Pattern  string `json:"pattern" mapstructure:"pattern"`
+	Severity string `json:"severity,omitempty" mapstructure:"severity"` // "low", "medium" or "high", empty means medium
+}
+
+// DefaultRateLimitRequestsPerMinute is the inbound rate limit when
+// rate_limit.requests_per_minute is unset
+const DefaultRateLimitRequestsPerMinute = 100
+
+// Rate limit keying strategies
+const (
+	RateLimitKeyingIP     = "ip"      // Limit each client address
+	RateLimitKeyingAPIKey = "api_key" // Limit each API key, or client address without one
+)
+
+// RateLimitConfig limits the API requests each client may make. Refused
+// requests get a 429 with a Retry-After header.
+type RateLimitConfig struct {
+	Enabled           bool   `json:"enabled" mapstructure:"enabled"`
+	RequestsPerMinute int    `json:"requests_per_minute,omitempty" mapstructure:"requests_per_minute"` // Zero uses DefaultRateLimitRequestsPerMinute
+	Keying            string `json:"keying,omitempty" mapstructure:"keying"`                           // RateLimitKeyingIP (default) or RateLimitKeyingAPIKey
+	Message           string `json:"message,omitempty" mapstructure:"message"`                         // Error message of 429 responses, empty uses the default
+}
+
+// ModelAccessRule limits an inbound API key, apikey or one of
+// inbound_api_keys, to some target models. Keys without a rule may use any
+// model.
+type ModelAccessRule struct {
+	Key    string   `json:"key" mapstructure:"key"`
+	Models []string `json:"models" mapstructure:"models"` // Routed target models, without the provider prefix
+}
+
+// PathAccessConfig limits the paths the server exposes. Deny rules are checked
+// first, then, when there are allow rules, a path must match one of them.
+// Refused requests get the same 404 as unknown paths.
+type PathAccessConfig struct {
+	Allow []PathRule `json:"allow,omitempty" mapstructure:"allow"`
+	Deny  []PathRule `json:"deny,omitempty" mapstructure:"deny"`
+}
+
+// PathRule matches a path prefix on segment boundaries, so /v1 covers
+// /v1/messages but not /v10
+type PathRule struct {
+	Prefix string `json:"prefix" mapstructure:"prefix"`
+
+	// Clients that may reach the path: "localhost", IP addresses or CIDR
+	// ranges. Empty means every client for an allow rule and none for a deny
+	// rule.
+	AllowFrom []string `json:"allow_from,omitempty" mapstructure:"allow_from"`
+}
+
+// ParseSource returns the address ranges of a PathRule AllowFrom entry
+func ParseSource(source string) ([]*net.IPNet, error) {
+	if source == "localhost" {
+		_, v4, _ := net.ParseCIDR("127.0.0.0/8")
+		_, v6, _ := net.ParseCIDR("::1/128")
+		return []*net.IPNet{v4, v6}, nil
+	}
+	if strings.Contains(source, "/") {
+		_, ipNet, err := net.ParseCIDR(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range: %w", err)
+		}
+		return []*net.IPNet{ipNet}, nil
+	}
+
+	ip := net.ParseIP(source)
+	if ip == nil {
+		return nil, fmt.Errorf("must be \"localhost\", an IP address or a CIDR range")
+	}
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 8 * net.IPv4len
+	}
+	return []*net.IPNet{{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+}
+
+// StreamingConfig controls streamed responses to clients
+type StreamingConfig struct {
+	KeepAliveInterval time.Duration `json:"keep_alive_interval,omitempty" mapstructure:"keep_alive_interval"` // Upstream silence before an SSE keepalive comment is sent, 0 disables
+
+	// How events are flushed to the client, see the FlushMode constants
+	FlushMode      string        `json:"flush_mode,omitempty" mapstructure:"flush_mode"`
+	FlushBatchSize int           `json:"flush_batch_size,omitempty" mapstructure:"flush_batch_size"` // Events per flush in batched mode, 0 uses 8
+	FlushInterval  time.Duration `json:"flush_interval,omitempty" mapstructure:"flush_interval"`     // Longest an event waits in batched mode, 0 uses 50ms
+
+	// Check the streamed arguments of each tool call once its block ends and
+	// complete truncated JSON with a final input_json_delta
+	ValidateToolArguments bool `json:"validate_tool_arguments,omitempty" mapstructure:"validate_tool_arguments"`
+}
+
+// Stream flush modes
+const (
+	FlushModeImmediate = "immediate" // Flush after every event, the default
+	FlushModeBatched   = "batched"   // Flush every FlushBatchSize events or after FlushInterval
+)
+
+// Defaults for batched flushing
+const (
+	DefaultFlushBatchSize = 8
+	DefaultFlushInterval  = 50 * time.Millisecond
+)
+
+// BatchSize returns the events per flush in batched mode
+func (s StreamingConfig) BatchSize() int {
+	if s.FlushBatchSize > 0 {
+		return s.FlushBatchSize
+	}
+	return DefaultFlushBatchSize
+}
+
+// BatchInterval returns the longest an event waits for a flush in batched mode
+func (s StreamingConfig) BatchInterval() time.Duration {
+	if s.FlushInterval > 0 {
+		return s.FlushInterval
+	}
+	return DefaultFlushInterval
+}
+
+// RetryConfig limits how many requests CCProxy retries, so retries cannot
+// amplify load during a provider outage
+type RetryConfig struct {
+	BudgetRatio float64 `json:"budget_ratio,omitempty" mapstructure:"budget_ratio"` // Retries allowed as a fraction of requests, 0 leaves retries unlimited
+	BudgetBurst int     `json:"budget_burst,omitempty" mapstructure:"budget_burst"` // Retries a full budget allows at once, 0 uses 10
+}
+
+// BudgetConfig caps token usage and spend across all providers over a
+// rolling window. Per-provider caps are set with Provider.Budget.
+type BudgetConfig struct {
+	Window    time.Duration `json:"window,omitempty" mapstructure:"window"`         // Rolling window usage is counted over, 0 uses 24h
+	MaxTokens int64         `json:"max_tokens,omitempty" mapstructure:"max_tokens"` // Input plus output tokens across all providers, 0 means unlimited
+	MaxCost   float64       `json:"max_cost,omitempty" mapstructure:"max_cost"`     // Spend across all providers from model pricing, 0 means unlimited
+	StateFile string        `json:"state_file,omitempty" mapstructure:"state_file"` // Keeps usage across restarts, empty keeps it in memory only
+}
+
+// ProviderBudget caps one provider's usage over the budget window
+type ProviderBudget struct {
+	MaxTokens int64   `json:"max_tokens,omitempty" mapstructure:"max_tokens"` // Input plus output tokens, 0 means unlimited
+	MaxCost   float64 `json:"max_cost,omitempty" mapstructure:"max_cost"`     // Spend from model pricing, 0 means unlimited
+	Fallback  string  `json:"fallback,omitempty" mapstructure:"fallback"`     // "provider,model" used once the budget is exhausted, empty rejects requests
+}
+
+// TracingConfig exports OpenTelemetry spans to an OTLP/HTTP collector
+type TracingConfig struct {
+	Endpoint    string            `json:"endpoint,omitempty" mapstructure:"endpoint"`         // Collector base URL such as http://localhost:4318, empty disables tracing
+	ServiceName string            `json:"service_name,omitempty" mapstructure:"service_name"` // Reported as service.name, defaults to ccproxy
+	Headers     map[string]string `json:"headers,omitempty" mapstructure:"headers"`           // Sent with every export, such as collector credentials
+}
+
+// LoggingConfig controls log output formatting
+type LoggingConfig struct {
+	Format string `json:"format,omitempty" mapstructure:"format"` // "text" or "json", empty keeps the default
 }
 
 // Provider represents a LLM provider configuration
 type Provider struct {
-	Name          string              `json:"name" mapstructure:"name"`
-	APIBaseURL    string              `json:"api_base_url" mapstructure:"api_base_url"`
-	APIKey        string              `json:"api_key" mapstructure:"api_key"`
-	Models        []string            `json:"models" mapstructure:"models"`
-	Enabled       bool                `json:"enabled" mapstructure:"enabled"`
-	Transformers  []TransformerConfig `json:"transformers" mapstructure:"transformers"`
-	CreatedAt     time.Time           `json:"created_at" mapstructure:"created_at"`
-	UpdatedAt     time.Time           `json:"updated_at" mapstructure:"updated_at"`
-	MessageFormat string              `json:"message_format,omitempty" mapstructure:"message_format"` // Message format used by provider
+	Name           string              `json:"name" mapstructure:"name"`
+	APIBaseURL     string              `json:"api_base_url" mapstructure:"api_base_url"`
+	BasePath       string              `json:"base_path,omitempty" mapstructure:"base_path"` // Path placed between api_base_url and the provider's endpoints, for gateways that namespace providers
+	APIKey         string              `json:"api_key" mapstructure:"api_key"`
+	APIKeyFile     string              `json:"api_key_file,omitempty" mapstructure:"api_key_file"` // File holding the API key, such as a mounted secret, read when api_key is empty
+	Models         []string            `json:"models" mapstructure:"models"`
+	Enabled        bool                `json:"enabled" mapstructure:"enabled"`
+	Transformers   []TransformerConfig `json:"transformers" mapstructure:"transformers"`
+	CreatedAt      time.Time           `json:"created_at" mapstructure:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at" mapstructure:"updated_at"`
+	MessageFormat  string              `json:"message_format,omitempty" mapstructure:"message_format"`   // Message format used by provider
+	Deployment     string              `json:"deployment,omitempty" mapstructure:"deployment"`           // Azure OpenAI deployment name (defaults to the request model)
+	APIVersion     string              `json:"api_version,omitempty" mapstructure:"api_version"`         // Azure OpenAI api-version query parameter, Anthropic anthropic-version header or Gemini API version
+	MaxJitter      time.Duration       `json:"max_jitter,omitempty" mapstructure:"max_jitter"`           // Upper bound for random delay before dispatch
+	Timeout        time.Duration       `json:"timeout,omitempty" mapstructure:"timeout"`                 // Overrides performance.request_timeout for this provider
+	FieldRenames   map[string]string   `json:"field_renames,omitempty" mapstructure:"field_renames"`     // Request body fields to rename, source -> target
+	Pricing        map[string]Pricing  `json:"pricing,omitempty" mapstructure:"pricing"`                 // Per-model token pricing used for cost logging
+	Headers        map[string]string   `json:"headers,omitempty" mapstructure:"headers"`                 // Extra headers sent with every upstream request
+	MaxConcurrency int                 `json:"max_concurrency,omitempty" mapstructure:"max_concurrency"` // Upper bound on in-flight upstream requests, 0 means unlimited
+	RepairJSON     bool                `json:"repair_json,omitempty" mapstructure:"repair_json"`         // Fix nearly-valid JSON responses, such as trailing commas, before they are parsed
+
+	// Upstream TLS settings
+	CACertFile         string `json:"ca_cert_file,omitempty" mapstructure:"ca_cert_file"`                 // PEM bundle trusted in addition to the system roots
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" mapstructure:"insecure_skip_verify"` // Disables certificate verification, for local development only
+
+	// Vertex AI settings, used when the provider is named "vertex"
+	ServiceAccountFile string `json:"service_account_file,omitempty" mapstructure:"service_account_file"` // Service account key used to mint OAuth2 access tokens
+	Project            string `json:"project,omitempty" mapstructure:"project"`                           // Defaults to the service account's project
+	Location           string `json:"location,omitempty" mapstructure:"location"`                         // Defaults to us-central1
+
+	// Mock settings, used when the provider is named "mock"
+	MockResponse   string        `json:"mock_response,omitempty" mapstructure:"mock_response"`       // Completion text, a Go template with .Model and .Prompt
+	MockLatency    time.Duration `json:"mock_latency,omitempty" mapstructure:"mock_latency"`         // Delay before the response starts
+	MockChunkDelay time.Duration `json:"mock_chunk_delay,omitempty" mapstructure:"mock_chunk_delay"` // Delay between streamed chunks
+
+	// Context windows, used to fit long conversations before sending
+	ContextLimits      map[string]int `json:"context_limits,omitempty" mapstructure:"context_limits"`           // Per-model token limit for input plus max_tokens
+	TruncationStrategy string         `json:"truncation_strategy,omitempty" mapstructure:"truncation_strategy"` // "drop_oldest" (default) or "error"
+
+	// ModelCapabilities describes what each model supports, so misrouted
+	// requests fail with a clear error. Models without an entry are unrestricted.
+	ModelCapabilities map[string]ModelCapabilities `json:"model_capabilities,omitempty" mapstructure:"model_capabilities"`
+
+	// MultipleCompletions handles requests for n > 1 completions when the
+	// provider has no native support
+	MultipleCompletions string `json:"multiple_completions,omitempty" mapstructure:"multiple_completions"` // "strip" (default) or "emulate"
+
+	// Tool limits, checked before sending so oversized tool lists fail with a
+	// clear error instead of an opaque upstream 400
+	MaxTools           int    `json:"max_tools,omitempty" mapstructure:"max_tools"`                         // Most tools per request, 0 uses the provider's built-in limit
+	MaxToolSchemaBytes int    `json:"max_tool_schema_bytes,omitempty" mapstructure:"max_tool_schema_bytes"` // Largest serialized tools array, 0 means unlimited
+	ToolLimitStrategy  string `json:"tool_limit_strategy,omitempty" mapstructure:"tool_limit_strategy"`     // "error" (default) or "truncate"
+
+	// Parameters are request defaults for this provider, overridden by route
+	// parameters
+	Parameters map[string]interface{} `json:"parameters,omitempty" mapstructure:"parameters"`
+
+	DefaultFrequencyPenalty *float64 `json:"default_frequency_penalty,omitempty" mapstructure:"default_frequency_penalty"` // Injected when a request omits frequency_penalty
+	UnsupportedParams       []string `json:"unsupported_params,omitempty" mapstructure:"unsupported_params"`               // Request fields stripped before sending, in addition to the built-in list
+
+	MergeConsecutiveMessages bool `json:"merge_consecutive_messages,omitempty" mapstructure:"merge_consecutive_messages"` // Merges runs of user or assistant messages before sending
+
+	// Budget caps this provider's usage over budget.window, nil means unlimited
+	Budget *ProviderBudget `json:"budget,omitempty" mapstructure:"budget"`
+}
+
+// EndpointURL joins api_base_url, base_path and an endpoint path with a
+// single slash between each part
+func (p *Provider) EndpointURL(endpoint string) string {
+	url := strings.TrimSuffix(p.APIBaseURL, "/")
+	if basePath := strings.Trim(p.BasePath, "/"); basePath != "" {
+		url += "/" + basePath
+	}
+	return url + "/" + strings.TrimPrefix(endpoint, "/")
+}
+
+// GeminiAPIVersion returns the Gemini API version used in endpoint paths,
+// v1beta unless api_version pins another
+func (p *Provider) GeminiAPIVersion() string {
+	if p.APIVersion != "" {
+		return p.APIVersion
+	}
+	return GeminiAPIV1Beta
+}
+
+// AzureAPIVersion returns the api-version query parameter of Azure OpenAI
+// requests, DefaultAzureAPIVersion unless api_version pins another
+func (p *Provider) AzureAPIVersion() string {
+	if p.APIVersion != "" {
+		return p.APIVersion
+	}
+	return DefaultAzureAPIVersion
+}
+
+// AnthropicVersion returns the anthropic-version header of Anthropic
+// requests, DefaultAnthropicVersion unless api_version pins another
+func (p *Provider) AnthropicVersion() string {
+	if p.APIVersion != "" {
+		return p.APIVersion
+	}
+	return DefaultAnthropicVersion
+}
+
+// ContextLimit returns the token limit for model from context_limits or the
+// model's capabilities, or 0 when it has none
+func (p *Provider) ContextLimit(model string) int {
+	if limit := p.ContextLimits[model]; limit > 0 {
+		return limit
+	}
+	return p.ModelCapabilities[model].MaxContext
+}
+
+// ModelCapabilities describes the features a model supports. Unset features
+// are assumed to be supported.
+type ModelCapabilities struct {
+	Streaming  *bool `json:"streaming,omitempty" mapstructure:"streaming"`
+	Tools      *bool `json:"tools,omitempty" mapstructure:"tools"`
+	Vision     *bool `json:"vision,omitempty" mapstructure:"vision"`
+	MaxContext int   `json:"max_context,omitempty" mapstructure:"max_context"` // Token limit for input plus max_tokens, 0 when unknown
+	StreamOnly bool  `json:"stream_only,omitempty" mapstructure:"stream_only"` // Non-streaming requests are streamed upstream and buffered into one response
+}
+
+// SupportsStreaming reports whether the model can stream responses
+func (c ModelCapabilities) SupportsStreaming() bool {
+	return c.Streaming == nil || *c.Streaming
+}
+
+// SupportsTools reports whether the model accepts tool definitions
+func (c ModelCapabilities) SupportsTools() bool {
+	return c.Tools == nil || *c.Tools
+}
+
+// SupportsVision reports whether the model accepts image input
+func (c ModelCapabilities) SupportsVision() bool {
+	return c.Vision == nil || *c.Vision
+}
+
+// MockProviderName is the provider name that answers requests locally with
+// canned completions instead of calling an upstream API
+const MockProviderName = "mock"
+
+// Truncation strategies for Provider.TruncationStrategy
+const (
+	TruncationDropOldest = "drop_oldest" // Drop the oldest turns until the request fits
+	TruncationError      = "error"       // Reject requests that do not fit
+)
+
+// Strategies for Provider.MultipleCompletions
+const (
+	MultipleCompletionsStrip   = "strip"   // Drop n and return a single completion
+	MultipleCompletionsEmulate = "emulate" // Send n parallel requests and merge their choices
+)
+
+// Strategies for Provider.ToolLimitStrategy
+const (
+	ToolLimitError    = "error"    // Reject requests over the tool limits
+	ToolLimitTruncate = "truncate" // Drop tools from the end until the request fits
+)
+
+// Gemini API versions for Provider.APIVersion
+const (
+	GeminiAPIV1      = "v1"
+	GeminiAPIV1Beta  = "v1beta" // The default, where new models and features appear first
+	GeminiAPIV1Alpha = "v1alpha"
+)
+
+// Default API versions for providers without api_version
+const (
+	DefaultAzureAPIVersion  = "2024-02-01"
+	DefaultAnthropicVersion = "2023-06-01"
+)
+
+// Pricing holds a model's token prices in USD per million tokens
+type Pricing struct {
+	InputPerMillion  float64 `json:"input_per_million" mapstructure:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million" mapstructure:"output_per_million"`
 }
 
 // Route represents a routing configuration
@@ -37,6 +496,211 @@ type Route struct {
 	Model      string                 `json:"model" mapstructure:"model"`
 	Conditions []Condition            `json:"conditions" mapstructure:"conditions"`
 	Parameters map[string]interface{} `json:"parameters,omitempty" mapstructure:"parameters"`
+	Threshold  int                    `json:"threshold,omitempty" mapstructure:"threshold"` // Token threshold for the longContext route
+	Schedules  []Schedule             `json:"schedules,omitempty" mapstructure:"schedules"` // Time-of-day target overrides, first match wins
+
+	// Additional candidates next to provider/model, chosen between by Strategy
+	Targets     []RouteTarget `json:"targets,omitempty" mapstructure:"targets"`
+	Strategy    string        `json:"strategy,omitempty" mapstructure:"strategy"`       // "latency-aware"
+	Exploration float64       `json:"exploration,omitempty" mapstructure:"exploration"` // Share of requests sent to a random healthy candidate
+
+	Shadow *ShadowTarget `json:"shadow,omitempty" mapstructure:"shadow"` // Mirrors a share of requests for evaluation
+}
+
+// ShadowTarget receives a copy of a share of a route's requests so a model can
+// be evaluated on live traffic. Its responses are only logged, never returned.
+type ShadowTarget struct {
+	Provider   string        `json:"provider" mapstructure:"provider"`
+	Model      string        `json:"model" mapstructure:"model"`
+	Percentage float64       `json:"percentage" mapstructure:"percentage"`     // Share of requests mirrored, 0-100
+	Timeout    time.Duration `json:"timeout,omitempty" mapstructure:"timeout"` // Bounds each mirrored call, defaults to 30s
+}
+
+// DefaultShadowTimeout bounds a mirrored call when the shadow sets no timeout
+const DefaultShadowTimeout = 30 * time.Second
+
+// RequestTimeout returns the timeout of a mirrored call
+func (s ShadowTarget) RequestTimeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return DefaultShadowTimeout
+}
+
+// RouteTarget is a provider and model a multi-target route can send to
+type RouteTarget struct {
+	Provider string `json:"provider" mapstructure:"provider"`
+	Model    string `json:"model" mapstructure:"model"`
+}
+
+// RouteStrategyLatencyAware sends each request to the route candidate with
+// the lowest recent latency among the healthy ones
+const RouteStrategyLatencyAware = "latency-aware"
+
+// DefaultRouteExploration is the share of latency-aware requests sent to a
+// random healthy candidate to keep its latency stats fresh
+const DefaultRouteExploration = 0.05
+
+// Candidates returns the route's provider/model followed by its targets
+func (r Route) Candidates() []RouteTarget {
+	candidates := make([]RouteTarget, 0, len(r.Targets)+1)
+	if r.Provider != "" {
+		candidates = append(candidates, RouteTarget{Provider: r.Provider, Model: r.Model})
+	}
+	return append(candidates, r.Targets...)
+}
+
+// ExplorationRate returns the exploration probability of a latency-aware route
+func (r Route) ExplorationRate() float64 {
+	if r.Exploration > 0 {
+		return r.Exploration
+	}
+	return DefaultRouteExploration
+}
+
+// DefaultLongContextThreshold is the token count above which the longContext
+// route is selected when the route does not configure its own threshold
+const DefaultLongContextThreshold = 60000
+
+// LongContextThreshold returns the token threshold of a longContext route
+func (r Route) LongContextThreshold() int {
+	if r.Threshold > 0 {
+		return r.Threshold
+	}
+	return DefaultLongContextThreshold
+}
+
+// ContentRule routes requests whose user message text matches a pattern
+type ContentRule struct {
+	Name     string `json:"name,omitempty" mapstructure:"name"` // Reported in the routing strategy, defaults to the rule index
+	Pattern  string `json:"pattern" mapstructure:"pattern"`     // Go regular expression
+	Provider string `json:"provider" mapstructure:"provider"`
+	Model    string `json:"model" mapstructure:"model"`
+
+	compiled *regexp.Regexp
+}
+
+// Compile compiles the rule's pattern and caches it for Match
+func (r *ContentRule) Compile() error {
+	compiled, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return err
+	}
+	r.compiled = compiled
+	return nil
+}
+
+// Compiled reports whether the pattern has been compiled
+func (r *ContentRule) Compiled() bool {
+	return r.compiled != nil
+}
+
+// Match reports whether text matches the rule. Uncompiled rules never match.
+func (r *ContentRule) Match(text string) bool {
+	return r.compiled != nil && r.compiled.MatchString(text)
+}
+
+// HeaderRule routes requests carrying an inbound header with a matching value
+type HeaderRule struct {
+	Name     string `json:"name,omitempty" mapstructure:"name"`       // Reported in the routing strategy, defaults to the rule index
+	Header   string `json:"header" mapstructure:"header"`             // Header name, case-insensitive
+	Value    string `json:"value,omitempty" mapstructure:"value"`     // Exact value, mutually exclusive with pattern
+	Pattern  string `json:"pattern,omitempty" mapstructure:"pattern"` // Go regular expression
+	Provider string `json:"provider" mapstructure:"provider"`
+	Model    string `json:"model" mapstructure:"model"`
+
+	compiled *regexp.Regexp
+}
+
+// Compile compiles the rule's pattern, if any, and caches it for Match
+func (r *HeaderRule) Compile() error {
+	if r.Pattern == "" {
+		return nil
+	}
+	compiled, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return err
+	}
+	r.compiled = compiled
+	return nil
+}
+
+// Compiled reports whether the rule is ready for Match
+func (r *HeaderRule) Compiled() bool {
+	return r.Pattern == "" || r.compiled != nil
+}
+
+// Match reports whether a header value matches the rule. Rules with an
+// uncompiled pattern never match.
+func (r *HeaderRule) Match(value string) bool {
+	if r.Pattern == "" {
+		return value == r.Value
+	}
+	return r.compiled != nil && r.compiled.MatchString(value)
+}
+
+// Schedule overrides a route's target during a daily time window
+type Schedule struct {
+	Start    string `json:"start" mapstructure:"start"`                 // "HH:MM", inclusive
+	End      string `json:"end" mapstructure:"end"`                     // "HH:MM", exclusive; may wrap past midnight
+	Timezone string `json:"timezone,omitempty" mapstructure:"timezone"` // IANA name, defaults to the server's local time
+	Provider string `json:"provider" mapstructure:"provider"`
+	Model    string `json:"model,omitempty" mapstructure:"model"` // Defaults to the route's model
+
+	start, end int // Minutes since midnight
+	loc        *time.Location
+}
+
+// Compile parses the schedule's window and time zone and caches them for Active
+func (s *Schedule) Compile() error {
+	start, err := parseClock(s.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := parseClock(s.End)
+	if err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+
+	loc := time.Local
+	if s.Timezone != "" {
+		loc, err = time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+		}
+	}
+	s.start, s.end, s.loc = start, end, loc
+	return nil
+}
+
+// Compiled reports whether the schedule is ready for Active
+func (s *Schedule) Compiled() bool {
+	return s.loc != nil
+}
+
+// Active reports whether t falls inside the schedule's window. Uncompiled
+// schedules are never active.
+func (s *Schedule) Active(t time.Time) bool {
+	if s.loc == nil {
+		return false
+	}
+
+	local := t.In(s.loc)
+	minute := local.Hour()*60 + local.Minute()
+	if s.start <= s.end {
+		return minute >= s.start && minute < s.end
+	}
+	// The window wraps past midnight
+	return minute >= s.start || minute < s.end
+}
+
+// parseClock parses an "HH:MM" time of day into minutes since midnight
+func parseClock(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an HH:MM time", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
 }
 
 // Condition represents a routing condition
@@ -46,10 +710,29 @@ type Condition struct {
 	Value    interface{} `json:"value" mapstructure:"value"`
 }
 
-// TransformerConfig represents transformer configuration
+// TransformerConfig represents transformer configuration. In a provider's
+// transformers list it may also be written as just the transformer name.
 type TransformerConfig struct {
-	Name   string                 `json:"name" mapstructure:"name"`
-	Config map[string]interface{} `json:"config,omitempty" mapstructure:"config"`
+	Name       string                 `json:"name" mapstructure:"name"`
+	Config     map[string]interface{} `json:"config,omitempty" mapstructure:"config"`
+	BestEffort bool                   `json:"best_effort,omitempty" mapstructure:"best_effort"` // A failure is logged and the transformer skipped
+}
+
+// UnmarshalJSON accepts either a transformer name or a full object
+func (t *TransformerConfig) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = TransformerConfig{Name: name}
+		return nil
+	}
+
+	type plain TransformerConfig
+	var cfg plain
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return err
+	}
+	*t = TransformerConfig(cfg)
+	return nil
 }
 
 // PerformanceConfig represents performance monitoring configuration
@@ -57,9 +740,28 @@ type PerformanceConfig struct {
 	MetricsEnabled          bool          `json:"metrics_enabled" mapstructure:"metrics_enabled"`
 	RateLimitEnabled        bool          `json:"rate_limit_enabled" mapstructure:"rate_limit_enabled"`
 	RateLimitRequestsPerMin int           `json:"rate_limit_requests_per_min" mapstructure:"rate_limit_requests_per_min"`
+	RateLimitPerUser        bool          `json:"rate_limit_per_user,omitempty" mapstructure:"rate_limit_per_user"` // Limit each body user separately, falling back to API key then IP
 	CircuitBreakerEnabled   bool          `json:"circuit_breaker_enabled" mapstructure:"circuit_breaker_enabled"`
 	RequestTimeout          time.Duration `json:"request_timeout" mapstructure:"request_timeout"`
 	MaxRequestBodySize      int64         `json:"max_request_body_size" mapstructure:"max_request_body_size"`
+	MaxToolRoundtrips       int           `json:"max_tool_roundtrips,omitempty" mapstructure:"max_tool_roundtrips"` // 0 disables flagging
+	MaxMessageBytes         int64         `json:"max_message_bytes,omitempty" mapstructure:"max_message_bytes"`     // 0 disables the per-message size cap
+	MaxContentBlocks        int           `json:"max_content_blocks,omitempty" mapstructure:"max_content_blocks"`   // 0 disables the per-message block cap
+	IdempotencyTTL          time.Duration `json:"idempotency_ttl,omitempty" mapstructure:"idempotency_ttl"`         // How long Idempotency-Key responses are kept, 0 disables
+	RetryEmptyStreams       bool          `json:"retry_empty_streams,omitempty" mapstructure:"retry_empty_streams"` // Retry once when a stream ends before any data
+	StickySessionTTL        time.Duration `json:"sticky_session_ttl,omitempty" mapstructure:"sticky_session_ttl"`   // How long X-CCProxy-Session keeps a session on one provider, 0 disables
+	CoalesceStreams         bool          `json:"coalesce_streams,omitempty" mapstructure:"coalesce_streams"`       // Share one upstream stream between identical concurrent streaming requests
+
+	// Global soft limit on concurrent requests. Requests over the limit wait
+	// up to QueueTimeout for a slot before failing with 503.
+	MaxConcurrentRequests int           `json:"max_concurrent_requests,omitempty" mapstructure:"max_concurrent_requests"` // 0 means unlimited
+	QueueTimeout          time.Duration `json:"queue_timeout,omitempty" mapstructure:"queue_timeout"`                     // 0 uses 5s
+
+	// Deadline for handling a whole inbound request, including transformers,
+	// queueing and the upstream call. RequestTimeout only bounds the upstream
+	// call. Streaming requests use TotalStreamingTimeout instead.
+	TotalRequestTimeout   time.Duration `json:"total_request_timeout,omitempty" mapstructure:"total_request_timeout"`     // 0 disables
+	TotalStreamingTimeout time.Duration `json:"total_streaming_timeout,omitempty" mapstructure:"total_streaming_timeout"` // 0 exempts streaming requests
 }
 
 // Default configuration values
@@ -503,3 +503,44 @@ func TestConfigNilHandling(t *testing.T) {
 		t.Errorf("Expected length of nil providers slice to be 0, got %d", len(config.Providers))
 	}
 }
+
+func TestSchedule_Active(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, _ := time.Parse("2006-01-02 15:04", "2024-03-10 "+clock)
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		now      time.Time
+		want     bool
+	}{
+		{"inside daytime window", Schedule{Start: "09:00", End: "17:00", Timezone: "UTC"}, at("12:30"), true},
+		{"start is inclusive", Schedule{Start: "09:00", End: "17:00", Timezone: "UTC"}, at("09:00"), true},
+		{"end is exclusive", Schedule{Start: "09:00", End: "17:00", Timezone: "UTC"}, at("17:00"), false},
+		{"overnight before midnight", Schedule{Start: "22:00", End: "06:00", Timezone: "UTC"}, at("23:15"), true},
+		{"overnight after midnight", Schedule{Start: "22:00", End: "06:00", Timezone: "UTC"}, at("05:59"), true},
+		{"outside overnight window", Schedule{Start: "22:00", End: "06:00", Timezone: "UTC"}, at("12:00"), false},
+		{"timezone applied", Schedule{Start: "09:00", End: "17:00", Timezone: "Asia/Tokyo"}, at("01:00"), true},
+		{"invalid schedule never active", Schedule{Start: "bad", End: "17:00"}, at("12:00"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schedule.Compile(); err != nil && tt.want {
+				t.Fatalf("Failed to compile schedule: %v", err)
+			}
+			if got := tt.schedule.Active(tt.now); got != tt.want {
+				t.Errorf("Expected Active=%v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("uncompiled schedule never active", func(t *testing.T) {
+		schedule := Schedule{Start: "00:00", End: "23:59", Timezone: "UTC"}
+		if schedule.Active(at("12:00")) {
+			t.Error("Expected an uncompiled schedule to be inactive")
+		}
+	})
+}
@@ -1,9 +1,15 @@
 package config
 
 import (
+	"crypto/x509"
 	"fmt"
+	"log"
 	"net/url"
+	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template"
 )
 
 // Validate checks if the configuration is valid
@@ -33,6 +39,11 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate global parameters
+	if err := validateRouteParameters(c.Parameters); err != nil {
+		return fmt.Errorf("invalid parameters: %w", err)
+	}
+
 	// Validate routes
 	for routeName, route := range c.Routes {
 		// Check if provider exists
@@ -47,10 +58,205 @@ func (c *Config) Validate() error {
 			}
 		}
 
+		// Validate token threshold
+		if route.Threshold < 0 {
+			return fmt.Errorf("route %s: threshold cannot be negative", routeName)
+		}
+
 		// Validate parameters
 		if err := validateRouteParameters(route.Parameters); err != nil {
 			return fmt.Errorf("invalid parameters in route %s: %w", routeName, err)
 		}
+
+		// Validate time-of-day overrides
+		for i := range route.Schedules {
+			if err := validateSchedule(&route.Schedules[i], providerNames); err != nil {
+				return fmt.Errorf("invalid schedule %d in route %s: %w", i, routeName, err)
+			}
+		}
+
+		// Validate multi-target selection
+		if err := validateRouteTargets(route, providerNames); err != nil {
+			return fmt.Errorf("route %s: %w", routeName, err)
+		}
+
+		// Validate traffic mirroring
+		if route.Shadow != nil {
+			if err := validateShadow(*route.Shadow, providerNames); err != nil {
+				return fmt.Errorf("invalid shadow in route %s: %w", routeName, err)
+			}
+		}
+	}
+
+	// Validate and compile header routing rules
+	for i := range c.HeaderRules {
+		rule := &c.HeaderRules[i]
+		if rule.Header == "" {
+			return fmt.Errorf("header rule %d: header is required", i)
+		}
+		if rule.Provider == "" || rule.Model == "" {
+			return fmt.Errorf("header rule %d: provider and model are required", i)
+		}
+		if !providerNames[rule.Provider] {
+			return fmt.Errorf("header rule %d references unknown provider: %s", i, rule.Provider)
+		}
+		if (rule.Value == "") == (rule.Pattern == "") {
+			return fmt.Errorf("header rule %d: exactly one of value or pattern is required", i)
+		}
+		if err := rule.Compile(); err != nil {
+			return fmt.Errorf("header rule %d: invalid pattern: %w", i, err)
+		}
+	}
+
+	// Validate and compile content routing rules
+	for i := range c.ContentRules {
+		rule := &c.ContentRules[i]
+		if rule.Provider == "" || rule.Model == "" {
+			return fmt.Errorf("content rule %d: provider and model are required", i)
+		}
+		if !providerNames[rule.Provider] {
+			return fmt.Errorf("content rule %d references unknown provider: %s", i, rule.Provider)
+		}
+		if err := rule.Compile(); err != nil {
+			return fmt.Errorf("content rule %d: invalid pattern: %w", i, err)
+		}
+	}
+
+	// Validate tool roundtrip threshold
+	if c.Performance.MaxToolRoundtrips < 0 {
+		return fmt.Errorf("max_tool_roundtrips cannot be negative")
+	}
+
+	// Validate per-field size caps
+	if c.Performance.MaxMessageBytes < 0 {
+		return fmt.Errorf("max_message_bytes cannot be negative")
+	}
+	if c.Performance.MaxContentBlocks < 0 {
+		return fmt.Errorf("max_content_blocks cannot be negative")
+	}
+
+	// Validate idempotency cache lifetime
+	if c.Performance.IdempotencyTTL < 0 {
+		return fmt.Errorf("idempotency_ttl cannot be negative")
+	}
+
+	// Validate sticky session lifetime
+	if c.Performance.StickySessionTTL < 0 {
+		return fmt.Errorf("sticky_session_ttl cannot be negative")
+	}
+
+	// Validate the global request queue
+	if c.Performance.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("max_concurrent_requests cannot be negative")
+	}
+	if c.Performance.QueueTimeout < 0 {
+		return fmt.Errorf("queue_timeout cannot be negative")
+	}
+
+	// Validate the overall request deadlines
+	if c.Performance.TotalRequestTimeout < 0 {
+		return fmt.Errorf("total_request_timeout cannot be negative")
+	}
+	if c.Performance.TotalStreamingTimeout < 0 {
+		return fmt.Errorf("total_streaming_timeout cannot be negative")
+	}
+
+	// Validate inbound API keys
+	for _, key := range c.InboundAPIKeys {
+		if key == "" {
+			return fmt.Errorf("inbound_api_keys entries cannot be empty")
+		}
+	}
+
+	// Validate CORS origins
+	for _, origin := range c.Security.AllowedOrigins {
+		if err := validateOrigin(origin); err != nil {
+			return fmt.Errorf("invalid allowed_origins entry %q: %w", origin, err)
+		}
+	}
+
+	// Validate path access rules
+	if err := validatePathRules("allow", c.Security.Paths.Allow); err != nil {
+		return err
+	}
+	if err := validatePathRules("deny", c.Security.Paths.Deny); err != nil {
+		return err
+	}
+
+	// Validate per-key model restrictions
+	inboundKeys := map[string]bool{c.APIKey: true}
+	for _, key := range c.InboundAPIKeys {
+		inboundKeys[key] = true
+	}
+	for i, rule := range c.Security.ModelAccess {
+		if rule.Key == "" || !inboundKeys[rule.Key] {
+			return fmt.Errorf("model_access rule %d: key must be apikey or one of inbound_api_keys", i)
+		}
+		if len(rule.Models) == 0 {
+			return fmt.Errorf("model_access rule %d: at least one model must be specified", i)
+		}
+	}
+
+	if c.Security.RateLimit.RequestsPerMinute < 0 {
+		return fmt.Errorf("rate_limit requests_per_minute cannot be negative")
+	}
+	switch c.Security.RateLimit.Keying {
+	case "", RateLimitKeyingIP, RateLimitKeyingAPIKey:
+	default:
+		return fmt.Errorf("invalid rate_limit keying %q: must be ip or api_key", c.Security.RateLimit.Keying)
+	}
+
+	if err := validateAudit(c.Security.Audit); err != nil {
+		return err
+	}
+	if err := validatePromptInjection(c.Security.PromptInjection); err != nil {
+		return err
+	}
+
+	// Validate stream keepalive interval
+	if c.Streaming.KeepAliveInterval < 0 {
+		return fmt.Errorf("keep_alive_interval cannot be negative")
+	}
+
+	// Validate the stream flush strategy
+	switch c.Streaming.FlushMode {
+	case "", FlushModeImmediate, FlushModeBatched:
+	default:
+		return fmt.Errorf("invalid streaming flush_mode %q: must be immediate or batched", c.Streaming.FlushMode)
+	}
+	if c.Streaming.FlushBatchSize < 0 {
+		return fmt.Errorf("flush_batch_size cannot be negative")
+	}
+	if c.Streaming.FlushInterval < 0 {
+		return fmt.Errorf("flush_interval cannot be negative")
+	}
+
+	// Validate log format
+	switch c.Logging.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid logging format %q: must be text or json", c.Logging.Format)
+	}
+
+	// Validate the retry budget
+	if c.Retry.BudgetRatio < 0 || c.Retry.BudgetRatio > 1 {
+		return fmt.Errorf("retry budget_ratio must be between 0 and 1")
+	}
+	if c.Retry.BudgetBurst < 0 {
+		return fmt.Errorf("retry budget_burst cannot be negative")
+	}
+
+	// Validate spend and token budgets
+	if err := validateBudgets(c, providerNames); err != nil {
+		return err
+	}
+
+	// Validate the trace collector endpoint
+	if c.Tracing.Endpoint != "" {
+		u, err := url.Parse(c.Tracing.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid tracing endpoint %q: must be an http or https URL", c.Tracing.Endpoint)
+		}
 	}
 
 	// Validate log file path if logging is enabled
@@ -61,6 +267,22 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate stream recording directory if recording is enabled
+	if strings.ContainsAny(c.StreamRecordDir, "\x00") {
+		return fmt.Errorf("invalid stream record directory")
+	}
+	if strings.ContainsAny(c.Replay.Dir, "\x00") {
+		return fmt.Errorf("invalid replay directory")
+	}
+	if c.Replay.MaxRequests < 0 || c.Replay.MaxAge < 0 {
+		return fmt.Errorf("replay max_requests and max_age cannot be negative")
+	}
+
+	// Capability mismatches do not fail validation, but every load reports them
+	for _, warning := range c.CapabilityWarnings() {
+		log.Printf("Warning: %s", warning)
+	}
+
 	return nil
 }
 
@@ -71,13 +293,152 @@ func validateProvider(p *Provider) error {
 		return fmt.Errorf("provider name is required")
 	}
 
+	// Mock providers answer locally, so they need no upstream URL
+	if p.Name == MockProviderName {
+		if err := validateMockProvider(p); err != nil {
+			return err
+		}
+	} else if err := validateAPIBaseURL(p.APIBaseURL); err != nil {
+		return err
+	}
+
+	// API key validation - warn if empty but don't fail
+	// This is just a warning, not an error
+	// The provider health check will handle this
+
+	// Validate models list
+	if len(p.Models) == 0 && p.Enabled {
+		return fmt.Errorf("at least one model must be specified for enabled provider")
+	}
+
+	// Jitter is a delay bound, so it cannot be negative
+	if p.MaxJitter < 0 {
+		return fmt.Errorf("max_jitter cannot be negative")
+	}
+
+	// Concurrency is a slot count, so zero means unlimited
+	if p.MaxConcurrency < 0 {
+		return fmt.Errorf("max_concurrency cannot be negative")
+	}
+
+	// Timeout overrides the global one, so zero means unset
+	if p.Timeout < 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+
+	// Validate field renames
+	if err := validateFieldRenames(p.FieldRenames); err != nil {
+		return err
+	}
+
+	// Validate default parameters
+	if err := validateRouteParameters(p.Parameters); err != nil {
+		return fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	// Unsupported params name top-level request fields
+	for _, param := range p.UnsupportedParams {
+		if param == "" {
+			return fmt.Errorf("unsupported_params entries cannot be empty")
+		}
+	}
+
+	// Vertex AI needs a project, either configured or from the service account
+	if p.Name == "vertex" && p.Project == "" && p.ServiceAccountFile == "" {
+		return fmt.Errorf("vertex provider requires project or service_account_file")
+	}
+
+	// The Gemini API version is a path segment, so only known ones are accepted
+	if p.Name == "gemini" {
+		switch p.APIVersion {
+		case "", GeminiAPIV1, GeminiAPIV1Beta, GeminiAPIV1Alpha:
+		default:
+			return fmt.Errorf("invalid api_version %q for gemini: must be %s, %s or %s",
+				p.APIVersion, GeminiAPIV1, GeminiAPIV1Beta, GeminiAPIV1Alpha)
+		}
+	}
+
+	// A CA bundle that cannot be used would leave the provider unreachable
+	if p.CACertFile != "" {
+		if err := validateCACertFile(p.CACertFile); err != nil {
+			return err
+		}
+	}
+
+	// The base path sits inside the URL path, so it cannot carry a query
+	if strings.ContainsAny(p.BasePath, "?# ") {
+		return fmt.Errorf("invalid base_path %q: must be a URL path", p.BasePath)
+	}
+
+	// Validate custom headers
+	if err := validateHeaders(p.Headers); err != nil {
+		return err
+	}
+
+	// Prices are rates, so they cannot be negative
+	for model, pricing := range p.Pricing {
+		if pricing.InputPerMillion < 0 || pricing.OutputPerMillion < 0 {
+			return fmt.Errorf("pricing for model %s cannot be negative", model)
+		}
+	}
+
+	// Context limits are token budgets, so they must be positive
+	for model, limit := range p.ContextLimits {
+		if limit <= 0 {
+			return fmt.Errorf("context limit for model %s must be positive", model)
+		}
+	}
+	for model, capabilities := range p.ModelCapabilities {
+		if capabilities.MaxContext < 0 {
+			return fmt.Errorf("max_context for model %s cannot be negative", model)
+		}
+		if capabilities.StreamOnly && !capabilities.SupportsStreaming() {
+			return fmt.Errorf("model %s cannot be stream_only without streaming support", model)
+		}
+	}
+	switch p.TruncationStrategy {
+	case "", TruncationDropOldest, TruncationError:
+	default:
+		return fmt.Errorf("invalid truncation_strategy %q: must be %s or %s", p.TruncationStrategy, TruncationDropOldest, TruncationError)
+	}
+
+	switch p.MultipleCompletions {
+	case "", MultipleCompletionsStrip, MultipleCompletionsEmulate:
+	default:
+		return fmt.Errorf("invalid multiple_completions %q: must be %s or %s", p.MultipleCompletions, MultipleCompletionsStrip, MultipleCompletionsEmulate)
+	}
+
+	if p.MaxTools < 0 {
+		return fmt.Errorf("max_tools cannot be negative")
+	}
+	if p.MaxToolSchemaBytes < 0 {
+		return fmt.Errorf("max_tool_schema_bytes cannot be negative")
+	}
+	switch p.ToolLimitStrategy {
+	case "", ToolLimitError, ToolLimitTruncate:
+	default:
+		return fmt.Errorf("invalid tool_limit_strategy %q: must be %s or %s", p.ToolLimitStrategy, ToolLimitError, ToolLimitTruncate)
+	}
+
+	// Validate transformer configs
+	for _, transformer := range p.Transformers {
+		if transformer.Name == "" {
+			return fmt.Errorf("transformer name is required")
+		}
+	}
+
+	return nil
+}
+
+// validateAPIBaseURL checks that a provider's base URL is an http(s) URL
+func validateAPIBaseURL(baseURL string) error {
 	// API base URL is required and must be valid
-	if p.APIBaseURL == "" {
+	if baseURL == "" {
 		return fmt.Errorf("API base URL is required")
 	}
 
 	// Parse and validate URL
-	u, err := url.Parse(p.APIBaseURL)
+	u, err := url.Parse(baseURL)
 	if err != nil {
 		return fmt.Errorf("invalid API base URL: %w", err)
 	}
@@ -86,23 +447,71 @@ func validateProvider(p *Provider) error {
 	if u.Scheme != "http" && u.Scheme != "https" {
 		return fmt.Errorf("API base URL must use http or https scheme")
 	}
+	return nil
+}
 
-	// API key validation - warn if empty but don't fail
-	// This is just a warning, not an error
-	// The provider health check will handle this
+// validateMockProvider checks a mock provider's response template and delays
+func validateMockProvider(p *Provider) error {
+	if _, err := template.New("mock_response").Parse(p.MockResponse); err != nil {
+		return fmt.Errorf("invalid mock_response template: %w", err)
+	}
+	if p.MockLatency < 0 {
+		return fmt.Errorf("mock_latency cannot be negative")
+	}
+	if p.MockChunkDelay < 0 {
+		return fmt.Errorf("mock_chunk_delay cannot be negative")
+	}
+	return nil
+}
 
-	// Validate models list
-	if len(p.Models) == 0 && p.Enabled {
-		return fmt.Errorf("at least one model must be specified for enabled provider")
+// validateHeaders validates a provider's custom header names and values
+func validateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if !isHeaderToken(name) {
+			return fmt.Errorf("headers: invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("headers: value for %s contains invalid characters", name)
+		}
+		switch strings.ToLower(name) {
+		case "host", "content-length", "transfer-encoding", "connection":
+			return fmt.Errorf("headers: %s cannot be overridden", name)
+		}
 	}
+	return nil
+}
 
-	// Validate transformer configs
-	for _, transformer := range p.Transformers {
-		if transformer.Name == "" {
-			return fmt.Errorf("transformer name is required")
+// isHeaderToken reports whether name is a valid HTTP header field name
+func isHeaderToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
 		}
 	}
+	return true
+}
 
+// validateFieldRenames validates a provider's field rename map
+func validateFieldRenames(renames map[string]string) error {
+	targets := make(map[string]string, len(renames))
+	for from, to := range renames {
+		if from == "" || to == "" {
+			return fmt.Errorf("field_renames entries must have non-empty source and target")
+		}
+		if from == to {
+			return fmt.Errorf("field_renames: %s is renamed to itself", from)
+		}
+		if other, exists := targets[to]; exists {
+			return fmt.Errorf("field_renames: %s and %s both rename to %s", other, from, to)
+		}
+		targets[to] = from
+	}
 	return nil
 }
 
@@ -132,7 +541,177 @@ func validateCondition(c *Condition) error {
 	return nil
 }
 
-// validateRouteParameters validates parameters configured for a route
+// validateSchedule validates a route's time-of-day override
+func validateSchedule(s *Schedule, providerNames map[string]bool) error {
+	if s.Provider == "" {
+		return fmt.Errorf("provider is required")
+	}
+	if !providerNames[s.Provider] {
+		return fmt.Errorf("unknown provider: %s", s.Provider)
+	}
+
+	if err := s.Compile(); err != nil {
+		return err
+	}
+	if s.start == s.end {
+		return fmt.Errorf("start and end cannot be equal")
+	}
+	return nil
+}
+
+// validateRouteTargets validates a route's extra targets and the strategy
+// that chooses between them
+func validateRouteTargets(route Route, providerNames map[string]bool) error {
+	for i, target := range route.Targets {
+		if target.Provider == "" || target.Model == "" {
+			return fmt.Errorf("target %d: provider and model are required", i)
+		}
+		if !providerNames[target.Provider] {
+			return fmt.Errorf("target %d references unknown provider: %s", i, target.Provider)
+		}
+	}
+
+	switch route.Strategy {
+	case "":
+		if len(route.Targets) > 0 {
+			return fmt.Errorf("targets require a strategy")
+		}
+	case RouteStrategyLatencyAware:
+		if len(route.Candidates()) < 2 {
+			return fmt.Errorf("strategy %s needs at least two candidates", route.Strategy)
+		}
+	default:
+		return fmt.Errorf("unknown strategy: %s", route.Strategy)
+	}
+
+	if route.Exploration < 0 || route.Exploration > 1 {
+		return fmt.Errorf("exploration must be between 0 and 1")
+	}
+	return nil
+}
+
+// validateShadow validates a route's traffic mirror
+func validateShadow(s ShadowTarget, providerNames map[string]bool) error {
+	if s.Provider == "" || s.Model == "" {
+		return fmt.Errorf("provider and model are required")
+	}
+	if !providerNames[s.Provider] {
+		return fmt.Errorf("unknown provider: %s", s.Provider)
+	}
+	if s.Percentage <= 0 || s.Percentage > 100 {
+		return fmt.Errorf("percentage must be greater than 0 and at most 100")
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	return nil
+}
+
+// validateOrigin checks that a CORS origin is "*" or a scheme and host
+// without a path, as browsers send it in the Origin header
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be \"*\" or an http or https origin such as https://example.com")
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("must not have a path, trailing slash, query or credentials")
+	}
+	return nil
+}
+
+// validatePathRules validates the path access rules of one list
+func validatePathRules(list string, rules []PathRule) error {
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.Prefix, "/") {
+			return fmt.Errorf("invalid paths.%s prefix %q: must start with /", list, rule.Prefix)
+		}
+		for _, source := range rule.AllowFrom {
+			if _, err := ParseSource(source); err != nil {
+				return fmt.Errorf("invalid paths.%s allow_from entry %q: %w", list, source, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateBudgets validates the global budget and each provider's budget
+// validatePromptInjection checks the threshold and the patterns and their
+// severities
+func validatePromptInjection(p PromptInjectionConfig) error {
+	severities := map[string]bool{"low": true, "medium": true, "high": true}
+	if p.Threshold != "" && !severities[p.Threshold] {
+		return fmt.Errorf("invalid prompt_injection threshold %q: must be low, medium or high", p.Threshold)
+	}
+	for i, pattern := range p.Patterns {
+		if _, err := regexp.Compile("(?i)" + pattern.Pattern); pattern.Pattern == "" || err != nil {
+			return fmt.Errorf("prompt_injection pattern %d: invalid pattern %q", i, pattern.Pattern)
+		}
+		if pattern.Severity != "" && !severities[pattern.Severity] {
+			return fmt.Errorf("prompt_injection pattern %d: invalid severity %q: must be low, medium or high", i, pattern.Severity)
+		}
+	}
+	return nil
+}
+
+// validateAudit checks the audit sink, its settings and the rotation limits
+func validateAudit(a AuditConfig) error {
+	switch a.Sink {
+	case "", AuditSinkFile, AuditSinkStdout, AuditSinkSyslog:
+	case AuditSinkWebhook:
+		if a.Enabled && a.WebhookURL == "" {
+			return fmt.Errorf("audit webhook_url is required for the webhook sink")
+		}
+	default:
+		return fmt.Errorf("invalid audit sink %q: must be file, stdout, syslog or webhook", a.Sink)
+	}
+	if a.WebhookBatchSize < 0 || a.WebhookFlushInterval < 0 || a.WebhookMaxRetries < 0 {
+		return fmt.Errorf("audit webhook_batch_size, webhook_flush_interval and webhook_max_retries cannot be negative")
+	}
+	if a.MaxSizeMB < 0 || a.MaxAgeDays < 0 || a.MaxBackups < 0 {
+		return fmt.Errorf("audit max_size_mb, max_age_days and max_backups cannot be negative")
+	}
+	return nil
+}
+
+func validateBudgets(c *Config, providerNames map[string]bool) error {
+	if c.Budget.Window < 0 {
+		return fmt.Errorf("budget window cannot be negative")
+	}
+	if c.Budget.MaxTokens < 0 || c.Budget.MaxCost < 0 {
+		return fmt.Errorf("budget max_tokens and max_cost cannot be negative")
+	}
+
+	for _, provider := range c.Providers {
+		budget := provider.Budget
+		if budget == nil {
+			continue
+		}
+		if budget.MaxTokens < 0 || budget.MaxCost < 0 {
+			return fmt.Errorf("provider %s: budget max_tokens and max_cost cannot be negative", provider.Name)
+		}
+		if budget.Fallback == "" {
+			continue
+		}
+		parts := strings.SplitN(budget.Fallback, ",", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("provider %s: budget fallback %q must be \"provider,model\"", provider.Name, budget.Fallback)
+		}
+		if !providerNames[parts[0]] {
+			return fmt.Errorf("provider %s: budget fallback references unknown provider: %s", provider.Name, parts[0])
+		}
+		if parts[0] == provider.Name {
+			return fmt.Errorf("provider %s: budget fallback cannot be the same provider", provider.Name)
+		}
+	}
+	return nil
+}
+
+// validateRouteParameters validates request default parameters configured
+// for a route, a provider or globally
 func validateRouteParameters(params map[string]interface{}) error {
 	if params == nil {
 		return nil // Parameters are optional
@@ -205,3 +784,85 @@ func validateRouteParameters(params map[string]interface{}) error {
 
 	return nil
 }
+
+// validateCACertFile checks that a CA bundle can be read and holds at least one
+// PEM certificate
+func validateCACertFile(path string) error {
+	pem, err := os.ReadFile(path) // #nosec G304 -- The path comes from the operator's configuration
+	if err != nil {
+		return fmt.Errorf("failed to read ca_cert_file: %w", err)
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates found in ca_cert_file %s", path)
+	}
+	return nil
+}
+
+// CapabilityWarnings lists routes and routing rules whose target model lacks
+// a feature the route implies, according to the provider's model
+// capabilities. Targets are expected to stream and call tools, as Claude Code
+// does, and the longContext route to fit more than its threshold.
+func (c *Config) CapabilityWarnings() []string {
+	providers := make(map[string]*Provider, len(c.Providers))
+	for i := range c.Providers {
+		providers[c.Providers[i].Name] = &c.Providers[i]
+	}
+
+	var warnings []string
+	check := func(target, providerName, model string, minContext int) {
+		provider, ok := providers[providerName]
+		if !ok {
+			return
+		}
+		capabilities, ok := provider.ModelCapabilities[model]
+		if !ok {
+			return
+		}
+		if !capabilities.SupportsStreaming() {
+			warnings = append(warnings, fmt.Sprintf("%s targets %s model %s, which does not support streaming", target, providerName, model))
+		}
+		if !capabilities.SupportsTools() {
+			warnings = append(warnings, fmt.Sprintf("%s targets %s model %s, which does not support tools", target, providerName, model))
+		}
+		if limit := provider.ContextLimit(model); minContext > 0 && limit > 0 && limit <= minContext {
+			warnings = append(warnings, fmt.Sprintf("%s targets %s model %s, whose %d token context does not exceed the %d token threshold",
+				target, providerName, model, limit, minContext))
+		}
+	}
+
+	routeNames := make([]string, 0, len(c.Routes))
+	for name := range c.Routes {
+		routeNames = append(routeNames, name)
+	}
+	sort.Strings(routeNames)
+
+	for _, name := range routeNames {
+		// Embeddings requests neither stream nor call tools
+		if name == "embeddings" {
+			continue
+		}
+		route := c.Routes[name]
+		minContext := 0
+		if name == "longContext" {
+			minContext = route.LongContextThreshold()
+		}
+		check("route "+name, route.Provider, route.Model, minContext)
+		for i, schedule := range route.Schedules {
+			model := schedule.Model
+			if model == "" {
+				model = route.Model
+			}
+			check(fmt.Sprintf("route %s schedule %d", name, i), schedule.Provider, model, minContext)
+		}
+		for i, target := range route.Targets {
+			check(fmt.Sprintf("route %s target %d", name, i), target.Provider, target.Model, minContext)
+		}
+	}
+	for i, rule := range c.HeaderRules {
+		check(fmt.Sprintf("header rule %d", i), rule.Provider, rule.Model, 0)
+	}
+	for i, rule := range c.ContentRules {
+		check(fmt.Sprintf("content rule %d", i), rule.Provider, rule.Model, 0)
+	}
+	return warnings
+}
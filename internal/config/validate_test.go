@@ -1,8 +1,14 @@
 package config
 
 import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestConfig_Validate(t *testing.T) {
@@ -1021,3 +1027,1104 @@ func TestConfig_ValidateWithRouteParameters(t *testing.T) {
 		}
 	})
 }
+
+func TestValidateProvider_MaxJitter(t *testing.T) {
+	provider := &Provider{
+		Name:       "openai",
+		APIBaseURL: "https://api.openai.com",
+		MaxJitter:  -1,
+	}
+
+	err := validateProvider(provider)
+	if err == nil || !strings.Contains(err.Error(), "max_jitter") {
+		t.Errorf("Expected max_jitter error, got: %v", err)
+	}
+
+	provider.MaxJitter = 50 * time.Millisecond
+	if err := validateProvider(provider); err != nil {
+		t.Errorf("Expected no error for positive jitter, got: %v", err)
+	}
+}
+
+func TestConfig_ValidateRouteThreshold(t *testing.T) {
+	cfg := &Config{
+		Port: 3456,
+		Providers: []Provider{
+			{Name: "gemini", APIBaseURL: "https://generativelanguage.googleapis.com", Models: []string{"gemini-1.5-pro"}, Enabled: true},
+		},
+		Routes: map[string]Route{
+			"longContext": {Provider: "gemini", Model: "gemini-1.5-pro", Threshold: -1},
+		},
+	}
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "threshold cannot be negative") {
+		t.Errorf("Expected threshold error, got: %v", err)
+	}
+
+	cfg.Routes["longContext"] = Route{Provider: "gemini", Model: "gemini-1.5-pro", Threshold: 100000}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for positive threshold, got: %v", err)
+	}
+}
+
+func TestValidateProvider_Timeout(t *testing.T) {
+	provider := &Provider{
+		Name:       "ollama",
+		APIBaseURL: "http://localhost:11434",
+		Timeout:    -time.Second,
+	}
+
+	err := validateProvider(provider)
+	if err == nil || !strings.Contains(err.Error(), "timeout must be positive") {
+		t.Errorf("Expected timeout error, got: %v", err)
+	}
+
+	provider.Timeout = 5 * time.Minute
+	if err := validateProvider(provider); err != nil {
+		t.Errorf("Expected no error for positive timeout, got: %v", err)
+	}
+}
+
+func TestValidateProvider_Pricing(t *testing.T) {
+	provider := &Provider{
+		Name:       "openai",
+		APIBaseURL: "https://api.openai.com/v1",
+		Pricing: map[string]Pricing{
+			"gpt-4o": {InputPerMillion: 2.5, OutputPerMillion: -10},
+		},
+	}
+
+	err := validateProvider(provider)
+	if err == nil || !strings.Contains(err.Error(), "pricing for model gpt-4o cannot be negative") {
+		t.Errorf("Expected pricing error, got: %v", err)
+	}
+
+	provider.Pricing["gpt-4o"] = Pricing{InputPerMillion: 2.5, OutputPerMillion: 10}
+	if err := validateProvider(provider); err != nil {
+		t.Errorf("Expected no error for valid pricing, got: %v", err)
+	}
+}
+
+func TestValidateFieldRenames(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames map[string]string
+		wantErr string
+	}{
+		{name: "nil", renames: nil},
+		{name: "valid", renames: map[string]string{"max_tokens": "max_completion_tokens", "stop": "stop_sequences"}},
+		{name: "empty source", renames: map[string]string{"": "x"}, wantErr: "non-empty"},
+		{name: "empty target", renames: map[string]string{"stop": ""}, wantErr: "non-empty"},
+		{name: "self rename", renames: map[string]string{"stop": "stop"}, wantErr: "renamed to itself"},
+		{name: "duplicate target", renames: map[string]string{"a": "x", "b": "x"}, wantErr: "both rename to x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFieldRenames(tt.renames)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateFieldLimits(t *testing.T) {
+	cfg := &Config{Port: 3456}
+
+	cfg.Performance.MaxMessageBytes = -1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "max_message_bytes") {
+		t.Errorf("Expected max_message_bytes error, got: %v", err)
+	}
+
+	cfg.Performance.MaxMessageBytes = 0
+	cfg.Performance.MaxContentBlocks = -1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "max_content_blocks") {
+		t.Errorf("Expected max_content_blocks error, got: %v", err)
+	}
+
+	cfg.Performance.MaxMessageBytes = 1 << 20
+	cfg.Performance.MaxContentBlocks = 100
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for positive limits, got: %v", err)
+	}
+}
+
+func TestConfig_ValidateLoggingFormat(t *testing.T) {
+	for _, format := range []string{"", "text", "json"} {
+		cfg := &Config{Port: 3456, Logging: LoggingConfig{Format: format}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Expected format %q to be valid, got: %v", format, err)
+		}
+	}
+
+	cfg := &Config{Port: 3456, Logging: LoggingConfig{Format: "xml"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid logging format") {
+		t.Errorf("Expected logging format error, got: %v", err)
+	}
+}
+
+func TestConfig_ValidateTracingEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "http://localhost:4318", "https://otel.example.com/otlp"} {
+		cfg := &Config{Port: 3456, Tracing: TracingConfig{Endpoint: endpoint}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Expected endpoint %q to be valid, got: %v", endpoint, err)
+		}
+	}
+
+	for _, endpoint := range []string{"localhost:4318", "grpc://localhost:4317", "http://"} {
+		cfg := &Config{Port: 3456, Tracing: TracingConfig{Endpoint: endpoint}}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid tracing endpoint") {
+			t.Errorf("Expected tracing endpoint error for %q, got: %v", endpoint, err)
+		}
+	}
+}
+
+func TestConfig_ValidateRetryBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   RetryConfig
+		wantErr string
+	}{
+		{name: "unset"},
+		{name: "valid", retry: RetryConfig{BudgetRatio: 0.1, BudgetBurst: 20}},
+		{name: "ratio above one", retry: RetryConfig{BudgetRatio: 1.5}, wantErr: "budget_ratio"},
+		{name: "negative ratio", retry: RetryConfig{BudgetRatio: -0.1}, wantErr: "budget_ratio"},
+		{name: "negative burst", retry: RetryConfig{BudgetRatio: 0.1, BudgetBurst: -1}, wantErr: "budget_burst"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Port: 3456, Retry: tt.retry}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %s error, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateBudgets(t *testing.T) {
+	providers := func(budget *ProviderBudget) []Provider {
+		return []Provider{
+			{Name: "openai", APIBaseURL: "https://api.openai.com", Models: []string{"gpt-4o"}, Enabled: true, Budget: budget},
+			{Name: "groq", APIBaseURL: "https://api.groq.com", Models: []string{"llama-3.3-70b-versatile"}, Enabled: true},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		budget    BudgetConfig
+		providers []Provider
+		wantErr   string
+	}{
+		{name: "unset", providers: providers(nil)},
+		{name: "valid", budget: BudgetConfig{Window: time.Hour, MaxTokens: 1000000, MaxCost: 50},
+			providers: providers(&ProviderBudget{MaxCost: 10, Fallback: "groq,llama-3.3-70b-versatile"})},
+		{name: "negative window", budget: BudgetConfig{Window: -time.Hour}, wantErr: "window"},
+		{name: "negative global tokens", budget: BudgetConfig{MaxTokens: -1}, wantErr: "max_tokens"},
+		{name: "negative provider cost", providers: providers(&ProviderBudget{MaxCost: -1}), wantErr: "max_cost"},
+		{name: "fallback without model", providers: providers(&ProviderBudget{MaxTokens: 10, Fallback: "groq"}), wantErr: "provider,model"},
+		{name: "unknown fallback provider", providers: providers(&ProviderBudget{MaxTokens: 10, Fallback: "mistral,small"}), wantErr: "unknown provider"},
+		{name: "fallback to itself", providers: providers(&ProviderBudget{MaxTokens: 10, Fallback: "openai,gpt-4o-mini"}), wantErr: "same provider"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Port: 3456, Budget: tt.budget, Providers: tt.providers}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %s error, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateIdempotencyTTL(t *testing.T) {
+	cfg := &Config{Port: 3456}
+
+	cfg.Performance.IdempotencyTTL = -time.Minute
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "idempotency_ttl") {
+		t.Errorf("Expected idempotency_ttl error, got: %v", err)
+	}
+
+	cfg.Performance.IdempotencyTTL = 10 * time.Minute
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for positive TTL, got: %v", err)
+	}
+}
+
+func TestConfig_ValidateStickySessionTTL(t *testing.T) {
+	cfg := &Config{Port: 3456}
+
+	cfg.Performance.StickySessionTTL = -time.Minute
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "sticky_session_ttl") {
+		t.Errorf("Expected sticky_session_ttl error, got: %v", err)
+	}
+
+	cfg.Performance.StickySessionTTL = 30 * time.Minute
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for positive TTL, got: %v", err)
+	}
+}
+
+func TestConfig_ValidateRequestQueue(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		timeout time.Duration
+		wantErr string
+	}{
+		{name: "unlimited", limit: 0},
+		{name: "limit with timeout", limit: 50, timeout: 2 * time.Second},
+		{name: "negative limit", limit: -1, wantErr: "max_concurrent_requests"},
+		{name: "negative timeout", limit: 50, timeout: -time.Second, wantErr: "queue_timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Port: 3456}
+			cfg.Performance.MaxConcurrentRequests = tt.limit
+			cfg.Performance.QueueTimeout = tt.timeout
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %s error, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateTotalRequestTimeouts(t *testing.T) {
+	cfg := &Config{Port: 3456}
+
+	cfg.Performance.TotalRequestTimeout = -time.Second
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "total_request_timeout") {
+		t.Errorf("Expected total_request_timeout error, got: %v", err)
+	}
+
+	cfg.Performance.TotalRequestTimeout = time.Minute
+	cfg.Performance.TotalStreamingTimeout = -time.Second
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "total_streaming_timeout") {
+		t.Errorf("Expected total_streaming_timeout error, got: %v", err)
+	}
+
+	cfg.Performance.TotalStreamingTimeout = 10 * time.Minute
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+}
+
+func TestProvider_ValidateContextLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   map[string]int
+		strategy string
+		wantErr  string
+	}{
+		{name: "valid", limits: map[string]int{"llama3": 8192}, strategy: TruncationDropOldest},
+		{name: "error strategy", limits: map[string]int{"llama3": 8192}, strategy: TruncationError},
+		{name: "zero limit", limits: map[string]int{"llama3": 0}, wantErr: "context limit for model llama3"},
+		{name: "unknown strategy", strategy: "summarize", wantErr: "invalid truncation_strategy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provider{
+				Name:               "groq",
+				APIBaseURL:         "https://api.groq.com",
+				Models:             []string{"llama3"},
+				ContextLimits:      tt.limits,
+				TruncationStrategy: tt.strategy,
+			}
+			err := validateProvider(p)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProvider_ValidateMultipleCompletions(t *testing.T) {
+	for _, strategy := range []string{"", MultipleCompletionsStrip, MultipleCompletionsEmulate} {
+		p := &Provider{Name: "groq", APIBaseURL: "https://api.groq.com", MultipleCompletions: strategy}
+		if err := validateProvider(p); err != nil {
+			t.Errorf("Unexpected error for %q: %v", strategy, err)
+		}
+	}
+
+	p := &Provider{Name: "groq", APIBaseURL: "https://api.groq.com", MultipleCompletions: "merge"}
+	if err := validateProvider(p); err == nil || !strings.Contains(err.Error(), "invalid multiple_completions") {
+		t.Errorf("Expected invalid multiple_completions error, got %v", err)
+	}
+}
+
+func TestProvider_ValidateBasePath(t *testing.T) {
+	p := &Provider{Name: "openai", APIBaseURL: "https://gateway.example.com", BasePath: "/llm/openai"}
+	if err := validateProvider(p); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	p.BasePath = "/llm?backend=openai"
+	if err := validateProvider(p); err == nil || !strings.Contains(err.Error(), "invalid base_path") {
+		t.Errorf("Expected invalid base_path error, got %v", err)
+	}
+}
+
+func TestProvider_ValidateCACertFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	badFile := filepath.Join(dir, "bad.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+	if err := os.WriteFile(badFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "valid bundle", file: caFile},
+		{name: "no certificates", file: badFile, wantErr: "no certificates found in ca_cert_file"},
+		{name: "missing file", file: filepath.Join(dir, "missing.pem"), wantErr: "failed to read ca_cert_file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provider{Name: "corp", APIBaseURL: "https://llm.corp.example.com/v1", CACertFile: tt.file}
+			err := validateProvider(p)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProvider_ValidateGeminiAPIVersion(t *testing.T) {
+	for _, version := range []string{"", GeminiAPIV1, GeminiAPIV1Beta, GeminiAPIV1Alpha} {
+		p := &Provider{Name: "gemini", APIBaseURL: "https://generativelanguage.googleapis.com", APIVersion: version}
+		if err := validateProvider(p); err != nil {
+			t.Errorf("Unexpected error for %q: %v", version, err)
+		}
+	}
+
+	p := &Provider{Name: "gemini", APIBaseURL: "https://generativelanguage.googleapis.com", APIVersion: "v2"}
+	if err := validateProvider(p); err == nil || !strings.Contains(err.Error(), "invalid api_version") {
+		t.Errorf("Expected invalid api_version error, got %v", err)
+	}
+
+	// Other providers keep their own version formats
+	p = &Provider{Name: "azure", APIBaseURL: "https://example.openai.azure.com", APIVersion: "2024-02-01"}
+	if err := validateProvider(p); err != nil {
+		t.Errorf("Unexpected error for azure: %v", err)
+	}
+
+	if version := (&Provider{Name: "gemini"}).GeminiAPIVersion(); version != GeminiAPIV1Beta {
+		t.Errorf("Expected default version %s, got %s", GeminiAPIV1Beta, version)
+	}
+}
+
+func TestProvider_ValidateToolLimits(t *testing.T) {
+	for _, strategy := range []string{"", ToolLimitError, ToolLimitTruncate} {
+		p := &Provider{Name: "openai", APIBaseURL: "https://api.openai.com", MaxTools: 64, MaxToolSchemaBytes: 65536, ToolLimitStrategy: strategy}
+		if err := validateProvider(p); err != nil {
+			t.Errorf("Unexpected error for %q: %v", strategy, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		provider Provider
+		want     string
+	}{
+		{"negative max_tools", Provider{MaxTools: -1}, "max_tools cannot be negative"},
+		{"negative schema size", Provider{MaxToolSchemaBytes: -1}, "max_tool_schema_bytes cannot be negative"},
+		{"unknown strategy", Provider{ToolLimitStrategy: "drop"}, "invalid tool_limit_strategy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.provider
+			p.Name = "openai"
+			p.APIBaseURL = "https://api.openai.com"
+			if err := validateProvider(&p); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected %q error, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestProvider_ValidateModelCapabilities(t *testing.T) {
+	p := &Provider{Name: "ollama", APIBaseURL: "http://localhost:11434", Models: []string{"llama3"}}
+
+	p.ModelCapabilities = map[string]ModelCapabilities{"llama3": {MaxContext: -1}}
+	if err := validateProvider(p); err == nil || !strings.Contains(err.Error(), "max_context for model llama3 cannot be negative") {
+		t.Errorf("Expected max_context error, got: %v", err)
+	}
+
+	streaming := false
+	p.ModelCapabilities = map[string]ModelCapabilities{"llama3": {StreamOnly: true, Streaming: &streaming}}
+	if err := validateProvider(p); err == nil || !strings.Contains(err.Error(), "cannot be stream_only") {
+		t.Errorf("Expected stream_only error, got: %v", err)
+	}
+
+	p.ModelCapabilities = map[string]ModelCapabilities{"llama3": {MaxContext: 8192, StreamOnly: true}}
+	if err := validateProvider(p); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+}
+
+func TestProvider_ContextLimit(t *testing.T) {
+	p := &Provider{
+		ContextLimits: map[string]int{"llama3": 4096},
+		ModelCapabilities: map[string]ModelCapabilities{
+			"llama3":  {MaxContext: 8192},
+			"mistral": {MaxContext: 32768},
+		},
+	}
+
+	for model, want := range map[string]int{"llama3": 4096, "mistral": 32768, "phi3": 0} {
+		if got := p.ContextLimit(model); got != want {
+			t.Errorf("ContextLimit(%s) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func TestConfig_CapabilityWarnings(t *testing.T) {
+	disabled := false
+	cfg := &Config{
+		Providers: []Provider{{
+			Name: "ollama",
+			ModelCapabilities: map[string]ModelCapabilities{
+				"llama3":  {Tools: &disabled, MaxContext: 8192},
+				"mistral": {Streaming: &disabled},
+				"qwen":    {MaxContext: 131072},
+			},
+		}},
+		Routes: map[string]Route{
+			"default":     {Provider: "ollama", Model: "qwen"},
+			"background":  {Provider: "ollama", Model: "llama3"},
+			"longContext": {Provider: "ollama", Model: "llama3"},
+			"think":       {Provider: "ollama", Model: "phi3"},
+			"embeddings":  {Provider: "ollama", Model: "mistral"},
+		},
+		HeaderRules: []HeaderRule{{Header: "X-Team", Value: "ml", Provider: "ollama", Model: "mistral"}},
+	}
+
+	warnings := cfg.CapabilityWarnings()
+	expected := []string{
+		"route background targets ollama model llama3, which does not support tools",
+		"route longContext targets ollama model llama3, which does not support tools",
+		"route longContext targets ollama model llama3, whose 8192 token context does not exceed the 60000 token threshold",
+		"header rule 0 targets ollama model mistral, which does not support streaming",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected warnings:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(warnings, "\n"))
+	}
+}
+
+func TestProvider_ValidateUnsupportedParams(t *testing.T) {
+	p := &Provider{Name: "ollama", APIBaseURL: "http://localhost:11434", Models: []string{"llama3"}}
+
+	p.UnsupportedParams = []string{"seed", ""}
+	if err := validateProvider(p); err == nil || !strings.Contains(err.Error(), "unsupported_params") {
+		t.Errorf("Expected unsupported_params error, got: %v", err)
+	}
+
+	p.UnsupportedParams = []string{"seed"}
+	if err := validateProvider(p); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+}
+
+func TestConfig_ValidateDefaultParameters(t *testing.T) {
+	provider := Provider{Name: "openai", APIBaseURL: "https://api.openai.com", Models: []string{"gpt-4"}}
+	tests := []struct {
+		name     string
+		global   map[string]interface{}
+		provider map[string]interface{}
+		wantErr  string
+	}{
+		{"Valid", map[string]interface{}{"temperature": 0.5}, map[string]interface{}{"top_p": 0.9}, ""},
+		{"GlobalTemperatureOutOfRange", map[string]interface{}{"temperature": 2.5}, nil, "temperature must be between 0 and 2"},
+		{"ProviderTopPOutOfRange", nil, map[string]interface{}{"top_p": 1.5}, "top_p must be between 0 and 1"},
+		{"ProviderTemperatureNotNumber", nil, map[string]interface{}{"temperature": "hot"}, "temperature must be a number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := provider
+			p.Parameters = tt.provider
+			cfg := &Config{Port: 3456, Providers: []Provider{p}, Parameters: tt.global}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateInboundAPIKeys(t *testing.T) {
+	cfg := &Config{Port: 3456}
+
+	cfg.InboundAPIKeys = []string{"key-one", ""}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "inbound_api_keys") {
+		t.Errorf("Expected inbound_api_keys error, got: %v", err)
+	}
+
+	cfg.InboundAPIKeys = []string{"key-one", "key-two"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for non-empty keys, got: %v", err)
+	}
+}
+
+func TestConfig_ValidateModelAccess(t *testing.T) {
+	cfg := &Config{Port: 3456, APIKey: "main-key", InboundAPIKeys: []string{"team-key"}}
+
+	cfg.Security.ModelAccess = []ModelAccessRule{{Key: "unknown-key", Models: []string{"gpt-4"}}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "model_access") {
+		t.Errorf("Expected model_access error for an unknown key, got: %v", err)
+	}
+
+	cfg.Security.ModelAccess = []ModelAccessRule{{Key: "team-key"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "at least one model") {
+		t.Errorf("Expected model_access error for a rule without models, got: %v", err)
+	}
+
+	cfg.Security.ModelAccess = []ModelAccessRule{{Key: "team-key", Models: []string{"gpt-4"}}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for a rule on an inbound key, got: %v", err)
+	}
+}
+
+func TestConfig_ValidateRateLimit(t *testing.T) {
+	cfg := &Config{Port: 3456}
+
+	cfg.Security.RateLimit = RateLimitConfig{Enabled: true, RequestsPerMinute: -1}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "requests_per_minute") {
+		t.Errorf("Expected requests_per_minute error, got: %v", err)
+	}
+
+	cfg.Security.RateLimit.RequestsPerMinute = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for the default limit, got: %v", err)
+	}
+
+	cfg.Security.RateLimit.Keying = "user"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "keying") {
+		t.Errorf("Expected keying error, got: %v", err)
+	}
+
+	cfg.Security.RateLimit.Keying = RateLimitKeyingAPIKey
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for api_key keying, got: %v", err)
+	}
+}
+
+func TestConfig_ValidatePromptInjection(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  PromptInjectionConfig
+		wantErr string
+	}{
+		{name: "default", filter: PromptInjectionConfig{Enabled: true, Block: true}},
+		{name: "custom patterns", filter: PromptInjectionConfig{Enabled: true, Threshold: "high", Patterns: []PromptInjectionPattern{
+			{Pattern: `reveal\s+secrets`, Severity: "high"},
+			{Pattern: "be evil"},
+		}}},
+		{name: "invalid threshold", filter: PromptInjectionConfig{Threshold: "severe"}, wantErr: "threshold"},
+		{name: "invalid pattern", filter: PromptInjectionConfig{Patterns: []PromptInjectionPattern{{Pattern: "("}}}, wantErr: "pattern 0"},
+		{name: "empty pattern", filter: PromptInjectionConfig{Patterns: []PromptInjectionPattern{{Severity: "low"}}}, wantErr: "pattern 0"},
+		{name: "invalid severity", filter: PromptInjectionConfig{Patterns: []PromptInjectionPattern{{Pattern: "x", Severity: "critical"}}}, wantErr: "severity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Port: 3456}
+			cfg.Security.PromptInjection = tt.filter
+			err := cfg.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateAudit(t *testing.T) {
+	tests := []struct {
+		name    string
+		audit   AuditConfig
+		wantErr string
+	}{
+		{name: "default file sink", audit: AuditConfig{Enabled: true}},
+		{name: "stdout", audit: AuditConfig{Enabled: true, Sink: AuditSinkStdout}},
+		{name: "webhook", audit: AuditConfig{Enabled: true, Sink: AuditSinkWebhook, WebhookURL: "https://siem.example.com/ingest"}},
+		{name: "webhook without url", audit: AuditConfig{Enabled: true, Sink: AuditSinkWebhook}, wantErr: "webhook_url"},
+		{name: "unknown sink", audit: AuditConfig{Sink: "kafka"}, wantErr: "invalid audit sink"},
+		{name: "negative batch size", audit: AuditConfig{WebhookBatchSize: -1}, wantErr: "cannot be negative"},
+		{name: "rotation", audit: AuditConfig{Enabled: true, MaxSizeMB: 100, MaxAgeDays: 7, MaxBackups: 10, Compress: true}},
+		{name: "negative max size", audit: AuditConfig{MaxSizeMB: -1}, wantErr: "max_size_mb"},
+		{name: "negative max backups", audit: AuditConfig{MaxBackups: -1}, wantErr: "max_backups"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Port: 3456}
+			cfg.Security.Audit = tt.audit
+			err := cfg.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateKeepAliveInterval(t *testing.T) {
+	cfg := &Config{Port: 3456}
+
+	cfg.Streaming.KeepAliveInterval = -time.Second
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "keep_alive_interval") {
+		t.Errorf("Expected keep_alive_interval error, got: %v", err)
+	}
+
+	cfg.Streaming.KeepAliveInterval = 15 * time.Second
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for positive interval, got: %v", err)
+	}
+}
+
+func TestConfig_ValidateStreamFlush(t *testing.T) {
+	tests := []struct {
+		name      string
+		streaming StreamingConfig
+		wantErr   string
+	}{
+		{name: "default", streaming: StreamingConfig{}},
+		{name: "immediate", streaming: StreamingConfig{FlushMode: FlushModeImmediate}},
+		{name: "batched", streaming: StreamingConfig{FlushMode: FlushModeBatched, FlushBatchSize: 4, FlushInterval: 20 * time.Millisecond}},
+		{name: "unknown mode", streaming: StreamingConfig{FlushMode: "lazy"}, wantErr: "invalid streaming flush_mode"},
+		{name: "negative batch size", streaming: StreamingConfig{FlushMode: FlushModeBatched, FlushBatchSize: -1}, wantErr: "flush_batch_size"},
+		{name: "negative interval", streaming: StreamingConfig{FlushMode: FlushModeBatched, FlushInterval: -time.Second}, wantErr: "flush_interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Port: 3456, Streaming: tt.streaming}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateRouteSchedules(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		wantErr  string
+	}{
+		{"valid window", Schedule{Start: "22:00", End: "06:00", Timezone: "America/New_York", Provider: "cheap"}, ""},
+		{"missing provider", Schedule{Start: "22:00", End: "06:00"}, "provider is required"},
+		{"unknown provider", Schedule{Start: "22:00", End: "06:00", Provider: "missing"}, "unknown provider"},
+		{"bad start", Schedule{Start: "10pm", End: "06:00", Provider: "cheap"}, "invalid start"},
+		{"bad end", Schedule{Start: "22:00", End: "24:30", Provider: "cheap"}, "invalid end"},
+		{"bad timezone", Schedule{Start: "22:00", End: "06:00", Timezone: "Mars/Olympus", Provider: "cheap"}, "invalid timezone"},
+		{"empty window", Schedule{Start: "09:00", End: "09:00", Provider: "cheap"}, "cannot be equal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port: 3456,
+				Providers: []Provider{
+					{Name: "main", APIBaseURL: "https://api.example.com", APIKey: "key", Models: []string{"big"}, Enabled: true},
+					{Name: "cheap", APIBaseURL: "https://cheap.example.com", APIKey: "key", Models: []string{"small"}, Enabled: true},
+				},
+				Routes: map[string]Route{
+					"default": {Provider: "main", Model: "big", Schedules: []Schedule{tt.schedule}},
+				},
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				// The time zone is loaded once, here, rather than per routing decision
+				if schedule := &cfg.Routes["default"].Schedules[0]; !schedule.Compiled() {
+					t.Error("Expected validation to compile the schedule")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateProvider_Headers(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr string
+	}{
+		{"valid headers", map[string]string{"HTTP-Referer": "https://example.com", "X-Title": "My App"}, ""},
+		{"empty name", map[string]string{"": "value"}, "invalid header name"},
+		{"space in name", map[string]string{"X Title": "value"}, "invalid header name"},
+		{"colon in name", map[string]string{"X-Title:": "value"}, "invalid header name"},
+		{"newline in value", map[string]string{"X-Title": "a\r\nX-Injected: b"}, "invalid characters"},
+		{"reserved header", map[string]string{"Host": "evil.example.com"}, "cannot be overridden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &Provider{
+				Name:       "openrouter",
+				APIBaseURL: "https://openrouter.ai/api/v1",
+				Headers:    tt.headers,
+			}
+
+			err := validateProvider(provider)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateProvider_Vertex(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		wantErr  bool
+	}{
+		{"project only", Provider{Project: "my-project"}, false},
+		{"service account only", Provider{ServiceAccountFile: "/etc/ccproxy/sa.json"}, false},
+		{"missing project and service account", Provider{APIKey: "token"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := tt.provider
+			provider.Name = "vertex"
+			provider.APIBaseURL = "https://us-central1-aiplatform.googleapis.com"
+
+			err := validateProvider(&provider)
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateProvider_Mock(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		wantErr  bool
+	}{
+		{"no base URL needed", Provider{}, false},
+		{"template", Provider{MockResponse: "Echo: {{.Prompt}}", MockLatency: time.Second}, false},
+		{"invalid template", Provider{MockResponse: "{{.Prompt"}, true},
+		{"negative latency", Provider{MockLatency: -time.Second}, true},
+		{"negative chunk delay", Provider{MockChunkDelay: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := tt.provider
+			provider.Name = MockProviderName
+
+			err := validateProvider(&provider)
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateHeaderRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    HeaderRule
+		wantErr string
+	}{
+		{"exact value", HeaderRule{Header: "X-Tenant", Value: "premium", Provider: "main", Model: "big"}, ""},
+		{"pattern", HeaderRule{Header: "X-Tenant", Pattern: `^prem`, Provider: "main", Model: "big"}, ""},
+		{"invalid pattern", HeaderRule{Header: "X-Tenant", Pattern: `(unclosed`, Provider: "main", Model: "big"}, "invalid pattern"},
+		{"missing header", HeaderRule{Value: "premium", Provider: "main", Model: "big"}, "header is required"},
+		{"missing model", HeaderRule{Header: "X-Tenant", Value: "premium", Provider: "main"}, "provider and model are required"},
+		{"unknown provider", HeaderRule{Header: "X-Tenant", Value: "premium", Provider: "missing", Model: "big"}, "unknown provider"},
+		{"value and pattern", HeaderRule{Header: "X-Tenant", Value: "premium", Pattern: `^prem`, Provider: "main", Model: "big"}, "exactly one of value or pattern"},
+		{"neither value nor pattern", HeaderRule{Header: "X-Tenant", Provider: "main", Model: "big"}, "exactly one of value or pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:        3456,
+				Providers:   []Provider{{Name: "main", APIBaseURL: "https://api.example.com", Models: []string{"big"}, Enabled: true}},
+				HeaderRules: []HeaderRule{tt.rule},
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				if !cfg.HeaderRules[0].Compiled() || !cfg.HeaderRules[0].Match("premium") {
+					t.Error("Expected the rule to match after validation")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateContentRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    ContentRule
+		wantErr string
+	}{
+		{"valid rule", ContentRule{Pattern: `(?i)translate`, Provider: "main", Model: "big"}, ""},
+		{"invalid pattern", ContentRule{Pattern: `(unclosed`, Provider: "main", Model: "big"}, "invalid pattern"},
+		{"missing model", ContentRule{Pattern: `x`, Provider: "main"}, "provider and model are required"},
+		{"unknown provider", ContentRule{Pattern: `x`, Provider: "missing", Model: "big"}, "unknown provider"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:         3456,
+				Providers:    []Provider{{Name: "main", APIBaseURL: "https://api.example.com", Models: []string{"big"}, Enabled: true}},
+				ContentRules: []ContentRule{tt.rule},
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				if !cfg.ContentRules[0].Compiled() || !cfg.ContentRules[0].Match("Please TRANSLATE") {
+					t.Error("Expected the pattern to be compiled during validation")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateAllowedOrigins(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "wildcard", origins: []string{"*"}},
+		{name: "valid", origins: []string{"http://localhost:3000", "https://app.example.com"}},
+		{name: "missing scheme", origins: []string{"app.example.com"}, wantErr: true},
+		{name: "unsupported scheme", origins: []string{"ftp://app.example.com"}, wantErr: true},
+		{name: "trailing slash", origins: []string{"https://app.example.com/"}, wantErr: true},
+		{name: "path", origins: []string{"https://app.example.com/ui"}, wantErr: true},
+		{name: "empty", origins: []string{""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Port: 3456, Security: SecurityConfig{AllowedOrigins: tt.origins}}
+			err := cfg.Validate()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "allowed_origins") {
+					t.Errorf("Expected allowed_origins error, got: %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidatePathRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   PathAccessConfig
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "valid", paths: PathAccessConfig{
+			Allow: []PathRule{{Prefix: "/v1/messages"}, {Prefix: "/", AllowFrom: []string{"localhost"}}},
+			Deny:  []PathRule{{Prefix: "/admin", AllowFrom: []string{"10.0.0.0/8", "192.168.1.5", "::1"}}},
+		}},
+		{name: "relative prefix", paths: PathAccessConfig{Allow: []PathRule{{Prefix: "v1"}}}, wantErr: true},
+		{name: "empty prefix", paths: PathAccessConfig{Deny: []PathRule{{}}}, wantErr: true},
+		{name: "invalid source", paths: PathAccessConfig{
+			Deny: []PathRule{{Prefix: "/admin", AllowFrom: []string{"intranet"}}},
+		}, wantErr: true},
+		{name: "invalid CIDR", paths: PathAccessConfig{
+			Allow: []PathRule{{Prefix: "/admin", AllowFrom: []string{"10.0.0.0/33"}}},
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Port: 3456, Security: SecurityConfig{Paths: tt.paths}}
+			err := cfg.Validate()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "paths.") {
+					t.Errorf("Expected paths error, got: %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateRouteTargets(t *testing.T) {
+	providerNames := map[string]bool{"openai": true, "groq": true}
+	targets := []RouteTarget{{Provider: "groq", Model: "llama-3.3-70b"}}
+
+	tests := []struct {
+		name    string
+		route   Route
+		wantErr string
+	}{
+		{name: "single target", route: Route{Provider: "openai", Model: "gpt-4o"}},
+		{name: "latency-aware", route: Route{Provider: "openai", Model: "gpt-4o", Targets: targets, Strategy: RouteStrategyLatencyAware, Exploration: 0.1}},
+		{name: "targets without strategy", route: Route{Provider: "openai", Model: "gpt-4o", Targets: targets}, wantErr: "targets require a strategy"},
+		{name: "unknown strategy", route: Route{Provider: "openai", Model: "gpt-4o", Targets: targets, Strategy: "round-robin"}, wantErr: "unknown strategy"},
+		{name: "one candidate", route: Route{Provider: "openai", Model: "gpt-4o", Strategy: RouteStrategyLatencyAware}, wantErr: "at least two candidates"},
+		{name: "target without model", route: Route{Provider: "openai", Model: "gpt-4o", Targets: []RouteTarget{{Provider: "groq"}}, Strategy: RouteStrategyLatencyAware}, wantErr: "provider and model are required"},
+		{name: "unknown target provider", route: Route{Provider: "openai", Model: "gpt-4o", Targets: []RouteTarget{{Provider: "mistral", Model: "m"}}, Strategy: RouteStrategyLatencyAware}, wantErr: "unknown provider: mistral"},
+		{name: "exploration above one", route: Route{Provider: "openai", Model: "gpt-4o", Targets: targets, Strategy: RouteStrategyLatencyAware, Exploration: 1.5}, wantErr: "between 0 and 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRouteTargets(tt.route, providerNames)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateShadow(t *testing.T) {
+	providerNames := map[string]bool{"openai": true}
+
+	tests := []struct {
+		name    string
+		shadow  ShadowTarget
+		wantErr string
+	}{
+		{name: "valid", shadow: ShadowTarget{Provider: "openai", Model: "gpt-4o", Percentage: 5, Timeout: 10 * time.Second}},
+		{name: "all traffic", shadow: ShadowTarget{Provider: "openai", Model: "gpt-4o", Percentage: 100}},
+		{name: "missing model", shadow: ShadowTarget{Provider: "openai", Percentage: 5}, wantErr: "provider and model are required"},
+		{name: "unknown provider", shadow: ShadowTarget{Provider: "groq", Model: "m", Percentage: 5}, wantErr: "unknown provider: groq"},
+		{name: "zero percentage", shadow: ShadowTarget{Provider: "openai", Model: "gpt-4o"}, wantErr: "percentage"},
+		{name: "percentage above 100", shadow: ShadowTarget{Provider: "openai", Model: "gpt-4o", Percentage: 150}, wantErr: "percentage"},
+		{name: "negative timeout", shadow: ShadowTarget{Provider: "openai", Model: "gpt-4o", Percentage: 5, Timeout: -time.Second}, wantErr: "timeout cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateShadow(tt.shadow, providerNames)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
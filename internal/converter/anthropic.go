@@ -198,6 +198,10 @@ func (ac *AnthropicConverter) FromGeneric(data json.RawMessage, isRequest bool)
 		Model:   resp.Model,
 	}
 
+	if anthropicResp.Type == "" {
+		anthropicResp.Type = "message"
+	}
+
 	if resp.Usage != nil {
 		anthropicResp.Usage = &AnthropicUsage{
 			InputTokens:  resp.Usage.InputTokens,
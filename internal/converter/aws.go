@@ -153,6 +153,10 @@ func (ac *AWSConverter) FromGeneric(data json.RawMessage, isRequest bool) (json.
 		StopReason: "stop_sequence",
 	}
 
+	if awsResp.Type == "" {
+		awsResp.Type = "message"
+	}
+
 	if resp.Usage != nil {
 		awsResp.Usage = &AWSUsage{
 			InputTokens:  resp.Usage.InputTokens,
@@ -150,3 +150,77 @@ func TestBasicStructs(t *testing.T) {
 		testutil.AssertEqual(t, "message_start", event.Event)
 	})
 }
+
+func TestMessageConverter_ResponseIdentityFields(t *testing.T) {
+	converter := NewMessageConverter()
+
+	t.Run("OpenAIResponseWithoutIdentity", func(t *testing.T) {
+		// Anthropic-style response from a provider that omitted the id
+		data := json.RawMessage(`{
+			"type": "message",
+			"role": "assistant",
+			"content": [{"type": "text", "text": "Hello"}],
+			"model": "claude-3-sonnet"
+		}`)
+
+		result, err := converter.ConvertResponse(data, FormatAnthropic, FormatOpenAI)
+		testutil.AssertNoError(t, err)
+
+		var resp OpenAIResponse
+		testutil.AssertNoError(t, json.Unmarshal(result, &resp))
+		testutil.AssertEqual(t, "chat.completion", resp.Object)
+		testutil.AssertTrue(t, resp.Created > 0)
+	})
+
+	t.Run("OpenAIResponsePreservesIdentity", func(t *testing.T) {
+		data := json.RawMessage(`{
+			"id": "msg_abc",
+			"type": "message",
+			"role": "assistant",
+			"content": [{"type": "text", "text": "Hello"}],
+			"model": "claude-3-sonnet"
+		}`)
+
+		result, err := converter.ConvertResponse(data, FormatAnthropic, FormatOpenAI)
+		testutil.AssertNoError(t, err)
+
+		var resp OpenAIResponse
+		testutil.AssertNoError(t, json.Unmarshal(result, &resp))
+		testutil.AssertEqual(t, "msg_abc", resp.ID)
+	})
+
+	t.Run("OpenAIToOpenAIKeepsCreated", func(t *testing.T) {
+		data := json.RawMessage(`{
+			"id": "chatcmpl-1",
+			"object": "chat.completion",
+			"created": 1700000000,
+			"model": "gpt-4",
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": "Hi"}, "finish_reason": "stop"}]
+		}`)
+
+		generic, err := converter.ConvertResponse(data, FormatOpenAI, FormatGeneric)
+		testutil.AssertNoError(t, err)
+
+		result, err := converter.converters[string(FormatOpenAI)].FromGeneric(generic, false)
+		testutil.AssertNoError(t, err)
+
+		var resp OpenAIResponse
+		testutil.AssertNoError(t, json.Unmarshal(result, &resp))
+		testutil.AssertEqual(t, int64(1700000000), resp.Created)
+	})
+
+	t.Run("AnthropicResponseWithoutIdentity", func(t *testing.T) {
+		// OpenAI-style response from a provider that omitted id, object and created
+		data := json.RawMessage(`{
+			"model": "llama-3",
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": "Hi"}, "finish_reason": "stop"}]
+		}`)
+
+		result, err := converter.ConvertResponse(data, FormatOpenAI, FormatAnthropic)
+		testutil.AssertNoError(t, err)
+
+		var resp AnthropicResponse
+		testutil.AssertNoError(t, json.Unmarshal(result, &resp))
+		testutil.AssertEqual(t, "message", resp.Type)
+	})
+}
@@ -0,0 +1,11 @@
+package converter
+
+import "time"
+
+// responseCreated returns created when set, otherwise the current Unix time
+func responseCreated(created int64) int64 {
+	if created > 0 {
+		return created
+	}
+	return time.Now().Unix()
+}
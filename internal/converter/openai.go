@@ -131,6 +131,7 @@ func (oc *OpenAIConverter) ToGeneric(data json.RawMessage, isRequest bool) (json
 		Role:    choice.Message.Role,
 		Content: content,
 		Model:   resp.Model,
+		Created: resp.Created,
 	}
 
 	if resp.Usage != nil {
@@ -246,9 +247,10 @@ func (oc *OpenAIConverter) FromGeneric(data json.RawMessage, isRequest bool) (js
 
 	// Create OpenAI response
 	openAIResp := OpenAIResponse{
-		ID:     resp.ID,
-		Object: "chat.completion",
-		Model:  resp.Model,
+		ID:      resp.ID,
+		Object:  "chat.completion",
+		Created: responseCreated(resp.Created),
+		Model:   resp.Model,
 		Choices: []OpenAIChoice{
 			{
 				Index: 0,
@@ -45,6 +45,7 @@ type Response struct {
 	Role    string          `json:"role"`
 	Content json.RawMessage `json:"content"`
 	Model   string          `json:"model"`
+	Created int64           `json:"created,omitempty"`
 	Usage   *Usage          `json:"usage,omitempty"`
 }
 
@@ -107,6 +107,33 @@ func (cb *CircuitBreaker) GetStats() map[string]interface{} {
 	}
 }
 
+// GetStatus returns the breaker state, failure counts and, when open, the
+// time remaining until it moves to half-open
+func (cb *CircuitBreaker) GetStatus() CircuitBreakerStatus {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	status := CircuitBreakerStatus{
+		State:               cb.stateString(),
+		Failures:            cb.failures,
+		Successes:           cb.successes,
+		ConsecutiveFailures: cb.consecutiveFails,
+	}
+
+	if total := cb.failures + cb.successes; total > 0 {
+		status.ErrorRate = float64(cb.failures) / float64(total)
+	}
+
+	if cb.state == StateOpen {
+		remaining := cb.config.OpenDuration - time.Since(cb.lastStateChange)
+		if remaining > 0 {
+			status.TimeUntilHalfOpenMs = remaining.Milliseconds()
+		}
+	}
+
+	return status
+}
+
 // Reset resets the circuit breaker
 func (cb *CircuitBreaker) Reset() {
 	cb.mu.Lock()
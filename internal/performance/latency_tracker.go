@@ -11,7 +11,9 @@ import (
 // LatencyTracker tracks request latencies and calculates percentiles
 type LatencyTracker struct {
 	samples    []time.Duration
+	times      []time.Time // When each sample was recorded
 	maxSamples int
+	window     time.Duration // Age after which samples are ignored, 0 keeps all
 	mu         sync.RWMutex
 }
 
@@ -33,19 +35,59 @@ func NewLatencyTracker() *LatencyTracker {
 	}
 }
 
+// NewWindowedLatencyTracker creates a latency tracker that only reports
+// samples recorded within the window
+func NewWindowedLatencyTracker(maxSamples int, window time.Duration) *LatencyTracker {
+	return &LatencyTracker{
+		samples:    make([]time.Duration, 0, maxSamples),
+		maxSamples: maxSamples,
+		window:     window,
+	}
+}
+
 // Record records a latency sample
 func (lt *LatencyTracker) Record(latency time.Duration) {
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 
+	now := time.Now()
 	lt.samples = append(lt.samples, latency)
+	lt.times = append(lt.times, now)
+
+	// Drop samples that have aged out of the window
+	drop := 0
+	if lt.window > 0 {
+		cutoff := now.Add(-lt.window)
+		for drop < len(lt.times) && lt.times[drop].Before(cutoff) {
+			drop++
+		}
+	}
 
 	// Keep only the last maxSamples
-	if len(lt.samples) > lt.maxSamples {
+	if len(lt.samples)-drop > lt.maxSamples {
+		drop = len(lt.samples) - lt.maxSamples
+	}
+
+	if drop > 0 {
 		// Remove oldest samples
-		copy(lt.samples, lt.samples[len(lt.samples)-lt.maxSamples:])
-		lt.samples = lt.samples[:lt.maxSamples]
+		n := copy(lt.samples, lt.samples[drop:])
+		lt.samples = lt.samples[:n]
+		copy(lt.times, lt.times[drop:])
+		lt.times = lt.times[:n]
+	}
+}
+
+// recentSamples returns the samples within the window. Callers must hold mu.
+func (lt *LatencyTracker) recentSamples() []time.Duration {
+	if lt.window <= 0 {
+		return lt.samples
 	}
+
+	cutoff := time.Now().Add(-lt.window)
+	start := sort.Search(len(lt.times), func(i int) bool {
+		return !lt.times[i].Before(cutoff)
+	})
+	return lt.samples[start:]
 }
 
 // GetPercentiles calculates and returns latency percentiles
@@ -53,13 +95,14 @@ func (lt *LatencyTracker) GetPercentiles() LatencyPercentiles {
 	lt.mu.RLock()
 	defer lt.mu.RUnlock()
 
-	if len(lt.samples) == 0 {
+	recent := lt.recentSamples()
+	if len(recent) == 0 {
 		return LatencyPercentiles{}
 	}
 
 	// Copy samples for sorting
-	samples := make([]time.Duration, len(lt.samples))
-	copy(samples, lt.samples)
+	samples := make([]time.Duration, len(recent))
+	copy(samples, recent)
 
 	// Sort samples
 	sort.Slice(samples, func(i, j int) bool {
@@ -92,6 +135,7 @@ func (lt *LatencyTracker) Reset() {
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 	lt.samples = lt.samples[:0]
+	lt.times = lt.times[:0]
 }
 
 // getPercentile calculates a specific percentile from sorted samples
@@ -127,7 +171,7 @@ func getPercentile(sortedSamples []time.Duration, percentile float64) time.Durat
 func (lt *LatencyTracker) GetSampleCount() int {
 	lt.mu.RLock()
 	defer lt.mu.RUnlock()
-	return len(lt.samples)
+	return len(lt.recentSamples())
 }
 
 // GetHistogram returns a histogram of latencies
@@ -151,7 +195,7 @@ func (lt *LatencyTracker) GetHistogram(buckets []time.Duration) map[string]int {
 	}
 
 	// Count samples in each bucket
-	for _, sample := range lt.samples {
+	for _, sample := range lt.recentSamples() {
 		placed := false
 		for i, bucket := range buckets {
 			if sample < bucket {
@@ -92,13 +92,6 @@ func Middleware(monitor *Monitor) gin.HandlerFunc {
 		// Start timing
 		startTime := time.Now()
 
-		// Capture response for metrics
-		writer := &responseWriter{
-			ResponseWriter: c.Writer,
-			body:           &bytes.Buffer{},
-		}
-		c.Writer = writer
-
 		// Process request
 		c.Next()
 
@@ -118,10 +111,10 @@ func Middleware(monitor *Monitor) gin.HandlerFunc {
 			StartTime:    startTime,
 			EndTime:      time.Now(),
 			Latency:      latency,
-			Success:      writer.status < 400,
-			StatusCode:   writer.status,
+			Success:      c.Writer.Status() < 400,
+			StatusCode:   c.Writer.Status(),
 			RequestSize:  requestSize,
-			ResponseSize: int64(writer.body.Len()),
+			ResponseSize: int64(c.Writer.Size()),
 		}
 
 		// Extract token counts if available
@@ -166,6 +159,16 @@ func extractProvider(c *gin.Context) string {
 	return ""
 }
 
+// extractUser extracts the end-user identifier from the request body's user
+// field or Anthropic's metadata.user_id
+func extractUser(c *gin.Context) string {
+	fields := requestFields(c)
+	if fields.User != "" {
+		return fields.User
+	}
+	return fields.Metadata.UserID
+}
+
 // extractModel extracts the model from request
 func extractModel(c *gin.Context) string {
 	// Check context first
@@ -175,29 +178,65 @@ func extractModel(c *gin.Context) string {
 		}
 	}
 
-	// Try to get from request body
-	var body map[string]interface{}
-	if c.Request.Body != nil {
-		bodyBytes, err := io.ReadAll(c.Request.Body)
-		if err == nil {
-			// Restore body
-			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-
-			// Try to parse
-			if err := json.Unmarshal(bodyBytes, &body); err == nil {
-				if model, ok := body["model"].(string); ok {
-					return model
-				}
-			}
+	return requestFields(c).Model
+}
+
+// bodyFieldsKey caches the parsed request fields on the context
+const bodyFieldsKey = "performance_body_fields"
+
+// bodyFields are the request body fields the middleware reads
+type bodyFields struct {
+	Model    string `json:"model"`
+	User     string `json:"user"`
+	Metadata struct {
+		UserID string `json:"user_id"`
+	} `json:"metadata"`
+}
+
+// requestFields parses the request body once per request. The body is put
+// back for downstream handlers even when reading it fails, in which case they
+// see what was read followed by the same error.
+func requestFields(c *gin.Context) *bodyFields {
+	if cached, exists := c.Get(bodyFieldsKey); exists {
+		if fields, ok := cached.(*bodyFields); ok {
+			return fields
 		}
 	}
 
-	return ""
+	fields := &bodyFields{}
+	c.Set(bodyFieldsKey, fields)
+	body := c.Request.Body
+	if body == nil {
+		return fields
+	}
+
+	bodyBytes, err := io.ReadAll(body)
+	if err != nil {
+		c.Request.Body = restoredBody{Reader: io.MultiReader(bytes.NewReader(bodyBytes), body), Closer: body}
+		return fields
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+	_ = json.Unmarshal(bodyBytes, fields) // Bodies that are not JSON have no fields
+	return fields
+}
+
+// restoredBody replays the bytes read before a failed read, then the rest of
+// the original body
+type restoredBody struct {
+	io.Reader
+	io.Closer
 }
 
 // getRateLimitKey determines the rate limit key based on configuration
 func getRateLimitKey(c *gin.Context, config RateLimitConfig) string {
-	if config.PerAPIKey {
+	if config.PerUser {
+		if user := extractUser(c); user != "" {
+			return fmt.Sprintf("user:%x", sha256.Sum256([]byte(user)))
+		}
+	}
+
+	if config.PerAPIKey || config.PerUser {
 		// Extract API key from Authorization header
 		auth := c.GetHeader("Authorization")
 		if auth != "" {
@@ -217,23 +256,6 @@ func getRateLimitKey(c *gin.Context, config RateLimitConfig) string {
 	return fmt.Sprintf("ip:%s", c.ClientIP())
 }
 
-// responseWriter captures response data for metrics
-type responseWriter struct {
-	gin.ResponseWriter
-	body   *bytes.Buffer
-	status int
-}
-
-func (w *responseWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
-	return w.ResponseWriter.Write(b)
-}
-
-func (w *responseWriter) WriteHeader(statusCode int) {
-	w.status = statusCode
-	w.ResponseWriter.WriteHeader(statusCode)
-}
-
 // GetPerformanceHandler returns a handler for performance metrics endpoint
 func GetPerformanceHandler(monitor *Monitor) gin.HandlerFunc {
 	return func(c *gin.Context) {
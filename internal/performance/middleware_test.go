@@ -0,0 +1,77 @@
+package performance
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingReader returns data, then err
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func newBodyContext(body io.Reader) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/messages", body)
+	return c
+}
+
+func TestExtractUser(t *testing.T) {
+	t.Run("ParsesOnce", func(t *testing.T) {
+		body := `{"model":"openai,gpt-4o","user":"alice"}`
+		c := newBodyContext(strings.NewReader(body))
+
+		if user := extractUser(c); user != "alice" {
+			t.Errorf("Expected user alice, got %q", user)
+		}
+		restored, _ := io.ReadAll(c.Request.Body)
+		if string(restored) != body {
+			t.Errorf("Expected the body to be restored, got %q", restored)
+		}
+
+		// Later lookups use the fields parsed the first time
+		c.Request.Body = io.NopCloser(strings.NewReader(`{"model":"groq,llama","user":"bob"}`))
+		if user := extractUser(c); user != "alice" {
+			t.Errorf("Expected the cached user alice, got %q", user)
+		}
+		if provider := extractProvider(c); provider != "openai" {
+			t.Errorf("Expected the cached provider openai, got %q", provider)
+		}
+	})
+
+	t.Run("MetadataUserID", func(t *testing.T) {
+		c := newBodyContext(strings.NewReader(`{"metadata":{"user_id":"user-123"}}`))
+		if user := extractUser(c); user != "user-123" {
+			t.Errorf("Expected user user-123, got %q", user)
+		}
+	})
+
+	t.Run("RestoresBodyAfterReadError", func(t *testing.T) {
+		readErr := errors.New("connection reset")
+		c := newBodyContext(&failingReader{data: `{"user":"al`, err: readErr})
+
+		if user := extractUser(c); user != "" {
+			t.Errorf("Expected no user from an unreadable body, got %q", user)
+		}
+		restored, err := io.ReadAll(c.Request.Body)
+		if string(restored) != `{"user":"al` || !errors.Is(err, readErr) {
+			t.Errorf("Expected the partial body and the read error, got %q, %v", restored, err)
+		}
+	})
+}
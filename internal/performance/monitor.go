@@ -10,6 +10,42 @@ import (
 	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
 
+const (
+	// DefaultLatencyWindow is how long samples count towards provider latency stats
+	DefaultLatencyWindow = 15 * time.Minute
+
+	// providerLatencySamples caps the samples kept per provider
+	providerLatencySamples = 2000
+
+	// interTokenSamples caps the gaps between tokens kept per provider. A
+	// single stream contributes many, so more are kept than for requests.
+	interTokenSamples = 20000
+)
+
+// latencyHistogramBuckets are the bucket bounds of provider latency histograms
+var latencyHistogramBuckets = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// interTokenHistogramBuckets are the bucket bounds of inter-token latency
+// histograms
+var interTokenHistogramBuckets = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
 // Monitor tracks performance metrics and enforces resource limits
 type Monitor struct {
 	config          *PerformanceConfig
@@ -18,6 +54,9 @@ type Monitor struct {
 	resourceMonitor *ResourceMonitor
 	rateLimiter     *RateLimiter
 	circuitBreakers map[string]*CircuitBreaker
+	providerLatency map[string]*LatencyTracker
+	firstToken      map[string]*LatencyTracker // Time to first token per provider
+	interToken      map[string]*LatencyTracker // Gaps between tokens per provider
 
 	requestCount int64
 	successCount int64
@@ -47,6 +86,9 @@ func NewMonitor(config *PerformanceConfig) *Monitor {
 		latencyTracker:  NewLatencyTracker(),
 		resourceMonitor: NewResourceMonitor(config.ResourceLimits),
 		circuitBreakers: make(map[string]*CircuitBreaker),
+		providerLatency: make(map[string]*LatencyTracker),
+		firstToken:      make(map[string]*LatencyTracker),
+		interToken:      make(map[string]*LatencyTracker),
 		startTime:       time.Now(),
 		ctx:             ctx,
 		cancel:          cancel,
@@ -138,6 +180,18 @@ func (m *Monitor) RecordProviderError(provider string, success bool) {
 	cb.Record(success)
 }
 
+// GetCircuitBreakerStatuses returns the status of each provider's circuit breaker
+func (m *Monitor) GetCircuitBreakerStatuses() map[string]CircuitBreakerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make(map[string]CircuitBreakerStatus, len(m.circuitBreakers))
+	for provider, cb := range m.circuitBreakers {
+		statuses[provider] = cb.GetStatus()
+	}
+	return statuses
+}
+
 // GetMetrics returns current performance metrics
 func (m *Monitor) GetMetrics() *Metrics {
 	m.mu.RLock()
@@ -190,6 +244,97 @@ func (m *Monitor) GetMetrics() *Metrics {
 	return metrics
 }
 
+// GetProviderLatencies returns latency percentiles, request counts and a
+// histogram per provider, covering requests within the latency window
+func (m *Monitor) GetProviderLatencies() map[string]ProviderLatencyStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make(map[string]ProviderLatencyStats, len(m.providerLatency))
+	for provider, tracker := range m.providerLatency {
+		requests := tracker.GetSampleCount()
+		if requests == 0 {
+			continue
+		}
+
+		percentiles := tracker.GetPercentiles()
+		stats[provider] = ProviderLatencyStats{
+			Requests:  requests,
+			P50Ms:     durationMs(percentiles.P50),
+			P95Ms:     durationMs(percentiles.P95),
+			P99Ms:     durationMs(percentiles.P99),
+			Histogram: tracker.GetHistogram(latencyHistogramBuckets),
+		}
+	}
+	return stats
+}
+
+// RecordStreamLatency records when a stream from provider delivered its first
+// token, measured from the start of the request, and the gaps between the
+// tokens that followed
+func (m *Monitor) RecordStreamLatency(provider string, firstToken time.Duration, interToken []time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.windowedTracker(m.firstToken, provider, providerLatencySamples).Record(firstToken)
+	tracker := m.windowedTracker(m.interToken, provider, interTokenSamples)
+	for _, gap := range interToken {
+		tracker.Record(gap)
+	}
+}
+
+// windowedTracker returns the provider's tracker from trackers, creating it
+// on first use. The caller must hold m.mu.
+func (m *Monitor) windowedTracker(trackers map[string]*LatencyTracker, provider string, maxSamples int) *LatencyTracker {
+	tracker, exists := trackers[provider]
+	if !exists {
+		window := m.config.LatencyWindow
+		if window <= 0 {
+			window = DefaultLatencyWindow
+		}
+		tracker = NewWindowedLatencyTracker(maxSamples, window)
+		trackers[provider] = tracker
+	}
+	return tracker
+}
+
+// GetStreamLatencies returns time to first token and inter-token latency
+// percentiles per provider, covering streams within the latency window
+func (m *Monitor) GetStreamLatencies() map[string]StreamLatencyStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make(map[string]StreamLatencyStats, len(m.firstToken))
+	for provider, tracker := range m.firstToken {
+		streams := tracker.GetSampleCount()
+		if streams == 0 {
+			continue
+		}
+
+		firstToken := tracker.GetPercentiles()
+		providerStats := StreamLatencyStats{
+			Streams:         streams,
+			FirstTokenP50Ms: durationMs(firstToken.P50),
+			FirstTokenP95Ms: durationMs(firstToken.P95),
+			FirstTokenP99Ms: durationMs(firstToken.P99),
+		}
+		if gaps, ok := m.interToken[provider]; ok && gaps.GetSampleCount() > 0 {
+			interToken := gaps.GetPercentiles()
+			providerStats.InterTokenP50Ms = durationMs(interToken.P50)
+			providerStats.InterTokenP95Ms = durationMs(interToken.P95)
+			providerStats.InterTokenP99Ms = durationMs(interToken.P99)
+			providerStats.InterTokenHistogram = gaps.GetHistogram(interTokenHistogramBuckets)
+		}
+		stats[provider] = providerStats
+	}
+	return stats
+}
+
+// durationMs converts a duration to milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
 // CheckResourceLimits checks if resource limits are exceeded
 func (m *Monitor) CheckResourceLimits() error {
 	if m.resourceMonitor != nil {
@@ -273,6 +418,9 @@ func (m *Monitor) updateProviderMetrics(req RequestMetrics) {
 		)
 	}
 
+	// Record latency for the provider's recent percentiles
+	m.windowedTracker(m.providerLatency, req.Provider, providerLatencySamples).Record(req.Latency)
+
 	// Update tokens
 	pm.TokensProcessed += int64(req.TokensIn + req.TokensOut)
 
@@ -297,6 +445,9 @@ func (m *Monitor) ResetMetrics() {
 	}
 
 	m.latencyTracker.Reset()
+	m.providerLatency = make(map[string]*LatencyTracker)
+	m.firstToken = make(map[string]*LatencyTracker)
+	m.interToken = make(map[string]*LatencyTracker)
 	m.startTime = time.Now()
 
 	utils.GetLogger().Info("Performance metrics reset")
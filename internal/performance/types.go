@@ -43,6 +43,28 @@ type ProviderMetrics struct {
 	HealthStatus       string        `json:"health_status"`
 }
 
+// ProviderLatencyStats summarizes a provider's recent request latencies
+type ProviderLatencyStats struct {
+	Requests  int            `json:"requests"`
+	P50Ms     float64        `json:"p50_ms"`
+	P95Ms     float64        `json:"p95_ms"`
+	P99Ms     float64        `json:"p99_ms"`
+	Histogram map[string]int `json:"histogram"`
+}
+
+// StreamLatencyStats summarizes how quickly a provider's recent streams
+// delivered their first token and the tokens after it
+type StreamLatencyStats struct {
+	Streams             int            `json:"streams"`
+	FirstTokenP50Ms     float64        `json:"first_token_p50_ms"`
+	FirstTokenP95Ms     float64        `json:"first_token_p95_ms"`
+	FirstTokenP99Ms     float64        `json:"first_token_p99_ms"`
+	InterTokenP50Ms     float64        `json:"inter_token_p50_ms"`
+	InterTokenP95Ms     float64        `json:"inter_token_p95_ms"`
+	InterTokenP99Ms     float64        `json:"inter_token_p99_ms"`
+	InterTokenHistogram map[string]int `json:"inter_token_histogram,omitempty"`
+}
+
 // ResourceLimits defines resource limits for the proxy
 type ResourceLimits struct {
 	MaxMemoryMB       uint64        `json:"max_memory_mb"`
@@ -60,6 +82,7 @@ type RateLimitConfig struct {
 	BurstSize       int           `json:"burst_size"`
 	PerProvider     bool          `json:"per_provider"`
 	PerAPIKey       bool          `json:"per_api_key"`
+	PerUser         bool          `json:"per_user"` // Key by the body's user or metadata.user_id, falling back to API key then IP
 	CleanupInterval time.Duration `json:"cleanup_interval"`
 }
 
@@ -72,6 +95,16 @@ type CircuitBreakerConfig struct {
 	HalfOpenMaxRequests int           `json:"half_open_max_requests"`
 }
 
+// CircuitBreakerStatus is a point-in-time view of a circuit breaker
+type CircuitBreakerStatus struct {
+	State               string  `json:"state"`
+	Failures            int64   `json:"failures"`
+	Successes           int64   `json:"successes"`
+	ConsecutiveFailures int64   `json:"consecutive_failures"`
+	TimeUntilHalfOpenMs int64   `json:"time_until_half_open_ms"`
+	ErrorRate           float64 `json:"error_rate"`
+}
+
 // PerformanceConfig combines all performance-related configurations
 type PerformanceConfig struct {
 	ResourceLimits  ResourceLimits       `json:"resource_limits"`
@@ -80,6 +113,7 @@ type PerformanceConfig struct {
 	MetricsEnabled  bool                 `json:"metrics_enabled"`
 	MetricsInterval time.Duration        `json:"metrics_interval"`
 	ProfilerEnabled bool                 `json:"profiler_enabled"`
+	LatencyWindow   time.Duration        `json:"latency_window"` // Age of samples in per-provider latency stats
 }
 
 // DefaultPerformanceConfig returns default performance configuration
@@ -111,6 +145,7 @@ func DefaultPerformanceConfig() *PerformanceConfig {
 		MetricsEnabled:  true,
 		MetricsInterval: 1 * time.Minute,
 		ProfilerEnabled: false,
+		LatencyWindow:   DefaultLatencyWindow,
 	}
 }
 
@@ -0,0 +1,433 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+const (
+	// defaultBudgetWindow is the rolling window when budget.window is unset
+	defaultBudgetWindow = 24 * time.Hour
+
+	// budgetBuckets is the number of buckets a window is split into. Usage
+	// expires one bucket at a time, so the window rolls in steps of 1/60.
+	budgetBuckets = 60
+
+	// budgetSaveInterval bounds how often usage is written to the state file
+	budgetSaveInterval = 10 * time.Second
+)
+
+// usageBucket is the usage recorded during one slice of the budget window
+type usageBucket struct {
+	Start  time.Time `json:"start"`
+	Tokens int64     `json:"tokens"`
+	Cost   float64   `json:"cost"`
+}
+
+// budgetState is the content of the budget state file
+type budgetState struct {
+	Usage map[string][]usageBucket `json:"usage"` // Buckets keyed by provider name, oldest first
+}
+
+// budgetTracker counts token usage and spend per provider over a rolling
+// window and rejects requests to providers, or to all providers, once their
+// budget is used up
+type budgetTracker struct {
+	mu          sync.Mutex
+	window      time.Duration
+	bucketWidth time.Duration
+	maxTokens   int64
+	maxCost     float64
+	providers   map[string]config.ProviderBudget
+	usage       map[string][]usageBucket
+	rejected    map[string]int64
+
+	stateFile string
+	lastSave  time.Time
+	dirty     bool
+
+	now func() time.Time
+}
+
+// BudgetStats reports budget usage for /status
+type BudgetStats struct {
+	Window    string                         `json:"window"`
+	Tokens    int64                          `json:"tokens"`
+	Cost      float64                        `json:"cost"`
+	MaxTokens int64                          `json:"max_tokens,omitempty"`
+	MaxCost   float64                        `json:"max_cost,omitempty"`
+	Exceeded  bool                           `json:"exceeded"`
+	Providers map[string]ProviderBudgetStats `json:"providers"`
+}
+
+// ProviderBudgetStats reports one provider's budget usage
+type ProviderBudgetStats struct {
+	Tokens    int64   `json:"tokens"`
+	Cost      float64 `json:"cost"`
+	MaxTokens int64   `json:"max_tokens,omitempty"`
+	MaxCost   float64 `json:"max_cost,omitempty"`
+	Exceeded  bool    `json:"exceeded"`
+	Fallback  string  `json:"fallback,omitempty"`
+	Rejected  int64   `json:"rejected"` // Requests rejected or redirected since startup
+}
+
+// newBudgetTracker creates a tracker for the configured budgets, or returns
+// nil when neither a global nor a provider budget is set. Usage saved in the
+// state file is loaded back.
+func newBudgetTracker(cfg *config.Config) *budgetTracker {
+	providers := make(map[string]config.ProviderBudget)
+	for _, provider := range cfg.Providers {
+		if provider.Budget != nil && (provider.Budget.MaxTokens > 0 || provider.Budget.MaxCost > 0) {
+			providers[provider.Name] = *provider.Budget
+		}
+	}
+	if len(providers) == 0 && cfg.Budget.MaxTokens <= 0 && cfg.Budget.MaxCost <= 0 {
+		return nil
+	}
+
+	window := cfg.Budget.Window
+	if window <= 0 {
+		window = defaultBudgetWindow
+	}
+	b := &budgetTracker{
+		window:      window,
+		bucketWidth: window / budgetBuckets,
+		maxTokens:   cfg.Budget.MaxTokens,
+		maxCost:     cfg.Budget.MaxCost,
+		providers:   providers,
+		usage:       make(map[string][]usageBucket),
+		rejected:    make(map[string]int64),
+		stateFile:   cfg.Budget.StateFile,
+		now:         time.Now,
+	}
+	if b.bucketWidth <= 0 {
+		b.bucketWidth = window
+	}
+
+	if err := b.load(); err != nil {
+		utils.GetLogger().Warnf("Failed to load budget state, starting from zero: %v", err)
+	}
+	return b
+}
+
+// record adds a request's tokens and cost to provider's usage
+func (b *budgetTracker) record(provider string, tokens int64, cost float64) {
+	if b == nil || (tokens <= 0 && cost <= 0) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	start := now.Truncate(b.bucketWidth)
+	buckets := b.prune(b.usage[provider], now)
+	if n := len(buckets); n > 0 && buckets[n-1].Start.Equal(start) {
+		buckets[n-1].Tokens += tokens
+		buckets[n-1].Cost += cost
+	} else {
+		buckets = append(buckets, usageBucket{Start: start, Tokens: tokens, Cost: cost})
+	}
+	b.usage[provider] = buckets
+	b.dirty = true
+
+	if b.stateFile != "" && now.Sub(b.lastSave) >= budgetSaveInterval {
+		if err := b.saveLocked(); err != nil {
+			utils.GetLogger().Warnf("Failed to save budget state: %v", err)
+		}
+	}
+}
+
+// check returns an error when the global budget or provider's budget is used
+// up. exhausted reports whether it was provider's own budget.
+func (b *budgetTracker) check(provider string) (exhausted bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if reason := b.globalExceeded(now); reason != "" {
+		b.rejected[provider]++
+		return false, b.exceededError(reason, b.retryAfter(now, ""))
+	}
+	if reason := b.providerExceeded(provider, now); reason != "" {
+		b.rejected[provider]++
+		return true, b.exceededError(reason, b.retryAfter(now, provider))
+	}
+	return false, nil
+}
+
+// fallback returns the provider and model to use once provider's budget is
+// used up, if one is configured
+func (b *budgetTracker) fallback(provider string) (string, string, bool) {
+	parts := strings.SplitN(b.providers[provider].Fallback, ",", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// globalExceeded describes why the global budget is used up, or returns ""
+func (b *budgetTracker) globalExceeded(now time.Time) string {
+	if b.maxTokens <= 0 && b.maxCost <= 0 {
+		return ""
+	}
+	var tokens int64
+	var cost float64
+	for provider := range b.usage {
+		t, c := b.totals(provider, now)
+		tokens += t
+		cost += c
+	}
+	return b.exceededReason("the global budget", tokens, cost, b.maxTokens, b.maxCost)
+}
+
+// providerExceeded describes why provider's budget is used up, or returns ""
+func (b *budgetTracker) providerExceeded(provider string, now time.Time) string {
+	limit, ok := b.providers[provider]
+	if !ok {
+		return ""
+	}
+	tokens, cost := b.totals(provider, now)
+	return b.exceededReason("provider "+provider, tokens, cost, limit.MaxTokens, limit.MaxCost)
+}
+
+// exceededReason describes which limit was reached, or returns "" when
+// neither was
+func (b *budgetTracker) exceededReason(scope string, tokens int64, cost float64, maxTokens int64, maxCost float64) string {
+	if maxTokens > 0 && tokens >= maxTokens {
+		return fmt.Sprintf("%s has used %d of %d tokens in the last %s", scope, tokens, maxTokens, b.window)
+	}
+	if maxCost > 0 && cost >= maxCost {
+		return fmt.Sprintf("%s has spent $%.4f of $%.4f in the last %s", scope, cost, maxCost, b.window)
+	}
+	return ""
+}
+
+// exceededError builds the 429 returned for a used up budget
+func (b *budgetTracker) exceededError(reason string, retryAfter time.Duration) error {
+	err := ccerrors.New(ccerrors.ErrorTypeRateLimitError, "Budget exceeded: "+reason)
+	return err.WithRetryAfter(retryAfter).WithCode("BUDGET_EXCEEDED")
+}
+
+// retryAfter returns how long until the oldest usage of provider, or of any
+// provider when provider is "", leaves the window
+func (b *budgetTracker) retryAfter(now time.Time, provider string) time.Duration {
+	var oldest time.Time
+	for name, buckets := range b.usage {
+		if provider != "" && name != provider {
+			continue
+		}
+		buckets = b.prune(buckets, now)
+		if len(buckets) > 0 && (oldest.IsZero() || buckets[0].Start.Before(oldest)) {
+			oldest = buckets[0].Start
+		}
+	}
+	if oldest.IsZero() {
+		return b.bucketWidth
+	}
+	wait := oldest.Add(b.bucketWidth + b.window).Sub(now)
+	return time.Duration(math.Ceil(wait.Seconds())) * time.Second
+}
+
+// totals sums provider's usage within the window
+func (b *budgetTracker) totals(provider string, now time.Time) (int64, float64) {
+	var tokens int64
+	var cost float64
+	for _, bucket := range b.prune(b.usage[provider], now) {
+		tokens += bucket.Tokens
+		cost += bucket.Cost
+	}
+	return tokens, cost
+}
+
+// prune drops buckets that have left the window. A bucket counts until its
+// end leaves the window, so limits are never enforced late.
+func (b *budgetTracker) prune(buckets []usageBucket, now time.Time) []usageBucket {
+	cutoff := now.Add(-b.window)
+	i := 0
+	for i < len(buckets) && !buckets[i].Start.Add(b.bucketWidth).After(cutoff) {
+		i++
+	}
+	return buckets[i:]
+}
+
+// stats returns a snapshot of global and per-provider usage
+func (b *budgetTracker) stats() BudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	stats := BudgetStats{
+		Window:    b.window.String(),
+		MaxTokens: b.maxTokens,
+		MaxCost:   b.maxCost,
+		Exceeded:  b.globalExceeded(now) != "",
+		Providers: make(map[string]ProviderBudgetStats),
+	}
+
+	names := make(map[string]bool)
+	for name := range b.usage {
+		names[name] = true
+	}
+	for name := range b.providers {
+		names[name] = true
+	}
+	for name := range names {
+		tokens, cost := b.totals(name, now)
+		stats.Tokens += tokens
+		stats.Cost += cost
+
+		limit := b.providers[name]
+		stats.Providers[name] = ProviderBudgetStats{
+			Tokens:    tokens,
+			Cost:      cost,
+			MaxTokens: limit.MaxTokens,
+			MaxCost:   limit.MaxCost,
+			Exceeded:  b.providerExceeded(name, now) != "",
+			Fallback:  limit.Fallback,
+			Rejected:  b.rejected[name],
+		}
+	}
+	return stats
+}
+
+// load reads usage saved by a previous run. A missing file is not an error.
+func (b *budgetTracker) load() error {
+	if b.stateFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(b.stateFile) // #nosec G304 - path comes from the config
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state budgetState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid budget state file %s: %w", b.stateFile, err)
+	}
+	now := b.now()
+	for provider, buckets := range state.Usage {
+		if buckets = b.prune(buckets, now); len(buckets) > 0 {
+			b.usage[provider] = buckets
+		}
+	}
+	return nil
+}
+
+// save writes current usage to the state file
+func (b *budgetTracker) save() error {
+	if b == nil || b.stateFile == "" {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.saveLocked()
+}
+
+// saveLocked writes usage to the state file if it changed since the last save
+func (b *budgetTracker) saveLocked() error {
+	if !b.dirty {
+		return nil
+	}
+
+	now := b.now()
+	state := budgetState{Usage: make(map[string][]usageBucket, len(b.usage))}
+	for provider, buckets := range b.usage {
+		if buckets = b.prune(buckets, now); len(buckets) > 0 {
+			state.Usage[provider] = buckets
+		}
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.stateFile), 0750); err != nil {
+		return fmt.Errorf("failed to create budget state directory: %w", err)
+	}
+	if err := utils.WriteFileAtomic(b.stateFile, data, 0600); err != nil {
+		return err
+	}
+
+	b.lastSave = now
+	b.dirty = false
+	return nil
+}
+
+// applyBudget checks the budgets for a routing decision, redirecting to the
+// provider's fallback when its own budget is used up and the fallback still
+// has budget left
+func (p *Pipeline) applyBudget(decision router.RouteDecision) (router.RouteDecision, error) {
+	exhausted, err := p.budget.check(decision.Provider)
+	if err == nil || !exhausted {
+		return decision, err
+	}
+
+	provider, model, ok := p.budget.fallback(decision.Provider)
+	if !ok {
+		return decision, err
+	}
+	if _, fallbackErr := p.budget.check(provider); fallbackErr != nil {
+		utils.GetLogger().Warnf("%v; fallback %s is also unavailable: %v", err, provider, fallbackErr)
+		return decision, err
+	}
+
+	utils.GetLogger().Warnf("%v, falling back to provider=%s, model=%s", err, provider, model)
+	decision.Provider = provider
+	decision.Model = model
+	decision.Reason += ", budget fallback"
+	return decision, nil
+}
+
+// recordBudgetUsage counts a completed request against its provider's budget
+func (p *Pipeline) recordBudgetUsage(provider string, tokens int, cost *CostBreakdown) {
+	if p.budget == nil {
+		return
+	}
+	var spent float64
+	if cost != nil {
+		spent = cost.TotalCost
+	}
+	p.budget.record(provider, int64(tokens), spent)
+}
+
+// budgetTokens returns the tokens a request counts against its budget,
+// falling back to the estimated input tokens when no usage was reported
+func budgetTokens(usage tokenUsage, estimated int) int {
+	if tokens := usage.Input + usage.Output; tokens > 0 {
+		return tokens
+	}
+	return estimated
+}
+
+// Budget returns current budget usage, or nil when no budget is configured
+func (p *Pipeline) Budget() *BudgetStats {
+	if p.budget == nil {
+		return nil
+	}
+	stats := p.budget.stats()
+	return &stats
+}
+
+// SaveBudget writes budget usage to the configured state file
+func (p *Pipeline) SaveBudget() error {
+	return p.budget.save()
+}
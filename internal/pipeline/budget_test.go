@@ -0,0 +1,248 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+// newTestBudget creates a budget tracker on a controllable clock
+func newTestBudget(t *testing.T, cfg *config.Config) (*budgetTracker, *time.Time) {
+	t.Helper()
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	budget := newBudgetTracker(cfg)
+	testutil.AssertTrue(t, budget != nil)
+	budget.now = func() time.Time { return now }
+	return budget, &now
+}
+
+// budgetConfig returns a config with the given global budget and provider budgets
+func budgetConfig(global config.BudgetConfig, providers map[string]*config.ProviderBudget) *config.Config {
+	cfg := &config.Config{Budget: global}
+	for name, budget := range providers {
+		cfg.Providers = append(cfg.Providers, config.Provider{Name: name, Budget: budget})
+	}
+	return cfg
+}
+
+func TestBudgetTracker(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		budget := newBudgetTracker(budgetConfig(config.BudgetConfig{}, map[string]*config.ProviderBudget{"openai": {}}))
+		testutil.AssertTrue(t, budget == nil)
+		budget.record("openai", 1000, 1)
+		_, err := budget.check("openai")
+		testutil.AssertNoError(t, err)
+		testutil.AssertNoError(t, budget.save())
+	})
+
+	t.Run("ProviderTokenLimit", func(t *testing.T) {
+		budget, _ := newTestBudget(t, budgetConfig(config.BudgetConfig{}, map[string]*config.ProviderBudget{
+			"openai": {MaxTokens: 1000},
+		}))
+
+		budget.record("openai", 600, 0)
+		_, err := budget.check("openai")
+		testutil.AssertNoError(t, err)
+
+		budget.record("openai", 400, 0)
+		exhausted, err := budget.check("openai")
+		testutil.AssertTrue(t, exhausted)
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "provider openai has used 1000 of 1000 tokens")
+
+		var ccErr *ccerrors.CCProxyError
+		testutil.AssertTrue(t, errors.As(err, &ccErr))
+		testutil.AssertEqual(t, ccerrors.ErrorTypeRateLimitError, ccErr.Type)
+		testutil.AssertEqual(t, 429, ccErr.StatusCode)
+		testutil.AssertTrue(t, ccErr.RetryAfter != nil && *ccErr.RetryAfter > 0)
+
+		// Other providers are unaffected
+		_, err = budget.check("groq")
+		testutil.AssertNoError(t, err)
+
+		stats := budget.stats()
+		testutil.AssertEqual(t, int64(1000), stats.Providers["openai"].Tokens)
+		testutil.AssertTrue(t, stats.Providers["openai"].Exceeded)
+		testutil.AssertEqual(t, int64(1), stats.Providers["openai"].Rejected)
+		testutil.AssertFalse(t, stats.Exceeded)
+	})
+
+	t.Run("GlobalCostLimit", func(t *testing.T) {
+		budget, _ := newTestBudget(t, budgetConfig(config.BudgetConfig{MaxCost: 1}, nil))
+
+		budget.record("openai", 100, 0.6)
+		_, err := budget.check("groq")
+		testutil.AssertNoError(t, err)
+
+		budget.record("groq", 100, 0.5)
+		exhausted, err := budget.check("anthropic")
+		testutil.AssertFalse(t, exhausted)
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "the global budget has spent $1.1000 of $1.0000")
+
+		stats := budget.stats()
+		testutil.AssertEqual(t, int64(200), stats.Tokens)
+		testutil.AssertTrue(t, stats.Exceeded)
+	})
+
+	t.Run("RollingWindow", func(t *testing.T) {
+		budget, now := newTestBudget(t, budgetConfig(config.BudgetConfig{Window: time.Hour}, map[string]*config.ProviderBudget{
+			"openai": {MaxTokens: 1000},
+		}))
+
+		budget.record("openai", 1000, 0)
+		_, err := budget.check("openai")
+		testutil.AssertError(t, err)
+
+		// Still counted just before the usage leaves the window
+		*now = now.Add(time.Hour)
+		_, err = budget.check("openai")
+		testutil.AssertError(t, err)
+
+		*now = now.Add(budget.bucketWidth)
+		_, err = budget.check("openai")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, int64(0), budget.stats().Providers["openai"].Tokens)
+	})
+
+	t.Run("PersistsUsage", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "state", "budget.json")
+		cfg := budgetConfig(config.BudgetConfig{StateFile: stateFile}, map[string]*config.ProviderBudget{
+			"openai": {MaxTokens: 1000},
+		})
+
+		budget := newBudgetTracker(cfg)
+		budget.record("openai", 700, 0.25)
+		testutil.AssertNoError(t, budget.save())
+
+		restored := newBudgetTracker(cfg)
+		stats := restored.stats()
+		testutil.AssertEqual(t, int64(700), stats.Providers["openai"].Tokens)
+		testutil.AssertEqual(t, 0.25, stats.Providers["openai"].Cost)
+	})
+}
+
+func TestPipeline_ApplyBudget(t *testing.T) {
+	decision := router.RouteDecision{Provider: "openai", Model: "gpt-4o", Reason: "default route"}
+
+	t.Run("FallsBack", func(t *testing.T) {
+		budget, _ := newTestBudget(t, budgetConfig(config.BudgetConfig{}, map[string]*config.ProviderBudget{
+			"openai": {MaxTokens: 100, Fallback: "groq,llama-3.3-70b-versatile"},
+		}))
+		p := &Pipeline{budget: budget}
+
+		got, err := p.applyBudget(decision)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "openai", got.Provider)
+
+		budget.record("openai", 100, 0)
+		got, err = p.applyBudget(decision)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "groq", got.Provider)
+		testutil.AssertEqual(t, "llama-3.3-70b-versatile", got.Model)
+		testutil.AssertEqual(t, "default route, budget fallback", got.Reason)
+	})
+
+	t.Run("FallbackExhausted", func(t *testing.T) {
+		budget, _ := newTestBudget(t, budgetConfig(config.BudgetConfig{}, map[string]*config.ProviderBudget{
+			"openai": {MaxTokens: 100, Fallback: "groq,llama-3.3-70b-versatile"},
+			"groq":   {MaxTokens: 100},
+		}))
+		p := &Pipeline{budget: budget}
+
+		budget.record("openai", 100, 0)
+		budget.record("groq", 100, 0)
+		_, err := p.applyBudget(decision)
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "provider openai")
+	})
+
+	t.Run("GlobalBudgetHasNoFallback", func(t *testing.T) {
+		budget, _ := newTestBudget(t, budgetConfig(config.BudgetConfig{MaxTokens: 100}, map[string]*config.ProviderBudget{
+			"openai": {MaxTokens: 1000, Fallback: "groq,llama-3.3-70b-versatile"},
+		}))
+		p := &Pipeline{budget: budget}
+
+		budget.record("openai", 100, 0)
+		_, err := p.applyBudget(decision)
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "the global budget")
+	})
+}
+
+func TestPipeline_StreamingBudgetUsage(t *testing.T) {
+	const stream = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":1000,\"output_tokens\":1,\"cache_read_input_tokens\":500,\"cache_creation_input_tokens\":200}}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":2000}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(stream))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+		Providers: []config.Provider{{
+			Name:       "anthropic",
+			APIBaseURL: upstream.URL,
+			APIKey:     "test-key",
+			Enabled:    true,
+			Pricing:    map[string]config.Pricing{"claude-3-opus": {InputPerMillion: 1000, OutputPerMillion: 1000}},
+			Budget:     &config.ProviderBudget{MaxCost: 3},
+		}},
+		Routes: map[string]config.Route{"default": {Provider: "anthropic", Model: "claude-3-opus"}},
+	}
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	testutil.AssertNoError(t, providerService.Initialize())
+	p := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+
+	request := func() (*ResponseContext, error) {
+		return p.ProcessRequest(context.Background(), &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "claude-3-opus",
+				"stream":   true,
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+			},
+			IsStreaming: true,
+		})
+	}
+
+	respCtx, err := request()
+	testutil.AssertNoError(t, err)
+	_, err = io.ReadAll(respCtx.Response.Body)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, int64(0), p.Budget().Tokens) // Counted once the client is done
+	testutil.AssertNoError(t, respCtx.Response.Body.Close())
+
+	stats := p.Budget().Providers["anthropic"]
+	testutil.AssertEqual(t, int64(3000), stats.Tokens)
+	testutil.AssertEqual(t, 3.0, stats.Cost)
+	testutil.AssertTrue(t, stats.Exceeded)
+
+	// The stream's usage is recorded on the response context too
+	testutil.AssertEqual(t, 1000, respCtx.InputTokens)
+	testutil.AssertEqual(t, 2000, respCtx.OutputTokens)
+	testutil.AssertEqual(t, 500, respCtx.CacheReadTokens)
+	testutil.AssertEqual(t, 200, respCtx.CacheCreationTokens)
+	testutil.AssertTrue(t, respCtx.Cost != nil)
+
+	_, err = request()
+	testutil.AssertError(t, err)
+	testutil.AssertContains(t, err.Error(), "Budget exceeded")
+}
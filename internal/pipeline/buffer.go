@@ -0,0 +1,212 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+// isStreamOnly reports whether the model only answers with a stream, so a
+// non-streaming request has to be streamed upstream and buffered
+func isStreamOnly(provider *config.Provider, model string) bool {
+	return provider.ModelCapabilities[model].StreamOnly
+}
+
+// bufferStreamResponse reads an Anthropic event stream to the end and returns
+// the message it describes as a non-streaming JSON response. An error event in
+// the stream becomes an Anthropic error response. Responses that are not
+// streams, such as upstream errors, are returned unchanged.
+func bufferStreamResponse(resp *http.Response) (*http.Response, error) {
+	if resp.Body == nil || resp.StatusCode >= http.StatusBadRequest ||
+		!strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp, nil
+	}
+
+	reader := transformer.NewSSEReader(resp.Body)
+	message, errBody, err := assembleStreamMessage(reader)
+	_ = reader.Close() // Safe to ignore: the stream has been fully read
+	if err != nil {
+		return nil, err
+	}
+
+	statusCode := resp.StatusCode
+	data := errBody
+	if errBody != nil {
+		// The provider accepted the request but failed while generating
+		statusCode = http.StatusBadGateway
+	} else if data, err = json.Marshal(message); err != nil {
+		return nil, fmt.Errorf("failed to marshal buffered message: %w", err)
+	}
+
+	header := resp.Header.Clone()
+	header.Set("Content-Type", "application/json")
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	header.Del("Cache-Control")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       resp.Request,
+	}, nil
+}
+
+// assembleStreamMessage builds an Anthropic message from its stream events.
+// It returns the error event's data instead when the stream reports an error.
+func assembleStreamMessage(reader transformer.StreamReader) (map[string]interface{}, []byte, error) {
+	var message map[string]interface{}
+	usage := map[string]interface{}{}
+	var blocks []map[string]interface{}
+	toolInputs := make(map[int]*strings.Builder)
+
+	for {
+		event, err := reader.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		data := strings.TrimSpace(event.Data)
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			return nil, nil, fmt.Errorf("invalid stream event: %w", err)
+		}
+
+		switch payload["type"] {
+		case "error":
+			return nil, []byte(data), nil
+
+		case "message_start":
+			start, _ := payload["message"].(map[string]interface{})
+			message = map[string]interface{}{
+				"id":            start["id"],
+				"type":          "message",
+				"role":          "assistant",
+				"model":         start["model"],
+				"stop_reason":   nil,
+				"stop_sequence": nil,
+			}
+			mergeStreamUsage(usage, start["usage"])
+
+		case "content_block_start":
+			index, ok := streamEventIndex(payload)
+			if !ok {
+				continue
+			}
+			block := make(map[string]interface{})
+			if start, ok := payload["content_block"].(map[string]interface{}); ok {
+				for key, value := range start {
+					block[key] = value
+				}
+			}
+			for len(blocks) <= index {
+				blocks = append(blocks, nil)
+			}
+			blocks[index] = block
+
+		case "content_block_delta":
+			index, ok := streamEventIndex(payload)
+			if !ok || index >= len(blocks) || blocks[index] == nil {
+				continue
+			}
+			block := blocks[index]
+			delta, _ := payload["delta"].(map[string]interface{})
+			switch delta["type"] {
+			case "text_delta":
+				block["text"] = stringField(block, "text") + stringField(delta, "text")
+			case "thinking_delta":
+				block["thinking"] = stringField(block, "thinking") + stringField(delta, "thinking")
+			case "signature_delta":
+				block["signature"] = stringField(block, "signature") + stringField(delta, "signature")
+			case "input_json_delta":
+				if toolInputs[index] == nil {
+					toolInputs[index] = &strings.Builder{}
+				}
+				toolInputs[index].WriteString(stringField(delta, "partial_json"))
+			}
+
+		case "message_delta":
+			if message == nil {
+				continue
+			}
+			if delta, ok := payload["delta"].(map[string]interface{}); ok {
+				message["stop_reason"] = delta["stop_reason"]
+				message["stop_sequence"] = delta["stop_sequence"]
+			}
+			mergeStreamUsage(usage, payload["usage"])
+		}
+	}
+
+	if message == nil {
+		return nil, nil, fmt.Errorf("stream ended without a message_start event")
+	}
+
+	// Tool inputs arrive as JSON fragments that only parse once complete
+	for index, partial := range toolInputs {
+		if blocks[index] == nil || strings.TrimSpace(partial.String()) == "" {
+			continue
+		}
+		var input interface{}
+		if err := json.Unmarshal([]byte(partial.String()), &input); err != nil {
+			return nil, nil, fmt.Errorf("invalid tool input in content block %d: %w", index, err)
+		}
+		blocks[index]["input"] = input
+	}
+
+	content := make([]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		if block != nil {
+			content = append(content, block)
+		}
+	}
+	message["content"] = content
+	message["usage"] = usage
+	return message, nil, nil
+}
+
+// mergeStreamUsage copies usage counts into usage. Counts are cumulative, so
+// later events replace earlier ones, but a zero never replaces a count.
+func mergeStreamUsage(usage map[string]interface{}, value interface{}) {
+	counts, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, count := range counts {
+		if n, ok := count.(float64); ok && n == 0 && usage[key] != nil {
+			continue
+		}
+		usage[key] = count
+	}
+}
+
+// streamEventIndex returns the content block index of a stream event
+func streamEventIndex(payload map[string]interface{}) (int, bool) {
+	index, ok := payload["index"].(float64)
+	if !ok || index < 0 {
+		return 0, false
+	}
+	return int(index), true
+}
+
+// stringField returns a string field of m, or "" when it is missing
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+// streamResponse returns a 200 SSE response with the given body
+func streamResponse(body string) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", "text/event-stream")
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestBufferStreamResponse(t *testing.T) {
+	t.Run("AssemblesMessage", func(t *testing.T) {
+		stream := strings.Join([]string{
+			`event: message_start`,
+			`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[],"usage":{"input_tokens":12,"output_tokens":0}}}`,
+			``,
+			`event: content_block_start`,
+			`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			``,
+			`event: content_block_delta`,
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			``,
+			`event: content_block_delta`,
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+			``,
+			`event: content_block_stop`,
+			`data: {"type":"content_block_stop","index":0}`,
+			``,
+			`event: content_block_start`,
+			`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`,
+			``,
+			`event: content_block_delta`,
+			`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"query\":"}}`,
+			``,
+			`event: content_block_delta`,
+			`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"weather\"}"}}`,
+			``,
+			`event: message_delta`,
+			`data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":7}}`,
+			``,
+			`event: message_stop`,
+			`data: {"type":"message_stop"}`,
+			``,
+		}, "\n")
+
+		resp, err := bufferStreamResponse(streamResponse(stream))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, http.StatusOK, resp.StatusCode)
+		testutil.AssertEqual(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var message struct {
+			ID         string `json:"id"`
+			Type       string `json:"type"`
+			Model      string `json:"model"`
+			StopReason string `json:"stop_reason"`
+			Content    []struct {
+				Type  string                 `json:"type"`
+				Text  string                 `json:"text"`
+				Name  string                 `json:"name"`
+				Input map[string]interface{} `json:"input"`
+			} `json:"content"`
+			Usage struct {
+				InputTokens  int `json:"input_tokens"`
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+		}
+		testutil.AssertNoError(t, json.NewDecoder(resp.Body).Decode(&message))
+		testutil.AssertEqual(t, "msg_1", message.ID)
+		testutil.AssertEqual(t, "message", message.Type)
+		testutil.AssertEqual(t, "tool_use", message.StopReason)
+		testutil.AssertEqual(t, 2, len(message.Content))
+		testutil.AssertEqual(t, "Hello world", message.Content[0].Text)
+		testutil.AssertEqual(t, "lookup", message.Content[1].Name)
+		testutil.AssertEqual(t, "weather", message.Content[1].Input["query"])
+		testutil.AssertEqual(t, 12, message.Usage.InputTokens)
+		testutil.AssertEqual(t, 7, message.Usage.OutputTokens)
+	})
+
+	t.Run("ErrorEvent", func(t *testing.T) {
+		stream := strings.Join([]string{
+			`event: message_start`,
+			`data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":1}}}`,
+			``,
+			`event: error`,
+			`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			``,
+		}, "\n")
+
+		resp, err := bufferStreamResponse(streamResponse(stream))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, http.StatusBadGateway, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		testutil.AssertContains(t, string(body), "overloaded_error")
+	})
+
+	t.Run("EmptyStream", func(t *testing.T) {
+		_, err := bufferStreamResponse(streamResponse(""))
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "without a message_start")
+	})
+
+	t.Run("NonStreamingUnchanged", func(t *testing.T) {
+		resp := streamResponse(`{"type":"error"}`)
+		resp.StatusCode = http.StatusTooManyRequests
+		got, err := bufferStreamResponse(resp)
+		testutil.AssertNoError(t, err)
+		testutil.AssertTrue(t, got == resp)
+	})
+}
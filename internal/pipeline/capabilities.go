@@ -0,0 +1,31 @@
+package pipeline
+
+import (
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+// checkModelCapabilities rejects a request that needs a feature the target
+// model's configured capabilities rule out, instead of letting the provider
+// fail it with a less helpful error
+func checkModelCapabilities(bodyMap map[string]interface{}, provider *config.Provider, model string, streaming bool) error {
+	capabilities, ok := provider.ModelCapabilities[model]
+	if !ok {
+		return nil
+	}
+
+	if tools, _ := bodyMap["tools"].([]interface{}); len(tools) > 0 && !capabilities.SupportsTools() {
+		return ccerrors.Newf(ccerrors.ErrorTypeBadRequest,
+			"model %s on provider %s does not support tools", model, provider.Name)
+	}
+	if streaming && !capabilities.SupportsStreaming() {
+		return ccerrors.Newf(ccerrors.ErrorTypeBadRequest,
+			"model %s on provider %s does not support streaming", model, provider.Name)
+	}
+	if !capabilities.SupportsVision() && transformer.CountImages(bodyMap) > 0 {
+		return ccerrors.Newf(ccerrors.ErrorTypeBadRequest,
+			"model %s on provider %s does not support image input", model, provider.Name)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+)
+
+func TestCheckModelCapabilities(t *testing.T) {
+	disabled := false
+	provider := &config.Provider{
+		Name: "ollama",
+		ModelCapabilities: map[string]config.ModelCapabilities{
+			"llama3": {Streaming: &disabled, Tools: &disabled, Vision: &disabled},
+			"llava":  {MaxContext: 4096},
+		},
+	}
+	image := map[string]interface{}{
+		"role": "user",
+		"content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "What is this?"},
+			map[string]interface{}{
+				"type":   "image",
+				"source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "AAAA"},
+			},
+		},
+	}
+	text := map[string]interface{}{"role": "user", "content": "Hello"}
+	tools := []interface{}{map[string]interface{}{"name": "get_weather"}}
+
+	tests := []struct {
+		name      string
+		model     string
+		streaming bool
+		body      map[string]interface{}
+		wantErr   string
+	}{
+		{"plain request", "llama3", false, map[string]interface{}{"messages": []interface{}{text}}, ""},
+		{"tools", "llama3", false, map[string]interface{}{"messages": []interface{}{text}, "tools": tools}, "does not support tools"},
+		{"empty tools", "llama3", false, map[string]interface{}{"messages": []interface{}{text}, "tools": []interface{}{}}, ""},
+		{"streaming", "llama3", true, map[string]interface{}{"messages": []interface{}{text}}, "does not support streaming"},
+		{"image", "llama3", false, map[string]interface{}{"messages": []interface{}{image}}, "does not support image input"},
+		{"unset capabilities", "llava", true, map[string]interface{}{"messages": []interface{}{image}, "tools": tools}, ""},
+		{"unconfigured model", "mistral", true, map[string]interface{}{"messages": []interface{}{image}, "tools": tools}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkModelCapabilities(tt.body, provider, tt.model, tt.streaming)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/orchestre-dev/ccproxy/internal/tracing"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// coalesceIgnoredHeaders differ between otherwise identical requests and do
+// not change the response
+var coalesceIgnoredHeaders = map[string]bool{
+	RequestIDHeader:           true,
+	IdempotencyHeader:         true,
+	tracing.TraceparentHeader: true,
+	tracing.TracestateHeader:  true,
+}
+
+// errSubscriberClosed is returned when reading a closed coalesced stream
+var errSubscriberClosed = errors.New("coalesced stream closed")
+
+// coalescedStream is one upstream streaming request shared by every identical
+// request that arrives while it is running
+type coalescedStream struct {
+	ready     chan struct{}    // Closed once the leader has a response
+	respCtx   *ResponseContext // Leader's response, nil when it failed
+	broadcast *streamBroadcast
+}
+
+// coalesceKey returns the key identical streaming requests share, or "" when
+// coalescing is disabled or the request is not streaming. Keys cover the
+// body, the caller's credentials and the headers that affect routing.
+func (p *Pipeline) coalesceKey(req *RequestContext) string {
+	if !req.IsStreaming || !p.currentConfig().Performance.CoalesceStreams {
+		return ""
+	}
+
+	body, err := json.Marshal(req.Body)
+	if err != nil {
+		return ""
+	}
+
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		if !coalesceIgnoredHeaders[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		hash.Write([]byte(name + ":" + req.Headers[name] + "\x00"))
+	}
+	for _, field := range []string{"api_key_hash", RequestedModelKey} {
+		if value, ok := req.Metadata[field].(string); ok {
+			hash.Write([]byte(field + ":" + value + "\x00"))
+		}
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// processCoalesced joins an identical streaming request that is already
+// running, or sends the request and shares its stream with later duplicates
+func (p *Pipeline) processCoalesced(ctx context.Context, req *RequestContext, key string) (*ResponseContext, error) {
+	p.coalescedMu.Lock()
+	if stream, exists := p.coalesced[key]; exists {
+		p.coalescedMu.Unlock()
+
+		select {
+		case <-stream.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		// Failures are never shared, the duplicate gets its own attempt
+		if respCtx := stream.subscribe(); respCtx != nil {
+			utils.GetLogger().Debug("Coalesced streaming request with an identical in-flight request")
+			return respCtx, nil
+		}
+		return p.processRequest(ctx, req)
+	}
+
+	stream := &coalescedStream{ready: make(chan struct{})}
+	p.coalesced[key] = stream
+	p.coalescedMu.Unlock()
+
+	release := func() {
+		p.coalescedMu.Lock()
+		if p.coalesced[key] == stream {
+			delete(p.coalesced, key)
+		}
+		p.coalescedMu.Unlock()
+	}
+	defer close(stream.ready)
+
+	// The upstream stream outlives the leader's client when others are
+	// reading it, and is cancelled once every subscriber has gone
+	upstreamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	respCtx, err := p.processRequest(upstreamCtx, req)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+
+	resp := respCtx.Response
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 ||
+		!strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		release()
+		resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+		return respCtx, nil
+	}
+
+	stream.respCtx = respCtx
+	stream.broadcast = newStreamBroadcast(resp.Body, cancel, release)
+	return stream.subscribe(), nil
+}
+
+// subscribe returns a response that replays the shared stream from the start,
+// or nil when the leader failed or the stream was cut off
+func (s *coalescedStream) subscribe() *ResponseContext {
+	if s.respCtx == nil {
+		return nil
+	}
+	body := s.broadcast.subscribe()
+	if body == nil {
+		return nil
+	}
+
+	respCtx := *s.respCtx
+	resp := *s.respCtx.Response
+	resp.Header = resp.Header.Clone()
+	resp.Body = body
+	respCtx.Response = &resp
+	return &respCtx
+}
+
+// streamBroadcast reads an upstream body once and fans it out to any number
+// of subscribers. Everything read is kept, so a subscriber that joins late
+// replays the stream from the start.
+type streamBroadcast struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	data        []byte
+	err         error // io.EOF once the stream ended cleanly
+	subscribers int
+	cancel      context.CancelFunc
+}
+
+// newStreamBroadcast starts reading body. cancel aborts the upstream request
+// and done runs once the body has been read to the end or failed.
+func newStreamBroadcast(body io.ReadCloser, cancel context.CancelFunc, done func()) *streamBroadcast {
+	b := &streamBroadcast{cancel: cancel}
+	b.cond = sync.NewCond(&b.mu)
+	go b.pump(body, done)
+	return b
+}
+
+// pump copies the upstream body into the buffer until it ends
+func (b *streamBroadcast) pump(body io.ReadCloser, done func()) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+
+		b.mu.Lock()
+		b.data = append(b.data, buf[:n]...)
+		if err != nil {
+			b.err = err
+		}
+		b.cond.Broadcast()
+		b.mu.Unlock()
+
+		if err != nil {
+			break
+		}
+	}
+
+	_ = body.Close() // Safe to ignore: the stream has been fully read
+	b.cancel()
+	done()
+}
+
+// subscribe returns a reader positioned at the start of the stream, or nil
+// when the stream failed before finishing
+func (b *streamBroadcast) subscribe() io.ReadCloser {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil && b.err != io.EOF {
+		return nil
+	}
+	b.subscribers++
+	return &broadcastReader{broadcast: b}
+}
+
+// broadcastReader is one subscriber's view of a streamBroadcast
+type broadcastReader struct {
+	broadcast *streamBroadcast
+	offset    int
+	closed    bool
+}
+
+// Read returns buffered data, waiting for more while the stream is running
+func (r *broadcastReader) Read(p []byte) (int, error) {
+	b := r.broadcast
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for !r.closed && r.offset >= len(b.data) && b.err == nil {
+		b.cond.Wait()
+	}
+	if r.closed {
+		return 0, errSubscriberClosed
+	}
+	if r.offset < len(b.data) {
+		n := copy(p, b.data[r.offset:])
+		r.offset += n
+		return n, nil
+	}
+	return 0, b.err
+}
+
+// Close detaches the subscriber, cancelling the upstream request when it was
+// the last one still reading
+func (r *broadcastReader) Close() error {
+	b := r.broadcast
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	b.subscribers--
+	if b.subscribers == 0 && b.err == nil {
+		b.cancel()
+	}
+	b.cond.Broadcast()
+	return nil
+}
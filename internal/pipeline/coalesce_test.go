@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_CoalesceStreams(t *testing.T) {
+	var upstreamCalls int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"id":"1","choices":[{"index":0,"delta":{"content":"first"}}]}` + "\n\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte(`data: {"id":"1","choices":[{"index":0,"delta":{"content":"second"},"finish_reason":"stop"}]}` + "\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second, CoalesceStreams: true},
+		Providers:   []config.Provider{{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"}},
+		Routes:      map[string]config.Route{"default": {Provider: "openai", Model: "gpt-4o"}},
+	}
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	testutil.AssertNoError(t, providerService.Initialize())
+	p := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+
+	newRequest := func(content string) *RequestContext {
+		return &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "claude-3-opus",
+				"stream":   true,
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": content}},
+			},
+			Headers:     map[string]string{"Authorization": "Bearer client", RequestIDHeader: content + "-" + time.Now().String()},
+			IsStreaming: true,
+			Metadata:    map[string]interface{}{},
+		}
+	}
+
+	readAll := func(resp *ResponseContext) string {
+		defer resp.Response.Body.Close()
+		body, err := io.ReadAll(resp.Response.Body)
+		testutil.AssertNoError(t, err)
+		return string(body)
+	}
+
+	leader, err := p.ProcessRequest(context.Background(), newRequest("Hello"))
+	testutil.AssertNoError(t, err)
+
+	// A duplicate joins the running stream, even though its request id differs
+	follower, err := p.ProcessRequest(context.Background(), newRequest("Hello"))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, "openai", follower.Provider)
+
+	close(release)
+	leaderBody := readAll(leader)
+	testutil.AssertContains(t, leaderBody, "second")
+	testutil.AssertEqual(t, leaderBody, readAll(follower))
+	testutil.AssertEqual(t, int32(1), atomic.LoadInt32(&upstreamCalls))
+
+	// Once the stream has ended, the next request goes upstream again
+	deadline := time.Now().Add(time.Second)
+	for {
+		p.coalescedMu.Lock()
+		remaining := len(p.coalesced)
+		p.coalescedMu.Unlock()
+		if remaining == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	readAll(mustProcess(t, p, newRequest("Hello")))
+	testutil.AssertEqual(t, int32(2), atomic.LoadInt32(&upstreamCalls))
+
+	// Different prompts are never shared
+	readAll(mustProcess(t, p, newRequest("Goodbye")))
+	testutil.AssertEqual(t, int32(3), atomic.LoadInt32(&upstreamCalls))
+}
+
+// mustProcess runs a request through the pipeline and fails the test on error
+func mustProcess(t *testing.T, p *Pipeline, req *RequestContext) *ResponseContext {
+	t.Helper()
+	resp, err := p.ProcessRequest(context.Background(), req)
+	testutil.AssertNoError(t, err)
+	return resp
+}
+
+func TestStreamBroadcast(t *testing.T) {
+	t.Run("LateSubscriberReplays", func(t *testing.T) {
+		upstream, writer := io.Pipe()
+		done := make(chan struct{})
+		b := newStreamBroadcast(upstream, func() {}, func() { close(done) })
+
+		early := b.subscribe()
+		writer.Write([]byte("data: one\n\n"))
+
+		buf := make([]byte, 64)
+		n, err := early.Read(buf)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "data: one\n\n", string(buf[:n]))
+
+		late := b.subscribe()
+		writer.Write([]byte("data: two\n\n"))
+		writer.Close()
+		<-done
+
+		rest, err := io.ReadAll(early)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "data: two\n\n", string(rest))
+
+		replayed, err := io.ReadAll(late)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "data: one\n\ndata: two\n\n", string(replayed))
+	})
+
+	t.Run("LastCloseCancelsUpstream", func(t *testing.T) {
+		upstream, writer := io.Pipe()
+		defer writer.Close()
+		var cancelled int32
+		b := newStreamBroadcast(upstream, func() { atomic.StoreInt32(&cancelled, 1) }, func() {})
+
+		first := b.subscribe()
+		second := b.subscribe()
+		first.Close()
+		testutil.AssertEqual(t, int32(0), atomic.LoadInt32(&cancelled))
+
+		// A blocked reader is woken by its own Close
+		readErr := make(chan error, 1)
+		go func() {
+			_, err := second.Read(make([]byte, 8))
+			readErr <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		second.Close()
+		testutil.AssertEqual(t, int32(1), atomic.LoadInt32(&cancelled))
+		select {
+		case err := <-readErr:
+			testutil.AssertTrue(t, strings.Contains(err.Error(), "closed"))
+		case <-time.After(time.Second):
+			t.Fatal("Expected the blocked read to return")
+		}
+	})
+
+	t.Run("FailedStreamRejectsSubscribers", func(t *testing.T) {
+		upstream, writer := io.Pipe()
+		done := make(chan struct{})
+		b := newStreamBroadcast(upstream, func() {}, func() { close(done) })
+		writer.CloseWithError(io.ErrUnexpectedEOF)
+		<-done
+		testutil.AssertTrue(t, b.subscribe() == nil)
+	})
+}
@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// maxEmulatedCompletions bounds the parallel requests sent for one request
+const maxEmulatedCompletions = 8
+
+// emulatedCompletions returns how many parallel requests to send for a
+// request asking for n > 1 completions from a provider without native
+// support, or 0 to send it once. Streaming requests and providers using the
+// strip strategy get a single completion.
+func (p *Pipeline) emulatedCompletions(req *RequestContext, providerName string) int {
+	bodyMap, ok := req.Body.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	n := intValue(bodyMap["n"])
+	if n <= 1 || transformer.SupportsMultipleCompletions(providerName) {
+		return 0
+	}
+
+	provider, err := p.providerService.GetProvider(providerName)
+	if err != nil {
+		return 0 // Reported when the request is processed
+	}
+	if provider.MultipleCompletions != config.MultipleCompletionsEmulate || req.IsStreaming {
+		utils.GetLogger().Warnf("Provider %s does not support n=%d, returning a single completion", providerName, n)
+		return 0
+	}
+	if n > maxEmulatedCompletions {
+		utils.GetLogger().Warnf("Emulating n=%d with %d requests to provider %s", n, maxEmulatedCompletions, providerName)
+		n = maxEmulatedCompletions
+	}
+	return n
+}
+
+// processEmulated sends count copies of a request in parallel and merges
+// their choices into one response. The first failure or non-200 response is
+// returned instead.
+func (p *Pipeline) processEmulated(ctx context.Context, req *RequestContext, count int) (*ResponseContext, error) {
+	results := make([]*ResponseContext, count)
+	errs := make([]error, count)
+
+	singles := make([]*RequestContext, count)
+	for i := range singles {
+		single, err := singleCompletionRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		singles[i] = single
+	}
+
+	var wg sync.WaitGroup
+	for i, single := range singles {
+		wg.Add(1)
+		go func(i int, single *RequestContext) {
+			defer wg.Done()
+			results[i], errs[i] = p.processRequest(ctx, single)
+		}(i, single)
+	}
+	wg.Wait()
+
+	// Return the first failure and release every other response
+	for i := range results {
+		if errs[i] == nil && results[i].Response.StatusCode == http.StatusOK {
+			continue
+		}
+		closeResponses(results, results[i])
+		return results[i], errs[i]
+	}
+
+	bodies := make([][]byte, count)
+	for i, result := range results {
+		body, err := io.ReadAll(result.Response.Body)
+		if err != nil {
+			closeResponses(results, nil)
+			return nil, fmt.Errorf("failed to read completion %d: %w", i, err)
+		}
+		bodies[i] = body
+	}
+	closeResponses(results, nil)
+
+	merged, err := mergeCompletions(bodies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge completions: %w", err)
+	}
+
+	respCtx := results[0]
+	respCtx.Response.Body = io.NopCloser(bytes.NewReader(merged))
+	respCtx.Response.ContentLength = int64(len(merged))
+	respCtx.Response.Header.Del("Content-Length")
+	for _, result := range results[1:] {
+		respCtx.InputTokens += result.InputTokens
+		respCtx.OutputTokens += result.OutputTokens
+		respCtx.CacheReadTokens += result.CacheReadTokens
+		respCtx.CacheCreationTokens += result.CacheCreationTokens
+		if respCtx.Cost != nil && result.Cost != nil {
+			respCtx.Cost.InputTokens += result.Cost.InputTokens
+			respCtx.Cost.OutputTokens += result.Cost.OutputTokens
+			respCtx.Cost.InputCost += result.Cost.InputCost
+			respCtx.Cost.OutputCost += result.Cost.OutputCost
+			respCtx.Cost.TotalCost += result.Cost.TotalCost
+		}
+	}
+	return respCtx, nil
+}
+
+// singleCompletionRequest returns a deep copy of req asking for one
+// completion, so parallel requests never share body maps
+func singleCompletionRequest(req *RequestContext) (*RequestContext, error) {
+	body, err := copyRequestBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	delete(body, "n")
+
+	single := *req
+	single.Body = body
+	return &single, nil
+}
+
+// copyRequestBody returns a deep copy of a JSON request body
+func copyRequestBody(body interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy request body: %w", err)
+	}
+	var copied map[string]interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, fmt.Errorf("failed to copy request body: %w", err)
+	}
+	return copied, nil
+}
+
+// mergeCompletions combines chat completions into the first one. Choices are
+// reindexed in order and numeric usage fields are added up.
+func mergeCompletions(bodies [][]byte) ([]byte, error) {
+	var merged map[string]interface{}
+	var choices []interface{}
+	usage := map[string]interface{}{}
+
+	for i, body := range bodies {
+		var completion map[string]interface{}
+		if err := json.Unmarshal(body, &completion); err != nil {
+			return nil, fmt.Errorf("completion %d is not valid JSON: %w", i, err)
+		}
+		completionChoices, ok := completion["choices"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("completion %d has no choices", i)
+		}
+		if merged == nil {
+			merged = completion
+		}
+
+		for _, choice := range completionChoices {
+			if choiceMap, ok := choice.(map[string]interface{}); ok {
+				choiceMap["index"] = len(choices)
+				choices = append(choices, choiceMap)
+			}
+		}
+
+		completionUsage, _ := completion["usage"].(map[string]interface{})
+		for key, value := range completionUsage {
+			if count, ok := value.(float64); ok {
+				total, _ := usage[key].(float64)
+				usage[key] = total + count
+			} else if _, exists := usage[key]; !exists {
+				usage[key] = value
+			}
+		}
+	}
+
+	if merged == nil {
+		return nil, fmt.Errorf("no completions to merge")
+	}
+	merged["choices"] = choices
+	if len(usage) > 0 {
+		merged["usage"] = usage
+	}
+	return json.Marshal(merged)
+}
+
+// closeResponses closes the bodies of every result except keep
+func closeResponses(results []*ResponseContext, keep *ResponseContext) {
+	for _, result := range results {
+		if result != nil && result != keep && result.Response != nil && result.Response.Body != nil {
+			_ = result.Response.Body.Close() // Safe to ignore: response is discarded
+		}
+	}
+}
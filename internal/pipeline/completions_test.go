@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestMergeCompletions(t *testing.T) {
+	completion := func(texts ...string) string {
+		var choices []string
+		for i, text := range texts {
+			choices = append(choices, fmt.Sprintf(`{"index":%d,"message":{"role":"assistant","content":%q},"finish_reason":"stop"}`, i, text))
+		}
+		return fmt.Sprintf(`{"id":"chatcmpl-%s","object":"chat.completion","choices":[%s],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12,"prompt_tokens_details":{"cached_tokens":0}}}`,
+			texts[0], strings.Join(choices, ","))
+	}
+
+	tests := []struct {
+		name      string
+		bodies    []string
+		wantTexts []string
+		wantUsage map[string]float64
+		wantErr   string
+	}{
+		{
+			name:      "one choice each",
+			bodies:    []string{completion("a"), completion("b"), completion("c")},
+			wantTexts: []string{"a", "b", "c"},
+			wantUsage: map[string]float64{"prompt_tokens": 30, "completion_tokens": 6, "total_tokens": 36},
+		},
+		{
+			name:      "several choices each",
+			bodies:    []string{completion("a", "b"), completion("c", "d")},
+			wantTexts: []string{"a", "b", "c", "d"},
+			wantUsage: map[string]float64{"prompt_tokens": 20, "completion_tokens": 4, "total_tokens": 24},
+		},
+		{
+			name:      "missing usage",
+			bodies:    []string{`{"choices":[{"index":0,"message":{"content":"a"}}]}`, completion("b")},
+			wantTexts: []string{"a", "b"},
+			wantUsage: map[string]float64{"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12},
+		},
+		{
+			name:    "invalid JSON",
+			bodies:  []string{completion("a"), "not json"},
+			wantErr: "completion 1 is not valid JSON",
+		},
+		{
+			name:    "no choices",
+			bodies:  []string{`{"error":{"message":"boom"}}`},
+			wantErr: "completion 0 has no choices",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies := make([][]byte, len(tt.bodies))
+			for i, body := range tt.bodies {
+				bodies[i] = []byte(body)
+			}
+
+			data, err := mergeCompletions(bodies)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var merged struct {
+				ID      string `json:"id"`
+				Choices []struct {
+					Index   int `json:"index"`
+					Message struct {
+						Content string `json:"content"`
+					} `json:"message"`
+				} `json:"choices"`
+				Usage map[string]interface{} `json:"usage"`
+			}
+			if err := json.Unmarshal(data, &merged); err != nil {
+				t.Fatalf("Merged response is not valid JSON: %v", err)
+			}
+
+			if len(merged.Choices) != len(tt.wantTexts) {
+				t.Fatalf("Expected %d choices, got %d", len(tt.wantTexts), len(merged.Choices))
+			}
+			for i, choice := range merged.Choices {
+				if choice.Index != i {
+					t.Errorf("Expected choice %d to have index %d, got %d", i, i, choice.Index)
+				}
+				if choice.Message.Content != tt.wantTexts[i] {
+					t.Errorf("Expected choice %d content %q, got %q", i, tt.wantTexts[i], choice.Message.Content)
+				}
+			}
+			for key, want := range tt.wantUsage {
+				if got := merged.Usage[key]; got != want {
+					t.Errorf("Expected usage %s %v, got %v", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPipeline_EmulatedCompletions(t *testing.T) {
+	var requests int32
+	var failAt int32 // 1-based request number answered with a 500, 0 for none
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := atomic.AddInt32(&requests, 1)
+
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if _, hasN := body["n"]; hasN {
+			t.Errorf("Expected n to be removed from upstream requests")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if count == atomic.LoadInt32(&failAt) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":{"message":"boom"}}`))
+			return
+		}
+		fmt.Fprintf(w, `{"id":"chatcmpl-%d","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"answer %d"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`, count, count)
+	}))
+	defer server.Close()
+
+	newPipeline := func(t *testing.T, strategy string) *Pipeline {
+		t.Helper()
+		cfg := &config.Config{
+			Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+			Providers: []config.Provider{{
+				Name:                "groq",
+				APIBaseURL:          server.URL,
+				APIKey:              "test-key",
+				Enabled:             true,
+				MultipleCompletions: strategy,
+			}},
+			Routes: map[string]config.Route{
+				"default": {Provider: "groq", Model: "llama"},
+			},
+		}
+		configService := config.NewService()
+		configService.SetConfig(cfg)
+		providerService := providers.NewService(configService)
+		if err := providerService.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize provider service: %v", err)
+		}
+		transformerService := transformer.NewService()
+		if err := transformer.RegisterBuiltinTransformers(transformerService); err != nil {
+			t.Fatalf("Failed to register transformers: %v", err)
+		}
+		return NewPipeline(cfg, providerService, transformerService, router.New(cfg))
+	}
+
+	request := func(streaming bool) *RequestContext {
+		return &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "groq,llama",
+				"n":        float64(3),
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+			},
+			Headers:     map[string]string{},
+			IsStreaming: streaming,
+		}
+	}
+
+	reset := func(fail int32) {
+		atomic.StoreInt32(&requests, 0)
+		atomic.StoreInt32(&failAt, fail)
+	}
+
+	t.Run("emulate merges choices", func(t *testing.T) {
+		reset(0)
+		respCtx, err := newPipeline(t, config.MultipleCompletionsEmulate).ProcessRequest(context.Background(), request(false))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+
+		if got := atomic.LoadInt32(&requests); got != 3 {
+			t.Errorf("Expected 3 upstream requests, got %d", got)
+		}
+		var merged struct {
+			Choices []struct {
+				Index int `json:"index"`
+			} `json:"choices"`
+		}
+		if err := json.NewDecoder(respCtx.Response.Body).Decode(&merged); err != nil {
+			t.Fatalf("Failed to decode merged response: %v", err)
+		}
+		if len(merged.Choices) != 3 {
+			t.Fatalf("Expected 3 choices, got %d", len(merged.Choices))
+		}
+		for i, choice := range merged.Choices {
+			if choice.Index != i {
+				t.Errorf("Expected choice %d to have index %d, got %d", i, i, choice.Index)
+			}
+		}
+		if respCtx.InputTokens != 15 || respCtx.OutputTokens != 3 {
+			t.Errorf("Expected summed usage 15/3, got %d/%d", respCtx.InputTokens, respCtx.OutputTokens)
+		}
+	})
+
+	t.Run("emulate returns upstream failure", func(t *testing.T) {
+		reset(2)
+		respCtx, err := newPipeline(t, config.MultipleCompletionsEmulate).ProcessRequest(context.Background(), request(false))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+
+		if respCtx.Response.StatusCode != http.StatusInternalServerError {
+			t.Errorf("Expected the failed completion's status, got %d", respCtx.Response.StatusCode)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		strategy  string
+		streaming bool
+	}{
+		{"strip by default", "", false},
+		{"emulate skips streams", config.MultipleCompletionsEmulate, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset(0)
+			respCtx, err := newPipeline(t, tt.strategy).ProcessRequest(context.Background(), request(tt.streaming))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			respCtx.Response.Body.Close()
+
+			if got := atomic.LoadInt32(&requests); got != 1 {
+				t.Errorf("Expected 1 upstream request, got %d", got)
+			}
+		})
+	}
+}
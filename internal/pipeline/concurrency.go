@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+)
+
+// providerLimiter bounds the in-flight upstream requests to one provider
+type providerLimiter struct {
+	slots    chan struct{}
+	inFlight int64
+}
+
+// limiterFor returns the limiter for a provider, or nil when unlimited.
+// A limiter is replaced when the configured capacity changes.
+func (p *Pipeline) limiterFor(provider *config.Provider) *providerLimiter {
+	if provider.MaxConcurrency <= 0 {
+		return nil
+	}
+
+	p.limitersMu.Lock()
+	defer p.limitersMu.Unlock()
+
+	if p.limiters == nil {
+		p.limiters = make(map[string]*providerLimiter)
+	}
+	limiter, exists := p.limiters[provider.Name]
+	if !exists || cap(limiter.slots) != provider.MaxConcurrency {
+		limiter = &providerLimiter{slots: make(chan struct{}, provider.MaxConcurrency)}
+		p.limiters[provider.Name] = limiter
+	}
+	return limiter
+}
+
+// acquireSlot blocks until the provider has a free concurrency slot. Without
+// a context deadline it waits at most the configured request timeout.
+func (p *Pipeline) acquireSlot(ctx context.Context, provider *config.Provider) (func(), error) {
+	limiter := p.limiterFor(provider)
+	if limiter == nil {
+		return func() {}, nil
+	}
+
+	timeout := p.currentConfig().Performance.RequestTimeout
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	select {
+	case limiter.slots <- struct{}{}:
+	case <-ctx.Done():
+		err := ccerrors.Wrapf(ctx.Err(), ccerrors.ErrorTypeResourceExhausted,
+			"provider %s is at its concurrency limit of %d", provider.Name, provider.MaxConcurrency)
+		err.StatusCode = http.StatusServiceUnavailable
+		return nil, err.WithProvider(provider.Name).WithCode("CONCURRENCY_LIMIT_EXCEEDED")
+	}
+
+	atomic.AddInt64(&limiter.inFlight, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			atomic.AddInt64(&limiter.inFlight, -1)
+			<-limiter.slots
+		})
+	}, nil
+}
+
+// InFlight returns the current in-flight request count for each provider
+// with a concurrency limit
+func (p *Pipeline) InFlight() map[string]int64 {
+	p.limitersMu.Lock()
+	defer p.limitersMu.Unlock()
+
+	counts := make(map[string]int64, len(p.limiters))
+	for name, limiter := range p.limiters {
+		counts[name] = atomic.LoadInt64(&limiter.inFlight)
+	}
+	return counts
+}
+
+// releaseOnCloseBody frees a concurrency slot once the response body is closed
+type releaseOnCloseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close closes the body and releases the slot
+func (b *releaseOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
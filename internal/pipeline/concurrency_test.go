@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_ConcurrencyLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"Hi\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key", MaxConcurrency: 1},
+		},
+		Routes: map[string]config.Route{
+			"default": {Provider: "openai", Model: "gpt-4"},
+		},
+	}
+
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	testutil.AssertNoError(t, providerService.Initialize())
+	pipeline := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+
+	newRequest := func() *RequestContext {
+		return &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "gpt-4",
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+			},
+			IsStreaming: true,
+		}
+	}
+
+	first, err := pipeline.ProcessRequest(context.Background(), newRequest())
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, int64(1), pipeline.InFlight()["openai"])
+
+	t.Run("SaturatedRequestTimesOut", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := pipeline.ProcessRequest(ctx, newRequest())
+		testutil.AssertError(t, err)
+
+		var ccErr *ccerrors.CCProxyError
+		testutil.AssertTrue(t, errors.As(err, &ccErr), "Expected CCProxyError")
+		testutil.AssertEqual(t, ccerrors.ErrorTypeResourceExhausted, ccErr.Type)
+		testutil.AssertEqual(t, http.StatusServiceUnavailable, ccErr.StatusCode)
+	})
+
+	t.Run("ClosingBodyReleasesSlot", func(t *testing.T) {
+		testutil.AssertNoError(t, first.Response.Body.Close())
+		testutil.AssertEqual(t, int64(0), pipeline.InFlight()["openai"])
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		next, err := pipeline.ProcessRequest(ctx, newRequest())
+		testutil.AssertNoError(t, err)
+		testutil.AssertNoError(t, next.Response.Body.Close())
+	})
+
+	t.Run("UnlimitedProvidersNotTracked", func(t *testing.T) {
+		release, err := pipeline.acquireSlot(context.Background(), &config.Provider{Name: "groq"})
+		testutil.AssertNoError(t, err)
+		release()
+
+		_, tracked := pipeline.InFlight()["groq"]
+		testutil.AssertTrue(t, !tracked, "Expected unlimited provider to have no limiter")
+	})
+}
+
+func TestPipeline_ConcurrencyLimitStreamingChain(t *testing.T) {
+	streams := map[string]string{
+		"openai":     "data: {\"choices\": [{\"delta\": {\"content\": \"Hi\"}}]}\n\ndata: [DONE]\n\n",
+		"deepseek":   "data: {\"choices\": [{\"delta\": {\"content\": \"Hi\"}}]}\n\ndata: [DONE]\n\n",
+		"openrouter": "data: {\"choices\": [{\"delta\": {\"content\": \"Hi\"}}]}\n\ndata: [DONE]\n\n",
+		"gemini":     "data: {\"candidates\": [{\"content\": {\"parts\": [{\"text\": \"Hi\"}]}, \"finishReason\": \"STOP\"}]}\n\n",
+	}
+
+	// newPipeline returns a pipeline with the builtin transformers and one
+	// provider limited to a single concurrent request
+	newPipeline := func(t *testing.T, provider string, handler http.HandlerFunc) *Pipeline {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		cfg := &config.Config{
+			Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+			Providers: []config.Provider{
+				{Name: provider, APIBaseURL: server.URL, APIKey: "test-key", Enabled: true, MaxConcurrency: 1},
+			},
+			Routes: map[string]config.Route{
+				"default": {Provider: provider, Model: "test-model"},
+			},
+		}
+		configService := config.NewService()
+		configService.SetConfig(cfg)
+		providerService := providers.NewService(configService)
+		testutil.AssertNoError(t, providerService.Initialize())
+		transformerService := transformer.NewService()
+		testutil.AssertNoError(t, transformer.RegisterBuiltinTransformers(transformerService))
+		return NewPipeline(cfg, providerService, transformerService, router.New(cfg))
+	}
+
+	newRequest := func() *RequestContext {
+		return &RequestContext{
+			Body: map[string]interface{}{
+				"model":      "claude-3-sonnet",
+				"max_tokens": float64(100),
+				"stream":     true,
+				"messages":   []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+			},
+			Headers:     map[string]string{},
+			IsStreaming: true,
+		}
+	}
+
+	// released waits for the provider's slot to be freed
+	released := func(p *Pipeline, provider string) bool {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if p.InFlight()[provider] == 0 {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+
+	for provider, stream := range streams {
+		t.Run(provider, func(t *testing.T) {
+			p := newPipeline(t, provider, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte(stream))
+			})
+
+			// Reading the stream to the end frees the slot without the
+			// client closing the body
+			for i := 0; i < 2; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				respCtx, err := p.ProcessRequest(ctx, newRequest())
+				cancel()
+				testutil.AssertNoError(t, err)
+				_, err = io.ReadAll(respCtx.Response.Body)
+				testutil.AssertNoError(t, err)
+				testutil.AssertTrue(t, released(p, provider), "Expected the slot to be released at the end of the stream")
+			}
+		})
+	}
+
+	t.Run("ClientCloseReleasesSlot", func(t *testing.T) {
+		done := make(chan struct{})
+		defer close(done)
+		p := newPipeline(t, "deepseek", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"Hi\"}}]}\n\n"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-done:
+			case <-r.Context().Done():
+			}
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		respCtx, err := p.ProcessRequest(ctx, newRequest())
+		testutil.AssertNoError(t, err)
+		testutil.AssertNoError(t, respCtx.Response.Body.Close())
+		testutil.AssertTrue(t, released(p, "deepseek"), "Expected closing the body to release the slot")
+	})
+}
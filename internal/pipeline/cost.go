@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// CostBreakdown is the cost attributed to a single request
+type CostBreakdown struct {
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	InputCost    float64 `json:"input_cost"`
+	OutputCost   float64 `json:"output_cost"`
+	TotalCost    float64 `json:"total_cost"`
+}
+
+// ComputeCost prices token counts using the given per-million rates
+func ComputeCost(provider, model string, pricing config.Pricing, inputTokens, outputTokens int) *CostBreakdown {
+	inputCost := float64(inputTokens) * pricing.InputPerMillion / 1_000_000
+	outputCost := float64(outputTokens) * pricing.OutputPerMillion / 1_000_000
+
+	return &CostBreakdown{
+		Provider:     provider,
+		Model:        model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		InputCost:    inputCost,
+		OutputCost:   outputCost,
+		TotalCost:    inputCost + outputCost,
+	}
+}
+
+// tokenUsage is the token usage reported in a response body
+type tokenUsage struct {
+	Input         int // Input tokens, excluding cached ones for Anthropic
+	Output        int // Output tokens
+	CacheRead     int // Input tokens read from the prompt cache
+	CacheCreation int // Input tokens written to the prompt cache
+}
+
+// responseUsage buffers a successful non-streaming response body and
+// returns the token usage it reports. The body remains readable afterwards.
+func responseUsage(resp *http.Response) (tokenUsage, bool) {
+	if resp == nil || resp.Body == nil || resp.StatusCode != http.StatusOK {
+		return tokenUsage{}, false
+	}
+
+	// Buffer the body so it can still be copied to the client
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close() // Safe to ignore: body is fully buffered
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		utils.GetLogger().Warnf("Failed to read response usage: %v", err)
+		return tokenUsage{}, false
+	}
+
+	return extractUsage(body)
+}
+
+// logCost computes and logs the cost breakdown for a priced model.
+// It returns nil when the model has no pricing.
+func logCost(provider *config.Provider, model string, inputTokens, outputTokens int) *CostBreakdown {
+	pricing, ok := provider.Pricing[model]
+	if !ok {
+		return nil
+	}
+
+	cost := ComputeCost(provider.Name, model, pricing, inputTokens, outputTokens)
+	utils.GetLogger().WithFields(logrus.Fields{
+		"provider":      cost.Provider,
+		"model":         cost.Model,
+		"input_tokens":  cost.InputTokens,
+		"output_tokens": cost.OutputTokens,
+		"input_cost":    cost.InputCost,
+		"output_cost":   cost.OutputCost,
+		"total_cost":    cost.TotalCost,
+		"type":          "cost",
+	}).Info("Request cost")
+
+	return cost
+}
+
+// extractUsage reads token usage from an OpenAI or Anthropic response body.
+// Cache reads come from Anthropic's cache_read_input_tokens or OpenAI's
+// prompt_tokens_details.cached_tokens.
+func extractUsage(body []byte) (tokenUsage, bool) {
+	var parsed struct {
+		Usage *struct {
+			PromptTokens             int `json:"prompt_tokens"`
+			CompletionTokens         int `json:"completion_tokens"`
+			InputTokens              int `json:"input_tokens"`
+			OutputTokens             int `json:"output_tokens"`
+			CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+			PromptTokensDetails      *struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil || parsed.Usage == nil {
+		return tokenUsage{}, false
+	}
+
+	usage := parsed.Usage
+	result := tokenUsage{
+		Input:         usage.PromptTokens,
+		Output:        usage.CompletionTokens,
+		CacheRead:     usage.CacheReadInputTokens,
+		CacheCreation: usage.CacheCreationInputTokens,
+	}
+	if usage.InputTokens > 0 || usage.OutputTokens > 0 {
+		result.Input, result.Output = usage.InputTokens, usage.OutputTokens
+	}
+	if result.CacheRead == 0 && usage.PromptTokensDetails != nil {
+		result.CacheRead = usage.PromptTokensDetails.CachedTokens
+	}
+	return result, true
+}
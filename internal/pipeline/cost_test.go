@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestComputeCost(t *testing.T) {
+	pricing := config.Pricing{InputPerMillion: 3, OutputPerMillion: 15}
+
+	tests := []struct {
+		name         string
+		inputTokens  int
+		outputTokens int
+		wantInput    float64
+		wantOutput   float64
+	}{
+		{name: "zero usage", inputTokens: 0, outputTokens: 0, wantInput: 0, wantOutput: 0},
+		{name: "one million each", inputTokens: 1_000_000, outputTokens: 1_000_000, wantInput: 3, wantOutput: 15},
+		{name: "typical request", inputTokens: 1200, outputTokens: 350, wantInput: 0.0036, wantOutput: 0.00525},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost := ComputeCost("anthropic", "claude-3-5-sonnet", pricing, tt.inputTokens, tt.outputTokens)
+
+			if cost.Provider != "anthropic" || cost.Model != "claude-3-5-sonnet" {
+				t.Errorf("Unexpected provider/model: %s/%s", cost.Provider, cost.Model)
+			}
+			if !floatEqual(cost.InputCost, tt.wantInput) {
+				t.Errorf("Expected input cost %v, got %v", tt.wantInput, cost.InputCost)
+			}
+			if !floatEqual(cost.OutputCost, tt.wantOutput) {
+				t.Errorf("Expected output cost %v, got %v", tt.wantOutput, cost.OutputCost)
+			}
+			if !floatEqual(cost.TotalCost, tt.wantInput+tt.wantOutput) {
+				t.Errorf("Expected total cost %v, got %v", tt.wantInput+tt.wantOutput, cost.TotalCost)
+			}
+		})
+	}
+}
+
+func TestResponseUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantInput  int
+		wantOutput int
+		wantOK     bool
+	}{
+		{"openai usage", http.StatusOK, `{"choices":[],"usage":{"prompt_tokens":2000,"completion_tokens":500}}`, 2000, 500, true},
+		{"anthropic usage", http.StatusOK, `{"usage":{"input_tokens":100,"output_tokens":40}}`, 100, 40, true},
+		{"missing usage", http.StatusOK, `{"choices":[]}`, 0, 0, false},
+		{"error response", http.StatusBadRequest, `{"usage":{"prompt_tokens":1,"completion_tokens":1}}`, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			usage, ok := responseUsage(resp)
+			if usage.Input != tt.wantInput || usage.Output != tt.wantOutput || ok != tt.wantOK {
+				t.Errorf("Expected (%d, %d, %v), got (%d, %d, %v)", tt.wantInput, tt.wantOutput, tt.wantOK, usage.Input, usage.Output, ok)
+			}
+
+			// The body must still be readable for the client
+			data, _ := io.ReadAll(resp.Body)
+			if string(data) != tt.body {
+				t.Errorf("Expected body to be preserved, got %s", data)
+			}
+		})
+	}
+}
+
+func TestExtractUsageCacheTokens(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantRead      int
+		wantCreation  int
+		wantInputUsed int
+	}{
+		{"anthropic cache usage", `{"usage":{"input_tokens":5,"output_tokens":2,"cache_read_input_tokens":1000,"cache_creation_input_tokens":200}}`, 1000, 200, 5},
+		{"converted anthropic usage", `{"usage":{"prompt_tokens":5,"completion_tokens":2,"cache_read_input_tokens":1000,"cache_creation_input_tokens":200,"prompt_tokens_details":{"cached_tokens":1000}}}`, 1000, 200, 5},
+		{"openai cached tokens", `{"usage":{"prompt_tokens":1500,"completion_tokens":2,"prompt_tokens_details":{"cached_tokens":1024}}}`, 1024, 0, 1500},
+		{"no cache usage", `{"usage":{"prompt_tokens":10,"completion_tokens":2}}`, 0, 0, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, ok := extractUsage([]byte(tt.body))
+			if !ok {
+				t.Fatal("Expected usage to be found")
+			}
+			if usage.CacheRead != tt.wantRead || usage.CacheCreation != tt.wantCreation || usage.Input != tt.wantInputUsed {
+				t.Errorf("Expected input %d, cache read %d, creation %d, got %+v", tt.wantInputUsed, tt.wantRead, tt.wantCreation, usage)
+			}
+		})
+	}
+}
+
+func TestLogCost(t *testing.T) {
+	provider := &config.Provider{
+		Name: "openai",
+		Pricing: map[string]config.Pricing{
+			"gpt-4o": {InputPerMillion: 2.5, OutputPerMillion: 10},
+		},
+	}
+
+	t.Run("logs breakdown", func(t *testing.T) {
+		hook := test.NewLocal(utils.GetLogger())
+		defer hook.Reset()
+
+		cost := logCost(provider, "gpt-4o", 2000, 500)
+		if cost == nil {
+			t.Fatal("Expected a cost breakdown")
+		}
+		if !floatEqual(cost.InputCost, 0.005) || !floatEqual(cost.OutputCost, 0.005) || !floatEqual(cost.TotalCost, 0.01) {
+			t.Errorf("Unexpected breakdown: %+v", cost)
+		}
+
+		var fields logrus.Fields
+		for _, entry := range hook.AllEntries() {
+			if entry.Data["type"] == "cost" {
+				fields = entry.Data
+			}
+		}
+		if fields == nil {
+			t.Fatal("Expected a cost log record")
+		}
+		if fields["provider"] != "openai" || fields["model"] != "gpt-4o" {
+			t.Errorf("Unexpected provider/model fields: %v", fields)
+		}
+		if fields["input_tokens"] != 2000 || fields["output_tokens"] != 500 {
+			t.Errorf("Unexpected token fields: %v", fields)
+		}
+		if !floatEqual(fields["total_cost"].(float64), 0.01) {
+			t.Errorf("Unexpected total_cost field: %v", fields["total_cost"])
+		}
+	})
+
+	t.Run("unpriced model", func(t *testing.T) {
+		if cost := logCost(provider, "gpt-3.5-turbo", 10, 5); cost != nil {
+			t.Errorf("Expected no breakdown for unpriced model, got %+v", cost)
+		}
+	})
+}
+
+func floatEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
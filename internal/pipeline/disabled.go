@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// SetProviderEnabled switches a provider on or off for new requests. Requests
+// routed to a disabled provider fail over to the default route.
+func (p *Pipeline) SetProviderEnabled(name string, enabled bool) {
+	p.disabledMu.Lock()
+	defer p.disabledMu.Unlock()
+
+	if enabled {
+		delete(p.disabled, name)
+		return
+	}
+	if p.disabled == nil {
+		p.disabled = make(map[string]bool)
+	}
+	p.disabled[name] = true
+}
+
+// ResetProviderStates re-enables every provider disabled at runtime
+func (p *Pipeline) ResetProviderStates() {
+	p.disabledMu.Lock()
+	defer p.disabledMu.Unlock()
+	p.disabled = nil
+}
+
+// providerDisabled reports whether a provider was disabled at runtime
+func (p *Pipeline) providerDisabled(name string) bool {
+	p.disabledMu.RLock()
+	defer p.disabledMu.RUnlock()
+	return p.disabled[name]
+}
+
+// applyProviderState redirects a routing decision for a disabled provider to
+// the default route, failing with a 503 when the default route's provider is
+// disabled as well
+func (p *Pipeline) applyProviderState(decision router.RouteDecision) (router.RouteDecision, error) {
+	if !p.providerDisabled(decision.Provider) {
+		return decision, nil
+	}
+
+	fallback, ok := p.router.DefaultRoute()
+	if !ok || p.providerDisabled(fallback.Provider) {
+		return decision, ccerrors.Newf(ccerrors.ErrorTypeServiceUnavailable,
+			"provider %s is disabled and no enabled default route is available", decision.Provider)
+	}
+
+	utils.GetLogger().Warnf("Provider %s is disabled, falling back to provider=%s, model=%s",
+		decision.Provider, fallback.Provider, fallback.Model)
+	fallback.Reason = decision.Reason + ", provider disabled"
+	return fallback, nil
+}
@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestPipeline_ApplyProviderState(t *testing.T) {
+	cfg := &config.Config{
+		Routes: map[string]config.Route{
+			"default": {Provider: "openai", Model: "gpt-4o"},
+		},
+	}
+	p := &Pipeline{router: router.New(cfg)}
+	decision := router.RouteDecision{Provider: "groq", Model: "llama-3.3-70b-versatile", Reason: "direct model route"}
+
+	got, err := p.applyProviderState(decision)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, "groq", got.Provider)
+
+	// A disabled provider fails over to the default route
+	p.SetProviderEnabled("groq", false)
+	got, err = p.applyProviderState(decision)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, "openai", got.Provider)
+	testutil.AssertEqual(t, "gpt-4o", got.Model)
+	testutil.AssertEqual(t, "direct model route, provider disabled", got.Reason)
+
+	// With the default provider disabled too the request fails cleanly
+	p.SetProviderEnabled("openai", false)
+	_, err = p.applyProviderState(decision)
+	testutil.AssertError(t, err)
+	var ccErr *ccerrors.CCProxyError
+	testutil.AssertTrue(t, errors.As(err, &ccErr))
+	testutil.AssertEqual(t, ccerrors.ErrorTypeServiceUnavailable, ccErr.Type)
+	testutil.AssertEqual(t, 503, ccErr.StatusCode)
+
+	p.SetProviderEnabled("groq", true)
+	got, err = p.applyProviderState(decision)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, "groq", got.Provider)
+
+	p.ResetProviderStates()
+	testutil.AssertFalse(t, p.providerDisabled("openai"))
+}
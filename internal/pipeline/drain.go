@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// shutdownErrorData is the Anthropic error event sent to streams that are
+// still open when the drain timeout expires
+const shutdownErrorData = `{"type":"error","error":{"type":"overloaded_error","message":"Server is shutting down"}}`
+
+// activeStream is an in-flight stream that shutdown can notify or cancel
+type activeStream struct {
+	writer *transformer.SSEWriter
+	cancel context.CancelFunc
+}
+
+// streamTracker tracks in-flight streams so shutdown can wait for them
+type streamTracker struct {
+	mu       sync.Mutex
+	streams  map[*activeStream]struct{}
+	draining bool
+	idle     chan struct{} // Closed once draining and no streams remain
+}
+
+// newStreamTracker creates an empty stream tracker
+func newStreamTracker() *streamTracker {
+	return &streamTracker{
+		streams: make(map[*activeStream]struct{}),
+		idle:    make(chan struct{}),
+	}
+}
+
+// add registers a stream until the returned function is called
+func (t *streamTracker) add(writer *transformer.SSEWriter, cancel context.CancelFunc) func() {
+	stream := &activeStream{writer: writer, cancel: cancel}
+
+	t.mu.Lock()
+	t.streams[stream] = struct{}{}
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		delete(t.streams, stream)
+		if t.draining && len(t.streams) == 0 {
+			t.closeIdle()
+		}
+	}
+}
+
+// count returns the number of in-flight streams
+func (t *streamTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.streams)
+}
+
+// snapshot returns the in-flight streams
+func (t *streamTracker) snapshot() []*activeStream {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	streams := make([]*activeStream, 0, len(t.streams))
+	for stream := range t.streams {
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// startDrain marks the tracker as draining and returns a channel that is
+// closed once no streams remain
+func (t *streamTracker) startDrain() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.draining {
+		t.draining = true
+		if len(t.streams) == 0 {
+			t.closeIdle()
+		}
+	}
+	return t.idle
+}
+
+// closeIdle closes the idle channel. Callers must hold t.mu.
+func (t *streamTracker) closeIdle() {
+	select {
+	case <-t.idle:
+	default:
+		close(t.idle)
+	}
+}
+
+// ActiveStreams returns the number of streams currently being sent to clients
+func (p *StreamingProcessor) ActiveStreams() int {
+	return p.streams.count()
+}
+
+// Drain tells in-flight streams the server is shutting down and waits for
+// them to finish. Streams still open when ctx is done get a final error
+// event and are closed, and ctx's error is returned.
+func (p *StreamingProcessor) Drain(ctx context.Context) error {
+	idle := p.streams.startDrain()
+
+	streams := p.streams.snapshot()
+	if len(streams) > 0 {
+		utils.GetLogger().Infof("Draining %d active streams", len(streams))
+	}
+	for _, stream := range streams {
+		// Clients ignore comments, so the stream carries on undisturbed
+		_ = stream.writer.WriteComment("server shutting down") // Safe to ignore: client may be gone
+	}
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+	}
+
+	streams = p.streams.snapshot()
+	utils.GetLogger().Warnf("Drain timed out, closing %d active streams", len(streams))
+	for _, stream := range streams {
+		// Safe to ignore: the stream is being closed either way
+		_ = stream.writer.WriteEvent(&transformer.SSEEvent{Event: "error", Data: shutdownErrorData})
+		_ = stream.writer.Close()
+		stream.cancel()
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestStreamingProcessor_Drain(t *testing.T) {
+	// stream sends one event and finishes once release is closed
+	stream := func(release <-chan struct{}) *http.Response {
+		body, upstream := io.Pipe()
+		go func() {
+			upstream.Write([]byte("data: first\n\n"))
+			<-release
+			upstream.Write([]byte("data: second\n\ndata: [DONE]\n\n"))
+			upstream.Close()
+		}()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       body,
+		}
+	}
+
+	// start runs a stream in the background and waits until it is tracked
+	start := func(t *testing.T, processor *StreamingProcessor, release <-chan struct{}) (*httptest.ResponseRecorder, <-chan error) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		done := make(chan error, 1)
+		go func() {
+			done <- processor.ProcessStreamingResponse(context.Background(), w, stream(release), "openai")
+		}()
+
+		deadline := time.Now().Add(time.Second)
+		for processor.ActiveStreams() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("Stream was never tracked")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return w, done
+	}
+
+	t.Run("NoStreams", func(t *testing.T) {
+		processor := NewStreamingProcessor(transformer.NewService())
+		if err := processor.Drain(context.Background()); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("WaitsForStreams", func(t *testing.T) {
+		processor := NewStreamingProcessor(transformer.NewService())
+		release := make(chan struct{})
+		w, done := start(t, processor, release)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := processor.Drain(ctx); err != nil {
+			t.Fatalf("Unexpected drain error: %v", err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("Unexpected stream error: %v", err)
+		}
+
+		body := w.Body.String()
+		if !strings.Contains(body, ": server shutting down\n\n") {
+			t.Errorf("Expected a shutdown notice, got %q", body)
+		}
+		if !strings.Contains(body, "data: [DONE]") {
+			t.Errorf("Expected the stream to complete, got %q", body)
+		}
+		if processor.ActiveStreams() != 0 {
+			t.Errorf("Expected no active streams, got %d", processor.ActiveStreams())
+		}
+	})
+
+	t.Run("ClosesStreamsOnTimeout", func(t *testing.T) {
+		processor := NewStreamingProcessor(transformer.NewService())
+		release := make(chan struct{})
+		defer close(release)
+		w, done := start(t, processor, release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := processor.Drain(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("Expected deadline exceeded, got %v", err)
+		}
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Stream was not closed after the drain timed out")
+		}
+
+		body := w.Body.String()
+		if !strings.Contains(body, "event: error\ndata: "+shutdownErrorData) {
+			t.Errorf("Expected a final shutdown error event, got %q", body)
+		}
+		if strings.Contains(body, "data: second") {
+			t.Errorf("Expected no events after the stream was closed, got %q", body)
+		}
+	})
+}
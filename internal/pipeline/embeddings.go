@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/performance"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// embeddingsEndpoints maps providers to their OpenAI-compatible embeddings
+// endpoint. Providers missing here fall back to /v1/embeddings.
+var embeddingsEndpoints = map[string]string{
+	"groq":       "/openai/v1/embeddings",
+	"openrouter": "/api/v1/embeddings",
+}
+
+// noEmbeddingsProviders cannot serve embeddings requests
+var noEmbeddingsProviders = map[string]bool{
+	"anthropic": true,
+	"deepseek":  true,
+	"vertex":    true,
+}
+
+// ProcessEmbeddings sends an OpenAI embeddings request to the provider and
+// model of the embeddings route. Gemini requests are converted to its
+// batchEmbedContents API, other providers receive the request unchanged.
+func (p *Pipeline) ProcessEmbeddings(ctx context.Context, req *RequestContext) (*ResponseContext, error) {
+	bodyMap, ok := req.Body.(map[string]interface{})
+	if !ok {
+		return nil, ccerrors.New(ccerrors.ErrorTypeBadRequest, "invalid request format")
+	}
+
+	decision, ok := p.router.RouteEmbeddings()
+	if !ok {
+		return nil, ccerrors.New(ccerrors.ErrorTypeNotFound, "no embeddings route is configured")
+	}
+
+	// Disabled providers and used up budgets apply as they do to messages
+	decision, err := p.applyProviderState(decision)
+	if err != nil {
+		return nil, err
+	}
+	if decision, err = p.applyBudget(decision); err != nil {
+		return nil, err
+	}
+	if noEmbeddingsProviders[decision.Provider] {
+		return nil, ccerrors.Newf(ccerrors.ErrorTypeBadRequest, "provider %s does not support embeddings", decision.Provider)
+	}
+
+	// The route's model replaces the client's unless the route leaves it unset
+	if decision.Model != "" {
+		bodyMap["model"] = decision.Model
+	}
+	model, _ := bodyMap["model"].(string)
+	if model == "" {
+		return nil, ccerrors.New(ccerrors.ErrorTypeBadRequest, "field 'model' is required when the embeddings route sets no model")
+	}
+
+	if err := p.checkModelAccess(req, model); err != nil {
+		return nil, fmt.Errorf("model access denied: %w", err)
+	}
+
+	selectedProvider, err := p.providerService.GetProvider(decision.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("provider not found: %s", decision.Provider)
+	}
+
+	// Build the request against the embeddings endpoint
+	var body interface{} = bodyMap
+	var endpoint string
+	switch decision.Provider {
+	case "gemini":
+		body, err = transformer.NewGeminiEmbeddingsTransformer().TransformRequestIn(ctx, bodyMap, decision.Provider)
+		if err != nil {
+			return nil, ccerrors.Wrap(err, ccerrors.ErrorTypeBadRequest, "invalid embeddings request")
+		}
+		endpoint = transformer.GeminiEmbeddingsEndpoint(selectedProvider.GeminiAPIVersion(), model)
+	case "azure":
+		endpoint = azureDeploymentEndpoint(selectedProvider, bodyMap, "embeddings")
+	default:
+		endpoint = "/v1/embeddings"
+		if custom, exists := embeddingsEndpoints[decision.Provider]; exists {
+			endpoint = custom
+		}
+	}
+
+	url := selectedProvider.EndpointURL(endpoint)
+	httpReq, err := p.buildHTTPRequest(ctx, selectedProvider, &transformer.RequestConfig{Body: body, URL: url}, false, decision.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP request: %w", err)
+	}
+	setRequestID(httpReq, req)
+
+	release, err := p.acquireSlot(ctx, selectedProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	httpResp, err := p.sendRequest(httpReq, selectedProvider, false)
+	duration := time.Since(startTime)
+	atomic.AddInt64(&p.requestCounter, 1)
+
+	if p.performanceMonitor != nil {
+		p.performanceMonitor.RecordRequest(performance.RequestMetrics{
+			Provider:  selectedProvider.Name,
+			StartTime: startTime,
+			EndTime:   time.Now(),
+			Latency:   duration,
+			Success:   err == nil,
+			Error:     err,
+		})
+	}
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("provider request failed: %w", err)
+	}
+	httpResp.Body = &releaseOnCloseBody{ReadCloser: httpResp.Body, release: release}
+	upstreamID := upstreamRequestID(httpResp)
+
+	if decision.Provider == "gemini" {
+		httpResp, err = transformer.NewGeminiEmbeddingsTransformer().TransformResponseOut(ctx, httpResp)
+		if err != nil {
+			return nil, fmt.Errorf("response transformation failed: %w", err)
+		}
+	}
+
+	// Pass the provider's usage through and log the cost for priced models
+	var cost *CostBreakdown
+	usage, ok := responseUsage(httpResp)
+	if ok {
+		cost = logCost(selectedProvider, model, usage.Input, 0)
+	}
+	tokenCount := embeddingsInputTokens(bodyMap["input"])
+	if httpResp.StatusCode < http.StatusBadRequest {
+		p.recordBudgetUsage(decision.Provider, budgetTokens(usage, tokenCount), cost)
+	}
+
+	return &ResponseContext{
+		Response:        httpResp,
+		Provider:        decision.Provider,
+		Model:           model,
+		TokenCount:      tokenCount,
+		RoutingStrategy: decision.Reason,
+		UpstreamID:      upstreamID,
+		InputTokens:     usage.Input,
+		Cost:            cost,
+	}, nil
+}
+
+// embeddingsInputTokens estimates the tokens in an embeddings input string or
+// array of strings
+func embeddingsInputTokens(input interface{}) int {
+	var texts []string
+	switch v := input.(type) {
+	case string:
+		texts = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if text, ok := item.(string); ok {
+				texts = append(texts, text)
+			}
+		}
+	}
+
+	total := 0
+	for _, text := range texts {
+		if count, err := utils.CountTokens(text); err == nil {
+			total += count
+		}
+	}
+	return total
+}
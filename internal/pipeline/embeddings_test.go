@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_ProcessEmbeddings(t *testing.T) {
+	var upstreamPath string
+	var upstreamBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPath = r.URL.Path
+		upstreamBody = nil
+		json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v1beta/models/text-embedding-004:batchEmbedContents" {
+			w.Write([]byte(`{"embeddings":[{"values":[0.5,0.25]}]}`))
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1]}],` +
+			`"model":"text-embedding-3-small","usage":{"prompt_tokens":7,"total_tokens":7}}`))
+	}))
+	defer server.Close()
+
+	// newPipeline creates a pipeline whose routes send embeddings to route
+	newPipeline := func(t *testing.T, route *config.Route) *Pipeline {
+		t.Helper()
+		cfg := &config.Config{
+			Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+			Providers: []config.Provider{
+				{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"},
+				{Name: "gemini", APIBaseURL: server.URL, APIKey: "test-key"},
+				{Name: "anthropic", APIBaseURL: server.URL, APIKey: "test-key"},
+			},
+			Routes: map[string]config.Route{
+				"default": {Provider: "openai", Model: "gpt-4"},
+			},
+		}
+		if route != nil {
+			cfg.Routes[router.EmbeddingsRoute] = *route
+		}
+
+		configService := config.NewService()
+		configService.SetConfig(cfg)
+		providerService := providers.NewService(configService)
+		if err := providerService.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize provider service: %v", err)
+		}
+		return NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+	}
+
+	newRequest := func() *RequestContext {
+		return &RequestContext{
+			Body:     map[string]interface{}{"model": "any", "input": "hello world"},
+			Headers:  map[string]string{},
+			Metadata: map[string]interface{}{},
+		}
+	}
+
+	t.Run("OpenAIPassthrough", func(t *testing.T) {
+		p := newPipeline(t, &config.Route{Provider: "openai", Model: "text-embedding-3-small"})
+
+		respCtx, err := p.ProcessEmbeddings(context.Background(), newRequest())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+
+		if upstreamPath != "/v1/embeddings" {
+			t.Errorf("Expected /v1/embeddings, got %s", upstreamPath)
+		}
+		if upstreamBody["model"] != "text-embedding-3-small" || upstreamBody["input"] != "hello world" {
+			t.Errorf("Expected routed model and original input upstream, got %v", upstreamBody)
+		}
+		if respCtx.InputTokens != 7 {
+			t.Errorf("Expected provider usage of 7 tokens, got %d", respCtx.InputTokens)
+		}
+
+		body, _ := io.ReadAll(respCtx.Response.Body)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(body, &parsed); err != nil || parsed["object"] != "list" {
+			t.Errorf("Expected the provider response unchanged, got %s", body)
+		}
+	})
+
+	t.Run("GeminiConverted", func(t *testing.T) {
+		p := newPipeline(t, &config.Route{Provider: "gemini", Model: "text-embedding-004"})
+
+		respCtx, err := p.ProcessEmbeddings(context.Background(), newRequest())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+
+		if upstreamPath != "/v1beta/models/text-embedding-004:batchEmbedContents" {
+			t.Errorf("Expected batchEmbedContents endpoint, got %s", upstreamPath)
+		}
+		if _, ok := upstreamBody["requests"].([]interface{}); !ok {
+			t.Errorf("Expected a batchEmbedContents body, got %v", upstreamBody)
+		}
+
+		var parsed struct {
+			Model string `json:"model"`
+			Data  []struct {
+				Embedding []float64 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(respCtx.Response.Body).Decode(&parsed); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if parsed.Model != "text-embedding-004" || len(parsed.Data) != 1 || parsed.Data[0].Embedding[0] != 0.5 {
+			t.Errorf("Expected converted Gemini embeddings, got %+v", parsed)
+		}
+	})
+
+	t.Run("NoRoute", func(t *testing.T) {
+		p := newPipeline(t, nil)
+
+		_, err := p.ProcessEmbeddings(context.Background(), newRequest())
+		var ccErr *ccerrors.CCProxyError
+		if !errors.As(err, &ccErr) || ccErr.Type != ccerrors.ErrorTypeNotFound {
+			t.Errorf("Expected a not found error, got %v", err)
+		}
+	})
+
+	t.Run("DisabledProvider", func(t *testing.T) {
+		p := newPipeline(t, &config.Route{Provider: "gemini", Model: "text-embedding-004"})
+		p.SetProviderEnabled("gemini", false)
+
+		respCtx, err := p.ProcessEmbeddings(context.Background(), newRequest())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		respCtx.Response.Body.Close()
+		if respCtx.Provider != "openai" || upstreamPath != "/v1/embeddings" {
+			t.Errorf("Expected the request to fall back to the default route, got %s at %s", respCtx.Provider, upstreamPath)
+		}
+
+		p.SetProviderEnabled("openai", false)
+		_, err = p.ProcessEmbeddings(context.Background(), newRequest())
+		var ccErr *ccerrors.CCProxyError
+		if !errors.As(err, &ccErr) || ccErr.Type != ccerrors.ErrorTypeServiceUnavailable {
+			t.Errorf("Expected a service unavailable error, got %v", err)
+		}
+	})
+
+	t.Run("Budget", func(t *testing.T) {
+		p := newPipeline(t, &config.Route{Provider: "openai", Model: "text-embedding-3-small"})
+		p.budget = newBudgetTracker(budgetConfig(config.BudgetConfig{}, map[string]*config.ProviderBudget{
+			"openai": {MaxTokens: 7},
+		}))
+
+		respCtx, err := p.ProcessEmbeddings(context.Background(), newRequest())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		respCtx.Response.Body.Close()
+		if used := p.Budget().Providers["openai"].Tokens; used != 7 {
+			t.Errorf("Expected the reported usage to count against the budget, got %d tokens", used)
+		}
+
+		if _, err := p.ProcessEmbeddings(context.Background(), newRequest()); err == nil {
+			t.Error("Expected the request to be rejected once the budget is used up")
+		}
+	})
+
+	t.Run("UnsupportedProvider", func(t *testing.T) {
+		p := newPipeline(t, &config.Route{Provider: "anthropic", Model: "claude-3-haiku"})
+
+		_, err := p.ProcessEmbeddings(context.Background(), newRequest())
+		var ccErr *ccerrors.CCProxyError
+		if !errors.As(err, &ccErr) || ccErr.Type != ccerrors.ErrorTypeBadRequest {
+			t.Errorf("Expected a bad request error, got %v", err)
+		}
+	})
+}
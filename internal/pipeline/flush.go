@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// batchFlusher sits between an SSE writer and the response, turning the
+// writer's per-event flushes into one flush per size events. A pending event
+// is never held longer than interval, and Stop flushes whatever is left, so
+// the end of a stream always reaches the client.
+type batchFlusher struct {
+	writer   io.Writer
+	flusher  http.Flusher
+	size     int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending int
+	timer   *time.Timer
+	stopped bool
+}
+
+// newBatchFlusher batches flushes of w, which must be an http.Flusher
+func newBatchFlusher(w io.Writer, flusher http.Flusher, size int, interval time.Duration) *batchFlusher {
+	return &batchFlusher{writer: w, flusher: flusher, size: size, interval: interval}
+}
+
+// Write writes to the response without flushing
+func (b *batchFlusher) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.writer.Write(p)
+}
+
+// Flush counts an event and flushes once the batch is full. The first event
+// of a batch starts the interval timer.
+func (b *batchFlusher) Flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		b.flusher.Flush()
+		return
+	}
+	b.pending++
+	if b.pending >= b.size {
+		b.flushLocked()
+		return
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, b.flushPending)
+	}
+}
+
+// flushPending flushes a batch that did not fill up within the interval
+func (b *batchFlusher) flushPending() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.stopped && b.pending > 0 {
+		b.flushLocked()
+	}
+}
+
+// flushLocked flushes the response and starts a new batch
+func (b *batchFlusher) flushLocked() {
+	b.flusher.Flush()
+	b.pending = 0
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+// Stop flushes pending events and passes later flushes straight through
+func (b *batchFlusher) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushLocked()
+	b.stopped = true
+}
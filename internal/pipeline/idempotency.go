@@ -0,0 +1,242 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// IdempotencyHeader is the request header carrying a client-chosen idempotency key
+const IdempotencyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader marks responses served from the idempotency cache
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+// CachedResponse is a completed response kept for idempotent replay
+type CachedResponse struct {
+	StatusCode      int
+	Header          http.Header
+	Body            []byte
+	Provider        string
+	Model           string
+	TokenCount      int
+	InputTokens     int
+	OutputTokens    int
+	RoutingStrategy string
+
+	CacheReadTokens     int
+	CacheCreationTokens int
+}
+
+// IdempotencyStore keeps completed responses by idempotency key
+type IdempotencyStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse, ttl time.Duration)
+}
+
+// MemoryIdempotencyStore is the default in-process IdempotencyStore
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryIdempotencyEntry
+}
+
+type memoryIdempotencyEntry struct {
+	resp      *CachedResponse
+	expiresAt time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory idempotency store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]memoryIdempotencyEntry),
+	}
+}
+
+// Get returns the cached response for key if it has not expired
+func (s *MemoryIdempotencyStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.entries[key]
+	if !exists {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return entry.resp, true
+}
+
+// Set caches resp under key for ttl, sweeping expired entries as it goes
+func (s *MemoryIdempotencyStore) Set(key string, resp *CachedResponse, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+
+	s.entries[key] = memoryIdempotencyEntry{
+		resp:      resp,
+		expiresAt: now.Add(ttl),
+	}
+}
+
+// inflightRequest tracks a request that duplicates are waiting on
+type inflightRequest struct {
+	done chan struct{}
+	resp *CachedResponse
+	err  error
+}
+
+// SetIdempotencyStore replaces the store used for Idempotency-Key responses
+func (p *Pipeline) SetIdempotencyStore(store IdempotencyStore) {
+	p.idempotencyStore = store
+}
+
+// idempotencyKey returns the store key for a request, or "" when the request
+// is not eligible. Keys are scoped to the caller's credentials so clients
+// cannot replay each other's responses.
+func (p *Pipeline) idempotencyKey(req *RequestContext) string {
+	if req.IsStreaming || p.idempotencyStore == nil || p.currentConfig().Performance.IdempotencyTTL <= 0 {
+		return ""
+	}
+
+	key := req.Headers[IdempotencyHeader]
+	if key == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(req.Headers["Authorization"] + "\x00" + req.Headers["X-Api-Key"] + "\x00" + key))
+	return hex.EncodeToString(hash[:])
+}
+
+// processIdempotent serves a request from the idempotency cache, waits for an
+// identical in-flight request, or processes it and caches the result
+func (p *Pipeline) processIdempotent(ctx context.Context, req *RequestContext, key string) (*ResponseContext, error) {
+	if cached, ok := p.idempotencyStore.Get(key); ok {
+		utils.GetLogger().Debug("Replaying cached response for idempotency key")
+		return cached.responseContext(true), nil
+	}
+
+	p.inflightMu.Lock()
+	if inflight, exists := p.inflight[key]; exists {
+		p.inflightMu.Unlock()
+
+		select {
+		case <-inflight.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		// Errors are never shared, the duplicate gets its own attempt
+		if inflight.err != nil {
+			return p.processIdempotent(ctx, req, key)
+		}
+		return inflight.resp.responseContext(true), nil
+	}
+
+	// The previous holder may have finished since the cache lookup
+	if cached, ok := p.idempotencyStore.Get(key); ok {
+		p.inflightMu.Unlock()
+		return cached.responseContext(true), nil
+	}
+
+	inflight := &inflightRequest{done: make(chan struct{})}
+	p.inflight[key] = inflight
+	p.inflightMu.Unlock()
+
+	defer func() {
+		p.inflightMu.Lock()
+		delete(p.inflight, key)
+		p.inflightMu.Unlock()
+		close(inflight.done)
+	}()
+
+	respCtx, err := p.processRequest(ctx, req)
+	if err != nil {
+		inflight.err = err
+		return nil, err
+	}
+
+	cached, err := newCachedResponse(respCtx)
+	if err != nil {
+		inflight.err = err
+		return nil, err
+	}
+	inflight.resp = cached
+
+	// Only successful responses are worth replaying
+	if cached.StatusCode >= 200 && cached.StatusCode < 300 {
+		p.idempotencyStore.Set(key, cached, p.currentConfig().Performance.IdempotencyTTL)
+	}
+
+	return cached.responseContext(false), nil
+}
+
+// newCachedResponse buffers a response so it can be replayed
+func newCachedResponse(respCtx *ResponseContext) (*CachedResponse, error) {
+	resp := respCtx.Response
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for idempotency cache: %w", err)
+	}
+
+	return &CachedResponse{
+		StatusCode:      resp.StatusCode,
+		Header:          resp.Header.Clone(),
+		Body:            body,
+		Provider:        respCtx.Provider,
+		Model:           respCtx.Model,
+		TokenCount:      respCtx.TokenCount,
+		InputTokens:     respCtx.InputTokens,
+		OutputTokens:    respCtx.OutputTokens,
+		RoutingStrategy: respCtx.RoutingStrategy,
+
+		CacheReadTokens:     respCtx.CacheReadTokens,
+		CacheCreationTokens: respCtx.CacheCreationTokens,
+	}, nil
+}
+
+// responseContext builds a fresh response context from the cached response
+func (c *CachedResponse) responseContext(replayed bool) *ResponseContext {
+	header := c.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if replayed {
+		header.Set(IdempotentReplayHeader, "true")
+	}
+
+	return &ResponseContext{
+		Response: &http.Response{
+			StatusCode:    c.StatusCode,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(c.Body)),
+			ContentLength: int64(len(c.Body)),
+		},
+		Provider:        c.Provider,
+		Model:           c.Model,
+		TokenCount:      c.TokenCount,
+		InputTokens:     c.InputTokens,
+		OutputTokens:    c.OutputTokens,
+		RoutingStrategy: c.RoutingStrategy,
+
+		CacheReadTokens:     c.CacheReadTokens,
+		CacheCreationTokens: c.CacheCreationTokens,
+	}
+}
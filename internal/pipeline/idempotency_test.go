@@ -0,0 +1,270 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	resp := &CachedResponse{StatusCode: http.StatusOK, Body: []byte("ok")}
+
+	if _, ok := store.Get("missing"); ok {
+		t.Error("Expected miss for unknown key")
+	}
+
+	store.Set("key", resp, time.Minute)
+	if cached, ok := store.Get("key"); !ok || cached != resp {
+		t.Error("Expected cached response for stored key")
+	}
+
+	store.Set("short", resp, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := store.Get("short"); ok {
+		t.Error("Expected expired entry to be dropped")
+	}
+}
+
+func TestPipeline_Idempotency(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	blocking := int32(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&blocking) == 1 {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"choices": [{"message": {"content": "Hello"}}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{
+			RequestTimeout: 30 * time.Second,
+			IdempotencyTTL: time.Minute,
+		},
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"},
+		},
+		Routes: map[string]config.Route{
+			"default": {Provider: "openai", Model: "gpt-4"},
+		},
+	}
+
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	if err := providerService.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize provider service: %v", err)
+	}
+	pipeline := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+
+	newRequest := func(headers map[string]string, streaming bool) *RequestContext {
+		return &RequestContext{
+			Body: map[string]interface{}{
+				"model": "gpt-4",
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": "Hello"},
+				},
+			},
+			Headers:     headers,
+			IsStreaming: streaming,
+		}
+	}
+
+	process := func(t *testing.T, req *RequestContext) *ResponseContext {
+		t.Helper()
+		respCtx, err := pipeline.ProcessRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return respCtx
+	}
+
+	readBody := func(t *testing.T, respCtx *ResponseContext) string {
+		t.Helper()
+		defer respCtx.Response.Body.Close()
+		data, err := io.ReadAll(respCtx.Response.Body)
+		if err != nil {
+			t.Fatalf("Failed to read body: %v", err)
+		}
+		return string(data)
+	}
+
+	t.Run("DuplicateReplaysCachedResponse", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		headers := map[string]string{IdempotencyHeader: "retry-1", "Authorization": "Bearer a"}
+
+		first := process(t, newRequest(headers, false))
+		second := process(t, newRequest(headers, false))
+
+		if got := atomic.LoadInt32(&calls); got != 1 {
+			t.Errorf("Expected 1 upstream call, got %d", got)
+		}
+		if readBody(t, first) != readBody(t, second) {
+			t.Error("Expected replay to return the original body")
+		}
+		if first.Response.Header.Get(IdempotentReplayHeader) != "" {
+			t.Error("Expected original response not to be marked as replayed")
+		}
+		if second.Response.Header.Get(IdempotentReplayHeader) != "true" {
+			t.Error("Expected replayed response to be marked")
+		}
+		if second.Provider != "openai" || second.Model != "gpt-4" {
+			t.Errorf("Expected routing details on replay, got %s/%s", second.Provider, second.Model)
+		}
+	})
+
+	t.Run("KeysScopedByCredentials", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+
+		process(t, newRequest(map[string]string{IdempotencyHeader: "shared", "Authorization": "Bearer a"}, false))
+		process(t, newRequest(map[string]string{IdempotencyHeader: "shared", "Authorization": "Bearer b"}, false))
+
+		if got := atomic.LoadInt32(&calls); got != 2 {
+			t.Errorf("Expected separate upstream calls per credential, got %d", got)
+		}
+	})
+
+	t.Run("WithoutKeyNotCached", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+
+		process(t, newRequest(map[string]string{}, false))
+		process(t, newRequest(map[string]string{}, false))
+
+		if got := atomic.LoadInt32(&calls); got != 2 {
+			t.Errorf("Expected 2 upstream calls without a key, got %d", got)
+		}
+	})
+
+	t.Run("StreamingExcluded", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		headers := map[string]string{IdempotencyHeader: "stream-1"}
+
+		readBody(t, process(t, newRequest(headers, true)))
+		readBody(t, process(t, newRequest(headers, true)))
+
+		if got := atomic.LoadInt32(&calls); got != 2 {
+			t.Errorf("Expected streaming requests to bypass the cache, got %d calls", got)
+		}
+	})
+
+	t.Run("DuplicateWaitsForInflight", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		atomic.StoreInt32(&blocking, 1)
+		defer atomic.StoreInt32(&blocking, 0)
+		headers := map[string]string{IdempotencyHeader: "inflight-1"}
+
+		var wg sync.WaitGroup
+		bodies := make([]string, 3)
+		for i := range bodies {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				respCtx, err := pipeline.ProcessRequest(context.Background(), newRequest(headers, false))
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				data, _ := io.ReadAll(respCtx.Response.Body)
+				bodies[i] = string(data)
+			}(i)
+		}
+
+		// Give the duplicates time to attach to the in-flight request
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if got := atomic.LoadInt32(&calls); got != 1 {
+			t.Errorf("Expected duplicates to share 1 upstream call, got %d", got)
+		}
+		for i, body := range bodies {
+			if body != bodies[0] || body == "" {
+				t.Errorf("Expected identical bodies, got %q for request %d", body, i)
+			}
+		}
+	})
+
+	t.Run("DisabledWithoutTTL", func(t *testing.T) {
+		cfg.Performance.IdempotencyTTL = 0
+		defer func() { cfg.Performance.IdempotencyTTL = time.Minute }()
+		atomic.StoreInt32(&calls, 0)
+		headers := map[string]string{IdempotencyHeader: "disabled-1"}
+
+		process(t, newRequest(headers, false))
+		process(t, newRequest(headers, false))
+
+		if got := atomic.LoadInt32(&calls); got != 2 {
+			t.Errorf("Expected 2 upstream calls when disabled, got %d", got)
+		}
+	})
+}
+
+func TestPipeline_IdempotencySkipsErrorResponses(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": "overloaded"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"choices": [{"message": {"content": "Hello"}}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{
+			RequestTimeout: 30 * time.Second,
+			IdempotencyTTL: time.Minute,
+		},
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"},
+		},
+		Routes: map[string]config.Route{
+			"default": {Provider: "openai", Model: "gpt-4"},
+		},
+	}
+
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	if err := providerService.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize provider service: %v", err)
+	}
+	pipeline := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+
+	for i, wantStatus := range []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK} {
+		req := &RequestContext{
+			Body:    map[string]interface{}{"model": "gpt-4", "messages": []interface{}{}},
+			Headers: map[string]string{IdempotencyHeader: "flaky-1"},
+		}
+		respCtx, err := pipeline.ProcessRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Request %d: unexpected error: %v", i, err)
+		}
+		if respCtx.Response.StatusCode != wantStatus {
+			t.Errorf("Request %d: expected status %d, got %d", i, wantStatus, respCtx.Response.StatusCode)
+		}
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("Expected the failed attempt to be retried once then cached, got %d calls", got)
+	}
+}
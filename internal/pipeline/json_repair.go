@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// repairJSONResponse replaces the body of a non-streaming response that is
+// not valid JSON with a repaired copy, when repairing makes it valid. Valid
+// bodies, and bodies the repair cannot fix, are left exactly as they were.
+func repairJSONResponse(resp *http.Response, provider string) error {
+	if resp.Body == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close() // Safe to ignore: body is fully buffered
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	body := data
+	if len(bytes.TrimSpace(data)) > 0 && !json.Valid(data) {
+		if repaired := repairJSON(data); json.Valid(repaired) {
+			utils.GetLogger().Warnf("Repaired malformed JSON response from provider %s", provider)
+			body = repaired
+			resp.ContentLength = int64(len(repaired))
+			if resp.Header != nil {
+				resp.Header.Set("Content-Length", strconv.Itoa(len(repaired)))
+			}
+		}
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// repairJSON fixes the mistakes providers make in otherwise valid JSON: a
+// leading byte order mark, trailing commas before a closing bracket, raw
+// control characters inside strings and escapes JSON does not define. The
+// result is not guaranteed to be valid.
+func repairJSON(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	out := make([]byte, 0, len(data)+16)
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		if !inString {
+			switch c {
+			case '"':
+				inString = true
+			case ',':
+				// Drop a comma followed only by whitespace and a closing bracket
+				next := i + 1
+				for next < len(data) && isJSONSpace(data[next]) {
+					next++
+				}
+				if next < len(data) && (data[next] == '}' || data[next] == ']') {
+					continue
+				}
+			}
+			out = append(out, c)
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = false
+			out = append(out, c)
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch next := data[i]; next {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+				out = append(out, '\\', next)
+			case '\'':
+				out = append(out, '\'') // A JavaScript escape, plain in JSON
+			default:
+				out = append(out, '\\', '\\') // Keep the backslash as a character
+				i--
+			}
+		case c < 0x20:
+			switch c {
+			case '\n':
+				out = append(out, '\\', 'n')
+			case '\r':
+				out = append(out, '\\', 'r')
+			case '\t':
+				out = append(out, '\\', 't')
+			default:
+				out = append(out, fmt.Sprintf("\\u%04x", c)...)
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// isJSONSpace reports whether c is whitespace between JSON tokens
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
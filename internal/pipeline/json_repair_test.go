@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{"trailing comma in object", `{"a": 1, "b": 2,}`, `{"a": 1, "b": 2}`},
+		{"trailing comma in array", `{"a": [1, 2, ]}`, `{"a": [1, 2 ]}`},
+		{"trailing comma before newline", "{\"a\": [1,\n]}", "{\"a\": [1\n]}"},
+		{"raw newline in string", "{\"text\": \"line one\nline two\"}", `{"text": "line one\nline two"}`},
+		{"raw tab and control character", "{\"text\": \"a\tb\x01\"}", `{"text": "a\tb\u0001"}`},
+		{"javascript quote escape", `{"text": "it\'s"}`, `{"text": "it's"}`},
+		{"unknown escape", `{"path": "C:\data"}`, `{"path": "C:\\data"}`},
+		{"byte order mark", "\xef\xbb\xbf{\"a\": 1}", `{"a": 1}`},
+		{"commas inside strings kept", `{"text": "a,}", "b": [1,],}`, `{"text": "a,}", "b": [1]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(repairJSON([]byte(tt.body)))
+			if got != tt.want {
+				t.Errorf("repairJSON(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("Expected valid JSON, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRepairJSONResponse(t *testing.T) {
+	respond := func(body string) *http.Response {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": []string{"application/json"}, "Content-Length": []string{"0"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+		}
+	}
+
+	t.Run("RepairsMalformed", func(t *testing.T) {
+		resp := respond(`{"choices": [{"message": {"content": "hi"}},],}`)
+		if err := repairJSONResponse(resp, "test"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != `{"choices": [{"message": {"content": "hi"}}]}` {
+			t.Errorf("Unexpected repaired body %s", body)
+		}
+		if resp.ContentLength != int64(len(body)) || resp.Header.Get("Content-Length") != "45" {
+			t.Errorf("Expected lengths of the repaired body, got %d and %s", resp.ContentLength, resp.Header.Get("Content-Length"))
+		}
+	})
+
+	// Valid JSON, including whitespace and escapes the repair would touch if
+	// it ran, and bodies the repair cannot fix come back byte for byte
+	for _, body := range []string{
+		"{ \"a\" : [ 1 , 2 ] ,\n \"b\": \"x\\u0001\" }",
+		`[]`,
+		`{"text": "unterminated`,
+		`not json at all`,
+		``,
+	} {
+		resp := respond(body)
+		if err := repairJSONResponse(resp, "test"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		if string(got) != body || resp.ContentLength != int64(len(body)) {
+			t.Errorf("Expected %q unchanged, got %q", body, got)
+		}
+	}
+}
+
+func TestPipeline_RepairJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{\"id\": \"chatcmpl-1\", \"model\": \"gpt-4\", \"choices\": [{\"index\": 0, \"message\": {\"role\": \"assistant\", \"content\": \"one\ntwo\"}, \"finish_reason\": \"stop\"},]}"))
+	}))
+	defer server.Close()
+
+	newPipeline := func(t *testing.T, repair bool) *Pipeline {
+		t.Helper()
+		cfg := &config.Config{
+			Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+			Providers: []config.Provider{
+				{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key", Enabled: true, RepairJSON: repair},
+			},
+			Routes: map[string]config.Route{
+				"default": {Provider: "openai", Model: "gpt-4"},
+			},
+		}
+		configService := config.NewService()
+		configService.SetConfig(cfg)
+		providerService := providers.NewService(configService)
+		if err := providerService.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize provider service: %v", err)
+		}
+		transformerService := transformer.NewService()
+		if err := transformer.RegisterBuiltinTransformers(transformerService); err != nil {
+			t.Fatalf("Failed to register transformers: %v", err)
+		}
+		return NewPipeline(cfg, providerService, transformerService, router.New(cfg))
+	}
+
+	request := func() *RequestContext {
+		return &RequestContext{
+			Body: map[string]interface{}{
+				"model":      "claude-3-sonnet",
+				"max_tokens": float64(100),
+				"messages":   []interface{}{map[string]interface{}{"role": "user", "content": "Count to two"}},
+			},
+			Headers: map[string]string{},
+		}
+	}
+
+	t.Run("Enabled", func(t *testing.T) {
+		respCtx, err := newPipeline(t, true).ProcessRequest(context.Background(), request())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+
+		var message struct {
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.NewDecoder(respCtx.Response.Body).Decode(&message); err != nil {
+			t.Fatalf("Expected a parsed response, got %v", err)
+		}
+		if len(message.Choices) != 1 || message.Choices[0].Message.Content != "one\ntwo" {
+			t.Errorf("Unexpected choices %+v", message.Choices)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		respCtx, err := newPipeline(t, false).ProcessRequest(context.Background(), request())
+		if err == nil {
+			defer respCtx.Response.Body.Close()
+			body, _ := io.ReadAll(respCtx.Response.Body)
+			if json.Valid(body) {
+				t.Errorf("Expected the malformed response not to be repaired, got %s", body)
+			}
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+// streamKeepAlive writes an SSE comment whenever the stream has been silent
+// for the interval, so intermediaries do not drop idle connections while a
+// model is thinking. Every real event restarts the countdown.
+type streamKeepAlive struct {
+	writer   *transformer.SSEWriter
+	interval time.Duration
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+}
+
+// startKeepAlive starts heartbeats on writer, or returns nil when interval
+// is not positive. All methods are safe to call on a nil keepalive.
+func startKeepAlive(writer *transformer.SSEWriter, interval time.Duration) *streamKeepAlive {
+	if interval <= 0 {
+		return nil
+	}
+
+	k := &streamKeepAlive{writer: writer, interval: interval}
+	k.mu.Lock()
+	k.timer = time.AfterFunc(interval, k.beat)
+	k.mu.Unlock()
+	return k
+}
+
+// beat sends one keepalive comment and schedules the next
+func (k *streamKeepAlive) beat() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.stopped {
+		return
+	}
+	if err := k.writer.WriteComment("keepalive"); err != nil {
+		// The client is gone, the stream loop will notice on its next write
+		k.stopped = true
+		return
+	}
+	k.timer.Reset(k.interval)
+}
+
+// Touch restarts the countdown after real data was written
+func (k *streamKeepAlive) Touch() {
+	if k == nil {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !k.stopped {
+		k.timer.Reset(k.interval)
+	}
+}
+
+// Stop stops heartbeats. It waits for a heartbeat in progress, so nothing is
+// written once it returns.
+func (k *streamKeepAlive) Stop() {
+	if k == nil {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.stopped = true
+	k.timer.Stop()
+}
@@ -0,0 +1,22 @@
+package pipeline
+
+// RequestedModelKey is the request metadata key holding the model the client
+// asked for, before routing rewrote it
+const RequestedModelKey = "requested_model"
+
+// maskedModel returns the model reported to clients when model masking is
+// enabled, or "" when responses keep the upstream model. requested is the
+// body's model, used when the metadata does not carry the client's model.
+func (p *Pipeline) maskedModel(req *RequestContext, requested string) string {
+	masking := p.currentConfig().ModelMasking
+	if !masking.Enabled {
+		return ""
+	}
+	if masking.Alias != "" {
+		return masking.Alias
+	}
+	if model, ok := req.Metadata[RequestedModelKey].(string); ok && model != "" {
+		return model
+	}
+	return requested
+}
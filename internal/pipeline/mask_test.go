@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_ModelMasking(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(`data: {"id":"1","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}` + "\n\ndata: [DONE]\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","model":"gpt-4o","choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	newPipeline := func(masking config.ModelMaskingConfig) *Pipeline {
+		cfg := &config.Config{
+			Performance:  config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+			Providers:    []config.Provider{{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"}},
+			Routes:       map[string]config.Route{"default": {Provider: "openai", Model: "gpt-4o"}},
+			ModelMasking: masking,
+		}
+		configService := config.NewService()
+		configService.SetConfig(cfg)
+		providerService := providers.NewService(configService)
+		testutil.AssertNoError(t, providerService.Initialize())
+		return NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+	}
+
+	process := func(p *Pipeline, streaming bool) string {
+		resp, err := p.ProcessRequest(context.Background(), &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "openai,gpt-4o",
+				"stream":   streaming,
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+			},
+			IsStreaming: streaming,
+			Metadata:    map[string]interface{}{RequestedModelKey: "claude-3-opus"},
+		})
+		testutil.AssertNoError(t, err)
+		defer resp.Response.Body.Close()
+		body, _ := io.ReadAll(resp.Response.Body)
+		testutil.AssertEqual(t, "gpt-4o", resp.Model)
+		return string(body)
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		testutil.AssertContains(t, process(newPipeline(config.ModelMaskingConfig{}), false), `"model":"gpt-4o"`)
+	})
+
+	t.Run("RequestedModel", func(t *testing.T) {
+		p := newPipeline(config.ModelMaskingConfig{Enabled: true})
+		for _, streaming := range []bool{false, true} {
+			body := process(p, streaming)
+			testutil.AssertContains(t, body, `"model":"claude-3-opus"`)
+			testutil.AssertFalse(t, strings.Contains(body, "gpt-4o"))
+		}
+	})
+
+	t.Run("Alias", func(t *testing.T) {
+		p := newPipeline(config.ModelMaskingConfig{Enabled: true, Alias: "acme-large"})
+		for _, streaming := range []bool{false, true} {
+			testutil.AssertContains(t, process(p, streaming), `"model":"acme-large"`)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// defaultMockResponse is used when a mock provider has no mock_response
+const defaultMockResponse = "This is a mock response from {{.Model}}."
+
+// mockTemplateData is the data available to mock_response templates
+type mockTemplateData struct {
+	Model  string // Requested model, without the provider prefix
+	Prompt string // Text of the user messages
+}
+
+// mockTransport answers requests in process with OpenAI-format completions,
+// so mock providers run through the same transformers as real ones
+type mockTransport struct {
+	response   *template.Template
+	latency    time.Duration
+	chunkDelay time.Duration
+}
+
+// newMockClient returns a client whose requests are answered by provider's
+// mock settings without any network call
+func newMockClient(provider *config.Provider) (*http.Client, error) {
+	text := provider.MockResponse
+	if text == "" {
+		text = defaultMockResponse
+	}
+	response, err := template.New("mock_response").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mock_response template: %w", err)
+	}
+	return &http.Client{Transport: &mockTransport{
+		response:   response,
+		latency:    provider.MockLatency,
+		chunkDelay: provider.MockChunkDelay,
+	}}, nil
+}
+
+// RoundTrip renders the completion and returns it as JSON, or as an SSE
+// stream of one chunk per word when the request asks for a stream
+func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body map[string]interface{}
+	if req.Body != nil {
+		err := json.NewDecoder(req.Body).Decode(&body)
+		_ = req.Body.Close() // Safe to ignore: body is fully read
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("mock provider: invalid request body: %w", err)
+		}
+	}
+
+	modelName, _ := body["model"].(string)
+	_, model := router.ParseModelString(modelName)
+	var text strings.Builder
+	if err := t.response.Execute(&text, mockTemplateData{Model: model, Prompt: utils.ExtractUserText(body)}); err != nil {
+		return nil, fmt.Errorf("mock provider: failed to render response: %w", err)
+	}
+
+	if err := sleepContext(req.Context(), t.latency); err != nil {
+		return nil, err
+	}
+
+	completion := mockCompletion{
+		id:           fmt.Sprintf("chatcmpl-mock-%d", time.Now().UnixNano()),
+		model:        model,
+		text:         text.String(),
+		inputTokens:  utils.CountRequestTokens(body),
+		outputTokens: utils.EstimateContentTokens(text.String()),
+	}
+
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+
+	if req.Header.Get("Accept") == "text/event-stream" {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(t.writeStream(req.Context(), pw, completion))
+		}()
+		resp.Header.Set("Content-Type", "text/event-stream")
+		resp.Body = pr
+		resp.ContentLength = -1
+		return resp, nil
+	}
+
+	data, err := json.Marshal(completion.message())
+	if err != nil {
+		return nil, fmt.Errorf("mock provider: failed to encode response: %w", err)
+	}
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	return resp, nil
+}
+
+// writeStream writes the completion as OpenAI stream chunks, one per word,
+// followed by a final chunk with the usage and the [DONE] marker
+func (t *mockTransport) writeStream(ctx context.Context, w io.Writer, completion mockCompletion) error {
+	writer := transformer.NewSSEWriter(w)
+
+	for i, word := range strings.SplitAfter(completion.text, " ") {
+		if i > 0 {
+			if err := sleepContext(ctx, t.chunkDelay); err != nil {
+				return err
+			}
+		}
+		if err := writeMockChunk(writer, completion.chunk(map[string]interface{}{"content": word}, nil)); err != nil {
+			return err
+		}
+	}
+
+	final := completion.chunk(map[string]interface{}{}, "stop")
+	final["usage"] = completion.usage()
+	if err := writeMockChunk(writer, final); err != nil {
+		return err
+	}
+	return writer.WriteEvent(&transformer.SSEEvent{Data: "[DONE]"})
+}
+
+// writeMockChunk writes one stream chunk as an SSE data event
+func writeMockChunk(writer *transformer.SSEWriter, chunk map[string]interface{}) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	return writer.WriteEvent(&transformer.SSEEvent{Data: string(data)})
+}
+
+// mockCompletion is a rendered mock response
+type mockCompletion struct {
+	id           string
+	model        string
+	text         string
+	inputTokens  int
+	outputTokens int
+}
+
+// message returns the completion as an OpenAI chat completion
+func (c mockCompletion) message() map[string]interface{} {
+	return map[string]interface{}{
+		"id":      c.id,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   c.model,
+		"choices": []interface{}{
+			map[string]interface{}{
+				"index":         0,
+				"message":       map[string]interface{}{"role": "assistant", "content": c.text},
+				"finish_reason": "stop",
+			},
+		},
+		"usage": c.usage(),
+	}
+}
+
+// chunk returns an OpenAI stream chunk carrying delta
+func (c mockCompletion) chunk(delta map[string]interface{}, finishReason interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":      c.id,
+		"object":  "chat.completion.chunk",
+		"created": time.Now().Unix(),
+		"model":   c.model,
+		"choices": []interface{}{
+			map[string]interface{}{"index": 0, "delta": delta, "finish_reason": finishReason},
+		},
+	}
+}
+
+// usage returns the completion's estimated token usage
+func (c mockCompletion) usage() map[string]interface{} {
+	return map[string]interface{}{
+		"prompt_tokens":     c.inputTokens,
+		"completion_tokens": c.outputTokens,
+		"total_tokens":      c.inputTokens + c.outputTokens,
+	}
+}
+
+// sleepContext waits for d, returning early with the context error if ctx
+// is canceled first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_MockProvider(t *testing.T) {
+	newPipeline := func(t *testing.T, provider config.Provider) *Pipeline {
+		t.Helper()
+		provider.Name = config.MockProviderName
+		provider.Enabled = true
+		cfg := &config.Config{
+			Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+			Providers:   []config.Provider{provider},
+			Routes: map[string]config.Route{
+				"default": {Provider: config.MockProviderName, Model: "mock-model"},
+			},
+		}
+		configService := config.NewService()
+		configService.SetConfig(cfg)
+		providerService := providers.NewService(configService)
+		if err := providerService.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize provider service: %v", err)
+		}
+		transformerService := transformer.NewService()
+		if err := transformer.RegisterBuiltinTransformers(transformerService); err != nil {
+			t.Fatalf("Failed to register transformers: %v", err)
+		}
+		return NewPipeline(cfg, providerService, transformerService, router.New(cfg))
+	}
+
+	request := func(streaming bool) *RequestContext {
+		body := map[string]interface{}{
+			"model":      "mock,mock-model",
+			"max_tokens": float64(100),
+			"messages": []interface{}{
+				map[string]interface{}{"role": "user", "content": "Say hello"},
+			},
+		}
+		if streaming {
+			body["stream"] = true
+		}
+		return &RequestContext{Body: body, Headers: map[string]string{}, IsStreaming: streaming}
+	}
+
+	t.Run("templated response", func(t *testing.T) {
+		pipeline := newPipeline(t, config.Provider{MockResponse: "{{.Model}} heard: {{.Prompt}}"})
+
+		respCtx, err := pipeline.ProcessRequest(context.Background(), request(false))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+
+		var body struct {
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.NewDecoder(respCtx.Response.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(body.Choices) != 1 || body.Choices[0].Message.Content != "mock-model heard: Say hello" {
+			t.Errorf("Unexpected response: %+v", body)
+		}
+		if respCtx.OutputTokens == 0 {
+			t.Error("Expected usage to be reported")
+		}
+	})
+
+	t.Run("streams chunks", func(t *testing.T) {
+		pipeline := newPipeline(t, config.Provider{MockResponse: "one two three"})
+
+		respCtx, err := pipeline.ProcessRequest(context.Background(), request(true))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+
+		data, err := io.ReadAll(respCtx.Response.Body)
+		if err != nil {
+			t.Fatalf("Failed to read stream: %v", err)
+		}
+		stream := string(data)
+		if got := strings.Count(stream, "event: content_block_delta"); got != 3 {
+			t.Errorf("Expected 3 text deltas, got %d in %s", got, stream)
+		}
+		if !strings.Contains(stream, "event: message_stop") {
+			t.Errorf("Expected message_stop, got %s", stream)
+		}
+	})
+
+	t.Run("stream-only model buffered", func(t *testing.T) {
+		pipeline := newPipeline(t, config.Provider{
+			MockResponse:      "one two three",
+			ModelCapabilities: map[string]config.ModelCapabilities{"mock-model": {StreamOnly: true}},
+		})
+
+		respCtx, err := pipeline.ProcessRequest(context.Background(), request(false))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+
+		if got := respCtx.Response.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Expected a JSON response, got %s", got)
+		}
+		var body struct {
+			StopReason string `json:"stop_reason"`
+			Content    []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		}
+		if err := json.NewDecoder(respCtx.Response.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(body.Content) != 1 || body.Content[0].Text != "one two three" || body.StopReason != "end_turn" {
+			t.Errorf("Unexpected response: %+v", body)
+		}
+		if respCtx.OutputTokens == 0 {
+			t.Error("Expected usage to be reported")
+		}
+	})
+
+	t.Run("latency honors cancellation", func(t *testing.T) {
+		pipeline := newPipeline(t, config.Provider{MockLatency: time.Minute})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := pipeline.ProcessRequest(ctx, request(false)); err == nil {
+			t.Fatal("Expected the canceled request to fail")
+		}
+	})
+}
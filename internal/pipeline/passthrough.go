@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+// isAnthropicPassthrough reports whether a request can skip the transformer
+// chain: it targets Anthropic through the default chain and is already in the
+// Anthropic format. Providers with their own transformer list always run it.
+func isAnthropicPassthrough(provider *config.Provider, bodyMap map[string]interface{}) bool {
+	return provider.Name == "anthropic" &&
+		len(provider.Transformers) == 0 &&
+		transformer.IsAnthropicRequest(bodyMap)
+}
+
+// passthroughBody returns a copy of an Anthropic request addressed to the
+// routed model. The client never sends OpenAI-only fields on this path, so
+// any present came from parameter defaults and are dropped. Consecutive
+// same-role messages are merged when the provider opts in. Every other field
+// is sent as it is.
+func passthroughBody(bodyMap map[string]interface{}, provider *config.Provider, model string) map[string]interface{} {
+	body := make(map[string]interface{}, len(bodyMap))
+	for key, value := range bodyMap {
+		if !transformer.IsOpenAIOnlyField(key) {
+			body[key] = value
+		}
+	}
+	if model != "" {
+		body["model"] = model
+	}
+	if provider.MergeConsecutiveMessages {
+		transformer.MergeConsecutiveMessages(body)
+	}
+	return body
+}
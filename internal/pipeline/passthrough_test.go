@@ -0,0 +1,216 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_AnthropicPassthrough(t *testing.T) {
+	const anthropicResponse = `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}],"model":"claude-3-opus","stop_reason":"end_turn","usage":{"input_tokens":5,"output_tokens":1}}`
+	const anthropicStream = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"thinking\",\"thinking\":\"\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"Hmm\"}}\n\n" +
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		if stream, _ := received["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(anthropicStream))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(anthropicResponse))
+	}))
+	defer server.Close()
+
+	newPipeline := func(t *testing.T, provider config.Provider) *Pipeline {
+		t.Helper()
+		provider.APIBaseURL = server.URL
+		provider.APIKey = "test-key"
+		cfg := &config.Config{
+			Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+			Providers:   []config.Provider{provider},
+			Routes: map[string]config.Route{
+				"default": {Provider: provider.Name, Model: "claude-3-opus", Parameters: map[string]interface{}{
+					"temperature":       0.2,
+					"frequency_penalty": 0.5, // Not accepted by Anthropic
+				}},
+			},
+		}
+		configService := config.NewService()
+		configService.SetConfig(cfg)
+		providerService := providers.NewService(configService)
+		if err := providerService.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize provider service: %v", err)
+		}
+		transformerService := transformer.NewService()
+		if err := transformer.RegisterBuiltinTransformers(transformerService); err != nil {
+			t.Fatalf("Failed to register transformers: %v", err)
+		}
+		if err := transformerService.ConfigureProviderChains(cfg.Providers); err != nil {
+			t.Fatalf("Failed to configure provider chains: %v", err)
+		}
+		return NewPipeline(cfg, providerService, transformerService, router.New(cfg))
+	}
+
+	// anthropicRequest uses fields the OpenAI conversion would rewrite or drop
+	anthropicRequest := func() map[string]interface{} {
+		return map[string]interface{}{
+			"model":      "claude-3-5-sonnet",
+			"max_tokens": float64(1024),
+			"system": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Be brief", "cache_control": map[string]interface{}{"type": "ephemeral"}},
+			},
+			"messages": []interface{}{
+				map[string]interface{}{"role": "user", "content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "Weather?"},
+				}},
+				map[string]interface{}{"role": "assistant", "content": []interface{}{
+					map[string]interface{}{"type": "tool_use", "id": "t1", "name": "get_weather", "input": map[string]interface{}{}},
+				}},
+				map[string]interface{}{"role": "user", "content": []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "t1", "content": "Sunny"},
+				}},
+			},
+			"tools": []interface{}{
+				map[string]interface{}{"name": "get_weather", "input_schema": map[string]interface{}{"type": "object"}},
+			},
+			"tool_choice": map[string]interface{}{"type": "auto", "disable_parallel_tool_use": true},
+			"thinking":    map[string]interface{}{"type": "enabled", "budget_tokens": float64(2048)},
+			"metadata":    map[string]interface{}{"user_id": "u1"},
+		}
+	}
+
+	t.Run("RequestFieldsUnchanged", func(t *testing.T) {
+		p := newPipeline(t, config.Provider{Name: "anthropic"})
+
+		respCtx, err := p.ProcessRequest(context.Background(), &RequestContext{Body: anthropicRequest()})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		body, _ := io.ReadAll(respCtx.Response.Body)
+		respCtx.Response.Body.Close()
+
+		want := anthropicRequest()
+		want["model"] = "claude-3-opus" // Routed model
+		want["temperature"] = 0.2       // Route parameter, frequency_penalty is dropped
+		if !reflect.DeepEqual(received, want) {
+			t.Errorf("Expected request to pass through unchanged\nwant: %v\ngot:  %v", want, received)
+		}
+		if string(body) != anthropicResponse {
+			t.Errorf("Expected response to pass through unchanged, got %s", body)
+		}
+	})
+
+	t.Run("StreamEventsUnchanged", func(t *testing.T) {
+		p := newPipeline(t, config.Provider{Name: "anthropic"})
+		request := anthropicRequest()
+		request["stream"] = true
+
+		respCtx, err := p.ProcessRequest(context.Background(), &RequestContext{Body: request, IsStreaming: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		recorder := httptest.NewRecorder()
+		if err := p.StreamResponse(context.Background(), recorder, respCtx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		got := recorder.Body.String()
+		for _, event := range []string{"message_start", "content_block_start", "thinking_delta", "content_block_stop", "message_stop"} {
+			if !strings.Contains(got, event) {
+				t.Errorf("Expected %s in stream, got %s", event, got)
+			}
+		}
+		if strings.Count(got, "event: message_stop") != 1 {
+			t.Errorf("Expected a single message_stop, got %s", got)
+		}
+	})
+
+	t.Run("OpenAIRequestIsConverted", func(t *testing.T) {
+		p := newPipeline(t, config.Provider{Name: "anthropic"})
+
+		respCtx, err := p.ProcessRequest(context.Background(), &RequestContext{Body: map[string]interface{}{
+			"model": "claude-3-5-sonnet",
+			"messages": []interface{}{
+				map[string]interface{}{"role": "system", "content": "Be brief"},
+				map[string]interface{}{"role": "user", "content": "Hello"},
+			},
+		}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		respCtx.Response.Body.Close()
+
+		if received["system"] != "Be brief" {
+			t.Errorf("Expected system message to be converted, got %v", received)
+		}
+	})
+
+	t.Run("MergesConsecutiveMessages", func(t *testing.T) {
+		p := newPipeline(t, config.Provider{Name: "anthropic", MergeConsecutiveMessages: true})
+		request := anthropicRequest()
+		request["messages"] = append(request["messages"].([]interface{}),
+			map[string]interface{}{"role": "user", "content": "Thanks"})
+
+		respCtx, err := p.ProcessRequest(context.Background(), &RequestContext{Body: request})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		respCtx.Response.Body.Close()
+
+		messages, _ := received["messages"].([]interface{})
+		if len(messages) != 3 {
+			t.Fatalf("Expected the trailing user messages to be merged, got %v", received["messages"])
+		}
+		content, _ := messages[2].(map[string]interface{})["content"].([]interface{})
+		if len(content) != 2 {
+			t.Errorf("Expected tool result and text blocks in the merged message, got %v", messages[2])
+		}
+	})
+
+	t.Run("AppliesToolLimits", func(t *testing.T) {
+		p := newPipeline(t, config.Provider{Name: "anthropic", MaxTools: 1})
+		request := anthropicRequest()
+		request["tools"] = append(request["tools"].([]interface{}),
+			map[string]interface{}{"name": "get_time", "input_schema": map[string]interface{}{"type": "object"}})
+
+		_, err := p.ProcessRequest(context.Background(), &RequestContext{Body: request})
+		if err == nil || !strings.Contains(err.Error(), "request has 2 tools but provider anthropic accepts at most 1") {
+			t.Errorf("Expected a tool limit error, got %v", err)
+		}
+	})
+
+	t.Run("CustomChainIsKept", func(t *testing.T) {
+		p := newPipeline(t, config.Provider{
+			Name:         "anthropic",
+			Transformers: []config.TransformerConfig{{Name: "anthropic"}},
+		})
+
+		respCtx, err := p.ProcessRequest(context.Background(), &RequestContext{Body: anthropicRequest()})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		respCtx.Response.Body.Close()
+
+		if _, exists := received["thinking"]; exists {
+			t.Errorf("Expected the configured chain to convert the request, got %v", received)
+		}
+	})
+}
@@ -5,10 +5,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	neturl "net/url"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -18,6 +22,7 @@ import (
 	"github.com/orchestre-dev/ccproxy/internal/providers"
 	"github.com/orchestre-dev/ccproxy/internal/proxy"
 	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/tracing"
 	"github.com/orchestre-dev/ccproxy/internal/transformer"
 	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
@@ -25,14 +30,82 @@ import (
 // Pipeline handles the complete request processing flow
 type Pipeline struct {
 	config             *config.Config
+	configMu           sync.RWMutex // Guards config and providerClients, replaced on reload
 	providerService    *providers.Service
 	transformerService *transformer.Service
 	router             *router.Router
 	httpClient         *http.Client
+	providerClients    map[string]*http.Client // Clients for providers with custom TLS settings or mock responses
 	streamingProcessor *StreamingProcessor
 	performanceMonitor *performance.Monitor
 	requestCounter     int64
 	messageConverter   *converter.MessageConverter
+	modelAccess        ModelAccessChecker
+	tracer             *tracing.Tracer
+
+	// Idempotency-Key handling
+	idempotencyStore IdempotencyStore
+	inflight         map[string]*inflightRequest
+	inflightMu       sync.Mutex
+
+	// Identical streaming requests sharing one upstream stream
+	coalesced   map[string]*coalescedStream
+	coalescedMu sync.Mutex
+
+	// X-CCProxy-Session provider pinning
+	sessions *stickySessions
+
+	// Global request queue and per-route concurrency counts
+	queue *requestQueue
+
+	// Limits retries to a fraction of requests, nil when unlimited
+	retryBudget *retryBudget
+
+	// Token and spend budgets, nil when none are configured
+	budget *budgetTracker
+
+	// Providers switched off at runtime through the admin API
+	disabled   map[string]bool
+	disabledMu sync.RWMutex
+
+	// Random roll for route exploration and shadow sampling, replaced in tests
+	roll func() float64
+
+	// Per-provider concurrency limiters keyed by provider name
+	limiters   map[string]*providerLimiter
+	limitersMu sync.Mutex
+
+	// Vertex AI token sources keyed by service account file
+	vertexTokens map[string]*vertexTokenSource
+	vertexMu     sync.Mutex
+}
+
+// ModelAccessChecker decides whether an API key may use a target model
+type ModelAccessChecker interface {
+	CheckModelAccess(apiKeyHash, model string) error
+}
+
+// SetModelAccessChecker enables per-key model restrictions. The check runs
+// after routing so it sees the resolved target model.
+func (p *Pipeline) SetModelAccessChecker(checker ModelAccessChecker) {
+	p.modelAccess = checker
+}
+
+// StreamLatencyStats returns recent per-provider time to first token and
+// inter-token latencies of streamed responses
+func (p *Pipeline) StreamLatencyStats() map[string]performance.StreamLatencyStats {
+	if p.performanceMonitor == nil {
+		return nil
+	}
+	return p.performanceMonitor.GetStreamLatencies()
+}
+
+// LatencyStats returns recent per-provider request latencies
+func (p *Pipeline) LatencyStats() map[string]performance.ProviderLatencyStats {
+	if p.performanceMonitor == nil {
+		return nil
+	}
+	return p.performanceMonitor.GetProviderLatencies()
 }
 
 // NewPipeline creates a new request processing pipeline
@@ -77,14 +150,36 @@ func NewPipeline(
 		}
 	}
 
+	// Apply per-provider CA bundles and verification settings
+	providerClients := buildProviderClients(httpClient, cfg.Providers)
+
+	configureTransformers(transformerService, cfg)
+
+	streamingProcessor := NewStreamingProcessor(transformerService)
+	streamingProcessor.SetRecordDir(cfg.StreamRecordDir)
+	streamingProcessor.SetKeepAliveInterval(cfg.Streaming.KeepAliveInterval)
+	streamingProcessor.SetToolArgumentValidation(cfg.Streaming.ValidateToolArguments)
+	if cfg.Streaming.FlushMode == config.FlushModeBatched {
+		streamingProcessor.SetFlushBatching(cfg.Streaming.BatchSize(), cfg.Streaming.BatchInterval())
+	}
+
 	return &Pipeline{
 		config:             cfg,
 		providerService:    providerService,
 		transformerService: transformerService,
 		router:             router,
 		httpClient:         httpClient,
-		streamingProcessor: NewStreamingProcessor(transformerService),
+		providerClients:    providerClients,
+		streamingProcessor: streamingProcessor,
 		messageConverter:   converter.NewMessageConverter(),
+		idempotencyStore:   NewMemoryIdempotencyStore(),
+		inflight:           make(map[string]*inflightRequest),
+		coalesced:          make(map[string]*coalescedStream),
+		sessions:           newStickySessions(),
+		queue:              newRequestQueue(cfg.Performance.MaxConcurrentRequests, cfg.Performance.QueueTimeout),
+		retryBudget:        newRetryBudget(cfg.Retry),
+		budget:             newBudgetTracker(cfg),
+		vertexTokens:       make(map[string]*vertexTokenSource),
 		performanceMonitor: performance.NewMonitor(&performance.PerformanceConfig{
 			MetricsEnabled:  true,
 			MetricsInterval: 30 * time.Second,
@@ -96,26 +191,151 @@ func NewPipeline(
 	}
 }
 
+// configureTransformers loads per-provider field renames, parameter lists
+// and tool limits into the transformer chain
+func configureTransformers(transformerService *transformer.Service, cfg *config.Config) {
+	transformerService.ConfigureFieldRenames(cfg.Providers)
+	transformerService.ConfigureUnsupportedParams(cfg.Providers)
+	transformerService.ConfigureToolLimits(cfg.Providers)
+	transformerService.ConfigureMessageMerging(cfg.Providers)
+}
+
+// SetConfig applies a reloaded configuration to routing, the transformer
+// chain, provider clients and per-request settings. The request queue, retry
+// budget, spend budgets and streaming settings keep their limits until a
+// restart.
+func (p *Pipeline) SetConfig(cfg *config.Config) {
+	configureTransformers(p.transformerService, cfg)
+	if p.router != nil {
+		p.router.SetConfig(cfg)
+	}
+	providerClients := buildProviderClients(p.httpClient, cfg.Providers)
+
+	p.configMu.Lock()
+	defer p.configMu.Unlock()
+	p.config = cfg
+	p.providerClients = providerClients
+}
+
+// currentConfig returns the configuration for one request
+func (p *Pipeline) currentConfig() *config.Config {
+	p.configMu.RLock()
+	defer p.configMu.RUnlock()
+	return p.config
+}
+
 // ProcessRequest handles the complete request processing pipeline
 func (p *Pipeline) ProcessRequest(ctx context.Context, req *RequestContext) (*ResponseContext, error) {
+	start := time.Now()
+	ctx, span := p.tracer.Start(ctx, requestSpanName, req.Headers)
+
+	var resp *ResponseContext
+	var err error
+	// Deduplicate retries that carry an Idempotency-Key
+	if key := p.idempotencyKey(req); key != "" {
+		resp, err = p.processIdempotent(ctx, req, key)
+	} else if key := p.coalesceKey(req); key != "" {
+		// Share one upstream stream between identical streaming requests
+		resp, err = p.processCoalesced(ctx, req, key)
+	} else {
+		resp, err = p.processRequest(ctx, req)
+	}
+
+	endRequestSpan(span, req, resp, err)
+	if resp != nil {
+		resp.TraceID = span.TraceID()
+		resp.StartTime = start
+	}
+	return resp, err
+}
+
+// processRequest runs the pipeline steps for a single request
+func (p *Pipeline) processRequest(ctx context.Context, req *RequestContext) (*ResponseContext, error) {
 	// Extract model and count tokens from request
 	var routeReq router.Request
 	var tokenCount int
+	var requestedProvider, requestedModel string
+	var toolRoundtrips int
 
 	if bodyMap, ok := req.Body.(map[string]interface{}); ok {
 		if model, ok := bodyMap["model"].(string); ok {
 			routeReq.Model = model
 		}
-		if thinking, ok := bodyMap["thinking"].(bool); ok {
-			routeReq.Thinking = thinking
+		if thinking, ok := bodyMap["thinking"]; ok {
+			routeReq.Thinking = transformer.ThinkingEnabled(thinking)
 		}
+		routeReq.UserText = utils.ExtractUserText(bodyMap)
+
+		// Count tokens with the tokenizer of the requested model, so
+		// token-threshold routing sees an accurate count
+		requestedProvider, requestedModel = router.ParseModelString(routeReq.Model)
+		tokenCount = utils.CountRequestTokensFor(requestedProvider, requestedModel, bodyMap)
+
+		// Count tool roundtrips for agentic conversations
+		toolRoundtrips = utils.CountToolRoundtrips(bodyMap)
+	}
+
+	// Header rules look values up by canonical name
+	routeReq.Headers = make(map[string]string, len(req.Headers))
+	for key, value := range req.Headers {
+		routeReq.Headers[http.CanonicalHeaderKey(key)] = value
+	}
 
-		// Count tokens
-		tokenCount = utils.CountRequestTokens(bodyMap)
+	maxToolRoundtrips := p.currentConfig().Performance.MaxToolRoundtrips
+	toolRoundtripsExceeded := maxToolRoundtrips > 0 && toolRoundtrips > maxToolRoundtrips
+	if toolRoundtripsExceeded {
+		utils.GetLogger().Warnf("Conversation flagged for review: %d tool roundtrips exceeds threshold of %d",
+			toolRoundtrips, maxToolRoundtrips)
 	}
 
-	// 1. Route to appropriate model/provider
-	routingDecision := p.router.Route(routeReq, tokenCount)
+	// 1. Route to appropriate model/provider, unless the client forced a
+	// named route, which also wins over session pinning
+	routingDecision, forced, err := p.router.ForcedRoute(routeReq)
+	if err != nil {
+		return nil, err
+	}
+	if forced {
+		routingDecision = p.applyRouteStrategy(routingDecision)
+	} else {
+		routingDecision = p.stickyRoute(req, routeReq, p.applyRouteStrategy(p.router.Route(routeReq, tokenCount)))
+	}
+
+	// Move requests off providers that were disabled at runtime
+	routingDecision, err = p.applyProviderState(routingDecision)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject or redirect requests to providers that have used up their budget
+	routingDecision, err = p.applyBudget(routingDecision)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enforce per-key model restrictions against the resolved model
+	if err := p.checkModelAccess(req, routingDecision.Model); err != nil {
+		return nil, fmt.Errorf("model access denied: %w", err)
+	}
+
+	// Fan out requests for several completions to providers that return one
+	if count := p.emulatedCompletions(req, routingDecision.Provider); count > 1 {
+		return p.processEmulated(ctx, req, count)
+	}
+
+	// Mirror a share of the route's traffic to its shadow target
+	p.mirrorToShadow(req, routingDecision.Shadow)
+
+	// Count the request against its route, queueing while over the global limit
+	finish, err := p.queue.admit(ctx, routingDecision.Provider+","+routingDecision.Model)
+	if err != nil {
+		return nil, err
+	}
+	admitted := false
+	defer func() {
+		if !admitted {
+			finish()
+		}
+	}()
 
 	// 2. Get provider configuration
 	selectedProvider, err := p.providerService.GetProvider(routingDecision.Provider)
@@ -123,40 +343,106 @@ func (p *Pipeline) ProcessRequest(ctx context.Context, req *RequestContext) (*Re
 		return nil, fmt.Errorf("provider not found: %s", routingDecision.Provider)
 	}
 
-	// 3. Apply route parameters to request body
+	// 3. Apply default parameters with precedence request > route > provider > global
 	requestBody := req.Body
-	if len(routingDecision.Parameters) > 0 {
-		// Apply parameters to request body
-		if bodyMap, ok := requestBody.(map[string]interface{}); ok {
-			for key, value := range routingDecision.Parameters {
-				// Only set if not already present in the request
-				if _, exists := bodyMap[key]; !exists {
-					bodyMap[key] = value
-				}
-			}
-			requestBody = bodyMap
+	passthrough := false
+	// Stream-only models are streamed upstream even when the client asked
+	// for a single response, which is assembled from the stream
+	upstreamStreaming := req.IsStreaming
+	if bodyMap, ok := requestBody.(map[string]interface{}); ok {
+		// Decide before defaults are applied so injected parameters do not
+		// change how the client's format is detected
+		passthrough = isAnthropicPassthrough(selectedProvider, bodyMap)
+
+		// Send the routed model, which failover and route strategies may
+		// have changed from the one the client or router middleware set
+		if routingDecision.Model != "" {
+			bodyMap["model"] = routingDecision.Model
+		}
+
+		// Recount for the routed model when its tokenizer may differ
+		if routingDecision.Provider != requestedProvider || routingDecision.Model != requestedModel {
+			tokenCount = utils.CountRequestTokensFor(routingDecision.Provider, routingDecision.Model, bodyMap)
+		}
+
+		if err := checkModelCapabilities(bodyMap, selectedProvider, routingDecision.Model, req.IsStreaming); err != nil {
+			return nil, err
+		}
+		if !req.IsStreaming && isStreamOnly(selectedProvider, routingDecision.Model) {
+			bodyMap["stream"] = true
+			upstreamStreaming = true
+		}
+
+		applyParameterDefaults(bodyMap, routingDecision.Parameters, selectedProvider.Parameters)
+		applyDefaultFrequencyPenalty(bodyMap, selectedProvider)
+		applyParameterDefaults(bodyMap, p.currentConfig().Parameters)
+
+		// Drop old turns that would overflow the model's context window
+		if err := fitContextWindow(bodyMap, selectedProvider, routingDecision.Model); err != nil {
+			return nil, err
 		}
 	}
 
 	// 4. Get transformer chain for provider
 	chain := p.transformerService.GetChainForProvider(routingDecision.Provider)
 
-	// 5. Apply request transformations
-	transformedRequest, err := chain.TransformRequestIn(ctx, requestBody, routingDecision.Provider)
-	if err != nil {
-		return nil, fmt.Errorf("request transformation failed: %w", err)
+	// 5. Apply request transformations. Anthropic-format requests to
+	// Anthropic skip the chain and are sent as they are.
+	var transformedRequest interface{}
+	if passthrough {
+		body := passthroughBody(requestBody.(map[string]interface{}), selectedProvider, routingDecision.Model)
+		if err := transformer.LimitTools(body, routingDecision.Provider, transformer.ToolLimitsFor(selectedProvider)); err != nil {
+			return nil, fmt.Errorf("request transformation failed: %w", err)
+		}
+		transformedRequest = body
+		utils.GetLogger().Debugf("Passing Anthropic request through to %s unchanged", selectedProvider.Name)
+	} else {
+		transformedRequest, err = chain.TransformRequestIn(ctx, requestBody, routingDecision.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("request transformation failed: %w", err)
+		}
+	}
+
+	// Gemini and Vertex AI address the model through the URL path
+	switch routingDecision.Provider {
+	case "gemini":
+		transformedRequest = geminiRequest(selectedProvider, transformedRequest, routingDecision.Model, upstreamStreaming)
+	case "vertex":
+		transformedRequest, err = p.vertexRequest(selectedProvider, transformedRequest, routingDecision.Model, upstreamStreaming)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build Vertex AI request: %w", err)
+		}
 	}
 
 	// 6. Build HTTP request with transformed data
-	httpReq, err := p.buildHTTPRequest(ctx, selectedProvider, transformedRequest, req.IsStreaming, routingDecision.Provider)
+	httpReq, err := p.buildHTTPRequest(ctx, selectedProvider, transformedRequest, upstreamStreaming, routingDecision.Provider)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build HTTP request: %w", err)
 	}
+	setRequestID(httpReq, req)
+
+	// 7. Smooth out bursts with optional per-provider jitter
+	if err := applyJitter(ctx, selectedProvider.MaxJitter); err != nil {
+		return nil, fmt.Errorf("request canceled during dispatch jitter: %w", err)
+	}
+
+	// Wait for a free slot when the provider limits concurrency
+	release, err := p.acquireSlot(ctx, selectedProvider)
+	if err != nil {
+		return nil, err
+	}
 
-	// 7. Send request to provider
+	// 8. Send request to provider, earning retry budget for later retries
+	p.retryBudget.deposit()
 	startTime := time.Now()
-	httpResp, err := p.httpClient.Do(httpReq)
+	httpResp, err := p.sendRequest(httpReq, selectedProvider, upstreamStreaming)
 	duration := time.Since(startTime)
+	if err != nil {
+		release()
+	} else {
+		// Hold the slot until the response has been consumed
+		httpResp.Body = &releaseOnCloseBody{ReadCloser: httpResp.Body, release: release}
+	}
 
 	// Track provider metrics atomically
 	atomic.AddInt64(&p.requestCounter, 1)
@@ -187,28 +473,281 @@ func (p *Pipeline) ProcessRequest(ctx context.Context, req *RequestContext) (*Re
 		})
 	}
 
-	// 8. Transform response through chain
-	transformedResp, err := chain.TransformResponseOut(ctx, httpResp)
-	if err != nil {
-		// Close response body to prevent leak
-		if httpResp.Body != nil {
-			_ = httpResp.Body.Close() // Safe to ignore: closing on error path
+	// Retry once when the provider closes the stream without any content.
+	// Nothing has been written to the client yet, so this is invisible to it.
+	// With the retry budget exhausted the empty stream is returned as is.
+	if upstreamStreaming && p.currentConfig().Performance.RetryEmptyStreams &&
+		httpResp.StatusCode == http.StatusOK && isEmptyStream(httpResp) && p.allowRetry(selectedProvider) {
+		_ = httpResp.Body.Close() // Safe to ignore: stream is being discarded
+		utils.GetLogger().Warnf("Empty stream from provider %s, retrying once", selectedProvider.Name)
+
+		retryReq, err := p.buildHTTPRequest(ctx, selectedProvider, transformedRequest, upstreamStreaming, routingDecision.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build HTTP request: %w", err)
+		}
+		setRequestID(retryReq, req)
+		release, err := p.acquireSlot(ctx, selectedProvider)
+		if err != nil {
+			return nil, err
+		}
+		httpResp, err = p.sendRequest(retryReq, selectedProvider, upstreamStreaming)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("provider request failed: %w", err)
+		}
+		httpResp.Body = &releaseOnCloseBody{ReadCloser: httpResp.Body, release: release}
+	}
+
+	upstreamID := upstreamRequestID(httpResp)
+
+	// Fix nearly-valid JSON from providers that opt in, before it is parsed
+	if selectedProvider.RepairJSON && !upstreamStreaming {
+		if err := repairJSONResponse(httpResp, selectedProvider.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	// 9. Transform response through chain. Passthrough responses are
+	// already in the Anthropic format.
+	transformedResp := httpResp
+	if !passthrough {
+		// Transformers that build a response from scratch name the routed model
+		transformedResp, err = chain.TransformResponseOut(transformer.WithRequestModel(ctx, routingDecision.Model), httpResp)
+		if err != nil {
+			// Close response body to prevent leak
+			if httpResp.Body != nil {
+				_ = httpResp.Body.Close() // Safe to ignore: closing on error path
+			}
+			return nil, fmt.Errorf("response transformation failed: %w", err)
 		}
+	}
+
+	// Clients of /v1/messages expect Anthropic error bodies
+	errorResp, err := transformer.NewAnthropicErrorTransformer().TransformResponseOut(ctx, transformedResp)
+	if err != nil {
 		return nil, fmt.Errorf("response transformation failed: %w", err)
 	}
+	transformedResp = errorResp
+
+	// Clients of /v1/messages expect Anthropic stream events
+	if upstreamStreaming && !passthrough {
+		streamResp, err := transformer.NewAnthropicStreamTransformer().TransformResponseOut(ctx, transformedResp)
+		if err != nil {
+			_ = transformedResp.Body.Close() // Safe to ignore: closing on error path
+			return nil, fmt.Errorf("response transformation failed: %w", err)
+		}
+		transformedResp = streamResp
+	}
+
+	// Assemble the stream of a stream-only model into a single message
+	if upstreamStreaming && !req.IsStreaming {
+		bufferedResp, err := bufferStreamResponse(transformedResp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to buffer streamed response: %w", err)
+		}
+		transformedResp = bufferedResp
+	}
+
+	// Report the requested model or an alias instead of the upstream one
+	if model := p.maskedModel(req, routeReq.Model); model != "" {
+		transformedResp, err = transformer.NewModelMaskTransformer(model).TransformResponseOut(ctx, transformedResp)
+		if err != nil {
+			return nil, fmt.Errorf("response transformation failed: %w", err)
+		}
+	}
 
-	// 9. Build response context
+	// Record reported usage and log the cost breakdown for priced models
+	var cost *CostBreakdown
+	var usage tokenUsage
+	if !req.IsStreaming {
+		var ok bool
+		if usage, ok = responseUsage(transformedResp); ok {
+			cost = logCost(selectedProvider, routingDecision.Model, usage.Input, usage.Output)
+		}
+	}
+
+	// 10. Build response context
 	respCtx := &ResponseContext{
 		Response:        transformedResp,
 		Provider:        routingDecision.Provider,
 		Model:           routingDecision.Model,
 		TokenCount:      tokenCount,
 		RoutingStrategy: routingDecision.Reason,
+		UpstreamID:      upstreamID,
+
+		ToolRoundtrips:         toolRoundtrips,
+		ToolRoundtripsExceeded: toolRoundtripsExceeded,
+		InputTokens:            usage.Input,
+		OutputTokens:           usage.Output,
+		CacheReadTokens:        usage.CacheRead,
+		CacheCreationTokens:    usage.CacheCreation,
+		Cost:                   cost,
+	}
+
+	// Count successful requests against the provider's budget. Streams
+	// report their usage in their final events, so they are counted, and
+	// their usage recorded, once the client is done with them.
+	if transformedResp.StatusCode < http.StatusBadRequest {
+		if req.IsStreaming && transformedResp.Body != nil {
+			transformedResp.Body = newStreamUsageBody(transformedResp.Body, func(usage tokenUsage, ok bool) {
+				var streamCost *CostBreakdown
+				if ok {
+					streamCost = logCost(selectedProvider, routingDecision.Model, usage.Input, usage.Output)
+					respCtx.setStreamUsage(usage, streamCost)
+				}
+				p.recordBudgetUsage(routingDecision.Provider, budgetTokens(usage, tokenCount), streamCost)
+			})
+		} else {
+			p.recordBudgetUsage(routingDecision.Provider, budgetTokens(usage, tokenCount), cost)
+		}
+	}
+
+	// The request stays counted until the client has consumed the response
+	if transformedResp.Body != nil {
+		transformedResp.Body = &releaseOnCloseBody{ReadCloser: transformedResp.Body, release: finish}
+		admitted = true
 	}
 
 	return respCtx, nil
 }
 
+// checkModelAccess runs the model access checker for authenticated requests
+func (p *Pipeline) checkModelAccess(req *RequestContext, model string) error {
+	if p.modelAccess == nil {
+		return nil
+	}
+	apiKeyHash, _ := req.Metadata["api_key_hash"].(string)
+	if apiKeyHash == "" {
+		return nil
+	}
+	return p.modelAccess.CheckModelAccess(apiKeyHash, model)
+}
+
+// Valid range for frequency_penalty across OpenAI-compatible providers
+const (
+	minFrequencyPenalty = -2.0
+	maxFrequencyPenalty = 2.0
+)
+
+// applyParameterDefaults sets parameters the request omits, taking each from
+// the first layer that defines it
+func applyParameterDefaults(bodyMap map[string]interface{}, layers ...map[string]interface{}) {
+	for _, layer := range layers {
+		for key, value := range layer {
+			if _, exists := bodyMap[key]; exists {
+				continue
+			}
+			if key == "frequency_penalty" {
+				value = clampFrequencyPenalty(value)
+			}
+			bodyMap[key] = value
+		}
+	}
+}
+
+// applyDefaultFrequencyPenalty sets the provider's default frequency_penalty
+// on requests that omit it
+func applyDefaultFrequencyPenalty(bodyMap map[string]interface{}, provider *config.Provider) {
+	if provider.DefaultFrequencyPenalty == nil {
+		return
+	}
+	if _, exists := bodyMap["frequency_penalty"]; exists {
+		return
+	}
+	bodyMap["frequency_penalty"] = clampFrequencyPenalty(*provider.DefaultFrequencyPenalty)
+}
+
+// clampFrequencyPenalty limits an injected frequency_penalty to the valid
+// [-2, 2] range. Non-numeric values are returned unchanged.
+func clampFrequencyPenalty(value interface{}) interface{} {
+	var penalty float64
+	switch v := value.(type) {
+	case float64:
+		penalty = v
+	case int:
+		penalty = float64(v)
+	default:
+		return value
+	}
+
+	if penalty < minFrequencyPenalty {
+		return minFrequencyPenalty
+	}
+	if penalty > maxFrequencyPenalty {
+		return maxFrequencyPenalty
+	}
+	return penalty
+}
+
+// applyJitter waits for a random duration in [0, maxJitter] before dispatch.
+// It returns early with the context error if the request is canceled.
+func applyJitter(ctx context.Context, maxJitter time.Duration) error {
+	if maxJitter <= 0 {
+		return nil
+	}
+
+	delay := time.Duration(rand.Int63n(int64(maxJitter) + 1)) // #nosec G404 - Used for non-cryptographic jitter only
+	return sleepContext(ctx, delay)
+}
+
+// errProviderTimeout is the cancellation cause when a provider timeout fires
+var errProviderTimeout = errors.New("provider timeout exceeded")
+
+// sendRequest sends the request, bounded by the provider's timeout when one is
+// configured and by the client-wide request timeout otherwise. For streaming
+// requests the provider timeout covers connecting and receiving the response
+// headers only, so long-running streams are not cut off mid-read.
+func (p *Pipeline) sendRequest(req *http.Request, provider *config.Provider, isStreaming bool) (*http.Response, error) {
+	if provider.Timeout <= 0 {
+		return p.clientFor(provider).Do(req)
+	}
+
+	// The provider timeout replaces the client-wide one
+	client := *p.clientFor(provider)
+	client.Timeout = 0
+
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(provider.Timeout, func() { cancel(errProviderTimeout) })
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		if context.Cause(ctx) == errProviderTimeout {
+			err = fmt.Errorf("%w after %s: %w", errProviderTimeout, provider.Timeout, err)
+		}
+		cancel(nil)
+		return nil, err
+	}
+
+	if isStreaming && !timer.Stop() {
+		// The timeout fired just as the headers arrived
+		_ = resp.Body.Close() // Safe to ignore: request already timed out
+		cancel(nil)
+		return nil, fmt.Errorf("%w after %s", errProviderTimeout, provider.Timeout)
+	}
+
+	// Release the timer and context once the caller is done with the body
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, timer: timer, cancel: func() { cancel(nil) }}
+	return resp, nil
+}
+
+// cancelOnCloseBody releases a request's timeout resources when closed
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	timer  *time.Timer // nil when the request has no timer
+	cancel func()
+}
+
+// Close closes the body and stops the request timeout
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.cancel()
+	return err
+}
+
 // buildHTTPRequest builds the HTTP request for the provider
 func (p *Pipeline) buildHTTPRequest(ctx context.Context, provider *config.Provider, body interface{}, isStreaming bool, providerName string) (*http.Request, error) {
 	// Check if body is a RequestConfig with custom URL/headers
@@ -228,21 +767,18 @@ func (p *Pipeline) buildHTTPRequest(ctx context.Context, provider *config.Provid
 		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	// Build URL
-	url := provider.APIBaseURL
-
-	// Use custom URL if provided by transformer
+	// Build URL, using the custom URL if provided by transformer
+	var url string
 	if reqConfig != nil && reqConfig.URL != "" {
 		url = reqConfig.URL
 	} else {
 		// Determine endpoint based on provider type
 		endpoint := p.getProviderEndpoint(providerName)
-
-		// Add endpoint to URL
-		if !strings.HasSuffix(url, "/") {
-			url += "/"
+		switch providerName {
+		case "azure":
+			endpoint = getAzureEndpoint(provider, actualBody)
 		}
-		url += strings.TrimPrefix(endpoint, "/")
+		url = provider.EndpointURL(endpoint)
 	}
 
 	// Create request
@@ -256,11 +792,18 @@ func (p *Pipeline) buildHTTPRequest(ctx context.Context, provider *config.Provid
 		return nil, err
 	}
 
+	// Set authentication header based on provider
+	if err := p.setAuthenticationHeader(req, provider, providerName); err != nil {
+		return nil, err
+	}
+	authHeaders := make(map[string]bool, len(req.Header))
+	for key := range req.Header {
+		authHeaders[key] = true
+	}
+
 	// Set default headers
 	req.Header.Set("Content-Type", "application/json")
-
-	// Set authentication header based on provider
-	p.setAuthenticationHeader(req, provider, providerName)
+	setVersionHeaders(req, provider, providerName)
 
 	// Set streaming header if needed
 	if isStreaming {
@@ -268,7 +811,20 @@ func (p *Pipeline) buildHTTPRequest(ctx context.Context, provider *config.Provid
 	}
 
 	// Add user agent
-	req.Header.Set("User-Agent", "ccproxy/1.0")
+	req.Header.Set("User-Agent", p.userAgent())
+
+	// Continue the caller's trace at the provider
+	tracing.Inject(ctx, req.Header)
+
+	// Apply provider custom headers. Headers set by authentication are kept,
+	// leave api_key empty to supply credentials through headers instead.
+	for key, value := range provider.Headers {
+		if authHeaders[http.CanonicalHeaderKey(key)] {
+			utils.GetLogger().Warnf("Ignoring custom header %s for provider %s: set by authentication", key, provider.Name)
+			continue
+		}
+		req.Header.Set(key, value)
+	}
 
 	// Apply custom headers from transformer
 	if reqConfig != nil && reqConfig.Headers != nil {
@@ -277,6 +833,11 @@ func (p *Pipeline) buildHTTPRequest(ctx context.Context, provider *config.Provid
 		}
 	}
 
+	// Prompt caching needs its beta flag alongside any configured ones
+	if providerName == "anthropic" && transformer.HasCacheControl(actualBody) {
+		addAnthropicBeta(req, transformer.PromptCachingBeta)
+	}
+
 	// Set timeout if specified
 	if reqConfig != nil && reqConfig.Timeout > 0 {
 		ctx, cancel := context.WithTimeout(ctx, time.Duration(reqConfig.Timeout)*time.Millisecond)
@@ -295,7 +856,6 @@ func (p *Pipeline) getProviderEndpoint(providerName string) string {
 		"openai":     "/v1/chat/completions",
 		"groq":       "/openai/v1/chat/completions",
 		"deepseek":   "/v1/chat/completions",
-		"gemini":     "/v1beta/models/generateContent",
 		"openrouter": "/api/v1/chat/completions",
 		"mistral":    "/v1/chat/completions",
 		"xai":        "/v1/chat/completions",
@@ -310,17 +870,90 @@ func (p *Pipeline) getProviderEndpoint(providerName string) string {
 	return "/v1/chat/completions"
 }
 
+// setVersionHeaders pins the provider's API version. Custom headers are
+// applied afterwards, so they can still override it or add beta flags such
+// as anthropic-beta.
+func setVersionHeaders(req *http.Request, provider *config.Provider, providerName string) {
+	if providerName == "anthropic" {
+		req.Header.Set("anthropic-version", provider.AnthropicVersion())
+	}
+}
+
+// addAnthropicBeta appends a flag to the comma-separated anthropic-beta
+// header unless it is already listed
+func addAnthropicBeta(req *http.Request, flag string) {
+	existing := req.Header.Get("anthropic-beta")
+	for _, value := range strings.Split(existing, ",") {
+		if strings.TrimSpace(value) == flag {
+			return
+		}
+	}
+	if existing != "" {
+		flag = existing + "," + flag
+	}
+	req.Header.Set("anthropic-beta", flag)
+}
+
+// geminiRequest wraps a transformed Gemini body with the model URL under the
+// provider's API version
+func geminiRequest(provider *config.Provider, body interface{}, model string, isStreaming bool) *transformer.RequestConfig {
+	reqConfig, ok := body.(*transformer.RequestConfig)
+	if !ok {
+		reqConfig = &transformer.RequestConfig{Body: body}
+	}
+	if reqConfig.URL == "" {
+		reqConfig.URL = provider.EndpointURL(getGeminiEndpoint(provider, model, isStreaming))
+	}
+	return reqConfig
+}
+
+// getGeminiEndpoint returns the generateContent endpoint for model, or the
+// SSE streaming endpoint for streamed requests
+func getGeminiEndpoint(provider *config.Provider, model string, isStreaming bool) string {
+	action := "generateContent"
+	if isStreaming {
+		action = "streamGenerateContent?alt=sse"
+	}
+	return fmt.Sprintf("/%s/models/%s:%s", provider.GeminiAPIVersion(), neturl.PathEscape(strings.TrimPrefix(model, "models/")), action)
+}
+
+// getAzureEndpoint builds the deployment-based endpoint used by Azure OpenAI.
+// The deployment falls back to the request model when not configured.
+func getAzureEndpoint(provider *config.Provider, body interface{}) string {
+	return azureDeploymentEndpoint(provider, body, "chat/completions")
+}
+
+// azureDeploymentEndpoint builds the endpoint for an operation on the
+// provider's Azure OpenAI deployment
+func azureDeploymentEndpoint(provider *config.Provider, body interface{}, operation string) string {
+	deployment := provider.Deployment
+	if deployment == "" {
+		if bodyMap, ok := body.(map[string]interface{}); ok {
+			if model, ok := bodyMap["model"].(string); ok {
+				_, deployment = router.ParseModelString(model)
+			}
+		}
+	}
+
+	return fmt.Sprintf("/openai/deployments/%s/%s?api-version=%s",
+		neturl.PathEscape(deployment), operation, neturl.QueryEscape(provider.AzureAPIVersion()))
+}
+
 // setAuthenticationHeader sets the appropriate authentication header for a provider
-func (p *Pipeline) setAuthenticationHeader(req *http.Request, provider *config.Provider, providerName string) {
+func (p *Pipeline) setAuthenticationHeader(req *http.Request, provider *config.Provider, providerName string) error {
+	if providerName == "vertex" {
+		// Vertex AI uses OAuth access tokens rather than API keys
+		return p.setVertexAuthentication(req, provider)
+	}
+
 	if provider.APIKey == "" {
-		return
+		return nil
 	}
 
 	// Provider-specific authentication headers
 	switch providerName {
 	case "anthropic":
 		req.Header.Set("X-API-Key", provider.APIKey)
-		req.Header.Set("anthropic-version", "2023-06-01")
 
 	case "gemini":
 		// Gemini uses API key as query parameter, handled by transformer
@@ -329,12 +962,15 @@ func (p *Pipeline) setAuthenticationHeader(req *http.Request, provider *config.P
 
 	case "openrouter":
 		req.Header.Set("Authorization", "Bearer "+provider.APIKey)
-		// OpenRouter also supports custom headers for app identification
-		// TODO: Add support for custom headers in provider configuration
+		// App identification headers come from the provider's custom headers
 
 	case "groq":
 		req.Header.Set("Authorization", "Bearer "+provider.APIKey)
 
+	case "azure":
+		// Azure OpenAI authenticates with an api-key header instead of Bearer
+		req.Header.Set("api-key", provider.APIKey)
+
 	case "ollama":
 		// Ollama typically doesn't require authentication
 		// but support it if configured
@@ -346,6 +982,8 @@ func (p *Pipeline) setAuthenticationHeader(req *http.Request, provider *config.P
 		// Default to Bearer token for OpenAI-compatible providers
 		req.Header.Set("Authorization", "Bearer "+provider.APIKey)
 	}
+
+	return nil
 }
 
 // RequestContext contains the incoming request information
@@ -363,6 +1001,33 @@ type ResponseContext struct {
 	Model           string         // Selected model
 	TokenCount      int            // Token count
 	RoutingStrategy string         // Routing strategy used
+	UpstreamID      string         // Request id returned by the provider, empty when none
+
+	ToolRoundtrips         int  // Completed tool roundtrips in the conversation
+	ToolRoundtripsExceeded bool // Whether the roundtrips exceed the configured threshold
+
+	// Reported usage. Streams fill these in when their body is closed,
+	// which StreamResponse does before returning.
+	InputTokens  int            // Input tokens reported by the provider, 0 when unknown
+	OutputTokens int            // Output tokens reported by the provider, 0 when unknown
+	Cost         *CostBreakdown // Request cost, nil when the model has no pricing
+
+	CacheReadTokens     int // Input tokens served from the provider's prompt cache
+	CacheCreationTokens int // Input tokens written to the provider's prompt cache
+
+	TraceID string // Trace id of the request span, empty when tracing is disabled
+
+	StartTime         time.Time     // When the pipeline received the request
+	FirstTokenLatency time.Duration // Time from StartTime to the first streamed content, set by StreamResponse
+}
+
+// setStreamUsage records the usage reported by a finished stream
+func (r *ResponseContext) setStreamUsage(usage tokenUsage, cost *CostBreakdown) {
+	r.InputTokens = usage.Input
+	r.OutputTokens = usage.Output
+	r.CacheReadTokens = usage.CacheRead
+	r.CacheCreationTokens = usage.CacheCreation
+	r.Cost = cost
 }
 
 // ErrorResponse represents a standardized error response
@@ -396,10 +1061,29 @@ func WriteErrorResponse(w http.ResponseWriter, statusCode int, err *ErrorRespons
 	_ = json.NewEncoder(w).Encode(err)
 }
 
+// Drain waits for in-flight streams to finish, see StreamingProcessor.Drain
+func (p *Pipeline) Drain(ctx context.Context) error {
+	return p.streamingProcessor.Drain(ctx)
+}
+
 // StreamResponse handles streaming responses with transformation support
 func (p *Pipeline) StreamResponse(ctx context.Context, w http.ResponseWriter, respCtx *ResponseContext) error {
+	timing := &streamTiming{start: respCtx.StartTime}
+	if timing.start.IsZero() {
+		timing.start = time.Now()
+	}
+
 	// Use the streaming processor for enhanced streaming support
-	return p.streamingProcessor.ProcessStreamingResponse(ctx, w, respCtx.Response, respCtx.Provider)
+	err := p.streamingProcessor.processStream(ctx, w, respCtx.Response, respCtx.Provider, timing)
+
+	// Streams that ended before any content have no token latency
+	if timing.seen {
+		respCtx.FirstTokenLatency = timing.firstToken
+		if p.performanceMonitor != nil {
+			p.performanceMonitor.RecordStreamLatency(respCtx.Provider, timing.firstToken, timing.gaps)
+		}
+	}
+	return err
 }
 
 // StreamResponse is a compatibility function for simple streaming
@@ -459,23 +1143,18 @@ func CopyResponse(w http.ResponseWriter, resp *http.Response) error {
 	return err
 }
 
-// HandleStreamingError attempts to send an error event in SSE format
+// HandleStreamingError attempts to send an error event in SSE format. Streams
+// failing with ErrStreamAborted have already sent one.
 func HandleStreamingError(w http.ResponseWriter, err error) {
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
-	// Try to write error as SSE event
-	errorEvent := fmt.Sprintf("event: error\ndata: %s\n\n", err.Error())
-	// Safe to ignore write errors for SSE cleanup
-	_, _ = w.Write([]byte(errorEvent))
-
-	// Send [DONE] marker
-	_, _ = w.Write([]byte("data: [DONE]\n\n"))
-
-	// Flush if possible
-	if flusher, ok := w.(http.Flusher); ok {
-		flusher.Flush()
-	}
+	// Write the error as an Anthropic error event followed by [DONE].
+	// Safe to ignore write errors for SSE cleanup.
+	writer := transformer.NewSSEWriter(w)
+	_ = writer.WriteEvent(transformer.NewStreamErrorEvent("api_error", err.Error()))
+	_ = writer.WriteEvent(&transformer.SSEEvent{Data: "[DONE]"})
+	_ = writer.Flush()
 }
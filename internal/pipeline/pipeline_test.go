@@ -3,6 +3,7 @@ package pipeline
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,9 +13,12 @@ import (
 	"time"
 
 	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
 	"github.com/orchestre-dev/ccproxy/internal/providers"
 	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/tracing"
 	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/orchestre-dev/ccproxy/internal/version"
 )
 
 func TestNewPipeline(t *testing.T) {
@@ -126,7 +130,6 @@ func TestPipeline_GetProviderEndpoint(t *testing.T) {
 		{"openai", "/v1/chat/completions"},
 		{"groq", "/openai/v1/chat/completions"},
 		{"deepseek", "/v1/chat/completions"},
-		{"gemini", "/v1beta/models/generateContent"},
 		{"openrouter", "/api/v1/chat/completions"},
 		{"mistral", "/v1/chat/completions"},
 		{"xai", "/v1/chat/completions"},
@@ -156,10 +159,6 @@ func TestPipeline_SetAuthenticationHeader(t *testing.T) {
 		if req.Header.Get("X-API-Key") != "test-api-key" {
 			t.Errorf("Expected X-API-Key header, got %v", req.Header.Get("X-API-Key"))
 		}
-
-		if req.Header.Get("anthropic-version") != "2023-06-01" {
-			t.Errorf("Expected anthropic-version header")
-		}
 	})
 
 	t.Run("OpenAIAuth", func(t *testing.T) {
@@ -190,6 +189,23 @@ func TestPipeline_SetAuthenticationHeader(t *testing.T) {
 		}
 	})
 
+	t.Run("AzureAuth", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "https://example.openai.azure.com/openai/deployments/gpt-4/chat/completions", nil)
+		provider := &config.Provider{
+			APIKey: "test-azure-key",
+		}
+
+		pipeline.setAuthenticationHeader(req, provider, "azure")
+
+		if req.Header.Get("api-key") != "test-azure-key" {
+			t.Errorf("Expected api-key header, got %v", req.Header.Get("api-key"))
+		}
+
+		if req.Header.Get("Authorization") != "" {
+			t.Error("Should not set Authorization header for Azure")
+		}
+	})
+
 	t.Run("NoAPIKey", func(t *testing.T) {
 		req, _ := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", nil)
 		provider := &config.Provider{
@@ -243,8 +259,8 @@ func TestPipeline_BuildHTTPRequest(t *testing.T) {
 			t.Error("Expected Content-Type header to be application/json")
 		}
 
-		if req.Header.Get("User-Agent") != "ccproxy/1.0" {
-			t.Error("Expected User-Agent header")
+		if req.Header.Get("User-Agent") != "ccproxy/"+version.Version {
+			t.Errorf("Expected User-Agent ccproxy/%s, got %s", version.Version, req.Header.Get("User-Agent"))
 		}
 
 		if req.Header.Get("Authorization") != "Bearer test-key" {
@@ -309,6 +325,261 @@ func TestPipeline_BuildHTTPRequest(t *testing.T) {
 		}
 	})
 
+	t.Run("AzureDeployment", func(t *testing.T) {
+		provider := &config.Provider{
+			APIBaseURL: "https://example.openai.azure.com",
+			APIKey:     "test-key",
+			Deployment: "my-gpt4",
+			APIVersion: "2024-06-01",
+		}
+
+		body := map[string]interface{}{
+			"model": "azure,gpt-4",
+		}
+
+		req, err := pipeline.buildHTTPRequest(ctx, provider, body, false, "azure")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedURL := "https://example.openai.azure.com/openai/deployments/my-gpt4/chat/completions?api-version=2024-06-01"
+		if req.URL.String() != expectedURL {
+			t.Errorf("Expected URL %s, got %s", expectedURL, req.URL.String())
+		}
+
+		if req.Header.Get("api-key") != "test-key" {
+			t.Error("Expected api-key header")
+		}
+	})
+
+	t.Run("AzureModelAsDeployment", func(t *testing.T) {
+		provider := &config.Provider{
+			APIBaseURL: "https://example.openai.azure.com/",
+			APIKey:     "test-key",
+		}
+
+		body := map[string]interface{}{
+			"model": "azure,gpt-4o-mini",
+		}
+
+		req, err := pipeline.buildHTTPRequest(ctx, provider, body, false, "azure")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedURL := "https://example.openai.azure.com/openai/deployments/gpt-4o-mini/chat/completions?api-version=" + config.DefaultAzureAPIVersion
+		if req.URL.String() != expectedURL {
+			t.Errorf("Expected URL %s, got %s", expectedURL, req.URL.String())
+		}
+	})
+
+	t.Run("ProviderCustomHeaders", func(t *testing.T) {
+		provider := &config.Provider{
+			Name:       "openrouter",
+			APIBaseURL: "https://openrouter.ai/api",
+			APIKey:     "test-key",
+			Headers: map[string]string{
+				"http-referer":  "https://example.com",
+				"X-Title":       "Example App",
+				"Authorization": "Bearer clobbered",
+				"Content-Type":  "application/vnd.custom+json",
+			},
+		}
+
+		req, err := pipeline.buildHTTPRequest(ctx, provider, map[string]interface{}{"model": "gpt-4"}, false, "openrouter")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if req.Header.Get("HTTP-Referer") != "https://example.com" || req.Header.Get("X-Title") != "Example App" {
+			t.Errorf("Expected custom headers, got %v", req.Header)
+		}
+		if req.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Expected auth header to be kept, got %s", req.Header.Get("Authorization"))
+		}
+		if req.Header.Get("Content-Type") != "application/vnd.custom+json" {
+			t.Errorf("Expected custom Content-Type, got %s", req.Header.Get("Content-Type"))
+		}
+	})
+
+	t.Run("AnthropicVersionHeaders", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			provider    config.Provider
+			wantVersion string
+			wantBeta    string
+		}{
+			{"default", config.Provider{APIKey: "test-key"}, config.DefaultAnthropicVersion, ""},
+			{"pinned", config.Provider{APIKey: "test-key", APIVersion: "2024-01-01"}, "2024-01-01", ""},
+			{"beta header", config.Provider{
+				APIKey:  "test-key",
+				Headers: map[string]string{"anthropic-beta": "prompt-caching-2024-07-31"},
+			}, config.DefaultAnthropicVersion, "prompt-caching-2024-07-31"},
+			{"header override", config.Provider{
+				Headers: map[string]string{"anthropic-version": "2025-01-01"},
+			}, "2025-01-01", ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				provider := tt.provider
+				provider.APIBaseURL = "https://api.anthropic.com"
+
+				req, err := pipeline.buildHTTPRequest(ctx, &provider, map[string]interface{}{"model": "claude-3"}, false, "anthropic")
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if got := req.Header.Get("anthropic-version"); got != tt.wantVersion {
+					t.Errorf("Expected anthropic-version %q, got %q", tt.wantVersion, got)
+				}
+				if got := req.Header.Get("anthropic-beta"); got != tt.wantBeta {
+					t.Errorf("Expected anthropic-beta %q, got %q", tt.wantBeta, got)
+				}
+			})
+		}
+	})
+
+	t.Run("PromptCachingBeta", func(t *testing.T) {
+		cached := map[string]interface{}{
+			"model": "claude-3",
+			"system": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Long context", "cache_control": map[string]interface{}{"type": "ephemeral"}},
+			},
+		}
+		uncached := map[string]interface{}{"model": "claude-3", "system": "Short context"}
+
+		tests := []struct {
+			name         string
+			providerName string
+			headers      map[string]string
+			body         map[string]interface{}
+			wantBeta     string
+		}{
+			{"markers", "anthropic", nil, cached, transformer.PromptCachingBeta},
+			{"no markers", "anthropic", nil, uncached, ""},
+			{"other provider", "openai", nil, cached, ""},
+			{"merged with configured beta", "anthropic", map[string]string{"anthropic-beta": "tools-2024-04-04"}, cached, "tools-2024-04-04," + transformer.PromptCachingBeta},
+			{"already configured", "anthropic", map[string]string{"anthropic-beta": transformer.PromptCachingBeta}, cached, transformer.PromptCachingBeta},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				provider := &config.Provider{APIBaseURL: "https://api.example.com", APIKey: "test-key", Headers: tt.headers}
+
+				req, err := pipeline.buildHTTPRequest(ctx, provider, tt.body, false, tt.providerName)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if got := req.Header.Get("anthropic-beta"); got != tt.wantBeta {
+					t.Errorf("Expected anthropic-beta %q, got %q", tt.wantBeta, got)
+				}
+			})
+		}
+	})
+
+	t.Run("TraceContext", func(t *testing.T) {
+		provider := &config.Provider{APIBaseURL: "https://api.openai.com", APIKey: "test-key"}
+
+		req, err := pipeline.buildHTTPRequest(ctx, provider, map[string]interface{}{"model": "gpt-4"}, false, "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := req.Header.Get(tracing.TraceparentHeader); got != "" {
+			t.Errorf("Expected no traceparent without a span, got %s", got)
+		}
+
+		tracer := tracing.New(config.TracingConfig{Endpoint: "http://127.0.0.1:1"})
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = tracer.Shutdown(shutdownCtx)
+		}()
+		spanCtx, span := tracer.Start(ctx, "request", map[string]string{
+			"Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		})
+
+		req, err = pipeline.buildHTTPRequest(spanCtx, provider, map[string]interface{}{"model": "gpt-4"}, false, "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got, want := req.Header.Get(tracing.TraceparentHeader), span.Context().Traceparent(); got != want {
+			t.Errorf("Expected traceparent %s, got %s", want, got)
+		}
+	})
+
+	t.Run("BasePath", func(t *testing.T) {
+		tests := []struct {
+			baseURL, basePath, providerName, want string
+		}{
+			{"https://gateway.example.com", "/llm/openai", "openai", "https://gateway.example.com/llm/openai/v1/chat/completions"},
+			{"https://gateway.example.com/", "llm/openai/", "openai", "https://gateway.example.com/llm/openai/v1/chat/completions"},
+			{"https://gateway.example.com/", "//llm/anthropic//", "anthropic", "https://gateway.example.com/llm/anthropic/v1/messages"},
+			{"https://gateway.example.com", "", "groq", "https://gateway.example.com/openai/v1/chat/completions"},
+		}
+
+		for _, tt := range tests {
+			provider := &config.Provider{APIBaseURL: tt.baseURL, BasePath: tt.basePath, APIKey: "test-key"}
+			req, err := pipeline.buildHTTPRequest(ctx, provider, map[string]interface{}{"model": "gpt-4"}, false, tt.providerName)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if req.URL.String() != tt.want {
+				t.Errorf("Expected URL %s for base path %q, got %s", tt.want, tt.basePath, req.URL.String())
+			}
+		}
+
+		// Azure deployment endpoints keep their query after the base path
+		provider := &config.Provider{APIBaseURL: "https://gateway.example.com", BasePath: "/azure", APIKey: "test-key", Deployment: "gpt4"}
+		req, err := pipeline.buildHTTPRequest(ctx, provider, map[string]interface{}{"model": "gpt-4"}, false, "azure")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := "https://gateway.example.com/azure/openai/deployments/gpt4/chat/completions?api-version=2024-02-01"; req.URL.String() != want {
+			t.Errorf("Expected URL %s, got %s", want, req.URL.String())
+		}
+	})
+
+	t.Run("GeminiAPIVersion", func(t *testing.T) {
+		tests := []struct {
+			version   string
+			streaming bool
+			want      string
+		}{
+			{"", false, "https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-pro:generateContent"},
+			{"v1", false, "https://generativelanguage.googleapis.com/v1/models/gemini-1.5-pro:generateContent"},
+			{"v1alpha", false, "https://generativelanguage.googleapis.com/v1alpha/models/gemini-1.5-pro:generateContent"},
+			{"", true, "https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-pro:streamGenerateContent?alt=sse"},
+		}
+
+		for _, tt := range tests {
+			provider := &config.Provider{APIBaseURL: "https://generativelanguage.googleapis.com", APIKey: "test-key", APIVersion: tt.version}
+			body := geminiRequest(provider, map[string]interface{}{"contents": []interface{}{}}, "gemini-1.5-pro", tt.streaming)
+			req, err := pipeline.buildHTTPRequest(ctx, provider, body, tt.streaming, "gemini")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if req.URL.String() != tt.want {
+				t.Errorf("Expected URL %s for version %q, got %s", tt.want, tt.version, req.URL.String())
+			}
+		}
+	})
+
+	t.Run("CustomAuthHeaderWithoutAPIKey", func(t *testing.T) {
+		provider := &config.Provider{
+			APIBaseURL: "https://gateway.example.com",
+			Headers:    map[string]string{"Authorization": "Basic abc123"},
+		}
+
+		req, err := pipeline.buildHTTPRequest(ctx, provider, map[string]interface{}{"model": "gpt-4"}, false, "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if req.Header.Get("Authorization") != "Basic abc123" {
+			t.Errorf("Expected custom Authorization header, got %s", req.Header.Get("Authorization"))
+		}
+	})
+
 	t.Run("InvalidJSON", func(t *testing.T) {
 		provider := &config.Provider{
 			APIBaseURL: "https://api.openai.com",
@@ -506,6 +777,10 @@ func TestHandleStreamingError(t *testing.T) {
 	if !strings.Contains(body, "event: error") {
 		t.Error("Expected error event type")
 	}
+
+	if !strings.Contains(body, `{"error":{"message":"test streaming error","type":"api_error"},"type":"error"}`) {
+		t.Errorf("Expected an Anthropic error envelope, got %s", body)
+	}
 }
 
 func TestRequestContext(t *testing.T) {
@@ -741,6 +1016,371 @@ func TestPipeline_ProcessRequest(t *testing.T) {
 		}
 	})
 
+	t.Run("ToolRoundtripThreshold", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			w.Write([]byte(`{"choices": [{"message": {"content": "Done"}}]}`))
+		}))
+		defer server.Close()
+
+		cfg.Providers[0].APIBaseURL = server.URL
+		configService.SetConfig(cfg)
+		providerService.Initialize()
+
+		cfg.Performance.MaxToolRoundtrips = 2
+		defer func() { cfg.Performance.MaxToolRoundtrips = 0 }()
+
+		messages := []interface{}{
+			map[string]interface{}{"role": "user", "content": "Fix the failing build"},
+		}
+		for i := 0; i < 3; i++ {
+			id := fmt.Sprintf("toolu_%d", i)
+			messages = append(messages,
+				map[string]interface{}{
+					"role": "assistant",
+					"content": []interface{}{
+						map[string]interface{}{"type": "tool_use", "id": id, "name": "bash", "input": map[string]interface{}{}},
+					},
+				},
+				map[string]interface{}{
+					"role": "user",
+					"content": []interface{}{
+						map[string]interface{}{"type": "tool_result", "tool_use_id": id, "content": "ok"},
+					},
+				},
+			)
+		}
+
+		req := &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "gpt-4",
+				"messages": messages,
+			},
+			Headers: map[string]string{},
+		}
+
+		respCtx, err := pipeline.ProcessRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if respCtx.ToolRoundtrips != 3 {
+			t.Errorf("Expected 3 tool roundtrips, got %d", respCtx.ToolRoundtrips)
+		}
+
+		if !respCtx.ToolRoundtripsExceeded {
+			t.Error("Expected conversation to be flagged for exceeding the roundtrip threshold")
+		}
+	})
+
+	t.Run("LongContextRouteThreshold", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			w.Write([]byte(`{"choices": [{"message": {"content": "Long context response"}}]}`))
+		}))
+		defer server.Close()
+
+		cfg.Providers[0].APIBaseURL = server.URL
+		configService.SetConfig(cfg)
+		providerService.Initialize()
+
+		cfg.Routes["longContext"] = config.Route{
+			Provider:  "openai",
+			Model:     "gpt-4-turbo",
+			Threshold: 1000,
+		}
+		defer delete(cfg.Routes, "longContext")
+
+		req := &RequestContext{
+			Body: map[string]interface{}{
+				"model": "claude-3-sonnet",
+				"messages": []interface{}{
+					map[string]interface{}{
+						"role":    "user",
+						"content": strings.Repeat("This is a long message. ", 500),
+					},
+				},
+			},
+			Headers:     map[string]string{},
+			IsStreaming: false,
+		}
+
+		respCtx, err := pipeline.ProcessRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if respCtx.Model != "gpt-4-turbo" {
+			t.Errorf("Expected long context model, got %s", respCtx.Model)
+		}
+
+		if !strings.Contains(respCtx.RoutingStrategy, "exceeds threshold (1000)") {
+			t.Errorf("Expected routing strategy to record threshold decision, got %s", respCtx.RoutingStrategy)
+		}
+	})
+
+	t.Run("ForcedRoute", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			w.Write([]byte(`{"choices": [{"message": {"content": "Forced response"}}]}`))
+		}))
+		defer server.Close()
+
+		cfg.Providers[0].APIBaseURL = server.URL
+		configService.SetConfig(cfg)
+		providerService.Initialize()
+
+		cfg.Routes["longContext"] = config.Route{Provider: "openai", Model: "gpt-4-turbo"}
+		cfg.AllowRouteHeader = true
+		defer func() {
+			delete(cfg.Routes, "longContext")
+			cfg.AllowRouteHeader = false
+		}()
+
+		request := func(route string) *RequestContext {
+			return &RequestContext{
+				Body: map[string]interface{}{
+					"model":    "gpt-4",
+					"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+				},
+				Headers: map[string]string{"x-ccproxy-route": route},
+			}
+		}
+
+		// A short request for a routed model is forced onto the long context route
+		respCtx, err := pipeline.ProcessRequest(context.Background(), request("longcontext"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		respCtx.Response.Body.Close()
+		if respCtx.Model != "gpt-4-turbo" {
+			t.Errorf("Expected forced route model, got %s", respCtx.Model)
+		}
+		if respCtx.RoutingStrategy != "route longContext forced by X-CCProxy-Route header" {
+			t.Errorf("Expected routing strategy to record the forced route, got %s", respCtx.RoutingStrategy)
+		}
+
+		// Unknown routes are rejected
+		_, err = pipeline.ProcessRequest(context.Background(), request("missing"))
+		var ccErr *ccerrors.CCProxyError
+		if !errors.As(err, &ccErr) || ccErr.Type != ccerrors.ErrorTypeValidationError {
+			t.Errorf("Expected a validation error for an unknown route, got %v", err)
+		}
+
+		// Without the flag the header is ignored
+		cfg.AllowRouteHeader = false
+		respCtx, err = pipeline.ProcessRequest(context.Background(), request("missing"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		respCtx.Response.Body.Close()
+		if respCtx.Model != "gpt-4" {
+			t.Errorf("Expected automatic routing when the header is not allowed, got %s", respCtx.Model)
+		}
+	})
+
+	t.Run("DefaultFrequencyPenalty", func(t *testing.T) {
+		var upstreamBody map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			upstreamBody = nil
+			json.NewDecoder(r.Body).Decode(&upstreamBody)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			w.Write([]byte(`{"choices": [{"message": {"content": "Hello"}}]}`))
+		}))
+		defer server.Close()
+
+		send := func(t *testing.T, providerDefault *float64, body map[string]interface{}) {
+			t.Helper()
+			cfg.Providers[0].APIBaseURL = server.URL
+			cfg.Providers[0].DefaultFrequencyPenalty = providerDefault
+			configService.SetConfig(cfg)
+			providerService.Initialize()
+
+			body["messages"] = []interface{}{
+				map[string]interface{}{"role": "user", "content": "Hello"},
+			}
+			req := &RequestContext{Body: body, Headers: map[string]string{}}
+			if _, err := pipeline.ProcessRequest(context.Background(), req); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		defer func() { cfg.Providers[0].DefaultFrequencyPenalty = nil }()
+
+		penalty := func(v float64) *float64 { return &v }
+
+		t.Run("InjectedWhenAbsent", func(t *testing.T) {
+			send(t, penalty(0.3), map[string]interface{}{"model": "gpt-4"})
+			if upstreamBody["frequency_penalty"] != 0.3 {
+				t.Errorf("Expected injected frequency_penalty 0.3, got %v", upstreamBody["frequency_penalty"])
+			}
+		})
+
+		t.Run("ExplicitValueKept", func(t *testing.T) {
+			send(t, penalty(0.3), map[string]interface{}{"model": "gpt-4", "frequency_penalty": 0.0})
+			if upstreamBody["frequency_penalty"] != 0.0 {
+				t.Errorf("Expected explicit frequency_penalty 0, got %v", upstreamBody["frequency_penalty"])
+			}
+		})
+
+		t.Run("ClampedToRange", func(t *testing.T) {
+			send(t, penalty(5), map[string]interface{}{"model": "gpt-4"})
+			if upstreamBody["frequency_penalty"] != 2.0 {
+				t.Errorf("Expected clamped frequency_penalty 2, got %v", upstreamBody["frequency_penalty"])
+			}
+		})
+
+		t.Run("RouteOverridesProvider", func(t *testing.T) {
+			route := cfg.Routes["gpt-4"]
+			cfg.Routes["gpt-4"] = config.Route{
+				Provider:   route.Provider,
+				Model:      route.Model,
+				Parameters: map[string]interface{}{"frequency_penalty": -3.0},
+			}
+			defer func() { cfg.Routes["gpt-4"] = route }()
+
+			send(t, penalty(0.3), map[string]interface{}{"model": "gpt-4"})
+			if upstreamBody["frequency_penalty"] != -2.0 {
+				t.Errorf("Expected clamped route frequency_penalty -2, got %v", upstreamBody["frequency_penalty"])
+			}
+		})
+
+		t.Run("NoDefaultConfigured", func(t *testing.T) {
+			send(t, nil, map[string]interface{}{"model": "gpt-4"})
+			if _, exists := upstreamBody["frequency_penalty"]; exists {
+				t.Errorf("Expected no frequency_penalty, got %v", upstreamBody["frequency_penalty"])
+			}
+		})
+	})
+
+	t.Run("DefaultParameterPrecedence", func(t *testing.T) {
+		var upstreamBody map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			upstreamBody = nil
+			json.NewDecoder(r.Body).Decode(&upstreamBody)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			w.Write([]byte(`{"choices": [{"message": {"content": "Hello"}}]}`))
+		}))
+		defer server.Close()
+
+		route := cfg.Routes["gpt-4"]
+		defer func() {
+			cfg.Parameters = nil
+			cfg.Providers[0].Parameters = nil
+			cfg.Routes["gpt-4"] = route
+		}()
+
+		cfg.Parameters = map[string]interface{}{"temperature": 0.1, "top_p": 0.1, "seed": 1.0, "max_tokens": 100.0}
+		cfg.Providers[0].Parameters = map[string]interface{}{"temperature": 0.2, "top_p": 0.2, "seed": 2.0}
+		cfg.Routes["gpt-4"] = config.Route{
+			Provider:   route.Provider,
+			Model:      route.Model,
+			Parameters: map[string]interface{}{"temperature": 0.3, "top_p": 0.3},
+		}
+		cfg.Providers[0].APIBaseURL = server.URL
+		configService.SetConfig(cfg)
+		providerService.Initialize()
+
+		req := &RequestContext{
+			Body: map[string]interface{}{
+				"model":       "gpt-4",
+				"temperature": 0.4,
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": "Hello"},
+				},
+			},
+			Headers: map[string]string{},
+		}
+		if _, err := pipeline.ProcessRequest(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		tests := []struct {
+			param string
+			want  float64
+			layer string
+		}{
+			{"temperature", 0.4, "request"},
+			{"top_p", 0.3, "route"},
+			{"seed", 2.0, "provider"},
+			{"max_tokens", 100.0, "global"},
+		}
+		for _, tt := range tests {
+			if upstreamBody[tt.param] != tt.want {
+				t.Errorf("Expected %s %v from the %s layer, got %v", tt.param, tt.want, tt.layer, upstreamBody[tt.param])
+			}
+		}
+	})
+
+	t.Run("ModelAccessCheckedAfterRouting", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			w.Write([]byte(`{"choices": [{"message": {"content": "Hello"}}]}`))
+		}))
+		defer server.Close()
+
+		cfg.Providers[0].APIBaseURL = server.URL
+		configService.SetConfig(cfg)
+		providerService.Initialize()
+
+		cfg.Routes["longContext"] = config.Route{
+			Provider:  "openai",
+			Model:     "gpt-4-turbo",
+			Threshold: 1000,
+		}
+		defer delete(cfg.Routes, "longContext")
+
+		checker := &fakeModelAccessChecker{allowed: map[string]bool{"gpt-4": true}}
+		pipeline.SetModelAccessChecker(checker)
+		defer pipeline.SetModelAccessChecker(nil)
+
+		newRequest := func(model, content, keyHash string) *RequestContext {
+			req := &RequestContext{
+				Body: map[string]interface{}{
+					"model": model,
+					"messages": []interface{}{
+						map[string]interface{}{"role": "user", "content": content},
+					},
+				},
+				Headers:  map[string]string{},
+				Metadata: map[string]interface{}{},
+			}
+			if keyHash != "" {
+				req.Metadata["api_key_hash"] = keyHash
+			}
+			return req
+		}
+
+		// Short request stays on the permitted model
+		if _, err := pipeline.ProcessRequest(context.Background(), newRequest("gpt-4", "Hello", "key-hash")); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// Long request is routed to a model the key may not use
+		longContent := strings.Repeat("This is a long message. ", 500)
+		_, err := pipeline.ProcessRequest(context.Background(), newRequest("claude-3-sonnet", longContent, "key-hash"))
+		if err == nil || !strings.Contains(err.Error(), "model access denied") {
+			t.Fatalf("Expected model access error, got %v", err)
+		}
+		if last := checker.checked[len(checker.checked)-1]; last != "gpt-4-turbo" {
+			t.Errorf("Expected check against routed model gpt-4-turbo, got %s", last)
+		}
+
+		// Requests without an authenticated key skip the check
+		checks := len(checker.checked)
+		if _, err := pipeline.ProcessRequest(context.Background(), newRequest("claude-3-sonnet", longContent, "")); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(checker.checked) != checks {
+			t.Error("Expected no model access check without an API key")
+		}
+	})
+
 	t.Run("ProviderNotFound", func(t *testing.T) {
 		// Create pipeline with no providers configured
 		emptyCfg := &config.Config{
@@ -875,6 +1515,51 @@ func TestPipeline_ProcessRequest(t *testing.T) {
 			t.Logf("Transformation handled invalid JSON gracefully")
 		}
 	})
+
+	t.Run("ProviderErrorTranslated", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "20")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "Rate limit reached", "code": "rate_limit_exceeded"}}`))
+		}))
+		defer server.Close()
+
+		cfg.Providers[0].APIBaseURL = server.URL
+		configService.SetConfig(cfg)
+		providerService.Initialize()
+
+		req := &RequestContext{
+			Body: map[string]interface{}{
+				"model":  "gpt-4",
+				"stream": true,
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": "Hello"},
+				},
+			},
+			Headers:     map[string]string{},
+			IsStreaming: true,
+		}
+
+		respCtx, err := pipeline.ProcessRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+
+		if respCtx.Response.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("Expected status 429, got %d", respCtx.Response.StatusCode)
+		}
+		if got := respCtx.Response.Header.Get("Retry-After"); got != "20" {
+			t.Errorf("Expected Retry-After to be preserved, got %q", got)
+		}
+
+		body, _ := io.ReadAll(respCtx.Response.Body)
+		expected := `{"error":{"message":"Rate limit reached","type":"rate_limit_error"},"type":"error"}`
+		if string(body) != expected {
+			t.Errorf("Expected Anthropic error body %s, got %s", expected, body)
+		}
+	})
 }
 
 // Test the Pipeline.StreamResponse method (not the global function)
@@ -1056,3 +1741,280 @@ func TestPipeline_CreationErrors(t *testing.T) {
 		}
 	})
 }
+
+func TestApplyJitter(t *testing.T) {
+	t.Run("ZeroJitterIsNoop", func(t *testing.T) {
+		start := time.Now()
+		if err := applyJitter(context.Background(), 0); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Millisecond {
+			t.Errorf("Expected no delay for zero jitter, got %v", elapsed)
+		}
+	})
+
+	t.Run("DelayBoundedByMaxJitter", func(t *testing.T) {
+		maxJitter := 20 * time.Millisecond
+		for i := 0; i < 5; i++ {
+			start := time.Now()
+			if err := applyJitter(context.Background(), maxJitter); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			// Allow a little scheduling slack on top of the configured bound
+			if elapsed := time.Since(start); elapsed > maxJitter+15*time.Millisecond {
+				t.Errorf("Expected delay of at most %v, got %v", maxJitter, elapsed)
+			}
+		}
+	})
+
+	t.Run("CanceledContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := applyJitter(ctx, time.Hour)
+		if err == nil {
+			t.Error("Expected error for canceled context")
+		}
+	})
+}
+
+func TestPipeline_SendRequestProviderTimeout(t *testing.T) {
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{
+			RequestTimeout: 50 * time.Millisecond,
+		},
+	}
+	pipeline := NewPipeline(cfg, &providers.Service{}, transformer.NewService(), router.New(cfg))
+
+	slowHeaders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer slowHeaders.Close()
+
+	slowStream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: {\"chunk\": %d}\n\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+	}))
+	defer slowStream.Close()
+
+	newRequest := func(url string) *http.Request {
+		req, err := http.NewRequest("POST", url, strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		return req
+	}
+
+	t.Run("OverridesGlobalTimeout", func(t *testing.T) {
+		provider := &config.Provider{Name: "ollama", Timeout: time.Second}
+
+		resp, err := pipeline.sendRequest(newRequest(slowHeaders.URL), provider, false)
+		if err != nil {
+			t.Fatalf("Expected provider timeout to allow slow response, got: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if _, err := io.ReadAll(resp.Body); err != nil {
+			t.Errorf("Failed to read body: %v", err)
+		}
+	})
+
+	t.Run("FallsBackToGlobalTimeout", func(t *testing.T) {
+		provider := &config.Provider{Name: "groq"}
+
+		_, err := pipeline.sendRequest(newRequest(slowHeaders.URL), provider, false)
+		if err == nil {
+			t.Error("Expected global timeout to apply when provider timeout is unset")
+		}
+	})
+
+	t.Run("ProviderTimeoutExceeded", func(t *testing.T) {
+		provider := &config.Provider{Name: "groq", Timeout: 20 * time.Millisecond}
+
+		_, err := pipeline.sendRequest(newRequest(slowHeaders.URL), provider, false)
+		if err == nil || !strings.Contains(err.Error(), "provider timeout exceeded") {
+			t.Errorf("Expected provider timeout error, got: %v", err)
+		}
+	})
+
+	t.Run("StreamingReadNotBounded", func(t *testing.T) {
+		// The stream outlives the timeout, but headers arrive well within it
+		provider := &config.Provider{Name: "ollama", Timeout: 100 * time.Millisecond}
+
+		resp, err := pipeline.sendRequest(newRequest(slowStream.URL), provider, true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Expected full stream to be readable, got: %v", err)
+		}
+		if strings.Count(string(body), "data:") != 3 {
+			t.Errorf("Expected 3 events, got body: %s", body)
+		}
+	})
+
+	t.Run("NonStreamingReadBounded", func(t *testing.T) {
+		provider := &config.Provider{Name: "ollama", Timeout: 100 * time.Millisecond}
+
+		resp, err := pipeline.sendRequest(newRequest(slowStream.URL), provider, false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if _, err := io.ReadAll(resp.Body); err == nil {
+			t.Error("Expected body read to be cut off by the provider timeout")
+		}
+	})
+}
+
+// fakeModelAccessChecker allows a fixed set of models and records each check
+type fakeModelAccessChecker struct {
+	allowed map[string]bool
+	checked []string
+}
+
+func (f *fakeModelAccessChecker) CheckModelAccess(apiKeyHash, model string) error {
+	f.checked = append(f.checked, model)
+	if !f.allowed[model] {
+		return fmt.Errorf("model %s is not allowed", model)
+	}
+	return nil
+}
+
+func TestPipeline_LatencyStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"choices": [{"message": {"content": "Hello"}}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"},
+		},
+		Routes: map[string]config.Route{
+			"default": {Provider: "openai", Model: "gpt-4"},
+		},
+	}
+
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	if err := providerService.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize provider service: %v", err)
+	}
+	pipeline := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+
+	if stats := pipeline.LatencyStats(); len(stats) != 0 {
+		t.Fatalf("Expected no latency stats before any request, got %v", stats)
+	}
+
+	for i := 0; i < 3; i++ {
+		respCtx, err := pipeline.ProcessRequest(context.Background(), &RequestContext{
+			Body: map[string]interface{}{"model": "gpt-4", "messages": []interface{}{}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		respCtx.Response.Body.Close()
+	}
+
+	stats, ok := pipeline.LatencyStats()["openai"]
+	if !ok {
+		t.Fatal("Expected latency stats for openai")
+	}
+	if stats.Requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", stats.Requests)
+	}
+	if stats.P50Ms <= 0 || stats.P50Ms > stats.P99Ms {
+		t.Errorf("Expected ordered positive percentiles, got p50=%v p99=%v", stats.P50Ms, stats.P99Ms)
+	}
+
+	total := 0
+	for _, count := range stats.Histogram {
+		total += count
+	}
+	if total != 3 {
+		t.Errorf("Expected histogram to hold 3 samples, got %d", total)
+	}
+}
+
+func TestPipeline_GeminiResponseModel(t *testing.T) {
+	var upstreamURI string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			upstreamURI = r.URL.RequestURI()
+		}
+		candidate := `{"candidates":[{"content":{"parts":[{"text":"Hi"}],"role":"model"},"finishReason":"STOP"}]}`
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: " + candidate + "\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(candidate))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+		Providers:   []config.Provider{{Name: "gemini", APIBaseURL: server.URL, APIKey: "test-key"}},
+		Routes:      map[string]config.Route{"default": {Provider: "gemini", Model: "gemini-1.5-pro"}},
+	}
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	if err := providerService.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize providers: %v", err)
+	}
+	transformerService := transformer.NewService()
+	if err := transformer.RegisterBuiltinTransformers(transformerService); err != nil {
+		t.Fatalf("Failed to register transformers: %v", err)
+	}
+	pipeline := NewPipeline(cfg, providerService, transformerService, router.New(cfg))
+
+	wantURIs := map[bool]string{
+		false: "/v1beta/models/gemini-1.5-pro:generateContent",
+		true:  "/v1beta/models/gemini-1.5-pro:streamGenerateContent?alt=sse",
+	}
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Streaming=%v", streaming), func(t *testing.T) {
+			resp, err := pipeline.ProcessRequest(context.Background(), &RequestContext{
+				Body: map[string]interface{}{
+					"model":    "claude-3-opus",
+					"stream":   streaming,
+					"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+				},
+				IsStreaming: streaming,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Response.Body.Close()
+
+			body, _ := io.ReadAll(resp.Response.Body)
+			if !strings.Contains(string(body), `"model":"gemini-1.5-pro"`) {
+				t.Errorf("Expected the response to report gemini-1.5-pro, got %s", body)
+			}
+			if strings.Contains(string(body), `"gemini-pro"`) {
+				t.Errorf("Expected no hardcoded gemini-pro, got %s", body)
+			}
+			if upstreamURI != wantURIs[streaming] {
+				t.Errorf("Expected the request to be sent to %s, got %s", wantURIs[streaming], upstreamURI)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+)
+
+// defaultQueueTimeout bounds the wait for a global slot when queue_timeout is unset
+const defaultQueueTimeout = 5 * time.Second
+
+// requestQueue counts concurrent requests per route and applies the global
+// soft limit, queueing requests over the limit until a slot frees up
+type requestQueue struct {
+	slots   chan struct{} // nil when there is no global limit
+	timeout time.Duration
+	active  int64
+	queued  int64
+
+	routes   map[string]int64 // Active requests keyed by "provider,model"
+	routesMu sync.Mutex
+}
+
+// ConcurrencyStats reports current request concurrency for /status
+type ConcurrencyStats struct {
+	Active int64            `json:"active"`
+	Queued int64            `json:"queued"`
+	Limit  int              `json:"limit,omitempty"`
+	Routes map[string]int64 `json:"routes"`
+}
+
+// newRequestQueue creates a queue allowing limit concurrent requests, or any
+// number when limit is 0
+func newRequestQueue(limit int, timeout time.Duration) *requestQueue {
+	if timeout <= 0 {
+		timeout = defaultQueueTimeout
+	}
+	q := &requestQueue{
+		timeout: timeout,
+		routes:  make(map[string]int64),
+	}
+	if limit > 0 {
+		q.slots = make(chan struct{}, limit)
+	}
+	return q
+}
+
+// admit counts a request against route and waits for a global slot when the
+// limit is reached. The wait ends at the queue timeout or the context
+// deadline, whichever comes first. The returned func must be called once the
+// request is finished.
+func (q *requestQueue) admit(ctx context.Context, route string) (func(), error) {
+	if q.slots != nil {
+		select {
+		case q.slots <- struct{}{}:
+		default:
+			if err := q.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	atomic.AddInt64(&q.active, 1)
+	q.routesMu.Lock()
+	q.routes[route]++
+	q.routesMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.routesMu.Lock()
+			if q.routes[route]--; q.routes[route] <= 0 {
+				delete(q.routes, route)
+			}
+			q.routesMu.Unlock()
+			atomic.AddInt64(&q.active, -1)
+			if q.slots != nil {
+				<-q.slots
+			}
+		})
+	}, nil
+}
+
+// wait queues for a global slot
+func (q *requestQueue) wait(ctx context.Context) error {
+	atomic.AddInt64(&q.queued, 1)
+	defer atomic.AddInt64(&q.queued, -1)
+
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return q.limitError(context.DeadlineExceeded)
+	case <-ctx.Done():
+		return q.limitError(ctx.Err())
+	}
+}
+
+// limitError builds the 503 returned when a queued request gives up
+func (q *requestQueue) limitError(cause error) error {
+	err := ccerrors.Wrapf(cause, ccerrors.ErrorTypeResourceExhausted,
+		"server is at its concurrency limit of %d", cap(q.slots))
+	err.StatusCode = http.StatusServiceUnavailable
+	return err.WithCode("QUEUE_TIMEOUT")
+}
+
+// stats returns a snapshot of the current counts
+func (q *requestQueue) stats() ConcurrencyStats {
+	q.routesMu.Lock()
+	routes := make(map[string]int64, len(q.routes))
+	for route, count := range q.routes {
+		routes[route] = count
+	}
+	q.routesMu.Unlock()
+
+	return ConcurrencyStats{
+		Active: atomic.LoadInt64(&q.active),
+		Queued: atomic.LoadInt64(&q.queued),
+		Limit:  cap(q.slots),
+		Routes: routes,
+	}
+}
+
+// Concurrency returns the current global and per-route request counts
+func (p *Pipeline) Concurrency() ConcurrencyStats {
+	return p.queue.stats()
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_RequestQueue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"content": "Hello"}}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{
+			RequestTimeout:        30 * time.Second,
+			MaxConcurrentRequests: 1,
+			QueueTimeout:          time.Second,
+		},
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"},
+		},
+		Routes: map[string]config.Route{
+			"default": {Provider: "openai", Model: "gpt-4"},
+		},
+	}
+
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	testutil.AssertNoError(t, providerService.Initialize())
+	pipeline := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+
+	send := func(ctx context.Context) (*ResponseContext, error) {
+		return pipeline.ProcessRequest(ctx, &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "claude-3-opus",
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+			},
+		})
+	}
+
+	first, err := send(context.Background())
+	testutil.AssertNoError(t, err)
+
+	stats := pipeline.Concurrency()
+	testutil.AssertEqual(t, int64(1), stats.Active)
+	testutil.AssertEqual(t, 1, stats.Limit)
+	testutil.AssertEqual(t, int64(1), stats.Routes["openai,gpt-4"])
+
+	t.Run("QueuedRequestTimesOut", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := send(ctx)
+		testutil.AssertError(t, err)
+
+		var ccErr *ccerrors.CCProxyError
+		testutil.AssertTrue(t, errors.As(err, &ccErr), "Expected CCProxyError")
+		testutil.AssertEqual(t, http.StatusServiceUnavailable, ccErr.StatusCode)
+		testutil.AssertEqual(t, "QUEUE_TIMEOUT", ccErr.Code)
+		testutil.AssertEqual(t, int64(0), pipeline.Concurrency().Queued)
+	})
+
+	t.Run("QueuedRequestRunsWhenSlotFrees", func(t *testing.T) {
+		done := make(chan error, 1)
+		go func() {
+			resp, err := send(context.Background())
+			if err == nil {
+				err = resp.Response.Body.Close()
+			}
+			done <- err
+		}()
+
+		// Wait for the request to queue, then free the slot
+		deadline := time.Now().Add(time.Second)
+		for pipeline.Concurrency().Queued == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		testutil.AssertEqual(t, int64(1), pipeline.Concurrency().Queued)
+		testutil.AssertNoError(t, first.Response.Body.Close())
+
+		testutil.AssertNoError(t, <-done)
+		stats := pipeline.Concurrency()
+		testutil.AssertEqual(t, int64(0), stats.Active)
+		testutil.AssertEqual(t, 0, len(stats.Routes))
+	})
+}
+
+func TestRequestQueue_Unlimited(t *testing.T) {
+	q := newRequestQueue(0, 0)
+	testutil.AssertEqual(t, defaultQueueTimeout, q.timeout)
+
+	var releases []func()
+	for i := 0; i < 3; i++ {
+		release, err := q.admit(context.Background(), "groq,llama")
+		testutil.AssertNoError(t, err)
+		releases = append(releases, release)
+	}
+	stats := q.stats()
+	testutil.AssertEqual(t, int64(3), stats.Active)
+	testutil.AssertEqual(t, 0, stats.Limit)
+	testutil.AssertEqual(t, int64(3), stats.Routes["groq,llama"])
+
+	for _, release := range releases {
+		release()
+		release() // Releasing twice is harmless
+	}
+	testutil.AssertEqual(t, int64(0), q.stats().Active)
+}
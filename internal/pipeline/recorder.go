@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// recordingSeq keeps recording file names unique within a process
+var recordingSeq int64
+
+// StreamRecorder writes the raw upstream SSE events of a single streamed
+// response to a file, one JSON-encoded event per line, for later replay
+type StreamRecorder struct {
+	file   *os.File
+	writer *bufio.Writer
+	path   string
+	mu     sync.Mutex
+}
+
+// NewStreamRecorder creates a recording file for a provider stream in dir
+func NewStreamRecorder(dir, provider string) (*StreamRecorder, error) {
+	if err := utils.EnsureDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to create stream record directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%d-%d-%s.jsonl", time.Now().UnixNano(), atomic.AddInt64(&recordingSeq, 1), provider)
+	path := filepath.Join(dir, filepath.Base(name))
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600) // #nosec G304 - Path is built from configured directory
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream recording: %w", err)
+	}
+
+	return &StreamRecorder{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		path:   path,
+	}, nil
+}
+
+// Record appends an event to the recording
+func (r *StreamRecorder) Record(event *transformer.SSEEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode SSE event: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write SSE event: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the recording file
+func (r *StreamRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.writer.Flush(); err != nil {
+		_ = r.file.Close() // Safe to ignore: already returning flush error
+		return fmt.Errorf("failed to flush stream recording: %w", err)
+	}
+	return r.file.Close()
+}
+
+// Path returns the recording file path
+func (r *StreamRecorder) Path() string {
+	return r.path
+}
+
+// ReadStreamRecording loads the events of a recorded stream in order
+func ReadStreamRecording(path string) ([]*transformer.SSEEvent, error) {
+	file, err := os.Open(path) // #nosec G304 - Path is provided by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream recording: %w", err)
+	}
+	defer file.Close()
+
+	var events []*transformer.SSEEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var event transformer.SSEEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode SSE event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream recording: %w", err)
+	}
+
+	return events, nil
+}
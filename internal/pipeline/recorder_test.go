@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestStreamRecorder_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	recorder, err := NewStreamRecorder(dir, "anthropic")
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	events := []*transformer.SSEEvent{
+		{Event: "message_start", Data: `{"type":"message_start"}`},
+		{Event: "content_block_delta", Data: "{\"type\":\"content_block_delta\",\"text\":\"line one\\nline two\"}", ID: "2"},
+		{Data: "[DONE]"},
+	}
+
+	for _, event := range events {
+		if err := recorder.Record(event); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	if filepath.Dir(recorder.Path()) != dir {
+		t.Errorf("Expected recording in %s, got %s", dir, recorder.Path())
+	}
+
+	replayed, err := ReadStreamRecording(recorder.Path())
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+
+	if len(replayed) != len(events) {
+		t.Fatalf("Expected %d events, got %d", len(events), len(replayed))
+	}
+	for i := range events {
+		if *replayed[i] != *events[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, *events[i], *replayed[i])
+		}
+	}
+}
+
+func TestStreamingProcessor_RecordsStream(t *testing.T) {
+	dir := t.TempDir()
+
+	processor := NewStreamingProcessor(transformer.NewService())
+	processor.SetRecordDir(dir)
+
+	sseData := "event: message_start\ndata: {\"type\": \"message_start\"}\n\n" +
+		"data: {\"chunk\": \"hello\"}\n\n" +
+		"data: {\"chunk\": \"world\"}\n\n" +
+		"data: [DONE]\n\n"
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(sseData)),
+	}
+
+	if err := processor.ProcessStreamingResponse(context.Background(), httptest.NewRecorder(), resp, "openai"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to list record dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected one recording, got %d", len(entries))
+	}
+	if !strings.HasSuffix(entries[0].Name(), "-openai.jsonl") {
+		t.Errorf("Expected recording name to include provider, got %s", entries[0].Name())
+	}
+
+	// Replaying the recording must yield the upstream events in order
+	expected, err := readAllEvents(sseData)
+	if err != nil {
+		t.Fatalf("Failed to parse source stream: %v", err)
+	}
+
+	replayed, err := ReadStreamRecording(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+
+	if len(replayed) != len(expected) {
+		t.Fatalf("Expected %d recorded events, got %d", len(expected), len(replayed))
+	}
+	for i := range expected {
+		if *replayed[i] != *expected[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, *expected[i], *replayed[i])
+		}
+	}
+}
+
+func TestStreamingProcessor_RecordingDisabled(t *testing.T) {
+	dir := t.TempDir()
+	processor := NewStreamingProcessor(transformer.NewService())
+
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader("data: [DONE]\n\n")),
+	}
+
+	if err := processor.ProcessStreamingResponse(context.Background(), httptest.NewRecorder(), resp, "openai"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected no recordings when disabled, got %d", len(entries))
+	}
+}
+
+// readAllEvents parses a raw SSE stream into events
+func readAllEvents(data string) ([]*transformer.SSEEvent, error) {
+	reader := transformer.NewSSEReader(io.NopCloser(strings.NewReader(data)))
+	var events []*transformer.SSEEvent
+	for {
+		event, err := reader.ReadEvent()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"net/http"
+
+	"github.com/orchestre-dev/ccproxy/internal/version"
+)
+
+// RequestIDHeader carries the request id assigned by the server so provider
+// logs can be correlated with CCProxy's
+const RequestIDHeader = "X-Request-ID"
+
+// defaultUserAgent is the product sent upstream when user_agent is unset
+const defaultUserAgent = "ccproxy"
+
+// upstreamRequestIDHeaders are the headers providers return their own
+// request id in, checked in order
+var upstreamRequestIDHeaders = []string{"request-id", "x-request-id"}
+
+// userAgent returns the outbound User-Agent. The CCProxy version is always
+// included, after the configured product when one is set.
+func (p *Pipeline) userAgent() string {
+	product := defaultUserAgent + "/" + version.Version
+	cfg := p.currentConfig()
+	if cfg == nil || cfg.UserAgent == "" {
+		return product
+	}
+	return cfg.UserAgent + " " + product
+}
+
+// setRequestID forwards the inbound request id to the provider
+func setRequestID(httpReq *http.Request, req *RequestContext) {
+	if id := req.Headers[RequestIDHeader]; id != "" {
+		httpReq.Header.Set(RequestIDHeader, id)
+	}
+}
+
+// upstreamRequestID returns the request id reported by the provider, or ""
+// when it sent none
+func upstreamRequestID(resp *http.Response) string {
+	for _, header := range upstreamRequestIDHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			return id
+		}
+	}
+	return ""
+}
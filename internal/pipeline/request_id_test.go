@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/orchestre-dev/ccproxy/internal/version"
+)
+
+func TestPipeline_RequestIDForwarding(t *testing.T) {
+	tests := []struct {
+		name          string
+		userAgent     string
+		requestID     string
+		upstreamIDKey string
+		wantUserAgent string
+		wantUpstream  string
+	}{
+		{
+			name:          "default user agent",
+			requestID:     "req-123",
+			upstreamIDKey: "request-id",
+			wantUserAgent: "ccproxy/" + version.Version,
+			wantUpstream:  "upstream-1",
+		},
+		{
+			name:          "configured user agent",
+			userAgent:     "acme-gateway/2.0",
+			upstreamIDKey: "x-request-id",
+			wantUserAgent: "acme-gateway/2.0 ccproxy/" + version.Version,
+			wantUpstream:  "upstream-1",
+		},
+		{
+			name:          "no upstream id",
+			requestID:     "req-456",
+			wantUserAgent: "ccproxy/" + version.Version,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header.Clone()
+				if tt.upstreamIDKey != "" {
+					w.Header().Set(tt.upstreamIDKey, "upstream-1")
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"choices": [{"message": {"content": "Hello"}}]}`))
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{
+				UserAgent:   tt.userAgent,
+				Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+				Providers: []config.Provider{
+					{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"},
+				},
+				Routes: map[string]config.Route{
+					"default": {Provider: "openai", Model: "gpt-4"},
+				},
+			}
+			configService := config.NewService()
+			configService.SetConfig(cfg)
+			providerService := providers.NewService(configService)
+			if err := providerService.Initialize(); err != nil {
+				t.Fatalf("Failed to initialize provider service: %v", err)
+			}
+			p := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+
+			headers := map[string]string{}
+			if tt.requestID != "" {
+				headers[RequestIDHeader] = tt.requestID
+			}
+			respCtx, err := p.ProcessRequest(context.Background(), &RequestContext{
+				Body: map[string]interface{}{
+					"model":    "gpt-4",
+					"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+				},
+				Headers: headers,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			respCtx.Response.Body.Close()
+
+			if got := received.Get("User-Agent"); got != tt.wantUserAgent {
+				t.Errorf("Expected User-Agent %q, got %q", tt.wantUserAgent, got)
+			}
+			if got := received.Get(RequestIDHeader); got != tt.requestID {
+				t.Errorf("Expected %s %q, got %q", RequestIDHeader, tt.requestID, got)
+			}
+			if respCtx.UpstreamID != tt.wantUpstream {
+				t.Errorf("Expected upstream request id %q, got %q", tt.wantUpstream, respCtx.UpstreamID)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/security"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// storableRequestID matches request ids that are safe to use as file names
+var storableRequestID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// StoredRequest is a request kept for replay, with how it was answered
+type StoredRequest struct {
+	ID         string          `json:"id"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Path       string          `json:"path"`
+	Streaming  bool            `json:"streaming"`
+	Provider   string          `json:"provider,omitempty"`
+	Model      string          `json:"model,omitempty"`
+	StatusCode int             `json:"status_code"`
+	DurationMS int64           `json:"duration_ms"`
+	Redacted   bool            `json:"redacted,omitempty"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// requestSweepInterval is how often stored requests are checked for age
+const requestSweepInterval = time.Minute
+
+// RequestStore keeps request bodies on disk by request id, one JSON file per
+// request, so they can be replayed later
+type RequestStore struct {
+	dir       string
+	sanitizer *security.DataSanitizer // Masks secrets in stored bodies, nil stores them as sent
+
+	// Retention, see SetRetention
+	maxRequests int
+	maxAge      time.Duration
+	mu          sync.Mutex
+	count       int // Stored requests at the last sweep plus those saved since
+	lastSweep   time.Time
+	sweeping    sync.Mutex
+}
+
+// NewRequestStore creates a store in dir. With redact set, secrets found in
+// the string values of a body are masked before it is written.
+func NewRequestStore(dir string, redact bool) (*RequestStore, error) {
+	if err := utils.EnsureDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to create request store directory: %w", err)
+	}
+
+	store := &RequestStore{dir: dir}
+	if redact {
+		sanitizer, err := security.NewDataSanitizer(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request redactor: %w", err)
+		}
+		store.sanitizer = sanitizer
+	}
+	return store, nil
+}
+
+// SetRetention bounds the stored requests. Once more than maxRequests are
+// stored the oldest are removed, leaving room for a tenth more before the
+// next sweep, and requests older than maxAge are removed every minute. Zero
+// disables each limit.
+func (s *RequestStore) SetRetention(maxRequests int, maxAge time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxRequests = maxRequests
+	s.maxAge = maxAge
+}
+
+// Save writes a request with its JSON body under its id. An id that is
+// already stored is refused, so a client reusing a request id cannot replace
+// another request.
+func (s *RequestStore) Save(req *StoredRequest, body []byte) error {
+	path, err := s.path(req.ID)
+	if err != nil {
+		return err
+	}
+
+	req.Body = body
+	if s.sanitizer != nil {
+		var decoded interface{}
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			return fmt.Errorf("failed to parse request body: %w", err)
+		}
+		if req.Body, err = json.Marshal(s.redact(decoded)); err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		req.Redacted = true
+	}
+
+	data, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode stored request: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600) // #nosec G304 - Path is built from the store directory and a validated id
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("request id %s is already stored", req.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write stored request: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write stored request: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write stored request: %w", err)
+	}
+
+	if s.sweepDue() {
+		s.sweep()
+	}
+	return nil
+}
+
+// sweepDue counts a saved request and reports whether the retention limits
+// should be checked
+func (s *RequestStore) sweepDue() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	if s.maxRequests <= 0 && s.maxAge <= 0 {
+		return false
+	}
+	return s.lastSweep.IsZero() ||
+		(s.maxRequests > 0 && s.count > s.maxRequests) ||
+		(s.maxAge > 0 && time.Since(s.lastSweep) >= requestSweepInterval)
+}
+
+// sweep removes stored requests beyond the retention limits. Saves that find
+// a sweep running skip theirs.
+func (s *RequestStore) sweep() {
+	if !s.sweeping.TryLock() {
+		return
+	}
+	defer s.sweeping.Unlock()
+
+	s.mu.Lock()
+	maxRequests, maxAge := s.maxRequests, s.maxAge
+	s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		utils.GetLogger().Warnf("Failed to read request store %s: %v", s.dir, err)
+		return
+	}
+
+	type storedFile struct {
+		path    string
+		modTime time.Time
+	}
+	files := make([]storedFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, storedFile{path: filepath.Join(s.dir, entry.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	// Keep the newest, trimming to nine tenths of the limit so the next
+	// sweep is a tenth of the limit away
+	keep := len(files)
+	if maxRequests > 0 && keep > maxRequests {
+		keep = maxRequests - maxRequests/10
+	}
+	if maxAge > 0 {
+		cutoff := time.Now().Add(-maxAge)
+		for keep > 0 && files[keep-1].modTime.Before(cutoff) {
+			keep--
+		}
+	}
+	for _, file := range files[keep:] {
+		if err := os.Remove(file.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			utils.GetLogger().Warnf("Failed to remove stored request %s: %v", file.path, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.count = keep
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+}
+
+// Load reads the request stored under id
+func (s *RequestStore) Load(id string) (*StoredRequest, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 - Path is built from the store directory and a validated id
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no stored request with id %s in %s", id, s.dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored request: %w", err)
+	}
+
+	var req StoredRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("failed to parse stored request %s: %w", id, err)
+	}
+	return &req, nil
+}
+
+// path returns the file of a request id, rejecting ids that could escape the
+// store directory
+func (s *RequestStore) path(id string) (string, error) {
+	if !storableRequestID.MatchString(id) {
+		return "", fmt.Errorf("request id %q cannot be stored", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// redact masks secrets in every string of a decoded JSON value. Keys are kept
+// as they are, so the request still has its shape.
+func (s *RequestStore) redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return s.sanitizer.RedactSecrets(v)
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			redacted[key] = s.redact(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = s.redact(item)
+		}
+		return redacted
+	}
+	return value
+}
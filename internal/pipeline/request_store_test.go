@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestStore(t *testing.T) {
+	body := []byte(`{"model":"claude-3","max_tokens":100,"messages":[{"role":"user","content":"my api_key=sk-secret123, mail me at dev@example.com"}]}`)
+
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		store, err := NewRequestStore(t.TempDir(), false)
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+
+		saved := &StoredRequest{ID: "req-1", Timestamp: time.Now().UTC(), Path: "/v1/messages", Provider: "anthropic", StatusCode: 200, DurationMS: 1234}
+		if err := store.Save(saved, body); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+
+		loaded, err := store.Load("req-1")
+		if err != nil {
+			t.Fatalf("Failed to load request: %v", err)
+		}
+		if loaded.Provider != "anthropic" || loaded.StatusCode != 200 || loaded.DurationMS != 1234 || loaded.Redacted {
+			t.Errorf("Unexpected stored request: %+v", loaded)
+		}
+		if !strings.Contains(string(loaded.Body), "sk-secret123") {
+			t.Errorf("Expected the body as sent, got %s", loaded.Body)
+		}
+	})
+
+	t.Run("Redacts", func(t *testing.T) {
+		store, err := NewRequestStore(t.TempDir(), true)
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		if err := store.Save(&StoredRequest{ID: "req-2"}, body); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+
+		loaded, err := store.Load("req-2")
+		if err != nil {
+			t.Fatalf("Failed to load request: %v", err)
+		}
+		if !loaded.Redacted {
+			t.Error("Expected the request to be marked redacted")
+		}
+		for _, secret := range []string{"sk-secret123", "dev@example.com"} {
+			if strings.Contains(string(loaded.Body), secret) {
+				t.Errorf("Expected %s to be redacted, got %s", secret, loaded.Body)
+			}
+		}
+
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(loaded.Body, &decoded); err != nil {
+			t.Fatalf("Expected a JSON body, got %s", loaded.Body)
+		}
+		if decoded["max_tokens"] != float64(100) {
+			t.Errorf("Expected fields other than strings to be kept, got %v", decoded)
+		}
+	})
+
+	t.Run("RejectsUnsafeIDs", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := NewRequestStore(filepath.Join(dir, "requests"), false)
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+
+		for _, id := range []string{"", "../escape", "a/b", ".hidden"} {
+			if err := store.Save(&StoredRequest{ID: id}, body); err == nil {
+				t.Errorf("Expected id %q to be rejected", id)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "escape.json")); err == nil {
+			t.Error("Expected no file outside the store directory")
+		}
+	})
+
+	t.Run("RefusesExistingID", func(t *testing.T) {
+		store, err := NewRequestStore(t.TempDir(), false)
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		if err := store.Save(&StoredRequest{ID: "req-3", Provider: "anthropic"}, body); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+		if err := store.Save(&StoredRequest{ID: "req-3", Provider: "openai"}, body); err == nil || !strings.Contains(err.Error(), "already stored") {
+			t.Errorf("Expected the second save to be refused, got %v", err)
+		}
+
+		loaded, err := store.Load("req-3")
+		if err != nil {
+			t.Fatalf("Failed to load request: %v", err)
+		}
+		if loaded.Provider != "anthropic" {
+			t.Errorf("Expected the first request to be kept, got %+v", loaded)
+		}
+	})
+
+	t.Run("KeepsNewestRequests", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := NewRequestStore(dir, false)
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		store.SetRetention(10, 0)
+
+		base := time.Now().Add(-time.Hour)
+		for i := 0; i < 11; i++ {
+			id := fmt.Sprintf("req-%02d", i)
+			if err := store.Save(&StoredRequest{ID: id}, body); err != nil {
+				t.Fatalf("Failed to save request %s: %v", id, err)
+			}
+			// Spread modification times, so the order does not depend on
+			// the file system's timestamp resolution
+			stamp := base.Add(time.Duration(i) * time.Minute)
+			if err := os.Chtimes(filepath.Join(dir, id+".json"), stamp, stamp); err != nil {
+				t.Fatalf("Failed to set modification time: %v", err)
+			}
+		}
+
+		// The eleventh save found more than ten and trimmed to nine
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("Failed to read store: %v", err)
+		}
+		if len(entries) != 9 {
+			t.Fatalf("Expected 9 stored requests, got %d", len(entries))
+		}
+		for _, id := range []string{"req-00", "req-01"} {
+			if _, err := store.Load(id); err == nil {
+				t.Errorf("Expected the oldest request %s to be removed", id)
+			}
+		}
+		if _, err := store.Load("req-10"); err != nil {
+			t.Errorf("Expected the newest request to be kept: %v", err)
+		}
+	})
+
+	t.Run("RemovesExpiredRequests", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := NewRequestStore(dir, false)
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		store.SetRetention(0, time.Hour)
+
+		if err := os.WriteFile(filepath.Join(dir, "old.json"), body, 0600); err != nil {
+			t.Fatalf("Failed to write old request: %v", err)
+		}
+		old := time.Now().Add(-2 * time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, "old.json"), old, old); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+
+		// The first save sweeps
+		if err := store.Save(&StoredRequest{ID: "new"}, body); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "old.json")); !os.IsNotExist(err) {
+			t.Errorf("Expected the expired request to be removed, got %v", err)
+		}
+		if _, err := store.Load("new"); err != nil {
+			t.Errorf("Expected the new request to be kept: %v", err)
+		}
+	})
+
+	t.Run("UnknownID", func(t *testing.T) {
+		store, err := NewRequestStore(t.TempDir(), false)
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		if _, err := store.Load("missing"); err == nil || !strings.Contains(err.Error(), "no stored request") {
+			t.Errorf("Expected a missing request error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// defaultRetryBudgetBurst is the budget capacity when retry.budget_burst is unset
+const defaultRetryBudgetBurst = 10
+
+// retryBudget is a token bucket that limits retries to a fraction of
+// requests. Each request deposits ratio tokens, up to the burst capacity, and
+// each retry withdraws a whole token, so a widespread provider failure cannot
+// multiply the load on the provider. A nil budget allows every retry.
+type retryBudget struct {
+	mu       sync.Mutex
+	ratio    float64
+	capacity float64
+	tokens   float64
+	retries  int64
+	denied   int64
+}
+
+// RetryBudgetStats reports the retry budget state for /status
+type RetryBudgetStats struct {
+	Ratio     float64 `json:"ratio"`
+	Capacity  float64 `json:"capacity"`
+	Available float64 `json:"available"`
+	Exhausted bool    `json:"exhausted"`
+	Retries   int64   `json:"retries"` // Retries allowed since startup
+	Denied    int64   `json:"denied"`  // Retries skipped because the budget was exhausted
+}
+
+// newRetryBudget creates a full budget, or returns nil when no ratio is
+// configured
+func newRetryBudget(cfg config.RetryConfig) *retryBudget {
+	if cfg.BudgetRatio <= 0 {
+		return nil
+	}
+	capacity := float64(cfg.BudgetBurst)
+	if capacity <= 0 {
+		capacity = defaultRetryBudgetBurst
+	}
+	return &retryBudget{ratio: cfg.BudgetRatio, capacity: capacity, tokens: capacity}
+}
+
+// deposit credits the budget for one request
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += b.ratio
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// withdraw reports whether a retry is allowed, spending a token if it is
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		b.denied++
+		return false
+	}
+	b.tokens--
+	b.retries++
+	return true
+}
+
+// stats returns a snapshot of the budget
+func (b *retryBudget) stats() RetryBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return RetryBudgetStats{
+		Ratio:     b.ratio,
+		Capacity:  b.capacity,
+		Available: b.tokens,
+		Exhausted: b.tokens < 1,
+		Retries:   b.retries,
+		Denied:    b.denied,
+	}
+}
+
+// RetryBudget returns the retry budget state, or nil when no budget is
+// configured
+func (p *Pipeline) RetryBudget() *RetryBudgetStats {
+	if p.retryBudget == nil {
+		return nil
+	}
+	stats := p.retryBudget.stats()
+	return &stats
+}
+
+// allowRetry spends retry budget for a retry to provider, logging when the
+// budget is exhausted
+func (p *Pipeline) allowRetry(provider *config.Provider) bool {
+	if p.retryBudget.withdraw() {
+		return true
+	}
+	utils.GetLogger().Warnf("Retry budget exhausted, not retrying request to provider %s", provider.Name)
+	return false
+}
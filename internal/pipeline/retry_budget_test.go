@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestRetryBudget(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		budget := newRetryBudget(config.RetryConfig{})
+		testutil.AssertTrue(t, budget == nil)
+		budget.deposit()
+		for i := 0; i < 100; i++ {
+			testutil.AssertTrue(t, budget.withdraw())
+		}
+	})
+
+	t.Run("LimitsRetriesToRatio", func(t *testing.T) {
+		budget := newRetryBudget(config.RetryConfig{BudgetRatio: 0.25, BudgetBurst: 2})
+
+		// A full budget allows a burst of retries
+		testutil.AssertTrue(t, budget.withdraw())
+		testutil.AssertTrue(t, budget.withdraw())
+		testutil.AssertFalse(t, budget.withdraw())
+		testutil.AssertTrue(t, budget.stats().Exhausted)
+
+		// Four requests earn one retry
+		for i := 0; i < 3; i++ {
+			budget.deposit()
+		}
+		testutil.AssertFalse(t, budget.withdraw())
+		budget.deposit()
+		testutil.AssertTrue(t, budget.withdraw())
+
+		stats := budget.stats()
+		testutil.AssertEqual(t, int64(3), stats.Retries)
+		testutil.AssertEqual(t, int64(2), stats.Denied)
+	})
+
+	t.Run("CappedAtBurst", func(t *testing.T) {
+		budget := newRetryBudget(config.RetryConfig{BudgetRatio: 1})
+		for i := 0; i < 100; i++ {
+			budget.deposit()
+		}
+		stats := budget.stats()
+		testutil.AssertEqual(t, float64(defaultRetryBudgetBurst), stats.Capacity)
+		testutil.AssertEqual(t, float64(defaultRetryBudgetBurst), stats.Available)
+		testutil.AssertFalse(t, stats.Exhausted)
+	})
+}
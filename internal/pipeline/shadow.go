@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// mirrorToShadow sends a copy of the request to the route's shadow target for
+// the configured share of requests. The copy is taken before the primary
+// request is modified, and the mirrored call runs in the background under its
+// own timeout, so it can neither delay nor fail the primary response.
+func (p *Pipeline) mirrorToShadow(req *RequestContext, shadow *config.ShadowTarget) {
+	if shadow == nil || p.randomRoll()*100 >= shadow.Percentage {
+		return
+	}
+
+	logger := utils.GetLogger()
+	body, err := copyRequestBody(req.Body)
+	if err != nil {
+		logger.Warnf("Shadow request to provider=%s skipped: %v", shadow.Provider, err)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shadow.RequestTimeout())
+		defer cancel()
+
+		start := time.Now()
+		status, err := p.sendShadow(ctx, shadow, body, req.IsStreaming)
+		latency := time.Since(start).Round(time.Millisecond)
+		switch {
+		case err != nil:
+			logger.Warnf("Shadow request to provider=%s, model=%s failed after %s: %v", shadow.Provider, shadow.Model, latency, err)
+		case status >= http.StatusBadRequest:
+			logger.Warnf("Shadow request to provider=%s, model=%s returned status %d in %s", shadow.Provider, shadow.Model, status, latency)
+		default:
+			logger.Infof("Shadow request to provider=%s, model=%s succeeded with status %d in %s", shadow.Provider, shadow.Model, status, latency)
+		}
+	}()
+}
+
+// sendShadow transforms and sends a mirrored request, reading the response to
+// the end so the measured latency covers all of it. The response is discarded.
+// Mirrored calls wait for the shadow provider's concurrency limit and count
+// against its budget like any other call, and are skipped once the budget is
+// used up or the provider has been disabled at runtime.
+func (p *Pipeline) sendShadow(ctx context.Context, shadow *config.ShadowTarget, body map[string]interface{}, streaming bool) (int, error) {
+	if p.providerDisabled(shadow.Provider) {
+		return 0, fmt.Errorf("provider %s is disabled", shadow.Provider)
+	}
+	provider, err := p.providerService.GetProvider(shadow.Provider)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := p.budget.check(shadow.Provider); err != nil {
+		return 0, err
+	}
+	estimated := utils.CountRequestTokensFor(shadow.Provider, shadow.Model, body)
+
+	var request interface{}
+	if isAnthropicPassthrough(provider, body) {
+		request = passthroughBody(body, provider, shadow.Model)
+	} else {
+		body["model"] = shadow.Model
+		request, err = p.transformerService.GetChainForProvider(shadow.Provider).TransformRequestIn(ctx, body, shadow.Provider)
+		if err != nil {
+			return 0, fmt.Errorf("request transformation failed: %w", err)
+		}
+	}
+
+	switch shadow.Provider {
+	case "gemini":
+		request = geminiRequest(provider, request, shadow.Model, streaming)
+	case "vertex":
+		request, err = p.vertexRequest(provider, request, shadow.Model, streaming)
+		if err != nil {
+			return 0, fmt.Errorf("failed to build Vertex AI request: %w", err)
+		}
+	}
+
+	httpReq, err := p.buildHTTPRequest(ctx, provider, request, streaming, shadow.Provider)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build HTTP request: %w", err)
+	}
+
+	release, err := p.acquireSlot(ctx, provider)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	resp, err := p.sendRequest(httpReq, provider, streaming)
+	if err != nil {
+		return 0, err
+	}
+
+	var usage tokenUsage
+	if streaming {
+		resp.Body = newStreamUsageBody(resp.Body, func(streamUsage tokenUsage, _ bool) { usage = streamUsage })
+	}
+	data, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close() // Safe to ignore: response is discarded
+	if readErr != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read response: %w", readErr)
+	}
+
+	if resp.StatusCode < http.StatusBadRequest {
+		if !streaming {
+			usage, _ = extractUsage(data)
+		}
+		var cost *CostBreakdown
+		if usage.Input > 0 || usage.Output > 0 {
+			cost = logCost(provider, shadow.Model, usage.Input, usage.Output)
+		}
+		p.recordBudgetUsage(shadow.Provider, budgetTokens(usage, estimated), cost)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,205 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_ShadowTraffic(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","choices":[{"message":{"role":"assistant","content":"primary"}}]}`))
+	}))
+	defer primary.Close()
+
+	shadowModels := make(chan string, 4)
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		shadowModels <- body["model"].(string)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"2","choices":[{"message":{"role":"assistant","content":"shadow"}}]}`))
+	}))
+	defer shadow.Close()
+	defer close(release)
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: primary.URL, APIKey: "test-key"},
+			{Name: "groq", APIBaseURL: shadow.URL, APIKey: "test-key"},
+		},
+		Routes: map[string]config.Route{
+			"default": {
+				Provider: "openai",
+				Model:    "gpt-4o",
+				Shadow:   &config.ShadowTarget{Provider: "groq", Model: "llama-3.3-70b-versatile", Percentage: 5, Timeout: time.Second},
+			},
+		},
+	}
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	testutil.AssertNoError(t, providerService.Initialize())
+	p := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+
+	process := func() string {
+		resp, err := p.ProcessRequest(context.Background(), &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "claude-3-opus",
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+			},
+		})
+		testutil.AssertNoError(t, err)
+		defer resp.Response.Body.Close()
+		body, _ := io.ReadAll(resp.Response.Body)
+		testutil.AssertEqual(t, "openai", resp.Provider)
+		return string(body)
+	}
+
+	t.Run("OutsidePercentage", func(t *testing.T) {
+		p.roll = func() float64 { return 0.06 }
+		process()
+
+		select {
+		case model := <-shadowModels:
+			t.Errorf("Expected no mirrored request, got one for %s", model)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("MirroredWithoutDelay", func(t *testing.T) {
+		p.roll = func() float64 { return 0.04 }
+
+		// The shadow is still blocked when the primary response arrives
+		testutil.AssertContains(t, process(), "primary")
+		select {
+		case model := <-shadowModels:
+			testutil.AssertEqual(t, "llama-3.3-70b-versatile", model)
+		case <-time.After(time.Second):
+			t.Fatal("Expected a mirrored request")
+		}
+	})
+
+	t.Run("SkippedWhenProviderDisabled", func(t *testing.T) {
+		p.roll = func() float64 { return 0 }
+		p.SetProviderEnabled("groq", false)
+		defer p.SetProviderEnabled("groq", true)
+
+		testutil.AssertContains(t, process(), "primary")
+		select {
+		case model := <-shadowModels:
+			t.Errorf("Expected no mirrored request to a disabled provider, got one for %s", model)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestPipeline_ShadowLimitsAndBudget(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","choices":[{"message":{"role":"assistant","content":"primary"}}]}`))
+	}))
+	defer primary.Close()
+
+	mirrored := make(chan struct{}, 4)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored <- struct{}{}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"2","choices":[{"message":{"role":"assistant","content":"shadow"}}],"usage":{"prompt_tokens":1000,"completion_tokens":2000}}`))
+	}))
+	defer shadow.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: primary.URL, APIKey: "test-key"},
+			{
+				Name:           "groq",
+				APIBaseURL:     shadow.URL,
+				APIKey:         "test-key",
+				MaxConcurrency: 1,
+				Pricing:        map[string]config.Pricing{"llama-3.3-70b-versatile": {InputPerMillion: 1000, OutputPerMillion: 1000}},
+				Budget:         &config.ProviderBudget{MaxCost: 3},
+			},
+		},
+		Routes: map[string]config.Route{
+			"default": {
+				Provider: "openai",
+				Model:    "gpt-4o",
+				Shadow:   &config.ShadowTarget{Provider: "groq", Model: "llama-3.3-70b-versatile", Percentage: 100, Timeout: 5 * time.Second},
+			},
+		},
+	}
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	testutil.AssertNoError(t, providerService.Initialize())
+	p := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+	p.roll = func() float64 { return 0 }
+
+	process := func() {
+		resp, err := p.ProcessRequest(context.Background(), &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "claude-3-opus",
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+			},
+		})
+		testutil.AssertNoError(t, err)
+		_, _ = io.ReadAll(resp.Response.Body)
+		resp.Response.Body.Close()
+	}
+
+	// The mirrored call waits while the shadow provider is at its limit
+	groq, err := providerService.GetProvider("groq")
+	testutil.AssertNoError(t, err)
+	release, err := p.acquireSlot(context.Background(), groq)
+	testutil.AssertNoError(t, err)
+	process()
+	select {
+	case <-mirrored:
+		t.Fatal("Expected the mirrored request to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-mirrored:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the mirrored request once the slot was released")
+	}
+
+	// Its usage counts against the shadow provider's budget
+	deadline := time.Now().Add(time.Second)
+	for p.Budget().Providers["groq"].Tokens == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	stats := p.Budget().Providers["groq"]
+	testutil.AssertEqual(t, int64(3000), stats.Tokens)
+	testutil.AssertEqual(t, 3.0, stats.Cost)
+	testutil.AssertTrue(t, stats.Exceeded)
+
+	// With the budget used up, requests are no longer mirrored
+	process()
+	select {
+	case <-mirrored:
+		t.Error("Expected no mirrored request once the shadow budget is used up")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
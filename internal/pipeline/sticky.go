@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// SessionHeader is the request header carrying a client-chosen conversation id
+const SessionHeader = "X-CCProxy-Session"
+
+// stickySweepInterval is how often the session store is swept for expired
+// sessions. Between sweeps expired sessions are dropped as they are read.
+const stickySweepInterval = time.Minute
+
+// stickySessions pins sessions to the routing decision of their first request
+type stickySessions struct {
+	mu        sync.Mutex
+	entries   map[string]stickySession
+	lastSweep time.Time
+	now       func() time.Time // Clock for expiry, replaced in tests
+}
+
+type stickySession struct {
+	decision  router.RouteDecision
+	expiresAt time.Time
+}
+
+// newStickySessions creates an empty session store
+func newStickySessions() *stickySessions {
+	return &stickySessions{
+		entries: make(map[string]stickySession),
+		now:     time.Now,
+	}
+}
+
+// get returns the decision pinned to key if it has not expired
+func (s *stickySessions) get(key string) (router.RouteDecision, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.entries[key]
+	if !exists {
+		return router.RouteDecision{}, false
+	}
+	if s.now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return router.RouteDecision{}, false
+	}
+	return entry.decision, true
+}
+
+// set pins key to decision for ttl. Sessions that expired without being read
+// again are swept at most once per stickySweepInterval.
+func (s *stickySessions) set(key string, decision router.RouteDecision, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= stickySweepInterval {
+		for k, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	s.entries[key] = stickySession{
+		decision:  decision,
+		expiresAt: now.Add(ttl),
+	}
+}
+
+// sessionKey returns the store key for a request's session, or "" when sticky
+// routing does not apply. Keys are scoped to the caller's credentials like
+// idempotency keys.
+func (p *Pipeline) sessionKey(req *RequestContext) string {
+	if p.currentConfig().Performance.StickySessionTTL <= 0 {
+		return ""
+	}
+
+	session := req.Headers[SessionHeader]
+	if session == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(req.Headers["Authorization"] + "\x00" + req.Headers["X-Api-Key"] + "\x00" + session))
+	return hex.EncodeToString(hash[:])
+}
+
+// stickyRoute keeps a session on the provider it was first routed to. The
+// routed decision is used instead when it already targets that provider, when
+// the client named a provider explicitly, or when the pinned provider is
+// unhealthy. Every request refreshes the session's TTL.
+func (p *Pipeline) stickyRoute(req *RequestContext, routeReq router.Request, decision router.RouteDecision) router.RouteDecision {
+	key := p.sessionKey(req)
+	if key == "" {
+		return decision
+	}
+
+	if pinned, ok := p.sessions.get(key); ok && pinned.Provider != decision.Provider && !router.IsExplicitModel(routeReq.Model) {
+		if p.providerService.IsHealthy(pinned.Provider) {
+			utils.GetLogger().Debugf("Keeping session on provider %s", pinned.Provider)
+			pinned.Reason = "sticky session"
+			decision = pinned
+		} else {
+			utils.GetLogger().Warnf("Sticky provider %s is unhealthy, rerouting session to %s", pinned.Provider, decision.Provider)
+		}
+	}
+
+	p.sessions.set(key, decision, p.currentConfig().Performance.StickySessionTTL)
+	return decision
+}
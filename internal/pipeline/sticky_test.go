@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_StickySessions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"content": "Hello"}}]}`))
+	}))
+	defer server.Close()
+
+	newPipeline := func(t *testing.T, ttl time.Duration) (*Pipeline, *providers.Service) {
+		t.Helper()
+		cfg := &config.Config{
+			Performance: config.PerformanceConfig{
+				RequestTimeout:   30 * time.Second,
+				StickySessionTTL: ttl,
+			},
+			Providers: []config.Provider{
+				{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"},
+				{Name: "groq", APIBaseURL: server.URL, APIKey: "test-key"},
+			},
+			Routes: map[string]config.Route{
+				"model-a": {Provider: "openai", Model: "gpt-4"},
+				"model-b": {Provider: "groq", Model: "llama"},
+			},
+		}
+
+		configService := config.NewService()
+		configService.SetConfig(cfg)
+		providerService := providers.NewService(configService)
+		if err := providerService.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize provider service: %v", err)
+		}
+		return NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg)), providerService
+	}
+
+	// send routes a request for model in session and returns the chosen provider
+	send := func(t *testing.T, p *Pipeline, session, model string) string {
+		t.Helper()
+		headers := map[string]string{"Authorization": "Bearer client"}
+		if session != "" {
+			headers[SessionHeader] = session
+		}
+		respCtx, err := p.ProcessRequest(context.Background(), &RequestContext{
+			Body: map[string]interface{}{
+				"model": model,
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": "Hello"},
+				},
+			},
+			Headers: headers,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		respCtx.Response.Body.Close()
+		return respCtx.Provider
+	}
+
+	t.Run("KeepsSessionOnProvider", func(t *testing.T) {
+		p, _ := newPipeline(t, time.Minute)
+
+		if got := send(t, p, "conv-1", "model-a"); got != "openai" {
+			t.Fatalf("Expected first request on openai, got %s", got)
+		}
+		if got := send(t, p, "conv-1", "model-b"); got != "openai" {
+			t.Errorf("Expected session to stay on openai, got %s", got)
+		}
+		if got := send(t, p, "conv-2", "model-b"); got != "groq" {
+			t.Errorf("Expected other sessions to route normally, got %s", got)
+		}
+		if got := send(t, p, "", "model-b"); got != "groq" {
+			t.Errorf("Expected requests without a session to route normally, got %s", got)
+		}
+	})
+
+	t.Run("DisabledWithoutTTL", func(t *testing.T) {
+		p, _ := newPipeline(t, 0)
+
+		send(t, p, "conv-1", "model-a")
+		if got := send(t, p, "conv-1", "model-b"); got != "groq" {
+			t.Errorf("Expected normal routing when sticky sessions are disabled, got %s", got)
+		}
+	})
+
+	t.Run("ExplicitModelWins", func(t *testing.T) {
+		p, _ := newPipeline(t, time.Minute)
+
+		send(t, p, "conv-1", "model-a")
+		if got := send(t, p, "conv-1", "groq,llama"); got != "groq" {
+			t.Fatalf("Expected explicit selection to override the session, got %s", got)
+		}
+		if got := send(t, p, "conv-1", "model-a"); got != "groq" {
+			t.Errorf("Expected session to follow the explicit selection, got %s", got)
+		}
+	})
+
+	t.Run("ReroutesWhenUnhealthy", func(t *testing.T) {
+		p, providerService := newPipeline(t, time.Minute)
+
+		send(t, p, "conv-1", "model-a")
+		health, err := providerService.GetProviderHealth("openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		health.Healthy = false
+
+		if got := send(t, p, "conv-1", "model-b"); got != "groq" {
+			t.Fatalf("Expected fallback to normal routing, got %s", got)
+		}
+
+		// The session is now pinned to the fallback provider
+		health.Healthy = true
+		if got := send(t, p, "conv-1", "model-a"); got != "groq" {
+			t.Errorf("Expected session to stay on groq, got %s", got)
+		}
+	})
+
+	t.Run("SessionsExpire", func(t *testing.T) {
+		p, _ := newPipeline(t, time.Minute)
+
+		send(t, p, "conv-1", "model-a")
+		key := p.sessionKey(&RequestContext{Headers: map[string]string{
+			"Authorization": "Bearer client",
+			SessionHeader:   "conv-1",
+		}})
+		p.sessions.entries[key] = stickySession{
+			decision:  p.sessions.entries[key].decision,
+			expiresAt: time.Now().Add(-time.Second),
+		}
+
+		if got := send(t, p, "conv-1", "model-b"); got != "groq" {
+			t.Errorf("Expected expired session to route normally, got %s", got)
+		}
+	})
+}
+
+func TestStickySessions_Sweep(t *testing.T) {
+	now := time.Now()
+	sessions := newStickySessions()
+	sessions.now = func() time.Time { return now }
+	decision := router.RouteDecision{Provider: "openai"}
+
+	sessions.set("idle-1", decision, time.Second)
+	sessions.set("idle-2", decision, time.Second)
+
+	// Expired sessions stay stored between sweeps but are never returned
+	now = now.Add(2 * time.Second)
+	sessions.set("active", decision, time.Hour)
+	if len(sessions.entries) != 3 {
+		t.Errorf("Expected no sweep within the interval, got %d sessions", len(sessions.entries))
+	}
+	if _, ok := sessions.get("idle-1"); ok {
+		t.Error("Expected an expired session to be dropped on read")
+	}
+	if len(sessions.entries) != 2 {
+		t.Errorf("Expected the read to remove the expired session, got %d sessions", len(sessions.entries))
+	}
+
+	now = now.Add(stickySweepInterval)
+	sessions.set("new", decision, time.Hour)
+	if _, ok := sessions.entries["idle-2"]; ok || len(sessions.entries) != 2 {
+		t.Errorf("Expected the sweep to remove expired sessions, got %d sessions", len(sessions.entries))
+	}
+	if _, ok := sessions.get("active"); !ok {
+		t.Error("Expected the unexpired session to be kept")
+	}
+}
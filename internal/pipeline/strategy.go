@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// RankedTarget is a latency-aware route candidate with its recent latency
+type RankedTarget struct {
+	Provider string  `json:"provider"`
+	Model    string  `json:"model"`
+	Healthy  bool    `json:"healthy"`
+	Requests int     `json:"requests"`
+	P50Ms    float64 `json:"p50_ms,omitempty"`
+}
+
+// RouteRankings returns the current candidate order of every latency-aware
+// route, fastest healthy candidate first
+func (p *Pipeline) RouteRankings() map[string][]RankedTarget {
+	rankings := make(map[string][]RankedTarget)
+	for name, route := range p.currentConfig().Routes {
+		if route.Strategy == config.RouteStrategyLatencyAware {
+			rankings[name] = p.rankTargets(route.Candidates())
+		}
+	}
+	return rankings
+}
+
+// rankTargets orders candidates with healthy ones first, then those with
+// latency samples by median latency, then the rest in configured order
+func (p *Pipeline) rankTargets(candidates []config.RouteTarget) []RankedTarget {
+	latency := p.LatencyStats()
+	ranking := make([]RankedTarget, len(candidates))
+	for i, candidate := range candidates {
+		stats := latency[candidate.Provider]
+		ranking[i] = RankedTarget{
+			Provider: candidate.Provider,
+			Model:    candidate.Model,
+			Healthy:  p.providerService.IsHealthy(candidate.Provider) && !p.providerDisabled(candidate.Provider),
+			Requests: stats.Requests,
+			P50Ms:    stats.P50Ms,
+		}
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		a, b := ranking[i], ranking[j]
+		if a.Healthy != b.Healthy {
+			return a.Healthy
+		}
+		if (a.Requests > 0) != (b.Requests > 0) {
+			return a.Requests > 0
+		}
+		return a.P50Ms < b.P50Ms
+	})
+	return ranking
+}
+
+// applyRouteStrategy picks the target of a latency-aware route: the fastest
+// healthy candidate, or with the route's exploration probability one of the
+// other healthy candidates so their latency stays measured. Decisions without
+// candidates, or whose candidates are all unhealthy, are returned unchanged.
+func (p *Pipeline) applyRouteStrategy(decision router.RouteDecision) router.RouteDecision {
+	if len(decision.Candidates) == 0 {
+		return decision
+	}
+
+	ranking := p.rankTargets(decision.Candidates)
+	healthy := 0
+	for healthy < len(ranking) && ranking[healthy].Healthy {
+		healthy++
+	}
+	if healthy == 0 {
+		return decision
+	}
+
+	choice, reason := ranking[0], "fastest healthy target"
+	if healthy > 1 && p.randomRoll() < decision.Exploration {
+		choice, reason = ranking[1+rand.Intn(healthy-1)], "exploring target" // #nosec G404 - Used for non-cryptographic sampling only
+	}
+
+	utils.GetLogger().Debugf("Latency-aware route selected provider=%s, model=%s (%s)", choice.Provider, choice.Model, reason)
+	decision.Provider = choice.Provider
+	decision.Model = choice.Model
+	decision.Reason += ", latency-aware " + reason
+	return decision
+}
+
+// randomRoll returns a random number in [0, 1) for sampling decisions
+func (p *Pipeline) randomRoll() float64 {
+	if p.roll != nil {
+		return p.roll()
+	}
+	return rand.Float64() // #nosec G404 - Used for non-cryptographic sampling only
+}
@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/performance"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestPipeline_ApplyRouteStrategy(t *testing.T) {
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: "https://api.openai.com/v1", APIKey: "test-key"},
+			{Name: "groq", APIBaseURL: "https://api.groq.com/openai/v1", APIKey: "test-key"},
+			{Name: "deepseek", APIBaseURL: "https://api.deepseek.com", APIKey: "test-key"},
+		},
+		Routes: map[string]config.Route{
+			"default": {
+				Provider: "openai",
+				Model:    "gpt-4o",
+				Targets: []config.RouteTarget{
+					{Provider: "groq", Model: "llama-3.3-70b-versatile"},
+					{Provider: "deepseek", Model: "deepseek-chat"},
+				},
+				Strategy: config.RouteStrategyLatencyAware,
+			},
+		},
+	}
+
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	testutil.AssertNoError(t, providerService.Initialize())
+
+	monitor := performance.NewMonitor(nil)
+	record := func(provider string, latency time.Duration) {
+		monitor.RecordRequest(performance.RequestMetrics{Provider: provider, Latency: latency, Success: true})
+	}
+	record("openai", 900*time.Millisecond)
+	record("groq", 200*time.Millisecond)
+	record("deepseek", 500*time.Millisecond)
+
+	routerInstance := router.New(cfg)
+	p := &Pipeline{
+		config:             cfg,
+		providerService:    providerService,
+		router:             routerInstance,
+		performanceMonitor: monitor,
+		roll:               func() float64 { return 0.99 },
+	}
+	route := func() router.RouteDecision {
+		return p.applyRouteStrategy(routerInstance.Route(router.Request{Model: "claude-3-opus"}, 10))
+	}
+
+	t.Run("FastestHealthy", func(t *testing.T) {
+		decision := route()
+		testutil.AssertEqual(t, "groq", decision.Provider)
+		testutil.AssertEqual(t, "llama-3.3-70b-versatile", decision.Model)
+		testutil.AssertEqual(t, "default model, latency-aware fastest healthy target", decision.Reason)
+	})
+
+	t.Run("SkipsUnhealthy", func(t *testing.T) {
+		p.SetProviderEnabled("groq", false)
+		defer p.SetProviderEnabled("groq", true)
+
+		testutil.AssertEqual(t, "deepseek", route().Provider)
+	})
+
+	t.Run("Exploration", func(t *testing.T) {
+		p.roll = func() float64 { return 0 }
+		defer func() { p.roll = func() float64 { return 0.99 } }()
+
+		decision := route()
+		testutil.AssertTrue(t, decision.Provider == "deepseek" || decision.Provider == "openai")
+		testutil.AssertContains(t, decision.Reason, "exploring target")
+	})
+
+	t.Run("NoHealthyCandidates", func(t *testing.T) {
+		for _, name := range []string{"openai", "groq", "deepseek"} {
+			p.SetProviderEnabled(name, false)
+		}
+		defer p.ResetProviderStates()
+
+		decision := route()
+		testutil.AssertEqual(t, "openai", decision.Provider)
+		testutil.AssertEqual(t, "default model", decision.Reason)
+	})
+
+	t.Run("Rankings", func(t *testing.T) {
+		p.SetProviderEnabled("openai", false)
+		defer p.ResetProviderStates()
+
+		ranking := p.RouteRankings()["default"]
+		testutil.AssertEqual(t, 3, len(ranking))
+		testutil.AssertEqual(t, "groq", ranking[0].Provider)
+		testutil.AssertEqual(t, "deepseek", ranking[1].Provider)
+		testutil.AssertEqual(t, "openai", ranking[2].Provider)
+		testutil.AssertFalse(t, ranking[2].Healthy)
+		testutil.AssertEqual(t, 1, ranking[0].Requests)
+	})
+}
+
+func TestPipeline_RankTargetsUnmeasuredLast(t *testing.T) {
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: "https://api.openai.com/v1", APIKey: "test-key"},
+			{Name: "groq", APIBaseURL: "https://api.groq.com/openai/v1", APIKey: "test-key"},
+		},
+	}
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	testutil.AssertNoError(t, providerService.Initialize())
+
+	monitor := performance.NewMonitor(nil)
+	monitor.RecordRequest(performance.RequestMetrics{Provider: "groq", Latency: time.Second, Success: true})
+	p := &Pipeline{config: cfg, providerService: providerService, performanceMonitor: monitor}
+
+	ranking := p.rankTargets([]config.RouteTarget{
+		{Provider: "openai", Model: "gpt-4o"},
+		{Provider: "groq", Model: "llama-3.3-70b-versatile"},
+	})
+	testutil.AssertEqual(t, "groq", ranking[0].Provider)
+	testutil.AssertEqual(t, 0, ranking[1].Requests)
+}
+
+func TestPipeline_LatencyAwareSendsChosenModel(t *testing.T) {
+	models := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		models <- body["model"].(string)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","choices":[{"message":{"role":"assistant","content":"Hi"}}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+		Providers: []config.Provider{
+			{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"},
+			{Name: "groq", APIBaseURL: server.URL, APIKey: "test-key"},
+		},
+		Routes: map[string]config.Route{
+			"default": {
+				Provider: "openai",
+				Model:    "gpt-4o",
+				Targets:  []config.RouteTarget{{Provider: "groq", Model: "llama-3.3-70b-versatile"}},
+				Strategy: config.RouteStrategyLatencyAware,
+			},
+		},
+	}
+	configService := config.NewService()
+	configService.SetConfig(cfg)
+	providerService := providers.NewService(configService)
+	testutil.AssertNoError(t, providerService.Initialize())
+
+	p := NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+	p.roll = func() float64 { return 0.99 }
+	p.performanceMonitor.RecordRequest(performance.RequestMetrics{Provider: "openai", Latency: time.Second, Success: true})
+	p.performanceMonitor.RecordRequest(performance.RequestMetrics{Provider: "groq", Latency: 100 * time.Millisecond, Success: true})
+
+	resp, err := p.ProcessRequest(context.Background(), &RequestContext{
+		Body: map[string]interface{}{
+			"model":    "claude-3-opus",
+			"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+		},
+	})
+	testutil.AssertNoError(t, err)
+	defer resp.Response.Body.Close()
+
+	testutil.AssertEqual(t, "groq", resp.Provider)
+	testutil.AssertEqual(t, "llama-3.3-70b-versatile", <-models)
+}
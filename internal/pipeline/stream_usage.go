@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// maxUsageLineBytes bounds the partial line kept between reads. Usage events
+// are small, so longer lines are skipped rather than buffered.
+const maxUsageLineBytes = 64 * 1024
+
+// streamUsageBody passes an Anthropic event stream through unchanged while
+// collecting the usage reported by its message_start and message_delta
+// events. done is called once, when the body is closed, with the usage seen
+// so far and whether any was reported.
+type streamUsageBody struct {
+	io.ReadCloser
+	done func(usage tokenUsage, ok bool)
+
+	mu       sync.Mutex
+	line     []byte // Incomplete line from the previous read
+	skipping bool   // Discarding the rest of an overlong line
+	usage    tokenUsage
+	seen     bool
+	closed   bool
+	once     sync.Once
+}
+
+// newStreamUsageBody wraps body to report its stream usage to done
+func newStreamUsageBody(body io.ReadCloser, done func(usage tokenUsage, ok bool)) *streamUsageBody {
+	return &streamUsageBody{ReadCloser: body, done: done}
+}
+
+// Read reads from the stream and scans what was read for usage
+func (b *streamUsageBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.scan(p[:n])
+	}
+	return n, err
+}
+
+// Close closes the stream and reports its usage
+func (b *streamUsageBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.mu.Lock()
+	b.closed = true
+	usage, seen := b.usage, b.seen
+	b.mu.Unlock()
+	b.once.Do(func() { b.done(usage, seen) })
+	return err
+}
+
+// scan splits data into lines and reads usage from complete data lines
+func (b *streamUsageBody) scan(data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			if !b.skipping {
+				b.line = append(b.line, data...)
+				if len(b.line) > maxUsageLineBytes {
+					b.line, b.skipping = b.line[:0], true
+				}
+			}
+			return
+		}
+
+		if !b.skipping {
+			line := data[:end]
+			if len(b.line) > 0 {
+				line = append(b.line, line...)
+			}
+			b.scanLine(line)
+		}
+		b.line, b.skipping = b.line[:0], false
+		data = data[end+1:]
+	}
+}
+
+// scanLine merges the usage of a single SSE data line
+func (b *streamUsageBody) scanLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("data:")) || !bytes.Contains(line, []byte(`"usage"`)) {
+		return
+	}
+
+	var event struct {
+		Type    string          `json:"type"`
+		Usage   json.RawMessage `json:"usage"`
+		Message struct {
+			Usage json.RawMessage `json:"usage"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(line[len("data:"):]), &event); err != nil {
+		return
+	}
+
+	raw := event.Usage
+	if event.Type == "message_start" {
+		raw = event.Message.Usage
+	}
+	if len(raw) == 0 {
+		return
+	}
+	usage, ok := extractUsage([]byte(`{"usage":` + string(raw) + `}`))
+	if !ok {
+		return
+	}
+
+	// Counts are cumulative, so later events replace earlier ones, but a
+	// zero never replaces a count
+	b.usage.Input = mergeCount(b.usage.Input, usage.Input)
+	b.usage.Output = mergeCount(b.usage.Output, usage.Output)
+	b.usage.CacheRead = mergeCount(b.usage.CacheRead, usage.CacheRead)
+	b.usage.CacheCreation = mergeCount(b.usage.CacheCreation, usage.CacheCreation)
+	b.seen = true
+}
+
+// mergeCount returns the later count unless it is zero
+func mergeCount(earlier, later int) int {
+	if later == 0 {
+		return earlier
+	}
+	return later
+}
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestStreamUsageBody(t *testing.T) {
+	read := func(t *testing.T, stream string) (tokenUsage, bool) {
+		t.Helper()
+		var got tokenUsage
+		reported, calls := false, 0
+		body := newStreamUsageBody(io.NopCloser(iotest.OneByteReader(strings.NewReader(stream))), func(usage tokenUsage, ok bool) {
+			got, reported = usage, ok
+			calls++
+		})
+
+		data, err := io.ReadAll(body)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, stream, string(data)) // Passed through unchanged
+		testutil.AssertNoError(t, body.Close())
+		testutil.AssertNoError(t, body.Close())
+		testutil.AssertEqual(t, 1, calls)
+		return got, reported
+	}
+
+	t.Run("MergesStartAndDelta", func(t *testing.T) {
+		usage, ok := read(t, "event: message_start\n"+
+			`data: {"type":"message_start","message":{"usage":{"input_tokens":10,"output_tokens":1,"cache_read_input_tokens":500,"cache_creation_input_tokens":20}}}`+"\n\n"+
+			"event: message_delta\n"+
+			`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":42}}`+"\n\n")
+		testutil.AssertTrue(t, ok)
+		testutil.AssertEqual(t, tokenUsage{Input: 10, Output: 42, CacheRead: 500, CacheCreation: 20}, usage)
+	})
+
+	t.Run("NoUsage", func(t *testing.T) {
+		_, ok := read(t, "data: {\"type\":\"message_stop\"}\n\n")
+		testutil.AssertTrue(t, !ok)
+	})
+
+	t.Run("SkipsOverlongLines", func(t *testing.T) {
+		long := `data: {"type":"content_block_delta","delta":{"text":"` + strings.Repeat("x", maxUsageLineBytes) + `"},"usage":{"output_tokens":99}}`
+		usage, ok := read(t, long+"\n\n"+`data: {"type":"message_delta","usage":{"output_tokens":7}}`+"\n\n")
+		testutil.AssertTrue(t, ok)
+		testutil.AssertEqual(t, 7, usage.Output)
+	})
+}
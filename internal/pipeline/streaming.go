@@ -1,34 +1,88 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/orchestre-dev/ccproxy/internal/transformer"
 	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
 
+// ErrStreamAborted reports a stream that failed upstream and was already
+// ended with a terminal error event sent to the client
+var ErrStreamAborted = errors.New("stream aborted after upstream failure")
+
 // StreamingProcessor handles streaming response processing
 type StreamingProcessor struct {
 	transformerService *transformer.Service
+	recordDir          string        // Directory for raw stream recordings, empty when disabled
+	keepAliveInterval  time.Duration // Silence before a keepalive comment is sent, 0 disables
+	flushBatchSize     int           // Events per flush, 0 flushes every event
+	flushInterval      time.Duration // Longest an event waits for a batched flush
+	validateToolArgs   bool          // Complete malformed streamed tool call arguments
+	streams            *streamTracker
 }
 
 // NewStreamingProcessor creates a new streaming processor
 func NewStreamingProcessor(transformerService *transformer.Service) *StreamingProcessor {
 	return &StreamingProcessor{
 		transformerService: transformerService,
+		streams:            newStreamTracker(),
 	}
 }
 
+// SetRecordDir enables recording of raw upstream streams into dir
+func (p *StreamingProcessor) SetRecordDir(dir string) {
+	p.recordDir = dir
+}
+
+// SetKeepAliveInterval enables SSE keepalive comments after interval of
+// upstream silence
+func (p *StreamingProcessor) SetKeepAliveInterval(interval time.Duration) {
+	p.keepAliveInterval = interval
+}
+
+// SetFlushBatching flushes the stream every size events, or interval after
+// the first unflushed event. A size of 0 or 1 flushes after every event.
+func (p *StreamingProcessor) SetFlushBatching(size int, interval time.Duration) {
+	if size <= 1 {
+		size = 0
+	}
+	p.flushBatchSize = size
+	p.flushInterval = interval
+}
+
+// SetToolArgumentValidation enables checking the streamed arguments of each
+// tool call, completing truncated JSON before its block ends
+func (p *StreamingProcessor) SetToolArgumentValidation(enabled bool) {
+	p.validateToolArgs = enabled
+}
+
 // ProcessStreamingResponse handles the complete streaming response flow
 func (p *StreamingProcessor) ProcessStreamingResponse(
 	ctx context.Context,
 	w http.ResponseWriter,
 	resp *http.Response,
 	provider string,
+) error {
+	return p.processStream(ctx, w, resp, provider, nil)
+}
+
+// processStream streams resp to the client, noting in timing, when not nil,
+// when each content event was written
+func (p *StreamingProcessor) processStream(
+	ctx context.Context,
+	w http.ResponseWriter,
+	resp *http.Response,
+	provider string,
+	timing *streamTiming,
 ) error {
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -42,11 +96,29 @@ func (p *StreamingProcessor) ProcessStreamingResponse(
 		return fmt.Errorf("response writer does not support flushing")
 	}
 
-	// Create SSE reader and writer
-	reader := transformer.NewSSEReader(resp.Body)
-	writer := transformer.NewSSEWriter(w)
+	// Batch flushes when configured. Stopping the batcher on return flushes
+	// the last events, including [DONE] or a terminal error event.
+	var out io.Writer = w
+	if p.flushBatchSize > 0 {
+		batch := newBatchFlusher(w, flusher, p.flushBatchSize, p.flushInterval)
+		defer batch.Stop()
+		out = batch
+	}
+
+	// Create a reader for the upstream framing; the client always gets SSE
+	reader, format := transformer.NewStreamReader(resp)
+	writer := transformer.NewSSEWriter(out)
+	utils.GetLogger().Debugf("Upstream stream format for %s: %s", provider, format)
+	defer reader.Close()
+
+	// Track the stream so shutdown can drain it, and let a timed out drain
+	// cancel it
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer p.streams.add(writer, cancel)()
 
-	// Handle context cancellation
+	// Handle context cancellation. Closing the reader closes the upstream
+	// body, which unblocks any pending read and stops the provider stream.
 	done := make(chan struct{})
 	defer close(done)
 
@@ -60,11 +132,30 @@ func (p *StreamingProcessor) ProcessStreamingResponse(
 		}
 	}()
 
+	// Record raw upstream events for replay when enabled
+	recorder := p.startRecording(provider)
+	if recorder != nil {
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				utils.GetLogger().Warnf("Failed to close stream recording: %v", err)
+				return
+			}
+			utils.GetLogger().Debugf("Recorded stream to %s", recorder.Path())
+		}()
+	}
+
+	// Keep the connection alive through long upstream pauses
+	keepAlive := startKeepAlive(writer, p.keepAliveInterval)
+	defer keepAlive.Stop()
+
+	// Check tool call arguments as they stream when enabled
+	toolArgs := newToolArgumentTracker(p.validateToolArgs, provider)
+
 	// Get transformer chain for the provider
 	chain := p.transformerService.GetChainForProvider(provider)
 	if chain == nil {
 		// If no chain, just pass through
-		return p.passThrough(reader, writer, flusher)
+		return p.passThrough(reader, writer, keepAlive, recorder, toolArgs, provider, timing)
 	}
 
 	// Process events through transformer chain
@@ -75,6 +166,11 @@ func (p *StreamingProcessor) ProcessStreamingResponse(
 		// Read event
 		event, err := reader.ReadEvent()
 		if err != nil {
+			if ctx.Err() != nil {
+				// Client went away; the upstream body is already closed
+				utils.GetLogger().Info("Client disconnected, aborted upstream stream")
+				return nil
+			}
 			if err == io.EOF {
 				// Normal end of stream
 				break
@@ -83,7 +179,9 @@ func (p *StreamingProcessor) ProcessStreamingResponse(
 			utils.GetLogger().Warnf("Error reading SSE event: %v", err)
 			errorCount++
 			if errorCount > 10 {
-				return fmt.Errorf("too many errors reading SSE stream")
+				return abortStream(writer, provider, eventCount,
+					transformer.NewStreamErrorEvent("api_error", "Upstream stream failed: "+err.Error()),
+					fmt.Errorf("too many errors reading SSE stream: %w", err))
 			}
 			continue
 		}
@@ -93,6 +191,12 @@ func (p *StreamingProcessor) ProcessStreamingResponse(
 			continue
 		}
 
+		recordEvent(recorder, event)
+
+		if errEvent := transformer.StreamErrorEvent(event); errEvent != nil {
+			return abortStream(writer, provider, eventCount, errEvent, fmt.Errorf("upstream error event: %s", event.Data))
+		}
+
 		// Apply transformations if this is a data event
 		if event.Data != "" && !strings.HasPrefix(event.Data, "[DONE]") {
 			transformedEvent, err := chain.TransformSSEEvent(ctx, event, provider)
@@ -104,6 +208,9 @@ func (p *StreamingProcessor) ProcessStreamingResponse(
 			event = transformedEvent
 		}
 
+		// Complete malformed tool call arguments before the event ending them
+		writeToolArgumentCorrections(writer, toolArgs.corrections(event))
+
 		// Write event
 		if err := writer.WriteEvent(event); err != nil {
 			// Client disconnected or context canceled
@@ -117,7 +224,9 @@ func (p *StreamingProcessor) ProcessStreamingResponse(
 		}
 
 		// Flush after each event
-		flusher.Flush()
+		_ = writer.Flush() // Safe to ignore: Flush never fails
+		keepAlive.Touch()
+		timing.observe(event)
 		eventCount++
 
 		// Check if this is the end marker
@@ -126,27 +235,84 @@ func (p *StreamingProcessor) ProcessStreamingResponse(
 		}
 	}
 
+	writeToolArgumentCorrections(writer, toolArgs.finish())
 	utils.GetLogger().Infof("Streamed %d events to client", eventCount)
 	return nil
 }
 
+// isEmptyStream reads a streaming response up to its first data event and
+// reports whether the stream ended without one. The consumed bytes are
+// replayed so the body still reads from the start afterwards.
+func isEmptyStream(resp *http.Response) bool {
+	var consumed bytes.Buffer
+	peek := &http.Response{
+		Header: resp.Header,
+		Body:   io.NopCloser(io.TeeReader(resp.Body, &consumed)),
+	}
+	reader, _ := transformer.NewStreamReader(peek)
+
+	empty := true
+	for {
+		event, err := reader.ReadEvent()
+		if err != nil {
+			// A read error is not an empty stream, leave it to the client
+			empty = err == io.EOF
+			break
+		}
+		if event.Data == "[DONE]" {
+			break
+		}
+		if event.Data != "" {
+			empty = false
+			break
+		}
+	}
+
+	resp.Body = &replayBody{
+		Reader: io.MultiReader(bytes.NewReader(consumed.Bytes()), resp.Body),
+		Closer: resp.Body,
+	}
+	return empty
+}
+
+// replayBody reads buffered bytes before the rest of the original body
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
 // passThrough handles streaming without transformation
 func (p *StreamingProcessor) passThrough(
-	reader *transformer.SSEReader,
+	reader transformer.StreamReader,
 	writer *transformer.SSEWriter,
-	flusher http.Flusher,
+	keepAlive *streamKeepAlive,
+	recorder *StreamRecorder,
+	toolArgs *toolArgumentTracker,
+	provider string,
+	timing *streamTiming,
 ) error {
 	defer reader.Close()
 
+	eventCount := 0
 	for {
 		event, err := reader.ReadEvent()
 		if err != nil {
 			if err == io.EOF {
+				writeToolArgumentCorrections(writer, toolArgs.finish())
 				return nil
 			}
-			return err
+			return abortStream(writer, provider, eventCount,
+				transformer.NewStreamErrorEvent("api_error", "Upstream stream failed: "+err.Error()), err)
+		}
+
+		recordEvent(recorder, event)
+
+		if errEvent := transformer.StreamErrorEvent(event); errEvent != nil {
+			return abortStream(writer, provider, eventCount, errEvent, fmt.Errorf("upstream error event: %s", event.Data))
 		}
 
+		writeToolArgumentCorrections(writer, toolArgs.corrections(event))
+
 		if err := writer.WriteEvent(event); err != nil {
 			// Check for expected errors during cancellation
 			if strings.Contains(err.Error(), "writer is closed") {
@@ -155,7 +321,10 @@ func (p *StreamingProcessor) passThrough(
 			return err
 		}
 
-		flusher.Flush()
+		_ = writer.Flush() // Safe to ignore: Flush never fails
+		keepAlive.Touch()
+		timing.observe(event)
+		eventCount++
 
 		if event.Data == "[DONE]" {
 			break
@@ -164,3 +333,117 @@ func (p *StreamingProcessor) passThrough(
 
 	return nil
 }
+
+// writeToolArgumentCorrections writes the events a toolArgumentTracker asks
+// for. Write errors are left to the next event write, which handles them.
+func writeToolArgumentCorrections(writer *transformer.SSEWriter, events []*transformer.SSEEvent) {
+	for _, event := range events {
+		if err := writer.WriteEvent(event); err != nil {
+			return
+		}
+	}
+}
+
+// streamTiming measures when the content of a stream reaches the client
+type streamTiming struct {
+	start      time.Time       // When the request started
+	seen       bool            // Whether any content has been written
+	firstToken time.Duration   // Time from start to the first content event
+	last       time.Time       // When the latest content event was written
+	gaps       []time.Duration // Time between consecutive content events
+}
+
+// observe notes an event written to the client, ignoring events without
+// content. A nil timing ignores every event.
+func (t *streamTiming) observe(event *transformer.SSEEvent) {
+	if t == nil || !hasStreamContent(event) {
+		return
+	}
+
+	now := time.Now()
+	if t.seen {
+		t.gaps = append(t.gaps, now.Sub(t.last))
+	} else {
+		t.seen = true
+		t.firstToken = now.Sub(t.start)
+	}
+	t.last = now
+}
+
+// hasStreamContent reports whether an event carries generated content: text,
+// thinking or tool call arguments, in the Anthropic or OpenAI format. Message
+// framing, pings and empty deltas do not count.
+func hasStreamContent(event *transformer.SSEEvent) bool {
+	if event == nil || !strings.Contains(event.Data, `"delta"`) {
+		return false
+	}
+
+	var payload struct {
+		Delta struct {
+			Text        string `json:"text"`
+			Thinking    string `json:"thinking"`
+			PartialJSON string `json:"partial_json"`
+		} `json:"delta"`
+		Choices []struct {
+			Delta struct {
+				Content          string            `json:"content"`
+				ReasoningContent string            `json:"reasoning_content"`
+				ToolCalls        []json.RawMessage `json:"tool_calls"`
+			} `json:"delta"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(event.Data), &payload); err != nil {
+		return false
+	}
+
+	if payload.Delta.Text != "" || payload.Delta.Thinking != "" || payload.Delta.PartialJSON != "" {
+		return true
+	}
+	for _, choice := range payload.Choices {
+		if choice.Delta.Content != "" || choice.Delta.ReasoningContent != "" || len(choice.Delta.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// abortStream ends a stream that failed upstream with a terminal error event
+// and [DONE] instead of truncating it, and logs how much had been delivered.
+// The returned error wraps ErrStreamAborted and cause.
+func abortStream(writer *transformer.SSEWriter, provider string, delivered int, errEvent *transformer.SSEEvent, cause error) error {
+	utils.GetLogger().Warnf("Stream from %s failed after %d events delivered: %v", provider, delivered, cause)
+
+	for _, event := range []*transformer.SSEEvent{errEvent, {Data: "[DONE]"}} {
+		if err := writer.WriteEvent(event); err != nil {
+			break // Client already gone
+		}
+	}
+	_ = writer.Flush() // Safe to ignore: Flush never fails
+
+	return fmt.Errorf("%w: %w", ErrStreamAborted, cause)
+}
+
+// startRecording opens a stream recording if recording is enabled
+func (p *StreamingProcessor) startRecording(provider string) *StreamRecorder {
+	if p.recordDir == "" {
+		return nil
+	}
+
+	recorder, err := NewStreamRecorder(p.recordDir, provider)
+	if err != nil {
+		// Recording is diagnostic only, never fail the stream over it
+		utils.GetLogger().Warnf("Stream recording disabled for this request: %v", err)
+		return nil
+	}
+	return recorder
+}
+
+// recordEvent appends an event to the recording if one is active
+func recordEvent(recorder *StreamRecorder, event *transformer.SSEEvent) {
+	if recorder == nil {
+		return
+	}
+	if err := recorder.Record(event); err != nil {
+		utils.GetLogger().Warnf("Failed to record SSE event: %v", err)
+	}
+}
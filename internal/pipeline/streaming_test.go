@@ -2,15 +2,20 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/providers"
+	"github.com/orchestre-dev/ccproxy/internal/router"
 	"github.com/orchestre-dev/ccproxy/internal/transformer"
 )
 
@@ -171,6 +176,58 @@ func (w *nonFlushableWriter) WriteHeader(statusCode int) {
 	w.status = statusCode
 }
 
+func TestStreamingProcessor_UpstreamFormats(t *testing.T) {
+	processor := NewStreamingProcessor(transformer.NewService())
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "NDJSON",
+			contentType: "application/x-ndjson",
+			body:        "{\"message\":{\"content\":\"Hi\"},\"done\":false}\n{\"done\":true}\n",
+			want:        "data: {\"message\":{\"content\":\"Hi\"},\"done\":false}\n\ndata: {\"done\":true}\n\n",
+		},
+		{
+			name:        "ChunkedJSONArray",
+			contentType: "application/json",
+			body:        "[{\"text\": \"a\"},\n{\"text\": \"b\"}]",
+			want:        "data: {\"text\":\"a\"}\n\ndata: {\"text\":\"b\"}\n\n",
+		},
+		{
+			name:        "SSE",
+			contentType: "text/event-stream",
+			body:        "data: {\"text\":\"a\"}\n\ndata: [DONE]\n\n",
+			want:        "data: {\"text\":\"a\"}\n\ndata: [DONE]\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{tt.contentType}},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			w := httptest.NewRecorder()
+			if err := processor.ProcessStreamingResponse(context.Background(), w, resp, "ollama"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if w.Header().Get("Content-Type") != "text/event-stream" {
+				t.Errorf("Expected SSE output, got Content-Type %s", w.Header().Get("Content-Type"))
+			}
+			if w.Body.String() != tt.want {
+				t.Errorf("Expected normalized output %q, got %q", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestStreamingProcessor_PassThrough(t *testing.T) {
 	transformerService := transformer.NewService()
 	processor := NewStreamingProcessor(transformerService)
@@ -181,9 +238,8 @@ func TestStreamingProcessor_PassThrough(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		writer := transformer.NewSSEWriter(w)
-		flusher := w // httptest.ResponseRecorder implements http.Flusher
 
-		err := processor.passThrough(reader, writer, flusher)
+		err := processor.passThrough(reader, writer, nil, nil, nil, "openai", nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -207,9 +263,8 @@ func TestStreamingProcessor_PassThrough(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		writer := transformer.NewSSEWriter(w)
-		flusher := w
 
-		err := processor.passThrough(reader, writer, flusher)
+		err := processor.passThrough(reader, writer, nil, nil, nil, "openai", nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -222,12 +277,14 @@ func TestStreamingProcessor_PassThrough(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		writer := transformer.NewSSEWriter(w)
-		flusher := w
 
-		err := processor.passThrough(reader, writer, flusher)
+		err := processor.passThrough(reader, writer, nil, nil, nil, "openai", nil)
 		if err == nil {
 			t.Error("Expected error from reader")
 		}
+		if !strings.Contains(w.Body.String(), "event: error") {
+			t.Errorf("Expected an error event, got %q", w.Body.String())
+		}
 	})
 }
 
@@ -387,6 +444,60 @@ func TestStreamingProcessor_AdvancedErrorHandling(t *testing.T) {
 		if !strings.Contains(err.Error(), "too many errors") {
 			t.Errorf("Expected 'too many errors' error, got %v", err)
 		}
+
+		// The client gets a terminal error event instead of a truncated stream
+		if !errors.Is(err, ErrStreamAborted) {
+			t.Errorf("Expected ErrStreamAborted, got %v", err)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "event: error") || !strings.Contains(body, `"type":"api_error"`) {
+			t.Errorf("Expected an error event, got %q", body)
+		}
+		if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+			t.Errorf("Expected the stream to end with [DONE], got %q", body)
+		}
+	})
+
+	t.Run("UpstreamErrorMidStream", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			errorData string
+			wantType  string
+		}{
+			{"openai error chunk", `data: {"error":{"message":"Rate limit reached","type":"rate_limit_exceeded"}}`, "rate_limit_error"},
+			{"anthropic error event", "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}", "overloaded_error"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sseData := "data: {\"chunk\": \"hello\"}\n\n" + tt.errorData + "\n\ndata: {\"chunk\": \"never sent\"}\n\n"
+				resp := &http.Response{
+					StatusCode: 200,
+					Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+					Body:       io.NopCloser(strings.NewReader(sseData)),
+				}
+
+				w := httptest.NewRecorder()
+				err := processor.ProcessStreamingResponse(context.Background(), w, resp, "openai")
+				if !errors.Is(err, ErrStreamAborted) {
+					t.Fatalf("Expected ErrStreamAborted, got %v", err)
+				}
+
+				body := w.Body.String()
+				if !strings.Contains(body, "hello") {
+					t.Error("Expected events before the error to be delivered")
+				}
+				if strings.Contains(body, "never sent") {
+					t.Error("Expected the stream to stop at the error")
+				}
+				if !strings.Contains(body, "event: error") || !strings.Contains(body, tt.wantType) {
+					t.Errorf("Expected an error event of type %s, got %q", tt.wantType, body)
+				}
+				if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+					t.Errorf("Expected the stream to end with [DONE], got %q", body)
+				}
+			})
+		}
 	})
 
 	t.Run("ClientDisconnectionDuringStream", func(t *testing.T) {
@@ -605,7 +716,7 @@ func TestStreamingProcessor_PassThroughErrors(t *testing.T) {
 		writer := transformer.NewSSEWriter(w)
 
 		// Should handle writer close error gracefully
-		err := processor.passThrough(reader, writer, w)
+		err := processor.passThrough(reader, writer, nil, nil, nil, "openai", nil)
 		if err != nil {
 			t.Logf("Pass-through writer close handled: %v", err)
 		}
@@ -661,6 +772,83 @@ data: [DONE]
 	})
 }
 
+func TestStreamingProcessor_ClientDisconnect(t *testing.T) {
+	transformerService := transformer.NewService()
+	processor := NewStreamingProcessor(transformerService)
+
+	// Upstream sends one event and then holds the stream open until the
+	// proxy closes its side of the connection
+	upstreamClosed := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"chunk\": \"first\"}\n\n")
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+		close(upstreamClosed)
+	}))
+	defer upstream.Close()
+
+	resp, err := http.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("Failed to reach upstream: %v", err)
+	}
+
+	body := &closeTrackingBody{ReadCloser: resp.Body}
+	resp.Body = body
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &safeTestWriter{ResponseWriter: httptest.NewRecorder()}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- processor.ProcessStreamingResponse(ctx, w, resp, "openai")
+	}()
+
+	// Simulate the client disconnecting mid-stream
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Expected graceful return on disconnect, got: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Streaming did not stop after client disconnect")
+	}
+
+	if !body.isClosed() {
+		t.Error("Expected upstream body to be closed")
+	}
+
+	select {
+	case <-upstreamClosed:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected upstream connection to be torn down")
+	}
+}
+
+// closeTrackingBody records whether the wrapped body was closed
+type closeTrackingBody struct {
+	io.ReadCloser
+	mu     sync.Mutex
+	closed bool
+}
+
+func (b *closeTrackingBody) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	return b.ReadCloser.Close()
+}
+
+func (b *closeTrackingBody) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
 // safeTestWriter wraps httptest.ResponseRecorder to be safe for concurrent access
 type safeTestWriter struct {
 	http.ResponseWriter
@@ -680,3 +868,384 @@ func (w *safeTestWriter) Flush() {
 		flusher.Flush()
 	}
 }
+
+func TestIsEmptyStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"done only", "text/event-stream", "data: [DONE]\n\n", true},
+		{"closed without events", "text/event-stream", "", true},
+		{"comments and pings only", "text/event-stream", ": keepalive\n\nevent: ping\n\ndata: [DONE]\n\n", true},
+		{"data before done", "text/event-stream", "data: {\"n\":1}\n\ndata: [DONE]\n\n", false},
+		{"ndjson content", "application/x-ndjson", "{\"done\":true}\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				Header: http.Header{"Content-Type": []string{tt.contentType}},
+				Body:   io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			if got := isEmptyStream(resp); got != tt.want {
+				t.Errorf("Expected empty=%v, got %v", tt.want, got)
+			}
+
+			// The peeked bytes must be replayed to the client
+			data, _ := io.ReadAll(resp.Body)
+			if string(data) != tt.body {
+				t.Errorf("Expected body to be preserved, got %q", data)
+			}
+		})
+	}
+}
+
+func TestPipeline_RetryEmptyStream(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Write([]byte("data: [DONE]\n\n"))
+			return
+		}
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	newPipeline := func(t *testing.T, retry bool) *Pipeline {
+		t.Helper()
+		cfg := &config.Config{
+			Performance: config.PerformanceConfig{
+				RequestTimeout:    30 * time.Second,
+				RetryEmptyStreams: retry,
+			},
+			Providers: []config.Provider{
+				{Name: "openai", APIBaseURL: server.URL, APIKey: "test-key"},
+			},
+			Routes: map[string]config.Route{
+				"default": {Provider: "openai", Model: "gpt-4"},
+			},
+		}
+
+		configService := config.NewService()
+		configService.SetConfig(cfg)
+		providerService := providers.NewService(configService)
+		if err := providerService.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize provider service: %v", err)
+		}
+		return NewPipeline(cfg, providerService, transformer.NewService(), router.New(cfg))
+	}
+
+	stream := func(t *testing.T, pipeline *Pipeline) string {
+		t.Helper()
+		req := &RequestContext{
+			Body: map[string]interface{}{
+				"model":    "gpt-4",
+				"stream":   true,
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+			},
+			IsStreaming: true,
+		}
+		respCtx, err := pipeline.ProcessRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer respCtx.Response.Body.Close()
+		data, err := io.ReadAll(respCtx.Response.Body)
+		if err != nil {
+			t.Fatalf("Failed to read stream: %v", err)
+		}
+		return string(data)
+	}
+
+	t.Run("RetriesOnce", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+
+		body := stream(t, newPipeline(t, true))
+		if got := atomic.LoadInt32(&calls); got != 2 {
+			t.Errorf("Expected 2 upstream calls, got %d", got)
+		}
+		if !strings.Contains(body, "Hello") {
+			t.Errorf("Expected retried stream to carry content, got %q", body)
+		}
+	})
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+
+		body := stream(t, newPipeline(t, false))
+		if got := atomic.LoadInt32(&calls); got != 1 {
+			t.Errorf("Expected 1 upstream call, got %d", got)
+		}
+		if strings.Contains(body, "Hello") {
+			t.Errorf("Expected the empty stream to be passed through, got %q", body)
+		}
+	})
+
+	t.Run("BudgetExhausted", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+
+		pipeline := newPipeline(t, true)
+		pipeline.retryBudget = newRetryBudget(config.RetryConfig{BudgetRatio: 0.1, BudgetBurst: 1})
+		pipeline.retryBudget.tokens = 0
+
+		body := stream(t, pipeline)
+		if got := atomic.LoadInt32(&calls); got != 1 {
+			t.Errorf("Expected 1 upstream call, got %d", got)
+		}
+		if strings.Contains(body, "Hello") {
+			t.Errorf("Expected the empty stream to be passed through, got %q", body)
+		}
+		if stats := pipeline.RetryBudget(); stats.Denied != 1 || stats.Retries != 0 {
+			t.Errorf("Expected one denied retry, got %+v", stats)
+		}
+	})
+
+	t.Run("ContentNotRetried", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 1)
+
+		body := stream(t, newPipeline(t, true))
+		if got := atomic.LoadInt32(&calls); got != 2 {
+			t.Errorf("Expected a single upstream call, got %d total", got)
+		}
+		if !strings.Contains(body, "Hello") {
+			t.Errorf("Expected stream content, got %q", body)
+		}
+	})
+}
+
+func TestStreamingProcessor_KeepAlive(t *testing.T) {
+	// stream sends one event, pauses, then finishes the stream
+	stream := func(pause time.Duration) *http.Response {
+		body, upstream := io.Pipe()
+		go func() {
+			upstream.Write([]byte("data: first\n\n"))
+			time.Sleep(pause)
+			upstream.Write([]byte("data: second\n\ndata: [DONE]\n\n"))
+			upstream.Close()
+		}()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       body,
+		}
+	}
+
+	t.Run("HeartbeatsDuringSilence", func(t *testing.T) {
+		processor := NewStreamingProcessor(transformer.NewService())
+		processor.SetKeepAliveInterval(20 * time.Millisecond)
+
+		w := httptest.NewRecorder()
+		if err := processor.ProcessStreamingResponse(context.Background(), w, stream(150*time.Millisecond), "openai"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		body := w.Body.String()
+		first := strings.Index(body, "data: first\n\n")
+		second := strings.Index(body, "data: second\n\n")
+		if first < 0 || second < 0 {
+			t.Fatalf("Expected both events in output, got %q", body)
+		}
+		if !strings.Contains(body[first:second], ": keepalive\n\n") {
+			t.Errorf("Expected a keepalive during the pause, got %q", body)
+		}
+		if strings.Contains(body[second:], "keepalive") {
+			t.Errorf("Expected no keepalive after the stream ended, got %q", body)
+		}
+
+		// Clients still see exactly the upstream events
+		reader := transformer.NewSSEReader(io.NopCloser(strings.NewReader(body)))
+		var data []string
+		for {
+			event, err := reader.ReadEvent()
+			if err != nil {
+				break
+			}
+			if event.Data != "" {
+				data = append(data, event.Data)
+			}
+		}
+		if strings.Join(data, ",") != "first,second,[DONE]" {
+			t.Errorf("Expected events first,second,[DONE], got %v", data)
+		}
+	})
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		processor := NewStreamingProcessor(transformer.NewService())
+
+		w := httptest.NewRecorder()
+		if err := processor.ProcessStreamingResponse(context.Background(), w, stream(50*time.Millisecond), "openai"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Contains(w.Body.String(), "keepalive") {
+			t.Errorf("Expected no keepalive when disabled, got %q", w.Body.String())
+		}
+	})
+}
+
+// flushCountingWriter records how many times the response was flushed and
+// what had been written at the last flush
+type flushCountingWriter struct {
+	*httptest.ResponseRecorder
+	mu      sync.Mutex
+	flushes int
+	flushed string
+}
+
+func (w *flushCountingWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushes++
+	w.flushed = w.Body.String()
+}
+
+func (w *flushCountingWriter) counts() (int, string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flushes, w.flushed
+}
+
+func TestBatchFlusher(t *testing.T) {
+	t.Run("FlushesFullBatches", func(t *testing.T) {
+		w := &flushCountingWriter{ResponseRecorder: httptest.NewRecorder()}
+		batch := newBatchFlusher(w, w, 3, time.Hour)
+
+		for i := 0; i < 4; i++ {
+			fmt.Fprintf(batch, "data: %d\n\n", i)
+			batch.Flush()
+		}
+		if flushes, flushed := w.counts(); flushes != 1 || strings.Contains(flushed, "data: 3") {
+			t.Errorf("Expected one flush of the first batch, got %d flushes of %q", flushes, flushed)
+		}
+
+		batch.Stop()
+		if flushes, flushed := w.counts(); flushes != 2 || !strings.Contains(flushed, "data: 3") {
+			t.Errorf("Expected Stop to flush the rest, got %d flushes of %q", flushes, flushed)
+		}
+	})
+
+	t.Run("FlushesAfterInterval", func(t *testing.T) {
+		w := &flushCountingWriter{ResponseRecorder: httptest.NewRecorder()}
+		batch := newBatchFlusher(w, w, 10, 10*time.Millisecond)
+		defer batch.Stop()
+
+		fmt.Fprint(batch, "data: only\n\n")
+		batch.Flush()
+		time.Sleep(50 * time.Millisecond)
+		if flushes, _ := w.counts(); flushes != 1 {
+			t.Errorf("Expected the pending event to be flushed after the interval, got %d flushes", flushes)
+		}
+	})
+}
+
+func TestStreamingProcessor_BatchedFlush(t *testing.T) {
+	upstream := "data: 1\n\ndata: 2\n\ndata: 3\n\ndata: 4\n\ndata: 5\n\ndata: [DONE]\n\n"
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(upstream)),
+	}
+
+	processor := NewStreamingProcessor(transformer.NewService())
+	processor.SetFlushBatching(4, time.Hour)
+
+	w := &flushCountingWriter{ResponseRecorder: httptest.NewRecorder()}
+	if err := processor.ProcessStreamingResponse(context.Background(), w, resp, "openai"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	flushes, flushed := w.counts()
+	if flushes >= 6 {
+		t.Errorf("Expected fewer flushes than events, got %d", flushes)
+	}
+	if !strings.HasSuffix(flushed, "data: [DONE]\n\n") {
+		t.Errorf("Expected [DONE] to be flushed when the stream ends, last flush saw %q", flushed)
+	}
+}
+
+func TestHasStreamContent(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"AnthropicText", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`, true},
+		{"AnthropicThinking", `{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Hmm"}}`, true},
+		{"AnthropicToolInput", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"a\""}}`, true},
+		{"AnthropicEmptyText", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":""}}`, false},
+		{"AnthropicMessageDelta", `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`, false},
+		{"AnthropicPing", `{"type":"ping"}`, false},
+		{"OpenAIContent", `{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`, true},
+		{"OpenAIToolCall", `{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{"}}]}}]}`, true},
+		{"OpenAIRole", `{"choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`, false},
+		{"Done", `[DONE]`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasStreamContent(&transformer.SSEEvent{Data: tt.data}); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPipeline_StreamResponseTokenLatency(t *testing.T) {
+	cfg := &config.Config{Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second}}
+	p := NewPipeline(cfg, nil, transformer.NewService(), router.New(cfg))
+
+	body, upstream := io.Pipe()
+	go func() {
+		upstream.Write([]byte(`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}` + "\n\n"))
+		time.Sleep(30 * time.Millisecond)
+		upstream.Write([]byte(`data: {"choices":[{"index":0,"delta":{"content":"Hello"}}]}` + "\n\n"))
+		time.Sleep(20 * time.Millisecond)
+		upstream.Write([]byte(`data: {"choices":[{"index":0,"delta":{"content":" world"}}]}` + "\n\ndata: [DONE]\n\n"))
+		upstream.Close()
+	}()
+
+	start := time.Now()
+	respCtx := &ResponseContext{
+		Response: &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       body,
+		},
+		Provider:  "openai",
+		StartTime: start.Add(-10 * time.Millisecond),
+	}
+
+	if err := p.StreamResponse(context.Background(), httptest.NewRecorder(), respCtx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The role-only chunk carries no content and does not count
+	if respCtx.FirstTokenLatency < 40*time.Millisecond || respCtx.FirstTokenLatency > time.Since(start)+10*time.Millisecond {
+		t.Errorf("Expected first token latency from the request start, got %v", respCtx.FirstTokenLatency)
+	}
+
+	stats, ok := p.StreamLatencyStats()["openai"]
+	if !ok {
+		t.Fatal("Expected stream latency stats for openai")
+	}
+	if stats.Streams != 1 {
+		t.Errorf("Expected 1 stream, got %d", stats.Streams)
+	}
+	if stats.FirstTokenP50Ms < 40 {
+		t.Errorf("Expected first token p50 of at least 40ms, got %v", stats.FirstTokenP50Ms)
+	}
+	if stats.InterTokenP50Ms < 15 {
+		t.Errorf("Expected inter-token p50 of at least 15ms, got %v", stats.InterTokenP50Ms)
+	}
+	samples := 0
+	for _, count := range stats.InterTokenHistogram {
+		samples += count
+	}
+	if samples != 1 {
+		t.Errorf("Expected 1 inter-token sample, got %d", samples)
+	}
+}
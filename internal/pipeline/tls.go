@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// hasCustomTLS reports whether a provider overrides the default TLS settings
+func hasCustomTLS(provider *config.Provider) bool {
+	return provider.CACertFile != "" || provider.InsecureSkipVerify
+}
+
+// newProviderClient returns a copy of base whose transport applies the
+// provider's CA bundle and certificate verification settings
+func newProviderClient(base *http.Client, provider *config.Provider) (*http.Client, error) {
+	var transport *http.Transport
+	if baseTransport, ok := base.Transport.(*http.Transport); ok {
+		transport = baseTransport.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+
+	if provider.CACertFile != "" {
+		pem, err := os.ReadFile(provider.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", provider.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if provider.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true // #nosec G402 - Explicit per-provider opt-in for local development
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	client := *base
+	client.Transport = transport
+	return &client, nil
+}
+
+// buildProviderClients creates HTTP clients for providers with custom TLS
+// settings and for the mock provider. Configuration validation rejects
+// unusable CA bundles, but a bundle can change after loading: providers whose
+// settings fail to load keep the default client, so their requests still fail
+// certificate verification.
+func buildProviderClients(base *http.Client, providers []config.Provider) map[string]*http.Client {
+	clients := make(map[string]*http.Client)
+	for i := range providers {
+		provider := &providers[i]
+		if provider.Name == config.MockProviderName {
+			client, err := newMockClient(provider)
+			if err != nil {
+				utils.GetLogger().Errorf("Failed to configure mock provider: %v", err)
+				continue
+			}
+			clients[provider.Name] = client
+			continue
+		}
+		if !hasCustomTLS(provider) {
+			continue
+		}
+
+		client, err := newProviderClient(base, provider)
+		if err != nil {
+			utils.GetLogger().Errorf("Failed to configure TLS for provider %s: %v", provider.Name, err)
+			continue
+		}
+		if provider.InsecureSkipVerify {
+			utils.GetLogger().Warnf("⚠️  TLS CERTIFICATE VERIFICATION IS DISABLED for provider %s (%s). "+
+				"Traffic to this provider can be intercepted; use insecure_skip_verify for local development only.",
+				provider.Name, provider.APIBaseURL)
+		}
+		clients[provider.Name] = client
+	}
+	return clients
+}
+
+// clientFor returns the HTTP client for a provider
+func (p *Pipeline) clientFor(provider *config.Provider) *http.Client {
+	p.configMu.RLock()
+	client, exists := p.providerClients[provider.Name]
+	p.configMu.RUnlock()
+	if exists {
+		return client
+	}
+	return p.httpClient
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestProviderTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	testutil.AssertNoError(t, os.WriteFile(caFile, caPEM, 0600))
+
+	base := &http.Client{Timeout: 5 * time.Second}
+
+	get := func(client *http.Client) error {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	t.Run("DefaultRejectsUnknownCA", func(t *testing.T) {
+		clients := buildProviderClients(base, []config.Provider{{Name: "local", APIBaseURL: server.URL}})
+		testutil.AssertEqual(t, 0, len(clients))
+
+		p := &Pipeline{httpClient: base, providerClients: clients}
+		testutil.AssertError(t, get(p.clientFor(&config.Provider{Name: "local"})))
+	})
+
+	t.Run("CustomCA", func(t *testing.T) {
+		provider := config.Provider{Name: "corp", APIBaseURL: server.URL, CACertFile: caFile}
+		p := &Pipeline{httpClient: base, providerClients: buildProviderClients(base, []config.Provider{provider})}
+
+		client := p.clientFor(&provider)
+		testutil.AssertTrue(t, client != base, "Expected a provider-specific client")
+		testutil.AssertEqual(t, base.Timeout, client.Timeout)
+		testutil.AssertNoError(t, get(client))
+	})
+
+	t.Run("InsecureSkipVerify", func(t *testing.T) {
+		provider := config.Provider{Name: "ollama", APIBaseURL: server.URL, InsecureSkipVerify: true}
+		p := &Pipeline{httpClient: base, providerClients: buildProviderClients(base, []config.Provider{provider})}
+		testutil.AssertNoError(t, get(p.clientFor(&provider)))
+	})
+
+	t.Run("InvalidCABundle", func(t *testing.T) {
+		badFile := filepath.Join(t.TempDir(), "bad.pem")
+		testutil.AssertNoError(t, os.WriteFile(badFile, []byte("not a certificate"), 0600))
+
+		_, err := newProviderClient(base, &config.Provider{Name: "corp", CACertFile: badFile})
+		testutil.AssertError(t, err)
+
+		_, err = newProviderClient(base, &config.Provider{Name: "corp", CACertFile: filepath.Join(t.TempDir(), "missing.pem")})
+		testutil.AssertError(t, err)
+
+		// A provider whose bundle fails to load keeps the secure default
+		clients := buildProviderClients(base, []config.Provider{{Name: "corp", CACertFile: badFile}})
+		testutil.AssertEqual(t, 0, len(clients))
+	})
+}
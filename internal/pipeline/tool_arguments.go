@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// toolArgumentTracker accumulates the input_json_delta fragments of each
+// tool_use block in an Anthropic stream and checks them once the block ends.
+// Events are never held back: when the arguments turn out to be malformed,
+// corrective events are written just before the event ending the block.
+type toolArgumentTracker struct {
+	provider string
+	blocks   map[int]*strings.Builder // Arguments of open tool_use blocks by index
+}
+
+// newToolArgumentTracker returns a tracker, or nil when validation is
+// disabled. All methods are safe to call on a nil tracker.
+func newToolArgumentTracker(enabled bool, provider string) *toolArgumentTracker {
+	if !enabled {
+		return nil
+	}
+	return &toolArgumentTracker{provider: provider, blocks: make(map[int]*strings.Builder)}
+}
+
+// corrections notes an event about to be written and returns the events to
+// write before it to leave every tool call with valid JSON arguments
+func (t *toolArgumentTracker) corrections(event *transformer.SSEEvent) []*transformer.SSEEvent {
+	if t == nil || event == nil {
+		return nil
+	}
+
+	// The end of the message or stream closes blocks the provider left open
+	if event.Data == "[DONE]" {
+		return t.finish()
+	}
+
+	// Only tool_use blocks, their deltas and block or message ends matter.
+	// Cheap checks keep text deltas from being decoded.
+	if !strings.Contains(event.Data, `"tool_use"`) && !strings.Contains(event.Data, `"input_json_delta"`) &&
+		!strings.Contains(event.Data, `"content_block_stop"`) && !strings.Contains(event.Data, `"message_`) {
+		return nil
+	}
+
+	var payload struct {
+		Type         string `json:"type"`
+		Index        int    `json:"index"`
+		ContentBlock struct {
+			Type string `json:"type"`
+		} `json:"content_block"`
+		Delta struct {
+			Type        string `json:"type"`
+			PartialJSON string `json:"partial_json"`
+		} `json:"delta"`
+	}
+	if err := json.Unmarshal([]byte(event.Data), &payload); err != nil {
+		return nil
+	}
+
+	switch payload.Type {
+	case "content_block_start":
+		if payload.ContentBlock.Type == "tool_use" || payload.ContentBlock.Type == "server_tool_use" {
+			t.blocks[payload.Index] = &strings.Builder{}
+		}
+	case "content_block_delta":
+		if args, ok := t.blocks[payload.Index]; ok && payload.Delta.Type == "input_json_delta" {
+			args.WriteString(payload.Delta.PartialJSON)
+		}
+	case "content_block_stop":
+		if args, ok := t.blocks[payload.Index]; ok {
+			delete(t.blocks, payload.Index)
+			return t.complete(payload.Index, args.String())
+		}
+	case "message_delta", "message_stop":
+		return t.finish()
+	}
+	return nil
+}
+
+// finish returns the events completing and closing every open tool_use
+// block, for a stream ending without content_block_stop events
+func (t *toolArgumentTracker) finish() []*transformer.SSEEvent {
+	if t == nil || len(t.blocks) == 0 {
+		return nil
+	}
+
+	indexes := make([]int, 0, len(t.blocks))
+	for index := range t.blocks {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var events []*transformer.SSEEvent
+	for _, index := range indexes {
+		events = append(events, t.complete(index, t.blocks[index].String())...)
+		events = append(events, streamEvent("content_block_stop", map[string]interface{}{
+			"type":  "content_block_stop",
+			"index": index,
+		}))
+	}
+	t.blocks = make(map[int]*strings.Builder)
+	return events
+}
+
+// complete returns an input_json_delta appending what the arguments of a
+// block lack to be valid JSON, or nothing when they already are or cannot
+// be fixed by appending
+func (t *toolArgumentTracker) complete(index int, args string) []*transformer.SSEEvent {
+	if strings.TrimSpace(args) == "" || json.Valid([]byte(args)) {
+		return nil // Clients read no arguments as an empty object
+	}
+
+	suffix, ok := jsonCompletion(args)
+	if !ok {
+		utils.GetLogger().Warnf("Tool call arguments from %s are not valid JSON and cannot be completed", t.provider)
+		return nil
+	}
+	utils.GetLogger().Warnf("Completed truncated tool call arguments from %s with %q", t.provider, suffix)
+
+	return []*transformer.SSEEvent{streamEvent("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": index,
+		"delta": map[string]interface{}{
+			"type":         "input_json_delta",
+			"partial_json": suffix,
+		},
+	})}
+}
+
+// jsonCompletion returns the text that turns truncated JSON into a valid
+// document by closing an open string, giving a dangling key or colon a null
+// value and closing open objects and arrays
+func jsonCompletion(partial string) (string, bool) {
+	var closers []byte
+	inString, escaped := false, false
+	for i := 0; i < len(partial); i++ {
+		c := partial[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			closers = append(closers, '}')
+		case c == '[':
+			closers = append(closers, ']')
+		case c == '}' || c == ']':
+			if len(closers) == 0 || closers[len(closers)-1] != c {
+				return "", false
+			}
+			closers = closers[:len(closers)-1]
+		}
+	}
+
+	var prefix strings.Builder
+	if escaped {
+		prefix.WriteByte('\\') // Make the dangling backslash a literal one
+	}
+	if inString {
+		prefix.WriteByte('"')
+	}
+
+	closing := make([]byte, len(closers))
+	for i, c := range closers {
+		closing[len(closers)-1-i] = c
+	}
+
+	for _, value := range []string{"", "null", ":null"} {
+		suffix := prefix.String() + value + string(closing)
+		if json.Valid([]byte(partial + suffix)) {
+			return suffix, true
+		}
+	}
+	return "", false
+}
+
+// streamEvent builds an Anthropic stream event
+func streamEvent(eventType string, data map[string]interface{}) *transformer.SSEEvent {
+	jsonData, _ := json.Marshal(data) // Safe to ignore: maps of plain values always marshal
+	return &transformer.SSEEvent{Event: eventType, Data: string(jsonData)}
+}
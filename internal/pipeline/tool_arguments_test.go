@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+func TestJSONCompletion(t *testing.T) {
+	tests := []struct {
+		partial string
+		suffix  string
+		ok      bool
+	}{
+		{`{"city": "Par`, `"}`, true},
+		{`{"city": "Paris", "days": [1, 2`, `]}`, true},
+		{`{"city": "Paris", "unit"`, `:null}`, true},
+		{`{"city":`, `null}`, true},
+		{`{"path": "C:\`, `\"}`, true},
+		{`{"nested": {"a": [{"b": "c`, `"}]}}`, true},
+		{`{"city": "Paris",`, ``, false},
+		{`{"ok": tru`, ``, false},
+		{`{"a": 1}}`, ``, false},
+	}
+
+	for _, tt := range tests {
+		suffix, ok := jsonCompletion(tt.partial)
+		if ok != tt.ok || suffix != tt.suffix {
+			t.Errorf("jsonCompletion(%q) = %q, %v, want %q, %v", tt.partial, suffix, ok, tt.suffix, tt.ok)
+		}
+		if ok && !json.Valid([]byte(tt.partial+suffix)) {
+			t.Errorf("jsonCompletion(%q) left invalid JSON %q", tt.partial, tt.partial+suffix)
+		}
+	}
+}
+
+// toolStream builds an Anthropic stream with one tool_use block whose
+// arguments arrive in fragments, ending the block when stop is set
+func toolStream(fragments []string, stop bool) string {
+	var b strings.Builder
+	b.WriteString("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n")
+	b.WriteString("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n")
+	b.WriteString("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Checking\"}}\n\n")
+	b.WriteString("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+	b.WriteString("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"weather\",\"input\":{}}}\n\n")
+	for _, fragment := range fragments {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": 1,
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": fragment},
+		})
+		b.WriteString("event: content_block_delta\ndata: " + string(data) + "\n\n")
+	}
+	if stop {
+		b.WriteString("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}\n\n")
+	}
+	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"}}\n\n")
+	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	return b.String()
+}
+
+// streamToolArguments streams body with tool argument validation set as
+// given and returns the reassembled arguments of block 1 and the event types
+func streamToolArguments(t *testing.T, body string, validate bool) (string, []string) {
+	t.Helper()
+
+	processor := NewStreamingProcessor(transformer.NewService())
+	processor.SetToolArgumentValidation(validate)
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+	w := httptest.NewRecorder()
+	if err := processor.ProcessStreamingResponse(context.Background(), w, resp, "openai"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var args strings.Builder
+	var types []string
+	reader := transformer.NewSSEReader(io.NopCloser(strings.NewReader(w.Body.String())))
+	for {
+		event, err := reader.ReadEvent()
+		if err != nil {
+			break
+		}
+		var payload struct {
+			Type  string `json:"type"`
+			Index int    `json:"index"`
+			Delta struct {
+				PartialJSON string `json:"partial_json"`
+			} `json:"delta"`
+		}
+		if err := json.Unmarshal([]byte(event.Data), &payload); err != nil {
+			continue
+		}
+		types = append(types, payload.Type)
+		if payload.Type == "content_block_delta" && payload.Index == 1 {
+			args.WriteString(payload.Delta.PartialJSON)
+		}
+	}
+	return args.String(), types
+}
+
+func TestStreamingProcessor_ToolArgumentValidation(t *testing.T) {
+	t.Run("CompletesTruncatedArguments", func(t *testing.T) {
+		args, types := streamToolArguments(t, toolStream([]string{`{"city": `, `"Par`}, true), true)
+		if args != `{"city": "Par"}` {
+			t.Errorf("Expected completed arguments, got %q", args)
+		}
+		// The correction comes before the block ends
+		if got := strings.Join(types[len(types)-4:], ","); got != "content_block_delta,content_block_stop,message_delta,message_stop" {
+			t.Errorf("Expected correction before content_block_stop, got %s", got)
+		}
+	})
+
+	t.Run("ClosesUnterminatedBlock", func(t *testing.T) {
+		args, types := streamToolArguments(t, toolStream([]string{`{"days": [1, 2`}, false), true)
+		if args != `{"days": [1, 2]}` {
+			t.Errorf("Expected completed arguments, got %q", args)
+		}
+		if got := strings.Join(types[len(types)-4:], ","); got != "content_block_delta,content_block_stop,message_delta,message_stop" {
+			t.Errorf("Expected the block closed before message_delta, got %s", got)
+		}
+	})
+
+	t.Run("LeavesValidArguments", func(t *testing.T) {
+		body := toolStream([]string{`{"city": `, `"Paris"}`}, true)
+		args, types := streamToolArguments(t, body, true)
+		if args != `{"city": "Paris"}` {
+			t.Errorf("Expected arguments unchanged, got %q", args)
+		}
+		_, unvalidated := streamToolArguments(t, body, false)
+		if len(types) != len(unvalidated) {
+			t.Errorf("Expected no extra events for valid arguments, got %v", types)
+		}
+	})
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		args, _ := streamToolArguments(t, toolStream([]string{`{"city": "Par`}, true), false)
+		if args != `{"city": "Par` {
+			t.Errorf("Expected arguments as sent, got %q", args)
+		}
+	})
+}
+
+func TestToolArgumentTracker_ContentOnly(t *testing.T) {
+	tracker := newToolArgumentTracker(true, "openai")
+	events := []*transformer.SSEEvent{
+		{Event: "content_block_start", Data: `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`},
+		{Event: "content_block_delta", Data: `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`},
+		{Event: "content_block_stop", Data: `{"type":"content_block_stop","index":0}`},
+		{Event: "message_stop", Data: `{"type":"message_stop"}`},
+	}
+	for _, event := range events {
+		if corrections := tracker.corrections(event); len(corrections) != 0 {
+			t.Errorf("Expected no corrections for %s, got %d", event.Event, len(corrections))
+		}
+	}
+
+	var disabled *toolArgumentTracker
+	if corrections := disabled.corrections(events[1]); corrections != nil {
+		t.Errorf("Expected a nil tracker to ignore events, got %v", corrections)
+	}
+}
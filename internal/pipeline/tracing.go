@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"net/http"
+
+	"github.com/orchestre-dev/ccproxy/internal/tracing"
+)
+
+// requestSpanName names the span recorded around ProcessRequest
+const requestSpanName = "ccproxy.request"
+
+// SetTracer enables a span around each request and trace context
+// propagation to providers. A nil tracer disables both.
+func (p *Pipeline) SetTracer(tracer *tracing.Tracer) {
+	p.tracer = tracer
+}
+
+// endRequestSpan records the outcome of a request on its span. For streaming
+// requests the span ends once the provider has answered, before the stream
+// is relayed, so output tokens are not known yet.
+func endRequestSpan(span *tracing.Span, req *RequestContext, resp *ResponseContext, err error) {
+	if span == nil {
+		return
+	}
+	defer span.End()
+
+	span.SetAttribute("ccproxy.streaming", req.IsStreaming)
+	if err != nil {
+		span.SetError(err.Error())
+		return
+	}
+
+	span.SetAttribute("gen_ai.system", resp.Provider)
+	span.SetAttribute("gen_ai.request.model", resp.Model)
+	span.SetAttribute("ccproxy.routing_strategy", resp.RoutingStrategy)
+	inputTokens := resp.InputTokens
+	if inputTokens == 0 {
+		inputTokens = resp.TokenCount
+	}
+	span.SetAttribute("gen_ai.usage.input_tokens", inputTokens)
+	if resp.OutputTokens > 0 {
+		span.SetAttribute("gen_ai.usage.output_tokens", resp.OutputTokens)
+	}
+	if resp.UpstreamID != "" {
+		span.SetAttribute("ccproxy.upstream_request_id", resp.UpstreamID)
+	}
+	if resp.Response != nil {
+		span.SetAttribute("http.response.status_code", resp.Response.StatusCode)
+		if resp.Response.StatusCode >= http.StatusBadRequest {
+			span.SetError(http.StatusText(resp.Response.StatusCode))
+		}
+	}
+}
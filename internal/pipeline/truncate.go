@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// requestOverheadTokens matches the base cost used by utils.CountRequestTokens
+const requestOverheadTokens = 50
+
+// fitContextWindow makes a request fit the provider's context limit for model
+// by dropping the oldest turns, or rejects it when the provider's strategy is
+// "error". System prompts and the most recent turns are always kept, and the
+// conversation always restarts at a user turn that is not a tool result.
+func fitContextWindow(bodyMap map[string]interface{}, provider *config.Provider, model string) error {
+	limit := provider.ContextLimit(model)
+	if limit <= 0 {
+		return nil
+	}
+
+	messages, _ := bodyMap["messages"].([]interface{})
+	budget := limit - intValue(bodyMap["max_tokens"])
+	if budget <= 0 {
+		return ccerrors.Newf(ccerrors.ErrorTypeBadRequest,
+			"max_tokens leaves no room for input in the %d token context of %s", limit, model)
+	}
+
+	// Tokens kept no matter what, and the cost of each droppable message
+	fixed := requestOverheadTokens + utils.EstimateContentTokens(bodyMap["system"])
+	var turns []int // Indexes of droppable messages, oldest first
+	costs := make(map[int]int)
+	total := fixed
+	for i, msg := range messages {
+		cost := messageTokens(msg)
+		total += cost
+		if messageRole(msg) == "system" {
+			fixed += cost
+			continue
+		}
+		turns = append(turns, i)
+		costs[i] = cost
+	}
+	if total <= budget {
+		return nil
+	}
+
+	if provider.TruncationStrategy == config.TruncationError {
+		return ccerrors.Newf(ccerrors.ErrorTypeBadRequest,
+			"request needs about %d tokens but %s allows %d after max_tokens", total, model, budget)
+	}
+
+	// Find the oldest starting turn that fits and begins a valid conversation
+	remaining := total
+	for start, i := range turns {
+		if remaining <= budget && isConversationStart(messages[i]) {
+			kept := make([]interface{}, 0, len(messages)-start)
+			for j, msg := range messages {
+				if _, droppable := costs[j]; !droppable || j >= i {
+					kept = append(kept, msg)
+				}
+			}
+			bodyMap["messages"] = kept
+
+			utils.GetLogger().Warnf("Truncated %d oldest messages (about %d tokens) to fit the %d token context of %s",
+				start, total-remaining, limit, model)
+			return nil
+		}
+		remaining -= costs[i]
+	}
+
+	return ccerrors.Newf(ccerrors.ErrorTypeBadRequest,
+		"the most recent turn needs more than the %d token context of %s allows after max_tokens", limit, model)
+}
+
+// messageTokens estimates a message's content and tool calls
+func messageTokens(msg interface{}) int {
+	msgMap, ok := msg.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	return utils.EstimateContentTokens(msgMap["content"]) + utils.EstimateContentTokens(msgMap["tool_calls"])
+}
+
+// messageRole returns a message's role, or "" when it has none
+func messageRole(msg interface{}) string {
+	msgMap, ok := msg.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	role, _ := msgMap["role"].(string)
+	return role
+}
+
+// isConversationStart reports whether msg is a user turn that does not answer
+// an earlier tool call, so a conversation can begin with it
+func isConversationStart(msg interface{}) bool {
+	if messageRole(msg) != "user" {
+		return false
+	}
+	blocks, ok := msg.(map[string]interface{})["content"].([]interface{})
+	if !ok {
+		return true
+	}
+	for _, block := range blocks {
+		if blockMap, ok := block.(map[string]interface{}); ok && blockMap["type"] == "tool_result" {
+			return false
+		}
+	}
+	return true
+}
+
+// intValue returns a numeric request field such as max_tokens, or 0 when it
+// is absent
+func intValue(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+)
+
+func TestFitContextWindow(t *testing.T) {
+	// text returns a string of about n tokens
+	text := func(n int) string { return strings.Repeat("abcd", n) }
+	user := func(n int) interface{} { return map[string]interface{}{"role": "user", "content": text(n)} }
+	assistant := func(n int) interface{} { return map[string]interface{}{"role": "assistant", "content": text(n)} }
+	toolResult := map[string]interface{}{
+		"role": "user",
+		"content": []interface{}{
+			map[string]interface{}{"type": "tool_result", "tool_use_id": "t1", "content": "ok"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		strategy  string
+		limit     int
+		maxTokens float64
+		messages  []interface{}
+		wantLen   int
+		wantFirst interface{} // Expected first kept message, nil to skip
+		wantErr   string
+	}{
+		{
+			name:     "fits unchanged",
+			limit:    1000,
+			messages: []interface{}{user(100), assistant(100), user(100)},
+			wantLen:  3,
+		},
+		{
+			name:     "drops oldest turns",
+			limit:    500,
+			messages: []interface{}{user(200), assistant(200), user(100), assistant(100), user(100)},
+			wantLen:  3,
+		},
+		{
+			name:      "reserves max_tokens",
+			limit:     600,
+			maxTokens: 300,
+			messages:  []interface{}{user(200), assistant(100), user(100)},
+			wantLen:   1,
+		},
+		{
+			name:  "keeps system messages",
+			limit: 400,
+			messages: []interface{}{
+				map[string]interface{}{"role": "system", "content": text(100)},
+				user(200), assistant(100), user(100),
+			},
+			wantLen:   2,
+			wantFirst: map[string]interface{}{"role": "system", "content": text(100)},
+		},
+		{
+			name:      "skips orphaned tool results",
+			limit:     400,
+			messages:  []interface{}{user(200), assistant(100), toolResult, assistant(50), user(50)},
+			wantLen:   1,
+			wantFirst: user(50),
+		},
+		{
+			name:     "most recent turn too large",
+			limit:    200,
+			messages: []interface{}{user(100), assistant(100), user(300)},
+			wantErr:  "most recent turn",
+		},
+		{
+			name:     "error strategy",
+			strategy: config.TruncationError,
+			limit:    200,
+			messages: []interface{}{user(100), assistant(100), user(100)},
+			wantErr:  "allows 200",
+		},
+		{
+			name:      "max_tokens exceeds limit",
+			limit:     200,
+			maxTokens: 200,
+			messages:  []interface{}{user(10)},
+			wantErr:   "no room for input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &config.Provider{
+				Name:               "groq",
+				ContextLimits:      map[string]int{"llama": tt.limit},
+				TruncationStrategy: tt.strategy,
+			}
+			bodyMap := map[string]interface{}{"messages": tt.messages}
+			if tt.maxTokens > 0 {
+				bodyMap["max_tokens"] = tt.maxTokens
+			}
+
+			err := fitContextWindow(bodyMap, provider, "llama")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			kept := bodyMap["messages"].([]interface{})
+			if len(kept) != tt.wantLen {
+				t.Fatalf("Expected %d messages, got %d", tt.wantLen, len(kept))
+			}
+			if tt.wantFirst != nil && messageTokens(kept[0]) != messageTokens(tt.wantFirst) {
+				t.Errorf("Expected first message %v, got %v", tt.wantFirst, kept[0])
+			}
+			if last, want := kept[len(kept)-1], tt.messages[len(tt.messages)-1]; messageTokens(last) != messageTokens(want) {
+				t.Errorf("Expected the most recent turn to be kept")
+			}
+		})
+	}
+
+	t.Run("other models untouched", func(t *testing.T) {
+		provider := &config.Provider{ContextLimits: map[string]int{"llama": 10}}
+		bodyMap := map[string]interface{}{"messages": []interface{}{user(1000)}}
+		if err := fitContextWindow(bodyMap, provider, "mixtral"); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
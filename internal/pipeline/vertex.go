@@ -0,0 +1,287 @@
+package pipeline
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+const (
+	// defaultVertexLocation is used when a Vertex AI provider has no location configured
+	defaultVertexLocation = "us-central1"
+
+	// vertexScope is the OAuth2 scope requested for Vertex AI access tokens
+	vertexScope = "https://www.googleapis.com/auth/cloud-platform"
+
+	// vertexTokenURL is the token endpoint used when the key file names none
+	vertexTokenURL = "https://oauth2.googleapis.com/token"
+
+	// vertexTokenRefreshMargin refreshes tokens this long before they expire
+	vertexTokenRefreshMargin = 5 * time.Minute
+)
+
+// serviceAccountKey is the subset of a Google service account key file we use
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// vertexTokenSource mints and caches OAuth2 access tokens for a service account
+type vertexTokenSource struct {
+	key        *serviceAccountKey
+	signer     *rsa.PrivateKey
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// newVertexTokenSource loads a service account key file
+func newVertexTokenSource(path string, httpClient *http.Client) (*vertexTokenSource, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- Path comes from the trusted provider configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account file: %w", err)
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to parse service account file: %w", err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("service account file %s is not a service account key", path)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = vertexTokenURL
+	}
+
+	signer, err := parseRSAPrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account private key: %w", err)
+	}
+
+	return &vertexTokenSource{
+		key:        &key,
+		signer:     signer,
+		httpClient: httpClient,
+		now:        time.Now,
+	}, nil
+}
+
+// parseRSAPrivateKey decodes a PEM encoded PKCS#8 or PKCS#1 RSA key
+func parseRSAPrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is not RSA")
+		}
+		return rsaKey, nil
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// Token returns a cached access token, refreshing it shortly before expiry
+func (s *vertexTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && s.now().Add(vertexTokenRefreshMargin).Before(s.expiry) {
+		return s.token, nil
+	}
+
+	token, expiresIn, err := s.fetchToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	s.expiry = s.now().Add(expiresIn)
+	return s.token, nil
+}
+
+// fetchToken exchanges a signed JWT assertion for an access token
+func (s *vertexTokenSource) fetchToken(ctx context.Context) (string, time.Duration, error) {
+	assertion, err := s.signAssertion()
+	if err != nil {
+		return "", 0, err
+	}
+
+	form := neturl.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.key.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", 0, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response has no access_token")
+	}
+
+	return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+}
+
+// signAssertion builds the RS256 JWT used for the jwt-bearer grant
+func (s *vertexTokenSource) signAssertion() (string, error) {
+	now := s.now()
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": s.key.PrivateKeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   s.key.ClientEmail,
+		"scope": vertexScope,
+		"aud":   s.key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token assertion: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// vertexTokenSource returns the shared token source for a provider's key file
+func (p *Pipeline) vertexTokenSource(provider *config.Provider) (*vertexTokenSource, error) {
+	p.vertexMu.Lock()
+	defer p.vertexMu.Unlock()
+
+	if source, exists := p.vertexTokens[provider.ServiceAccountFile]; exists {
+		return source, nil
+	}
+
+	httpClient := p.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	source, err := newVertexTokenSource(provider.ServiceAccountFile, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	if p.vertexTokens == nil {
+		p.vertexTokens = make(map[string]*vertexTokenSource)
+	}
+	p.vertexTokens[provider.ServiceAccountFile] = source
+	return source, nil
+}
+
+// vertexRequest wraps a transformed Gemini body with the Vertex AI model URL
+func (p *Pipeline) vertexRequest(provider *config.Provider, body interface{}, model string, isStreaming bool) (*transformer.RequestConfig, error) {
+	reqConfig, ok := body.(*transformer.RequestConfig)
+	if !ok {
+		reqConfig = &transformer.RequestConfig{Body: body}
+	}
+	if reqConfig.URL != "" {
+		return reqConfig, nil
+	}
+
+	project := provider.Project
+	if project == "" && provider.ServiceAccountFile != "" {
+		source, err := p.vertexTokenSource(provider)
+		if err != nil {
+			return nil, err
+		}
+		project = source.key.ProjectID
+	}
+	if project == "" {
+		return nil, fmt.Errorf("vertex provider %s has no project", provider.Name)
+	}
+
+	location := provider.Location
+	if location == "" {
+		location = defaultVertexLocation
+	}
+
+	action := "generateContent"
+	if isStreaming {
+		action = "streamGenerateContent?alt=sse"
+	}
+
+	reqConfig.URL = provider.EndpointURL(fmt.Sprintf("/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
+		neturl.PathEscape(project), neturl.PathEscape(location), neturl.PathEscape(model), action))
+	return reqConfig, nil
+}
+
+// setVertexAuthentication sets a Bearer token from the service account, or
+// from api_key when it holds a pre-issued access token
+func (p *Pipeline) setVertexAuthentication(req *http.Request, provider *config.Provider) error {
+	if provider.ServiceAccountFile == "" {
+		if provider.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+provider.APIKey)
+		}
+		return nil
+	}
+
+	source, err := p.vertexTokenSource(provider)
+	if err != nil {
+		return err
+	}
+	token, err := source.Token(req.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get Vertex AI access token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
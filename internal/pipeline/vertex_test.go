@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+)
+
+// writeServiceAccount writes a service account key file pointing at tokenURL
+func writeServiceAccount(t *testing.T, tokenURL string) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	testutil.AssertNoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	testutil.AssertNoError(t, err)
+
+	data, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "sa-project",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email": "ccproxy@sa-project.iam.gserviceaccount.com",
+		"token_uri":    tokenURL,
+	})
+	testutil.AssertNoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "sa.json")
+	testutil.AssertNoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestVertexTokenSource(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		testutil.AssertNoError(t, r.ParseForm())
+		testutil.AssertEqual(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+		testutil.AssertEqual(t, 3, len(strings.Split(r.Form.Get("assertion"), ".")))
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": 3600}`, n)
+	}))
+	defer server.Close()
+
+	source, err := newVertexTokenSource(writeServiceAccount(t, server.URL), server.Client())
+	testutil.AssertNoError(t, err)
+	now := time.Now()
+	source.now = func() time.Time { return now }
+
+	t.Run("CachesToken", func(t *testing.T) {
+		first, err := source.Token(context.Background())
+		testutil.AssertNoError(t, err)
+		second, err := source.Token(context.Background())
+		testutil.AssertNoError(t, err)
+
+		testutil.AssertEqual(t, "token-1", first)
+		testutil.AssertEqual(t, first, second)
+		testutil.AssertEqual(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("RefreshesBeforeExpiry", func(t *testing.T) {
+		now = now.Add(time.Hour - vertexTokenRefreshMargin)
+		token, err := source.Token(context.Background())
+		testutil.AssertNoError(t, err)
+
+		testutil.AssertEqual(t, "token-2", token)
+		testutil.AssertEqual(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestVertexTokenSource_Errors(t *testing.T) {
+	t.Run("NotServiceAccount", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "user.json")
+		testutil.AssertNoError(t, os.WriteFile(path, []byte(`{"type": "authorized_user"}`), 0600))
+
+		_, err := newVertexTokenSource(path, http.DefaultClient)
+		testutil.AssertError(t, err)
+	})
+
+	t.Run("TokenEndpointRejects", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+		}))
+		defer server.Close()
+
+		source, err := newVertexTokenSource(writeServiceAccount(t, server.URL), server.Client())
+		testutil.AssertNoError(t, err)
+
+		_, err = source.Token(context.Background())
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "status 401")
+	})
+}
+
+func TestPipeline_VertexRequest(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token": "sa-token", "expires_in": 3600}`))
+	}))
+	defer tokenServer.Close()
+
+	pipeline := &Pipeline{httpClient: tokenServer.Client()}
+	body := map[string]interface{}{"contents": []interface{}{}}
+
+	t.Run("ConfiguredProject", func(t *testing.T) {
+		provider := &config.Provider{
+			Name:       "vertex",
+			APIBaseURL: "https://europe-west4-aiplatform.googleapis.com/",
+			Project:    "my-project",
+			Location:   "europe-west4",
+		}
+
+		reqConfig, err := pipeline.vertexRequest(provider, body, "gemini-1.5-pro", false)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "https://europe-west4-aiplatform.googleapis.com/v1/projects/my-project/locations/europe-west4/publishers/google/models/gemini-1.5-pro:generateContent", reqConfig.URL)
+	})
+
+	t.Run("StreamingWithServiceAccountProject", func(t *testing.T) {
+		provider := &config.Provider{
+			Name:               "vertex",
+			APIBaseURL:         "https://us-central1-aiplatform.googleapis.com",
+			ServiceAccountFile: writeServiceAccount(t, tokenServer.URL),
+		}
+
+		reqConfig, err := pipeline.vertexRequest(provider, &transformer.RequestConfig{Body: body}, "gemini-1.5-flash", true)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "https://us-central1-aiplatform.googleapis.com/v1/projects/sa-project/locations/us-central1/publishers/google/models/gemini-1.5-flash:streamGenerateContent?alt=sse", reqConfig.URL)
+
+		httpReq, err := pipeline.buildHTTPRequest(context.Background(), provider, reqConfig, true, "vertex")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "Bearer sa-token", httpReq.Header.Get("Authorization"))
+	})
+
+	t.Run("StaticAccessToken", func(t *testing.T) {
+		provider := &config.Provider{
+			Name:       "vertex",
+			APIBaseURL: "https://us-central1-aiplatform.googleapis.com",
+			APIKey:     "ya29.static",
+			Project:    "my-project",
+		}
+
+		reqConfig, err := pipeline.vertexRequest(provider, body, "gemini-1.5-pro", false)
+		testutil.AssertNoError(t, err)
+		httpReq, err := pipeline.buildHTTPRequest(context.Background(), provider, reqConfig, false, "vertex")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "Bearer ya29.static", httpReq.Header.Get("Authorization"))
+	})
+
+	t.Run("MissingProject", func(t *testing.T) {
+		provider := &config.Provider{Name: "vertex", APIBaseURL: "https://us-central1-aiplatform.googleapis.com"}
+
+		_, err := pipeline.vertexRequest(provider, body, "gemini-1.5-pro", false)
+		testutil.AssertError(t, err)
+	})
+}
@@ -0,0 +1,153 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// DefaultKeyProbeTimeout bounds each startup key probe
+const DefaultKeyProbeTimeout = 5 * time.Second
+
+// ValidateKeys sends one minimal authenticated request to every enabled
+// provider with credentials, concurrently, and warns about each key the
+// provider rejects. It returns the names of those providers, sorted. Network
+// errors and other statuses are not reported, as the key may still be fine.
+func (s *Service) ValidateKeys(ctx context.Context, timeout time.Duration) []string {
+	logger := utils.GetLogger()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		rejected []string
+	)
+	for _, provider := range s.GetAllProviders() {
+		if !provider.Enabled {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *config.Provider) {
+			defer wg.Done()
+
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			req, err := keyProbeRequest(probeCtx, p)
+			if err != nil {
+				logger.Debugf("Skipping API key check for provider %s: %v", p.Name, err)
+				return
+			}
+			if req == nil {
+				return
+			}
+
+			resp, err := s.httpClient.Do(req)
+			if err != nil {
+				logger.Debugf("Could not verify API key for provider %s: %v", p.Name, err)
+				return
+			}
+			_ = resp.Body.Close() // Safe to ignore: only the status matters
+
+			if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+				logger.Warnf("Provider %s rejected its API key (status %d), requests to it will fail",
+					p.Name, resp.StatusCode)
+				mu.Lock()
+				rejected = append(rejected, p.Name)
+				mu.Unlock()
+			}
+		}(provider)
+	}
+	wg.Wait()
+
+	sort.Strings(rejected)
+	return rejected
+}
+
+// probeAuthHeaders are the headers providers read credentials from
+var probeAuthHeaders = []string{"Authorization", "X-API-Key", "api-key", "x-goog-api-key"}
+
+// keyProbeRequest builds a cheap authenticated request that lists models, or
+// nil for providers that have no credentials to check. Credentials come from
+// api_key or, when it is empty, from the provider's custom headers.
+func keyProbeRequest(ctx context.Context, provider *config.Provider) (*http.Request, error) {
+	// Vertex AI authenticates with OAuth and mock providers need no key
+	if provider.Name == "vertex" || provider.Name == config.MockProviderName {
+		return nil, nil
+	}
+	if provider.APIKey == "" && !hasAuthHeader(provider.Headers) {
+		return nil, nil
+	}
+
+	var path string
+	switch provider.Name {
+	case "groq":
+		path = "/openai/v1/models"
+	case "openrouter":
+		// The models list is public, the key endpoint is not
+		path = "/api/v1/auth/key"
+	case "gemini":
+		path = "/" + provider.GeminiAPIVersion() + "/models"
+	case "ollama":
+		path = "/api/tags"
+	case "azure":
+		path = "/openai/models?api-version=" + neturl.QueryEscape(provider.AzureAPIVersion())
+	default:
+		path = "/v1/models"
+	}
+
+	url := provider.EndpointURL(path)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if provider.APIKey != "" {
+		switch provider.Name {
+		case "anthropic":
+			req.Header.Set("X-API-Key", provider.APIKey)
+		case "gemini":
+			req.Header.Set("x-goog-api-key", provider.APIKey)
+		case "azure":
+			req.Header.Set("api-key", provider.APIKey)
+		default:
+			req.Header.Set("Authorization", "Bearer "+provider.APIKey)
+		}
+	}
+	authHeaders := make(map[string]bool, len(req.Header))
+	for key := range req.Header {
+		authHeaders[key] = true
+	}
+	if provider.Name == "anthropic" {
+		req.Header.Set("anthropic-version", provider.AnthropicVersion())
+	}
+
+	// Custom headers are applied as on proxied requests, so they can pin the
+	// version or carry credentials but never replace those set from api_key
+	for key, value := range provider.Headers {
+		if !authHeaders[http.CanonicalHeaderKey(key)] {
+			req.Header.Set(key, value)
+		}
+	}
+	return req, nil
+}
+
+// hasAuthHeader reports whether custom headers carry credentials
+func hasAuthHeader(headers map[string]string) bool {
+	for key := range headers {
+		for _, name := range probeAuthHeaders {
+			if strings.EqualFold(key, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+)
+
+func TestKeyProbeRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    config.Provider
+		wantURL     string // Empty expects no probe
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "openai",
+			provider:    config.Provider{Name: "openai", APIBaseURL: "https://api.openai.com", APIKey: "sk-test"},
+			wantURL:     "https://api.openai.com/v1/models",
+			wantHeaders: map[string]string{"Authorization": "Bearer sk-test"},
+		},
+		{
+			name:     "anthropic",
+			provider: config.Provider{Name: "anthropic", APIBaseURL: "https://api.anthropic.com", APIKey: "sk-ant"},
+			wantURL:  "https://api.anthropic.com/v1/models",
+			wantHeaders: map[string]string{
+				"X-API-Key":         "sk-ant",
+				"anthropic-version": config.DefaultAnthropicVersion,
+				"Authorization":     "",
+			},
+		},
+		{
+			name:        "anthropic with api_version",
+			provider:    config.Provider{Name: "anthropic", APIBaseURL: "https://api.anthropic.com", APIKey: "sk-ant", APIVersion: "2024-01-01"},
+			wantURL:     "https://api.anthropic.com/v1/models",
+			wantHeaders: map[string]string{"anthropic-version": "2024-01-01"},
+		},
+		{
+			name:        "azure",
+			provider:    config.Provider{Name: "azure", APIBaseURL: "https://example.openai.azure.com", APIKey: "az-key"},
+			wantURL:     "https://example.openai.azure.com/openai/models?api-version=" + config.DefaultAzureAPIVersion,
+			wantHeaders: map[string]string{"api-key": "az-key", "Authorization": ""},
+		},
+		{
+			name:        "gemini",
+			provider:    config.Provider{Name: "gemini", APIBaseURL: "https://generativelanguage.googleapis.com", APIKey: "g-key"},
+			wantURL:     "https://generativelanguage.googleapis.com/v1beta/models",
+			wantHeaders: map[string]string{"x-goog-api-key": "g-key"},
+		},
+		{
+			name:     "groq",
+			provider: config.Provider{Name: "groq", APIBaseURL: "https://api.groq.com", APIKey: "gsk"},
+			wantURL:  "https://api.groq.com/openai/v1/models",
+		},
+		{
+			name:     "openrouter",
+			provider: config.Provider{Name: "openrouter", APIBaseURL: "https://openrouter.ai", APIKey: "or-key"},
+			wantURL:  "https://openrouter.ai/api/v1/auth/key",
+		},
+		{
+			name:     "ollama",
+			provider: config.Provider{Name: "ollama", APIBaseURL: "http://localhost:11434", APIKey: "local"},
+			wantURL:  "http://localhost:11434/api/tags",
+		},
+		{
+			name: "custom auth header",
+			provider: config.Provider{Name: "openai", APIBaseURL: "https://gateway.example.com",
+				Headers: map[string]string{"Authorization": "Bearer from-header", "X-Team": "ml"}},
+			wantURL:     "https://gateway.example.com/v1/models",
+			wantHeaders: map[string]string{"Authorization": "Bearer from-header", "X-Team": "ml"},
+		},
+		{
+			name: "custom header does not replace api_key",
+			provider: config.Provider{Name: "openai", APIBaseURL: "https://api.openai.com", APIKey: "sk-test",
+				Headers: map[string]string{"authorization": "Bearer other"}},
+			wantURL:     "https://api.openai.com/v1/models",
+			wantHeaders: map[string]string{"Authorization": "Bearer sk-test"},
+		},
+		{
+			name: "custom header pins anthropic-version",
+			provider: config.Provider{Name: "anthropic", APIBaseURL: "https://api.anthropic.com", APIKey: "sk-ant",
+				Headers: map[string]string{"anthropic-version": "2099-01-01"}},
+			wantURL:     "https://api.anthropic.com/v1/models",
+			wantHeaders: map[string]string{"anthropic-version": "2099-01-01"},
+		},
+		{
+			name:     "no credentials",
+			provider: config.Provider{Name: "openai", APIBaseURL: "https://api.openai.com", Headers: map[string]string{"X-Team": "ml"}},
+		},
+		{
+			name:     "vertex",
+			provider: config.Provider{Name: "vertex", APIBaseURL: "https://aiplatform.googleapis.com", APIKey: "token"},
+		},
+		{
+			name:     "mock",
+			provider: config.Provider{Name: config.MockProviderName, APIKey: "unused"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := keyProbeRequest(context.Background(), &tt.provider)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.wantURL == "" {
+				if req != nil {
+					t.Fatalf("Expected no probe, got %s", req.URL)
+				}
+				return
+			}
+			if req == nil {
+				t.Fatal("Expected a probe request")
+			}
+			if req.Method != "GET" || req.URL.String() != tt.wantURL {
+				t.Errorf("Expected GET %s, got %s %s", tt.wantURL, req.Method, req.URL)
+			}
+			for key, want := range tt.wantHeaders {
+				if got := req.Header.Get(key); got != want {
+					t.Errorf("Expected %s header %q, got %q", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateKeys(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	configService := config.NewService()
+	configService.SetConfig(&config.Config{Providers: []config.Provider{
+		{Name: "good", APIBaseURL: upstream.URL, APIKey: "good-key", Enabled: true},
+		{Name: "revoked", APIBaseURL: upstream.URL, APIKey: "revoked-key", Enabled: true},
+		{Name: "stale", APIBaseURL: upstream.URL, APIKey: "stale-key", Enabled: true},
+		{Name: "disabled", APIBaseURL: upstream.URL, APIKey: "bad-key"},
+	}})
+	service := NewService(configService)
+	service.httpClient = upstream.Client()
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	rejected := service.ValidateKeys(context.Background(), time.Second)
+	if want := []string{"revoked", "stale"}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("Expected rejected providers %v, got %v", want, rejected)
+	}
+}
@@ -7,12 +7,18 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
 	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
 
 // RouterMiddleware creates a middleware that performs intelligent model routing
 func RouterMiddleware(cfg *config.Config) gin.HandlerFunc {
-	router := New(cfg)
+	return Middleware(New(cfg))
+}
+
+// Middleware creates a routing middleware around an existing router, so it
+// follows the router's configuration when it is reloaded
+func Middleware(router *Router) gin.HandlerFunc {
 	logger := utils.GetLogger()
 
 	return func(c *gin.Context) {
@@ -43,64 +49,31 @@ func RouterMiddleware(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// Check for thinking parameter
-		if thinking, ok := body["thinking"].(bool); ok {
-			req.Thinking = thinking
-		}
-
-		// Count tokens
-		tokenCount := 0
-		params := &utils.MessageCreateParams{
-			Model: modelStr,
-		}
-
-		// Parse messages
-		if messages, ok := body["messages"].([]interface{}); ok {
-			params.Messages = make([]utils.Message, 0, len(messages))
-			for _, m := range messages {
-				if msgMap, ok := m.(map[string]interface{}); ok {
-					msg := utils.Message{
-						Role:    getStringValue(msgMap, "role"),
-						Content: msgMap["content"],
-					}
-					params.Messages = append(params.Messages, msg)
-				}
-			}
-		}
-
-		// Parse system
-		if system, ok := body["system"]; ok {
-			params.System = system
+		if thinking, ok := body["thinking"]; ok {
+			req.Thinking = transformer.ThinkingEnabled(thinking)
 		}
-
-		// Parse tools
-		if tools, ok := body["tools"].([]interface{}); ok {
-			params.Tools = make([]utils.Tool, 0, len(tools))
-			for _, t := range tools {
-				if toolMap, ok := t.(map[string]interface{}); ok {
-					tool := utils.Tool{
-						Name:        getStringValue(toolMap, "name"),
-						Description: getStringValue(toolMap, "description"),
-						InputSchema: toolMap["input_schema"],
-					}
-					params.Tools = append(params.Tools, tool)
-				}
+		req.UserText = utils.ExtractUserText(body)
+		req.Headers = make(map[string]string, len(c.Request.Header))
+		for key, values := range c.Request.Header {
+			if len(values) > 0 {
+				req.Headers[key] = values[0]
 			}
 		}
 
-		// Count tokens
-		var err error
-		tokenCount, err = utils.CountMessageTokens(params)
-		if err != nil {
-			logger.WithError(err).Error("Failed to count tokens in router middleware")
-			// Continue with tokenCount = 0
-		}
+		// Count tokens with the tokenizer of the requested model, as the
+		// pipeline does
+		provider, model := ParseModelString(modelStr)
+		tokenCount := utils.CountRequestTokensFor(provider, model, body)
 
 		// Perform routing
 		decision := router.Route(req, tokenCount)
 
-		// Update the model in the request
+		// Update the model in the request. Latency-aware and mirrored routes
+		// keep the original model so the pipeline routes them itself.
 		newModel := FormatModelString(decision.Provider, decision.Model)
-		body["model"] = newModel
+		if len(decision.Candidates) == 0 && decision.Shadow == nil {
+			body["model"] = newModel
+		}
 
 		// Log routing decision
 		logger.WithFields(map[string]interface{}{
@@ -112,6 +85,7 @@ func RouterMiddleware(cfg *config.Config) gin.HandlerFunc {
 
 		// Store routing metadata in context
 		c.Set("routing_decision", decision)
+		c.Set("requested_model", modelStr)
 		c.Set("token_count", tokenCount)
 
 		// Re-bind the modified body
@@ -276,6 +276,68 @@ func TestRouterMiddleware(t *testing.T) {
 			t.Error("Should not set routing decision for missing model")
 		}
 	})
+
+	t.Run("LatencyAwareRouteKeepsModel", func(t *testing.T) {
+		latencyCfg := &config.Config{
+			Routes: map[string]config.Route{
+				"default": {
+					Provider: "openai",
+					Model:    "gpt-4",
+					Targets:  []config.RouteTarget{{Provider: "groq", Model: "llama-3.3-70b-versatile"}},
+					Strategy: config.RouteStrategyLatencyAware,
+				},
+			},
+		}
+		body, _ := json.Marshal(map[string]interface{}{
+			"model":    "claude-3-opus",
+			"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+		})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		RouterMiddleware(latencyCfg)(c)
+
+		// The pipeline picks the candidate, so the model is left for it
+		var capturedBody map[string]interface{}
+		bodyBytes, _ := io.ReadAll(c.Request.Body)
+		json.Unmarshal(bodyBytes, &capturedBody)
+		if capturedBody["model"] != "claude-3-opus" {
+			t.Errorf("Expected the original model to be kept, got %v", capturedBody["model"])
+		}
+	})
+
+	t.Run("CountsWithModelTokenizer", func(t *testing.T) {
+		thresholdCfg := &config.Config{
+			Routes: map[string]config.Route{
+				"default":     {Provider: "openai", Model: "gpt-4"},
+				"longContext": {Provider: "anthropic", Model: "claude-3-opus", Threshold: 1000},
+			},
+		}
+		// 3.5 characters per token for Claude models, far more than the
+		// few tokens tiktoken merges repeated characters into
+		body, _ := json.Marshal(map[string]interface{}{
+			"model":    "claude-3-5-sonnet",
+			"messages": []interface{}{map[string]interface{}{"role": "user", "content": strings.Repeat("a", 7000)}},
+		})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		RouterMiddleware(thresholdCfg)(c)
+
+		if count := c.GetInt("token_count"); count < 2000 {
+			t.Errorf("Expected the Claude token estimate of about 2000 tokens, got %d", count)
+		}
+		decision := c.MustGet("routing_decision").(RouteDecision)
+		if decision.Provider != "anthropic" {
+			t.Errorf("Expected the longContext route, got %s", decision.Provider)
+		}
+	})
 }
 
 func TestBodyReader(t *testing.T) {
@@ -2,16 +2,33 @@ package router
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
 	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
 
+// DefaultLongContextThreshold is the token count above which the longContext
+// route is selected when the route does not configure its own threshold
+const DefaultLongContextThreshold = config.DefaultLongContextThreshold
+
+// EmbeddingsRoute is the route key used for /v1/embeddings requests
+const EmbeddingsRoute = "embeddings"
+
+// RouteHeader names a configured route that replaces automatic routing for a
+// request, honored when allow_route_header is set
+const RouteHeader = "X-CCProxy-Route"
+
 // Request represents the incoming request with model and parameters
 type Request struct {
-	Model    string `json:"model"`
-	Thinking bool   `json:"thinking,omitempty"`
+	Model    string            `json:"model"`
+	Thinking bool              `json:"thinking,omitempty"`
+	UserText string            `json:"-"` // Concatenated user message text for content rules
+	Headers  map[string]string `json:"-"` // Inbound header values by canonical name, for header rules
 }
 
 // RouteDecision represents the result of routing logic
@@ -20,32 +37,88 @@ type RouteDecision struct {
 	Model      string
 	Reason     string
 	Parameters map[string]interface{}
+
+	// Set for latency-aware routes, the pipeline picks among the candidates
+	Candidates  []config.RouteTarget
+	Exploration float64
+
+	// Set for routes that mirror traffic, the pipeline sends the copies
+	Shadow *config.ShadowTarget
 }
 
 // Router handles intelligent model routing based on various criteria
 type Router struct {
-	config *config.Config
+	config   *config.Config
+	configMu sync.RWMutex
+	now      func() time.Time // Clock for route schedules, replaced in tests
 }
 
 // New creates a new Router instance
 func New(cfg *config.Config) *Router {
+	compileRules(cfg)
 	return &Router{
 		config: cfg,
+		now:    time.Now,
+	}
+}
+
+// SetConfig replaces the configuration used for routing decisions, such as
+// after a reload
+func (r *Router) SetConfig(cfg *config.Config) {
+	compileRules(cfg)
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+	r.config = cfg
+}
+
+// currentConfig returns the configuration for one routing decision
+func (r *Router) currentConfig() *config.Config {
+	r.configMu.RLock()
+	defer r.configMu.RUnlock()
+	return r.config
+}
+
+// compileRules compiles header and content rules and route schedules. Loaded
+// configs are compiled during validation, this covers the rest.
+func compileRules(cfg *config.Config) {
+	for i := range cfg.HeaderRules {
+		if rule := &cfg.HeaderRules[i]; !rule.Compiled() {
+			if err := rule.Compile(); err != nil {
+				utils.GetLogger().Warnf("Header rule %d disabled: %v", i, err)
+			}
+		}
+	}
+	for i := range cfg.ContentRules {
+		if rule := &cfg.ContentRules[i]; !rule.Compiled() {
+			if err := rule.Compile(); err != nil {
+				utils.GetLogger().Warnf("Content rule %d disabled: %v", i, err)
+			}
+		}
+	}
+	for name, route := range cfg.Routes {
+		for i := range route.Schedules {
+			if schedule := &route.Schedules[i]; !schedule.Compiled() {
+				if err := schedule.Compile(); err != nil {
+					utils.GetLogger().Warnf("Schedule %d of route %s disabled: %v", i, name, err)
+				}
+			}
+		}
 	}
 }
 
 // Route determines which model to use based on request parameters and token count
 func (r *Router) Route(req Request, tokenCount int) RouteDecision {
 	logger := utils.GetLogger()
+	cfg := r.currentConfig()
 
 	// 1. Check for explicit provider,model format
-	if strings.Contains(req.Model, ",") {
+	if IsExplicitModel(req.Model) {
 		parts := strings.SplitN(req.Model, ",", 2)
 		if len(parts) == 2 {
 			logger.Debugf("Using explicit model selection: %s", req.Model)
 			// Use default route parameters as fallback for explicit selection
 			var parameters map[string]interface{}
-			if defaultRoute, exists := r.config.Routes["default"]; exists {
+			if defaultRoute, exists := cfg.Routes["default"]; exists {
 				parameters = defaultRoute.Parameters
 			}
 			return RouteDecision{
@@ -57,59 +130,177 @@ func (r *Router) Route(req Request, tokenCount int) RouteDecision {
 		}
 	}
 
+	// Header rules, then content rules, match before any model-based routing
+	for i := range cfg.HeaderRules {
+		rule := &cfg.HeaderRules[i]
+		value, ok := req.Headers[http.CanonicalHeaderKey(rule.Header)]
+		if !ok || !rule.Match(value) {
+			continue
+		}
+		name := ruleName(rule.Name, i)
+		logger.Debugf("Using header rule %s", name)
+		return r.ruleDecision(rule.Provider, rule.Model, fmt.Sprintf("header rule %s matched", name))
+	}
+	for i := range cfg.ContentRules {
+		rule := &cfg.ContentRules[i]
+		if !rule.Match(req.UserText) {
+			continue
+		}
+		name := ruleName(rule.Name, i)
+		logger.Debugf("Using content rule %s", name)
+		return r.ruleDecision(rule.Provider, rule.Model, fmt.Sprintf("content rule %s matched", name))
+	}
+
 	// 2. Check if there's a direct route for this model
-	if route, exists := r.config.Routes[req.Model]; exists && route.Provider != "" {
+	if route, exists := cfg.Routes[req.Model]; exists && route.Provider != "" {
 		logger.Debugf("Using direct route for model: %s", req.Model)
-		return RouteDecision{
-			Provider:   route.Provider,
-			Model:      route.Model,
-			Reason:     "direct model route",
-			Parameters: route.Parameters,
-		}
+		return r.decide(route, "direct model route")
 	}
 
 	// 3. Check for long context routing based on token count
-	if longContext, exists := r.config.Routes["longContext"]; exists && tokenCount > 60000 && longContext.Provider != "" {
+	if longContext, exists := cfg.Routes["longContext"]; exists && longContext.Provider != "" && tokenCount > longContext.LongContextThreshold() {
 		logger.Infof("Using long context model due to token count: %d", tokenCount)
-		return RouteDecision{
-			Provider:   longContext.Provider,
-			Model:      longContext.Model,
-			Reason:     fmt.Sprintf("token count (%d) exceeds threshold", tokenCount),
-			Parameters: longContext.Parameters,
-		}
+		return r.decide(longContext, fmt.Sprintf("token count (%d) exceeds threshold (%d)", tokenCount, longContext.LongContextThreshold()))
 	}
 
-	// 4. Check for background routing for haiku models
-	if background, exists := r.config.Routes["background"]; exists && strings.HasPrefix(req.Model, "claude-3-5-haiku") && background.Provider != "" {
-		logger.Info("Using background model for claude-3-5-haiku")
-		return RouteDecision{
-			Provider:   background.Provider,
-			Model:      background.Model,
-			Reason:     "haiku model routed to background",
-			Parameters: background.Parameters,
-		}
+	// 4. Check for thinking routing based on parameter, which overrides
+	// model-based routing such as background
+	if think, exists := cfg.Routes["think"]; exists && req.Thinking && think.Provider != "" {
+		logger.Info("Using think model due to thinking parameter")
+		return r.decide(think, "thinking parameter enabled")
 	}
 
-	// 5. Check for thinking routing based on parameter
-	if think, exists := r.config.Routes["think"]; exists && req.Thinking && think.Provider != "" {
-		logger.Info("Using think model due to thinking parameter")
-		return RouteDecision{
-			Provider:   think.Provider,
-			Model:      think.Model,
-			Reason:     "thinking parameter enabled",
-			Parameters: think.Parameters,
-		}
+	// 5. Check for background routing for haiku models
+	if background, exists := cfg.Routes["background"]; exists && strings.HasPrefix(req.Model, "claude-3-5-haiku") && background.Provider != "" {
+		logger.Info("Using background model for claude-3-5-haiku")
+		return r.decide(background, "haiku model routed to background")
 	}
 
 	// 6. Fall back to default model
-	defaultRoute := r.config.Routes["default"]
+	defaultRoute := cfg.Routes["default"]
 	logger.Debug("Using default model")
+	return r.decide(defaultRoute, "default model")
+}
+
+// ForcedRoute returns the decision for the route named by the request's
+// RouteHeader, reporting false when the header is absent or not allowed.
+// Names match route keys exactly, or else ignoring case. A name without a
+// configured route is a validation error.
+func (r *Router) ForcedRoute(req Request) (RouteDecision, bool, error) {
+	cfg := r.currentConfig()
+	name := strings.TrimSpace(req.Headers[http.CanonicalHeaderKey(RouteHeader)])
+	if name == "" {
+		return RouteDecision{}, false, nil
+	}
+	if !cfg.AllowRouteHeader {
+		utils.GetLogger().Debugf("Ignoring %s header, allow_route_header is not enabled", RouteHeader)
+		return RouteDecision{}, false, nil
+	}
+
+	key, route, exists := name, cfg.Routes[name], false
+	if route.Provider != "" {
+		exists = true
+	} else {
+		for routeName, candidate := range cfg.Routes {
+			if strings.EqualFold(routeName, name) && candidate.Provider != "" {
+				key, route, exists = routeName, candidate, true
+				break
+			}
+		}
+	}
+	if !exists {
+		return RouteDecision{}, false, ccerrors.NewValidationError(
+			fmt.Sprintf("%s names route %q, which is not configured", RouteHeader, name), nil)
+	}
+
+	utils.GetLogger().Debugf("Using route %s forced by %s header", key, RouteHeader)
+	return r.decide(route, fmt.Sprintf("route %s forced by %s header", key, RouteHeader)), true, nil
+}
+
+// RouteEmbeddings returns the target of the embeddings route, reporting false
+// when no embeddings route is configured
+func (r *Router) RouteEmbeddings() (RouteDecision, bool) {
+	cfg := r.currentConfig()
+	route, exists := cfg.Routes[EmbeddingsRoute]
+	if !exists || route.Provider == "" {
+		return RouteDecision{}, false
+	}
+	return r.decide(route, "embeddings route"), true
+}
+
+// DefaultRoute returns the target of the default route, reporting false when
+// no default route is configured
+func (r *Router) DefaultRoute() (RouteDecision, bool) {
+	cfg := r.currentConfig()
+	route, exists := cfg.Routes["default"]
+	if !exists || route.Provider == "" {
+		return RouteDecision{}, false
+	}
+	return r.decide(route, "default model"), true
+}
+
+// ruleDecision targets a routing rule's provider and model with the default
+// route's parameters
+func (r *Router) ruleDecision(provider, model, reason string) RouteDecision {
+	cfg := r.currentConfig()
+	var parameters map[string]interface{}
+	if defaultRoute, exists := cfg.Routes["default"]; exists {
+		parameters = defaultRoute.Parameters
+	}
 	return RouteDecision{
-		Provider:   defaultRoute.Provider,
-		Model:      defaultRoute.Model,
-		Reason:     "default model",
-		Parameters: defaultRoute.Parameters,
+		Provider:   provider,
+		Model:      model,
+		Reason:     reason,
+		Parameters: parameters,
+	}
+}
+
+// ruleName returns a rule's configured name or its index
+func ruleName(name string, index int) string {
+	if name == "" {
+		return fmt.Sprintf("#%d", index)
+	}
+	return name
+}
+
+// decide builds the decision for a route, applying the first schedule whose
+// time window contains the current time. Without one, a latency-aware route
+// hands its candidates to the pipeline.
+func (r *Router) decide(route config.Route, reason string) RouteDecision {
+	decision := RouteDecision{
+		Provider:   route.Provider,
+		Model:      route.Model,
+		Reason:     reason,
+		Parameters: route.Parameters,
+		Shadow:     route.Shadow,
 	}
+
+	now := r.now()
+	for i := range route.Schedules {
+		schedule := &route.Schedules[i]
+		if !schedule.Active(now) {
+			continue
+		}
+		decision.Provider = schedule.Provider
+		if schedule.Model != "" {
+			decision.Model = schedule.Model
+		}
+		decision.Reason = fmt.Sprintf("%s, scheduled %s-%s", reason, schedule.Start, schedule.End)
+		utils.GetLogger().Debugf("Route schedule %s-%s selected %s", schedule.Start, schedule.End, decision.Provider)
+		return decision
+	}
+
+	// An active schedule pins the target, otherwise the strategy chooses
+	if route.Strategy == config.RouteStrategyLatencyAware {
+		decision.Candidates = route.Candidates()
+		decision.Exploration = route.ExplorationRate()
+	}
+	return decision
+}
+
+// IsExplicitModel reports whether model uses the explicit provider,model form
+func IsExplicitModel(model string) bool {
+	return strings.Contains(model, ",")
 }
 
 // ParseModelString parses a model string which can be either "model" or "provider,model"
@@ -134,6 +325,7 @@ func FormatModelString(provider, model string) string {
 
 // GetProviderForModel returns the provider for a given model based on configuration
 func (r *Router) GetProviderForModel(model string) (string, error) {
+	cfg := r.currentConfig()
 	// Check if model already has provider specified
 	if strings.Contains(model, ",") {
 		provider, _ := ParseModelString(model)
@@ -141,7 +333,7 @@ func (r *Router) GetProviderForModel(model string) (string, error) {
 	}
 
 	// Look for model in provider configurations
-	for _, provider := range r.config.Providers {
+	for _, provider := range cfg.Providers {
 		for _, m := range provider.Models {
 			if m == model {
 				return provider.Name, nil
@@ -150,7 +342,7 @@ func (r *Router) GetProviderForModel(model string) (string, error) {
 	}
 
 	// If not found, use default provider
-	if defaultRoute, exists := r.config.Routes["default"]; exists && defaultRoute.Provider != "" {
+	if defaultRoute, exists := cfg.Routes["default"]; exists && defaultRoute.Provider != "" {
 		return defaultRoute.Provider, nil
 	}
 
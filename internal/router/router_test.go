@@ -3,6 +3,7 @@ package router
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/orchestre-dev/ccproxy/internal/config"
 )
@@ -28,6 +29,29 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestRouter_SetConfig(t *testing.T) {
+	router := New(&config.Config{
+		Routes: map[string]config.Route{"default": {Provider: "openai", Model: "gpt-4"}},
+	})
+
+	router.SetConfig(&config.Config{
+		Routes: map[string]config.Route{"default": {Provider: "anthropic", Model: "claude-3-opus"}},
+		HeaderRules: []config.HeaderRule{
+			{Header: "X-Team", Pattern: "^search$", Provider: "groq", Model: "llama3"},
+		},
+	})
+
+	decision := router.Route(Request{Model: "claude-3-sonnet"}, 100)
+	if decision.Provider != "anthropic" || decision.Model != "claude-3-opus" {
+		t.Errorf("Expected the replaced default route, got %s,%s", decision.Provider, decision.Model)
+	}
+
+	decision = router.Route(Request{Model: "claude-3-sonnet", Headers: map[string]string{"X-Team": "search"}}, 100)
+	if decision.Provider != "groq" {
+		t.Errorf("Expected the replaced header rules to be compiled, got %s", decision.Provider)
+	}
+}
+
 func TestRouter_Route(t *testing.T) {
 	cfg := &config.Config{
 		Routes: map[string]config.Route{
@@ -484,3 +508,329 @@ func TestRouter_ComplexRouting(t *testing.T) {
 		}
 	})
 }
+
+func TestRouter_LongContextThreshold(t *testing.T) {
+	cfg := &config.Config{
+		Routes: map[string]config.Route{
+			"default": {
+				Provider: "openai",
+				Model:    "gpt-4",
+			},
+			"longContext": {
+				Provider:  "gemini",
+				Model:     "gemini-1.5-pro",
+				Threshold: 10000,
+			},
+		},
+	}
+
+	router := New(cfg)
+
+	t.Run("AboveConfiguredThreshold", func(t *testing.T) {
+		decision := router.Route(Request{Model: "gpt-4"}, 15000)
+
+		if decision.Provider != "gemini" || decision.Model != "gemini-1.5-pro" {
+			t.Errorf("Expected long context route, got %s,%s", decision.Provider, decision.Model)
+		}
+
+		if !strings.Contains(decision.Reason, "threshold (10000)") {
+			t.Errorf("Expected reason to mention configured threshold, got %s", decision.Reason)
+		}
+	})
+
+	t.Run("AtConfiguredThreshold", func(t *testing.T) {
+		decision := router.Route(Request{Model: "gpt-4"}, 10000)
+
+		if decision.Provider != "openai" {
+			t.Errorf("Expected default route at threshold, got %s", decision.Provider)
+		}
+	})
+
+	t.Run("DefaultThresholdWhenUnset", func(t *testing.T) {
+		route := cfg.Routes["longContext"]
+		route.Threshold = 0
+		cfg.Routes["longContext"] = route
+
+		decision := router.Route(Request{Model: "gpt-4"}, 15000)
+		if decision.Provider != "openai" {
+			t.Errorf("Expected default route below default threshold, got %s", decision.Provider)
+		}
+
+		decision = router.Route(Request{Model: "gpt-4"}, DefaultLongContextThreshold+1)
+		if decision.Provider != "gemini" {
+			t.Errorf("Expected long context route above default threshold, got %s", decision.Provider)
+		}
+	})
+}
+
+func TestRouter_ThinkingOverridesBackground(t *testing.T) {
+	cfg := &config.Config{
+		Routes: map[string]config.Route{
+			"default":    {Provider: "openai", Model: "gpt-4"},
+			"background": {Provider: "groq", Model: "llama-3-8b"},
+			"think":      {Provider: "anthropic", Model: "claude-3-opus"},
+			"claude-3-5-haiku-direct": {
+				Provider: "openai",
+				Model:    "gpt-4o-mini",
+			},
+		},
+	}
+
+	router := New(cfg)
+
+	t.Run("ThinkingBeatsBackground", func(t *testing.T) {
+		decision := router.Route(Request{Model: "claude-3-5-haiku-20241022", Thinking: true}, 100)
+
+		if decision.Reason != "thinking parameter enabled" {
+			t.Errorf("Expected thinking route, got %s", decision.Reason)
+		}
+	})
+
+	t.Run("DirectRouteBeatsThinking", func(t *testing.T) {
+		decision := router.Route(Request{Model: "claude-3-5-haiku-direct", Thinking: true}, 100)
+
+		if decision.Reason != "direct model route" {
+			t.Errorf("Expected direct route, got %s", decision.Reason)
+		}
+	})
+}
+
+func TestRouter_Schedules(t *testing.T) {
+	cfg := &config.Config{
+		Routes: map[string]config.Route{
+			"default": {
+				Provider: "anthropic",
+				Model:    "claude-3-opus",
+				Schedules: []config.Schedule{
+					{Start: "22:00", End: "06:00", Timezone: "UTC", Provider: "deepseek", Model: "deepseek-chat"},
+					{Start: "12:00", End: "13:00", Timezone: "UTC", Provider: "groq"},
+				},
+			},
+			"think": {
+				Provider:  "anthropic",
+				Model:     "claude-3-opus",
+				Schedules: []config.Schedule{{Start: "00:00", End: "23:59", Timezone: "UTC", Provider: "openai", Model: "o1"}},
+			},
+		},
+	}
+
+	router := New(cfg)
+	at := func(clock string) func() time.Time {
+		return func() time.Time {
+			now, _ := time.Parse("2006-01-02 15:04", "2024-03-10 "+clock)
+			return now
+		}
+	}
+
+	tests := []struct {
+		name         string
+		clock        string
+		req          Request
+		wantProvider string
+		wantModel    string
+	}{
+		{"peak hours use route target", "10:00", Request{Model: "claude-3-opus"}, "anthropic", "claude-3-opus"},
+		{"overnight window", "23:30", Request{Model: "claude-3-opus"}, "deepseek", "deepseek-chat"},
+		{"overnight window after midnight", "03:00", Request{Model: "claude-3-opus"}, "deepseek", "deepseek-chat"},
+		{"window keeps route model", "12:15", Request{Model: "claude-3-opus"}, "groq", "claude-3-opus"},
+		{"window end exclusive", "06:00", Request{Model: "claude-3-opus"}, "anthropic", "claude-3-opus"},
+		{"applies to named routes", "10:00", Request{Model: "claude-3-opus", Thinking: true}, "openai", "o1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router.now = at(tt.clock)
+
+			decision := router.Route(tt.req, 100)
+			if decision.Provider != tt.wantProvider || decision.Model != tt.wantModel {
+				t.Errorf("Expected %s/%s, got %s/%s", tt.wantProvider, tt.wantModel, decision.Provider, decision.Model)
+			}
+		})
+	}
+
+	t.Run("ReasonNotesSchedule", func(t *testing.T) {
+		router.now = at("23:30")
+
+		decision := router.Route(Request{Model: "claude-3-opus"}, 100)
+		if !strings.Contains(decision.Reason, "scheduled 22:00-06:00") {
+			t.Errorf("Expected scheduled reason, got %s", decision.Reason)
+		}
+	})
+}
+
+func TestRouter_LatencyAwareCandidates(t *testing.T) {
+	cfg := &config.Config{
+		Routes: map[string]config.Route{
+			"default": {
+				Provider:  "anthropic",
+				Model:     "claude-3-opus",
+				Targets:   []config.RouteTarget{{Provider: "openai", Model: "gpt-4o"}},
+				Strategy:  config.RouteStrategyLatencyAware,
+				Schedules: []config.Schedule{{Start: "22:00", End: "06:00", Timezone: "UTC", Provider: "deepseek", Model: "deepseek-chat"}},
+			},
+		},
+	}
+
+	router := New(cfg)
+	router.now = func() time.Time { return time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC) }
+
+	decision := router.Route(Request{Model: "claude-3-opus"}, 100)
+	if len(decision.Candidates) != 2 || decision.Candidates[1].Provider != "openai" {
+		t.Errorf("Expected the route target and its extra target as candidates, got %v", decision.Candidates)
+	}
+	if decision.Exploration != config.DefaultRouteExploration {
+		t.Errorf("Expected default exploration %v, got %v", config.DefaultRouteExploration, decision.Exploration)
+	}
+
+	// An active schedule pins its target
+	router.now = func() time.Time { return time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC) }
+	decision = router.Route(Request{Model: "claude-3-opus"}, 100)
+	if decision.Provider != "deepseek" || len(decision.Candidates) != 0 {
+		t.Errorf("Expected the scheduled target without candidates, got %s with %v", decision.Provider, decision.Candidates)
+	}
+}
+
+func TestRouter_Shadow(t *testing.T) {
+	shadow := &config.ShadowTarget{Provider: "groq", Model: "llama-3.3-70b-versatile", Percentage: 5}
+	cfg := &config.Config{
+		Routes: map[string]config.Route{
+			"default": {Provider: "anthropic", Model: "claude-3-opus", Shadow: shadow},
+		},
+	}
+	router := New(cfg)
+
+	if decision := router.Route(Request{Model: "claude-3-opus"}, 100); decision.Shadow != shadow {
+		t.Errorf("Expected the route's shadow, got %v", decision.Shadow)
+	}
+	if decision := router.Route(Request{Model: "openai,gpt-4o"}, 100); decision.Shadow != nil {
+		t.Errorf("Expected no shadow for explicit selection, got %v", decision.Shadow)
+	}
+}
+
+func TestRouter_ContentRules(t *testing.T) {
+	cfg := &config.Config{
+		ContentRules: []config.ContentRule{
+			{Name: "translation", Pattern: `(?i)\btranslat(e|ion)\b`, Provider: "mistral", Model: "mistral-large"},
+			{Pattern: `(?i)sql`, Provider: "deepseek", Model: "deepseek-coder"},
+		},
+		Routes: map[string]config.Route{
+			"default": {Provider: "anthropic", Model: "claude-3-opus", Parameters: map[string]interface{}{"temperature": 0.5}},
+			"gpt-4":   {Provider: "openai", Model: "gpt-4"},
+		},
+	}
+
+	router := New(cfg)
+
+	tests := []struct {
+		name         string
+		req          Request
+		wantProvider string
+		wantReason   string
+	}{
+		{"first matching rule wins", Request{Model: "gpt-4", UserText: "Please TRANSLATE this SQL query"}, "mistral", "content rule translation matched"},
+		{"unnamed rule uses index", Request{Model: "gpt-4", UserText: "write sql for me"}, "deepseek", "content rule #1 matched"},
+		{"no match falls back to model routing", Request{Model: "gpt-4", UserText: "hello"}, "openai", "direct model route"},
+		{"explicit selection wins", Request{Model: "groq,llama3", UserText: "translate"}, "groq", "explicit model selection"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := router.Route(tt.req, 100)
+			if decision.Provider != tt.wantProvider || decision.Reason != tt.wantReason {
+				t.Errorf("Expected %s (%s), got %s (%s)", tt.wantProvider, tt.wantReason, decision.Provider, decision.Reason)
+			}
+		})
+	}
+
+	t.Run("UsesDefaultParameters", func(t *testing.T) {
+		decision := router.Route(Request{UserText: "translation please"}, 100)
+		if decision.Parameters["temperature"] != 0.5 {
+			t.Errorf("Expected default route parameters, got %v", decision.Parameters)
+		}
+	})
+}
+
+func TestRouter_HeaderRules(t *testing.T) {
+	cfg := &config.Config{
+		HeaderRules: []config.HeaderRule{
+			{Name: "premium", Header: "x-tenant", Value: "premium", Provider: "anthropic", Model: "claude-3-opus"},
+			{Header: "X-Team", Pattern: `^data-`, Provider: "deepseek", Model: "deepseek-coder"},
+		},
+		ContentRules: []config.ContentRule{
+			{Name: "translation", Pattern: `(?i)translate`, Provider: "mistral", Model: "mistral-large"},
+		},
+		Routes: map[string]config.Route{
+			"default": {Provider: "openai", Model: "gpt-4o-mini", Parameters: map[string]interface{}{"temperature": 0.5}},
+		},
+	}
+
+	router := New(cfg)
+
+	tests := []struct {
+		name         string
+		req          Request
+		wantProvider string
+		wantReason   string
+	}{
+		{"exact value", Request{Model: "gpt-4", Headers: map[string]string{"X-Tenant": "premium"}}, "anthropic", "header rule premium matched"},
+		{"exact value is case-sensitive", Request{Model: "gpt-4", Headers: map[string]string{"X-Tenant": "Premium"}}, "openai", "default model"},
+		{"pattern uses index", Request{Model: "gpt-4", Headers: map[string]string{"X-Team": "data-science"}}, "deepseek", "header rule #1 matched"},
+		{"checked before content rules", Request{Model: "gpt-4", UserText: "translate", Headers: map[string]string{"X-Tenant": "premium"}}, "anthropic", "header rule premium matched"},
+		{"missing header", Request{Model: "gpt-4", UserText: "translate"}, "mistral", "content rule translation matched"},
+		{"explicit selection wins", Request{Model: "groq,llama3", Headers: map[string]string{"X-Tenant": "premium"}}, "groq", "explicit model selection"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := router.Route(tt.req, 100)
+			if decision.Provider != tt.wantProvider || decision.Reason != tt.wantReason {
+				t.Errorf("Expected %s (%s), got %s (%s)", tt.wantProvider, tt.wantReason, decision.Provider, decision.Reason)
+			}
+		})
+	}
+
+	t.Run("UsesDefaultParameters", func(t *testing.T) {
+		decision := router.Route(Request{Headers: map[string]string{"X-Tenant": "premium"}}, 100)
+		if decision.Parameters["temperature"] != 0.5 {
+			t.Errorf("Expected default route parameters, got %v", decision.Parameters)
+		}
+	})
+}
+
+func TestRouter_ForcedRoute(t *testing.T) {
+	cfg := &config.Config{
+		AllowRouteHeader: true,
+		Routes: map[string]config.Route{
+			"default":     {Provider: "openai", Model: "gpt-4o-mini"},
+			"longContext": {Provider: "anthropic", Model: "claude-3-opus", Parameters: map[string]interface{}{"temperature": 0.2}},
+		},
+	}
+	router := New(cfg)
+	forced := func(route string) Request {
+		return Request{Model: "anthropic,claude-3-haiku", Headers: map[string]string{"X-Ccproxy-Route": route}}
+	}
+
+	decision, ok, err := router.ForcedRoute(forced("longcontext"))
+	if err != nil || !ok {
+		t.Fatalf("Expected the route to be forced, got %v, %v", ok, err)
+	}
+	if decision.Provider != "anthropic" || decision.Parameters["temperature"] != 0.2 {
+		t.Errorf("Expected the longContext route with its parameters, got %+v", decision)
+	}
+	if decision.Reason != "route longContext forced by X-CCProxy-Route header" {
+		t.Errorf("Unexpected reason: %s", decision.Reason)
+	}
+
+	if _, ok, err := router.ForcedRoute(Request{Model: "gpt-4"}); ok || err != nil {
+		t.Errorf("Expected no forced route without the header, got %v, %v", ok, err)
+	}
+
+	if _, _, err := router.ForcedRoute(forced("missing")); err == nil || !strings.Contains(err.Error(), `route "missing", which is not configured`) {
+		t.Errorf("Expected an unknown route error, got %v", err)
+	}
+
+	cfg.AllowRouteHeader = false
+	if _, ok, err := router.ForcedRoute(forced("missing")); ok || err != nil {
+		t.Errorf("Expected the header to be ignored when not allowed, got %v, %v", ok, err)
+	}
+}
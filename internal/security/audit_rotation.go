@@ -0,0 +1,150 @@
+package security
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// auditBackupTimeFormat stamps rotated audit logs so they sort by age
+const auditBackupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotateAuditLog rotates the audit log file once it exceeds the configured
+// size or age, then compresses and prunes old backups. It runs on the flush
+// goroutine, and only the rename holds the auditor lock.
+func (a *SecurityAuditor) rotateAuditLog() {
+	fileSink, ok := a.sink.(*fileAuditSink)
+	if !ok || (a.config.AuditMaxSizeMB <= 0 && a.config.AuditMaxAgeDays <= 0) {
+		return
+	}
+
+	a.mu.Lock()
+	backup, err := a.rotateLocked(fileSink)
+	a.mu.Unlock()
+	if err != nil {
+		utils.GetLogger().Errorf("Failed to rotate audit log: %v", err)
+		return
+	}
+	if backup == "" {
+		return
+	}
+	utils.GetLogger().Infof("Rotated audit log to %s", backup)
+
+	if a.config.AuditCompress {
+		if err := compressAuditBackup(backup); err != nil {
+			utils.GetLogger().Errorf("Failed to compress audit log %s: %v", backup, err)
+		}
+	}
+	if a.config.AuditMaxBackups > 0 {
+		pruneAuditBackups(fileSink.path, a.config.AuditMaxBackups)
+	}
+}
+
+// rotateLocked flushes pending entries and rotates the log if it is due,
+// returning the backup path or "" when no rotation was needed (must be
+// called with lock held)
+func (a *SecurityAuditor) rotateLocked(fileSink *fileAuditSink) (string, error) {
+	if !a.rotationDue(fileSink) {
+		return "", nil
+	}
+
+	// Pending entries belong to the file being rotated
+	a.flushLocked()
+
+	backup := auditBackupPath(fileSink.path, time.Now())
+	if err := fileSink.rotate(backup); err != nil {
+		return "", err
+	}
+	a.logFile = fileSink.file
+	return backup, nil
+}
+
+// rotationDue reports whether the log has reached its size or age limit
+func (a *SecurityAuditor) rotationDue(fileSink *fileAuditSink) bool {
+	if a.config.AuditMaxAgeDays > 0 &&
+		time.Since(fileSink.startedAt) >= time.Duration(a.config.AuditMaxAgeDays)*24*time.Hour {
+		return true
+	}
+	if a.config.AuditMaxSizeMB > 0 {
+		info, err := fileSink.file.Stat()
+		if err != nil {
+			utils.GetLogger().Warnf("Failed to stat audit log: %v", err)
+			return false
+		}
+		return info.Size() >= int64(a.config.AuditMaxSizeMB)*1024*1024
+	}
+	return false
+}
+
+// auditBackupPath names a rotated log after the original and the rotation
+// time, for example audit-2024-01-02T15-04-05.000.log
+func auditBackupPath(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	return fmt.Sprintf("%s-%s%s", base, now.Format(auditBackupTimeFormat), ext)
+}
+
+// compressAuditBackup gzips a rotated log and removes the original
+func compressAuditBackup(path string) error {
+	src, err := os.Open(path) // #nosec G304 - Path is a rotated audit log
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = gz.Close()
+		_ = dst.Close()
+		_ = os.Remove(path + ".gz") // Safe to ignore: keep the uncompressed backup
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(path + ".gz") // Safe to ignore: keep the uncompressed backup
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// pruneAuditBackups removes the oldest rotated logs beyond keep
+func pruneAuditBackups(path string, keep int) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	var backups []string
+	for _, pattern := range []string{base + "-*" + ext, base + "-*" + ext + ".gz"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			utils.GetLogger().Errorf("Failed to list audit log backups: %v", err)
+			return
+		}
+		backups = append(backups, matches...)
+	}
+	if len(backups) <= keep {
+		return
+	}
+
+	// Timestamps sort chronologically, so the oldest come first
+	sort.Strings(backups)
+	for _, backup := range backups[:len(backups)-keep] {
+		if err := os.Remove(backup); err != nil {
+			utils.GetLogger().Errorf("Failed to remove old audit log %s: %v", backup, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package security
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+// newRotatingAuditor creates a file auditor in a temp dir with configure applied
+func newRotatingAuditor(t *testing.T, configure func(*SecurityConfig)) (*SecurityAuditor, string) {
+	t.Helper()
+	dir := t.TempDir()
+	config := DefaultSecurityConfig()
+	config.AuditLogPath = filepath.Join(dir, "audit.log")
+	configure(config)
+
+	auditor, err := NewSecurityAuditor(config)
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { _ = auditor.Close() })
+	return auditor, dir
+}
+
+// auditBackups lists the rotated logs in dir
+func auditBackups(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "audit-*"))
+	testutil.AssertNoError(t, err)
+	return matches
+}
+
+func TestAuditLogSizeRotation(t *testing.T) {
+	auditor, dir := newRotatingAuditor(t, func(config *SecurityConfig) {
+		config.AuditMaxSizeMB = 1
+		config.AuditCompress = true
+	})
+	logPath := filepath.Join(dir, "audit.log")
+
+	// Below the limit nothing is rotated
+	auditor.LogSuspiciousActivity("probe", "10.0.0.1", "small entry")
+	auditor.rotateAuditLog()
+	testutil.AssertEqual(t, 0, len(auditBackups(t, dir)))
+
+	// Fill the log past the limit, leaving one entry still buffered
+	padding := strings.Repeat("x", 1024*1024)
+	testutil.AssertNoError(t, os.WriteFile(logPath, []byte(padding), 0600))
+	auditor.LogSuspiciousActivity("probe", "10.0.0.2", "buffered entry")
+	auditor.rotateAuditLog()
+
+	backups := auditBackups(t, dir)
+	testutil.AssertEqual(t, 1, len(backups))
+	testutil.AssertTrue(t, strings.HasSuffix(backups[0], ".log.gz"))
+
+	// The buffered entry was flushed into the rotated file
+	file, err := os.Open(backups[0])
+	testutil.AssertNoError(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	testutil.AssertNoError(t, err)
+	content, err := io.ReadAll(gz)
+	testutil.AssertNoError(t, err)
+	testutil.AssertContains(t, string(content), "buffered entry")
+
+	// New entries go to a fresh log
+	auditor.LogSuspiciousActivity("probe", "10.0.0.3", "after rotation")
+	auditor.flush()
+	current, err := os.ReadFile(logPath)
+	testutil.AssertNoError(t, err)
+	testutil.AssertContains(t, string(current), "after rotation")
+	testutil.AssertFalse(t, strings.Contains(string(current), "buffered entry"))
+}
+
+func TestAuditLogAgeRotation(t *testing.T) {
+	auditor, dir := newRotatingAuditor(t, func(config *SecurityConfig) {
+		config.AuditMaxAgeDays = 1
+	})
+
+	auditor.rotateAuditLog()
+	testutil.AssertEqual(t, 0, len(auditBackups(t, dir)))
+
+	auditor.sink.(*fileAuditSink).startedAt = time.Now().AddDate(0, 0, -2)
+	auditor.rotateAuditLog()
+
+	backups := auditBackups(t, dir)
+	testutil.AssertEqual(t, 1, len(backups))
+	testutil.AssertTrue(t, strings.HasSuffix(backups[0], ".log"))
+}
+
+func TestAuditLogAgeRotationAfterRestart(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		age    time.Duration
+		rotate bool
+	}{
+		{"old log", 48 * time.Hour, true},
+		{"recent log", time.Hour, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := DefaultSecurityConfig()
+			config.AuditLogPath = filepath.Join(dir, "audit.log")
+			config.AuditMaxAgeDays = 1
+
+			// A log left by an earlier run
+			entry := `{"id":"1","timestamp":"` + time.Now().Add(-tt.age).Format(time.RFC3339Nano) + `","type":"auth"}` + "\n"
+			testutil.AssertNoError(t, os.WriteFile(config.AuditLogPath, []byte(entry), 0600))
+
+			auditor, err := NewSecurityAuditor(config)
+			testutil.AssertNoError(t, err)
+			t.Cleanup(func() { _ = auditor.Close() })
+
+			auditor.rotateAuditLog()
+			testutil.AssertEqual(t, tt.rotate, len(auditBackups(t, dir)) == 1)
+		})
+	}
+}
+
+func TestAuditLogBackupPruning(t *testing.T) {
+	auditor, dir := newRotatingAuditor(t, func(config *SecurityConfig) {
+		config.AuditMaxAgeDays = 1
+		config.AuditMaxBackups = 2
+	})
+
+	// Older backups, one of them compressed
+	old := []string{
+		"audit-2020-01-01T00-00-00.000.log.gz",
+		"audit-2020-01-02T00-00-00.000.log",
+		"audit-2020-01-03T00-00-00.000.log",
+	}
+	for _, name := range old {
+		testutil.AssertNoError(t, os.WriteFile(filepath.Join(dir, name), []byte("old"), 0600))
+	}
+
+	auditor.sink.(*fileAuditSink).startedAt = time.Now().AddDate(0, 0, -2)
+	auditor.rotateAuditLog()
+
+	backups := auditBackups(t, dir)
+	testutil.AssertEqual(t, 2, len(backups))
+	testutil.AssertEqual(t, filepath.Join(dir, old[2]), backups[0])
+}
+
+func TestAuditBackupPath(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	testutil.AssertEqual(t, "/var/log/audit-2024-01-02T15-04-05.000.log", auditBackupPath("/var/log/audit.log", now))
+	testutil.AssertEqual(t, "/var/log/audit-2024-01-02T15-04-05.000", auditBackupPath("/var/log/audit", now))
+}
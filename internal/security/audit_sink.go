@@ -0,0 +1,361 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// Audit sink names accepted by SecurityConfig.AuditSink
+const (
+	AuditSinkFile    = "file"
+	AuditSinkStdout  = "stdout"
+	AuditSinkSyslog  = "syslog"
+	AuditSinkWebhook = "webhook"
+)
+
+// Webhook sink defaults
+const (
+	defaultWebhookBatchSize     = 100
+	defaultWebhookFlushInterval = 5 * time.Second
+	defaultWebhookMaxRetries    = 3
+	defaultWebhookQueueSize     = 1000
+	webhookRetryBackoff         = 500 * time.Millisecond
+	webhookCloseTimeout         = 10 * time.Second
+)
+
+// AuditSink receives flushed audit entries
+type AuditSink interface {
+	// Write persists or forwards entries. Implementations must not retain
+	// the slice after returning.
+	Write(entries []AuditEntry) error
+	Close() error
+}
+
+// newAuditSink creates the sink selected by config.AuditSink
+func newAuditSink(config *SecurityConfig) (AuditSink, error) {
+	switch config.AuditSink {
+	case "", AuditSinkFile:
+		sink, err := newFileAuditSink(config.AuditLogPath)
+		if err != nil {
+			return nil, err
+		}
+		return sink, nil
+	case AuditSinkStdout:
+		return newWriterAuditSink(os.Stdout), nil
+	case AuditSinkSyslog:
+		return newSyslogAuditSink(config.AuditSyslogNetwork, config.AuditSyslogAddress, config.AuditSyslogTag)
+	case AuditSinkWebhook:
+		sink, err := newWebhookAuditSink(config)
+		if err != nil {
+			return nil, err
+		}
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink: %s", config.AuditSink)
+	}
+}
+
+// writerAuditSink writes entries as JSON lines to an io.Writer
+type writerAuditSink struct {
+	w io.Writer
+}
+
+// newWriterAuditSink creates a sink writing JSON lines to w
+func newWriterAuditSink(w io.Writer) *writerAuditSink {
+	return &writerAuditSink{w: w}
+}
+
+// Write writes each entry as a JSON line
+func (s *writerAuditSink) Write(entries []AuditEntry) error {
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			utils.GetLogger().Errorf("Failed to marshal audit entry: %v", err)
+			continue
+		}
+
+		if _, err := s.w.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write audit entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close is a no-op, the writer is owned by the caller
+func (s *writerAuditSink) Close() error {
+	return nil
+}
+
+// fileAuditSink appends JSON lines to the audit log file
+type fileAuditSink struct {
+	writerAuditSink
+	file      *os.File
+	path      string
+	startedAt time.Time // When the current file got its first entry, for age-based rotation
+}
+
+// newFileAuditSink opens the audit log at path, creating its directory
+func newFileAuditSink(path string) (*fileAuditSink, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	s := &fileAuditSink{path: path}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open opens the audit log for appending
+func (s *fileAuditSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	s.file = file
+	s.w = file
+	s.startedAt = auditLogStartTime(s.path)
+	return nil
+}
+
+// auditLogStartTime returns the timestamp of the first entry in the audit log
+// at path, so a log reopened after a restart keeps its age. An empty or
+// unreadable log starts now.
+func auditLogStartTime(path string) time.Time {
+	file, err := os.Open(path) // #nosec G304 - Path is the configured audit log
+	if err != nil {
+		return time.Now()
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return time.Now()
+	}
+	var first AuditEntry
+	if json.Unmarshal(line, &first) != nil || first.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return first.Timestamp
+}
+
+// rotate renames the current log to backup and starts a new one
+func (s *fileAuditSink) rotate(backup string) error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	if err := os.Rename(s.path, backup); err != nil {
+		// Keep logging to the current file rather than losing entries
+		if openErr := s.open(); openErr != nil {
+			return fmt.Errorf("failed to rename audit log: %w (reopen failed: %v)", err, openErr)
+		}
+		return fmt.Errorf("failed to rename audit log: %w", err)
+	}
+	return s.open()
+}
+
+// Write appends entries and syncs the file to disk
+func (s *fileAuditSink) Write(entries []AuditEntry) error {
+	if err := s.writerAuditSink.Write(entries); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return nil
+}
+
+// Close closes the audit log file
+func (s *fileAuditSink) Close() error {
+	return s.file.Close()
+}
+
+// webhookAuditSink POSTs batches of entries as a JSON array. Write only
+// queues entries, so request handling never waits on the webhook; a
+// background goroutine batches and retries them.
+type webhookAuditSink struct {
+	url           string
+	headers       map[string]string
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+
+	queue     chan AuditEntry
+	stop      chan struct{}
+	done      chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// newWebhookAuditSink creates a webhook sink and starts its sender
+func newWebhookAuditSink(config *SecurityConfig) (*webhookAuditSink, error) {
+	if config.AuditWebhookURL == "" {
+		return nil, fmt.Errorf("audit_webhook_url is required for the webhook audit sink")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &webhookAuditSink{
+		url:           config.AuditWebhookURL,
+		headers:       config.AuditWebhookHeaders,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		batchSize:     config.AuditWebhookBatchSize,
+		flushInterval: config.AuditWebhookFlushInterval,
+		maxRetries:    config.AuditWebhookMaxRetries,
+		queue:         make(chan AuditEntry, defaultWebhookQueueSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = defaultWebhookBatchSize
+	}
+	if s.flushInterval <= 0 {
+		s.flushInterval = defaultWebhookFlushInterval
+	}
+	if s.maxRetries <= 0 {
+		s.maxRetries = defaultWebhookMaxRetries
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// Write queues entries for the sender, dropping them if the queue is full
+func (s *webhookAuditSink) Write(entries []AuditEntry) error {
+	dropped := 0
+	for _, entry := range entries {
+		select {
+		case s.queue <- entry:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		return fmt.Errorf("audit webhook queue full, dropped %d entries", dropped)
+	}
+	return nil
+}
+
+// Close sends the queued entries and stops the sender. Retries still running
+// after webhookCloseTimeout are abandoned.
+func (s *webhookAuditSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		select {
+		case <-s.done:
+		case <-time.After(webhookCloseTimeout):
+			s.cancel()
+			<-s.done
+		}
+		s.cancel()
+	})
+	return nil
+}
+
+// run batches queued entries by size and interval until the sink is closed
+func (s *webhookAuditSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]AuditEntry, 0, s.batchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.send(batch); err != nil {
+			utils.GetLogger().Errorf("Failed to send %d audit entries to webhook: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-s.queue:
+			batch = append(batch, entry)
+			if len(batch) >= s.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case <-s.stop:
+			for {
+				select {
+				case entry := <-s.queue:
+					batch = append(batch, entry)
+					if len(batch) >= s.batchSize {
+						send()
+					}
+				default:
+					send()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send POSTs a batch, retrying with exponential backoff
+func (s *webhookAuditSink) send(batch []AuditEntry) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit batch: %w", err)
+	}
+
+	backoff := webhookRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = s.post(data)
+		if err == nil || attempt >= s.maxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-s.ctx.Done():
+			return fmt.Errorf("gave up after %d attempts: %w", attempt+1, err)
+		}
+	}
+}
+
+// post sends one request to the webhook
+func (s *webhookAuditSink) post(data []byte) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body) // Safe to ignore: drained for connection reuse
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+//go:build !windows
+// +build !windows
+
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// syslogAuditSink writes entries as JSON messages to syslog
+type syslogAuditSink struct {
+	writer *syslog.Writer
+}
+
+// newSyslogAuditSink connects to syslog. An empty network uses the local
+// syslog daemon.
+func newSyslogAuditSink(network, address, tag string) (AuditSink, error) {
+	if tag == "" {
+		tag = "ccproxy-audit"
+	}
+
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &syslogAuditSink{writer: writer}, nil
+}
+
+// Write sends each entry with a priority matching its severity
+func (s *syslogAuditSink) Write(entries []AuditEntry) error {
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			utils.GetLogger().Errorf("Failed to marshal audit entry: %v", err)
+			continue
+		}
+
+		message := string(data)
+		switch entry.Result {
+		case "critical", "high":
+			err = s.writer.Err(message)
+		case "medium", "failed":
+			err = s.writer.Warning(message)
+		default:
+			err = s.writer.Info(message)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write audit entry to syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the syslog connection
+func (s *syslogAuditSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package security
+
+import "fmt"
+
+// newSyslogAuditSink fails, syslog is not available on Windows
+func newSyslogAuditSink(network, address, tag string) (AuditSink, error) {
+	return nil, fmt.Errorf("the syslog audit sink is not supported on windows")
+}
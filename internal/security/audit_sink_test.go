@@ -0,0 +1,188 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestNewAuditSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SecurityConfig
+		wantErr string
+	}{
+		{
+			name:   "default is file",
+			config: SecurityConfig{AuditLogPath: filepath.Join(t.TempDir(), "audit.log")},
+		},
+		{
+			name:   "stdout",
+			config: SecurityConfig{AuditSink: AuditSinkStdout},
+		},
+		{
+			name:    "webhook without url",
+			config:  SecurityConfig{AuditSink: AuditSinkWebhook},
+			wantErr: "audit_webhook_url is required",
+		},
+		{
+			name:    "unknown sink",
+			config:  SecurityConfig{AuditSink: "kafka"},
+			wantErr: "unknown audit sink: kafka",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, err := newAuditSink(&tt.config)
+			if tt.wantErr != "" {
+				testutil.AssertError(t, err)
+				testutil.AssertContains(t, err.Error(), tt.wantErr)
+				return
+			}
+			testutil.AssertNoError(t, err)
+			testutil.AssertNoError(t, sink.Close())
+		})
+	}
+}
+
+func TestWriterAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := newWriterAuditSink(&buf)
+
+	err := sink.Write([]AuditEntry{
+		{ID: "1", Type: "access_attempt"},
+		{ID: "2", Type: "security_event"},
+	})
+	testutil.AssertNoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	testutil.AssertEqual(t, 2, len(lines))
+
+	var entry AuditEntry
+	testutil.AssertNoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	testutil.AssertEqual(t, "2", entry.ID)
+	testutil.AssertEqual(t, "security_event", entry.Type)
+}
+
+func TestAuditorStdoutSink(t *testing.T) {
+	config := DefaultSecurityConfig()
+	config.AuditSink = AuditSinkStdout
+
+	auditor, err := NewSecurityAuditor(config)
+	testutil.AssertNoError(t, err)
+	_, ok := auditor.sink.(*writerAuditSink)
+	testutil.AssertTrue(t, ok, "expected the stdout sink")
+	testutil.AssertEqual(t, (*os.File)(nil), auditor.logFile)
+	testutil.AssertNotEqual(t, nil, auditor.flushTicker)
+	testutil.AssertNoError(t, auditor.Close())
+}
+
+// webhookRecorder records the batches a test webhook receives
+type webhookRecorder struct {
+	mu       sync.Mutex
+	batches  [][]AuditEntry
+	failures int // Requests to fail before accepting
+	requests int
+	headers  http.Header
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests++
+	r.headers = req.Header.Clone()
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var batch []AuditEntry
+	if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.batches = append(r.batches, batch)
+}
+
+func (r *webhookRecorder) snapshot() (batches [][]AuditEntry, requests int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]AuditEntry(nil), r.batches...), r.requests
+}
+
+func TestWebhookAuditSink(t *testing.T) {
+	newSink := func(t *testing.T, recorder *webhookRecorder, batchSize int) *webhookAuditSink {
+		t.Helper()
+		server := httptest.NewServer(recorder)
+		t.Cleanup(server.Close)
+
+		sink, err := newWebhookAuditSink(&SecurityConfig{
+			AuditWebhookURL:           server.URL,
+			AuditWebhookHeaders:       map[string]string{"Authorization": "Bearer token"},
+			AuditWebhookBatchSize:     batchSize,
+			AuditWebhookFlushInterval: time.Hour,
+			AuditWebhookMaxRetries:    3,
+		})
+		testutil.AssertNoError(t, err)
+		return sink
+	}
+
+	t.Run("batches by size", func(t *testing.T) {
+		recorder := &webhookRecorder{}
+		sink := newSink(t, recorder, 2)
+
+		testutil.AssertNoError(t, sink.Write([]AuditEntry{{ID: "1"}, {ID: "2"}, {ID: "3"}}))
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if batches, _ := recorder.snapshot(); len(batches) == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Full batch was never sent")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		// Close sends the partial batch
+		testutil.AssertNoError(t, sink.Close())
+		batches, _ := recorder.snapshot()
+		testutil.AssertEqual(t, 2, len(batches))
+		testutil.AssertEqual(t, 2, len(batches[0]))
+		testutil.AssertEqual(t, "3", batches[1][0].ID)
+		testutil.AssertEqual(t, "Bearer token", recorder.headers.Get("Authorization"))
+	})
+
+	t.Run("retries failed requests", func(t *testing.T) {
+		recorder := &webhookRecorder{failures: 1}
+		sink := newSink(t, recorder, 10)
+
+		testutil.AssertNoError(t, sink.Write([]AuditEntry{{ID: "1"}}))
+		testutil.AssertNoError(t, sink.Close())
+
+		batches, requests := recorder.snapshot()
+		testutil.AssertEqual(t, 2, requests)
+		testutil.AssertEqual(t, 1, len(batches))
+		testutil.AssertEqual(t, "1", batches[0][0].ID)
+	})
+
+	t.Run("write does not block when queue is full", func(t *testing.T) {
+		sink := &webhookAuditSink{queue: make(chan AuditEntry, 1)}
+
+		err := sink.Write([]AuditEntry{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "dropped 2 entries")
+	})
+}
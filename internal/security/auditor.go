@@ -1,7 +1,6 @@
 package security
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -15,7 +14,8 @@ import (
 // SecurityAuditor handles security audit logging
 type SecurityAuditor struct {
 	config      *SecurityConfig
-	logFile     *os.File
+	sink        AuditSink
+	logFile     *os.File // Set when the sink is the audit log file
 	mu          sync.Mutex
 	buffer      []AuditEntry
 	bufferSize  int
@@ -38,18 +38,14 @@ func NewSecurityAuditor(config *SecurityConfig) (*SecurityAuditor, error) {
 	}
 
 	if config.EnableAuditLog {
-		// Create audit log directory if needed
-		dir := filepath.Dir(config.AuditLogPath)
-		if err := os.MkdirAll(dir, 0750); err != nil {
-			return nil, fmt.Errorf("failed to create audit log directory: %w", err)
-		}
-
-		// Open audit log file
-		file, err := os.OpenFile(config.AuditLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		sink, err := newAuditSink(config)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open audit log: %w", err)
+			return nil, err
+		}
+		auditor.sink = sink
+		if fileSink, ok := sink.(*fileAuditSink); ok {
+			auditor.logFile = fileSink.file
 		}
-		auditor.logFile = file
 
 		// Start flush ticker
 		auditor.flushTicker = time.NewTicker(5 * time.Second)
@@ -72,8 +68,8 @@ func (a *SecurityAuditor) Close() error {
 		// Flush remaining entries
 		a.flush()
 
-		if a.logFile != nil {
-			err = a.logFile.Close()
+		if a.sink != nil {
+			err = a.sink.Close()
 		}
 	})
 	return err
@@ -226,47 +222,34 @@ func (a *SecurityAuditor) addEntry(entry AuditEntry) {
 	}
 }
 
-// flush writes buffered entries to disk
+// flush writes buffered entries to the sink
 func (a *SecurityAuditor) flush() {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	a.flushLocked()
 }
 
-// flushLocked writes buffered entries to disk (must be called with lock held)
+// flushLocked writes buffered entries to the sink (must be called with lock held)
 func (a *SecurityAuditor) flushLocked() {
-	if len(a.buffer) == 0 || a.logFile == nil {
+	if len(a.buffer) == 0 || a.sink == nil {
 		return
 	}
 
-	// Write each entry as JSON line
-	for _, entry := range a.buffer {
-		data, err := json.Marshal(entry)
-		if err != nil {
-			utils.GetLogger().Errorf("Failed to marshal audit entry: %v", err)
-			continue
-		}
-
-		if _, err := a.logFile.Write(append(data, '\n')); err != nil {
-			utils.GetLogger().Errorf("Failed to write audit entry: %v", err)
-		}
-	}
-
-	// Sync to disk
-	if err := a.logFile.Sync(); err != nil {
-		utils.GetLogger().Errorf("Failed to sync audit log: %v", err)
+	if err := a.sink.Write(a.buffer); err != nil {
+		utils.GetLogger().Errorf("Failed to write audit entries: %v", err)
 	}
 
 	// Clear buffer
 	a.buffer = a.buffer[:0]
 }
 
-// periodicFlush periodically flushes the buffer
+// periodicFlush periodically flushes the buffer and rotates the audit log
 func (a *SecurityAuditor) periodicFlush() {
 	for {
 		select {
 		case <-a.flushTicker.C:
 			a.flush()
+			a.rotateAuditLog()
 		case <-a.done:
 			return
 		}
@@ -9,6 +9,20 @@ import (
 	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
 )
 
+// chdirTempDir moves the test into a temporary directory, so the default
+// relative audit log path does not write into the package directory
+func chdirTempDir(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("Failed to restore working directory: %v", err)
+		}
+	})
+}
+
 func TestNewSecurityAuditor(t *testing.T) {
 	testConfig := testutil.SetupTest(t)
 	defer func() {
@@ -45,6 +59,8 @@ func TestNewSecurityAuditor(t *testing.T) {
 	})
 
 	t.Run("with nil config", func(t *testing.T) {
+		chdirTempDir(t)
+
 		auditor, err := NewSecurityAuditor(nil)
 		testutil.AssertNoError(t, err)
 		testutil.AssertNotEqual(t, nil, auditor)
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/orchestre-dev/ccproxy/internal/errors"
 	"github.com/orchestre-dev/ccproxy/internal/utils"
@@ -28,7 +29,7 @@ type Manager struct {
 	ipMu        sync.RWMutex
 
 	// Rate limiting
-	rateLimiter *IPRateLimiter
+	rateLimiter RateLimiter
 
 	// API key management
 	apiKeys    map[string]APIKeyInfo
@@ -51,6 +52,9 @@ type APIKeyInfo struct {
 	Permissions []string
 	RateLimit   int
 	Active      bool
+
+	// AllowedModels restricts which target models the key may use, empty allows all
+	AllowedModels []string
 }
 
 // NewManager creates a new security manager
@@ -94,7 +98,11 @@ func NewManager(config *SecurityConfig) (*Manager, error) {
 
 	// Initialize rate limiter
 	if config.EnableRateLimiting {
-		manager.rateLimiter = NewIPRateLimiter(100, time.Minute) // 100 requests per minute default
+		limit := config.RateLimitPerMinute
+		if limit <= 0 {
+			limit = 100 // 100 requests per minute default
+		}
+		manager.rateLimiter = NewRateLimiter(config, limit, time.Minute)
 	}
 
 	// Start API key rotation if enabled
@@ -112,10 +120,22 @@ func (m *Manager) Close() error {
 	if m.keyRotator != nil {
 		m.keyRotator.Stop()
 	}
+	if limiter, ok := m.rateLimiter.(interface{ Stop() }); ok {
+		limiter.Stop()
+	}
 
 	return m.auditor.Close()
 }
 
+// RateLimitMiddleware returns the middleware enforcing the configured rate
+// limit with the configured message, or nil when rate limiting is disabled
+func (m *Manager) RateLimitMiddleware() gin.HandlerFunc {
+	if m.rateLimiter == nil {
+		return nil
+	}
+	return RateLimitMiddleware(m.rateLimiter, m.config.RateLimitMessage)
+}
+
 // ValidateRequest validates an incoming HTTP request
 func (m *Manager) ValidateRequest(req *http.Request) error {
 	if req == nil {
@@ -135,7 +155,7 @@ func (m *Manager) ValidateRequest(req *http.Request) error {
 
 	// Check rate limiting
 	if m.config.EnableRateLimiting {
-		if !m.rateLimiter.Allow(clientIP) {
+		if !m.rateLimiter.Allow(m.rateLimiter.ClientKey(req, clientIP)) {
 			m.recordBlockedRequest(req, "rate_limit", "rate limit exceeded")
 			return errors.NewRateLimitError("rate limit exceeded", nil)
 		}
@@ -171,6 +191,46 @@ func (m *Manager) ValidateRequest(req *http.Request) error {
 	return nil
 }
 
+// CheckPromptInjection scans a request body for prompt injection patterns.
+// Matches are audit-logged, and blocked reports whether the request must be
+// rejected rather than only flagged.
+func (m *Manager) CheckPromptInjection(req *http.Request, body map[string]interface{}) (matches []PromptInjectionMatch, blocked bool) {
+	if !m.config.EnablePromptInjectionFilter {
+		return nil, false
+	}
+
+	matches = m.validator.DetectPromptInjection(body)
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	blocked = m.config.BlockPromptInjection
+	if blocked {
+		m.mu.Lock()
+		m.blockedCount++
+		m.mu.Unlock()
+	}
+
+	patterns := make([]string, len(matches))
+	for i, match := range matches {
+		patterns[i] = match.Pattern
+	}
+	m.auditor.LogSecurityEvent(SecurityEvent{
+		ID:          uuid.New().String(),
+		Type:        "prompt_injection",
+		Severity:    highestSeverity(matches),
+		Timestamp:   time.Now(),
+		Source:      m.getClientIP(req),
+		Description: fmt.Sprintf("prompt injection patterns matched in %s %s", req.Method, req.URL.Path),
+		Data: map[string]interface{}{
+			"patterns": patterns,
+			"blocked":  blocked,
+		},
+	})
+
+	return matches, blocked
+}
+
 // ValidateResponse validates an outgoing response
 func (m *Manager) ValidateResponse(resp interface{}) error {
 	result := m.validator.ValidateResponse(resp)
@@ -221,8 +281,9 @@ func (m *Manager) ValidateAPIKey(key string) error {
 	return nil
 }
 
-// GenerateAPIKey generates a new API key
-func (m *Manager) GenerateAPIKey(permissions []string, rateLimit int) (string, error) {
+// GenerateAPIKey generates a new API key. A non-empty allowedModels restricts
+// the key to those target models.
+func (m *Manager) GenerateAPIKey(permissions []string, rateLimit int, allowedModels []string) (string, error) {
 	// Generate random key
 	key := uuid.New().String()
 	hash := m.hashAPIKey(key)
@@ -235,6 +296,8 @@ func (m *Manager) GenerateAPIKey(permissions []string, rateLimit int) (string, e
 		Permissions: permissions,
 		RateLimit:   rateLimit,
 		Active:      true,
+
+		AllowedModels: allowedModels,
 	}
 
 	m.keyMu.Lock()
@@ -250,15 +313,78 @@ func (m *Manager) GenerateAPIKey(permissions []string, rateLimit int) (string, e
 		Source:      "security_manager",
 		Description: "New API key generated",
 		Data: map[string]interface{}{
-			"key_hash":    hash,
-			"permissions": permissions,
-			"rate_limit":  rateLimit,
+			"key_hash":       hash,
+			"permissions":    permissions,
+			"rate_limit":     rateLimit,
+			"allowed_models": allowedModels,
 		},
 	})
 
 	return key, nil
 }
 
+// AddAPIKey registers a key issued outside the manager, such as one from the
+// server configuration. A non-empty allowedModels restricts the key to those
+// target models.
+func (m *Manager) AddAPIKey(key string, allowedModels []string) {
+	m.keyMu.Lock()
+	defer m.keyMu.Unlock()
+
+	m.apiKeys[key] = APIKeyInfo{
+		Key:     key,
+		Hash:    HashAPIKey(key),
+		Created: time.Now(),
+		Active:  true,
+
+		AllowedModels: allowedModels,
+	}
+}
+
+// CheckModelAccess verifies that the API key with the given hash may use the
+// target model. Denials are recorded in the audit log.
+func (m *Manager) CheckModelAccess(keyHash, model string) error {
+	m.keyMu.RLock()
+	var allowedModels []string
+	found := false
+	for _, info := range m.apiKeys {
+		if info.Hash == keyHash {
+			allowedModels = info.AllowedModels
+			found = true
+			break
+		}
+	}
+	m.keyMu.RUnlock()
+
+	if !found {
+		return errors.NewAuthError("invalid API key", nil)
+	}
+
+	if len(allowedModels) == 0 {
+		return nil
+	}
+	for _, allowed := range allowedModels {
+		if allowed == model {
+			return nil
+		}
+	}
+
+	m.auditor.LogSecurityEvent(SecurityEvent{
+		ID:          uuid.New().String(),
+		Type:        "model_access_denied",
+		Severity:    "medium",
+		Timestamp:   time.Now(),
+		Source:      "security_manager",
+		Description: fmt.Sprintf("API key not permitted to use model %s", model),
+		Data: map[string]interface{}{
+			"key_hash":       keyHash,
+			"model":          model,
+			"allowed_models": allowedModels,
+		},
+	})
+
+	return errors.NewForbiddenError(fmt.Sprintf("model %s is not allowed for this API key", model), nil)
+}
+
 // RevokeAPIKey revokes an API key
 func (m *Manager) RevokeAPIKey(key string) error {
 	m.keyMu.Lock()
@@ -372,6 +498,12 @@ func (m *Manager) logAccessAttempt(req *http.Request, success bool, reason strin
 }
 
 func (m *Manager) hashAPIKey(key string) string {
+	return HashAPIKey(key)
+}
+
+// HashAPIKey returns the digest that identifies an API key in the audit log
+// and in model access checks
+func HashAPIKey(key string) string {
 	hash := sha256.Sum256([]byte(key))
 	return hex.EncodeToString(hash[:])
 }
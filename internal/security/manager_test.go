@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/orchestre-dev/ccproxy/internal/errors"
 	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
 )
 
@@ -18,6 +19,8 @@ func TestNewManager(t *testing.T) {
 	}()
 
 	t.Run("with default config", func(t *testing.T) {
+		chdirTempDir(t)
+
 		manager, err := NewManager(nil)
 		testutil.AssertNoError(t, err)
 		testutil.AssertNotEqual(t, nil, manager)
@@ -168,7 +171,7 @@ func TestManagerAPIKeyManagement(t *testing.T) {
 		permissions := []string{"read", "write"}
 		rateLimit := 100
 
-		key, err := manager.GenerateAPIKey(permissions, rateLimit)
+		key, err := manager.GenerateAPIKey(permissions, rateLimit, nil)
 		testutil.AssertNoError(t, err)
 		testutil.AssertNotEqual(t, "", key)
 		testutil.AssertTrue(t, len(key) > 10)
@@ -185,7 +188,7 @@ func TestManagerAPIKeyManagement(t *testing.T) {
 	})
 
 	t.Run("revoke API key", func(t *testing.T) {
-		key, err := manager.GenerateAPIKey([]string{"read"}, 50)
+		key, err := manager.GenerateAPIKey([]string{"read"}, 50, nil)
 		testutil.AssertNoError(t, err)
 
 		// Key should be valid initially
@@ -207,6 +210,48 @@ func TestManagerAPIKeyManagement(t *testing.T) {
 		testutil.AssertError(t, err)
 		testutil.AssertContains(t, err.Error(), "not found", "Error should mention key not found")
 	})
+
+	t.Run("allowed models", func(t *testing.T) {
+		key, err := manager.GenerateAPIKey([]string{"read"}, 100, []string{"claude-3-haiku", "gpt-4o-mini"})
+		testutil.AssertNoError(t, err)
+		hash := manager.hashAPIKey(key)
+
+		testutil.AssertNoError(t, manager.CheckModelAccess(hash, "gpt-4o-mini"))
+
+		err = manager.CheckModelAccess(hash, "claude-3-opus")
+		testutil.AssertError(t, err)
+		ccErr, ok := err.(*errors.CCProxyError)
+		testutil.AssertTrue(t, ok && ccErr.Type == errors.ErrorTypeForbidden, "Error should be forbidden")
+		testutil.AssertContains(t, err.Error(), "claude-3-opus", "Error should name the model")
+
+		// The denial is recorded by the auditor
+		trail := manager.auditor.GetAuditTrail(AuditFilter{Action: "model_access_denied"})
+		testutil.AssertEqual(t, 1, len(trail))
+		testutil.AssertEqual(t, "claude-3-opus", trail[0].Details["model"])
+		testutil.AssertEqual(t, hash, trail[0].Details["key_hash"])
+	})
+
+	t.Run("unrestricted key", func(t *testing.T) {
+		key, err := manager.GenerateAPIKey([]string{"read"}, 100, nil)
+		testutil.AssertNoError(t, err)
+
+		testutil.AssertNoError(t, manager.CheckModelAccess(manager.hashAPIKey(key), "claude-3-opus"))
+	})
+
+	t.Run("added key", func(t *testing.T) {
+		manager.AddAPIKey("configured-key", []string{"gpt-4o-mini"})
+		hash := HashAPIKey("configured-key")
+
+		testutil.AssertNoError(t, manager.ValidateAPIKey("configured-key"))
+		testutil.AssertNoError(t, manager.CheckModelAccess(hash, "gpt-4o-mini"))
+		testutil.AssertError(t, manager.CheckModelAccess(hash, "claude-3-opus"))
+	})
+
+	t.Run("unknown key hash", func(t *testing.T) {
+		err := manager.CheckModelAccess("unknown-hash", "gpt-4o-mini")
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "invalid API key", "Error should mention invalid key")
+	})
 }
 
 func TestManagerIPManagement(t *testing.T) {
@@ -454,7 +499,7 @@ func TestManagerWithAuth(t *testing.T) {
 	defer manager.Close()
 
 	// Generate a valid API key
-	apiKey, err := manager.GenerateAPIKey([]string{"read"}, 100)
+	apiKey, err := manager.GenerateAPIKey([]string{"read"}, 100, nil)
 	testutil.AssertNoError(t, err)
 
 	t.Run("request with valid API key", func(t *testing.T) {
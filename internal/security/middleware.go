@@ -1,7 +1,11 @@
 package security
 
 import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -77,26 +81,112 @@ func AuthMiddleware(manager *Manager) gin.HandlerFunc {
 	}
 }
 
-// RateLimitMiddleware provides rate limiting middleware
-func RateLimitMiddleware(limiter *IPRateLimiter) gin.HandlerFunc {
+// PromptInjectionMiddleware scans JSON request bodies for prompt injection
+// patterns. Matching requests are passed to reject in blocking mode, and
+// otherwise continue with PromptInjectionHeader set on the response. A nil
+// reject answers with 400 in the Anthropic and OpenAI error shape. Bodies
+// that cannot be read, such as those over a http.MaxBytesReader limit, are
+// passed to readError, which answers with 413 or 400 in the same shape when
+// nil.
+func PromptInjectionMiddleware(manager *Manager, reject gin.HandlerFunc, readError func(*gin.Context, error)) gin.HandlerFunc {
+	if readError == nil {
+		readError = func(c *gin.Context, err error) {
+			status := http.StatusBadRequest
+			var maxBytesErr *http.MaxBytesError
+			if stderrors.As(err, &maxBytesErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.JSON(status, gin.H{
+				"type": "error",
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+		}
+	}
+	if reject == nil {
+		reject = func(c *gin.Context) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"type": "error",
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": "Request blocked: prompt injection detected",
+					"code":    "prompt_injection_detected",
+				},
+			})
+		}
+	}
+
+	return func(c *gin.Context) {
+		if !manager.config.EnablePromptInjectionFilter || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		// Read the body and restore it for the handlers
+		data, err := io.ReadAll(c.Request.Body)
+		_ = c.Request.Body.Close() // Safe to ignore: body is fully buffered
+		if err != nil {
+			readError(c, err)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			c.Next()
+			return
+		}
+
+		matches, blocked := manager.CheckPromptInjection(c.Request, body)
+		if blocked {
+			reject(c)
+			c.Abort()
+			return
+		}
+		if len(matches) > 0 {
+			c.Header(PromptInjectionHeader, highestSeverity(matches))
+		}
+
+		c.Next()
+	}
+}
+
+// RateLimitMiddleware provides rate limiting middleware. Refused requests get
+// a 429 in the Anthropic and OpenAI error shape with a Retry-After header, so
+// SDK clients wait and retry. An empty message uses DefaultRateLimitMessage.
+func RateLimitMiddleware(limiter RateLimiter, message string) gin.HandlerFunc {
+	if message == "" {
+		message = DefaultRateLimitMessage
+	}
+
 	return func(c *gin.Context) {
 		if limiter == nil {
 			c.Next()
 			return
 		}
 
-		ip := getClientIP(c)
-		if !limiter.Allow(ip) {
-			info := limiter.GetLimit(ip)
+		// Key on the peer address, which unlike forwarding headers clients
+		// cannot choose
+		key := limiter.ClientKey(c.Request, c.RemoteIP())
+		if !limiter.Allow(key) {
+			info := limiter.GetLimit(key)
 
 			// Set rate limit headers
 			c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", info.Limit))
 			c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", info.Remaining))
 			c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", info.Reset.Unix()))
+			c.Header("Retry-After", fmt.Sprintf("%d", retryAfterSeconds(info.RetryAfter)))
 
 			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":       "rate limit exceeded",
-				"retry_after": info.Reset.Unix(),
+				"type": "error",
+				"error": gin.H{
+					"type":    "rate_limit_error",
+					"message": message,
+					"code":    "rate_limit_exceeded",
+				},
 			})
 			c.Abort()
 			return
@@ -106,23 +196,49 @@ func RateLimitMiddleware(limiter *IPRateLimiter) gin.HandlerFunc {
 	}
 }
 
-// CORSMiddleware provides CORS security middleware
+// retryAfterSeconds rounds a wait up to whole seconds for Retry-After. A
+// refused request always waits at least a second.
+func retryAfterSeconds(wait time.Duration) int64 {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// CORSMiddleware provides CORS security middleware. Requests from origins
+// outside allowedOrigins get no CORS headers, so browsers block them; "*"
+// allows every origin.
 func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.GetHeader("Origin")
 
-		// Check if origin is allowed
-		allowed := false
+		// Responses differ by origin, so caches must key on it
+		c.Writer.Header().Add("Vary", "Origin")
+
+		// Check if origin is allowed. Same-origin and non-browser requests
+		// send no Origin and need no CORS headers.
+		allowOrigin := ""
 		for _, allowedOrigin := range allowedOrigins {
-			if allowedOrigin == "*" || allowedOrigin == origin {
-				allowed = true
+			if origin == "" {
+				break
+			}
+			if allowedOrigin == origin {
+				allowOrigin = origin
 				break
 			}
+			if allowedOrigin == "*" {
+				allowOrigin = "*"
+			}
 		}
 
-		if allowed {
-			c.Header("Access-Control-Allow-Origin", origin)
-			c.Header("Access-Control-Allow-Credentials", "true")
+		if allowOrigin != "" {
+			c.Header("Access-Control-Allow-Origin", allowOrigin)
+			// Credentials are only for origins listed by name; browsers
+			// reject them alongside a wildcard anyway.
+			if allowOrigin != "*" {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
 			c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 			c.Header("Access-Control-Max-Age", "86400")
@@ -1,6 +1,8 @@
 package security
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -193,7 +195,7 @@ func TestAuthMiddleware(t *testing.T) {
 		testutil.AssertNoError(t, err)
 		defer manager.Close()
 
-		apiKey, err := manager.GenerateAPIKey([]string{"read"}, 100)
+		apiKey, err := manager.GenerateAPIKey([]string{"read"}, 100, nil)
 		testutil.AssertNoError(t, err)
 
 		router := gin.New()
@@ -219,7 +221,7 @@ func TestAuthMiddleware(t *testing.T) {
 		testutil.AssertNoError(t, err)
 		defer manager.Close()
 
-		apiKey, err := manager.GenerateAPIKey([]string{"read"}, 100)
+		apiKey, err := manager.GenerateAPIKey([]string{"read"}, 100, nil)
 		testutil.AssertNoError(t, err)
 
 		router := gin.New()
@@ -297,7 +299,7 @@ func TestRateLimitMiddleware(t *testing.T) {
 
 	t.Run("no rate limiter", func(t *testing.T) {
 		router := gin.New()
-		router.Use(RateLimitMiddleware(nil))
+		router.Use(RateLimitMiddleware(nil, ""))
 		router.GET("/test", func(c *gin.Context) {
 			c.JSON(200, gin.H{"status": "ok"})
 		})
@@ -314,7 +316,7 @@ func TestRateLimitMiddleware(t *testing.T) {
 		defer limiter.Stop()
 
 		router := gin.New()
-		router.Use(RateLimitMiddleware(limiter))
+		router.Use(RateLimitMiddleware(limiter, ""))
 		router.GET("/test", func(c *gin.Context) {
 			c.JSON(200, gin.H{"status": "ok"})
 		})
@@ -331,7 +333,7 @@ func TestRateLimitMiddleware(t *testing.T) {
 		defer limiter.Stop()
 
 		router := gin.New()
-		router.Use(RateLimitMiddleware(limiter))
+		router.Use(RateLimitMiddleware(limiter, ""))
 		router.GET("/test", func(c *gin.Context) {
 			c.JSON(200, gin.H{"status": "ok"})
 		})
@@ -355,6 +357,114 @@ func TestRateLimitMiddleware(t *testing.T) {
 		testutil.AssertNotEqual(t, "", w2.Header().Get("X-RateLimit-Remaining"))
 		testutil.AssertNotEqual(t, "", w2.Header().Get("X-RateLimit-Reset"))
 	})
+
+	t.Run("keyed by api key", func(t *testing.T) {
+		limiter := NewKeyRateLimiter(1, time.Minute)
+		defer limiter.Stop()
+
+		router := gin.New()
+		router.Use(RateLimitMiddleware(limiter, ""))
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(200, gin.H{"status": "ok"})
+		})
+
+		send := func(setAuth func(req *http.Request)) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.RemoteAddr = "192.168.1.1:12345"
+			setAuth(req)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		keyA := func(req *http.Request) { req.Header.Set("Authorization", "Bearer key-a") }
+		keyB := func(req *http.Request) { req.Header.Set("X-API-Key", "key-b") }
+		anonymous := func(req *http.Request) {}
+
+		// Each key from the same IP gets its own bucket
+		testutil.AssertEqual(t, 200, send(keyA).Code)
+		testutil.AssertEqual(t, 200, send(keyB).Code)
+		testutil.AssertEqual(t, 200, send(anonymous).Code)
+
+		w := send(keyA)
+		testutil.AssertEqual(t, 429, w.Code)
+		testutil.AssertEqual(t, "1", w.Header().Get("X-RateLimit-Limit"))
+		testutil.AssertEqual(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+		testutil.AssertNotEqual(t, "", w.Header().Get("X-RateLimit-Reset"))
+
+		testutil.AssertEqual(t, 429, send(keyB).Code)
+		testutil.AssertEqual(t, 429, send(anonymous).Code)
+	})
+
+	t.Run("retry after token bucket refill", func(t *testing.T) {
+		limiter := NewKeyRateLimiter(3, 3*time.Second)
+		defer limiter.Stop()
+
+		router := gin.New()
+		router.Use(RateLimitMiddleware(limiter, "slow down"))
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(200, gin.H{"status": "ok"})
+		})
+
+		// An empty bucket last refilled 200ms ago gets its next token in 800ms
+		lastRefill := time.Now().Add(-200 * time.Millisecond)
+		limiter.buckets["ip:192.168.1.1"] = &tokenBucket{tokens: 0, lastRefill: lastRefill}
+
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		testutil.AssertEqual(t, 429, w.Code)
+		testutil.AssertEqual(t, "1", w.Header().Get("Retry-After"))
+		testutil.AssertEqual(t, fmt.Sprintf("%d", lastRefill.Add(3*time.Second).Unix()), w.Header().Get("X-RateLimit-Reset"))
+
+		var body struct {
+			Type  string `json:"type"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+				Code    string `json:"code"`
+			} `json:"error"`
+		}
+		testutil.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		testutil.AssertEqual(t, "error", body.Type)
+		testutil.AssertEqual(t, "rate_limit_error", body.Error.Type)
+		testutil.AssertEqual(t, "slow down", body.Error.Message)
+		testutil.AssertEqual(t, "rate_limit_exceeded", body.Error.Code)
+	})
+
+	t.Run("retry after window reset", func(t *testing.T) {
+		limiter := NewIPRateLimiter(1, time.Minute)
+		defer limiter.Stop()
+
+		router := gin.New()
+		router.Use(RateLimitMiddleware(limiter, ""))
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(200, gin.H{"status": "ok"})
+		})
+
+		send := func() *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.RemoteAddr = "192.168.1.1:12345"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		testutil.AssertEqual(t, 200, send().Code)
+		w := send()
+
+		testutil.AssertEqual(t, 429, w.Code)
+		testutil.AssertEqual(t, "60", w.Header().Get("Retry-After"))
+		testutil.AssertContains(t, w.Body.String(), DefaultRateLimitMessage)
+	})
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	testutil.AssertEqual(t, int64(1), retryAfterSeconds(0))
+	testutil.AssertEqual(t, int64(1), retryAfterSeconds(-time.Second))
+	testutil.AssertEqual(t, int64(1), retryAfterSeconds(time.Millisecond))
+	testutil.AssertEqual(t, int64(1), retryAfterSeconds(time.Second))
+	testutil.AssertEqual(t, int64(2), retryAfterSeconds(1001*time.Millisecond))
 }
 
 func TestCORSMiddleware(t *testing.T) {
@@ -401,7 +511,24 @@ func TestCORSMiddleware(t *testing.T) {
 		router.ServeHTTP(w, req)
 
 		testutil.AssertEqual(t, 200, w.Code)
-		testutil.AssertEqual(t, "https://any-domain.com", w.Header().Get("Access-Control-Allow-Origin"))
+		testutil.AssertEqual(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		testutil.AssertEqual(t, "", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("listed origin alongside wildcard", func(t *testing.T) {
+		router := gin.New()
+		router.Use(CORSMiddleware([]string{"*", "https://example.com"}))
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(200, gin.H{"status": "ok"})
+		})
+
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Origin", "https://example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		testutil.AssertEqual(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		testutil.AssertEqual(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
 	})
 
 	t.Run("disallowed origin", func(t *testing.T) {
@@ -0,0 +1,147 @@
+package security
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PromptInjectionHeader flags responses to requests that matched prompt
+// injection patterns but were let through in warn-only mode
+const PromptInjectionHeader = "X-CCProxy-Prompt-Injection"
+
+// Prompt injection severities, lowest first
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// severityRank orders severities so a threshold can be compared
+var severityRank = map[string]int{
+	SeverityLow:    1,
+	SeverityMedium: 2,
+	SeverityHigh:   3,
+}
+
+// PromptInjectionPattern is a regular expression for a known prompt
+// injection or jailbreak phrase. Patterns match case-insensitively.
+type PromptInjectionPattern struct {
+	Pattern  string `json:"pattern"`
+	Severity string `json:"severity"` // "low", "medium" or "high"
+}
+
+// PromptInjectionMatch is a pattern found in a request
+type PromptInjectionMatch struct {
+	Pattern  string `json:"pattern"`
+	Severity string `json:"severity"`
+}
+
+// DefaultPromptInjectionPatterns are used when no patterns are configured
+func DefaultPromptInjectionPatterns() []PromptInjectionPattern {
+	return []PromptInjectionPattern{
+		{Pattern: `ignore\s+(all\s+)?(the\s+)?(previous|prior|above)\s+(instructions|prompts|rules)`, Severity: SeverityHigh},
+		{Pattern: `disregard\s+(all\s+)?(your|the|previous|prior)\s+(instructions|rules|guidelines)`, Severity: SeverityHigh},
+		{Pattern: `(reveal|print|repeat)\s+(your|the)\s+(system\s+prompt|hidden\s+instructions)`, Severity: SeverityHigh},
+		{Pattern: `you\s+are\s+now\s+(DAN|in\s+developer\s+mode)`, Severity: SeverityMedium},
+		{Pattern: `(pretend|act\s+as\s+if)\s+(that\s+)?you\s+have\s+no\s+(restrictions|rules|guidelines|filters)`, Severity: SeverityMedium},
+		{Pattern: `\bjailbreak\b`, Severity: SeverityMedium},
+		{Pattern: `new\s+instructions\s*:`, Severity: SeverityLow},
+	}
+}
+
+// injectionPattern is a compiled prompt injection pattern
+type injectionPattern struct {
+	re       *regexp.Regexp
+	source   string
+	severity string
+}
+
+// compileInjectionPatterns compiles the configured patterns, or the defaults
+// when none are configured
+func compileInjectionPatterns(patterns []PromptInjectionPattern) ([]injectionPattern, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultPromptInjectionPatterns()
+	}
+
+	compiled := make([]injectionPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		severity := pattern.Severity
+		if severity == "" {
+			severity = SeverityMedium
+		}
+		if _, ok := severityRank[severity]; !ok {
+			return nil, fmt.Errorf("invalid severity %q for prompt injection pattern %s", pattern.Severity, pattern.Pattern)
+		}
+		re, err := regexp.Compile("(?i)" + pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt injection pattern %s: %w", pattern.Pattern, err)
+		}
+		compiled = append(compiled, injectionPattern{re: re, source: pattern.Pattern, severity: severity})
+	}
+	return compiled, nil
+}
+
+// DetectPromptInjection returns the patterns at or above the configured
+// severity threshold that match the user and system text of a request body
+func (v *RequestValidator) DetectPromptInjection(body map[string]interface{}) []PromptInjectionMatch {
+	text := promptText(body)
+	if text == "" {
+		return nil
+	}
+
+	var matches []PromptInjectionMatch
+	for _, pattern := range v.injectionPatterns {
+		if severityRank[pattern.severity] < v.injectionThreshold {
+			continue
+		}
+		if pattern.re.MatchString(text) {
+			matches = append(matches, PromptInjectionMatch{Pattern: pattern.source, Severity: pattern.severity})
+		}
+	}
+	return matches
+}
+
+// highestSeverity returns the most severe level among matches
+func highestSeverity(matches []PromptInjectionMatch) string {
+	highest := ""
+	for _, match := range matches {
+		if severityRank[match.Severity] > severityRank[highest] {
+			highest = match.Severity
+		}
+	}
+	return highest
+}
+
+// promptText joins the system prompt and the text of user and system
+// messages, including tool results, which can carry injected instructions.
+// Assistant turns are the model's own output and are skipped.
+func promptText(body map[string]interface{}) string {
+	var parts []string
+	collectText(body["system"], &parts)
+
+	messages, _ := body["messages"].([]interface{})
+	for _, msg := range messages {
+		msgMap, ok := msg.(map[string]interface{})
+		if !ok || msgMap["role"] == "assistant" {
+			continue
+		}
+		collectText(msgMap["content"], &parts)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// collectText appends every string found in content blocks
+func collectText(value interface{}, parts *[]string) {
+	switch v := value.(type) {
+	case string:
+		*parts = append(*parts, v)
+	case []interface{}:
+		for _, item := range v {
+			collectText(item, parts)
+		}
+	case map[string]interface{}:
+		collectText(v["text"], parts)
+		collectText(v["content"], parts)
+	}
+}
@@ -1,10 +1,24 @@
 package security
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
 
+// RateLimiter limits requests per client key
+type RateLimiter interface {
+	// Allow checks if a request under the given key is allowed
+	Allow(key string) bool
+	// GetLimit returns the current limit for a key
+	GetLimit(key string) RateLimitInfo
+	// ClientKey returns the key a request is limited under
+	ClientKey(req *http.Request, clientIP string) string
+}
+
 // IPRateLimiter implements a simple IP-based rate limiter
 type IPRateLimiter struct {
 	requests map[string]*rateLimitBucket
@@ -79,12 +93,13 @@ func (rl *IPRateLimiter) GetLimit(ip string) RateLimitInfo {
 	}
 
 	bucket, exists := rl.requests[ip]
-	if exists && time.Now().Before(bucket.resetTime) {
+	if now := time.Now(); exists && now.Before(bucket.resetTime) {
 		info.Used = bucket.count
 		info.Reset = bucket.resetTime
 		info.Remaining = rl.limit - bucket.count
-		if info.Remaining < 0 {
+		if info.Remaining <= 0 {
 			info.Remaining = 0
+			info.RetryAfter = bucket.resetTime.Sub(now)
 		}
 	} else {
 		info.Used = 0
@@ -95,6 +110,11 @@ func (rl *IPRateLimiter) GetLimit(ip string) RateLimitInfo {
 	return info
 }
 
+// ClientKey limits requests by client IP
+func (rl *IPRateLimiter) ClientKey(req *http.Request, clientIP string) string {
+	return clientIP
+}
+
 // Reset resets the rate limit for an IP
 func (rl *IPRateLimiter) Reset(ip string) {
 	rl.mu.Lock()
@@ -133,14 +153,15 @@ func (rl *IPRateLimiter) Stop() {
 
 // TokenBucketRateLimiter implements a token bucket rate limiter
 type TokenBucketRateLimiter struct {
-	buckets    map[string]*tokenBucket
-	capacity   int
-	refillRate int
-	mu         sync.RWMutex
-	cleanup    *time.Ticker
-	done       chan struct{}
-	wg         sync.WaitGroup
-	stopOnce   sync.Once
+	buckets     map[string]*tokenBucket
+	capacity    int
+	refillRate  int           // Tokens added every refillEvery
+	refillEvery time.Duration // Refill period, a second unless set otherwise
+	mu          sync.RWMutex
+	cleanup     *time.Ticker
+	done        chan struct{}
+	wg          sync.WaitGroup
+	stopOnce    sync.Once
 }
 
 // tokenBucket represents a token bucket for rate limiting
@@ -152,11 +173,12 @@ type tokenBucket struct {
 // NewTokenBucketRateLimiter creates a new token bucket rate limiter
 func NewTokenBucketRateLimiter(capacity, refillRate int) *TokenBucketRateLimiter {
 	rl := &TokenBucketRateLimiter{
-		buckets:    make(map[string]*tokenBucket),
-		capacity:   capacity,
-		refillRate: refillRate,
-		cleanup:    time.NewTicker(5 * time.Minute),
-		done:       make(chan struct{}),
+		buckets:     make(map[string]*tokenBucket),
+		capacity:    capacity,
+		refillRate:  refillRate,
+		refillEvery: time.Second,
+		cleanup:     time.NewTicker(5 * time.Minute),
+		done:        make(chan struct{}),
 	}
 
 	rl.wg.Add(1)
@@ -193,17 +215,26 @@ func (rl *TokenBucketRateLimiter) Allow(key string, tokens int) bool {
 // refillTokens refills tokens based on elapsed time
 func (rl *TokenBucketRateLimiter) refillTokens(bucket *tokenBucket) {
 	now := time.Now()
-	elapsed := now.Sub(bucket.lastRefill)
-
-	// Calculate tokens to add
-	tokensToAdd := int(elapsed.Seconds()) * rl.refillRate
-	if tokensToAdd > 0 {
-		bucket.tokens += tokensToAdd
-		if bucket.tokens > rl.capacity {
-			bucket.tokens = rl.capacity
-		}
+	periods := rl.refillPeriods(bucket, now)
+	if periods <= 0 {
+		return
+	}
+
+	bucket.tokens += periods * rl.refillRate
+	if bucket.tokens >= rl.capacity {
+		bucket.tokens = rl.capacity
 		bucket.lastRefill = now
+		return
 	}
+	// Keep the time towards the next refill, so frequent requests do not
+	// delay it
+	bucket.lastRefill = bucket.lastRefill.Add(time.Duration(periods) * rl.refillEvery)
+}
+
+// refillPeriods returns the number of whole refill periods since the bucket
+// was last refilled
+func (rl *TokenBucketRateLimiter) refillPeriods(bucket *tokenBucket, now time.Time) int {
+	return int(now.Sub(bucket.lastRefill) / rl.refillEvery)
 }
 
 // GetTokens returns the current token count for a key
@@ -217,11 +248,7 @@ func (rl *TokenBucketRateLimiter) GetTokens(key string) int {
 	}
 
 	// Calculate current tokens without modifying
-	now := time.Now()
-	elapsed := now.Sub(bucket.lastRefill)
-	tokensToAdd := int(elapsed.Seconds()) * rl.refillRate
-
-	tokens := bucket.tokens + tokensToAdd
+	tokens := bucket.tokens + rl.refillPeriods(bucket, time.Now())*rl.refillRate
 	if tokens > rl.capacity {
 		tokens = rl.capacity
 	}
@@ -229,6 +256,29 @@ func (rl *TokenBucketRateLimiter) GetTokens(key string) int {
 	return tokens
 }
 
+// RefillTime returns when the bucket for a key will hold the given number of
+// tokens, or now when it already does. Tokens are added in whole refill
+// periods from the last refill, so this is the first refill bringing enough
+// of them.
+func (rl *TokenBucketRateLimiter) RefillTime(key string, tokens int) time.Time {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	now := time.Now()
+	bucket, exists := rl.buckets[key]
+	if !exists || bucket.tokens >= tokens {
+		return now
+	}
+
+	missing := tokens - bucket.tokens
+	periods := (missing + rl.refillRate - 1) / rl.refillRate
+	refill := bucket.lastRefill.Add(time.Duration(periods) * rl.refillEvery)
+	if refill.Before(now) {
+		return now
+	}
+	return refill
+}
+
 // cleanupStale removes buckets that haven't been used recently
 func (rl *TokenBucketRateLimiter) cleanupStale() {
 	defer rl.wg.Done()
@@ -257,3 +307,83 @@ func (rl *TokenBucketRateLimiter) Stop() {
 		rl.wg.Wait()
 	})
 }
+
+// KeyRateLimiter rate limits requests per API key using a token bucket,
+// falling back to the client IP for unauthenticated requests
+type KeyRateLimiter struct {
+	*TokenBucketRateLimiter
+	window       time.Duration
+	apiKeyHeader string
+}
+
+// NewKeyRateLimiter creates a rate limiter allowing limit requests per window
+// for each API key. Tokens come back one at a time, evenly over the window,
+// so limits below one request per second hold too.
+func NewKeyRateLimiter(limit int, window time.Duration) *KeyRateLimiter {
+	bucket := NewTokenBucketRateLimiter(limit, 1)
+	if limit > 0 {
+		bucket.refillEvery = max(window/time.Duration(limit), time.Nanosecond)
+	}
+
+	return &KeyRateLimiter{
+		TokenBucketRateLimiter: bucket,
+		window:                 window,
+		apiKeyHeader:           "X-API-Key",
+	}
+}
+
+// Allow checks if a request under the given key is allowed
+func (rl *KeyRateLimiter) Allow(key string) bool {
+	return rl.TokenBucketRateLimiter.Allow(key, 1)
+}
+
+// GetLimit returns the current limit for a key
+func (rl *KeyRateLimiter) GetLimit(key string) RateLimitInfo {
+	remaining := rl.GetTokens(key)
+
+	// The limit resets once the bucket is full again
+	info := RateLimitInfo{
+		Key:       key,
+		Limit:     rl.capacity,
+		Window:    rl.window,
+		Used:      rl.capacity - remaining,
+		Reset:     rl.RefillTime(key, rl.capacity),
+		Remaining: remaining,
+	}
+
+	// An empty bucket allows the next request once a token is refilled
+	if remaining == 0 {
+		info.RetryAfter = time.Until(rl.RefillTime(key, 1))
+	}
+	return info
+}
+
+// ClientKey limits requests by API key, or by client IP when the request
+// carries no key. Keys are hashed so they are never held in memory.
+func (rl *KeyRateLimiter) ClientKey(req *http.Request, clientIP string) string {
+	apiKey := req.Header.Get(rl.apiKeyHeader)
+	if apiKey == "" {
+		if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			apiKey = strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
+	if apiKey == "" {
+		return "ip:" + clientIP
+	}
+
+	hash := sha256.Sum256([]byte(apiKey))
+	return "key:" + hex.EncodeToString(hash[:])
+}
+
+// NewRateLimiter creates the rate limiter selected by the configured keying
+// strategy, allowing limit requests per window
+func NewRateLimiter(config *SecurityConfig, limit int, window time.Duration) RateLimiter {
+	if config.RateLimitKeying == RateLimitKeyingAPIKey {
+		limiter := NewKeyRateLimiter(limit, window)
+		if config.APIKeyHeader != "" {
+			limiter.apiKeyHeader = config.APIKeyHeader
+		}
+		return limiter
+	}
+	return NewIPRateLimiter(limit, window)
+}
@@ -1,6 +1,8 @@
 package security
 
 import (
+	"net/http"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -773,3 +775,105 @@ func TestTokenBucketRefillTokens(t *testing.T) {
 		testutil.AssertEqual(t, 100, bucket.tokens) // Should be capped at capacity
 	})
 }
+
+func TestTokenBucketRefillTime(t *testing.T) {
+	limiter := NewTokenBucketRateLimiter(10, 2)
+	defer limiter.Stop()
+
+	lastRefill := time.Now().Add(-300 * time.Millisecond)
+	limiter.buckets["empty"] = &tokenBucket{tokens: 0, lastRefill: lastRefill}
+	limiter.buckets["half"] = &tokenBucket{tokens: 5, lastRefill: lastRefill}
+
+	// Two tokens arrive each whole second after the last refill
+	testutil.AssertEqual(t, lastRefill.Add(time.Second), limiter.RefillTime("empty", 1))
+	testutil.AssertEqual(t, lastRefill.Add(time.Second), limiter.RefillTime("empty", 2))
+	testutil.AssertEqual(t, lastRefill.Add(2*time.Second), limiter.RefillTime("empty", 3))
+	testutil.AssertEqual(t, lastRefill.Add(5*time.Second), limiter.RefillTime("empty", 10))
+	testutil.AssertEqual(t, lastRefill.Add(3*time.Second), limiter.RefillTime("half", 10))
+
+	// Buckets holding enough tokens, or not created yet, are ready now
+	testutil.AssertTrue(t, !limiter.RefillTime("half", 5).After(time.Now()), "Expected enough tokens now")
+	testutil.AssertTrue(t, !limiter.RefillTime("unknown", 10).After(time.Now()), "Expected a new bucket to be full")
+}
+
+func TestKeyRateLimiter(t *testing.T) {
+	t.Run("client key", func(t *testing.T) {
+		limiter := NewKeyRateLimiter(10, time.Minute)
+		defer limiter.Stop()
+
+		bearer, _ := http.NewRequest("GET", "/", nil)
+		bearer.Header.Set("Authorization", "Bearer secret-key")
+		header, _ := http.NewRequest("GET", "/", nil)
+		header.Header.Set("X-API-Key", "secret-key")
+		anonymous, _ := http.NewRequest("GET", "/", nil)
+
+		key := limiter.ClientKey(bearer, "10.0.0.1")
+		testutil.AssertTrue(t, strings.HasPrefix(key, "key:"), "Expected API key based key")
+		testutil.AssertTrue(t, !strings.Contains(key, "secret-key"), "Expected API key to be hashed")
+		testutil.AssertEqual(t, key, limiter.ClientKey(header, "10.0.0.2"))
+		testutil.AssertEqual(t, "ip:10.0.0.1", limiter.ClientKey(anonymous, "10.0.0.1"))
+	})
+
+	t.Run("get limit", func(t *testing.T) {
+		limiter := NewKeyRateLimiter(3, time.Minute)
+		defer limiter.Stop()
+
+		testutil.AssertTrue(t, limiter.Allow("key:a"), "Expected first request to be allowed")
+		info := limiter.GetLimit("key:a")
+		testutil.AssertEqual(t, 3, info.Limit)
+		testutil.AssertEqual(t, 1, info.Used)
+		testutil.AssertEqual(t, 2, info.Remaining)
+		testutil.AssertEqual(t, time.Minute, info.Window)
+		testutil.AssertTrue(t, info.Reset.After(time.Now()), "Expected reset in the future")
+		testutil.AssertEqual(t, time.Duration(0), info.RetryAfter)
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		limiter := NewKeyRateLimiter(2, time.Minute)
+		defer limiter.Stop()
+
+		lastRefill := time.Now().Add(-400 * time.Millisecond)
+		limiter.buckets["key:a"] = &tokenBucket{tokens: 0, lastRefill: lastRefill}
+
+		info := limiter.GetLimit("key:a")
+		testutil.AssertEqual(t, 0, info.Remaining)
+		// Two requests a minute refill a token every 30 seconds
+		testutil.AssertEqual(t, lastRefill.Add(time.Minute), info.Reset)
+		testutil.AssertTrue(t, info.RetryAfter > 29*time.Second && info.RetryAfter <= 29600*time.Millisecond,
+			"Expected to retry when the next token is refilled")
+	})
+
+	t.Run("fractional refill", func(t *testing.T) {
+		limiter := NewKeyRateLimiter(2, time.Minute)
+		defer limiter.Stop()
+
+		testutil.AssertTrue(t, limiter.Allow("key:a"))
+		testutil.AssertTrue(t, limiter.Allow("key:a"))
+		testutil.AssertTrue(t, !limiter.Allow("key:a"), "Expected the bucket to be empty")
+
+		// A second later still nothing is refilled, half a minute later one
+		// token is
+		limiter.buckets["key:a"].lastRefill = time.Now().Add(-time.Second)
+		testutil.AssertTrue(t, !limiter.Allow("key:a"), "Expected no token after a second")
+		limiter.buckets["key:a"].lastRefill = time.Now().Add(-31 * time.Second)
+		testutil.AssertTrue(t, limiter.Allow("key:a"), "Expected a token after 30 seconds")
+		testutil.AssertTrue(t, !limiter.Allow("key:a"), "Expected only one token")
+	})
+
+	t.Run("keying strategy", func(t *testing.T) {
+		config := DefaultSecurityConfig()
+		ipLimiter, ok := NewRateLimiter(config, 10, time.Minute).(*IPRateLimiter)
+		testutil.AssertTrue(t, ok, "Expected IP rate limiter by default")
+		ipLimiter.Stop()
+
+		config.RateLimitKeying = RateLimitKeyingAPIKey
+		config.APIKeyHeader = "X-Custom-Key"
+		keyLimiter, ok := NewRateLimiter(config, 10, time.Minute).(*KeyRateLimiter)
+		testutil.AssertTrue(t, ok, "Expected API key rate limiter")
+		defer keyLimiter.Stop()
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Custom-Key", "secret-key")
+		testutil.AssertTrue(t, strings.HasPrefix(keyLimiter.ClientKey(req, "10.0.0.1"), "key:"), "Expected configured header to be used")
+	})
+}
@@ -15,6 +15,15 @@ const (
 	SecurityLevelParanoid SecurityLevel = "paranoid"
 )
 
+// Rate limit keying strategies
+const (
+	RateLimitKeyingIP     = "ip"
+	RateLimitKeyingAPIKey = "api_key"
+)
+
+// DefaultRateLimitMessage is the error message of rate limited responses
+const DefaultRateLimitMessage = "rate limit exceeded"
+
 // ValidationResult represents the result of a security validation
 type ValidationResult struct {
 	Valid    bool     `json:"valid"`
@@ -30,6 +39,9 @@ type SecurityConfig struct {
 	EnableTLS            bool          `json:"enable_tls"`
 	TLSMinVersion        string        `json:"tls_min_version"`
 	EnableRateLimiting   bool          `json:"enable_rate_limiting"`
+	RateLimitKeying      string        `json:"rate_limit_keying"`     // "ip" or "api_key"
+	RateLimitPerMinute   int           `json:"rate_limit_per_minute"` // Requests allowed per minute, zero uses 100
+	RateLimitMessage     string        `json:"rate_limit_message"`    // Error message of 429 responses, empty uses DefaultRateLimitMessage
 	EnableIPWhitelist    bool          `json:"enable_ip_whitelist"`
 	EnableAPIKeyRotation bool          `json:"enable_api_key_rotation"`
 
@@ -44,6 +56,13 @@ type SecurityConfig struct {
 	BlockedPatterns     []string `json:"blocked_patterns"`
 	SensitivePatterns   []string `json:"sensitive_patterns"`
 
+	// Prompt injection filtering. Matches at or above the threshold are
+	// rejected when blocking, otherwise flagged with PromptInjectionHeader.
+	EnablePromptInjectionFilter bool                     `json:"enable_prompt_injection_filter"`
+	BlockPromptInjection        bool                     `json:"block_prompt_injection"`
+	PromptInjectionThreshold    string                   `json:"prompt_injection_threshold"` // "low", "medium" or "high", empty means medium
+	PromptInjectionPatterns     []PromptInjectionPattern `json:"prompt_injection_patterns"`  // Empty uses DefaultPromptInjectionPatterns
+
 	// Authentication
 	RequireAuth        bool     `json:"require_auth"`
 	AllowedAuthMethods []string `json:"allowed_auth_methods"`
@@ -59,6 +78,23 @@ type SecurityConfig struct {
 	LogSensitiveData bool   `json:"log_sensitive_data"`
 	AuditLogPath     string `json:"audit_log_path"`
 	RetentionDays    int    `json:"retention_days"`
+
+	// Audit sink: "file" (default), "stdout", "syslog" or "webhook"
+	AuditSink                 string            `json:"audit_sink"`
+	AuditSyslogNetwork        string            `json:"audit_syslog_network"` // Empty uses the local syslog daemon
+	AuditSyslogAddress        string            `json:"audit_syslog_address"`
+	AuditSyslogTag            string            `json:"audit_syslog_tag"`
+	AuditWebhookURL           string            `json:"audit_webhook_url"`
+	AuditWebhookHeaders       map[string]string `json:"audit_webhook_headers"`
+	AuditWebhookBatchSize     int               `json:"audit_webhook_batch_size"`
+	AuditWebhookFlushInterval time.Duration     `json:"audit_webhook_flush_interval"`
+	AuditWebhookMaxRetries    int               `json:"audit_webhook_max_retries"`
+
+	// Audit log file rotation, checked in the background. Zero disables each limit.
+	AuditMaxSizeMB  int  `json:"audit_max_size_mb"`  // Rotate once the log reaches this size
+	AuditMaxAgeDays int  `json:"audit_max_age_days"` // Rotate once the log's first entry is this old
+	AuditMaxBackups int  `json:"audit_max_backups"`  // Rotated logs to keep, zero keeps them all
+	AuditCompress   bool `json:"audit_compress"`     // Gzip rotated logs
 }
 
 // Validator interface for security validation
@@ -146,12 +182,13 @@ type AuditFilter struct {
 
 // RateLimitInfo represents rate limit information
 type RateLimitInfo struct {
-	Key       string
-	Limit     int
-	Window    time.Duration
-	Used      int
-	Reset     time.Time
-	Remaining int
+	Key        string
+	Limit      int
+	Window     time.Duration
+	Used       int
+	Reset      time.Time
+	Remaining  int
+	RetryAfter time.Duration // Wait until another request is allowed, 0 while requests are allowed
 }
 
 // IPInfo represents IP address information
@@ -175,6 +212,7 @@ func DefaultSecurityConfig() *SecurityConfig {
 		EnableTLS:            true,
 		TLSMinVersion:        "1.2",
 		EnableRateLimiting:   true,
+		RateLimitKeying:      RateLimitKeyingIP,
 		EnableIPWhitelist:    false,
 		EnableAPIKeyRotation: false,
 
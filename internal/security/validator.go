@@ -17,6 +17,10 @@ type RequestValidator struct {
 	config           *SecurityConfig
 	compiledPatterns []*regexp.Regexp
 	sensitiveRegexps []*regexp.Regexp
+
+	// Prompt injection patterns, compiled only when the filter is enabled
+	injectionPatterns  []injectionPattern
+	injectionThreshold int
 }
 
 // NewRequestValidator creates a new request validator
@@ -49,6 +53,24 @@ func NewRequestValidator(config *SecurityConfig) (*RequestValidator, error) {
 		validator.sensitiveRegexps = append(validator.sensitiveRegexps, re)
 	}
 
+	// Compile prompt injection patterns
+	if config.EnablePromptInjectionFilter {
+		threshold := config.PromptInjectionThreshold
+		if threshold == "" {
+			threshold = SeverityMedium
+		}
+		rank, ok := severityRank[threshold]
+		if !ok {
+			return nil, fmt.Errorf("invalid prompt injection threshold: %s", config.PromptInjectionThreshold)
+		}
+		patterns, err := compileInjectionPatterns(config.PromptInjectionPatterns)
+		if err != nil {
+			return nil, err
+		}
+		validator.injectionPatterns = patterns
+		validator.injectionThreshold = rank
+	}
+
 	return validator, nil
 }
 
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/transformer"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// sensitiveHeaderPattern matches custom header names that carry credentials
+var sensitiveHeaderPattern = regexp.MustCompile(`(?i)(auth|key|token|secret|cookie)`)
+
+// handleGetConfig returns the active configuration with secrets masked
+func (s *Server) handleGetConfig(c *gin.Context) {
+	Success(c, maskConfig(s.currentConfig()))
+}
+
+// handleReloadConfig reloads the configuration and returns the masked result
+func (s *Server) handleReloadConfig(c *gin.Context) {
+	if err := s.reloadConfig(); err != nil {
+		BadRequest(c, fmt.Sprintf("Failed to reload configuration: %v", err))
+		return
+	}
+
+	utils.GetLogger().Info("Configuration reloaded")
+	Success(c, maskConfig(s.currentConfig()))
+}
+
+// reloadConfig reloads the configuration from the file the server was started
+// with, or from the default sources, and applies it to the provider and
+// transformer services, routing and the pipeline. Listener and middleware
+// settings such as host, port, authentication, CORS, rate limits, the prompt
+// injection filter and the audit log still need a restart.
+func (s *Server) reloadConfig() error {
+	if s.configPath == config.StdinPath {
+		return fmt.Errorf("configuration read from stdin cannot be reloaded")
+	}
+	if s.configPath != "" {
+		cfg, err := config.LoadFromFile(s.configPath)
+		if err != nil {
+			return err
+		}
+		s.configService.SetConfig(cfg)
+	} else if err := s.configService.Reload(); err != nil {
+		return err
+	}
+
+	cfg := s.configService.Get()
+	if err := transformer.GetRegistry().ConfigureProviderChains(cfg.Providers); err != nil {
+		return fmt.Errorf("failed to configure transformer chains: %w", err)
+	}
+	if err := s.providerService.Initialize(); err != nil {
+		return fmt.Errorf("failed to reinitialize provider service: %w", err)
+	}
+
+	// The reloaded file decides which providers are enabled
+	s.pipeline.ResetProviderStates()
+
+	s.setConfig(cfg)
+
+	// Refresh readiness now rather than at the next probe, so a server
+	// leaving degraded mode reports healthy right away
+	s.readiness.RunOnce(context.Background())
+	return nil
+}
+
+// handleSetProviderEnabled returns a handler that switches a provider on or
+// off in the live configuration. With ?persist=true the provider's enabled
+// flag is also written to the configuration file.
+func (s *Server) handleSetProviderEnabled(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		persist := c.Query("persist") == "true"
+		if persist && (s.configPath == "" || s.configPath == config.StdinPath || config.IsRemotePath(s.configPath)) {
+			BadRequest(c, "Cannot persist: the configuration was not loaded from a local file")
+			return
+		}
+
+		provider, err := s.configService.SetProviderEnabled(name, enabled)
+		if err != nil {
+			NotFound(c, fmt.Sprintf("Provider '%s' not found", name))
+			return
+		}
+		if err := s.providerService.RefreshProvider(name); err != nil {
+			utils.GetLogger().Warnf("Failed to refresh provider in service: %v", err)
+		}
+		s.pipeline.SetProviderEnabled(name, enabled)
+		s.setConfig(s.configService.Get())
+
+		state := "disabled"
+		if enabled {
+			state = "enabled"
+		}
+		utils.GetLogger().Warnf("Provider %s %s through the admin API", name, state)
+
+		if persist {
+			if err := persistProviderEnabled(s.configPath, name, enabled); err != nil {
+				InternalServerError(c, fmt.Sprintf("Provider %s but not persisted: %v", state, err))
+				return
+			}
+		}
+
+		Success(c, gin.H{
+			"name":      provider.Name,
+			"enabled":   provider.Enabled,
+			"persisted": persist,
+		})
+	}
+}
+
+// persistProviderEnabled sets a provider's enabled flag in the configuration
+// file. Only that value changes, so the file keeps its formatting, key order
+// and mode.
+func persistProviderEnabled(path, name string, enabled bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+	data, err := os.ReadFile(path) // #nosec G304 - path is the configuration file the server was started with
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+
+	updated, err := setProviderEnabledJSON(data, name, enabled)
+	if err != nil {
+		return fmt.Errorf("failed to parse configuration: %w", err)
+	}
+	if updated == nil {
+		return fmt.Errorf("provider %s is not defined in %s", name, path)
+	}
+	return utils.WriteFileAtomic(path, updated, info.Mode().Perm())
+}
+
+// setProviderEnabledJSON rewrites the enabled value of the named provider in
+// a JSON configuration, adding it after the provider's name when missing. It
+// returns nil when no provider has that name.
+func setProviderEnabledJSON(data []byte, name string, enabled bool) ([]byte, error) {
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	value := fmt.Sprintf("%t", enabled)
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if key != "providers" {
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return nil, fmt.Errorf("providers must be an array")
+		}
+		for decoder.More() {
+			if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+				return nil, fmt.Errorf("providers must be objects")
+			}
+
+			// Offsets of the name key and of the name and enabled values
+			var providerName string
+			nameKeyStart, nameEnd, enabledStart, enabledEnd := -1, -1, -1, -1
+			for decoder.More() {
+				field, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				keyEnd := int(decoder.InputOffset())
+				var raw json.RawMessage
+				if err := decoder.Decode(&raw); err != nil {
+					return nil, err
+				}
+				valueEnd := int(decoder.InputOffset())
+
+				switch field {
+				case "name":
+					_ = json.Unmarshal(raw, &providerName)
+					nameKeyStart = bytes.LastIndexByte(data[:keyEnd-1], '"')
+					nameEnd = valueEnd
+				case "enabled":
+					enabledStart, enabledEnd = valueEnd-len(raw), valueEnd
+				}
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+
+			switch {
+			case providerName != name:
+			case enabledStart >= 0:
+				edits = append(edits, edit{start: enabledStart, end: enabledEnd, text: value})
+			case nameEnd >= 0:
+				// Put the new key on its own line when the name is on one
+				lineStart := bytes.LastIndexByte(data[:nameKeyStart], '\n') + 1
+				indent := data[lineStart:nameKeyStart]
+				separator := ", "
+				if len(bytes.TrimSpace(indent)) == 0 {
+					separator = ",\n" + string(indent)
+				}
+				edits = append(edits, edit{start: nameEnd, end: nameEnd, text: separator + `"enabled": ` + value})
+			}
+		}
+		break
+	}
+	if len(edits) == 0 {
+		return nil, nil
+	}
+
+	updated := append([]byte(nil), data...)
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		updated = append(updated[:e.start], append([]byte(e.text), updated[e.end:]...)...)
+	}
+	return updated, nil
+}
+
+// maskConfig returns a copy of the configuration that is safe to display
+func maskConfig(cfg *config.Config) *config.Config {
+	masked := *cfg
+	masked.APIKey = maskSecret(cfg.APIKey)
+	masked.ProxyURL = maskURLPassword(cfg.ProxyURL)
+
+	if len(cfg.InboundAPIKeys) > 0 {
+		masked.InboundAPIKeys = make([]string, len(cfg.InboundAPIKeys))
+		for i, key := range cfg.InboundAPIKeys {
+			masked.InboundAPIKeys[i] = maskSecret(key)
+		}
+	}
+
+	masked.Providers = make([]config.Provider, len(cfg.Providers))
+	for i, provider := range cfg.Providers {
+		provider.APIKey = maskSecret(provider.APIKey)
+		provider.Headers = maskHeaders(provider.Headers)
+		masked.Providers[i] = provider
+	}
+	masked.Tracing.Headers = maskHeaders(cfg.Tracing.Headers)
+
+	if len(cfg.Security.ModelAccess) > 0 {
+		masked.Security.ModelAccess = make([]config.ModelAccessRule, len(cfg.Security.ModelAccess))
+		for i, rule := range cfg.Security.ModelAccess {
+			rule.Key = maskSecret(rule.Key)
+			masked.Security.ModelAccess[i] = rule
+		}
+	}
+	// Webhook URLs often carry their token in the path or query
+	masked.Security.Audit.WebhookURL = maskSecret(cfg.Security.Audit.WebhookURL)
+	masked.Security.Audit.WebhookHeaders = maskHeaders(cfg.Security.Audit.WebhookHeaders)
+
+	return &masked
+}
+
+// maskHeaders returns a copy of headers with credential values masked
+func maskHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return headers
+	}
+	masked := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if sensitiveHeaderPattern.MatchString(name) {
+			value = maskSecret(value)
+		}
+		masked[name] = value
+	}
+	return masked
+}
+
+// maskSecret masks a secret, leaving unset values empty
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return utils.MaskAPIKey(secret)
+}
+
+// maskURLPassword masks the password in a URL's user info
+func maskURLPassword(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	if _, hasPassword := u.User.Password(); !hasPassword {
+		return rawURL
+	}
+	u.User = url.UserPassword(u.User.Username(), "****")
+	return u.String()
+}
@@ -1,17 +1,108 @@
 package server
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/orchestre-dev/ccproxy/internal/security"
 )
 
+// apiKeyAllowlist holds the SHA-256 digests of accepted API keys. Comparing
+// fixed-length digests keeps the check constant-time in the key length too.
+type apiKeyAllowlist [][sha256.Size]byte
+
+// newAPIKeyAllowlist creates an allowlist from keys, skipping empty ones
+func newAPIKeyAllowlist(keys ...string) apiKeyAllowlist {
+	allowlist := make(apiKeyAllowlist, 0, len(keys))
+	for _, key := range keys {
+		if key != "" {
+			allowlist = append(allowlist, sha256.Sum256([]byte(key)))
+		}
+	}
+	return allowlist
+}
+
+// contains reports whether key is allowed. Every entry is compared so the
+// time taken does not reveal which key, if any, matched.
+func (a apiKeyAllowlist) contains(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(key))
+	match := 0
+	for i := range a {
+		match |= subtle.ConstantTimeCompare(digest[:], a[i][:])
+	}
+	return match == 1
+}
+
+// allows reports whether the request presents an allowed key as a Bearer
+// token or in the x-api-key header
+func (a apiKeyAllowlist) allows(c *gin.Context) bool {
+	return a.match(c) != ""
+}
+
+// match returns the allowed key the request presents as a Bearer token or in
+// the x-api-key header, or "" when it presents none
+func (a apiKeyAllowlist) match(c *gin.Context) string {
+	var token string
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+		token = parts[1]
+	}
+	header := c.GetHeader("x-api-key")
+
+	// Evaluate both headers so timing does not depend on which one is set
+	bearerOK := a.contains(token)
+	headerOK := a.contains(header)
+	switch {
+	case bearerOK:
+		return token
+	case headerOK:
+		return header
+	}
+	return ""
+}
+
+// authenticated marks the request as made with key, so per-key model
+// restrictions apply to it
+func authenticated(c *gin.Context, key string) {
+	c.Set("api_key_hash", security.HashAPIKey(key))
+	c.Next()
+}
+
+// isPublicPath reports whether a path is served without authentication
+func isPublicPath(path string) bool {
+	return path == "/" || path == "/health" || path == "/status"
+}
+
+// inboundKeyMiddleware rejects requests that do not present a key from the
+// allowlist with 401
+func inboundKeyMiddleware(allowlist apiKeyAllowlist) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isPublicPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		if key := allowlist.match(c); key != "" {
+			authenticated(c, key)
+			return
+		}
+
+		Unauthorized(c, "Invalid API key")
+		c.Abort()
+	}
+}
+
 // authMiddleware creates authentication middleware
 func authMiddleware(apiKey string, enforceLocalhost bool) gin.HandlerFunc {
+	allowlist := newAPIKeyAllowlist(apiKey)
 	return func(c *gin.Context) {
 		// Skip auth for health and status endpoints
-		path := c.Request.URL.Path
-		if path == "/" || path == "/health" || path == "/status" {
+		if isPublicPath(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
@@ -29,21 +120,9 @@ func authMiddleware(apiKey string, enforceLocalhost bool) gin.HandlerFunc {
 			return
 		}
 
-		// Check Authorization header (Bearer token)
-		authHeader := c.GetHeader("Authorization")
-		if authHeader != "" {
-			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
-				if parts[1] == apiKey {
-					c.Next()
-					return
-				}
-			}
-		}
-
-		// Check x-api-key header
-		if c.GetHeader("x-api-key") == apiKey {
-			c.Next()
+		// Check the Bearer token and x-api-key header
+		if key := allowlist.match(c); key != "" {
+			authenticated(c, key)
 			return
 		}
 
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// noProvidersMessage tells clients of a server without providers how to
+// configure one
+const noProvidersMessage = "CCProxy has no providers configured. Add a provider with its API key " +
+	"to the configuration, for example with `ccproxy setup`, then reload it with POST /admin/config/reload " +
+	"or restart CCProxy."
+
+// hasProviders reports whether any provider is configured. Without one the
+// server runs in degraded mode: it starts and answers health checks, but API
+// requests fail until a configuration with providers is loaded. Providers
+// disabled through the admin API still count, as they fail requests with
+// their own error.
+func (s *Server) hasProviders() bool {
+	return len(s.providerService.GetAllProviders()) > 0
+}
+
+// requireProviders rejects API requests with 503 and configuration guidance
+// while the server is in degraded mode
+func (s *Server) requireProviders(c *gin.Context) {
+	if s.hasProviders() {
+		c.Next()
+		return
+	}
+
+	RespondWithErrorCode(c, http.StatusServiceUnavailable, ErrorTypeServerError, noProvidersMessage, "no_providers_configured")
+	c.Abort()
+}
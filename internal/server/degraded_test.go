@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+)
+
+func TestDegradedMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"apikey": "test-api-key"}`), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := config.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	server, err := NewWithPath(cfg, path)
+	if err != nil {
+		t.Fatalf("Expected the server to start without providers, got: %v", err)
+	}
+	router := server.GetRouter()
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-api-key")
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	healthStatus := func(t *testing.T) string {
+		t.Helper()
+		w := request("GET", "/health", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		status, _ := response["status"].(string)
+		return status
+	}
+
+	t.Run("HealthReportsDegraded", func(t *testing.T) {
+		if status := healthStatus(t); status != "degraded" {
+			t.Errorf("Expected status degraded, got %q", status)
+		}
+	})
+
+	t.Run("RequestsGetGuidance", func(t *testing.T) {
+		for _, path := range []string{"/v1/messages", "/v1/embeddings"} {
+			w := request("POST", path, `{"model": "claude-3-opus", "max_tokens": 10, "messages": [{"role": "user", "content": "Hi"}]}`)
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("%s: expected status 503, got %d", path, w.Code)
+			}
+
+			var response ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Error.Code != "no_providers_configured" || !strings.Contains(response.Error.Message, "/admin/config/reload") {
+				t.Errorf("%s: expected configuration guidance, got %+v", path, response.Error)
+			}
+		}
+	})
+
+	t.Run("ReloadRecovers", func(t *testing.T) {
+		data := `{
+			"apikey": "test-api-key",
+			"providers": [{"name": "openai", "api_base_url": "https://api.openai.com", "api_key": "test-key", "models": ["gpt-4o"], "enabled": true}],
+			"routes": {"default": {"provider": "openai", "model": "gpt-4o"}}
+		}`
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+
+		if w := request("POST", "/admin/config/reload", ""); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if status := healthStatus(t); status != "healthy" {
+			t.Errorf("Expected status healthy after reload, got %q", status)
+		}
+	})
+}
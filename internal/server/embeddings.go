@@ -0,0 +1,77 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orchestre-dev/ccproxy/internal/pipeline"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// handleEmbeddings proxies OpenAI embeddings requests to the embeddings route
+func (s *Server) handleEmbeddings(c *gin.Context) {
+	// Increment request counter
+	atomic.AddInt64(&s.requestsServed, 1)
+
+	var rawBody interface{}
+	if err := c.ShouldBindJSON(&rawBody); err != nil {
+		BindError(c, err)
+		return
+	}
+
+	bodyMap, ok := rawBody.(map[string]interface{})
+	if !ok {
+		BadRequest(c, "Invalid request format")
+		return
+	}
+
+	switch input := bodyMap["input"].(type) {
+	case string:
+	case []interface{}:
+		if len(input) == 0 {
+			BadRequest(c, "Field 'input' must not be empty")
+			return
+		}
+	case nil:
+		BadRequest(c, "Field 'input' is required")
+		return
+	default:
+		BadRequest(c, "Field 'input' must be a string or an array")
+		return
+	}
+
+	reqCtx := &pipeline.RequestContext{
+		Body:     bodyMap,
+		Headers:  extractHeaders(c),
+		Metadata: make(map[string]interface{}),
+	}
+
+	// Pass the authenticated key along for per-key model restrictions
+	if apiKeyHash := c.GetString("api_key_hash"); apiKeyHash != "" {
+		reqCtx.Metadata["api_key_hash"] = apiKeyHash
+	}
+
+	respCtx, err := s.pipeline.ProcessEmbeddings(c.Request.Context(), reqCtx)
+	if err != nil {
+		utils.GetLogger().Errorf("Embeddings request failed: %v", err)
+		writePipelineError(c, err)
+		return
+	}
+
+	utils.GetLogger().Infof("Routed embeddings to provider=%s, model=%s, tokens=%d, upstream_request_id=%s",
+		respCtx.Provider, respCtx.Model, respCtx.TokenCount, respCtx.UpstreamID)
+
+	// Record the request details for the access log
+	inputTokens := respCtx.InputTokens
+	if inputTokens == 0 {
+		inputTokens = respCtx.TokenCount
+	}
+	c.Set("provider", respCtx.Provider)
+	c.Set("model", respCtx.Model)
+	c.Set("upstream_request_id", respCtx.UpstreamID)
+	c.Set("tokens_in", inputTokens)
+
+	if err := pipeline.CopyResponse(c.Writer, respCtx.Response); err != nil {
+		utils.GetLogger().Errorf("Response copy failed: %v", err)
+	}
+}
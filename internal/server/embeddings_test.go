@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleEmbeddings(t *testing.T) {
+	server := createTestServer(t)
+	router := server.GetRouter()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantType   string
+	}{
+		{"MissingInput", `{"model":"text-embedding-3-small"}`, http.StatusBadRequest, string(ErrorTypeInvalidRequest)},
+		{"EmptyInput", `{"model":"text-embedding-3-small","input":[]}`, http.StatusBadRequest, string(ErrorTypeInvalidRequest)},
+		{"InvalidInput", `{"model":"text-embedding-3-small","input":42}`, http.StatusBadRequest, string(ErrorTypeInvalidRequest)},
+		{"NoEmbeddingsRoute", `{"model":"text-embedding-3-small","input":"hello"}`, http.StatusNotFound, "not_found_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/v1/embeddings", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer test-api-key")
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			var response struct {
+				Error struct {
+					Type string `json:"type"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Error.Type != tt.wantType {
+				t.Errorf("Expected error type %s, got %s", tt.wantType, response.Error.Type)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package server
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
 )
 
 // ErrorType represents the type of error
@@ -94,3 +96,22 @@ func ProviderError(c *gin.Context, message string) {
 func RateLimitError(c *gin.Context, message string) {
 	RespondWithError(c, http.StatusTooManyRequests, ErrorTypeRateLimit, message)
 }
+
+// RequestTooLarge aborts with a 413 for a body over the configured size limit
+func RequestTooLarge(c *gin.Context, limit int64) {
+	err := ccerrors.Newf(ccerrors.ErrorTypeBadRequest, "Request body exceeds the %d byte limit", limit).
+		WithCode("REQUEST_TOO_LARGE").
+		WithDetails(map[string]interface{}{"limit": limit})
+	err.StatusCode = http.StatusRequestEntityTooLarge
+	ccerrors.HandleError(c, err)
+}
+
+// BindError sends a 413 when a body read hit the size limit and a 400 otherwise
+func BindError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		RequestTooLarge(c, maxBytesErr.Limit)
+		return
+	}
+	BadRequest(c, err.Error())
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -273,6 +274,204 @@ func TestHandleStatusWithVersion(t *testing.T) {
 	}
 }
 
+func TestHandleStatusCircuitBreakers(t *testing.T) {
+	cfg := *createTestServer(t).config
+	cfg.Performance.CircuitBreakerEnabled = true
+
+	server, err := New(&cfg)
+	if err != nil {
+		t.Fatalf("Failed to create test server: %v", err)
+	}
+	router := server.GetRouter()
+
+	getBreaker := func(t *testing.T) map[string]interface{} {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		breakers, ok := response["circuit_breakers"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("Expected circuit_breakers in response, got %v", response["circuit_breakers"])
+		}
+		breaker, ok := breakers["openai"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("Expected breaker for openai, got %v", breakers)
+		}
+		return breaker
+	}
+
+	t.Run("ClosedBeforeTraffic", func(t *testing.T) {
+		breaker := getBreaker(t)
+		if breaker["state"] != "closed" {
+			t.Errorf("Expected closed breaker, got %v", breaker["state"])
+		}
+	})
+
+	t.Run("StaysClosedBelowThreshold", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			server.performance.RecordProviderError("openai", false)
+		}
+
+		breaker := getBreaker(t)
+		if breaker["state"] != "closed" {
+			t.Errorf("Expected closed breaker, got %v", breaker["state"])
+		}
+		if breaker["failures"] != float64(4) {
+			t.Errorf("Expected 4 failures, got %v", breaker["failures"])
+		}
+		if breaker["consecutive_failures"] != float64(4) {
+			t.Errorf("Expected 4 consecutive failures, got %v", breaker["consecutive_failures"])
+		}
+		if breaker["time_until_half_open_ms"] != float64(0) {
+			t.Errorf("Expected no half-open countdown while closed, got %v", breaker["time_until_half_open_ms"])
+		}
+	})
+
+	t.Run("OpensAtThreshold", func(t *testing.T) {
+		server.performance.RecordProviderError("openai", false)
+
+		breaker := getBreaker(t)
+		if breaker["state"] != "open" {
+			t.Errorf("Expected open breaker, got %v", breaker["state"])
+		}
+		if breaker["failures"] != float64(5) {
+			t.Errorf("Expected 5 failures, got %v", breaker["failures"])
+		}
+		remaining, _ := breaker["time_until_half_open_ms"].(float64)
+		if remaining <= 0 || remaining > float64((30*time.Second).Milliseconds()) {
+			t.Errorf("Expected half-open countdown within open duration, got %v", remaining)
+		}
+	})
+}
+
+func TestHandleStatusCircuitBreakerTraffic(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":{"message":"upstream down"}}`))
+	}))
+	defer upstream.Close()
+
+	server := createMockServer(t, func(cfg *config.Config) {
+		cfg.Performance.CircuitBreakerEnabled = true
+		cfg.Providers = []config.Provider{{Name: "openai", APIBaseURL: upstream.URL, APIKey: "test-key", Enabled: true}}
+		cfg.Routes = map[string]config.Route{"default": {Provider: "openai", Model: "gpt-4o"}}
+	})
+	router := server.GetRouter()
+
+	state := func(t *testing.T) interface{} {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+		var response struct {
+			CircuitBreakers map[string]map[string]interface{} `json:"circuit_breakers"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return response.CircuitBreakers["openai"]["state"]
+	}
+
+	// Provider errors on routed requests reach the breaker /status reports
+	for i := 0; i < 4; i++ {
+		if w := postMessage(router, nil); w.Code < http.StatusInternalServerError {
+			t.Fatalf("Expected a provider error, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if got := state(t); got != "closed" {
+		t.Errorf("Expected closed breaker below the threshold, got %v", got)
+	}
+
+	postMessage(router, nil)
+	if got := state(t); got != "open" {
+		t.Errorf("Expected the breaker to open after repeated provider errors, got %v", got)
+	}
+}
+
+func TestHandleStatusWithoutCircuitBreakers(t *testing.T) {
+	server := createTestServer(t)
+	router := server.GetRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if _, exists := response["circuit_breakers"]; exists {
+		t.Error("Expected no circuit_breakers field when breakers are disabled")
+	}
+}
+
+func TestHandleStatusRetryBudget(t *testing.T) {
+	getStatus := func(t *testing.T, cfg *config.Config) map[string]interface{} {
+		t.Helper()
+		server, err := New(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create test server: %v", err)
+		}
+		w := httptest.NewRecorder()
+		server.GetRouter().ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return response
+	}
+
+	cfg := *createTestServer(t).config
+	if _, exists := getStatus(t, &cfg)["retry_budget"]; exists {
+		t.Error("Expected no retry_budget field without a budget")
+	}
+
+	cfg.Retry = config.RetryConfig{BudgetRatio: 0.1, BudgetBurst: 5}
+	budget, ok := getStatus(t, &cfg)["retry_budget"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected retry_budget in response")
+	}
+	if budget["ratio"] != 0.1 || budget["capacity"] != float64(5) || budget["available"] != float64(5) || budget["exhausted"] != false {
+		t.Errorf("Expected a full budget, got %v", budget)
+	}
+}
+
+func TestHandleStatusBudget(t *testing.T) {
+	server := createTestServer(t)
+	w := httptest.NewRecorder()
+	server.GetRouter().ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if strings.Contains(w.Body.String(), `"budget"`) {
+		t.Error("Expected no budget field without a budget")
+	}
+
+	cfg := *server.config
+	cfg.Budget = config.BudgetConfig{MaxTokens: 5000}
+	budgetServer, err := New(&cfg)
+	if err != nil {
+		t.Fatalf("Failed to create test server: %v", err)
+	}
+	w = httptest.NewRecorder()
+	budgetServer.GetRouter().ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	budget, ok := response["budget"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected budget in response")
+	}
+	if budget["max_tokens"] != float64(5000) || budget["tokens"] != float64(0) || budget["window"] != "24h0m0s" || budget["exceeded"] != false {
+		t.Errorf("Expected an unused budget, got %v", budget)
+	}
+}
+
 func TestIsHealthRequestAuthenticated(t *testing.T) {
 	server := createTestServer(t)
 
@@ -436,6 +635,12 @@ func TestExtractHeaders(t *testing.T) {
 	if headers["Authorization"] != "Bearer token" {
 		t.Errorf("Expected Authorization header 'Bearer token', got %s", headers["Authorization"])
 	}
+
+	// Headers used by routing rules are extracted under their canonical name
+	headers = extractHeaders(c, "x-custom-header")
+	if headers["X-Custom-Header"] != "custom-value" {
+		t.Errorf("Expected routing header to be extracted, got %v", headers)
+	}
 }
 
 func TestServerRouteSetup(t *testing.T) {
@@ -1,13 +1,19 @@
 package server
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
 	"github.com/orchestre-dev/ccproxy/internal/pipeline"
+	modelrouter "github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/tracing"
 	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
 
@@ -56,51 +62,21 @@ func (s *Server) handleMessages(c *gin.Context) {
 	// Parse raw body for pipeline processing
 	var rawBody interface{}
 	if err := c.ShouldBindJSON(&rawBody); err != nil {
-		BadRequest(c, err.Error())
+		BindError(c, err)
 		return
 	}
 
-	// Validate request structure
-	bodyMap, ok := rawBody.(map[string]interface{})
-	if !ok {
-		BadRequest(c, "Invalid request format")
+	// Reject malformed requests before routing
+	if err := validateMessagesRequest(rawBody); err != nil {
+		ccerrors.HandleError(c, err)
 		return
 	}
+	bodyMap := rawBody.(map[string]interface{})
 
-	// Check required fields
-	if _, hasModel := bodyMap["model"]; !hasModel {
-		BadRequest(c, "Field 'model' is required")
-		return
-	}
-
-	messages, hasMessages := bodyMap["messages"]
-	if !hasMessages {
-		BadRequest(c, "Field 'messages' is required")
-		return
-	}
-
-	// Validate messages array
-	messagesArray, ok := messages.([]interface{})
-	if !ok || len(messagesArray) == 0 {
-		BadRequest(c, "Field 'messages' must be a non-empty array")
-		return
-	}
-
-	// Validate each message
-	for _, msg := range messagesArray {
-		msgMap, ok := msg.(map[string]interface{})
-		if !ok {
-			BadRequest(c, "Invalid message format")
-			return
-		}
-
-		if _, hasRole := msgMap["role"]; !hasRole {
-			BadRequest(c, "Message missing required field 'role'")
-			return
-		}
-
-		if _, hasContent := msgMap["content"]; !hasContent {
-			BadRequest(c, "Message missing required field 'content'")
+	for i, msg := range bodyMap["messages"].([]interface{}) {
+		content := msg.(map[string]interface{})["content"]
+		if code, err := s.checkMessageLimits(i, content); err != nil {
+			RespondWithErrorCode(c, http.StatusRequestEntityTooLarge, ErrorTypeInvalidRequest, err.Error(), code)
 			return
 		}
 	}
@@ -111,52 +87,69 @@ func (s *Server) handleMessages(c *gin.Context) {
 		isStreaming = stream
 	}
 
+	c.Set("streamed", isStreaming)
+
+	// Keep the request for ccproxy replay once it has been answered
+	defer s.startStoringRequest(c, rawBody, isStreaming)()
+
 	// Create request context
 	reqCtx := &pipeline.RequestContext{
 		Body:        rawBody,
-		Headers:     extractHeaders(c),
+		Headers:     extractHeaders(c, s.routingHeaders()...),
 		IsStreaming: isStreaming,
 		Metadata:    make(map[string]interface{}),
 	}
 
-	// Process through pipeline
-	ctx := context.Background()
+	// Keep the model the client asked for, which routing rewrites, for
+	// model masking
+	if requestedModel := c.GetString("requested_model"); requestedModel != "" {
+		reqCtx.Metadata[pipeline.RequestedModelKey] = requestedModel
+	}
+
+	// Pass the authenticated key along for per-key model restrictions
+	if apiKeyHash := c.GetString("api_key_hash"); apiKeyHash != "" {
+		reqCtx.Metadata["api_key_hash"] = apiKeyHash
+	}
+
+	// Process through pipeline, tied to the client connection so a
+	// disconnect cancels the upstream request and stream
+	ctx := c.Request.Context()
 	respCtx, err := s.pipeline.ProcessRequest(ctx, reqCtx)
 	if err != nil {
 		utils.GetLogger().Errorf("Pipeline processing failed: %v", err)
-
-		// Return appropriate error response
-		statusCode := http.StatusInternalServerError
-		errorType := "api_error"
-
-		// Check for specific error types
-		if strings.Contains(err.Error(), "connection refused") ||
-			strings.Contains(err.Error(), "provider request failed") {
-			statusCode = http.StatusBadGateway
-			errorType = "provider_error"
-		}
-
-		errResp := pipeline.NewErrorResponse(
-			err.Error(),
-			errorType,
-			"pipeline_error",
-		)
-		pipeline.WriteErrorResponse(c.Writer, statusCode, errResp)
+		writePipelineError(c, err)
 		return
 	}
 
 	// Log routing decision
-	utils.GetLogger().Infof("Routed to provider=%s, model=%s, tokens=%d, strategy=%s",
-		respCtx.Provider, respCtx.Model, respCtx.TokenCount, respCtx.RoutingStrategy)
+	utils.GetLogger().Infof("Routed to provider=%s, model=%s, tokens=%d, tool_roundtrips=%d, strategy=%s, upstream_request_id=%s",
+		respCtx.Provider, respCtx.Model, respCtx.TokenCount, respCtx.ToolRoundtrips, respCtx.RoutingStrategy, respCtx.UpstreamID)
+
+	// Record the request details for the access log
+	c.Set("provider", respCtx.Provider)
+	c.Set("model", respCtx.Model)
+	c.Set("upstream_request_id", respCtx.UpstreamID)
+	setUsage(c, respCtx)
+	if respCtx.TraceID != "" {
+		c.Set("trace_id", respCtx.TraceID)
+	}
 
-	// Handle response based on streaming
-	if isStreaming {
+	// Handle response based on streaming. Provider errors are plain JSON
+	// even for streaming requests.
+	if isStreaming && respCtx.Response.StatusCode < http.StatusBadRequest {
 		// Stream the response with transformation support
 		if err := s.pipeline.StreamResponse(ctx, c.Writer, respCtx); err != nil {
 			utils.GetLogger().Errorf("Streaming failed: %v", err)
-			// Try to send error event if possible
-			pipeline.HandleStreamingError(c.Writer, err)
+			// Try to send error event unless the stream already ended with one
+			if !errors.Is(err, pipeline.ErrStreamAborted) {
+				pipeline.HandleStreamingError(c.Writer, err)
+			}
+		}
+		if respCtx.FirstTokenLatency > 0 {
+			c.Set("first_token_ms", respCtx.FirstTokenLatency.Milliseconds())
 		}
+		// Streams report their usage in their final events
+		setUsage(c, respCtx)
 	} else {
 		// Copy non-streaming response
 		if err := pipeline.CopyResponse(c.Writer, respCtx.Response); err != nil {
@@ -165,8 +158,104 @@ func (s *Server) handleMessages(c *gin.Context) {
 	}
 }
 
-// extractHeaders extracts relevant headers from the request
-func extractHeaders(c *gin.Context) map[string]string {
+// setUsage records a response's token usage for the access log. Input tokens
+// fall back to the request's estimate when the provider reports none.
+func setUsage(c *gin.Context, respCtx *pipeline.ResponseContext) {
+	inputTokens := respCtx.InputTokens
+	if inputTokens == 0 {
+		inputTokens = respCtx.TokenCount
+	}
+	c.Set("tokens_in", inputTokens)
+	c.Set("tokens_out", respCtx.OutputTokens)
+	c.Set("tokens_cache_read", respCtx.CacheReadTokens)
+	c.Set("tokens_cache_creation", respCtx.CacheCreationTokens)
+}
+
+// writePipelineError writes a pipeline error with the status code and error
+// type that match its cause
+func writePipelineError(c *gin.Context, err error) {
+	if totalTimeoutExceeded(c) {
+		writeTotalTimeout(c)
+		return
+	}
+
+	statusCode := http.StatusInternalServerError
+	errorType := "api_error"
+
+	// Check for specific error types
+	var ccErr *ccerrors.CCProxyError
+	if errors.As(err, &ccErr) && ccErr.Type == ccerrors.ErrorTypeForbidden {
+		statusCode = http.StatusForbidden
+		errorType = string(ErrorTypePermission)
+	} else if errors.As(err, &ccErr) && (ccErr.Type == ccerrors.ErrorTypeResourceExhausted ||
+		ccErr.Type == ccerrors.ErrorTypeTransformError || ccErr.Type == ccerrors.ErrorTypeServiceUnavailable) {
+		statusCode = ccErr.StatusCode
+		errorType = string(ccErr.Type)
+	} else if errors.As(err, &ccErr) && ccErr.Type == ccerrors.ErrorTypeRateLimitError {
+		statusCode = http.StatusTooManyRequests
+		errorType = string(ErrorTypeRateLimit)
+		if ccErr.RetryAfter != nil {
+			c.Header("Retry-After", strconv.Itoa(int(ccErr.RetryAfter.Seconds())))
+		}
+	} else if errors.As(err, &ccErr) && ccErr.Type == ccerrors.ErrorTypeValidationError {
+		statusCode = http.StatusBadRequest
+		errorType = string(ccErr.Type)
+	} else if errors.As(err, &ccErr) && ccErr.Type == ccerrors.ErrorTypeBadRequest {
+		statusCode = http.StatusBadRequest
+		errorType = "invalid_request_error"
+	} else if errors.As(err, &ccErr) && ccErr.Type == ccerrors.ErrorTypeNotFound {
+		statusCode = http.StatusNotFound
+		errorType = "not_found_error"
+	} else if strings.Contains(err.Error(), "connection refused") ||
+		strings.Contains(err.Error(), "provider request failed") {
+		statusCode = http.StatusBadGateway
+		errorType = "provider_error"
+	}
+
+	errResp := pipeline.NewErrorResponse(
+		err.Error(),
+		errorType,
+		"pipeline_error",
+	)
+	pipeline.WriteErrorResponse(c.Writer, statusCode, errResp)
+}
+
+// checkMessageLimits enforces the configured per-message caps on content size
+// and content block count, returning an error code and message on violation
+func (s *Server) checkMessageLimits(index int, content interface{}) (string, error) {
+	perf := s.currentConfig().Performance
+
+	if blocks, ok := content.([]interface{}); ok && perf.MaxContentBlocks > 0 && len(blocks) > perf.MaxContentBlocks {
+		return "too_many_content_blocks", fmt.Errorf("message %d has %d content blocks, exceeding the limit of %d",
+			index, len(blocks), perf.MaxContentBlocks)
+	}
+
+	if perf.MaxMessageBytes > 0 {
+		size := messageContentSize(content)
+		if size > perf.MaxMessageBytes {
+			return "message_too_large", fmt.Errorf("message %d content is %d bytes, exceeding the limit of %d bytes",
+				index, size, perf.MaxMessageBytes)
+		}
+	}
+
+	return "", nil
+}
+
+// messageContentSize returns the size in bytes of a message's content
+func messageContentSize(content interface{}) int64 {
+	if text, ok := content.(string); ok {
+		return int64(len(text))
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// extractHeaders extracts relevant headers from the request, plus any
+// extra headers such as those matched by header routing rules
+func extractHeaders(c *gin.Context, extra ...string) map[string]string {
 	headers := make(map[string]string)
 
 	// Extract relevant headers
@@ -176,6 +265,10 @@ func extractHeaders(c *gin.Context) map[string]string {
 		"Content-Type",
 		"Accept",
 		"User-Agent",
+		pipeline.IdempotencyHeader,
+		pipeline.SessionHeader,
+		tracing.TraceparentHeader,
+		tracing.TracestateHeader,
 	}
 
 	for _, header := range relevantHeaders {
@@ -183,6 +276,31 @@ func extractHeaders(c *gin.Context) map[string]string {
 			headers[header] = value
 		}
 	}
+	for _, header := range extra {
+		if value := c.GetHeader(header); value != "" {
+			headers[http.CanonicalHeaderKey(header)] = value
+		}
+	}
 
+	// Forward the id assigned by the request id middleware, which may have
+	// generated it rather than read it from the client
+	if requestID := c.GetString("request_id"); requestID != "" {
+		headers[pipeline.RequestIDHeader] = requestID
+	}
+
+	return headers
+}
+
+// routingHeaders lists the inbound headers used by header routing rules and,
+// when clients may force a route, the route header
+func (s *Server) routingHeaders() []string {
+	cfg := s.currentConfig()
+	headers := make([]string, 0, len(cfg.HeaderRules)+1)
+	for _, rule := range cfg.HeaderRules {
+		headers = append(headers, rule.Header)
+	}
+	if cfg.AllowRouteHeader {
+		headers = append(headers, modelrouter.RouteHeader)
+	}
 	return headers
 }
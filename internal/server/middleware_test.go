@@ -1,13 +1,19 @@
 package server
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/security"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 )
 
 func init() {
@@ -156,6 +162,68 @@ func TestAuthMiddleware(t *testing.T) {
 	})
 }
 
+func TestInboundKeyMiddleware(t *testing.T) {
+	middleware := inboundKeyMiddleware(newAPIKeyAllowlist("key-one", "key-two"))
+
+	router := gin.New()
+	router.Use(middleware)
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "success"})
+	})
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"BearerFirstKey", "/test", "10.0.0.5:1234", map[string]string{"Authorization": "Bearer key-one"}, http.StatusOK},
+		{"XAPIKeySecondKey", "/test", "10.0.0.5:1234", map[string]string{"x-api-key": "key-two"}, http.StatusOK},
+		{"WrongKey", "/test", "127.0.0.1:1234", map[string]string{"x-api-key": "key-three"}, http.StatusUnauthorized},
+		{"KeyPrefix", "/test", "127.0.0.1:1234", map[string]string{"Authorization": "Bearer key-on"}, http.StatusUnauthorized},
+		{"NonBearerScheme", "/test", "127.0.0.1:1234", map[string]string{"Authorization": "Basic key-one"}, http.StatusUnauthorized},
+		{"MissingKeyFromLocalhost", "/test", "127.0.0.1:1234", nil, http.StatusUnauthorized},
+		{"HealthIsPublic", "/health", "10.0.0.5:1234", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAllowlist(t *testing.T) {
+	allowlist := newAPIKeyAllowlist("", "secret")
+
+	if len(allowlist) != 1 {
+		t.Errorf("Expected empty keys to be skipped, got %d entries", len(allowlist))
+	}
+	if !allowlist.contains("secret") {
+		t.Error("Expected configured key to be allowed")
+	}
+	if allowlist.contains("") || allowlist.contains("secret ") {
+		t.Error("Expected empty and near-miss keys to be rejected")
+	}
+	if newAPIKeyAllowlist().contains("secret") {
+		t.Error("Expected empty allowlist to reject every key")
+	}
+}
+
 func TestIsLocalhost(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -187,49 +255,157 @@ func TestIsLocalhost(t *testing.T) {
 }
 
 func TestCORSMiddleware(t *testing.T) {
-	middleware := corsMiddleware()
-
-	router := gin.New()
-	router.Use(middleware)
-	router.GET("/test", func(c *gin.Context) {
-		c.JSON(200, gin.H{"message": "success"})
-	})
-
-	t.Run("NormalRequest", func(t *testing.T) {
+	preflight := func(t *testing.T, server *Server, origin string) *httptest.ResponseRecorder {
+		t.Helper()
 		w := httptest.NewRecorder()
-		req := httptest.NewRequest("GET", "/test", nil)
-		router.ServeHTTP(w, req)
+		req := httptest.NewRequest("OPTIONS", "/v1/messages", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		server.GetRouter().ServeHTTP(w, req)
+		return w
+	}
 
-		if w.Code != http.StatusOK {
-			t.Errorf("Expected status 200, got %d", w.Code)
+	t.Run("NoOriginsByDefault", func(t *testing.T) {
+		server := createTestServer(t)
+		w := preflight(t, server, "https://evil.example")
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status 204 for OPTIONS request, got %d", w.Code)
 		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Expected no Access-Control-Allow-Origin header, got %q", got)
+		}
+	})
 
-		// Check CORS headers
-		if w.Header().Get("Access-Control-Allow-Origin") != "*" {
-			t.Error("Expected Access-Control-Allow-Origin header")
+	t.Run("AllowedOrigin", func(t *testing.T) {
+		cfg := *createTestServer(t).config
+		cfg.Security.AllowedOrigins = []string{"https://app.example"}
+		server, err := New(&cfg)
+		if err != nil {
+			t.Fatalf("Failed to create test server: %v", err)
 		}
 
-		if w.Header().Get("Access-Control-Allow-Methods") == "" {
-			t.Error("Expected Access-Control-Allow-Methods header")
+		w := preflight(t, server, "https://app.example")
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+			t.Errorf("Expected the allowed origin to be echoed, got %q", got)
+		}
+		if w.Header().Get("Access-Control-Allow-Methods") == "" || w.Header().Get("Access-Control-Allow-Headers") == "" {
+			t.Error("Expected Access-Control-Allow-Methods and Access-Control-Allow-Headers headers")
+		}
+		if w.Header().Get("Vary") != "Origin" {
+			t.Errorf("Expected Vary: Origin, got %q", w.Header().Get("Vary"))
 		}
 
-		if w.Header().Get("Access-Control-Allow-Headers") == "" {
-			t.Error("Expected Access-Control-Allow-Headers header")
+		w = preflight(t, server, "https://other.example")
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Expected no Access-Control-Allow-Origin header for another origin, got %q", got)
 		}
 	})
+}
+
+func TestPathAccessMiddleware(t *testing.T) {
+	newRouter := func(t *testing.T, access config.PathAccessConfig) *gin.Engine {
+		middleware, err := pathAccessMiddleware(access)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		router := gin.New()
+		router.Use(middleware)
+		for _, path := range []string{"/health", "/v1/messages", "/v10", "/admin/config", "/providers"} {
+			router.GET(path, func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"message": "success"})
+			})
+		}
+		return router
+	}
 
-	t.Run("PreflightRequest", func(t *testing.T) {
+	request := func(router *gin.Engine, path, remoteAddr string) *httptest.ResponseRecorder {
 		w := httptest.NewRecorder()
-		req := httptest.NewRequest("OPTIONS", "/test", nil)
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
 		router.ServeHTTP(w, req)
+		return w
+	}
 
-		if w.Code != http.StatusNoContent {
-			t.Errorf("Expected status 204 for OPTIONS request, got %d", w.Code)
+	t.Run("AllowlistWithLocalhostFallback", func(t *testing.T) {
+		router := newRouter(t, config.PathAccessConfig{Allow: []config.PathRule{
+			{Prefix: "/v1/messages"},
+			{Prefix: "/health"},
+			{Prefix: "/", AllowFrom: []string{"localhost"}},
+		}})
+		unknown := request(router, "/missing", "127.0.0.1:12345")
+
+		tests := []struct {
+			path       string
+			remoteAddr string
+			want       int
+		}{
+			{"/v1/messages", "203.0.113.7:12345", http.StatusOK},
+			{"/health", "203.0.113.7:12345", http.StatusOK},
+			{"/v10", "203.0.113.7:12345", http.StatusNotFound},
+			{"/admin/config", "203.0.113.7:12345", http.StatusNotFound},
+			{"/v1/../admin/config", "203.0.113.7:12345", http.StatusNotFound},
+			{"/admin/config", "127.0.0.1:12345", http.StatusOK},
+			{"/admin/config", "[::1]:12345", http.StatusOK},
+		}
+		for _, tt := range tests {
+			w := request(router, tt.path, tt.remoteAddr)
+			if w.Code != tt.want {
+				t.Errorf("%s from %s: expected status %d, got %d", tt.path, tt.remoteAddr, tt.want, w.Code)
+			}
+			// A refused path is indistinguishable from an unknown one
+			if w.Code == http.StatusNotFound && w.Body.String() != unknown.Body.String() {
+				t.Errorf("%s: expected body %q, got %q", tt.path, unknown.Body.String(), w.Body.String())
+			}
 		}
+	})
+
+	t.Run("DenylistWithSources", func(t *testing.T) {
+		router := newRouter(t, config.PathAccessConfig{Deny: []config.PathRule{
+			{Prefix: "/admin", AllowFrom: []string{"localhost", "10.0.0.0/8"}},
+			{Prefix: "/providers"},
+		}})
+
+		tests := []struct {
+			path       string
+			remoteAddr string
+			want       int
+		}{
+			{"/v1/messages", "203.0.113.7:12345", http.StatusOK},
+			{"/admin/config", "203.0.113.7:12345", http.StatusNotFound},
+			{"/admin/config", "10.1.2.3:12345", http.StatusOK},
+			{"/providers", "127.0.0.1:12345", http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			if w := request(router, tt.path, tt.remoteAddr); w.Code != tt.want {
+				t.Errorf("%s from %s: expected status %d, got %d", tt.path, tt.remoteAddr, tt.want, w.Code)
+			}
+		}
+	})
+
+	t.Run("IgnoresForwardedFor", func(t *testing.T) {
+		router := newRouter(t, config.PathAccessConfig{Allow: []config.PathRule{
+			{Prefix: "/admin", AllowFrom: []string{"localhost"}},
+		}})
+
+		for _, header := range []string{"X-Forwarded-For", "X-Real-IP"} {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/admin/config", nil)
+			req.RemoteAddr = "203.0.113.7:12345"
+			req.Header.Set(header, "127.0.0.1")
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("Expected a spoofed %s header to be ignored, got status %d", header, w.Code)
+			}
+		}
+	})
 
-		// Check CORS headers
-		if w.Header().Get("Access-Control-Allow-Origin") != "*" {
-			t.Error("Expected Access-Control-Allow-Origin header for preflight")
+	t.Run("InvalidSource", func(t *testing.T) {
+		_, err := pathAccessMiddleware(config.PathAccessConfig{Allow: []config.PathRule{
+			{Prefix: "/admin", AllowFrom: []string{"not-an-ip"}},
+		}})
+		if err == nil {
+			t.Error("Expected an error for an invalid source")
 		}
 	})
 }
@@ -321,10 +497,56 @@ func TestRequestSizeLimitMiddleware(t *testing.T) {
 			t.Errorf("Expected status 200 when no content-length, got %d", w.Code)
 		}
 	})
+
+	t.Run("OversizedBodyWithoutContentLength", func(t *testing.T) {
+		maxSize := int64(1024)
+		middleware := requestSizeLimitMiddleware(maxSize)
+
+		router := gin.New()
+		router.Use(middleware)
+		router.POST("/test", func(c *gin.Context) {
+			var body map[string]interface{}
+			if err := c.ShouldBindJSON(&body); err != nil {
+				BindError(c, err)
+				return
+			}
+			c.JSON(200, gin.H{"message": "success"})
+		})
+
+		total := 10 * 1024 * 1024
+		body := &countingReader{Reader: strings.NewReader(`{"message": "` + strings.Repeat("x", total) + `"}`)}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/test", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.ContentLength = -1
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413 for oversized chunked request, got %d", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "REQUEST_TOO_LARGE") {
+			t.Errorf("Expected REQUEST_TOO_LARGE code, got %s", w.Body.String())
+		}
+		if body.read > total/2 {
+			t.Errorf("Expected body to be rejected before buffering, read %d bytes", body.read)
+		}
+	})
+}
+
+// countingReader records how many bytes have been read
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
 }
 
 func TestLoggingMiddleware(t *testing.T) {
-	middleware := loggingMiddleware()
+	middleware := loggingMiddleware("text")
 
 	router := gin.New()
 	router.Use(middleware)
@@ -344,47 +566,203 @@ func TestLoggingMiddleware(t *testing.T) {
 	// Test that middleware doesn't crash (logging is hard to test without capturing output)
 }
 
-func TestPerformanceMiddleware(t *testing.T) {
-	// Create a mock server with performance monitoring
-	cfg := &config.Config{
-		Host: "127.0.0.1",
-		Port: 3456,
-		Performance: config.PerformanceConfig{
-			MetricsEnabled: true,
-		},
-		Routes: map[string]config.Route{
-			"default": {
-				Provider: "openai",
-				Model:    "gpt-4",
-			},
-		},
-	}
-
-	server, err := New(cfg)
-	if err != nil {
-		t.Fatalf("Failed to create server: %v", err)
-	}
-
-	middleware := server.performanceMiddleware()
+func TestLoggingMiddlewareJSONAccessLog(t *testing.T) {
+	hook := test.NewLocal(utils.GetLogger())
+	defer hook.Reset()
 
 	router := gin.New()
-	router.Use(middleware)
-	router.GET("/test", func(c *gin.Context) {
+	router.Use(security.RequestIDMiddleware())
+	router.Use(loggingMiddleware("json"))
+	router.POST("/v1/messages", func(c *gin.Context) {
 		c.Set("provider", "openai")
-		c.Set("model", "gpt-4")
-		c.JSON(200, gin.H{"message": "success"})
+		c.Set("model", "gpt-4o")
+		c.Set("tokens_in", 120)
+		c.Set("tokens_out", 45)
+		c.Set("streamed", true)
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
 
 	w := httptest.NewRecorder()
-	req := httptest.NewRequest("GET", "/test", nil)
+	req := httptest.NewRequest("POST", "/v1/messages", nil)
+	req.Header.Set("X-Request-ID", "req-123")
 	router.ServeHTTP(w, req)
 
+	if got := w.Header().Get("X-Request-ID"); got != "req-123" {
+		t.Errorf("Expected request ID to be echoed, got %q", got)
+	}
+
+	var fields logrus.Fields
+	for _, entry := range hook.AllEntries() {
+		if entry.Data["type"] == "access" {
+			fields = entry.Data
+		}
+		if entry.Data["type"] == "request" || entry.Data["type"] == "response" {
+			t.Errorf("Expected no text request/response records in json mode, got %v", entry.Data)
+		}
+	}
+	if fields == nil {
+		t.Fatal("Expected an access log record")
+	}
+
+	want := logrus.Fields{
+		"request_id":    "req-123",
+		"method":        "POST",
+		"path":          "/v1/messages",
+		"provider":      "openai",
+		"model":         "gpt-4o",
+		"status":        http.StatusOK,
+		"input_tokens":  120,
+		"output_tokens": 45,
+		"streamed":      true,
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, fields[key])
+		}
+	}
+	if _, ok := fields["duration_ms"].(int64); !ok {
+		t.Errorf("Expected duration_ms to be recorded, got %v", fields["duration_ms"])
+	}
+}
+
+func TestLoggingMiddlewareStreamedUsage(t *testing.T) {
+	const stream = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":12,\"output_tokens\":1,\"cache_read_input_tokens\":500,\"cache_creation_input_tokens\":200}}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":42}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(stream))
+	}))
+	defer upstream.Close()
+
+	hook := test.NewLocal(utils.GetLogger())
+	defer hook.Reset()
+
+	router := createMockServer(t, func(cfg *config.Config) {
+		cfg.Log = true
+		cfg.Logging.Format = "json"
+		cfg.Providers = []config.Provider{{Name: "anthropic", APIBaseURL: upstream.URL, APIKey: "test-key", Enabled: true}}
+		cfg.Routes = map[string]config.Route{"default": {Provider: "anthropic", Model: "claude-3-opus"}}
+	}).GetRouter()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(
+		`{"model":"claude-3-opus","stream":true,"messages":[{"role":"user","content":"Hello"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer test-api-key")
+	router.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", w.Code)
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var fields logrus.Fields
+	for _, entry := range hook.AllEntries() {
+		if entry.Data["type"] == "access" {
+			fields = entry.Data
+		}
+	}
+	if fields == nil {
+		t.Fatal("Expected an access log record")
+	}
+
+	// The usage comes from the stream's events, which arrive after the
+	// response has started
+	want := logrus.Fields{
+		"streamed":              true,
+		"input_tokens":          12,
+		"output_tokens":         42,
+		"cache_read_tokens":     500,
+		"cache_creation_tokens": 200,
 	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, fields[key])
+		}
+	}
+}
 
-	// Check that requests served counter increased
-	if server.requestsServed <= 0 {
-		t.Error("Expected requests served counter to increase")
+func TestTotalTimeoutMiddleware(t *testing.T) {
+	newRouter := func(timeout, streamingTimeout time.Duration, handler gin.HandlerFunc) *gin.Engine {
+		router := gin.New()
+		router.Use(requestSizeLimitMiddleware(64))
+		router.Use(totalTimeoutMiddleware(timeout, streamingTimeout))
+		router.POST("/test", handler)
+		return router
+	}
+	// waitForDeadline blocks until the request deadline passes
+	waitForDeadline := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(time.Second):
+			c.JSON(http.StatusOK, gin.H{"message": "success"})
+		}
+	}
+	post := func(router *gin.Engine, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/test", strings.NewReader(body)))
+		return w
 	}
+
+	t.Run("ExceededBeforeResponse", func(t *testing.T) {
+		w := post(newRouter(20*time.Millisecond, 0, waitForDeadline), `{"stream":false}`)
+		if w.Code != http.StatusGatewayTimeout {
+			t.Fatalf("Expected status 504, got %d", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"gateway_timeout"`) {
+			t.Errorf("Expected gateway_timeout error, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("PipelineErrorAfterDeadline", func(t *testing.T) {
+		router := newRouter(20*time.Millisecond, 0, func(c *gin.Context) {
+			<-c.Request.Context().Done()
+			writePipelineError(c, c.Request.Context().Err())
+		})
+		if w := post(router, `{}`); w.Code != http.StatusGatewayTimeout {
+			t.Errorf("Expected status 504, got %d", w.Code)
+		}
+	})
+
+	t.Run("StreamingExempt", func(t *testing.T) {
+		router := newRouter(20*time.Millisecond, 0, func(c *gin.Context) {
+			if _, hasDeadline := c.Request.Context().Deadline(); hasDeadline {
+				t.Error("Expected no deadline for a streaming request")
+			}
+			c.Status(http.StatusOK)
+		})
+		if w := post(router, `{"stream":true}`); w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("StreamingLimit", func(t *testing.T) {
+		w := post(newRouter(time.Minute, 20*time.Millisecond, waitForDeadline), `{"stream":true}`)
+		if w.Code != http.StatusGatewayTimeout {
+			t.Errorf("Expected status 504, got %d", w.Code)
+		}
+	})
+
+	t.Run("BodyLeftForHandler", func(t *testing.T) {
+		router := newRouter(time.Minute, 0, func(c *gin.Context) {
+			var body map[string]interface{}
+			if err := c.ShouldBindJSON(&body); err != nil {
+				BindError(c, err)
+				return
+			}
+			c.JSON(http.StatusOK, body)
+		})
+
+		if w := post(router, `{"model":"m"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"model":"m"`) {
+			t.Errorf("Expected the body to be echoed, got %d %s", w.Code, w.Body.String())
+		}
+		// Without a Content-Length the size limit is hit while peeking
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(`{"model":"`+strings.Repeat("m", 100)+`"}`))
+		req.ContentLength = -1
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413 for an oversized body, got %d", w.Code)
+		}
+	})
 }
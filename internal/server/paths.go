@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orchestre-dev/ccproxy/internal/config"
+)
+
+// pathRule is a config.PathRule with its sources parsed
+type pathRule struct {
+	prefix  string
+	sources []*net.IPNet
+}
+
+// newPathRules parses the sources of each rule
+func newPathRules(rules []config.PathRule) ([]pathRule, error) {
+	parsed := make([]pathRule, 0, len(rules))
+	for _, rule := range rules {
+		r := pathRule{prefix: strings.TrimSuffix(rule.Prefix, "/")}
+		for _, source := range rule.AllowFrom {
+			nets, err := config.ParseSource(source)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allow_from entry %q: %w", source, err)
+			}
+			r.sources = append(r.sources, nets...)
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// matches reports whether p is the prefix or below it
+func (r pathRule) matches(p string) bool {
+	return p == r.prefix || strings.HasPrefix(p, r.prefix+"/")
+}
+
+// fromSource reports whether ip is in one of the rule's sources
+func (r pathRule) fromSource(ip net.IP) bool {
+	for _, source := range r.sources {
+		if ip != nil && source.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathAccessMiddleware refuses paths the access rules do not expose. Refused
+// requests get the same 404 as unknown paths, so they do not reveal which
+// endpoints exist.
+func pathAccessMiddleware(access config.PathAccessConfig) (gin.HandlerFunc, error) {
+	allow, err := newPathRules(access.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid paths.allow rule: %w", err)
+	}
+	deny, err := newPathRules(access.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid paths.deny rule: %w", err)
+	}
+
+	return func(c *gin.Context) {
+		// Clean the path so dot segments and repeated slashes cannot slip
+		// past a prefix
+		p := path.Clean("/" + c.Request.URL.Path)
+		// Use the peer address, not ClientIP, which believes any
+		// X-Forwarded-For header
+		ip := net.ParseIP(c.RemoteIP())
+
+		if pathAllowed(allow, deny, p, ip) {
+			c.Next()
+			return
+		}
+
+		c.String(http.StatusNotFound, "404 page not found")
+		c.Abort()
+	}, nil
+}
+
+// pathAllowed applies the deny rules, then the allow rules when there are any
+func pathAllowed(allow, deny []pathRule, p string, ip net.IP) bool {
+	for _, rule := range deny {
+		if rule.matches(p) && !rule.fromSource(ip) {
+			return false
+		}
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	for _, rule := range allow {
+		if rule.matches(p) && (len(rule.sources) == 0 || rule.fromSource(ip)) {
+			return true
+		}
+	}
+	return false
+}
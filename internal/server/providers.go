@@ -27,7 +27,7 @@ type UpdateProviderRequest struct {
 
 // handleListProviders returns all configured providers
 func (s *Server) handleListProviders(c *gin.Context) {
-	providers := s.config.Providers
+	providers := s.currentConfig().Providers
 	Success(c, providers)
 }
 
@@ -35,12 +35,12 @@ func (s *Server) handleListProviders(c *gin.Context) {
 func (s *Server) handleCreateProvider(c *gin.Context) {
 	var req CreateProviderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		BadRequest(c, err.Error())
+		BindError(c, err)
 		return
 	}
 
 	// Validate provider name doesn't already exist
-	for _, p := range s.config.Providers {
+	for _, p := range s.currentConfig().Providers {
 		if p.Name == req.Name {
 			Conflict(c, fmt.Sprintf("Provider '%s' already exists", req.Name))
 			return
@@ -63,7 +63,7 @@ func (s *Server) handleCreateProvider(c *gin.Context) {
 	}
 
 	// Update server's config reference
-	s.config = s.configService.Get()
+	s.setConfig(s.configService.Get())
 
 	Created(c, provider)
 }
@@ -73,7 +73,7 @@ func (s *Server) handleGetProvider(c *gin.Context) {
 	name := c.Param("name")
 
 	// Find provider
-	for _, provider := range s.config.Providers {
+	for _, provider := range s.currentConfig().Providers {
 		if provider.Name == name {
 			Success(c, provider)
 			return
@@ -89,7 +89,7 @@ func (s *Server) handleUpdateProvider(c *gin.Context) {
 
 	var req UpdateProviderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		BadRequest(c, err.Error())
+		BindError(c, err)
 		return
 	}
 
@@ -162,7 +162,7 @@ func (s *Server) handleDeleteProvider(c *gin.Context) {
 	}
 
 	// Reload config in provider service
-	s.config = s.configService.Get()
+	s.setConfig(s.configService.Get())
 	if err := s.providerService.Initialize(); err != nil {
 		InternalServerError(c, fmt.Sprintf("Failed to reinitialize provider service: %v", err))
 		return
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/orchestre-dev/ccproxy/internal/pipeline"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// startStoringRequest snapshots a request body for `ccproxy replay` and
+// returns a function that stores it once the response has been written.
+// Requests without an id from the request ID middleware take the client's
+// X-Request-ID or a new one, returned so the client can quote it. Nothing is
+// stored unless replay.store_requests is enabled.
+func (s *Server) startStoringRequest(c *gin.Context, body interface{}, streaming bool) func() {
+	if s.requestStore == nil {
+		return func() {}
+	}
+
+	requestID := c.GetString("request_id")
+	if requestID == "" {
+		requestID = c.GetHeader(pipeline.RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		c.Set("request_id", requestID)
+		c.Header(pipeline.RequestIDHeader, requestID)
+	}
+
+	// The pipeline rewrites the body, so keep it as the client sent it, with
+	// the model from before routing so a replay is routed afresh
+	if requestedModel := c.GetString("requested_model"); requestedModel != "" {
+		if bodyMap, ok := body.(map[string]interface{}); ok {
+			original := make(map[string]interface{}, len(bodyMap))
+			for key, value := range bodyMap {
+				original[key] = value
+			}
+			original["model"] = requestedModel
+			body = original
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		utils.GetLogger().Warnf("Failed to encode request %s for replay: %v", requestID, err)
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		stored := &pipeline.StoredRequest{
+			ID:         requestID,
+			Timestamp:  start,
+			Path:       c.Request.URL.Path,
+			Streaming:  streaming,
+			Provider:   c.GetString("provider"),
+			Model:      c.GetString("model"),
+			StatusCode: c.Writer.Status(),
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		if err := s.requestStore.Save(stored, data); err != nil {
+			utils.GetLogger().Warnf("Failed to store request %s for replay: %v", requestID, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/pipeline"
+)
+
+func TestStoreRequestsForReplay(t *testing.T) {
+	newServer := func(t *testing.T, replay config.ReplayConfig) *Server {
+		t.Helper()
+		cfg := &config.Config{
+			APIKey:      "test-api-key",
+			Performance: config.PerformanceConfig{RequestTimeout: 30 * time.Second},
+			Providers: []config.Provider{
+				{Name: config.MockProviderName, Enabled: true, Models: []string{"mock-model"}},
+			},
+			Routes: map[string]config.Route{
+				"default": {Provider: config.MockProviderName, Model: "mock-model"},
+			},
+			Replay: replay,
+		}
+		server, err := New(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		return server
+	}
+
+	send := func(server *Server, requestID string) *httptest.ResponseRecorder {
+		body := `{"model":"mock-model","max_tokens":50,"messages":[{"role":"user","content":"password: hunter2"}]}`
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-api-key")
+		req.Header.Set("Content-Type", "application/json")
+		if requestID != "" {
+			req.Header.Set(pipeline.RequestIDHeader, requestID)
+		}
+		server.GetRouter().ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Stored", func(t *testing.T) {
+		dir := t.TempDir()
+		server := newServer(t, config.ReplayConfig{StoreRequests: true, Dir: dir})
+
+		w := send(server, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		requestID := w.Header().Get(pipeline.RequestIDHeader)
+		if requestID == "" {
+			t.Fatal("Expected the response to carry a request id")
+		}
+
+		store, err := pipeline.NewRequestStore(dir, false)
+		if err != nil {
+			t.Fatalf("Failed to open store: %v", err)
+		}
+		stored, err := store.Load(requestID)
+		if err != nil {
+			t.Fatalf("Expected the request to be stored: %v", err)
+		}
+		if stored.Path != "/v1/messages" || stored.StatusCode != http.StatusOK || stored.Provider != config.MockProviderName {
+			t.Errorf("Unexpected stored request: %+v", stored)
+		}
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(stored.Body, &body); err != nil {
+			t.Fatalf("Failed to parse stored body: %v", err)
+		}
+		if body["model"] != "mock-model" {
+			t.Errorf("Expected the body as the client sent it, got %v", body)
+		}
+	})
+
+	t.Run("Redacted", func(t *testing.T) {
+		dir := t.TempDir()
+		server := newServer(t, config.ReplayConfig{StoreRequests: true, Dir: dir, Redact: true})
+
+		if w := send(server, "client-id-1"); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		store, _ := pipeline.NewRequestStore(dir, false)
+		stored, err := store.Load("client-id-1")
+		if err != nil {
+			t.Fatalf("Expected the request to be stored under the client's id: %v", err)
+		}
+		if strings.Contains(string(stored.Body), "hunter2") {
+			t.Errorf("Expected the password to be redacted, got %s", stored.Body)
+		}
+	})
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		server := newServer(t, config.ReplayConfig{})
+		if server.requestStore != nil {
+			t.Error("Expected no request store unless enabled")
+		}
+		// Requests still get an id to quote, but nothing is stored
+		if w := send(server, ""); w.Header().Get(pipeline.RequestIDHeader) == "" {
+			t.Error("Expected a request id without logging or replay")
+		}
+	})
+}
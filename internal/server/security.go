@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/security"
+)
+
+// newSecurityManager creates the security manager for the security features
+// the configuration turns on, or returns nil when it turns on none
+func newSecurityManager(cfg *config.Config) (*security.Manager, error) {
+	rateLimit := cfg.Security.RateLimit
+	injection := cfg.Security.PromptInjection
+	audit := cfg.Security.Audit
+	if len(cfg.Security.ModelAccess) == 0 && !rateLimit.Enabled && !injection.Enabled && !audit.Enabled {
+		return nil, nil
+	}
+	keying := security.RateLimitKeyingIP
+	if rateLimit.Keying == config.RateLimitKeyingAPIKey {
+		keying = security.RateLimitKeyingAPIKey
+	}
+
+	manager, err := security.NewManager(&security.SecurityConfig{
+		Level:              security.SecurityLevelNone,
+		EnableRateLimiting: rateLimit.Enabled,
+		RateLimitKeying:    keying,
+		RateLimitPerMinute: rateLimit.RequestsPerMinute,
+		RateLimitMessage:   rateLimit.Message,
+
+		EnablePromptInjectionFilter: injection.Enabled,
+		BlockPromptInjection:        injection.Block,
+		PromptInjectionThreshold:    injection.Threshold,
+		PromptInjectionPatterns:     injectionPatterns(injection.Patterns),
+
+		EnableAuditLog:            audit.Enabled,
+		AuditLogPath:              audit.LogPath(),
+		AuditSink:                 audit.Sink,
+		AuditSyslogNetwork:        audit.SyslogNetwork,
+		AuditSyslogAddress:        audit.SyslogAddress,
+		AuditSyslogTag:            audit.SyslogTag,
+		AuditWebhookURL:           audit.WebhookURL,
+		AuditWebhookHeaders:       audit.WebhookHeaders,
+		AuditWebhookBatchSize:     audit.WebhookBatchSize,
+		AuditWebhookFlushInterval: audit.WebhookFlushInterval,
+		AuditWebhookMaxRetries:    audit.WebhookMaxRetries,
+		AuditMaxSizeMB:            audit.MaxSizeMB,
+		AuditMaxAgeDays:           audit.MaxAgeDays,
+		AuditMaxBackups:           audit.MaxBackups,
+		AuditCompress:             audit.Compress,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create security manager: %w", err)
+	}
+
+	// Register every inbound key, so model access checks find the
+	// restrictions of the key a request authenticated with
+	allowedModels := make(map[string][]string, len(cfg.Security.ModelAccess))
+	for _, rule := range cfg.Security.ModelAccess {
+		allowedModels[rule.Key] = rule.Models
+	}
+	for _, key := range append([]string{cfg.APIKey}, cfg.InboundAPIKeys...) {
+		if key != "" {
+			manager.AddAPIKey(key, allowedModels[key])
+		}
+	}
+
+	return manager, nil
+}
+
+// injectionPatterns converts configured prompt injection patterns for the
+// security manager
+func injectionPatterns(patterns []config.PromptInjectionPattern) []security.PromptInjectionPattern {
+	if len(patterns) == 0 {
+		return nil
+	}
+	converted := make([]security.PromptInjectionPattern, len(patterns))
+	for i, pattern := range patterns {
+		converted[i] = security.PromptInjectionPattern{Pattern: pattern.Pattern, Severity: pattern.Severity}
+	}
+	return converted
+}
+
+// promptInjectionMiddleware flags or, when blocking, rejects requests with
+// prompt injection patterns. Rejections and body read errors use the API's
+// error shape.
+func promptInjectionMiddleware(manager *security.Manager) gin.HandlerFunc {
+	return security.PromptInjectionMiddleware(manager, func(c *gin.Context) {
+		RespondWithErrorCode(c, http.StatusBadRequest, ErrorTypeInvalidRequest,
+			"Request blocked: prompt injection detected", "prompt_injection_detected")
+	}, BindError)
+}
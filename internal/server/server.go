@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -18,7 +19,9 @@ import (
 	"github.com/orchestre-dev/ccproxy/internal/pipeline"
 	"github.com/orchestre-dev/ccproxy/internal/providers"
 	modelrouter "github.com/orchestre-dev/ccproxy/internal/router"
+	"github.com/orchestre-dev/ccproxy/internal/security"
 	"github.com/orchestre-dev/ccproxy/internal/state"
+	"github.com/orchestre-dev/ccproxy/internal/tracing"
 	"github.com/orchestre-dev/ccproxy/internal/transformer"
 	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
@@ -26,6 +29,7 @@ import (
 // Server represents the CCProxy HTTP server
 type Server struct {
 	config          *config.Config
+	configMu        sync.RWMutex // Guards config, replaced on reload
 	configService   *config.Service
 	configPath      string
 	router          *gin.Engine
@@ -37,6 +41,10 @@ type Server struct {
 	stateManager    *state.Manager
 	readiness       *state.ReadinessProbe
 	performance     *performance.Monitor
+	tracer          *tracing.Tracer
+	requestStore    *pipeline.RequestStore // Nil unless requests are stored for replay
+	security        *security.Manager      // Nil unless a security feature is configured
+	apiMiddleware   []gin.HandlerFunc      // Runs before the /v1 handlers
 }
 
 // New creates a new server instance
@@ -52,7 +60,7 @@ func NewWithPath(cfg *config.Config, configPath string) (*Server, error) {
 	}
 
 	// Apply security constraint: force localhost when no API key
-	if cfg.APIKey == "" && cfg.Host != "" && cfg.Host != "127.0.0.1" && cfg.Host != "localhost" {
+	if cfg.APIKey == "" && len(cfg.InboundAPIKeys) == 0 && cfg.Host != "" && cfg.Host != "127.0.0.1" && cfg.Host != "localhost" {
 		utils.GetLogger().Warn("Forcing host to 127.0.0.1 due to missing API key")
 		cfg.Host = "127.0.0.1"
 	}
@@ -67,11 +75,19 @@ func NewWithPath(cfg *config.Config, configPath string) (*Server, error) {
 		return nil, fmt.Errorf("failed to initialize provider service: %w", err)
 	}
 
+	// Warn early about rejected keys instead of on the first request
+	if cfg.ValidateKeysOnStart {
+		providerService.ValidateKeys(context.Background(), providers.DefaultKeyProbeTimeout)
+	}
+
 	// Start health checks with 5 minute interval to reduce system load
 	providerService.StartHealthChecks(5 * time.Minute)
 
 	// Create transformer service
 	transformerService := transformer.GetRegistry()
+	if err := transformerService.ConfigureProviderChains(cfg.Providers); err != nil {
+		return nil, fmt.Errorf("failed to configure transformer chains: %w", err)
+	}
 
 	// Create routing engine
 	routingEngine := modelrouter.New(cfg)
@@ -79,24 +95,69 @@ func NewWithPath(cfg *config.Config, configPath string) (*Server, error) {
 	// Create pipeline
 	pipelineService := pipeline.NewPipeline(cfg, providerService, transformerService, routingEngine)
 
+	// Export request spans when an OTLP collector is configured
+	tracer := tracing.New(cfg.Tracing)
+	pipelineService.SetTracer(tracer)
+
+	// Restrict inbound keys to their allowed models and audit security events
+	securityManager, err := newSecurityManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if securityManager != nil && len(cfg.Security.ModelAccess) > 0 {
+		pipelineService.SetModelAccessChecker(securityManager)
+	}
+
+	// Rate limit the API once requests are authenticated, so rejected
+	// credentials do not use up a client's requests
+	var apiMiddleware []gin.HandlerFunc
+	if securityManager != nil {
+		if rateLimit := securityManager.RateLimitMiddleware(); rateLimit != nil {
+			apiMiddleware = append(apiMiddleware, rateLimit)
+		}
+	}
+
 	// Create router
 	router := gin.New()
 
 	// Add middleware
 	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
+	// Assign every request an id, echoed to the client and sent upstream
+	router.Use(security.RequestIDMiddleware())
+	// Only configured origins may call the API from a browser
+	router.Use(security.CORSMiddleware(cfg.Security.AllowedOrigins))
 	if cfg.Log {
-		router.Use(loggingMiddleware())
+		router.Use(loggingMiddleware(cfg.Logging.Format))
+	}
+
+	// Hide the paths the access rules do not expose, before authentication
+	// can reveal that they exist
+	if len(cfg.Security.Paths.Allow) > 0 || len(cfg.Security.Paths.Deny) > 0 {
+		pathAccess, err := pathAccessMiddleware(cfg.Security.Paths)
+		if err != nil {
+			return nil, err
+		}
+		router.Use(pathAccess)
 	}
 
 	// Add request size limit middleware
 	router.Use(requestSizeLimitMiddleware(cfg.Performance.MaxRequestBodySize))
 
-	// Add authentication middleware
-	router.Use(authMiddleware(cfg.APIKey, true))
+	// Bound the whole request handling, separately from the upstream timeout
+	if cfg.Performance.TotalRequestTimeout > 0 || cfg.Performance.TotalStreamingTimeout > 0 {
+		router.Use(totalTimeoutMiddleware(cfg.Performance.TotalRequestTimeout, cfg.Performance.TotalStreamingTimeout))
+	}
+
+	// Add authentication middleware. An inbound key allowlist replaces the
+	// single apikey check and also accepts apikey itself.
+	if len(cfg.InboundAPIKeys) > 0 {
+		router.Use(inboundKeyMiddleware(newAPIKeyAllowlist(append([]string{cfg.APIKey}, cfg.InboundAPIKeys...)...)))
+	} else {
+		router.Use(authMiddleware(cfg.APIKey, true))
+	}
 
 	// Add router middleware for intelligent model routing
-	router.Use(modelrouter.RouterMiddleware(cfg))
+	router.Use(modelrouter.Middleware(routingEngine))
 
 	// Create state manager
 	stateManager := state.NewManager()
@@ -117,6 +178,7 @@ func NewWithPath(cfg *config.Config, configPath string) (*Server, error) {
 			BurstSize:       100,
 			PerProvider:     true,
 			PerAPIKey:       false,
+			PerUser:         cfg.Performance.RateLimitPerUser,
 			CleanupInterval: 5 * time.Minute,
 		},
 		CircuitBreaker: performance.CircuitBreakerConfig{
@@ -132,6 +194,12 @@ func NewWithPath(cfg *config.Config, configPath string) (*Server, error) {
 	}
 	perfMonitor := performance.NewMonitor(perfConfig)
 
+	// Enforce resource limits, per-user rate limits and circuit breakers on
+	// the API and record its request metrics
+	if cfg.Performance.MetricsEnabled || cfg.Performance.RateLimitEnabled || cfg.Performance.CircuitBreakerEnabled {
+		apiMiddleware = append(apiMiddleware, performance.Middleware(perfMonitor))
+	}
+
 	// Create server
 	s := &Server{
 		config:          cfg,
@@ -143,6 +211,9 @@ func NewWithPath(cfg *config.Config, configPath string) (*Server, error) {
 		startTime:       time.Now(),
 		stateManager:    stateManager,
 		performance:     perfMonitor,
+		tracer:          tracer,
+		security:        securityManager,
+		apiMiddleware:   apiMiddleware,
 		server: &http.Server{
 			Addr:    fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 			Handler: router,
@@ -153,6 +224,19 @@ func NewWithPath(cfg *config.Config, configPath string) (*Server, error) {
 		},
 	}
 
+	// Keep request bodies for ccproxy replay, which exposes prompts to
+	// anyone who can read the directory
+	if cfg.Replay.StoreRequests {
+		dir := cfg.Replay.StoreDir()
+		requestStore, err := pipeline.NewRequestStore(dir, cfg.Replay.Redact)
+		if err != nil {
+			return nil, err
+		}
+		requestStore.SetRetention(cfg.Replay.RetainedRequests(), cfg.Replay.MaxAge)
+		s.requestStore = requestStore
+		utils.GetLogger().Warnf("Storing request bodies in %s for replay", dir)
+	}
+
 	// Create readiness probe
 	s.readiness = state.NewReadinessProbe(stateManager, 10*time.Second, 5*time.Second)
 
@@ -165,13 +249,6 @@ func NewWithPath(cfg *config.Config, configPath string) (*Server, error) {
 	// Setup routes
 	s.setupRoutes()
 
-	// Add performance monitoring middleware if enabled
-	if cfg.Performance.MetricsEnabled {
-		router.Use(s.performanceMiddleware())
-		// Add resource limit enforcement middleware
-		router.Use(performance.Middleware(s.performance))
-	}
-
 	return s, nil
 }
 
@@ -184,16 +261,28 @@ func (s *Server) Run() error {
 	s.readiness.Start(ctx)
 	defer s.readiness.Stop()
 
-	// Wait for readiness
-	utils.GetLogger().Info("Waiting for server components to be ready...")
-	if err := s.readiness.WaitForReady(ctx, 30*time.Second); err != nil {
-		s.stateManager.SetError(err)
-		return fmt.Errorf("failed to initialize server components: %w", err)
-	}
+	// Load tokenizers in the background, so requests do not wait on their
+	// download
+	go utils.PreloadEncodings()
+
+	if !s.hasProviders() {
+		// Start degraded instead of failing, so a first run can be fixed by
+		// reloading the configuration. The readiness probe marks the server
+		// ready once providers are configured.
+		utils.GetLogger().Warn("No providers are configured, starting in degraded mode: " +
+			"API requests return 503 until a configuration with providers is loaded")
+	} else {
+		// Wait for readiness
+		utils.GetLogger().Info("Waiting for server components to be ready...")
+		if err := s.readiness.WaitForReady(ctx, 30*time.Second); err != nil {
+			s.stateManager.SetError(err)
+			return fmt.Errorf("failed to initialize server components: %w", err)
+		}
 
-	// Mark server as ready
-	s.stateManager.SetReady()
-	utils.GetLogger().Info("Server components ready")
+		// Mark server as ready
+		s.stateManager.SetReady()
+		utils.GetLogger().Info("Server components ready")
+	}
 
 	// Setup graceful shutdown
 	stop := make(chan os.Signal, 1)
@@ -227,6 +316,25 @@ func (s *Server) GetRouter() *gin.Engine {
 	return s.router
 }
 
+// currentConfig returns the active configuration
+func (s *Server) currentConfig() *config.Config {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.config
+}
+
+// setConfig makes cfg the active configuration of the server and of the
+// routing and transformer settings the pipeline applies to new requests
+func (s *Server) setConfig(cfg *config.Config) {
+	s.configMu.Lock()
+	s.config = cfg
+	s.configMu.Unlock()
+
+	if s.pipeline != nil {
+		s.pipeline.SetConfig(cfg)
+	}
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown() error {
 	// Update state
@@ -247,10 +355,40 @@ func (s *Server) Shutdown() error {
 		s.performance.Stop()
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	timeout := s.currentConfig().ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	if err := s.server.Shutdown(ctx); err != nil {
+	// Stop accepting new requests while in-flight streams drain
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- s.server.Shutdown(ctx)
+	}()
+
+	if s.pipeline != nil {
+		if err := s.pipeline.Drain(ctx); err != nil {
+			utils.GetLogger().Warnf("Streams did not finish within %s: %v", timeout, err)
+		}
+		if err := s.pipeline.SaveBudget(); err != nil {
+			utils.GetLogger().Warnf("Failed to save budget state: %v", err)
+		}
+	}
+
+	if err := s.tracer.Shutdown(ctx); err != nil {
+		utils.GetLogger().Warnf("Failed to export remaining spans: %v", err)
+	}
+
+	if s.security != nil {
+		if err := s.security.Close(); err != nil {
+			utils.GetLogger().Warnf("Failed to close security manager: %v", err)
+		}
+	}
+
+	if err := <-shutdownErr; err != nil {
+		_ = s.server.Close() // Safe to ignore: forcing close after a failed shutdown
 		return fmt.Errorf("server shutdown error: %w", err)
 	}
 
@@ -269,7 +407,13 @@ func (s *Server) setupRoutes() {
 	s.router.GET("/status", s.handleStatus)
 
 	// Main API endpoint
-	s.router.POST("/v1/messages", s.handleMessages)
+	v1 := s.router.Group("/v1", s.apiMiddleware...)
+	messages := []gin.HandlerFunc{s.requireProviders}
+	if s.security != nil && s.currentConfig().Security.PromptInjection.Enabled {
+		messages = append(messages, promptInjectionMiddleware(s.security))
+	}
+	v1.POST("/messages", append(messages, s.handleMessages)...)
+	v1.POST("/embeddings", s.requireProviders, s.handleEmbeddings)
 
 	// Provider management endpoints
 	providers := s.router.Group("/providers")
@@ -281,6 +425,15 @@ func (s *Server) setupRoutes() {
 		providers.DELETE("/:name", s.handleDeleteProvider)
 		providers.PATCH("/:name/toggle", s.handleToggleProvider)
 	}
+
+	// Admin endpoints, protected by the authentication middleware
+	admin := s.router.Group("/admin")
+	{
+		admin.GET("/config", s.handleGetConfig)
+		admin.POST("/config/reload", s.handleReloadConfig)
+		admin.POST("/providers/:name/disable", s.handleSetProviderEnabled(false))
+		admin.POST("/providers/:name/enable", s.handleSetProviderEnabled(true))
+	}
 }
 
 // Placeholder handlers - to be implemented
@@ -292,8 +445,11 @@ func (s *Server) handleRoot(c *gin.Context) {
 }
 
 func (s *Server) handleHealth(c *gin.Context) {
+	// Without providers the server is degraded by design, not unhealthy
+	degraded := !s.hasProviders()
+
 	// Check if server is healthy
-	if !s.stateManager.IsHealthy() {
+	if !degraded && !s.stateManager.IsHealthy() {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"status":    "unhealthy",
 			"timestamp": time.Now().Format(time.RFC3339),
@@ -315,6 +471,10 @@ func (s *Server) handleHealth(c *gin.Context) {
 			"total":   len(s.providerService.GetAllProviders()),
 		},
 	}
+	if degraded {
+		response["status"] = "degraded"
+		response["message"] = noProvidersMessage
+	}
 
 	// Add detailed information only if authenticated
 	if isAuthenticated {
@@ -357,28 +517,12 @@ func (s *Server) handleHealth(c *gin.Context) {
 // isHealthRequestAuthenticated checks if the health request is authenticated
 func (s *Server) isHealthRequestAuthenticated(c *gin.Context) bool {
 	// If no API key is configured, allow detailed access from localhost only
-	if s.config.APIKey == "" {
+	cfg := s.currentConfig()
+	if cfg.APIKey == "" && len(cfg.InboundAPIKeys) == 0 {
 		return isLocalhost(c)
 	}
 
-	// Check Authorization header (Bearer token)
-	authHeader := c.GetHeader("Authorization")
-	if authHeader != "" {
-		const bearerPrefix = "Bearer "
-		if strings.HasPrefix(authHeader, bearerPrefix) {
-			token := authHeader[len(bearerPrefix):]
-			if token == s.config.APIKey {
-				return true
-			}
-		}
-	}
-
-	// Check x-api-key header
-	if c.GetHeader("x-api-key") == s.config.APIKey {
-		return true
-	}
-
-	return false
+	return newAPIKeyAllowlist(append([]string{cfg.APIKey}, cfg.InboundAPIKeys...)...).allows(c)
 }
 
 // handleStatus returns detailed status information about ccproxy and providers
@@ -411,7 +555,7 @@ func (s *Server) handleStatus(c *gin.Context) {
 		}
 
 		// Get default model from routes
-		if routes := s.config.Routes; routes != nil {
+		if routes := s.currentConfig().Routes; routes != nil {
 			if defaultRoute, ok := routes["default"]; ok && defaultRoute.Provider == currentProvider.Name {
 				providerStatus["model"] = defaultRoute.Model
 			}
@@ -463,13 +607,57 @@ func (s *Server) handleStatus(c *gin.Context) {
 		"provider": providerStatus,
 	}
 
+	// Add in-flight counts for providers with a concurrency limit and the
+	// global request queue depth
+	if s.pipeline != nil {
+		if inFlight := s.pipeline.InFlight(); len(inFlight) > 0 {
+			response["in_flight"] = inFlight
+		}
+		response["concurrency"] = s.pipeline.Concurrency()
+		if latency := s.pipeline.LatencyStats(); len(latency) > 0 {
+			response["latency"] = latency
+		}
+		if streamLatency := s.pipeline.StreamLatencyStats(); len(streamLatency) > 0 {
+			response["stream_latency"] = streamLatency
+		}
+		if rankings := s.pipeline.RouteRankings(); len(rankings) > 0 {
+			response["route_rankings"] = rankings
+		}
+		if budget := s.pipeline.RetryBudget(); budget != nil {
+			response["retry_budget"] = budget
+		}
+		if budget := s.pipeline.Budget(); budget != nil {
+			response["budget"] = budget
+		}
+	}
+
+	// Add circuit breaker state per provider when breakers are enabled
+	if s.performance != nil && s.currentConfig().Performance.CircuitBreakerEnabled {
+		response["circuit_breakers"] = s.circuitBreakerStatus()
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
+// circuitBreakerStatus returns breaker status for every configured provider.
+// Providers that have not served a request yet report a closed breaker.
+func (s *Server) circuitBreakerStatus() map[string]performance.CircuitBreakerStatus {
+	statuses := s.performance.GetCircuitBreakerStatuses()
+	for _, provider := range s.currentConfig().Providers {
+		if _, exists := statuses[provider.Name]; !exists {
+			statuses[provider.Name] = performance.CircuitBreakerStatus{State: "closed"}
+		}
+	}
+	return statuses
+}
+
 // setupReadinessChecks registers readiness checks for server components
 func (s *Server) setupReadinessChecks() {
 	// Provider service check
 	s.readiness.RegisterCheck("providers", func(ctx context.Context) error {
+		if !s.hasProviders() {
+			return fmt.Errorf("no providers configured")
+		}
 		healthyProviders := s.providerService.GetHealthyProviders()
 		if len(healthyProviders) == 0 {
 			return fmt.Errorf("no healthy providers available")
@@ -479,7 +667,7 @@ func (s *Server) setupReadinessChecks() {
 
 	// Config service check
 	s.readiness.RegisterCheck("config", func(ctx context.Context) error {
-		if s.config == nil {
+		if s.currentConfig() == nil {
 			return fmt.Errorf("configuration not loaded")
 		}
 		return nil
@@ -496,7 +684,8 @@ func (s *Server) setupReadinessChecks() {
 	// Server port check
 	s.readiness.RegisterCheck("server", func(ctx context.Context) error {
 		// Check if we can bind to the port
-		addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+		cfg := s.currentConfig()
+		addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 		listener, err := net.Listen("tcp", addr)
 		if err != nil {
 			// Port might already be in use by the server itself
@@ -526,36 +715,9 @@ func (s *Server) setupStateHandlers() {
 	})
 }
 
-// performanceMiddleware creates a middleware for performance monitoring
-func (s *Server) performanceMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		start := time.Now()
-
-		// Process request
-		c.Next()
-
-		// Record metrics
-		latency := time.Since(start)
-		provider := c.GetString("provider")
-		model := c.GetString("model")
-
-		s.performance.RecordRequest(performance.RequestMetrics{
-			Provider:   provider,
-			Model:      model,
-			StartTime:  start,
-			EndTime:    time.Now(),
-			Latency:    latency,
-			Success:    c.Writer.Status() < 400,
-			StatusCode: c.Writer.Status(),
-		})
-
-		// Increment requests served
-		atomic.AddInt64(&s.requestsServed, 1)
-	}
-}
-
-// loggingMiddleware creates a logging middleware
-func loggingMiddleware() gin.HandlerFunc {
+// loggingMiddleware creates a logging middleware. With the "json" format it
+// emits one structured access record per request instead of the text lines.
+func loggingMiddleware(format string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
@@ -571,6 +733,34 @@ func loggingMiddleware() gin.HandlerFunc {
 			path = path + "?" + raw
 		}
 
+		if format == "json" {
+			fields := map[string]interface{}{
+				"request_id":    c.GetString("request_id"),
+				"method":        c.Request.Method,
+				"path":          path,
+				"provider":      c.GetString("provider"),
+				"model":         c.GetString("model"),
+				"upstream_id":   c.GetString("upstream_request_id"),
+				"status":        c.Writer.Status(),
+				"duration_ms":   latency.Milliseconds(),
+				"input_tokens":  c.GetInt("tokens_in"),
+				"output_tokens": c.GetInt("tokens_out"),
+				"streamed":      c.GetBool("streamed"),
+				"trace_id":      c.GetString("trace_id"),
+			}
+			if firstToken, ok := c.Get("first_token_ms"); ok {
+				fields["first_token_ms"] = firstToken
+			}
+			if cacheRead := c.GetInt("tokens_cache_read"); cacheRead > 0 {
+				fields["cache_read_tokens"] = cacheRead
+			}
+			if cacheCreation := c.GetInt("tokens_cache_creation"); cacheCreation > 0 {
+				fields["cache_creation_tokens"] = cacheCreation
+			}
+			utils.LogAccess(fields)
+			return
+		}
+
 		utils.LogRequest(c.Request.Method, path, map[string]interface{}{
 			"client_ip":  c.ClientIP(),
 			"user_agent": c.Request.UserAgent(),
@@ -584,7 +774,7 @@ func loggingMiddleware() gin.HandlerFunc {
 
 // GetPort returns the port the server is configured to run on
 func (s *Server) GetPort() int {
-	return s.config.Port
+	return s.currentConfig().Port
 }
 
 // requestSizeLimitMiddleware limits the size of request bodies
@@ -599,15 +789,12 @@ func requestSizeLimitMiddleware(maxSize int64) gin.HandlerFunc {
 		// Check Content-Length header
 		contentLength := c.Request.ContentLength
 		if contentLength > maxSize {
-			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
-				"error": "Request body too large",
-				"limit": maxSize,
-			})
-			c.Abort()
+			RequestTooLarge(c, maxSize)
 			return
 		}
 
-		// Wrap the body with a limited reader to enforce the limit at read time
+		// Wrap the body with a limited reader to enforce the limit at read time,
+		// handlers report the resulting *http.MaxBytesError through BindError
 		if c.Request.Body != nil {
 			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
 		}
@@ -615,22 +802,3 @@ func requestSizeLimitMiddleware(maxSize int64) gin.HandlerFunc {
 		c.Next()
 	}
 }
-
-// corsMiddleware adds CORS headers
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Set CORS headers
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
-
-		// Handle preflight requests
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
-		c.Next()
-	}
-}
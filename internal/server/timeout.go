@@ -0,0 +1,91 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orchestre-dev/ccproxy/internal/pipeline"
+)
+
+// totalTimeoutMiddleware bounds the whole handling of a request, including
+// transformers, queueing and the upstream call, and answers 504 when the
+// deadline passes before a response was written. Streaming requests use
+// streamingTimeout instead and are exempt when it is 0.
+func totalTimeoutMiddleware(timeout, streamingTimeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := timeout
+		if isStreamingRequest(c) {
+			limit = streamingTimeout
+		}
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), limit)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if totalTimeoutExceeded(c) && !c.Writer.Written() {
+			writeTotalTimeout(c)
+		}
+	}
+}
+
+// totalTimeoutExceeded reports whether the request's overall deadline passed
+func totalTimeoutExceeded(c *gin.Context) bool {
+	return errors.Is(c.Request.Context().Err(), context.DeadlineExceeded)
+}
+
+// writeTotalTimeout answers a request that ran past its overall deadline
+func writeTotalTimeout(c *gin.Context) {
+	pipeline.WriteErrorResponse(c.Writer, http.StatusGatewayTimeout, pipeline.NewErrorResponse(
+		"Request exceeded the total request timeout",
+		"gateway_timeout",
+		"total_request_timeout",
+	))
+	c.Abort()
+}
+
+// isStreamingRequest peeks at a JSON body's stream flag, leaving the body
+// for the handler to read
+func isStreamingRequest(c *gin.Context) bool {
+	if c.Request.Method != http.MethodPost || c.Request.Body == nil {
+		return false
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), errorReader{err}))
+	if err != nil {
+		return false
+	}
+
+	var body struct {
+		Stream bool `json:"stream"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		return false
+	}
+	return body.Stream
+}
+
+// errorReader replays a body read error, such as the size limit being hit,
+// once the data read before it is consumed
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
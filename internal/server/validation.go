@@ -0,0 +1,139 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+)
+
+// nullableFields are optional request fields that clients may send as null
+// to mean unset
+var nullableFields = []string{"max_tokens", "stream", "system", "temperature", "tools"}
+
+// validateMessagesRequest checks the shape of a /v1/messages body before it is
+// routed, so malformed requests fail with a precise error instead of deep in
+// a transformer. Optional fields sent as null are removed first, so they
+// count as absent here and downstream. It returns nil for a valid body, or a
+// validation error that lists every problem found.
+func validateMessagesRequest(body interface{}) *ccerrors.CCProxyError {
+	bodyMap, ok := body.(map[string]interface{})
+	if !ok {
+		return validationError([]string{"request body must be a JSON object"})
+	}
+	for _, field := range nullableFields {
+		if value, ok := bodyMap[field]; ok && value == nil {
+			delete(bodyMap, field)
+		}
+	}
+
+	var problems []string
+
+	switch model, ok := bodyMap["model"]; {
+	case !ok:
+		problems = append(problems, "model is required")
+	case !isNonEmptyString(model):
+		problems = append(problems, "model must be a non-empty string")
+	}
+
+	switch messages, ok := bodyMap["messages"]; {
+	case !ok:
+		problems = append(problems, "messages is required")
+	default:
+		list, ok := messages.([]interface{})
+		if !ok || len(list) == 0 {
+			problems = append(problems, "messages must be a non-empty array")
+		}
+		for i, msg := range list {
+			problems = append(problems, validateMessage(i, msg)...)
+		}
+	}
+
+	if value, ok := bodyMap["max_tokens"]; ok && !isPositiveInteger(value) {
+		problems = append(problems, "max_tokens must be a positive integer")
+	}
+	if value, ok := bodyMap["stream"]; ok {
+		if _, isBool := value.(bool); !isBool {
+			problems = append(problems, "stream must be a boolean")
+		}
+	}
+	if value, ok := bodyMap["system"]; ok && !isTextOrBlocks(value) {
+		problems = append(problems, "system must be a string or an array of content blocks")
+	}
+	if value, ok := bodyMap["temperature"]; ok {
+		if _, isNumber := value.(float64); !isNumber {
+			problems = append(problems, "temperature must be a number")
+		}
+	}
+	if value, ok := bodyMap["tools"]; ok {
+		if _, isArray := value.([]interface{}); !isArray {
+			problems = append(problems, "tools must be an array")
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return validationError(problems)
+}
+
+// validateMessage checks a single entry of the messages array
+func validateMessage(index int, msg interface{}) []string {
+	msgMap, ok := msg.(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("messages[%d] must be an object", index)}
+	}
+
+	var problems []string
+	switch role, ok := msgMap["role"]; {
+	case !ok:
+		problems = append(problems, fmt.Sprintf("messages[%d].role is required", index))
+	case !isNonEmptyString(role):
+		problems = append(problems, fmt.Sprintf("messages[%d].role must be a non-empty string", index))
+	}
+
+	// OpenAI-format assistant messages carry a null content next to tool_calls
+	switch content, ok := msgMap["content"]; {
+	case !ok:
+		problems = append(problems, fmt.Sprintf("messages[%d].content is required", index))
+	case content != nil && !isTextOrBlocks(content):
+		problems = append(problems, fmt.Sprintf("messages[%d].content must be a string or an array of content blocks", index))
+	}
+	return problems
+}
+
+// validationError builds the 400 validation_error for a list of problems
+func validationError(problems []string) *ccerrors.CCProxyError {
+	return ccerrors.NewValidationError("Invalid request: "+strings.Join(problems, "; "), nil).
+		WithDetails(map[string]interface{}{"problems": problems})
+}
+
+// isNonEmptyString reports whether value is a string with content
+func isNonEmptyString(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && s != ""
+}
+
+// isPositiveInteger reports whether a decoded JSON value is a whole number
+// greater than zero
+func isPositiveInteger(value interface{}) bool {
+	n, ok := value.(float64)
+	return ok && n > 0 && n == math.Trunc(n)
+}
+
+// isTextOrBlocks reports whether value is a string or an array of objects
+func isTextOrBlocks(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return true
+	case []interface{}:
+		for _, block := range v {
+			if _, ok := block.(map[string]interface{}); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
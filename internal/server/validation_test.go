@@ -0,0 +1,130 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateMessagesRequest(t *testing.T) {
+	userMessage := map[string]interface{}{"role": "user", "content": "Hello"}
+
+	tests := []struct {
+		name     string
+		body     interface{}
+		problems []string
+	}{
+		{
+			name: "valid",
+			body: map[string]interface{}{
+				"model":      "claude-3-5-sonnet",
+				"messages":   []interface{}{userMessage},
+				"max_tokens": float64(1024),
+				"stream":     true,
+				"system":     []interface{}{map[string]interface{}{"type": "text", "text": "Be brief"}},
+			},
+		},
+		{
+			name: "null content next to tool calls",
+			body: map[string]interface{}{
+				"model": "gpt-4o",
+				"messages": []interface{}{
+					map[string]interface{}{"role": "assistant", "content": nil, "tool_calls": []interface{}{}},
+				},
+			},
+		},
+		{
+			name: "null optional fields",
+			body: map[string]interface{}{
+				"model":       "claude-3-5-sonnet",
+				"messages":    []interface{}{userMessage},
+				"max_tokens":  nil,
+				"stream":      nil,
+				"system":      nil,
+				"temperature": nil,
+				"tools":       nil,
+			},
+		},
+		{
+			name:     "not an object",
+			body:     []interface{}{"model"},
+			problems: []string{"request body must be a JSON object"},
+		},
+		{
+			name:     "missing model",
+			body:     map[string]interface{}{"messages": []interface{}{userMessage}},
+			problems: []string{"model is required"},
+		},
+		{
+			name:     "empty model",
+			body:     map[string]interface{}{"model": "", "messages": []interface{}{userMessage}},
+			problems: []string{"model must be a non-empty string"},
+		},
+		{
+			name:     "non-array messages",
+			body:     map[string]interface{}{"model": "gpt-4o", "messages": "Hello"},
+			problems: []string{"messages must be a non-empty array"},
+		},
+		{
+			name:     "empty messages",
+			body:     map[string]interface{}{"model": "gpt-4o", "messages": []interface{}{}},
+			problems: []string{"messages must be a non-empty array"},
+		},
+		{
+			name: "every problem listed",
+			body: map[string]interface{}{
+				"messages": []interface{}{
+					"Hello",
+					map[string]interface{}{"content": float64(1)},
+				},
+				"max_tokens": float64(1.5),
+				"stream":     "yes",
+			},
+			problems: []string{
+				"model is required",
+				"messages[0] must be an object",
+				"messages[1].role is required",
+				"messages[1].content must be a string or an array of content blocks",
+				"max_tokens must be a positive integer",
+				"stream must be a boolean",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMessagesRequest(tt.body)
+			if tt.problems == nil {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected problems %v, got none", tt.problems)
+			}
+			if err.StatusCode != http.StatusBadRequest || err.Type != "validation_error" {
+				t.Errorf("Expected a 400 validation_error, got %d %s", err.StatusCode, err.Type)
+			}
+			if got := err.Details["problems"]; !reflect.DeepEqual(got, tt.problems) {
+				t.Errorf("Expected problems %v, got %v", tt.problems, got)
+			}
+			if !strings.Contains(err.Message, tt.problems[0]) {
+				t.Errorf("Expected message to list the problems, got %s", err.Message)
+			}
+		})
+	}
+
+	t.Run("null fields removed", func(t *testing.T) {
+		body := map[string]interface{}{"model": "gpt-4o", "messages": []interface{}{userMessage}, "max_tokens": nil, "stream": nil}
+		if err := validateMessagesRequest(body); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, field := range []string{"max_tokens", "stream"} {
+			if _, ok := body[field]; ok {
+				t.Errorf("Expected null %s to be removed", field)
+			}
+		}
+	})
+}
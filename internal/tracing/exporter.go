@@ -0,0 +1,274 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+const (
+	defaultExportBatchSize     = 256
+	defaultExportFlushInterval = 5 * time.Second
+	defaultExportQueueSize     = 2048
+	exportTimeout              = 10 * time.Second
+)
+
+// OTLP span kind and status codes
+const (
+	spanKindServer  = 2
+	statusCodeUnset = 0
+	statusCodeError = 2
+)
+
+// exporter sends finished spans to an OTLP/HTTP collector using the JSON
+// encoding. Spans are queued so request handling never waits on the
+// collector, and sent in batches by a background goroutine.
+type exporter struct {
+	url         string
+	headers     map[string]string
+	client      *http.Client
+	serviceName string
+
+	queue     chan otlpSpan
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newExporter creates an exporter and starts its sender
+func newExporter(cfg config.TracingConfig, serviceName string) *exporter {
+	e := &exporter{
+		url:         strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces",
+		headers:     cfg.Headers,
+		client:      &http.Client{Timeout: exportTimeout},
+		serviceName: serviceName,
+		queue:       make(chan otlpSpan, defaultExportQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// enqueue queues a span, dropping it if the queue is full
+func (e *exporter) enqueue(span otlpSpan) {
+	select {
+	case e.queue <- span:
+	default:
+		utils.GetLogger().Warn("Trace export queue full, dropping span")
+	}
+}
+
+// shutdown sends the queued spans and stops the sender
+func (e *exporter) shutdown(ctx context.Context) error {
+	e.closeOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("trace export did not finish: %w", ctx.Err())
+	}
+}
+
+// run batches queued spans by size and interval until the exporter stops
+func (e *exporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(defaultExportFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]otlpSpan, 0, defaultExportBatchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			utils.GetLogger().Errorf("Failed to export %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= defaultExportBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case <-e.stop:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+				default:
+					send()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts one batch of spans as an OTLP ExportTraceServiceRequest
+func (e *exporter) send(spans []otlpSpan) error {
+	payload := otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: []otlpAttribute{stringAttribute("service.name", e.serviceName)}},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: defaultServiceName},
+				Spans: spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// snapshot converts the span to its OTLP form. The caller holds s.mu.
+func (s *Span) snapshot(end time.Time) otlpSpan {
+	keys := make([]string, 0, len(s.attributes))
+	for key := range s.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attributes := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		attributes = append(attributes, attribute(key, s.attributes[key]))
+	}
+
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+		TraceState:        s.context.TraceState,
+		Name:              s.name,
+		Kind:              spanKindServer,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        attributes,
+		Status:            otlpStatus{Code: statusCodeUnset},
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if s.failed {
+		span.Status = otlpStatus{Code: statusCodeError, Message: s.errMessage}
+	}
+	return span
+}
+
+// attribute converts a Go value to an OTLP attribute, formatting unknown
+// types as strings
+func attribute(key string, value interface{}) otlpAttribute {
+	switch v := value.(type) {
+	case string:
+		return stringAttribute(key, v)
+	case bool:
+		return otlpAttribute{Key: key, Value: otlpValue{BoolValue: &v}}
+	case int:
+		return intAttribute(key, int64(v))
+	case int64:
+		return intAttribute(key, v)
+	case float64:
+		return otlpAttribute{Key: key, Value: otlpValue{DoubleValue: &v}}
+	default:
+		return stringAttribute(key, fmt.Sprint(v))
+	}
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+// intAttribute encodes an integer as a string, as OTLP JSON requires for int64
+func intAttribute(key string, value int64) otlpAttribute {
+	encoded := strconv.FormatInt(value, 10)
+	return otlpAttribute{Key: key, Value: otlpValue{IntValue: &encoded}}
+}
+
+// OTLP/JSON trace payload types, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	TraceState        string          `json:"traceState,omitempty"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
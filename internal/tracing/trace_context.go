@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// W3C trace context headers, see https://www.w3.org/TR/trace-context/
+const (
+	TraceparentHeader = "Traceparent"
+	TracestateHeader  = "Tracestate"
+)
+
+// sampledFlag is the traceparent flag marking a sampled trace
+const sampledFlag = 0x01
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Flags      byte
+	TraceState string
+}
+
+// IsValid reports whether both ids are set, as the specification requires
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats the span context as a version 00 traceparent value
+func (sc SpanContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent parses a traceparent header value. Values from future
+// versions are accepted as long as their leading fields parse.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if !decodeHex(sc.TraceID[:], traceID) || !decodeHex(sc.SpanID[:], spanID) {
+		return sc, false
+	}
+	var flagBytes [1]byte
+	if !decodeHex(flagBytes[:], flags) {
+		return sc, false
+	}
+	sc.Flags = flagBytes[0]
+	return sc, sc.IsValid()
+}
+
+// decodeHex decodes lowercase hex of exactly the destination's length
+func decodeHex(dst []byte, value string) bool {
+	if len(value) != hex.EncodedLen(len(dst)) || strings.ToLower(value) != value {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(value))
+	return err == nil
+}
+
+// newTraceID returns a random trace id
+func newTraceID() [16]byte {
+	var id [16]byte
+	_, _ = rand.Read(id[:]) // crypto/rand never fails on supported platforms
+	return id
+}
+
+// newSpanID returns a random span id
+func newSpanID() [8]byte {
+	var id [8]byte
+	_, _ = rand.Read(id[:]) // crypto/rand never fails on supported platforms
+	return id
+}
@@ -0,0 +1,182 @@
+// Package tracing records OpenTelemetry spans for proxied requests and
+// propagates W3C trace context to providers. Spans are exported to an OTLP
+// collector over HTTP; without an endpoint every operation is a no-op.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+)
+
+// defaultServiceName is reported as service.name when none is configured
+const defaultServiceName = "ccproxy"
+
+// Tracer starts spans and exports them. A nil *Tracer is valid and records
+// nothing.
+type Tracer struct {
+	serviceName string
+	exporter    *exporter
+}
+
+// New creates a tracer exporting to the configured OTLP endpoint, or returns
+// nil when tracing is not configured
+func New(cfg config.TracingConfig) *Tracer {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	return &Tracer{
+		serviceName: serviceName,
+		exporter:    newExporter(cfg, serviceName),
+	}
+}
+
+// Start begins a server span for an inbound request. The span continues the
+// trace from the request's traceparent and tracestate headers when present,
+// and is stored in the returned context for Inject.
+func (t *Tracer) Start(ctx context.Context, name string, headers map[string]string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+	if parent, ok := ParseTraceparent(headerValue(headers, TraceparentHeader)); ok {
+		span.parentID = parent.SpanID
+		span.context = SpanContext{
+			TraceID:    parent.TraceID,
+			Flags:      parent.Flags,
+			TraceState: headerValue(headers, TracestateHeader),
+		}
+	} else {
+		span.context = SpanContext{TraceID: newTraceID(), Flags: sampledFlag}
+	}
+	span.context.SpanID = newSpanID()
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Shutdown exports the spans still queued
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.shutdown(ctx)
+}
+
+// spanKey stores the active span in a context
+type spanKey struct{}
+
+// FromContext returns the span stored in ctx, or nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Inject sets the traceparent and tracestate headers of an outbound request
+// so the provider's spans join the trace of the span in ctx
+func Inject(ctx context.Context, header http.Header) {
+	span := FromContext(ctx)
+	if span == nil {
+		return
+	}
+	header.Set(TraceparentHeader, span.context.Traceparent())
+	if span.context.TraceState != "" {
+		header.Set(TracestateHeader, span.context.TraceState)
+	}
+}
+
+// headerValue looks a header up case-insensitively
+func headerValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// Span is a single timed operation. All methods are safe on a nil *Span.
+type Span struct {
+	tracer   *Tracer
+	name     string
+	context  SpanContext
+	parentID [8]byte
+	start    time.Time
+
+	mu         sync.Mutex
+	attributes map[string]interface{}
+	errMessage string
+	failed     bool
+	ended      bool
+}
+
+// Context returns the span's identity
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// TraceID returns the hex trace id, or "" for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.context.TraceID[:])
+}
+
+// SetAttribute records a string, bool, integer or float attribute
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.errMessage = message
+}
+
+// End finishes the span and queues it for export when its trace is sampled.
+// Calls after the first are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := s.snapshot(time.Now())
+	s.mu.Unlock()
+
+	if s.context.Flags&sampledFlag != 0 {
+		s.tracer.exporter.enqueue(data)
+	}
+}
@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+const (
+	parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"sampled", "00-" + parentTraceID + "-" + parentSpanID + "-01", true},
+		{"not sampled", "00-" + parentTraceID + "-" + parentSpanID + "-00", true},
+		{"future version with extra field", "01-" + parentTraceID + "-" + parentSpanID + "-01-extra", true},
+		{"version 00 with extra field", "00-" + parentTraceID + "-" + parentSpanID + "-01-extra", false},
+		{"invalid version", "ff-" + parentTraceID + "-" + parentSpanID + "-01", false},
+		{"zero trace id", "00-" + strings.Repeat("0", 32) + "-" + parentSpanID + "-01", false},
+		{"zero span id", "00-" + parentTraceID + "-" + strings.Repeat("0", 16) + "-01", false},
+		{"uppercase", "00-" + strings.ToUpper(parentTraceID) + "-" + parentSpanID + "-01", false},
+		{"short trace id", "00-4bf92f35-" + parentSpanID + "-01", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.value)
+			testutil.AssertEqual(t, tt.valid, ok)
+			if ok && strings.HasPrefix(tt.value, "00-") {
+				testutil.AssertEqual(t, tt.value, sc.Traceparent())
+			}
+		})
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	tracer := New(config.TracingConfig{})
+	testutil.AssertTrue(t, tracer == nil)
+
+	ctx, span := tracer.Start(context.Background(), "request", nil)
+	testutil.AssertTrue(t, span == nil)
+	testutil.AssertTrue(t, FromContext(ctx) == nil)
+
+	// Span methods and Inject are no-ops
+	span.SetAttribute("key", "value")
+	span.SetError("failed")
+	span.End()
+	testutil.AssertEqual(t, "", span.TraceID())
+
+	header := http.Header{}
+	Inject(ctx, header)
+	testutil.AssertEqual(t, 0, len(header))
+	testutil.AssertNoError(t, tracer.Shutdown(context.Background()))
+}
+
+func TestTracerExportsSpans(t *testing.T) {
+	var requests []otlpExportRequest
+	var authHeader string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.AssertEqual(t, "/v1/traces", r.URL.Path)
+		testutil.AssertEqual(t, "application/json", r.Header.Get("Content-Type"))
+		authHeader = r.Header.Get("Authorization")
+
+		body, err := io.ReadAll(r.Body)
+		testutil.AssertNoError(t, err)
+		var request otlpExportRequest
+		testutil.AssertNoError(t, json.Unmarshal(body, &request))
+		requests = append(requests, request)
+	}))
+	defer collector.Close()
+
+	tracer := New(config.TracingConfig{
+		Endpoint:    collector.URL + "/",
+		ServiceName: "proxy",
+		Headers:     map[string]string{"Authorization": "Bearer secret"},
+	})
+
+	t.Run("ContinuesInboundTrace", func(t *testing.T) {
+		ctx, span := tracer.Start(context.Background(), "request", map[string]string{
+			"traceparent": "00-" + parentTraceID + "-" + parentSpanID + "-01",
+			"tracestate":  "vendor=value",
+		})
+		testutil.AssertEqual(t, parentTraceID, span.TraceID())
+
+		header := http.Header{}
+		Inject(ctx, header)
+		outbound, ok := ParseTraceparent(header.Get(TraceparentHeader))
+		testutil.AssertTrue(t, ok)
+		testutil.AssertEqual(t, span.Context(), SpanContext{
+			TraceID:    outbound.TraceID,
+			SpanID:     outbound.SpanID,
+			Flags:      outbound.Flags,
+			TraceState: "vendor=value",
+		})
+		testutil.AssertEqual(t, "vendor=value", header.Get(TracestateHeader))
+
+		span.SetAttribute("gen_ai.system", "openai")
+		span.SetAttribute("gen_ai.usage.input_tokens", 12)
+		span.SetError("upstream failed")
+		span.End()
+		span.End()
+	})
+
+	t.Run("UnsampledTraceIsNotExported", func(t *testing.T) {
+		ctx, span := tracer.Start(context.Background(), "request", map[string]string{
+			"Traceparent": "00-" + parentTraceID + "-" + parentSpanID + "-00",
+		})
+		header := http.Header{}
+		Inject(ctx, header)
+		testutil.AssertTrue(t, strings.HasSuffix(header.Get(TraceparentHeader), "-00"))
+		span.End()
+	})
+
+	t.Run("StartsNewTrace", func(t *testing.T) {
+		_, span := tracer.Start(context.Background(), "request", nil)
+		testutil.AssertTrue(t, span.Context().IsValid())
+		testutil.AssertTrue(t, span.TraceID() != parentTraceID)
+		span.End()
+	})
+
+	testutil.AssertNoError(t, tracer.Shutdown(context.Background()))
+
+	testutil.AssertEqual(t, 1, len(requests))
+	testutil.AssertEqual(t, "Bearer secret", authHeader)
+	resource := requests[0].ResourceSpans[0]
+	testutil.AssertEqual(t, "service.name", resource.Resource.Attributes[0].Key)
+	testutil.AssertEqual(t, "proxy", *resource.Resource.Attributes[0].Value.StringValue)
+
+	spans := resource.ScopeSpans[0].Spans
+	testutil.AssertEqual(t, 2, len(spans))
+
+	continued := spans[0]
+	testutil.AssertEqual(t, parentTraceID, continued.TraceID)
+	testutil.AssertEqual(t, parentSpanID, continued.ParentSpanID)
+	testutil.AssertEqual(t, "vendor=value", continued.TraceState)
+	testutil.AssertEqual(t, spanKindServer, continued.Kind)
+	testutil.AssertEqual(t, otlpStatus{Code: statusCodeError, Message: "upstream failed"}, continued.Status)
+	testutil.AssertEqual(t, 2, len(continued.Attributes))
+	testutil.AssertEqual(t, "gen_ai.system", continued.Attributes[0].Key)
+	testutil.AssertEqual(t, "openai", *continued.Attributes[0].Value.StringValue)
+	testutil.AssertEqual(t, "12", *continued.Attributes[1].Value.IntValue)
+
+	testutil.AssertEqual(t, "", spans[1].ParentSpanID)
+	testutil.AssertEqual(t, statusCodeUnset, spans[1].Status.Code)
+}
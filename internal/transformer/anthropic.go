@@ -65,6 +65,15 @@ func (t *AnthropicTransformer) TransformRequestIn(ctx context.Context, request i
 	if stream, ok := reqMap["stream"]; ok {
 		transformed["stream"] = stream
 	}
+	if stop, ok := stopSequencesField(reqMap); ok {
+		transformed["stop_sequences"] = stop
+	}
+	// Anthropic has no token log probabilities
+	warnDroppedLogprobs(reqMap, provider)
+	// Anthropic identifies the end user through metadata.user_id
+	if user := requestUser(reqMap); user != "" {
+		transformed["metadata"] = map[string]interface{}{"user_id": user}
+	}
 
 	// Transform messages
 	messages, ok := reqMap["messages"].([]interface{})
@@ -73,7 +82,9 @@ func (t *AnthropicTransformer) TransformRequestIn(ctx context.Context, request i
 	}
 
 	transformedMessages := []interface{}{}
-	var systemMessage string
+
+	// Anthropic-format requests carry the system prompt at the top level
+	system := reqMap["system"]
 
 	for _, msg := range messages {
 		msgMap, ok := msg.(map[string]interface{})
@@ -84,10 +95,12 @@ func (t *AnthropicTransformer) TransformRequestIn(ctx context.Context, request i
 		role, _ := msgMap["role"].(string)
 		content := msgMap["content"]
 
-		// Extract system message
+		// Extract system message, keeping content blocks so their
+		// cache_control markers survive
 		if role == "system" {
-			if str, ok := content.(string); ok {
-				systemMessage = str
+			switch content.(type) {
+			case string, []interface{}:
+				system = content
 			}
 			continue
 		}
@@ -166,8 +179,8 @@ func (t *AnthropicTransformer) TransformRequestIn(ctx context.Context, request i
 	}
 
 	// Set system message if found
-	if systemMessage != "" {
-		transformed["system"] = systemMessage
+	if system != nil && system != "" {
+		transformed["system"] = system
 	}
 
 	transformed["messages"] = transformedMessages
@@ -191,29 +204,28 @@ func (t *AnthropicTransformer) TransformRequestIn(ctx context.Context, request i
 				"description":  funcMap["description"],
 				"input_schema": funcMap["parameters"],
 			}
+			if cacheControl, ok := toolMap["cache_control"]; ok {
+				anthropicTool["cache_control"] = cacheControl
+			}
 			transformedTools = append(transformedTools, anthropicTool)
 		}
 		transformed["tools"] = transformedTools
 
 		// Transform tool_choice
-		if toolChoice := reqMap["tool_choice"]; toolChoice != nil {
-			switch tc := toolChoice.(type) {
-			case string:
-				if tc == "auto" {
-					transformed["tool_choice"] = map[string]interface{}{"type": "any"}
-				} else if tc == "required" {
-					transformed["tool_choice"] = map[string]interface{}{"type": "any"}
-				} else if tc == "none" {
-					transformed["tool_choice"] = map[string]interface{}{"type": "none"}
-				}
-			case map[string]interface{}:
-				if funcName, ok := tc["function"].(map[string]interface{})["name"].(string); ok {
-					transformed["tool_choice"] = map[string]interface{}{
-						"type": "tool",
-						"name": funcName,
-					}
-				}
+		choice, ok := parseToolChoice(reqMap["tool_choice"])
+		if ok {
+			transformed["tool_choice"] = choice.anthropic()
+		}
+
+		// Anthropic limits a turn to one tool call through tool_choice,
+		// which is invalid when tools are disabled
+		if sequentialToolCalls(reqMap) && choice.mode != toolChoiceNone {
+			anthropicChoice, ok := transformed["tool_choice"].(map[string]interface{})
+			if !ok {
+				anthropicChoice = map[string]interface{}{"type": toolChoiceAuto}
 			}
+			anthropicChoice["disable_parallel_tool_use"] = true
+			transformed["tool_choice"] = anthropicChoice
 		}
 	}
 
@@ -248,13 +260,14 @@ func (t *AnthropicTransformer) TransformResponseOut(ctx context.Context, respons
 		ProtoMajor:    response.ProtoMajor,
 		ProtoMinor:    response.ProtoMinor,
 		Header:        response.Header.Clone(),
-		Body:          pr,
+		Body:          &pipeBody{PipeReader: pr, upstream: reader},
 		ContentLength: -1,
 		Request:       response.Request,
 	}
 
 	// Start the transformation in a goroutine
 	go func() {
+		defer reader.Close()
 		defer pw.Close()
 		writer := NewSSEWriter(pw)
 
@@ -312,7 +325,7 @@ func (t *AnthropicTransformer) transformNonStreamingResponse(ctx context.Context
 	}
 
 	// Transform to OpenAI format
-	openaiResp := t.transformAnthropicToOpenAI(anthropicResp)
+	openaiResp := t.transformAnthropicToOpenAI(anthropicResp, body)
 
 	// Marshal the transformed response
 	transformedBody, err := json.Marshal(openaiResp)
@@ -326,16 +339,13 @@ func (t *AnthropicTransformer) transformNonStreamingResponse(ctx context.Context
 	return response, nil
 }
 
-// transformAnthropicToOpenAI transforms Anthropic response format to OpenAI format
-func (t *AnthropicTransformer) transformAnthropicToOpenAI(anthropicResp map[string]interface{}) map[string]interface{} {
-	// Ensure we have a valid ID
-	responseID := anthropicResp["id"]
-	if responseID == nil || responseID == "" {
-		responseID = "chatcmpl-" + uuid.New().String()
-	}
+// transformAnthropicToOpenAI transforms Anthropic response format to OpenAI
+// format, deriving a missing id from the raw response body
+func (t *AnthropicTransformer) transformAnthropicToOpenAI(anthropicResp map[string]interface{}, body []byte) map[string]interface{} {
+	responseID, _ := anthropicResp["id"].(string)
 
 	openaiResp := map[string]interface{}{
-		"id":      responseID,
+		"id":      stableResponseID(responseID, "chatcmpl-", body),
 		"object":  "chat.completion",
 		"created": utils.GetTimestamp(),
 		"model":   anthropicResp["model"],
@@ -402,16 +412,30 @@ func (t *AnthropicTransformer) transformAnthropicToOpenAI(anthropicResp map[stri
 
 	// Transform usage
 	if usage, ok := anthropicResp["usage"].(map[string]interface{}); ok {
-		openaiResp["usage"] = map[string]interface{}{
-			"prompt_tokens":     usage["input_tokens"],
-			"completion_tokens": usage["output_tokens"],
-			"total_tokens":      t.sumTokens(usage["input_tokens"], usage["output_tokens"]),
-		}
+		openaiResp["usage"] = t.convertUsage(usage)
 	}
 
 	return openaiResp
 }
 
+// convertUsage converts Anthropic usage to OpenAI format, keeping the prompt
+// cache token counts when the response reports them
+func (t *AnthropicTransformer) convertUsage(usage map[string]interface{}) map[string]interface{} {
+	converted := map[string]interface{}{
+		"prompt_tokens":     usage["input_tokens"],
+		"completion_tokens": usage["output_tokens"],
+		"total_tokens":      t.sumTokens(usage["input_tokens"], usage["output_tokens"]),
+	}
+	if cacheRead, ok := usage["cache_read_input_tokens"]; ok {
+		converted["cache_read_input_tokens"] = cacheRead
+		converted["prompt_tokens_details"] = map[string]interface{}{"cached_tokens": cacheRead}
+	}
+	if cacheCreation, ok := usage["cache_creation_input_tokens"]; ok {
+		converted["cache_creation_input_tokens"] = cacheCreation
+	}
+	return converted
+}
+
 // convertStopReason converts Anthropic stop reason to OpenAI finish reason
 func (t *AnthropicTransformer) convertStopReason(stopReason interface{}) string {
 	reason, _ := stopReason.(string)
@@ -547,11 +571,7 @@ func (t *AnthropicTransformer) transformStreamEvent(event *SSEEvent, state *anth
 		// Send final chunk with usage
 		if state.usage != nil {
 			chunk := t.createStreamChunk(state, nil)
-			chunk["usage"] = map[string]interface{}{
-				"prompt_tokens":     state.usage["input_tokens"],
-				"completion_tokens": state.usage["output_tokens"],
-				"total_tokens":      t.sumTokens(state.usage["input_tokens"], state.usage["output_tokens"]),
-			}
+			chunk["usage"] = t.convertUsage(state.usage)
 			transformed = append(transformed, t.createSSEEvent(chunk))
 		}
 
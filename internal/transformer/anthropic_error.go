@@ -0,0 +1,215 @@
+package transformer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxErrorMessageLength caps messages taken from raw provider error bodies
+const maxErrorMessageLength = 500
+
+// AnthropicErrorTransformer converts provider error responses into the
+// Anthropic error envelope expected by /v1/messages clients
+type AnthropicErrorTransformer struct {
+	BaseTransformer
+}
+
+// NewAnthropicErrorTransformer creates a new Anthropic error transformer
+func NewAnthropicErrorTransformer() *AnthropicErrorTransformer {
+	return &AnthropicErrorTransformer{
+		BaseTransformer: *NewBaseTransformer("anthropic-error", ""),
+	}
+}
+
+// TransformResponseOut rewrites error responses as
+// {"type":"error","error":{"type":...,"message":...}}, keeping the status
+// code and headers such as Retry-After. Successful responses pass through
+// unchanged.
+func (t *AnthropicErrorTransformer) TransformResponseOut(ctx context.Context, response *http.Response) (*http.Response, error) {
+	if response == nil || response.StatusCode < http.StatusBadRequest || response.Body == nil {
+		return response, nil
+	}
+
+	body, err := io.ReadAll(response.Body)
+	_ = response.Body.Close() // Safe to ignore: body has been fully read
+	if err != nil {
+		return nil, fmt.Errorf("failed to read error response: %w", err)
+	}
+
+	data := body
+	if !isAnthropicErrorBody(body) {
+		errorType, message := parseProviderError(response.StatusCode, body)
+		data, err = json.Marshal(map[string]interface{}{
+			"type": "error",
+			"error": map[string]interface{}{
+				"type":    errorType,
+				"message": message,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal error response: %w", err)
+		}
+	}
+
+	header := response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Type", "application/json")
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	header.Del("Content-Encoding")
+
+	return &http.Response{
+		Status:        response.Status,
+		StatusCode:    response.StatusCode,
+		Proto:         response.Proto,
+		ProtoMajor:    response.ProtoMajor,
+		ProtoMinor:    response.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       response.Request,
+	}, nil
+}
+
+// isAnthropicErrorBody reports whether a body already uses the Anthropic
+// error envelope
+func isAnthropicErrorBody(body []byte) bool {
+	var envelope struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return false
+	}
+	return envelope.Type == "error" && envelope.Error.Type != "" && envelope.Error.Message != ""
+}
+
+// parseProviderError extracts the Anthropic error type and message from a
+// provider error body. OpenAI, Gemini and plain-text bodies are understood.
+func parseProviderError(statusCode int, body []byte) (string, string) {
+	errorType := anthropicErrorType(statusCode)
+	message := ""
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		switch errValue := parsed["error"].(type) {
+		case map[string]interface{}:
+			message, _ = errValue["message"].(string)
+			// OpenAI-style type/code or Gemini-style status hints
+			for _, field := range []string{"type", "code", "status"} {
+				if hint, ok := errValue[field].(string); ok {
+					if hinted := anthropicErrorTypeFromHint(hint); hinted != "" {
+						errorType = hinted
+						break
+					}
+				}
+			}
+		case string:
+			message = errValue
+		}
+		if message == "" {
+			for _, field := range []string{"message", "detail"} {
+				if text, ok := parsed[field].(string); ok && text != "" {
+					message = text
+					break
+				}
+			}
+		}
+	} else {
+		message = strings.TrimSpace(string(body))
+	}
+
+	if message == "" {
+		message = fmt.Sprintf("Provider returned status %d", statusCode)
+	}
+	if len(message) > maxErrorMessageLength {
+		message = message[:maxErrorMessageLength-3] + "..."
+	}
+
+	return errorType, message
+}
+
+// anthropicErrorType maps an HTTP status code to an Anthropic error type
+func anthropicErrorType(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		if statusCode < http.StatusInternalServerError {
+			return "invalid_request_error"
+		}
+		return "api_error"
+	}
+}
+
+// anthropicErrorTypeFromHint maps provider error types and codes that are more
+// specific than the status code, returning "" for unknown hints
+func anthropicErrorTypeFromHint(hint string) string {
+	switch strings.ToLower(hint) {
+	case "rate_limit_exceeded", "rate_limit_error", "insufficient_quota", "resource_exhausted":
+		return "rate_limit_error"
+	case "overloaded", "overloaded_error", "server_overloaded", "unavailable":
+		return "overloaded_error"
+	case "invalid_api_key", "unauthenticated":
+		return "authentication_error"
+	case "permission_denied":
+		return "permission_error"
+	case "context_length_exceeded", "invalid_argument":
+		return "invalid_request_error"
+	}
+	return ""
+}
+
+// StreamErrorEvent returns an Anthropic error event for an upstream stream
+// event that reports an error, or nil for any other event. Anthropic error
+// events and OpenAI-style {"error":...} chunks are recognized.
+func StreamErrorEvent(event *SSEEvent) *SSEEvent {
+	data := []byte(strings.TrimSpace(event.Data))
+	if event.Event != "error" {
+		var chunk map[string]interface{}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil
+		}
+		if chunk["error"] == nil && chunk["type"] != "error" {
+			return nil
+		}
+	}
+
+	if isAnthropicErrorBody(data) {
+		return &SSEEvent{Event: "error", Data: string(data)}
+	}
+	return NewStreamErrorEvent(parseProviderError(http.StatusInternalServerError, data))
+}
+
+// NewStreamErrorEvent builds an Anthropic error event
+func NewStreamErrorEvent(errorType, message string) *SSEEvent {
+	return anthropicEvent("error", map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    errorType,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,182 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestAnthropicErrorTransformer(t *testing.T) {
+	transformer := NewAnthropicErrorTransformer()
+
+	tests := []struct {
+		name        string
+		statusCode  int
+		body        string
+		wantType    string
+		wantMessage string
+	}{
+		{
+			name:        "OpenAIRateLimit",
+			statusCode:  http.StatusTooManyRequests,
+			body:        `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`,
+			wantType:    "rate_limit_error",
+			wantMessage: "Rate limit reached",
+		},
+		{
+			name:        "OpenAIQuotaOnForbidden",
+			statusCode:  http.StatusForbidden,
+			body:        `{"error":{"message":"You exceeded your quota","type":"insufficient_quota"}}`,
+			wantType:    "rate_limit_error",
+			wantMessage: "You exceeded your quota",
+		},
+		{
+			name:        "GeminiStatus",
+			statusCode:  http.StatusTooManyRequests,
+			body:        `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`,
+			wantType:    "rate_limit_error",
+			wantMessage: "Quota exceeded",
+		},
+		{
+			name:        "Overloaded",
+			statusCode:  http.StatusServiceUnavailable,
+			body:        `{"error":"overloaded"}`,
+			wantType:    "overloaded_error",
+			wantMessage: "overloaded",
+		},
+		{
+			name:        "Unauthorized",
+			statusCode:  http.StatusUnauthorized,
+			body:        `{"message":"Invalid token"}`,
+			wantType:    "authentication_error",
+			wantMessage: "Invalid token",
+		},
+		{
+			name:        "PlainText",
+			statusCode:  http.StatusBadGateway,
+			body:        "upstream connect error",
+			wantType:    "api_error",
+			wantMessage: "upstream connect error",
+		},
+		{
+			name:        "EmptyBody",
+			statusCode:  http.StatusNotFound,
+			body:        "",
+			wantType:    "not_found_error",
+			wantMessage: "Provider returned status 404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.statusCode,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			out, err := transformer.TransformResponseOut(context.Background(), resp)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, tt.statusCode, out.StatusCode)
+
+			var envelope struct {
+				Type  string `json:"type"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			data, err := io.ReadAll(out.Body)
+			testutil.AssertNoError(t, err)
+			testutil.AssertNoError(t, json.Unmarshal(data, &envelope))
+			testutil.AssertEqual(t, "error", envelope.Type)
+			testutil.AssertEqual(t, tt.wantType, envelope.Error.Type)
+			testutil.AssertEqual(t, tt.wantMessage, envelope.Error.Message)
+		})
+	}
+
+	t.Run("PreservesRetryAfter", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": []string{"30"}},
+			Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"slow down"}}`)),
+		}
+
+		out, err := transformer.TransformResponseOut(context.Background(), resp)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "30", out.Header.Get("Retry-After"))
+		testutil.AssertEqual(t, "application/json", out.Header.Get("Content-Type"))
+	})
+
+	t.Run("AnthropicBodyUnchanged", func(t *testing.T) {
+		body := `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+		resp := &http.Response{
+			StatusCode: 529,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+
+		out, err := transformer.TransformResponseOut(context.Background(), resp)
+		testutil.AssertNoError(t, err)
+		data, err := io.ReadAll(out.Body)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, body, string(data))
+	})
+
+	t.Run("SuccessUnchanged", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"choices":[]}`)),
+		}
+
+		out, err := transformer.TransformResponseOut(context.Background(), resp)
+		testutil.AssertNoError(t, err)
+		testutil.AssertTrue(t, out == resp, "Expected successful response to pass through")
+	})
+}
+
+func TestStreamErrorEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		event       *SSEEvent
+		wantType    string
+		wantMessage string
+	}{
+		{"openai error chunk", &SSEEvent{Data: `{"error":{"message":"Rate limit reached","type":"rate_limit_exceeded"}}`}, "rate_limit_error", "Rate limit reached"},
+		{"anthropic error event", &SSEEvent{Event: "error", Data: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`}, "overloaded_error", "Overloaded"},
+		{"plain text error event", &SSEEvent{Event: "error", Data: "connection lost"}, "api_error", "connection lost"},
+		{"string error", &SSEEvent{Data: `{"error":"boom"}`}, "api_error", "boom"},
+		{"content chunk", &SSEEvent{Data: `{"choices":[{"delta":{"content":"hi"}}]}`}, "", ""},
+		{"null error field", &SSEEvent{Data: `{"choices":[],"error":null}`}, "", ""},
+		{"done marker", &SSEEvent{Data: "[DONE]"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := StreamErrorEvent(tt.event)
+			if tt.wantType == "" {
+				testutil.AssertTrue(t, event == nil, "Expected no error event")
+				return
+			}
+
+			testutil.AssertEqual(t, "error", event.Event)
+			var data struct {
+				Type  string `json:"type"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			testutil.AssertNoError(t, json.Unmarshal([]byte(event.Data), &data))
+			testutil.AssertEqual(t, "error", data.Type)
+			testutil.AssertEqual(t, tt.wantType, data.Error.Type)
+			testutil.AssertEqual(t, tt.wantMessage, data.Error.Message)
+		})
+	}
+}
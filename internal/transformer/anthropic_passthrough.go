@@ -0,0 +1,73 @@
+package transformer
+
+// openAIOnlyFields are request fields that only exist in the OpenAI format
+var openAIOnlyFields = []string{
+	"stop", "n", "user", "seed", "logprobs", "top_logprobs", "logit_bias",
+	"presence_penalty", "frequency_penalty", "response_format",
+	"max_completion_tokens", "functions", "function_call",
+	"parallel_tool_calls", "stream_options",
+}
+
+// IsAnthropicRequest reports whether a request body is already in the
+// Anthropic Messages format and can be sent to Anthropic unconverted. Bodies
+// that are valid in both formats, such as plain text conversations, count as
+// Anthropic.
+func IsAnthropicRequest(reqMap map[string]interface{}) bool {
+	for field := range reqMap {
+		if IsOpenAIOnlyField(field) {
+			return false
+		}
+	}
+
+	// OpenAI uses a boolean thinking flag and string tool choices
+	if _, ok := reqMap["thinking"].(bool); ok {
+		return false
+	}
+	if _, ok := reqMap["tool_choice"].(string); ok {
+		return false
+	}
+
+	if tools, ok := reqMap["tools"].([]interface{}); ok {
+		for _, tool := range tools {
+			if toolMap, ok := tool.(map[string]interface{}); ok && toolMap["function"] != nil {
+				return false
+			}
+		}
+	}
+
+	messages, ok := reqMap["messages"].([]interface{})
+	if !ok {
+		return false
+	}
+	for _, msg := range messages {
+		msgMap, ok := msg.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if role, _ := msgMap["role"].(string); role != "user" && role != "assistant" {
+			return false
+		}
+		if msgMap["tool_calls"] != nil {
+			return false
+		}
+		if blocks, ok := msgMap["content"].([]interface{}); ok {
+			for _, block := range blocks {
+				if blockMap, ok := block.(map[string]interface{}); ok && blockMap["type"] == "image_url" {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// IsOpenAIOnlyField reports whether a request field exists only in the OpenAI
+// format and would be rejected by the Anthropic Messages API
+func IsOpenAIOnlyField(field string) bool {
+	for _, openAIField := range openAIOnlyFields {
+		if field == openAIField {
+			return true
+		}
+	}
+	return false
+}
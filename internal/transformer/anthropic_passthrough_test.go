@@ -0,0 +1,85 @@
+package transformer
+
+import "testing"
+
+func TestIsAnthropicRequest(t *testing.T) {
+	userMessage := map[string]interface{}{"role": "user", "content": "Hello"}
+
+	tests := []struct {
+		name    string
+		request map[string]interface{}
+		want    bool
+	}{
+		{
+			name:    "plain conversation",
+			request: map[string]interface{}{"model": "claude-3", "messages": []interface{}{userMessage}},
+			want:    true,
+		},
+		{
+			name: "anthropic system, tools and thinking",
+			request: map[string]interface{}{
+				"system":      []interface{}{map[string]interface{}{"type": "text", "text": "Be brief"}},
+				"messages":    []interface{}{userMessage},
+				"tools":       []interface{}{map[string]interface{}{"name": "get_weather", "input_schema": map[string]interface{}{"type": "object"}}},
+				"tool_choice": map[string]interface{}{"type": "any"},
+				"thinking":    map[string]interface{}{"type": "enabled", "budget_tokens": float64(1024)},
+			},
+			want: true,
+		},
+		{
+			name: "anthropic tool result",
+			request: map[string]interface{}{"messages": []interface{}{
+				map[string]interface{}{"role": "user", "content": []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "t1", "content": "ok"},
+				}},
+			}},
+			want: true,
+		},
+		{
+			name:    "system message",
+			request: map[string]interface{}{"messages": []interface{}{map[string]interface{}{"role": "system", "content": "Be brief"}, userMessage}},
+		},
+		{
+			name:    "tool message",
+			request: map[string]interface{}{"messages": []interface{}{map[string]interface{}{"role": "tool", "tool_call_id": "t1", "content": "ok"}}},
+		},
+		{
+			name:    "tool calls",
+			request: map[string]interface{}{"messages": []interface{}{map[string]interface{}{"role": "assistant", "tool_calls": []interface{}{}}}},
+		},
+		{
+			name: "image_url block",
+			request: map[string]interface{}{"messages": []interface{}{
+				map[string]interface{}{"role": "user", "content": []interface{}{map[string]interface{}{"type": "image_url"}}},
+			}},
+		},
+		{
+			name:    "openai tools",
+			request: map[string]interface{}{"messages": []interface{}{userMessage}, "tools": []interface{}{map[string]interface{}{"type": "function", "function": map[string]interface{}{}}}},
+		},
+		{
+			name:    "string tool_choice",
+			request: map[string]interface{}{"messages": []interface{}{userMessage}, "tool_choice": "auto"},
+		},
+		{
+			name:    "boolean thinking",
+			request: map[string]interface{}{"messages": []interface{}{userMessage}, "thinking": true},
+		},
+		{
+			name:    "openai only field",
+			request: map[string]interface{}{"messages": []interface{}{userMessage}, "stop": []interface{}{"END"}},
+		},
+		{
+			name:    "no messages",
+			request: map[string]interface{}{"model": "claude-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAnthropicRequest(tt.request); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
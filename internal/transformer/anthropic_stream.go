@@ -0,0 +1,371 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// AnthropicStreamTransformer converts OpenAI-style streaming chunks into the
+// Anthropic Messages event sequence expected by /v1/messages clients
+type AnthropicStreamTransformer struct {
+	BaseTransformer
+}
+
+// NewAnthropicStreamTransformer creates a new Anthropic stream transformer
+func NewAnthropicStreamTransformer() *AnthropicStreamTransformer {
+	return &AnthropicStreamTransformer{
+		BaseTransformer: *NewBaseTransformer("anthropic-stream", ""),
+	}
+}
+
+// anthropicEventState tracks the Anthropic message being built from chunks
+type anthropicEventState struct {
+	started      bool
+	messageID    string
+	model        string
+	blockIndex   int    // Index of the open content block, -1 when none is open
+	blockType    string // Type of the open content block
+	toolIndex    int    // OpenAI tool_calls index of the open tool_use block
+	nextIndex    int
+	stopReason   string
+	inputTokens  int
+	outputTokens int
+}
+
+// TransformResponseOut converts a streaming response into Anthropic events.
+// Non-streaming responses pass through unchanged.
+func (t *AnthropicStreamTransformer) TransformResponseOut(ctx context.Context, response *http.Response) (*http.Response, error) {
+	if !isStreamingResponse(response) {
+		return response, nil
+	}
+
+	reader, _ := NewStreamReader(response)
+	pr, pw := io.Pipe()
+
+	header := response.Header.Clone()
+	header.Set("Content-Type", "text/event-stream")
+	header.Del("Content-Length")
+
+	newResp := &http.Response{
+		Status:     response.Status,
+		StatusCode: response.StatusCode,
+		Proto:      response.Proto,
+		ProtoMajor: response.ProtoMajor,
+		ProtoMinor: response.ProtoMinor,
+		Header:     header,
+		// Closing the body also closes the upstream stream so the
+		// conversion goroutine never outlives the client
+		Body:          &pipeBody{PipeReader: pr, upstream: reader},
+		ContentLength: -1,
+		Request:       response.Request,
+	}
+
+	go func() {
+		defer reader.Close()
+		defer pw.Close()
+		writer := NewSSEWriter(pw)
+
+		state := &anthropicEventState{blockIndex: -1}
+		for {
+			event, err := reader.ReadEvent()
+			if err != nil {
+				if err != io.EOF {
+					// Pass the failure on instead of ending the message
+					// as if it had completed
+					utils.GetLogger().Errorf("Error reading SSE event: %v", err)
+					_ = pw.CloseWithError(err) // Safe to ignore: always nil
+					return
+				}
+				break
+			}
+			if event.Data == "" {
+				continue
+			}
+			if strings.TrimSpace(event.Data) == "[DONE]" {
+				break
+			}
+
+			// An upstream error ends the message without a message_stop
+			if errEvent := StreamErrorEvent(event); errEvent != nil {
+				_ = writer.WriteEvent(errEvent) // Safe to ignore: the stream ends either way
+				return
+			}
+
+			for _, evt := range t.convertEvent(event, state) {
+				if err := writer.WriteEvent(evt); err != nil {
+					return
+				}
+			}
+		}
+
+		for _, evt := range t.finish(state) {
+			if err := writer.WriteEvent(evt); err != nil {
+				return
+			}
+		}
+	}()
+
+	return newResp, nil
+}
+
+// convertEvent converts one upstream event. Events that are already in the
+// Anthropic format are passed through.
+func (t *AnthropicStreamTransformer) convertEvent(event *SSEEvent, state *anthropicEventState) []*SSEEvent {
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+		utils.GetLogger().Warnf("Skipping malformed stream chunk: %v", err)
+		return nil
+	}
+
+	if eventType, ok := chunk["type"].(string); ok && isAnthropicEventType(eventType) {
+		if eventType == "message_start" || eventType == "message_stop" {
+			// The upstream produces the whole sequence itself
+			state.started = true
+			state.stopReason = "passthrough"
+		}
+		return []*SSEEvent{{Event: eventType, Data: event.Data}}
+	}
+
+	var events []*SSEEvent
+	if !state.started {
+		state.started = true
+		state.messageID, _ = chunk["id"].(string)
+		if state.messageID == "" {
+			state.messageID = "msg_" + uuid.New().String()
+		}
+		state.model, _ = chunk["model"].(string)
+		events = append(events, t.messageStart(state))
+	}
+
+	if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+		if prompt, ok := usage["prompt_tokens"].(float64); ok {
+			state.inputTokens = int(prompt)
+		}
+		if completion, ok := usage["completion_tokens"].(float64); ok {
+			state.outputTokens = int(completion)
+		}
+	}
+
+	choices, _ := chunk["choices"].([]interface{})
+	if len(choices) == 0 {
+		return events
+	}
+	choice, _ := choices[0].(map[string]interface{})
+
+	if delta, ok := choice["delta"].(map[string]interface{}); ok {
+		if text, ok := delta["content"].(string); ok && text != "" {
+			if state.blockType != "text" {
+				events = append(events, t.startBlock(state, "text", map[string]interface{}{
+					"type": "text",
+					"text": "",
+				})...)
+			}
+			events = append(events, t.blockDelta(state, map[string]interface{}{
+				"type": "text_delta",
+				"text": text,
+			}))
+		}
+
+		toolCalls, _ := delta["tool_calls"].([]interface{})
+		for _, item := range toolCalls {
+			toolCall, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			events = append(events, t.toolCallEvents(state, toolCall)...)
+		}
+	}
+
+	if finishReason, ok := choice["finish_reason"].(string); ok && finishReason != "" {
+		state.stopReason = convertFinishReason(finishReason)
+	}
+
+	return events
+}
+
+// toolCallEvents converts a tool call delta into tool_use block events
+func (t *AnthropicStreamTransformer) toolCallEvents(state *anthropicEventState, toolCall map[string]interface{}) []*SSEEvent {
+	var events []*SSEEvent
+
+	index := 0
+	if idx, ok := toolCall["index"].(float64); ok {
+		index = int(idx)
+	}
+	function, _ := toolCall["function"].(map[string]interface{})
+
+	// A tool call id or a new index marks the start of another tool call
+	id, _ := toolCall["id"].(string)
+	if id != "" || state.blockType != "tool_use" || state.toolIndex != index {
+		if id == "" {
+			id = "toolu_" + uuid.New().String()
+		}
+		name, _ := function["name"].(string)
+		events = append(events, t.startBlock(state, "tool_use", map[string]interface{}{
+			"type":  "tool_use",
+			"id":    id,
+			"name":  name,
+			"input": map[string]interface{}{},
+		})...)
+		state.toolIndex = index
+	}
+
+	if arguments, ok := function["arguments"].(string); ok && arguments != "" {
+		events = append(events, t.blockDelta(state, map[string]interface{}{
+			"type":         "input_json_delta",
+			"partial_json": arguments,
+		}))
+	}
+
+	return events
+}
+
+// finish closes the open block and ends the message
+func (t *AnthropicStreamTransformer) finish(state *anthropicEventState) []*SSEEvent {
+	if !state.started || state.stopReason == "passthrough" {
+		return nil
+	}
+
+	events := t.stopBlock(state)
+
+	stopReason := state.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	usage := map[string]interface{}{"output_tokens": state.outputTokens}
+	if state.inputTokens > 0 {
+		usage["input_tokens"] = state.inputTokens
+	}
+
+	events = append(events,
+		anthropicEvent("message_delta", map[string]interface{}{
+			"type": "message_delta",
+			"delta": map[string]interface{}{
+				"stop_reason":   stopReason,
+				"stop_sequence": nil,
+			},
+			"usage": usage,
+		}),
+		anthropicEvent("message_stop", map[string]interface{}{"type": "message_stop"}),
+	)
+	return events
+}
+
+// messageStart builds the message_start event
+func (t *AnthropicStreamTransformer) messageStart(state *anthropicEventState) *SSEEvent {
+	return anthropicEvent("message_start", map[string]interface{}{
+		"type": "message_start",
+		"message": map[string]interface{}{
+			"id":            state.messageID,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         state.model,
+			"content":       []interface{}{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage": map[string]interface{}{
+				"input_tokens":  state.inputTokens,
+				"output_tokens": 0,
+			},
+		},
+	})
+}
+
+// startBlock closes any open block and starts a new one
+func (t *AnthropicStreamTransformer) startBlock(state *anthropicEventState, blockType string, block map[string]interface{}) []*SSEEvent {
+	events := t.stopBlock(state)
+
+	state.blockIndex = state.nextIndex
+	state.blockType = blockType
+	state.nextIndex++
+
+	return append(events, anthropicEvent("content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         state.blockIndex,
+		"content_block": block,
+	}))
+}
+
+// stopBlock closes the open block, if any
+func (t *AnthropicStreamTransformer) stopBlock(state *anthropicEventState) []*SSEEvent {
+	if state.blockIndex < 0 {
+		return nil
+	}
+
+	event := anthropicEvent("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": state.blockIndex,
+	})
+	state.blockIndex = -1
+	state.blockType = ""
+	return []*SSEEvent{event}
+}
+
+// blockDelta builds a content_block_delta for the open block
+func (t *AnthropicStreamTransformer) blockDelta(state *anthropicEventState, delta map[string]interface{}) *SSEEvent {
+	return anthropicEvent("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": state.blockIndex,
+		"delta": delta,
+	})
+}
+
+// anthropicEvent builds a named SSE event with JSON data
+func anthropicEvent(eventType string, data map[string]interface{}) *SSEEvent {
+	jsonData, _ := json.Marshal(data) // Safe to ignore: maps of plain values always marshal
+	return &SSEEvent{Event: eventType, Data: string(jsonData)}
+}
+
+// isAnthropicEventType reports whether a chunk type is an Anthropic stream event
+func isAnthropicEventType(eventType string) bool {
+	switch eventType {
+	case "message_start", "message_delta", "message_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"ping", "error":
+		return true
+	}
+	return false
+}
+
+// convertFinishReason maps an OpenAI finish_reason to an Anthropic stop_reason
+func convertFinishReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
+
+// isStreamingResponse reports whether a response carries a stream
+func isStreamingResponse(response *http.Response) bool {
+	if response == nil || response.Body == nil {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	mediaType = strings.ToLower(mediaType)
+	return mediaType == "text/event-stream" || ndjsonMediaTypes[mediaType]
+}
+
+// pipeBody closes the upstream stream along with the pipe
+type pipeBody struct {
+	*io.PipeReader
+	upstream io.Closer
+}
+
+// Close closes the pipe and the upstream stream
+func (b *pipeBody) Close() error {
+	_ = b.PipeReader.Close() // Safe to ignore: closing a pipe never fails
+	return b.upstream.Close()
+}
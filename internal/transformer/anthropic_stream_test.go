@@ -0,0 +1,192 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestAnthropicStreamTransformer(t *testing.T) {
+	transformer := NewAnthropicStreamTransformer()
+
+	// convert runs an upstream stream through the transformer and returns
+	// the resulting events
+	convert := func(t *testing.T, contentType, body string) []*SSEEvent {
+		t.Helper()
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{contentType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+
+		out, err := transformer.TransformResponseOut(context.Background(), resp)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "text/event-stream", out.Header.Get("Content-Type"))
+
+		reader := NewSSEReader(out.Body)
+		defer reader.Close()
+		var events []*SSEEvent
+		for {
+			event, err := reader.ReadEvent()
+			if err == io.EOF {
+				return events
+			}
+			testutil.AssertNoError(t, err)
+			events = append(events, event)
+		}
+	}
+
+	eventTypes := func(events []*SSEEvent) string {
+		var types []string
+		for _, event := range events {
+			types = append(types, event.Event)
+		}
+		return strings.Join(types, ",")
+	}
+
+	decode := func(t *testing.T, event *SSEEvent) map[string]interface{} {
+		t.Helper()
+		var data map[string]interface{}
+		testutil.AssertNoError(t, json.Unmarshal([]byte(event.Data), &data))
+		return data
+	}
+
+	t.Run("TextStream", func(t *testing.T) {
+		events := convert(t, "text/event-stream", strings.Join([]string{
+			`data: {"id":"chatcmpl-1","model":"gpt-4","choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`data: {"id":"chatcmpl-1","model":"gpt-4","choices":[{"delta":{"content":"lo"},"finish_reason":"stop"}]}`,
+			`data: {"id":"chatcmpl-1","model":"gpt-4","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":2}}`,
+			`data: [DONE]`,
+		}, "\n\n")+"\n\n")
+
+		testutil.AssertEqual(t, "message_start,content_block_start,content_block_delta,content_block_delta,content_block_stop,message_delta,message_stop", eventTypes(events))
+
+		start := decode(t, events[0])["message"].(map[string]interface{})
+		testutil.AssertEqual(t, "chatcmpl-1", start["id"])
+		testutil.AssertEqual(t, "gpt-4", start["model"])
+
+		delta := decode(t, events[2])["delta"].(map[string]interface{})
+		testutil.AssertEqual(t, "text_delta", delta["type"])
+		testutil.AssertEqual(t, "Hel", delta["text"])
+
+		messageDelta := decode(t, events[5])
+		testutil.AssertEqual(t, "end_turn", messageDelta["delta"].(map[string]interface{})["stop_reason"])
+		usage := messageDelta["usage"].(map[string]interface{})
+		testutil.AssertEqual(t, float64(2), usage["output_tokens"])
+		testutil.AssertEqual(t, float64(12), usage["input_tokens"])
+	})
+
+	t.Run("ToolUseStream", func(t *testing.T) {
+		events := convert(t, "text/event-stream", strings.Join([]string{
+			`data: {"id":"c","choices":[{"delta":{"content":"Checking"}}]}`,
+			`data: {"id":"c","choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`data: {"id":"c","choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`data: {"id":"c","choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+			`data: {"id":"c","choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+			`data: {"id":"c","choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			`data: [DONE]`,
+		}, "\n\n")+"\n\n")
+
+		testutil.AssertEqual(t, strings.Join([]string{
+			"message_start",
+			"content_block_start", "content_block_delta", "content_block_stop",
+			"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+			"content_block_start", "content_block_delta", "content_block_stop",
+			"message_delta", "message_stop",
+		}, ","), eventTypes(events))
+
+		toolStart := decode(t, events[4])
+		testutil.AssertEqual(t, float64(1), toolStart["index"])
+		block := toolStart["content_block"].(map[string]interface{})
+		testutil.AssertEqual(t, "tool_use", block["type"])
+		testutil.AssertEqual(t, "call_1", block["id"])
+		testutil.AssertEqual(t, "get_weather", block["name"])
+
+		argDelta := decode(t, events[6])["delta"].(map[string]interface{})
+		testutil.AssertEqual(t, "input_json_delta", argDelta["type"])
+		testutil.AssertEqual(t, `"Paris"}`, argDelta["partial_json"])
+
+		secondTool := decode(t, events[8])
+		testutil.AssertEqual(t, float64(2), secondTool["index"])
+		testutil.AssertEqual(t, "get_time", secondTool["content_block"].(map[string]interface{})["name"])
+
+		stop := decode(t, events[11])["delta"].(map[string]interface{})
+		testutil.AssertEqual(t, "tool_use", stop["stop_reason"])
+	})
+
+	t.Run("NDJSONStream", func(t *testing.T) {
+		events := convert(t, "application/x-ndjson",
+			`{"id":"c","choices":[{"delta":{"content":"Hi"},"finish_reason":"length"}]}`+"\n")
+
+		testutil.AssertEqual(t, "message_start,content_block_start,content_block_delta,content_block_stop,message_delta,message_stop", eventTypes(events))
+		stop := decode(t, events[4])["delta"].(map[string]interface{})
+		testutil.AssertEqual(t, "max_tokens", stop["stop_reason"])
+	})
+
+	t.Run("AnthropicEventsPassThrough", func(t *testing.T) {
+		events := convert(t, "text/event-stream", strings.Join([]string{
+			"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}",
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}",
+		}, "\n\n")+"\n\n")
+
+		testutil.AssertEqual(t, "message_start,message_stop", eventTypes(events))
+		testutil.AssertEqual(t, `{"type":"message_stop"}`, events[1].Data)
+	})
+
+	t.Run("UpstreamErrorChunk", func(t *testing.T) {
+		events := convert(t, "text/event-stream", strings.Join([]string{
+			`data: {"id":"chatcmpl-1","model":"gpt-4","choices":[{"delta":{"content":"Hel"}}]}`,
+			`data: {"error":{"message":"Rate limit reached","type":"rate_limit_exceeded"}}`,
+			`data: {"id":"chatcmpl-1","model":"gpt-4","choices":[{"delta":{"content":"lo"}}]}`,
+		}, "\n\n")+"\n\n")
+
+		// The message ends with the error instead of a message_stop
+		testutil.AssertEqual(t, "message_start,content_block_start,content_block_delta,error", eventTypes(events))
+		errData := decode(t, events[3])["error"].(map[string]interface{})
+		testutil.AssertEqual(t, "rate_limit_error", errData["type"])
+		testutil.AssertEqual(t, "Rate limit reached", errData["message"])
+	})
+
+	t.Run("UpstreamReadFailure", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body: io.NopCloser(io.MultiReader(
+				strings.NewReader(`data: {"id":"chatcmpl-1","choices":[{"delta":{"content":"Hel"}}]}`+"\n\n"),
+				iotest.ErrReader(io.ErrUnexpectedEOF),
+			)),
+		}
+
+		out, err := transformer.TransformResponseOut(context.Background(), resp)
+		testutil.AssertNoError(t, err)
+
+		// The failure reaches the reader instead of a completed message
+		data, err := io.ReadAll(out.Body)
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, string(data), "content_block_delta")
+		testutil.AssertFalse(t, strings.Contains(string(data), "message_stop"))
+	})
+
+	t.Run("EmptyStream", func(t *testing.T) {
+		events := convert(t, "text/event-stream", "data: [DONE]\n\n")
+		testutil.AssertEqual(t, 0, len(events))
+	})
+
+	t.Run("NonStreamingUnchanged", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"choices":[]}`)),
+		}
+
+		out, err := transformer.TransformResponseOut(context.Background(), resp)
+		testutil.AssertNoError(t, err)
+		testutil.AssertTrue(t, out == resp, "Expected non-streaming response to pass through")
+	})
+}
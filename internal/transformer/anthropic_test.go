@@ -167,8 +167,8 @@ func TestAnthropicTransformer_TransformRequestIn(t *testing.T) {
 		}
 
 		toolChoice := resultMap["tool_choice"].(map[string]interface{})
-		if toolChoice["type"] != "any" {
-			t.Errorf("Expected tool_choice type 'any', got %v", toolChoice["type"])
+		if toolChoice["type"] != "auto" {
+			t.Errorf("Expected tool_choice type 'auto', got %v", toolChoice["type"])
 		}
 	})
 
@@ -205,6 +205,113 @@ func TestAnthropicTransformer_TransformRequestIn(t *testing.T) {
 		}
 	})
 
+	t.Run("StopSequencesCarried", func(t *testing.T) {
+		request := map[string]interface{}{
+			"model":    "claude-3-haiku",
+			"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+			"stop":     []interface{}{"END"},
+		}
+
+		result, err := transformer.TransformRequestIn(ctx, request, "anthropic")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		stop, ok := result.(map[string]interface{})["stop_sequences"].([]interface{})
+		if !ok || len(stop) != 1 || stop[0] != "END" {
+			t.Errorf("Expected stop to be carried as stop_sequences, got %v", result.(map[string]interface{})["stop_sequences"])
+		}
+	})
+
+	t.Run("UserToMetadata", func(t *testing.T) {
+		request := map[string]interface{}{
+			"model":    "claude-3-haiku",
+			"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+			"user":     "user-123",
+		}
+
+		result, err := transformer.TransformRequestIn(ctx, request, "anthropic")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		resultMap := result.(map[string]interface{})
+		if _, exists := resultMap["user"]; exists {
+			t.Error("Expected user to be removed")
+		}
+		metadata, ok := resultMap["metadata"].(map[string]interface{})
+		if !ok || metadata["user_id"] != "user-123" {
+			t.Errorf("Expected metadata.user_id 'user-123', got %v", resultMap["metadata"])
+		}
+	})
+
+	t.Run("CacheControlPreserved", func(t *testing.T) {
+		cacheControl := map[string]interface{}{"type": "ephemeral"}
+		request := map[string]interface{}{
+			"model": "claude-3-haiku",
+			"system": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Long context", "cache_control": cacheControl},
+			},
+			"messages": []interface{}{
+				map[string]interface{}{
+					"role": "user",
+					"content": []interface{}{
+						map[string]interface{}{"type": "text", "text": "Hello", "cache_control": cacheControl},
+					},
+				},
+			},
+			"tools": []interface{}{
+				map[string]interface{}{
+					"type":          "function",
+					"function":      map[string]interface{}{"name": "lookup", "parameters": map[string]interface{}{"type": "object"}},
+					"cache_control": cacheControl,
+				},
+			},
+		}
+
+		result, err := transformer.TransformRequestIn(ctx, request, "anthropic")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		resultMap := result.(map[string]interface{})
+		system, ok := resultMap["system"].([]interface{})
+		if !ok || len(system) != 1 {
+			t.Fatalf("Expected system blocks to be kept, got %v", resultMap["system"])
+		}
+		if system[0].(map[string]interface{})["cache_control"] == nil {
+			t.Error("Expected cache_control on the system block")
+		}
+		tools := resultMap["tools"].([]interface{})
+		if tools[0].(map[string]interface{})["cache_control"] == nil {
+			t.Error("Expected cache_control on the tool")
+		}
+		if !HasCacheControl(resultMap) {
+			t.Error("Expected transformed request to report cache_control markers")
+		}
+	})
+
+	t.Run("SystemMessageBlocks", func(t *testing.T) {
+		blocks := []interface{}{map[string]interface{}{"type": "text", "text": "Be brief"}}
+		request := map[string]interface{}{
+			"model": "claude-3-haiku",
+			"messages": []interface{}{
+				map[string]interface{}{"role": "system", "content": blocks},
+				map[string]interface{}{"role": "user", "content": "Hello"},
+			},
+		}
+
+		result, err := transformer.TransformRequestIn(ctx, request, "anthropic")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		system, ok := result.(map[string]interface{})["system"].([]interface{})
+		if !ok || len(system) != 1 {
+			t.Errorf("Expected system message blocks to be kept, got %v", result.(map[string]interface{})["system"])
+		}
+	})
+
 	t.Run("InvalidRequest", func(t *testing.T) {
 		_, err := transformer.TransformRequestIn(ctx, "invalid", "anthropic")
 		if err == nil {
@@ -295,6 +402,80 @@ func TestAnthropicTransformer_TransformResponseOut(t *testing.T) {
 		if totalTokensValue != float64(25) {
 			t.Errorf("Expected total_tokens 25, got %v (type: %T)", totalTokensValue, totalTokensValue)
 		}
+		if openaiResp["id"] != "msg_123" {
+			t.Errorf("Expected id 'msg_123', got %v", openaiResp["id"])
+		}
+	})
+
+	t.Run("MissingID", func(t *testing.T) {
+		body := []byte(`{"model":"claude-3-haiku","content":[{"type":"text","text":"Hi"}],"stop_reason":"end_turn"}`)
+		transformID := func() interface{} {
+			resp := &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(bytes.NewReader(body)),
+			}
+			result, err := transformer.TransformResponseOut(ctx, resp)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var openaiResp map[string]interface{}
+			if err := json.NewDecoder(result.Body).Decode(&openaiResp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			return openaiResp["id"]
+		}
+
+		id, _ := transformID().(string)
+		if !strings.HasPrefix(id, "chatcmpl-") || len(id) == len("chatcmpl-") {
+			t.Errorf("Expected a generated chatcmpl- id, got %q", id)
+		}
+		if again := transformID(); again != id {
+			t.Errorf("Expected the same response to get the same id, got %v and %v", id, again)
+		}
+	})
+
+	t.Run("CacheUsage", func(t *testing.T) {
+		anthropicResp := map[string]interface{}{
+			"id":          "msg_789",
+			"model":       "claude-3-haiku",
+			"content":     []interface{}{map[string]interface{}{"type": "text", "text": "Hi"}},
+			"stop_reason": "end_turn",
+			"usage": map[string]interface{}{
+				"input_tokens":                5,
+				"output_tokens":               2,
+				"cache_read_input_tokens":     1000,
+				"cache_creation_input_tokens": 200,
+			},
+		}
+
+		body, _ := json.Marshal(anthropicResp)
+		resp := &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}
+
+		result, err := transformer.TransformResponseOut(ctx, resp)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		resultBody, _ := io.ReadAll(result.Body)
+		var openaiResp map[string]interface{}
+		json.Unmarshal(resultBody, &openaiResp)
+
+		usage := openaiResp["usage"].(map[string]interface{})
+		if usage["cache_read_input_tokens"] != float64(1000) {
+			t.Errorf("Expected cache_read_input_tokens 1000, got %v", usage["cache_read_input_tokens"])
+		}
+		if usage["cache_creation_input_tokens"] != float64(200) {
+			t.Errorf("Expected cache_creation_input_tokens 200, got %v", usage["cache_creation_input_tokens"])
+		}
+		details, ok := usage["prompt_tokens_details"].(map[string]interface{})
+		if !ok || details["cached_tokens"] != float64(1000) {
+			t.Errorf("Expected prompt_tokens_details.cached_tokens 1000, got %v", usage["prompt_tokens_details"])
+		}
 	})
 
 	t.Run("ResponseWithToolUse", func(t *testing.T) {
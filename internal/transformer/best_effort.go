@@ -0,0 +1,97 @@
+package transformer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// BestEffortTransformer is implemented by transformers whose failures should
+// not fail the request. The chain logs the error and continues with the
+// request or response the transformer was given.
+type BestEffortTransformer interface {
+	BestEffort() bool
+}
+
+// bestEffortTransformer marks a transformer as best-effort
+type bestEffortTransformer struct {
+	Transformer
+}
+
+// NewBestEffortTransformer wraps a transformer so the chain skips it when it
+// fails instead of aborting the request
+func NewBestEffortTransformer(t Transformer) Transformer {
+	return &bestEffortTransformer{Transformer: t}
+}
+
+// BestEffort reports that failures of the wrapped transformer are tolerated
+func (t *bestEffortTransformer) BestEffort() bool {
+	return true
+}
+
+// isBestEffort reports whether a transformer's failures are tolerated
+func isBestEffort(t Transformer) bool {
+	bestEffort, ok := t.(BestEffortTransformer)
+	return ok && bestEffort.BestEffort()
+}
+
+// copyRequest returns a copy of a request that a best-effort transformer can
+// modify without touching the original. Bodies that are not JSON objects are
+// returned as they are.
+func copyRequest(request interface{}) interface{} {
+	switch r := request.(type) {
+	case map[string]interface{}:
+		if copied, ok := copyJSONObject(r); ok {
+			return copied
+		}
+	case *RequestConfig:
+		copied := *r
+		if body, ok := r.Body.(map[string]interface{}); ok {
+			if copiedBody, ok := copyJSONObject(body); ok {
+				copied.Body = copiedBody
+			}
+		}
+		if r.Headers != nil {
+			copied.Headers = make(map[string]string, len(r.Headers))
+			for key, value := range r.Headers {
+				copied.Headers[key] = value
+			}
+		}
+		return &copied
+	}
+	return request
+}
+
+// copyJSONObject deep copies a decoded JSON object
+func copyJSONObject(object map[string]interface{}) (map[string]interface{}, bool) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, false
+	}
+	var copied map[string]interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, false
+	}
+	return copied, true
+}
+
+// bufferResponse reads a non-streaming response body into memory so it can
+// be replayed if a best-effort transformer fails after reading it. Streams
+// are left alone and reported as not replayable.
+func bufferResponse(response *http.Response) ([]byte, bool) {
+	if response == nil || response.Body == nil ||
+		strings.Contains(response.Header.Get("Content-Type"), "text/event-stream") {
+		return nil, false
+	}
+	data, err := io.ReadAll(response.Body)
+	_ = response.Body.Close() // Safe to ignore: the body has been read
+	if err != nil {
+		// Replay what was read, the next reader sees the same short body
+		response.Body = io.NopCloser(bytes.NewReader(data))
+		return nil, false
+	}
+	response.Body = io.NopCloser(bytes.NewReader(data))
+	return data, true
+}
@@ -0,0 +1,100 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+// partialFailTransformer changes its input before failing, like a
+// transformer that breaks halfway through
+type partialFailTransformer struct {
+	BaseTransformer
+}
+
+func (t *partialFailTransformer) TransformRequestIn(ctx context.Context, request interface{}, provider string) (interface{}, error) {
+	if reqMap, ok := request.(map[string]interface{}); ok {
+		reqMap["half_done"] = true
+	}
+	return nil, errors.New("normalization failed")
+}
+
+func (t *partialFailTransformer) TransformResponseOut(ctx context.Context, response *http.Response) (*http.Response, error) {
+	_, _ = io.ReadAll(response.Body)
+	return nil, errors.New("normalization failed")
+}
+
+func newPartialFailTransformer() *partialFailTransformer {
+	return &partialFailTransformer{BaseTransformer: *NewBaseTransformer("normalize", "")}
+}
+
+func TestTransformerChain_BestEffort(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("RequestContinuesUntransformed", func(t *testing.T) {
+		chain := NewTransformerChain(
+			&mockTransformer{name: "first"},
+			NewBestEffortTransformer(newPartialFailTransformer()),
+			&mockTransformer{name: "last"},
+		)
+
+		result, err := chain.TransformRequestIn(ctx, map[string]interface{}{"model": "m"}, "openai")
+		testutil.AssertNoError(t, err)
+		resultMap := result.(map[string]interface{})
+		testutil.AssertEqual(t, "first", resultMap["transformed_by_first"])
+		testutil.AssertEqual(t, "last", resultMap["transformed_by_last"])
+		_, halfDone := resultMap["half_done"]
+		testutil.AssertFalse(t, halfDone)
+	})
+
+	t.Run("RequestConfigContinuesUntransformed", func(t *testing.T) {
+		chain := NewTransformerChain(NewBestEffortTransformer(newPartialFailTransformer()))
+		request := &RequestConfig{Body: map[string]interface{}{"model": "m"}, URL: "https://example.com"}
+
+		result, err := chain.TransformRequestIn(ctx, request, "openai")
+		testutil.AssertNoError(t, err)
+		testutil.AssertTrue(t, result == request)
+	})
+
+	t.Run("ResponseReplayed", func(t *testing.T) {
+		chain := NewTransformerChain(NewBestEffortTransformer(newPartialFailTransformer()))
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"id":"1"}`)),
+		}
+
+		result, err := chain.TransformResponseOut(ctx, resp)
+		testutil.AssertNoError(t, err)
+		body, _ := io.ReadAll(result.Body)
+		testutil.AssertEqual(t, `{"id":"1"}`, string(body))
+	})
+
+	t.Run("RequiredTransformerStillFails", func(t *testing.T) {
+		chain := NewTransformerChain(newPartialFailTransformer())
+
+		_, err := chain.TransformRequestIn(ctx, map[string]interface{}{"model": "m"}, "openai")
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "normalization failed")
+	})
+}
+
+func TestService_CreateChainBestEffort(t *testing.T) {
+	service := NewService()
+	testutil.AssertNoError(t, service.Register(newPartialFailTransformer()))
+
+	chain, err := service.CreateChain([]config.TransformerConfig{{Name: "normalize", BestEffort: true}})
+	testutil.AssertNoError(t, err)
+	testutil.AssertTrue(t, isBestEffort(chain.transformers[0]))
+	testutil.AssertEqual(t, "normalize", chain.transformers[0].GetName())
+
+	chain, err = service.CreateChain([]config.TransformerConfig{{Name: "normalize"}})
+	testutil.AssertNoError(t, err)
+	testutil.AssertFalse(t, isBestEffort(chain.transformers[0]))
+}
@@ -0,0 +1,73 @@
+package transformer
+
+// PromptCachingBeta is the anthropic-beta value enabling cache_control markers
+const PromptCachingBeta = "prompt-caching-2024-07-31"
+
+// HasCacheControl reports whether a request marks any system block, message
+// content block or tool definition with cache_control
+func HasCacheControl(request interface{}) bool {
+	bodyMap, ok := request.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	found := false
+	visitCacheControlTargets(bodyMap, func(target map[string]interface{}) {
+		if _, exists := target["cache_control"]; exists {
+			found = true
+		}
+	})
+	return found
+}
+
+// stripCacheControl removes cache_control markers from a request and reports
+// whether any were found
+func stripCacheControl(bodyMap map[string]interface{}) bool {
+	stripped := false
+	visitCacheControlTargets(bodyMap, func(target map[string]interface{}) {
+		if _, exists := target["cache_control"]; exists {
+			delete(target, "cache_control")
+			stripped = true
+		}
+	})
+	return stripped
+}
+
+// visitCacheControlTargets calls visit for every map that may carry a
+// cache_control marker: system blocks, messages and their content blocks,
+// and tool definitions
+func visitCacheControlTargets(bodyMap map[string]interface{}, visit func(map[string]interface{})) {
+	visitContentBlocks(bodyMap["system"], visit)
+
+	if messages, ok := bodyMap["messages"].([]interface{}); ok {
+		for _, msg := range messages {
+			if msgMap, ok := msg.(map[string]interface{}); ok {
+				visit(msgMap)
+				visitContentBlocks(msgMap["content"], visit)
+			}
+		}
+	}
+
+	if tools, ok := bodyMap["tools"].([]interface{}); ok {
+		for _, tool := range tools {
+			if toolMap, ok := tool.(map[string]interface{}); ok {
+				visit(toolMap)
+			}
+		}
+	}
+}
+
+// visitContentBlocks calls visit for each block of an array content value,
+// including the blocks nested in tool results
+func visitContentBlocks(content interface{}, visit func(map[string]interface{})) {
+	blocks, ok := content.([]interface{})
+	if !ok {
+		return
+	}
+	for _, block := range blocks {
+		if blockMap, ok := block.(map[string]interface{}); ok {
+			visit(blockMap)
+			visitContentBlocks(blockMap["content"], visit)
+		}
+	}
+}
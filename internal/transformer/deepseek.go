@@ -61,13 +61,14 @@ func (t *DeepSeekTransformer) TransformResponseOut(ctx context.Context, response
 		ProtoMajor:    response.ProtoMajor,
 		ProtoMinor:    response.ProtoMinor,
 		Header:        response.Header.Clone(),
-		Body:          pr,
+		Body:          &pipeBody{PipeReader: pr, upstream: reader},
 		ContentLength: -1,
 		Request:       response.Request,
 	}
 
 	// Start transformation in goroutine
 	go func() {
+		defer reader.Close()
 		defer pw.Close()
 		writer := NewSSEWriter(pw)
 
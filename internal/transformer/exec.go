@@ -0,0 +1,221 @@
+package transformer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+)
+
+// ExecTransformerName is the transformer name that runs an external command.
+// It is never part of a default chain and is only created from a provider's
+// transformers list.
+const ExecTransformerName = "exec"
+
+// defaultExecTimeout bounds a command run when no timeout is configured
+const defaultExecTimeout = 5 * time.Second
+
+// maxExecStderr caps the stderr included in error messages
+const maxExecStderr = 500
+
+// Phases an exec transformer runs in
+const (
+	ExecPhaseRequest  = "request"
+	ExecPhaseResponse = "response"
+	ExecPhaseBoth     = "both"
+)
+
+// ExecTransformer pipes JSON bodies through an external command. The body is
+// written to the command's stdin and its stdout must be the transformed JSON
+// object.
+type ExecTransformer struct {
+	BaseTransformer
+	command   string
+	args      []string
+	timeout   time.Duration
+	requests  bool
+	responses bool
+}
+
+// NewExecTransformer creates an exec transformer from its config options:
+// command (required), args, timeout and phase
+func NewExecTransformer(options map[string]interface{}) (*ExecTransformer, error) {
+	command, _ := options["command"].(string)
+	if command == "" {
+		return nil, fmt.Errorf("exec transformer requires a command")
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("exec transformer command %s: %w", command, err)
+	}
+
+	t := &ExecTransformer{
+		BaseTransformer: *NewBaseTransformer(ExecTransformerName, ""),
+		command:         path,
+		timeout:         defaultExecTimeout,
+	}
+
+	if rawArgs, exists := options["args"]; exists {
+		args, ok := rawArgs.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("exec transformer args must be a list of strings")
+		}
+		for _, arg := range args {
+			s, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("exec transformer args must be a list of strings")
+			}
+			t.args = append(t.args, s)
+		}
+	}
+
+	switch timeout := options["timeout"].(type) {
+	case nil:
+	case string:
+		if t.timeout, err = time.ParseDuration(timeout); err != nil {
+			return nil, fmt.Errorf("invalid exec transformer timeout: %w", err)
+		}
+	case float64:
+		t.timeout = time.Duration(timeout * float64(time.Second))
+	default:
+		return nil, fmt.Errorf("exec transformer timeout must be a duration string or seconds")
+	}
+	if t.timeout <= 0 {
+		return nil, fmt.Errorf("exec transformer timeout must be positive")
+	}
+
+	phase, _ := options["phase"].(string)
+	switch phase {
+	case "", ExecPhaseRequest:
+		t.requests = true
+	case ExecPhaseResponse:
+		t.responses = true
+	case ExecPhaseBoth:
+		t.requests, t.responses = true, true
+	default:
+		return nil, fmt.Errorf("invalid exec transformer phase %q: must be request, response or both", phase)
+	}
+
+	return t, nil
+}
+
+// TransformRequestIn pipes the request body through the command
+func (t *ExecTransformer) TransformRequestIn(ctx context.Context, request interface{}, provider string) (interface{}, error) {
+	if !t.requests {
+		return request, nil
+	}
+
+	reqConfig, isConfig := request.(*RequestConfig)
+	body := request
+	if isConfig {
+		body = reqConfig.Body
+	}
+
+	input, err := json.Marshal(body)
+	if err != nil {
+		return nil, ccerrors.Wrap(err, ccerrors.ErrorTypeTransformError, "failed to encode request for exec transformer")
+	}
+
+	output, err := t.run(ctx, input, provider, ExecPhaseRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	var transformed map[string]interface{}
+	if err := json.Unmarshal(output, &transformed); err != nil {
+		return nil, ccerrors.Wrapf(err, ccerrors.ErrorTypeTransformError,
+			"exec transformer %s returned malformed JSON", t.command)
+	}
+
+	if isConfig {
+		reqConfig.Body = transformed
+		return reqConfig, nil
+	}
+	return transformed, nil
+}
+
+// TransformResponseOut pipes successful JSON response bodies through the
+// command. Streams and error responses pass through unchanged.
+func (t *ExecTransformer) TransformResponseOut(ctx context.Context, response *http.Response) (*http.Response, error) {
+	if !t.responses || response.StatusCode >= http.StatusBadRequest ||
+		!strings.Contains(response.Header.Get("Content-Type"), "json") {
+		return response, nil
+	}
+
+	input, err := io.ReadAll(response.Body)
+	_ = response.Body.Close() // Safe to ignore: body fully read
+	if err != nil {
+		return nil, ccerrors.Wrap(err, ccerrors.ErrorTypeTransformError, "failed to read response for exec transformer")
+	}
+
+	output, err := t.run(ctx, input, "", ExecPhaseResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	var transformed map[string]interface{}
+	if err := json.Unmarshal(output, &transformed); err != nil {
+		return nil, ccerrors.Wrapf(err, ccerrors.ErrorTypeTransformError,
+			"exec transformer %s returned malformed JSON", t.command)
+	}
+
+	response.Body = io.NopCloser(bytes.NewReader(output))
+	response.ContentLength = int64(len(output))
+	response.Header.Set("Content-Length", strconv.Itoa(len(output)))
+	return response, nil
+}
+
+// run executes the command with input on stdin and returns its stdout
+func (t *ExecTransformer) run(ctx context.Context, input []byte, provider, phase string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	// #nosec G204 - The command comes from the operator's configuration
+	cmd := exec.CommandContext(ctx, t.command, t.args...)
+	cmd.Env = append(os.Environ(), "CCPROXY_PROVIDER="+provider, "CCPROXY_PHASE="+phase)
+	cmd.Stdin = bytes.NewReader(input)
+	// Do not wait on grandchildren that keep the output pipes open
+	cmd.WaitDelay = time.Second
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, ccerrors.Newf(ccerrors.ErrorTypeTransformError,
+			"exec transformer %s timed out after %s", t.command, t.timeout)
+	case err != nil:
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, ccerrors.Newf(ccerrors.ErrorTypeTransformError,
+				"exec transformer %s exited with status %d: %s", t.command, exitErr.ExitCode(), execStderr(&stderr))
+		}
+		return nil, ccerrors.Wrapf(err, ccerrors.ErrorTypeTransformError, "exec transformer %s failed", t.command)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// execStderr returns the command's trimmed stderr, capped for error messages
+func execStderr(stderr *bytes.Buffer) string {
+	msg := strings.TrimSpace(stderr.String())
+	if len(msg) > maxExecStderr {
+		msg = msg[:maxExecStderr] + "..."
+	}
+	if msg == "" {
+		msg = "no output on stderr"
+	}
+	return msg
+}
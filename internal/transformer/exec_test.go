@@ -0,0 +1,201 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+// shellTransformer creates an exec transformer running script with sh
+func shellTransformer(t *testing.T, script string, options map[string]interface{}) *ExecTransformer {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("exec transformer tests require a POSIX shell")
+	}
+
+	if options == nil {
+		options = map[string]interface{}{}
+	}
+	options["command"] = "sh"
+	options["args"] = []interface{}{"-c", script}
+
+	transformer, err := NewExecTransformer(options)
+	testutil.AssertNoError(t, err)
+	return transformer
+}
+
+// assertTransformError checks that err is a transform_error mentioning want
+func assertTransformError(t *testing.T, err error, want string) {
+	t.Helper()
+	var ccErr *ccerrors.CCProxyError
+	if !errors.As(err, &ccErr) {
+		t.Fatalf("Expected a CCProxyError, got %v", err)
+	}
+	testutil.AssertEqual(t, ccerrors.ErrorTypeTransformError, ccErr.Type)
+	testutil.AssertContains(t, err.Error(), want)
+}
+
+func TestExecTransformer_Request(t *testing.T) {
+	ctx := context.Background()
+	request := func() map[string]interface{} {
+		return map[string]interface{}{"model": "gpt-4", "max_tokens": float64(100)}
+	}
+
+	t.Run("TransformsBody", func(t *testing.T) {
+		transformer := shellTransformer(t, `sed 's/gpt-4/gpt-4o/'`, nil)
+
+		result, err := transformer.TransformRequestIn(ctx, request(), "openai")
+		testutil.AssertNoError(t, err)
+		body := result.(map[string]interface{})
+		testutil.AssertEqual(t, "gpt-4o", body["model"])
+		testutil.AssertEqual(t, float64(100), body["max_tokens"])
+	})
+
+	t.Run("RequestConfigBody", func(t *testing.T) {
+		transformer := shellTransformer(t, `sed 's/gpt-4/gpt-4o/'`, nil)
+
+		result, err := transformer.TransformRequestIn(ctx, &RequestConfig{Body: request()}, "openai")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "gpt-4o", result.(*RequestConfig).Body.(map[string]interface{})["model"])
+	})
+
+	t.Run("ProviderEnvironment", func(t *testing.T) {
+		transformer := shellTransformer(t, `printf '{"provider":"%s","phase":"%s"}' "$CCPROXY_PROVIDER" "$CCPROXY_PHASE"`, nil)
+
+		result, err := transformer.TransformRequestIn(ctx, request(), "groq")
+		testutil.AssertNoError(t, err)
+		body := result.(map[string]interface{})
+		testutil.AssertEqual(t, "groq", body["provider"])
+		testutil.AssertEqual(t, "request", body["phase"])
+	})
+
+	t.Run("NonZeroExit", func(t *testing.T) {
+		transformer := shellTransformer(t, `echo "policy violation" >&2; exit 3`, nil)
+
+		_, err := transformer.TransformRequestIn(ctx, request(), "openai")
+		assertTransformError(t, err, "exited with status 3: policy violation")
+	})
+
+	t.Run("MalformedOutput", func(t *testing.T) {
+		transformer := shellTransformer(t, `echo "not json"`, nil)
+
+		_, err := transformer.TransformRequestIn(ctx, request(), "openai")
+		assertTransformError(t, err, "malformed JSON")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		transformer := shellTransformer(t, `sleep 5`, map[string]interface{}{"timeout": "100ms"})
+
+		_, err := transformer.TransformRequestIn(ctx, request(), "openai")
+		assertTransformError(t, err, "timed out after 100ms")
+	})
+
+	t.Run("ResponsePhaseSkipsRequests", func(t *testing.T) {
+		transformer := shellTransformer(t, `exit 1`, map[string]interface{}{"phase": "response"})
+
+		result, err := transformer.TransformRequestIn(ctx, request(), "openai")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "gpt-4", result.(map[string]interface{})["model"])
+	})
+}
+
+func TestExecTransformer_Response(t *testing.T) {
+	newResponse := func(status int, contentType, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": []string{contentType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	transformer := shellTransformer(t, `sed 's/Hello/Goodbye/'`, map[string]interface{}{"phase": "both"})
+
+	t.Run("TransformsJSON", func(t *testing.T) {
+		resp, err := transformer.TransformResponseOut(context.Background(),
+			newResponse(http.StatusOK, "application/json", `{"content":"Hello"}`))
+		testutil.AssertNoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, `{"content":"Goodbye"}`, strings.TrimSpace(string(body)))
+		testutil.AssertEqual(t, int64(len(body)), resp.ContentLength)
+	})
+
+	t.Run("StreamsUnchanged", func(t *testing.T) {
+		resp, err := transformer.TransformResponseOut(context.Background(),
+			newResponse(http.StatusOK, "text/event-stream", "data: Hello\n\n"))
+		testutil.AssertNoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "data: Hello\n\n", string(body))
+	})
+
+	t.Run("ErrorsUnchanged", func(t *testing.T) {
+		resp, err := transformer.TransformResponseOut(context.Background(),
+			newResponse(http.StatusBadRequest, "application/json", `{"error":"Hello"}`))
+		testutil.AssertNoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, `{"error":"Hello"}`, string(body))
+	})
+}
+
+func TestNewExecTransformer_Config(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		wantErr string
+	}{
+		{"MissingCommand", map[string]interface{}{}, "requires a command"},
+		{"UnknownCommand", map[string]interface{}{"command": "ccproxy-no-such-command"}, "ccproxy-no-such-command"},
+		{"InvalidArgs", map[string]interface{}{"command": "sh", "args": "-c"}, "args must be a list"},
+		{"InvalidTimeout", map[string]interface{}{"command": "sh", "timeout": "soon"}, "invalid exec transformer timeout"},
+		{"NegativeTimeout", map[string]interface{}{"command": "sh", "timeout": float64(-1)}, "must be positive"},
+		{"InvalidPhase", map[string]interface{}{"command": "sh", "phase": "sometimes"}, "invalid exec transformer phase"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" {
+				t.Skip("exec transformer tests require a POSIX shell")
+			}
+			_, err := NewExecTransformer(tt.options)
+			testutil.AssertError(t, err)
+			testutil.AssertContains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestService_CreateChainExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec transformer tests require a POSIX shell")
+	}
+
+	service := NewService()
+	testutil.AssertNoError(t, service.Register(NewOpenAITransformer()))
+
+	chain, err := service.CreateChain([]config.TransformerConfig{
+		{Name: "openai"},
+		{Name: ExecTransformerName, Config: map[string]interface{}{"command": "cat"}},
+	})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, 2, len(chain.transformers))
+	testutil.AssertEqual(t, ExecTransformerName, chain.transformers[1].GetName())
+
+	// The exec transformer is never available by name alone
+	_, err = service.Get(ExecTransformerName)
+	testutil.AssertError(t, err)
+
+	_, err = service.CreateChain([]config.TransformerConfig{{Name: ExecTransformerName}})
+	testutil.AssertError(t, err)
+}
@@ -19,7 +19,15 @@ type GeminiTransformer struct {
 // NewGeminiTransformer creates a new Gemini transformer
 func NewGeminiTransformer() *GeminiTransformer {
 	return &GeminiTransformer{
-		BaseTransformer: *NewBaseTransformer("gemini", "/v1beta/models/:modelAndAction"),
+		BaseTransformer: *NewBaseTransformer("gemini", "/:version/models/:model:generateContent"),
+	}
+}
+
+// NewVertexTransformer creates a Gemini transformer for Vertex AI, which
+// accepts the same request format under a different URL
+func NewVertexTransformer() *GeminiTransformer {
+	return &GeminiTransformer{
+		BaseTransformer: *NewBaseTransformer("vertex", ""),
 	}
 }
 
@@ -77,6 +85,11 @@ func (t *GeminiTransformer) TransformRequestIn(ctx context.Context, request inte
 			})
 		}
 
+		// Handle text and image content blocks
+		if blocks, ok := content.([]interface{}); ok {
+			parts = append(parts, t.contentBlockParts(blocks)...)
+		}
+
 		// Handle tool calls from assistant
 		if role == "assistant" && msgMap["tool_calls"] != nil {
 			toolCalls, ok := msgMap["tool_calls"].([]interface{})
@@ -153,10 +166,20 @@ func (t *GeminiTransformer) TransformRequestIn(ctx context.Context, request inte
 	if topK, ok := reqMap["top_k"]; ok {
 		genConfig["topK"] = topK
 	}
+	if n, ok := reqMap["n"]; ok {
+		genConfig["candidateCount"] = n
+	}
+	if stop, ok := stopSequencesField(reqMap); ok {
+		genConfig["stopSequences"] = stop
+	}
+	if responseFormat, ok := reqMap["response_format"]; ok {
+		t.applyResponseFormat(genConfig, responseFormat)
+	}
 
 	if len(genConfig) > 0 {
 		transformed["generationConfig"] = genConfig
 	}
+	warnDroppedLogprobs(reqMap, provider)
 
 	// Transform tools
 	if tools, ok := reqMap["tools"].([]interface{}); ok {
@@ -187,12 +210,60 @@ func (t *GeminiTransformer) TransformRequestIn(ctx context.Context, request inte
 
 		if len(transformedTools) > 0 {
 			transformed["tools"] = transformedTools
+
+			if choice, ok := parseToolChoice(reqMap["tool_choice"]); ok {
+				transformed["toolConfig"] = choice.gemini()
+			}
+			if sequentialToolCalls(reqMap) {
+				utils.GetLogger().Warnf("Dropping parallel_tool_calls: provider %s cannot limit a turn to one tool call", provider)
+			}
 		}
 	}
 
 	return transformed, nil
 }
 
+// contentBlockParts converts text and image content blocks into Gemini parts.
+// Anthropic image blocks and OpenAI image_url parts become inlineData, or
+// fileData for URL images.
+func (t *GeminiTransformer) contentBlockParts(blocks []interface{}) []interface{} {
+	parts := []interface{}{}
+	for _, block := range blocks {
+		blockMap, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if img, ok := parseImagePart(blockMap); ok {
+			parts = append(parts, img.geminiPart())
+			continue
+		}
+		if text, ok := blockMap["text"].(string); ok && blockMap["type"] == "text" {
+			parts = append(parts, map[string]interface{}{"text": text})
+		}
+	}
+	return parts
+}
+
+// applyResponseFormat translates an OpenAI response_format into Gemini's
+// responseMimeType and, for JSON schemas, responseSchema
+func (t *GeminiTransformer) applyResponseFormat(genConfig map[string]interface{}, responseFormat interface{}) {
+	formatMap, ok := responseFormat.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	switch formatMap["type"] {
+	case "json_object":
+		genConfig["responseMimeType"] = "application/json"
+	case "json_schema":
+		genConfig["responseMimeType"] = "application/json"
+		if schema, ok := jsonSchemaOf(formatMap); ok {
+			genConfig["responseSchema"] = t.cleanJSONSchema(schema)
+		}
+	}
+}
+
 // cleanJSONSchema removes unsupported fields from JSON schema
 func (t *GeminiTransformer) cleanJSONSchema(schema map[string]interface{}) map[string]interface{} {
 	cleaned := make(map[string]interface{})
@@ -218,6 +289,19 @@ func (t *GeminiTransformer) cleanJSONSchema(schema map[string]interface{}) map[s
 			} else {
 				cleaned[k] = v
 			}
+		} else if items, ok := v.(map[string]interface{}); ok && k == "items" {
+			// Structured output schemas nest objects inside arrays
+			cleaned[k] = t.cleanJSONSchema(items)
+		} else if variants, ok := v.([]interface{}); ok && k == "anyOf" {
+			cleanedVariants := make([]interface{}, len(variants))
+			for i, variant := range variants {
+				if variantMap, ok := variant.(map[string]interface{}); ok {
+					cleanedVariants[i] = t.cleanJSONSchema(variantMap)
+				} else {
+					cleanedVariants[i] = variant
+				}
+			}
+			cleaned[k] = cleanedVariants
 		} else {
 			cleaned[k] = v
 		}
@@ -257,7 +341,7 @@ func (t *GeminiTransformer) TransformResponseOut(ctx context.Context, response *
 	}
 
 	// Transform to OpenAI format
-	openaiResp := t.transformGeminiToOpenAI(geminiResp)
+	openaiResp := t.transformGeminiToOpenAI(geminiResp, body, RequestModel(ctx))
 
 	// Marshal transformed response
 	transformedBody, err := json.Marshal(openaiResp)
@@ -271,13 +355,17 @@ func (t *GeminiTransformer) TransformResponseOut(ctx context.Context, response *
 	return response, nil
 }
 
-// transformGeminiToOpenAI transforms Gemini response format to OpenAI format
-func (t *GeminiTransformer) transformGeminiToOpenAI(geminiResp map[string]interface{}) map[string]interface{} {
+// transformGeminiToOpenAI transforms Gemini response format to OpenAI
+// format, naming the requested model when known and keeping Gemini's
+// responseId, or deriving one from the raw response body
+func (t *GeminiTransformer) transformGeminiToOpenAI(geminiResp map[string]interface{}, body []byte, model string) map[string]interface{} {
+	responseID, _ := geminiResp["responseId"].(string)
+
 	openaiResp := map[string]interface{}{
-		"id":      fmt.Sprintf("chatcmpl-%d", utils.GetTimestamp()),
+		"id":      stableResponseID(responseID, "chatcmpl-", body),
 		"object":  "chat.completion",
 		"created": utils.GetTimestamp(),
-		"model":   "gemini-pro", // Default model name
+		"model":   geminiResponseModel(geminiResp, model),
 	}
 
 	// Transform candidates to choices
@@ -388,6 +476,18 @@ func (t *GeminiTransformer) convertFinishReason(reason string) string {
 	}
 }
 
+// geminiResponseModel returns the model to report for a Gemini response: the
+// requested model, else the modelVersion Gemini reports, else gemini-pro
+func geminiResponseModel(resp map[string]interface{}, requested string) string {
+	if requested != "" {
+		return requested
+	}
+	if version, ok := resp["modelVersion"].(string); ok && version != "" {
+		return version
+	}
+	return "gemini-pro"
+}
+
 // transformStreamingResponse transforms Gemini streaming response
 func (t *GeminiTransformer) transformStreamingResponse(ctx context.Context, response *http.Response) (*http.Response, error) {
 	reader := NewSSEReader(response.Body)
@@ -401,13 +501,16 @@ func (t *GeminiTransformer) transformStreamingResponse(ctx context.Context, resp
 		ProtoMajor:    response.ProtoMajor,
 		ProtoMinor:    response.ProtoMinor,
 		Header:        response.Header.Clone(),
-		Body:          pr,
+		Body:          &pipeBody{PipeReader: pr, upstream: reader},
 		ContentLength: -1,
 		Request:       response.Request,
 	}
 
+	model := RequestModel(ctx)
+
 	// Start transformation in goroutine
 	go func() {
+		defer reader.Close()
 		defer pw.Close()
 		writer := NewSSEWriter(pw)
 
@@ -421,7 +524,7 @@ func (t *GeminiTransformer) transformStreamingResponse(ctx context.Context, resp
 			}
 
 			// Transform the event
-			transformed := t.transformStreamEvent(event)
+			transformed := t.transformStreamEvent(event, model)
 			if transformed != nil {
 				// Safe to ignore error for streaming output
 				_ = writer.WriteEvent(transformed)
@@ -436,8 +539,9 @@ func (t *GeminiTransformer) transformStreamingResponse(ctx context.Context, resp
 	return newResp, nil
 }
 
-// transformStreamEvent transforms a single Gemini SSE event
-func (t *GeminiTransformer) transformStreamEvent(event *SSEEvent) *SSEEvent {
+// transformStreamEvent transforms a single Gemini SSE event, naming the
+// requested model when known
+func (t *GeminiTransformer) transformStreamEvent(event *SSEEvent, model string) *SSEEvent {
 	// Parse the event data
 	var data map[string]interface{}
 	if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
@@ -449,7 +553,7 @@ func (t *GeminiTransformer) transformStreamEvent(event *SSEEvent) *SSEEvent {
 		"id":      fmt.Sprintf("chatcmpl-%d", utils.GetTimestamp()),
 		"object":  "chat.completion.chunk",
 		"created": utils.GetTimestamp(),
-		"model":   "gemini-pro",
+		"model":   geminiResponseModel(data, model),
 		"choices": []interface{}{},
 	}
 
@@ -0,0 +1,170 @@
+package transformer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GeminiEmbeddingsTransformer converts OpenAI embeddings requests to Gemini's
+// batchEmbedContents API, the batch form of embedContent, and converts the
+// responses back. It is used for /v1/embeddings only and is never part of a
+// provider chain.
+type GeminiEmbeddingsTransformer struct {
+	BaseTransformer
+}
+
+// NewGeminiEmbeddingsTransformer creates a new Gemini embeddings transformer
+func NewGeminiEmbeddingsTransformer() *GeminiEmbeddingsTransformer {
+	return &GeminiEmbeddingsTransformer{
+		BaseTransformer: *NewBaseTransformer("gemini-embeddings", "/:version/models/:model:batchEmbedContents"),
+	}
+}
+
+// GeminiEmbeddingsEndpoint returns the batchEmbedContents endpoint for model
+// in the given API version
+func GeminiEmbeddingsEndpoint(version, model string) string {
+	return fmt.Sprintf("/%s/models/%s:batchEmbedContents", version, url.PathEscape(strings.TrimPrefix(model, "models/")))
+}
+
+// TransformRequestIn converts {"model","input","dimensions"} to a
+// batchEmbedContents request with one entry per input string
+func (t *GeminiEmbeddingsTransformer) TransformRequestIn(ctx context.Context, request interface{}, provider string) (interface{}, error) {
+	reqMap, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	model, _ := reqMap["model"].(string)
+	if model == "" {
+		return nil, fmt.Errorf("missing model")
+	}
+	model = "models/" + strings.TrimPrefix(model, "models/")
+
+	var inputs []string
+	switch input := reqMap["input"].(type) {
+	case string:
+		inputs = []string{input}
+	case []interface{}:
+		for _, item := range input {
+			text, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("gemini embeddings only accept string inputs")
+			}
+			inputs = append(inputs, text)
+		}
+	default:
+		return nil, fmt.Errorf("input must be a string or an array of strings")
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("input must not be empty")
+	}
+
+	requests := make([]interface{}, 0, len(inputs))
+	for _, text := range inputs {
+		entry := map[string]interface{}{
+			"model": model,
+			"content": map[string]interface{}{
+				"parts": []interface{}{map[string]interface{}{"text": text}},
+			},
+		}
+		if dimensions, ok := reqMap["dimensions"]; ok {
+			entry["outputDimensionality"] = dimensions
+		}
+		requests = append(requests, entry)
+	}
+
+	return map[string]interface{}{"requests": requests}, nil
+}
+
+// TransformResponseOut converts a batchEmbedContents response to an OpenAI
+// embeddings list, and Gemini errors to the OpenAI error envelope. Gemini does
+// not report token usage for embeddings, so usage is always zero.
+func (t *GeminiEmbeddingsTransformer) TransformResponseOut(ctx context.Context, response *http.Response) (*http.Response, error) {
+	if response == nil || response.Body == nil {
+		return response, nil
+	}
+
+	body, err := io.ReadAll(response.Body)
+	_ = response.Body.Close() // Safe to ignore: body has been fully read
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embeddings response: %w", err)
+	}
+
+	var converted interface{}
+	if response.StatusCode >= http.StatusBadRequest {
+		errorType, message := parseProviderError(response.StatusCode, body)
+		converted = map[string]interface{}{
+			"error": map[string]interface{}{
+				"type":    errorType,
+				"message": message,
+			},
+		}
+	} else {
+		var geminiResp struct {
+			Embeddings []struct {
+				Values []float64 `json:"values"`
+			} `json:"embeddings"`
+		}
+		if err := json.Unmarshal(body, &geminiResp); err != nil {
+			return nil, fmt.Errorf("failed to parse embeddings response: %w", err)
+		}
+
+		data := make([]interface{}, 0, len(geminiResp.Embeddings))
+		for i, embedding := range geminiResp.Embeddings {
+			data = append(data, map[string]interface{}{
+				"object":    "embedding",
+				"index":     i,
+				"embedding": embedding.Values,
+			})
+		}
+		converted = map[string]interface{}{
+			"object": "list",
+			"data":   data,
+			"model":  embeddingsModel(response),
+			"usage": map[string]interface{}{
+				"prompt_tokens": 0,
+				"total_tokens":  0,
+			},
+		}
+	}
+
+	data, err := json.Marshal(converted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embeddings response: %w", err)
+	}
+
+	header := response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Type", "application/json")
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	header.Del("Content-Encoding")
+
+	response.Header = header
+	response.Body = io.NopCloser(bytes.NewReader(data))
+	response.ContentLength = int64(len(data))
+	return response, nil
+}
+
+// embeddingsModel recovers the model name from the request URL, since
+// batchEmbedContents responses do not include it
+func embeddingsModel(response *http.Response) string {
+	if response.Request == nil || response.Request.URL == nil {
+		return ""
+	}
+	path := response.Request.URL.Path
+	start := strings.LastIndex(path, "/models/")
+	end := strings.LastIndex(path, ":")
+	if start < 0 || end < start {
+		return ""
+	}
+	return path[start+len("/models/") : end]
+}
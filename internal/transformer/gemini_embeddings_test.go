@@ -0,0 +1,119 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestGeminiEmbeddingsTransformer_TransformRequestIn(t *testing.T) {
+	transformer := NewGeminiEmbeddingsTransformer()
+	ctx := context.Background()
+
+	t.Run("ArrayInput", func(t *testing.T) {
+		result, err := transformer.TransformRequestIn(ctx, map[string]interface{}{
+			"model":      "text-embedding-004",
+			"input":      []interface{}{"first", "second"},
+			"dimensions": float64(256),
+		}, "gemini")
+		testutil.AssertNoError(t, err)
+
+		requests := result.(map[string]interface{})["requests"].([]interface{})
+		testutil.AssertEqual(t, 2, len(requests))
+		first := requests[0].(map[string]interface{})
+		testutil.AssertEqual(t, "models/text-embedding-004", first["model"])
+		testutil.AssertEqual(t, float64(256), first["outputDimensionality"])
+		parts := first["content"].(map[string]interface{})["parts"].([]interface{})
+		testutil.AssertEqual(t, "first", parts[0].(map[string]interface{})["text"])
+	})
+
+	t.Run("StringInput", func(t *testing.T) {
+		result, err := transformer.TransformRequestIn(ctx, map[string]interface{}{
+			"model": "models/text-embedding-004",
+			"input": "hello",
+		}, "gemini")
+		testutil.AssertNoError(t, err)
+
+		requests := result.(map[string]interface{})["requests"].([]interface{})
+		testutil.AssertEqual(t, 1, len(requests))
+		testutil.AssertEqual(t, "models/text-embedding-004", requests[0].(map[string]interface{})["model"])
+	})
+
+	t.Run("TokenInputRejected", func(t *testing.T) {
+		_, err := transformer.TransformRequestIn(ctx, map[string]interface{}{
+			"model": "text-embedding-004",
+			"input": []interface{}{float64(1), float64(2)},
+		}, "gemini")
+		testutil.AssertError(t, err)
+	})
+
+	t.Run("EmptyInputRejected", func(t *testing.T) {
+		_, err := transformer.TransformRequestIn(ctx, map[string]interface{}{
+			"model": "text-embedding-004",
+			"input": []interface{}{},
+		}, "gemini")
+		testutil.AssertError(t, err)
+	})
+}
+
+func TestGeminiEmbeddingsTransformer_TransformResponseOut(t *testing.T) {
+	transformer := NewGeminiEmbeddingsTransformer()
+	requestURL, _ := url.Parse("https://example.com" + GeminiEmbeddingsEndpoint("v1beta", "text-embedding-004"))
+
+	newResponse := func(status int, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    &http.Request{URL: requestURL},
+		}
+	}
+
+	t.Run("Embeddings", func(t *testing.T) {
+		resp, err := transformer.TransformResponseOut(context.Background(), newResponse(http.StatusOK,
+			`{"embeddings":[{"values":[0.1,0.2]},{"values":[0.3]}]}`))
+		testutil.AssertNoError(t, err)
+
+		var body struct {
+			Object string `json:"object"`
+			Model  string `json:"model"`
+			Data   []struct {
+				Object    string    `json:"object"`
+				Index     int       `json:"index"`
+				Embedding []float64 `json:"embedding"`
+			} `json:"data"`
+			Usage map[string]int `json:"usage"`
+		}
+		testutil.AssertNoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		testutil.AssertEqual(t, "list", body.Object)
+		testutil.AssertEqual(t, "text-embedding-004", body.Model)
+		testutil.AssertEqual(t, 2, len(body.Data))
+		testutil.AssertEqual(t, "embedding", body.Data[1].Object)
+		testutil.AssertEqual(t, 1, body.Data[1].Index)
+		testutil.AssertEqual(t, 0.3, body.Data[1].Embedding[0])
+		testutil.AssertEqual(t, 0, body.Usage["prompt_tokens"])
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		resp, err := transformer.TransformResponseOut(context.Background(), newResponse(http.StatusTooManyRequests,
+			`{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, http.StatusTooManyRequests, resp.StatusCode)
+
+		var body struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		testutil.AssertNoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		testutil.AssertEqual(t, "rate_limit_error", body.Error.Type)
+		testutil.AssertEqual(t, "Quota exceeded", body.Error.Message)
+	})
+}
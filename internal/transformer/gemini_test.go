@@ -18,7 +18,7 @@ func TestGeminiTransformer(t *testing.T) {
 	t.Run("NewGeminiTransformer", func(t *testing.T) {
 		transformer := NewGeminiTransformer()
 		testutil.AssertEqual(t, "gemini", transformer.GetName())
-		testutil.AssertEqual(t, "/v1beta/models/:modelAndAction", transformer.GetEndpoint())
+		testutil.AssertEqual(t, "/:version/models/:model:generateContent", transformer.GetEndpoint())
 	})
 }
 
@@ -29,6 +29,147 @@ func TestGeminiTransformRequestIn(t *testing.T) {
 	transformer := NewGeminiTransformer()
 	ctx := context.Background()
 
+	t.Run("CandidateCount", func(t *testing.T) {
+		request := map[string]interface{}{
+			"model": "gemini-pro",
+			"messages": []interface{}{
+				map[string]interface{}{"role": "user", "content": "Name a color"},
+			},
+			"n": float64(3),
+		}
+
+		result, err := transformer.TransformRequestIn(ctx, request, "gemini")
+		testutil.AssertNoError(t, err)
+
+		resultMap := result.(map[string]interface{})
+		_, hasN := resultMap["n"]
+		testutil.AssertFalse(t, hasN)
+		genConfig := resultMap["generationConfig"].(map[string]interface{})
+		testutil.AssertEqual(t, float64(3), genConfig["candidateCount"])
+	})
+
+	t.Run("ResponseFormatTranslation", func(t *testing.T) {
+		messages := []interface{}{
+			map[string]interface{}{"role": "user", "content": "List three colors as JSON"},
+		}
+
+		request := map[string]interface{}{
+			"model":           "gemini-pro",
+			"messages":        messages,
+			"response_format": map[string]interface{}{"type": "json_object"},
+		}
+
+		result, err := transformer.TransformRequestIn(ctx, request, "gemini")
+		testutil.AssertNoError(t, err)
+
+		resultMap := result.(map[string]interface{})
+		_, hasResponseFormat := resultMap["response_format"]
+		testutil.AssertFalse(t, hasResponseFormat)
+		genConfig := resultMap["generationConfig"].(map[string]interface{})
+		testutil.AssertEqual(t, "application/json", genConfig["responseMimeType"])
+
+		// JSON schemas also carry the cleaned schema across
+		request = map[string]interface{}{
+			"model":    "gemini-pro",
+			"messages": messages,
+			"response_format": map[string]interface{}{
+				"type": "json_schema",
+				"json_schema": map[string]interface{}{
+					"name": "colors",
+					"schema": map[string]interface{}{
+						"type":                 "object",
+						"additionalProperties": false,
+					},
+				},
+			},
+		}
+
+		result, err = transformer.TransformRequestIn(ctx, request, "gemini")
+		testutil.AssertNoError(t, err)
+
+		genConfig = result.(map[string]interface{})["generationConfig"].(map[string]interface{})
+		testutil.AssertEqual(t, "application/json", genConfig["responseMimeType"])
+		schema := genConfig["responseSchema"].(map[string]interface{})
+		testutil.AssertEqual(t, "object", schema["type"])
+		_, hasAdditional := schema["additionalProperties"]
+		testutil.AssertFalse(t, hasAdditional)
+
+		// Plain text needs no generation config at all
+		request = map[string]interface{}{
+			"model":           "gemini-pro",
+			"messages":        messages,
+			"response_format": map[string]interface{}{"type": "text"},
+		}
+
+		result, err = transformer.TransformRequestIn(ctx, request, "gemini")
+		testutil.AssertNoError(t, err)
+
+		_, hasGenConfig := result.(map[string]interface{})["generationConfig"]
+		testutil.AssertFalse(t, hasGenConfig)
+	})
+
+	t.Run("StructuredOutputSchema", func(t *testing.T) {
+		request := map[string]interface{}{
+			"model": "gemini-pro",
+			"messages": []interface{}{
+				map[string]interface{}{"role": "user", "content": "List three colors"},
+			},
+			"response_format": map[string]interface{}{
+				"type": "json_schema",
+				"json_schema": map[string]interface{}{
+					"name":   "colors",
+					"strict": true,
+					"schema": map[string]interface{}{
+						"$schema":              "http://json-schema.org/draft-07/schema#",
+						"type":                 "object",
+						"additionalProperties": false,
+						"required":             []interface{}{"colors"},
+						"properties": map[string]interface{}{
+							"colors": map[string]interface{}{
+								"type": "array",
+								"items": map[string]interface{}{
+									"type":                 "object",
+									"additionalProperties": false,
+									"properties": map[string]interface{}{
+										"name": map[string]interface{}{"type": "string"},
+										"hex": map[string]interface{}{
+											"anyOf": []interface{}{
+												map[string]interface{}{"type": "string", "additionalProperties": false},
+												map[string]interface{}{"type": "null"},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		result, err := transformer.TransformRequestIn(ctx, request, "gemini")
+		testutil.AssertNoError(t, err)
+
+		genConfig := result.(map[string]interface{})["generationConfig"].(map[string]interface{})
+		testutil.AssertEqual(t, "application/json", genConfig["responseMimeType"])
+
+		schema := genConfig["responseSchema"].(map[string]interface{})
+		_, hasSchemaURI := schema["$schema"]
+		testutil.AssertFalse(t, hasSchemaURI)
+		testutil.AssertEqual(t, 1, len(schema["required"].([]interface{})))
+
+		colors := schema["properties"].(map[string]interface{})["colors"].(map[string]interface{})
+		items := colors["items"].(map[string]interface{})
+		_, hasAdditional := items["additionalProperties"]
+		testutil.AssertFalse(t, hasAdditional)
+
+		hex := items["properties"].(map[string]interface{})["hex"].(map[string]interface{})
+		variant := hex["anyOf"].([]interface{})[0].(map[string]interface{})
+		testutil.AssertEqual(t, "string", variant["type"])
+		_, hasAdditional = variant["additionalProperties"]
+		testutil.AssertFalse(t, hasAdditional)
+	})
+
 	t.Run("BasicMessageTransformation", func(t *testing.T) {
 		request := map[string]interface{}{
 			"model": "gemini-pro",
@@ -395,6 +536,18 @@ func TestGeminiTransformResponseOut(t *testing.T) {
 		testutil.AssertEqual(t, "chat.completion", transformedResp["object"])
 		testutil.AssertEqual(t, "gemini-pro", transformedResp["model"])
 
+		// The requested model replaces the default name
+		resp = &http.Response{
+			StatusCode: 200,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(string(responseBody))),
+		}
+		result, err = transformer.TransformResponseOut(WithRequestModel(ctx, "gemini-2.0-flash"), resp)
+		testutil.AssertNoError(t, err)
+		var requestedResp map[string]interface{}
+		testutil.AssertNoError(t, json.NewDecoder(result.Body).Decode(&requestedResp))
+		testutil.AssertEqual(t, "gemini-2.0-flash", requestedResp["model"])
+
 		// Check choices
 		choices := transformedResp["choices"].([]interface{})
 		testutil.AssertEqual(t, 1, len(choices))
@@ -412,6 +565,25 @@ func TestGeminiTransformResponseOut(t *testing.T) {
 		testutil.AssertEqual(t, 10, int(usage["prompt_tokens"].(float64)))
 		testutil.AssertEqual(t, 15, int(usage["completion_tokens"].(float64)))
 		testutil.AssertEqual(t, 25, int(usage["total_tokens"].(float64)))
+
+		// The id is derived from the response, so it is the same both times
+		id, _ := transformedResp["id"].(string)
+		testutil.AssertTrue(t, strings.HasPrefix(id, "chatcmpl-"))
+		testutil.AssertEqual(t, id, requestedResp["id"])
+	})
+
+	t.Run("NonStreamingResponseID", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: 200,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(`{"responseId":"resp-1","candidates":[]}`)),
+		}
+
+		result, err := transformer.TransformResponseOut(ctx, resp)
+		testutil.AssertNoError(t, err)
+		var transformedResp map[string]interface{}
+		testutil.AssertNoError(t, json.NewDecoder(result.Body).Decode(&transformedResp))
+		testutil.AssertEqual(t, "resp-1", transformedResp["id"])
 	})
 
 	t.Run("ResponseWithFunctionCall", func(t *testing.T) {
@@ -516,6 +688,25 @@ data: [DONE]
 		testutil.AssertEqual(t, "Hello", delta["content"])
 	})
 
+	t.Run("StreamingRequestedModel", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body: io.NopCloser(strings.NewReader(`data: {"candidates": [{"content": {"parts": [{"text": "Hello"}]}}], "modelVersion": "gemini-1.5-pro-002"}
+
+`)),
+		}
+
+		result, err := transformer.TransformResponseOut(WithRequestModel(ctx, "gemini-1.5-pro"), resp)
+		testutil.AssertNoError(t, err)
+
+		event, err := NewSSEReader(result.Body).ReadEvent()
+		testutil.AssertNoError(t, err)
+		var chunk map[string]interface{}
+		testutil.AssertNoError(t, json.Unmarshal([]byte(event.Data), &chunk))
+		testutil.AssertEqual(t, "gemini-1.5-pro", chunk["model"])
+	})
+
 	t.Run("InvalidJSON", func(t *testing.T) {
 		resp := &http.Response{
 			StatusCode: 200,
@@ -581,7 +772,7 @@ func TestGeminiTransformStreamEvent(t *testing.T) {
 		jsonData, _ := json.Marshal(eventData)
 		event := &SSEEvent{Data: string(jsonData)}
 
-		result := transformer.transformStreamEvent(event)
+		result := transformer.transformStreamEvent(event, "")
 		testutil.AssertNotEqual(t, nil, result)
 
 		var transformedData map[string]interface{}
@@ -590,6 +781,17 @@ func TestGeminiTransformStreamEvent(t *testing.T) {
 		testutil.AssertEqual(t, "chat.completion.chunk", transformedData["object"])
 		testutil.AssertEqual(t, "gemini-pro", transformedData["model"])
 
+		// The requested model wins over the reported model version
+		eventData["modelVersion"] = "gemini-1.5-flash-002"
+		jsonData, _ = json.Marshal(eventData)
+		result = transformer.transformStreamEvent(&SSEEvent{Data: string(jsonData)}, "")
+		json.Unmarshal([]byte(result.Data), &transformedData)
+		testutil.AssertEqual(t, "gemini-1.5-flash-002", transformedData["model"])
+
+		result = transformer.transformStreamEvent(&SSEEvent{Data: string(jsonData)}, "gemini-1.5-flash")
+		json.Unmarshal([]byte(result.Data), &transformedData)
+		testutil.AssertEqual(t, "gemini-1.5-flash", transformedData["model"])
+
 		choices := transformedData["choices"].([]interface{})
 		choice := choices[0].(map[string]interface{})
 		delta := choice["delta"].(map[string]interface{})
@@ -619,7 +821,7 @@ func TestGeminiTransformStreamEvent(t *testing.T) {
 		jsonData, _ := json.Marshal(eventData)
 		event := &SSEEvent{Data: string(jsonData)}
 
-		result := transformer.transformStreamEvent(event)
+		result := transformer.transformStreamEvent(event, "")
 		testutil.AssertNotEqual(t, nil, result)
 
 		var transformedData map[string]interface{}
@@ -643,7 +845,7 @@ func TestGeminiTransformStreamEvent(t *testing.T) {
 	t.Run("InvalidEventData", func(t *testing.T) {
 		event := &SSEEvent{Data: "invalid json"}
 
-		result := transformer.transformStreamEvent(event)
+		result := transformer.transformStreamEvent(event, "")
 		testutil.AssertEqual(t, (*SSEEvent)(nil), result)
 	})
 }
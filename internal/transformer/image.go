@@ -0,0 +1,219 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// nonVisionProviders lists providers whose APIs reject image content
+var nonVisionProviders = map[string]bool{
+	"deepseek": true,
+}
+
+// imagePart is an image taken from an Anthropic image block or an OpenAI
+// image_url part
+type imagePart struct {
+	mediaType string
+	data      string // Base64 data, empty for URL images
+	url       string
+}
+
+// parseImagePart extracts the image from a content part, if it is one
+func parseImagePart(part map[string]interface{}) (*imagePart, bool) {
+	switch part["type"] {
+	case "image":
+		source, ok := part["source"].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		mediaType, _ := source["media_type"].(string)
+		switch source["type"] {
+		case "base64":
+			data, _ := source["data"].(string)
+			return &imagePart{mediaType: mediaType, data: data}, data != ""
+		case "url":
+			url, _ := source["url"].(string)
+			return &imagePart{mediaType: mediaType, url: url}, url != ""
+		}
+
+	case "image_url":
+		var url string
+		switch imageURL := part["image_url"].(type) {
+		case map[string]interface{}:
+			url, _ = imageURL["url"].(string)
+		case string:
+			url = imageURL
+		}
+		if url == "" {
+			return nil, false
+		}
+		// Data URLs carry base64 data: data:<media type>;base64,<data>
+		if rest, ok := strings.CutPrefix(url, "data:"); ok {
+			header, data, found := strings.Cut(rest, ",")
+			if mediaType, isBase64 := strings.CutSuffix(header, ";base64"); found && isBase64 {
+				return &imagePart{mediaType: mediaType, data: data}, true
+			}
+		}
+		return &imagePart{url: url}, true
+	}
+
+	return nil, false
+}
+
+// anthropicBlock returns the image as an Anthropic image block
+func (img *imagePart) anthropicBlock() map[string]interface{} {
+	source := map[string]interface{}{"type": "url", "url": img.url}
+	if img.data != "" {
+		source = map[string]interface{}{
+			"type":       "base64",
+			"media_type": img.mediaType,
+			"data":       img.data,
+		}
+	}
+	return map[string]interface{}{"type": "image", "source": source}
+}
+
+// openAIPart returns the image as an OpenAI image_url part
+func (img *imagePart) openAIPart() map[string]interface{} {
+	url := img.url
+	if img.data != "" {
+		url = fmt.Sprintf("data:%s;base64,%s", img.mediaType, img.data)
+	}
+	return map[string]interface{}{
+		"type":      "image_url",
+		"image_url": map[string]interface{}{"url": url},
+	}
+}
+
+// geminiPart returns the image as a Gemini inlineData or fileData part
+func (img *imagePart) geminiPart() map[string]interface{} {
+	if img.data != "" {
+		return map[string]interface{}{
+			"inlineData": map[string]interface{}{
+				"mimeType": img.mediaType,
+				"data":     img.data,
+			},
+		}
+	}
+
+	fileData := map[string]interface{}{"fileUri": img.url}
+	if img.mediaType != "" {
+		fileData["mimeType"] = img.mediaType
+	}
+	return map[string]interface{}{"fileData": fileData}
+}
+
+// convertImageContent rewrites the image parts of a message's content with
+// convert, leaving other parts untouched. It returns the content and the
+// number of images found.
+func convertImageContent(content interface{}, convert func(*imagePart) map[string]interface{}) (interface{}, int) {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return content, 0
+	}
+
+	images := 0
+	converted := make([]interface{}, len(parts))
+	for i, part := range parts {
+		converted[i] = part
+		partMap, ok := part.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if img, ok := parseImagePart(partMap); ok {
+			converted[i] = convert(img)
+			images++
+		}
+	}
+	return converted, images
+}
+
+// CountImages returns the number of image parts in a request's messages
+func CountImages(bodyMap map[string]interface{}) int {
+	messages, _ := bodyMap["messages"].([]interface{})
+	count := 0
+	for _, msg := range messages {
+		msgMap, ok := msg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		parts, _ := msgMap["content"].([]interface{})
+		for _, part := range parts {
+			if partMap, ok := part.(map[string]interface{}); ok {
+				if _, isImage := parseImagePart(partMap); isImage {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// ImageTransformer translates image content parts into the format each
+// provider expects and removes them for providers without vision support.
+// Gemini and Vertex AI requests are converted by their provider transformer.
+type ImageTransformer struct {
+	BaseTransformer
+}
+
+// NewImageTransformer creates a new image transformer
+func NewImageTransformer() *ImageTransformer {
+	return &ImageTransformer{
+		BaseTransformer: *NewBaseTransformer("image", ""),
+	}
+}
+
+// TransformRequestIn converts image parts in the request messages
+func (t *ImageTransformer) TransformRequestIn(ctx context.Context, request interface{}, provider string) (interface{}, error) {
+	bodyMap, ok := request.(map[string]interface{})
+	if reqConfig, isConfig := request.(*RequestConfig); isConfig {
+		bodyMap, ok = reqConfig.Body.(map[string]interface{})
+	}
+	if !ok {
+		return request, nil
+	}
+
+	var convert func(*imagePart) map[string]interface{}
+	switch {
+	case provider == "gemini" || provider == "vertex":
+		return request, nil
+	case provider == "anthropic":
+		convert = (*imagePart).anthropicBlock
+	case nonVisionProviders[provider]:
+		convert = func(*imagePart) map[string]interface{} {
+			return map[string]interface{}{
+				"type": "text",
+				"text": fmt.Sprintf("[image omitted: %s does not support image input]", provider),
+			}
+		}
+	default:
+		convert = (*imagePart).openAIPart
+	}
+
+	messages, ok := bodyMap["messages"].([]interface{})
+	if !ok {
+		return request, nil
+	}
+
+	total := 0
+	for _, msg := range messages {
+		msgMap, ok := msg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		content, images := convertImageContent(msgMap["content"], convert)
+		if images > 0 {
+			msgMap["content"] = content
+			total += images
+		}
+	}
+
+	if total > 0 && nonVisionProviders[provider] {
+		utils.GetLogger().Warnf("Dropped %d image(s): provider %s does not support image input", total, provider)
+	}
+
+	return request, nil
+}
@@ -0,0 +1,162 @@
+package transformer
+
+import (
+	"context"
+	"testing"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+// testPNG is a 1x1 PNG image
+const testPNG = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="
+
+// imageRequest builds a request with a text block followed by an image part
+func imageRequest(image map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"model": "test-model",
+		"messages": []interface{}{
+			map[string]interface{}{
+				"role": "user",
+				"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "What is this?"},
+					image,
+				},
+			},
+		},
+	}
+}
+
+func anthropicImageBlock() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "image",
+		"source": map[string]interface{}{
+			"type":       "base64",
+			"media_type": "image/png",
+			"data":       testPNG,
+		},
+	}
+}
+
+func openAIImagePart() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "image_url",
+		"image_url": map[string]interface{}{"url": "data:image/png;base64," + testPNG},
+	}
+}
+
+// contentParts returns the content parts of the request's first message
+func contentParts(t *testing.T, request interface{}) []interface{} {
+	t.Helper()
+	messages := request.(map[string]interface{})["messages"].([]interface{})
+	return messages[0].(map[string]interface{})["content"].([]interface{})
+}
+
+func TestImageTransformer(t *testing.T) {
+	transformer := NewImageTransformer()
+	ctx := context.Background()
+
+	t.Run("AnthropicToOpenAI", func(t *testing.T) {
+		result, err := transformer.TransformRequestIn(ctx, imageRequest(anthropicImageBlock()), "openai")
+		testutil.AssertNoError(t, err)
+
+		parts := contentParts(t, result)
+		testutil.AssertEqual(t, "What is this?", parts[0].(map[string]interface{})["text"])
+		image := parts[1].(map[string]interface{})
+		testutil.AssertEqual(t, "image_url", image["type"])
+		testutil.AssertEqual(t, "data:image/png;base64,"+testPNG, image["image_url"].(map[string]interface{})["url"])
+	})
+
+	t.Run("OpenAIToAnthropic", func(t *testing.T) {
+		result, err := transformer.TransformRequestIn(ctx, imageRequest(openAIImagePart()), "anthropic")
+		testutil.AssertNoError(t, err)
+
+		image := contentParts(t, result)[1].(map[string]interface{})
+		testutil.AssertEqual(t, "image", image["type"])
+		source := image["source"].(map[string]interface{})
+		testutil.AssertEqual(t, "base64", source["type"])
+		testutil.AssertEqual(t, "image/png", source["media_type"])
+		testutil.AssertEqual(t, testPNG, source["data"])
+	})
+
+	t.Run("URLImageToAnthropic", func(t *testing.T) {
+		request := imageRequest(map[string]interface{}{
+			"type":      "image_url",
+			"image_url": "https://example.com/cat.png",
+		})
+		result, err := transformer.TransformRequestIn(ctx, request, "anthropic")
+		testutil.AssertNoError(t, err)
+
+		source := contentParts(t, result)[1].(map[string]interface{})["source"].(map[string]interface{})
+		testutil.AssertEqual(t, "url", source["type"])
+		testutil.AssertEqual(t, "https://example.com/cat.png", source["url"])
+	})
+
+	t.Run("NonVisionProviderDropsImages", func(t *testing.T) {
+		result, err := transformer.TransformRequestIn(ctx, imageRequest(anthropicImageBlock()), "deepseek")
+		testutil.AssertNoError(t, err)
+
+		image := contentParts(t, result)[1].(map[string]interface{})
+		testutil.AssertEqual(t, "text", image["type"])
+		testutil.AssertContains(t, image["text"].(string), "does not support image input")
+	})
+
+	t.Run("GeminiLeftToProviderTransformer", func(t *testing.T) {
+		result, err := transformer.TransformRequestIn(ctx, imageRequest(anthropicImageBlock()), "gemini")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, "image", contentParts(t, result)[1].(map[string]interface{})["type"])
+	})
+
+	t.Run("RequestConfigBody", func(t *testing.T) {
+		reqConfig := &RequestConfig{Body: imageRequest(anthropicImageBlock())}
+		result, err := transformer.TransformRequestIn(ctx, reqConfig, "groq")
+		testutil.AssertNoError(t, err)
+
+		parts := contentParts(t, result.(*RequestConfig).Body)
+		testutil.AssertEqual(t, "image_url", parts[1].(map[string]interface{})["type"])
+	})
+}
+
+func TestGeminiTransformer_ImageContent(t *testing.T) {
+	transformer := NewGeminiTransformer()
+
+	for name, image := range map[string]map[string]interface{}{
+		"AnthropicBlock": anthropicImageBlock(),
+		"OpenAIDataURL":  openAIImagePart(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := transformer.TransformRequestIn(context.Background(), imageRequest(image), "gemini")
+			testutil.AssertNoError(t, err)
+
+			contents := result.(map[string]interface{})["contents"].([]interface{})
+			parts := contents[0].(map[string]interface{})["parts"].([]interface{})
+			testutil.AssertEqual(t, 2, len(parts))
+			testutil.AssertEqual(t, "What is this?", parts[0].(map[string]interface{})["text"])
+
+			inlineData := parts[1].(map[string]interface{})["inlineData"].(map[string]interface{})
+			testutil.AssertEqual(t, "image/png", inlineData["mimeType"])
+			testutil.AssertEqual(t, testPNG, inlineData["data"])
+		})
+	}
+
+	t.Run("URLImage", func(t *testing.T) {
+		request := imageRequest(map[string]interface{}{
+			"type":   "image",
+			"source": map[string]interface{}{"type": "url", "url": "https://example.com/cat.png"},
+		})
+		result, err := transformer.TransformRequestIn(context.Background(), request, "gemini")
+		testutil.AssertNoError(t, err)
+
+		contents := result.(map[string]interface{})["contents"].([]interface{})
+		parts := contents[0].(map[string]interface{})["parts"].([]interface{})
+		fileData := parts[1].(map[string]interface{})["fileData"].(map[string]interface{})
+		testutil.AssertEqual(t, "https://example.com/cat.png", fileData["fileUri"])
+	})
+}
+
+func TestCountImages(t *testing.T) {
+	testutil.AssertEqual(t, 1, CountImages(imageRequest(anthropicImageBlock())))
+	testutil.AssertEqual(t, 1, CountImages(imageRequest(openAIImagePart())))
+	testutil.AssertEqual(t, 0, CountImages(map[string]interface{}{
+		"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+	}))
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
@@ -26,8 +27,10 @@ func NewMaxTokenTransformer() *MaxTokenTransformer {
 		providerLimits: map[string]int{
 			"anthropic":  200000,  // Claude 3 models
 			"openai":     128000,  // GPT-4 Turbo
+			"azure":      128000,  // Azure OpenAI GPT-4 deployments
 			"groq":       32768,   // Typical Groq limit
 			"gemini":     1048576, // Gemini 1.5 Pro
+			"vertex":     1048576, // Gemini on Vertex AI
 			"deepseek":   32768,   // DeepSeek default
 			"openrouter": 200000,  // Varies by model
 			"mistral":    32768,   // Mistral default
@@ -146,6 +149,12 @@ func (t *MaxTokenTransformer) processMaxTokens(bodyMap map[string]interface{}, p
 
 // TransformResponseOut adds token usage information if available
 func (t *MaxTokenTransformer) TransformResponseOut(ctx context.Context, response *http.Response) (*http.Response, error) {
+	// Streams carry no usage object to complete, and reading one here would
+	// hold it back until the provider finishes
+	if strings.Contains(response.Header.Get("Content-Type"), "text/event-stream") {
+		return response, nil
+	}
+
 	// Read response body
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
@@ -0,0 +1,123 @@
+package transformer
+
+import (
+	"context"
+)
+
+// MergeMessagesTransformer merges consecutive user or assistant messages into
+// one for providers that reject or mishandle repeated roles. It is added to
+// the default chain of providers with merge_consecutive_messages set.
+type MergeMessagesTransformer struct {
+	*BaseTransformer
+}
+
+// NewMergeMessagesTransformer creates a new MergeMessages transformer
+func NewMergeMessagesTransformer() *MergeMessagesTransformer {
+	return &MergeMessagesTransformer{
+		BaseTransformer: NewBaseTransformer("mergemessages", ""),
+	}
+}
+
+// TransformRequestIn merges consecutive same-role messages
+func (t *MergeMessagesTransformer) TransformRequestIn(ctx context.Context, request interface{}, provider string) (interface{}, error) {
+	// Handle RequestConfig
+	if reqConfig, ok := request.(*RequestConfig); ok {
+		if bodyMap, ok := reqConfig.Body.(map[string]interface{}); ok {
+			MergeConsecutiveMessages(bodyMap)
+		}
+		return reqConfig, nil
+	}
+
+	if bodyMap, ok := request.(map[string]interface{}); ok {
+		MergeConsecutiveMessages(bodyMap)
+	}
+	return request, nil
+}
+
+// MergeConsecutiveMessages merges runs of user or assistant messages in a
+// request into single messages. String contents are joined with a newline;
+// when either side is an array of content blocks the blocks are concatenated.
+// Messages carrying fields other than role and content, such as OpenAI
+// tool_calls or tool_call_id, are never merged. The original message maps
+// are left unchanged.
+func MergeConsecutiveMessages(bodyMap map[string]interface{}) {
+	messages, ok := bodyMap["messages"].([]interface{})
+	if !ok || len(messages) < 2 {
+		return
+	}
+
+	merged := make([]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		if n := len(merged); n > 0 {
+			if prev, ok := mergeableMessage(merged[n-1]); ok {
+				if next, ok := mergeableMessage(msg); ok && prev["role"] == next["role"] {
+					merged[n-1] = map[string]interface{}{
+						"role":    prev["role"],
+						"content": mergeContent(prev["content"], next["content"]),
+					}
+					continue
+				}
+			}
+		}
+		merged = append(merged, msg)
+	}
+
+	if len(merged) < len(messages) {
+		bodyMap["messages"] = merged
+	}
+}
+
+// mergeableMessage returns msg as a map when it is a plain user or assistant
+// message with only role and content
+func mergeableMessage(msg interface{}) (map[string]interface{}, bool) {
+	msgMap, ok := msg.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if role := msgMap["role"]; role != "user" && role != "assistant" {
+		return nil, false
+	}
+	for key := range msgMap {
+		if key != "role" && key != "content" {
+			return nil, false
+		}
+	}
+	switch msgMap["content"].(type) {
+	case string, []interface{}:
+		return msgMap, true
+	}
+	return nil, false
+}
+
+// mergeContent combines two message contents, joining strings with a newline
+// and concatenating block arrays
+func mergeContent(first, second interface{}) interface{} {
+	firstText, firstIsText := first.(string)
+	secondText, secondIsText := second.(string)
+	if firstIsText && secondIsText {
+		switch {
+		case firstText == "":
+			return secondText
+		case secondText == "":
+			return firstText
+		}
+		return firstText + "\n" + secondText
+	}
+
+	return append(contentBlocks(first), contentBlocks(second)...)
+}
+
+// contentBlocks returns content as a new array of blocks, wrapping a
+// non-empty string in a text block
+func contentBlocks(content interface{}) []interface{} {
+	switch v := content.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []interface{}{map[string]interface{}{"type": "text", "text": v}}
+	case []interface{}:
+		return append([]interface{}(nil), v...)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package transformer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+)
+
+func TestMergeConsecutiveMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []interface{}
+		want     []interface{}
+	}{
+		{
+			name: "user/user strings",
+			messages: []interface{}{
+				map[string]interface{}{"role": "user", "content": "First"},
+				map[string]interface{}{"role": "user", "content": "Second"},
+				map[string]interface{}{"role": "assistant", "content": "Reply"},
+			},
+			want: []interface{}{
+				map[string]interface{}{"role": "user", "content": "First\nSecond"},
+				map[string]interface{}{"role": "assistant", "content": "Reply"},
+			},
+		},
+		{
+			name: "assistant/assistant strings",
+			messages: []interface{}{
+				map[string]interface{}{"role": "user", "content": "Question"},
+				map[string]interface{}{"role": "assistant", "content": "Part one"},
+				map[string]interface{}{"role": "assistant", "content": "Part two"},
+				map[string]interface{}{"role": "assistant", "content": "Part three"},
+			},
+			want: []interface{}{
+				map[string]interface{}{"role": "user", "content": "Question"},
+				map[string]interface{}{"role": "assistant", "content": "Part one\nPart two\nPart three"},
+			},
+		},
+		{
+			name: "user/user blocks and string",
+			messages: []interface{}{
+				map[string]interface{}{"role": "user", "content": []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_1", "content": "42"},
+				}},
+				map[string]interface{}{"role": "user", "content": "Thanks"},
+			},
+			want: []interface{}{
+				map[string]interface{}{"role": "user", "content": []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_1", "content": "42"},
+					map[string]interface{}{"type": "text", "text": "Thanks"},
+				}},
+			},
+		},
+		{
+			name: "assistant/assistant blocks",
+			messages: []interface{}{
+				map[string]interface{}{"role": "assistant", "content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "Checking"},
+				}},
+				map[string]interface{}{"role": "assistant", "content": []interface{}{
+					map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "lookup"},
+				}},
+			},
+			want: []interface{}{
+				map[string]interface{}{"role": "assistant", "content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "Checking"},
+					map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "lookup"},
+				}},
+			},
+		},
+		{
+			name: "empty string content",
+			messages: []interface{}{
+				map[string]interface{}{"role": "user", "content": ""},
+				map[string]interface{}{"role": "user", "content": "Hello"},
+			},
+			want: []interface{}{
+				map[string]interface{}{"role": "user", "content": "Hello"},
+			},
+		},
+		{
+			name: "alternating roles unchanged",
+			messages: []interface{}{
+				map[string]interface{}{"role": "user", "content": "Hi"},
+				map[string]interface{}{"role": "assistant", "content": "Hello"},
+				map[string]interface{}{"role": "user", "content": "Bye"},
+			},
+			want: []interface{}{
+				map[string]interface{}{"role": "user", "content": "Hi"},
+				map[string]interface{}{"role": "assistant", "content": "Hello"},
+				map[string]interface{}{"role": "user", "content": "Bye"},
+			},
+		},
+		{
+			name: "tool calls and tool messages unchanged",
+			messages: []interface{}{
+				map[string]interface{}{"role": "assistant", "content": "", "tool_calls": []interface{}{}},
+				map[string]interface{}{"role": "assistant", "content": "Done"},
+				map[string]interface{}{"role": "tool", "tool_call_id": "call_1", "content": "1"},
+				map[string]interface{}{"role": "tool", "tool_call_id": "call_2", "content": "2"},
+			},
+			want: []interface{}{
+				map[string]interface{}{"role": "assistant", "content": "", "tool_calls": []interface{}{}},
+				map[string]interface{}{"role": "assistant", "content": "Done"},
+				map[string]interface{}{"role": "tool", "tool_call_id": "call_1", "content": "1"},
+				map[string]interface{}{"role": "tool", "tool_call_id": "call_2", "content": "2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodyMap := map[string]interface{}{"model": "test-model", "messages": tt.messages}
+			MergeConsecutiveMessages(bodyMap)
+
+			if !reflect.DeepEqual(tt.want, bodyMap["messages"]) {
+				t.Errorf("Expected messages %v, got %v", tt.want, bodyMap["messages"])
+			}
+		})
+	}
+
+	t.Run("OriginalMessagesUnchanged", func(t *testing.T) {
+		first := map[string]interface{}{"role": "user", "content": "First"}
+		bodyMap := map[string]interface{}{"messages": []interface{}{
+			first,
+			map[string]interface{}{"role": "user", "content": "Second"},
+		}}
+		MergeConsecutiveMessages(bodyMap)
+
+		if first["content"] != "First" {
+			t.Errorf("Expected the original message to be unchanged, got %v", first["content"])
+		}
+	})
+}
+
+func TestMergeMessagesTransformer_TransformRequestIn(t *testing.T) {
+	ctx := context.Background()
+	transformer := NewMergeMessagesTransformer()
+
+	if transformer.GetName() != "mergemessages" {
+		t.Errorf("Expected name 'mergemessages', got %s", transformer.GetName())
+	}
+
+	newRequest := func() map[string]interface{} {
+		return map[string]interface{}{
+			"messages": []interface{}{
+				map[string]interface{}{"role": "user", "content": "First"},
+				map[string]interface{}{"role": "user", "content": "Second"},
+			},
+		}
+	}
+
+	t.Run("BodyMap", func(t *testing.T) {
+		result, err := transformer.TransformRequestIn(ctx, newRequest(), "gemini")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if messages := result.(map[string]interface{})["messages"].([]interface{}); len(messages) != 1 {
+			t.Errorf("Expected 1 merged message, got %d", len(messages))
+		}
+	})
+
+	t.Run("RequestConfig", func(t *testing.T) {
+		result, err := transformer.TransformRequestIn(ctx, &RequestConfig{Body: newRequest()}, "gemini")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		body := result.(*RequestConfig).Body.(map[string]interface{})
+		if messages := body["messages"].([]interface{}); len(messages) != 1 {
+			t.Errorf("Expected 1 merged message, got %d", len(messages))
+		}
+	})
+}
+
+func TestService_ConfigureMessageMerging(t *testing.T) {
+	service := NewService()
+	if err := RegisterBuiltinTransformers(service); err != nil {
+		t.Fatalf("Failed to register transformers: %v", err)
+	}
+
+	firstName := func(provider string) string {
+		return service.GetChainForProvider(provider).transformers[0].GetName()
+	}
+
+	if got := firstName("gemini"); got != "gemini" {
+		t.Errorf("Expected the default chain to start with gemini, got %s", got)
+	}
+
+	// Opting in rebuilds the cached chain with merging first
+	service.ConfigureMessageMerging([]config.Provider{
+		{Name: "gemini", MergeConsecutiveMessages: true},
+		{Name: "openai"},
+	})
+	if got := firstName("gemini"); got != "mergemessages" {
+		t.Errorf("Expected the gemini chain to start with mergemessages, got %s", got)
+	}
+	if got := firstName("openai"); got != "openai" {
+		t.Errorf("Expected the openai chain to be unchanged, got %s", got)
+	}
+
+	// Opting out removes it again
+	service.ConfigureMessageMerging([]config.Provider{{Name: "gemini"}})
+	if got := firstName("gemini"); got != "gemini" {
+		t.Errorf("Expected mergemessages to be removed, got %s", got)
+	}
+}
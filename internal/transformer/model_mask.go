@@ -0,0 +1,146 @@
+package transformer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// requestModelKey is the context key for the model sent upstream
+type requestModelKey struct{}
+
+// WithRequestModel returns a context carrying the model a request was sent
+// upstream with, for transformers whose responses do not name it
+func WithRequestModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, requestModelKey{}, model)
+}
+
+// RequestModel returns the model set by WithRequestModel, or ""
+func RequestModel(ctx context.Context) string {
+	model, _ := ctx.Value(requestModelKey{}).(string)
+	return model
+}
+
+// ModelMaskTransformer rewrites the model named in responses, so clients see
+// the model they asked for or an alias instead of the upstream model
+type ModelMaskTransformer struct {
+	BaseTransformer
+	model string
+}
+
+// NewModelMaskTransformer creates a transformer that reports model in every
+// response
+func NewModelMaskTransformer(model string) *ModelMaskTransformer {
+	return &ModelMaskTransformer{
+		BaseTransformer: *NewBaseTransformer("model-mask", ""),
+		model:           model,
+	}
+}
+
+// TransformResponseOut rewrites the model of a JSON response, or of every
+// event of a stream. Both the OpenAI top-level model and the Anthropic
+// message.model are covered. Error responses pass through unchanged.
+func (t *ModelMaskTransformer) TransformResponseOut(ctx context.Context, response *http.Response) (*http.Response, error) {
+	if response == nil || response.Body == nil || response.StatusCode >= http.StatusBadRequest {
+		return response, nil
+	}
+	if isStreamingResponse(response) {
+		return t.transformStream(response), nil
+	}
+
+	body, err := io.ReadAll(response.Body)
+	_ = response.Body.Close() // Safe to ignore: body has been fully read
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	data := t.maskData(body)
+	header := response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+
+	newResp := *response
+	newResp.Header = header
+	newResp.Body = io.NopCloser(bytes.NewReader(data))
+	newResp.ContentLength = int64(len(data))
+	return &newResp, nil
+}
+
+// transformStream rewrites each event of a stream as it is read
+func (t *ModelMaskTransformer) transformStream(response *http.Response) *http.Response {
+	reader, _ := NewStreamReader(response)
+	pr, pw := io.Pipe()
+
+	header := response.Header.Clone()
+	header.Set("Content-Type", "text/event-stream")
+	header.Del("Content-Length")
+
+	newResp := *response
+	newResp.Header = header
+	newResp.Body = &pipeBody{PipeReader: pr, upstream: reader}
+	newResp.ContentLength = -1
+
+	go func() {
+		defer reader.Close()
+		writer := NewSSEWriter(pw)
+		for {
+			event, err := reader.ReadEvent()
+			if err != nil {
+				if err == io.EOF {
+					_ = pw.Close() // Safe to ignore: always nil
+				} else {
+					_ = pw.CloseWithError(err) // Safe to ignore: always nil
+				}
+				return
+			}
+			if event.Data != "" {
+				event.Data = string(t.maskData([]byte(event.Data)))
+			}
+			if err := writer.WriteEvent(event); err != nil {
+				return
+			}
+		}
+	}()
+
+	return &newResp
+}
+
+// maskData replaces the model in a JSON object. Anything else, such as
+// [DONE] or malformed data, is returned unchanged.
+func (t *ModelMaskTransformer) maskData(data []byte) []byte {
+	if !bytes.Contains(data, []byte(`"model"`)) {
+		return data
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return data
+	}
+
+	masked := false
+	if _, ok := payload["model"].(string); ok {
+		payload["model"] = t.model
+		masked = true
+	}
+	if message, ok := payload["message"].(map[string]interface{}); ok {
+		if _, ok := message["model"].(string); ok {
+			message["model"] = t.model
+			masked = true
+		}
+	}
+	if !masked {
+		return data
+	}
+
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return data
+	}
+	return out
+}
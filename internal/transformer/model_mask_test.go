@@ -0,0 +1,78 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestModelMaskTransformer(t *testing.T) {
+	ctx := context.Background()
+	mask := NewModelMaskTransformer("claude-3-opus")
+
+	t.Run("JSONResponse", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"id":"msg_1","type":"message","model":"gpt-4o","content":[]}`)),
+		}
+
+		result, err := mask.TransformResponseOut(ctx, resp)
+		testutil.AssertNoError(t, err)
+
+		var body map[string]interface{}
+		testutil.AssertNoError(t, json.NewDecoder(result.Body).Decode(&body))
+		testutil.AssertEqual(t, "claude-3-opus", body["model"])
+		testutil.AssertEqual(t, "msg_1", body["id"])
+	})
+
+	t.Run("StreamEvents", func(t *testing.T) {
+		stream := strings.Join([]string{
+			`event: message_start`,
+			`data: {"type":"message_start","message":{"id":"msg_1","model":"gpt-4o"}}`,
+			``,
+			`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[]}`,
+			``,
+			`event: content_block_delta`,
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"the model is gpt-4o"}}`,
+			``,
+			`data: [DONE]`,
+			``,
+		}, "\n")
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(stream)),
+		}
+
+		result, err := mask.TransformResponseOut(ctx, resp)
+		testutil.AssertNoError(t, err)
+		data, err := io.ReadAll(result.Body)
+		testutil.AssertNoError(t, err)
+		out := string(data)
+
+		testutil.AssertContains(t, out, "event: message_start")
+		testutil.AssertContains(t, out, `"model":"claude-3-opus"`)
+		testutil.AssertFalse(t, strings.Contains(out, `"model":"gpt-4o"`))
+		// Text that merely mentions the model is left alone
+		testutil.AssertContains(t, out, "the model is gpt-4o")
+		testutil.AssertContains(t, out, "data: [DONE]")
+	})
+
+	t.Run("ErrorResponseUnchanged", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"unknown model gpt-4o"},"model":"gpt-4o"}`)),
+		}
+
+		result, err := mask.TransformResponseOut(ctx, resp)
+		testutil.AssertNoError(t, err)
+		testutil.AssertTrue(t, result == resp)
+	})
+}
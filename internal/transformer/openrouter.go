@@ -43,13 +43,14 @@ func (t *OpenRouterTransformer) TransformResponseOut(ctx context.Context, respon
 		ProtoMajor:    response.ProtoMajor,
 		ProtoMinor:    response.ProtoMinor,
 		Header:        response.Header.Clone(),
-		Body:          pr,
+		Body:          &pipeBody{PipeReader: pr, upstream: reader},
 		ContentLength: -1,
 		Request:       response.Request,
 	}
 
 	// Start transformation in goroutine
 	go func() {
+		defer reader.Close()
 		defer pw.Close()
 		writer := NewSSEWriter(pw)
 
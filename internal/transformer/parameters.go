@@ -4,13 +4,124 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
 
+// responseFormatProviders lists providers that accept response_format natively
+var responseFormatProviders = map[string]bool{
+	"openai":     true,
+	"azure":      true,
+	"deepseek":   true,
+	"groq":       true,
+	"mistral":    true,
+	"openrouter": true,
+	"xai":        true,
+}
+
+// jsonSchemaProviders lists the response_format providers that also accept
+// json_schema structured outputs. The others get plain JSON mode instead.
+var jsonSchemaProviders = map[string]bool{
+	"openai":     true,
+	"azure":      true,
+	"groq":       true,
+	"mistral":    true,
+	"openrouter": true,
+	"xai":        true,
+}
+
+// seedFields lists providers that accept a sampling seed and the field that
+// carries it. Other providers have the seed dropped.
+var seedFields = map[string]string{
+	"openai":     "seed",
+	"azure":      "seed",
+	"groq":       "seed",
+	"openrouter": "seed",
+	"xai":        "seed",
+	"ollama":     "seed",
+	"mistral":    "random_seed",
+}
+
+// SupportsSeed reports whether a provider honours a sampling seed
+func SupportsSeed(provider string) bool {
+	_, ok := seedFields[provider]
+	return ok
+}
+
+// logprobsProviders lists providers that return token log probabilities.
+// Other providers have logprobs and top_logprobs dropped.
+var logprobsProviders = map[string]bool{
+	"openai":     true,
+	"azure":      true,
+	"deepseek":   true,
+	"openrouter": true,
+	"xai":        true,
+}
+
+// logprobsFields are the request fields that ask for token log probabilities
+var logprobsFields = []string{"logprobs", "top_logprobs"}
+
+// SupportsLogprobs reports whether a provider returns token log probabilities
+func SupportsLogprobs(provider string) bool {
+	return logprobsProviders[provider]
+}
+
+// multipleCompletionProviders lists providers that accept n > 1 natively.
+// Gemini and Vertex AI receive it as generationConfig.candidateCount.
+var multipleCompletionProviders = map[string]bool{
+	"openai":  true,
+	"azure":   true,
+	"mistral": true,
+	"xai":     true,
+	"gemini":  true,
+	"vertex":  true,
+}
+
+// SupportsMultipleCompletions reports whether a provider can return several
+// choices for one request
+func SupportsMultipleCompletions(provider string) bool {
+	return multipleCompletionProviders[provider]
+}
+
+// stopSequenceField names the stop sequence field and its maximum number of
+// entries for a provider, 0 meaning no limit
+type stopSequenceField struct {
+	name string
+	max  int
+}
+
+// stopSequenceFields lists providers that differ from the OpenAI-compatible
+// default of an unlimited "stop" array
+var stopSequenceFields = map[string]stopSequenceField{
+	"anthropic": {name: "stop_sequences"},
+	"openai":    {name: "stop", max: 4},
+	"azure":     {name: "stop", max: 4},
+	"groq":      {name: "stop", max: 4},
+	"deepseek":  {name: "stop", max: 16},
+	"gemini":    {name: "stopSequences", max: 5},
+	"vertex":    {name: "stopSequences", max: 5},
+}
+
+// unsupportedParams lists request fields each provider rejects with a 400
+var unsupportedParams = map[string][]string{
+	"anthropic": {"presence_penalty", "frequency_penalty", "logit_bias", "user", "parallel_tool_calls"},
+	"gemini":    {"presence_penalty", "frequency_penalty", "logit_bias", "user", "parallel_tool_calls", "metadata"},
+	"vertex":    {"presence_penalty", "frequency_penalty", "logit_bias", "user", "parallel_tool_calls", "metadata"},
+	"groq":      {"logit_bias", "metadata"},
+	"mistral":   {"user", "metadata"},
+}
+
 // ParametersTransformer handles common parameters across different providers
 type ParametersTransformer struct {
 	*BaseTransformer
 	parameterMappings map[string]map[string]string // provider -> parameter -> mapped name
 	parameterLimits   map[string]map[string]Range  // provider -> parameter -> valid range
+
+	mu                sync.RWMutex
+	unsupportedParams map[string][]string // provider -> configured fields to strip
 }
 
 // Range defines min and max values for a parameter
@@ -34,11 +145,18 @@ func NewParametersTransformer() *ParametersTransformer {
 				"top_p":      "topP",
 				"top_k":      "topK",
 			},
+			// Vertex AI serves Gemini models
+			"vertex": {
+				"max_tokens": "maxOutputTokens",
+				"top_p":      "topP",
+				"top_k":      "topK",
+			},
 			// DeepSeek uses standard names
 			"deepseek": {},
 			// Groq uses standard names
 			"groq": {},
 		},
+		unsupportedParams: make(map[string][]string),
 		parameterLimits: map[string]map[string]Range{
 			"anthropic": {
 				"temperature": {Min: 0, Max: 1},
@@ -56,6 +174,11 @@ func NewParametersTransformer() *ParametersTransformer {
 				"topP":        {Min: 0, Max: 1},
 				"topK":        {Min: 1, Max: 100},
 			},
+			"vertex": {
+				"temperature": {Min: 0, Max: 2},
+				"topP":        {Min: 0, Max: 1},
+				"topK":        {Min: 1, Max: 100},
+			},
 			"deepseek": {
 				"temperature": {Min: 0, Max: 2},
 				"top_p":       {Min: 0, Max: 1},
@@ -121,6 +244,10 @@ func (t *ParametersTransformer) transformRequestConfig(ctx context.Context, reqC
 
 // processParameters validates and transforms parameters
 func (t *ParametersTransformer) processParameters(bodyMap map[string]interface{}, provider string) error {
+	// Drop fields the provider would reject before validating the rest
+	t.processUser(bodyMap, provider)
+	t.stripUnsupportedParams(bodyMap, provider)
+
 	// Get mappings and limits for provider
 	mappings, hasMappings := t.parameterMappings[provider]
 	limits, hasLimits := t.parameterLimits[provider]
@@ -157,27 +284,326 @@ func (t *ParametersTransformer) processParameters(bodyMap map[string]interface{}
 		}
 	}
 
+	t.processResponseFormat(bodyMap, provider)
+	t.processSeed(bodyMap, provider)
+	t.processLogprobs(bodyMap, provider)
+	t.processCacheControl(bodyMap, provider)
+	t.processCompletionCount(bodyMap, provider)
+	t.processStopSequences(bodyMap, provider)
+	t.processToolChoice(bodyMap, provider)
+
 	// Handle provider-specific validation
 	switch provider {
-	case "anthropic":
-		// Anthropic doesn't support presence_penalty or frequency_penalty
-		delete(bodyMap, "presence_penalty")
-		delete(bodyMap, "frequency_penalty")
-
-	case "gemini":
+	case "gemini", "vertex":
 		// Gemini parameters need to be in generationConfig
 		if err := t.wrapGeminiParameters(bodyMap); err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
+
+// SetUnsupportedParams sets the request fields stripped for a provider in
+// addition to its built-in list
+func (t *ParametersTransformer) SetUnsupportedParams(provider string, params []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(params) == 0 {
+		delete(t.unsupportedParams, provider)
+		return
+	}
+	t.unsupportedParams[provider] = append([]string(nil), params...)
+}
+
+// stripUnsupportedParams removes the fields the provider does not accept and
+// logs what was removed
+func (t *ParametersTransformer) stripUnsupportedParams(bodyMap map[string]interface{}, provider string) {
+	t.mu.RLock()
+	configured := t.unsupportedParams[provider]
+	t.mu.RUnlock()
+
+	var stripped []string
+	for _, params := range [][]string{unsupportedParams[provider], configured} {
+		for _, param := range params {
+			if _, exists := bodyMap[param]; exists {
+				delete(bodyMap, param)
+				stripped = append(stripped, param)
+			}
+		}
+	}
 
-	case "openai":
-		// Ensure logprobs is boolean if present
-		if logprobs, exists := bodyMap["logprobs"]; exists {
-			bodyMap["logprobs"] = t.toBool(logprobs)
+	if len(stripped) > 0 {
+		sort.Strings(stripped)
+		utils.GetLogger().Infof("Stripped parameters unsupported by %s: %s", provider, strings.Join(stripped, ", "))
+	}
+}
+
+// processUser forwards the end-user identifier in the field the provider
+// understands. Anthropic takes it as metadata.user_id, the only metadata it
+// accepts, and everyone else as OpenAI's user field. Providers without a user
+// field, or that reject metadata, have them stripped afterwards.
+func (t *ParametersTransformer) processUser(bodyMap map[string]interface{}, provider string) {
+	user := requestUser(bodyMap)
+	if provider == "anthropic" {
+		if user != "" {
+			bodyMap["metadata"] = map[string]interface{}{"user_id": user}
+		} else {
+			delete(bodyMap, "metadata")
 		}
+		return
 	}
 
-	return nil
+	if user != "" {
+		bodyMap["user"] = user
+	}
+}
+
+// requestUser returns the end-user identifier from the user field or from
+// metadata.user_id
+func requestUser(reqMap map[string]interface{}) string {
+	if user, ok := reqMap["user"].(string); ok && user != "" {
+		return user
+	}
+	if metadata, ok := reqMap["metadata"].(map[string]interface{}); ok {
+		if userID, ok := metadata["user_id"].(string); ok {
+			return userID
+		}
+	}
+	return ""
+}
+
+// processResponseFormat passes response_format through for providers that
+// support it and drops it for the rest, which would reject it with a 400.
+// JSON schemas become plain JSON mode for providers without structured
+// outputs, and Ollama receives the format in its own format field.
+func (t *ParametersTransformer) processResponseFormat(bodyMap map[string]interface{}, provider string) {
+	responseFormat, exists := bodyMap["response_format"]
+	if !exists {
+		return
+	}
+	formatMap, _ := responseFormat.(map[string]interface{})
+
+	if responseFormatProviders[provider] {
+		if formatMap["type"] == "json_schema" && !jsonSchemaProviders[provider] {
+			bodyMap["response_format"] = map[string]interface{}{"type": "json_object"}
+			utils.GetLogger().Warnf("Downgrading json_schema response_format to json_object: provider %s has no structured outputs", provider)
+		}
+		return
+	}
+
+	delete(bodyMap, "response_format")
+	if provider == "ollama" {
+		switch formatMap["type"] {
+		case "json_object":
+			bodyMap["format"] = "json"
+			return
+		case "json_schema":
+			if schema, ok := jsonSchemaOf(formatMap); ok {
+				bodyMap["format"] = schema
+			} else {
+				bodyMap["format"] = "json"
+			}
+			return
+		case "text":
+			return
+		}
+	}
+	utils.GetLogger().Warnf("Dropping response_format %v: provider %s has no equivalent", responseFormat, provider)
+}
+
+// jsonSchemaOf returns the schema of a json_schema response_format
+func jsonSchemaOf(formatMap map[string]interface{}) (map[string]interface{}, bool) {
+	jsonSchema, ok := formatMap["json_schema"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	schema, ok := jsonSchema["schema"].(map[string]interface{})
+	return schema, ok
+}
+
+// processSeed forwards the sampling seed under the provider's field name and
+// drops it for providers without deterministic sampling
+func (t *ParametersTransformer) processSeed(bodyMap map[string]interface{}, provider string) {
+	seed, exists := bodyMap["seed"]
+	if !exists {
+		return
+	}
+
+	field, ok := seedFields[provider]
+	if !ok {
+		delete(bodyMap, "seed")
+		utils.GetLogger().Warnf("Dropping seed %v: provider %s does not support deterministic sampling", seed, provider)
+		return
+	}
+	if field != "seed" {
+		delete(bodyMap, "seed")
+		bodyMap[field] = seed
+	}
+}
+
+// processLogprobs forwards logprobs and top_logprobs to providers that return
+// token log probabilities and drops them for the rest
+func (t *ParametersTransformer) processLogprobs(bodyMap map[string]interface{}, provider string) {
+	if !SupportsLogprobs(provider) {
+		for _, field := range dropLogprobs(bodyMap) {
+			utils.GetLogger().Warnf("Dropping %s: provider %s does not return token log probabilities", field, provider)
+		}
+		return
+	}
+
+	if logprobs, exists := bodyMap["logprobs"]; exists {
+		bodyMap["logprobs"] = t.toBool(logprobs)
+	}
+	// top_logprobs is rejected unless logprobs is enabled
+	if _, exists := bodyMap["top_logprobs"]; exists {
+		bodyMap["logprobs"] = true
+	}
+}
+
+// dropLogprobs removes the log probability fields from a request, returning
+// the names of the fields it removed
+func dropLogprobs(bodyMap map[string]interface{}) []string {
+	var dropped []string
+	for _, field := range logprobsFields {
+		if _, exists := bodyMap[field]; exists {
+			delete(bodyMap, field)
+			dropped = append(dropped, field)
+		}
+	}
+	return dropped
+}
+
+// warnDroppedLogprobs logs the log probability fields a request asked for
+// that a transformer building a new body for provider leaves out
+func warnDroppedLogprobs(reqMap map[string]interface{}, provider string) {
+	for _, field := range logprobsFields {
+		if _, exists := reqMap[field]; exists {
+			utils.GetLogger().Warnf("Dropping %s: provider %s does not return token log probabilities", field, provider)
+		}
+	}
+}
+
+// processCacheControl strips Anthropic prompt-caching markers for other
+// providers, which reject or ignore them
+func (t *ParametersTransformer) processCacheControl(bodyMap map[string]interface{}, provider string) {
+	if provider == "anthropic" {
+		return
+	}
+	if stripCacheControl(bodyMap) {
+		utils.GetLogger().Debugf("Stripped cache_control markers unsupported by %s", provider)
+	}
+}
+
+// processCompletionCount drops n for providers that would reject it. The
+// pipeline warns about or emulates larger counts before the request gets here.
+func (t *ParametersTransformer) processCompletionCount(bodyMap map[string]interface{}, provider string) {
+	if _, exists := bodyMap["n"]; exists && !multipleCompletionProviders[provider] {
+		delete(bodyMap, "n")
+	}
+}
+
+// processToolChoice converts tool_choice to OpenAI's form for OpenAI-compatible
+// providers, carrying a sequential tool call limit over as parallel_tool_calls,
+// which OpenAI rejects without tools. Anthropic, Gemini and Vertex requests
+// already carry both in their own form from the provider transformer.
+func (t *ParametersTransformer) processToolChoice(bodyMap map[string]interface{}, provider string) {
+	switch provider {
+	case "anthropic", "gemini", "vertex":
+		return
+	}
+	sequential := sequentialToolCalls(bodyMap)
+	if choice, ok := parseToolChoice(bodyMap["tool_choice"]); ok {
+		bodyMap["tool_choice"] = choice.openAI()
+	}
+
+	if tools, ok := bodyMap["tools"].([]interface{}); !ok || len(tools) == 0 {
+		delete(bodyMap, "parallel_tool_calls")
+		return
+	}
+	if sequential {
+		bodyMap["parallel_tool_calls"] = false
+	}
+}
+
+// processStopSequences renames stop or stop_sequences to the provider's field,
+// accepting a single string or an array, and truncates the list to the
+// provider's maximum
+func (t *ParametersTransformer) processStopSequences(bodyMap map[string]interface{}, provider string) {
+	field, ok := stopSequenceFields[provider]
+	if !ok {
+		field = stopSequenceField{name: "stop"}
+	}
+
+	var value interface{}
+	found := false
+	for _, name := range []string{"stop_sequences", "stop", "stopSequences"} {
+		if v, exists := bodyMap[name]; exists {
+			if !found {
+				value, found = v, true
+			}
+			delete(bodyMap, name)
+		}
+	}
+	// Gemini request transformers may already have placed them in generationConfig
+	if genConfig, ok := bodyMap["generationConfig"].(map[string]interface{}); ok {
+		if v, exists := genConfig["stopSequences"]; exists {
+			if !found {
+				value, found = v, true
+			}
+			delete(genConfig, "stopSequences")
+		}
+	}
+	if !found {
+		return
+	}
+
+	sequences := normalizeStopSequences(value)
+	if len(sequences) == 0 {
+		return
+	}
+	if field.max > 0 && len(sequences) > field.max {
+		utils.GetLogger().Warnf("Truncating %d stop sequences to %d: provider %s allows no more", len(sequences), field.max, provider)
+		sequences = sequences[:field.max]
+	}
+
+	bodyMap[field.name] = sequences
+}
+
+// stopSequencesField returns the stop sequences of a request in either the
+// OpenAI or the Anthropic field
+func stopSequencesField(reqMap map[string]interface{}) (interface{}, bool) {
+	if stop, ok := reqMap["stop_sequences"]; ok {
+		return stop, true
+	}
+	stop, ok := reqMap["stop"]
+	return stop, ok
+}
+
+// normalizeStopSequences converts a single stop string or an array of strings
+// into a list, dropping empty and non-string entries
+func normalizeStopSequences(value interface{}) []string {
+	var sequences []string
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			sequences = append(sequences, v)
+		}
+	case []string:
+		for _, seq := range v {
+			if seq != "" {
+				sequences = append(sequences, seq)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if seq, ok := item.(string); ok && seq != "" {
+				sequences = append(sequences, seq)
+			}
+		}
+	}
+	return sequences
 }
 
 // validateParameter checks if a parameter value is within valid range
@@ -205,7 +631,7 @@ func (t *ParametersTransformer) validateParameter(name string, value interface{}
 // wrapGeminiParameters wraps parameters in generationConfig for Gemini
 func (t *ParametersTransformer) wrapGeminiParameters(bodyMap map[string]interface{}) error {
 	// Parameters that should be in generationConfig
-	configParams := []string{"temperature", "topP", "topK", "maxOutputTokens"}
+	configParams := []string{"temperature", "topP", "topK", "maxOutputTokens", "stopSequences"}
 
 	// Check if generationConfig exists
 	genConfig, exists := bodyMap["generationConfig"].(map[string]interface{})
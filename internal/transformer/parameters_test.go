@@ -2,8 +2,14 @@ package transformer
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/orchestre-dev/ccproxy/internal/config"
 	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
 )
 
@@ -294,6 +300,587 @@ func TestParametersProcessParameters(t *testing.T) {
 	})
 }
 
+func TestParametersResponseFormat(t *testing.T) {
+	transformer := NewParametersTransformer()
+
+	tests := []struct {
+		provider string
+		kept     bool
+	}{
+		{"openai", true},
+		{"azure", true},
+		{"deepseek", true},
+		{"groq", true},
+		{"mistral", true},
+		{"openrouter", true},
+		{"xai", true},
+		{"anthropic", false},
+		{"ollama", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			bodyMap := map[string]interface{}{
+				"model":           "test-model",
+				"response_format": map[string]interface{}{"type": "json_object"},
+			}
+
+			err := transformer.processParameters(bodyMap, tt.provider)
+			testutil.AssertNoError(t, err)
+
+			_, hasResponseFormat := bodyMap["response_format"]
+			testutil.AssertEqual(t, tt.kept, hasResponseFormat)
+		})
+	}
+}
+
+func TestParametersResponseFormatJSONSchema(t *testing.T) {
+	transformer := NewParametersTransformer()
+
+	newBody := func() map[string]interface{} {
+		return map[string]interface{}{
+			"model": "test-model",
+			"response_format": map[string]interface{}{
+				"type": "json_schema",
+				"json_schema": map[string]interface{}{
+					"name":   "answer",
+					"schema": map[string]interface{}{"type": "object"},
+				},
+			},
+		}
+	}
+
+	t.Run("PassedThrough", func(t *testing.T) {
+		bodyMap := newBody()
+		testutil.AssertNoError(t, transformer.processParameters(bodyMap, "openai"))
+		format := bodyMap["response_format"].(map[string]interface{})
+		testutil.AssertEqual(t, "json_schema", format["type"])
+	})
+
+	t.Run("DowngradedToJSONMode", func(t *testing.T) {
+		bodyMap := newBody()
+		testutil.AssertNoError(t, transformer.processParameters(bodyMap, "deepseek"))
+		format := bodyMap["response_format"].(map[string]interface{})
+		testutil.AssertEqual(t, "json_object", format["type"])
+		_, hasSchema := format["json_schema"]
+		testutil.AssertFalse(t, hasSchema)
+	})
+
+	t.Run("OllamaFormat", func(t *testing.T) {
+		bodyMap := newBody()
+		testutil.AssertNoError(t, transformer.processParameters(bodyMap, "ollama"))
+		_, hasResponseFormat := bodyMap["response_format"]
+		testutil.AssertFalse(t, hasResponseFormat)
+		schema := bodyMap["format"].(map[string]interface{})
+		testutil.AssertEqual(t, "object", schema["type"])
+
+		bodyMap = map[string]interface{}{
+			"model":           "test-model",
+			"response_format": map[string]interface{}{"type": "json_object"},
+		}
+		testutil.AssertNoError(t, transformer.processParameters(bodyMap, "ollama"))
+		testutil.AssertEqual(t, "json", bodyMap["format"])
+	})
+
+	t.Run("Stripped", func(t *testing.T) {
+		bodyMap := newBody()
+		testutil.AssertNoError(t, transformer.processParameters(bodyMap, "anthropic"))
+		_, hasResponseFormat := bodyMap["response_format"]
+		testutil.AssertFalse(t, hasResponseFormat)
+	})
+}
+
+func TestParametersSeed(t *testing.T) {
+	transformer := NewParametersTransformer()
+
+	tests := []struct {
+		provider string
+		field    string
+	}{
+		{"openai", "seed"},
+		{"azure", "seed"},
+		{"groq", "seed"},
+		{"openrouter", "seed"},
+		{"xai", "seed"},
+		{"ollama", "seed"},
+		{"mistral", "random_seed"},
+		{"anthropic", ""},
+		{"gemini", ""},
+		{"vertex", ""},
+		{"deepseek", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			bodyMap := map[string]interface{}{"model": "test-model", "seed": float64(42)}
+
+			err := transformer.processParameters(bodyMap, tt.provider)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, tt.field != "", SupportsSeed(tt.provider))
+
+			if tt.field == "" {
+				_, hasSeed := bodyMap["seed"]
+				testutil.AssertFalse(t, hasSeed)
+				if genConfig, ok := bodyMap["generationConfig"].(map[string]interface{}); ok {
+					_, hasSeed = genConfig["seed"]
+					testutil.AssertFalse(t, hasSeed)
+				}
+				return
+			}
+			testutil.AssertEqual(t, float64(42), bodyMap[tt.field])
+			if tt.field != "seed" {
+				_, hasSeed := bodyMap["seed"]
+				testutil.AssertFalse(t, hasSeed)
+			}
+		})
+	}
+}
+
+func TestParametersLogprobs(t *testing.T) {
+	transformer := NewParametersTransformer()
+
+	tests := []struct {
+		provider  string
+		supported bool
+	}{
+		{"openai", true},
+		{"azure", true},
+		{"deepseek", true},
+		{"openrouter", true},
+		{"xai", true},
+		{"groq", false},
+		{"mistral", false},
+		{"ollama", false},
+		{"gemini", false},
+		{"vertex", false},
+		{"anthropic", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			bodyMap := map[string]interface{}{"model": "test-model", "logprobs": true, "top_logprobs": float64(5)}
+
+			err := transformer.processParameters(bodyMap, tt.provider)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, tt.supported, SupportsLogprobs(tt.provider))
+
+			if !tt.supported {
+				_, hasLogprobs := bodyMap["logprobs"]
+				_, hasTopLogprobs := bodyMap["top_logprobs"]
+				testutil.AssertFalse(t, hasLogprobs)
+				testutil.AssertFalse(t, hasTopLogprobs)
+				return
+			}
+			testutil.AssertEqual(t, true, bodyMap["logprobs"])
+			testutil.AssertEqual(t, float64(5), bodyMap["top_logprobs"])
+		})
+	}
+
+	t.Run("TopLogprobsEnablesLogprobs", func(t *testing.T) {
+		bodyMap := map[string]interface{}{"model": "test-model", "top_logprobs": float64(3)}
+		testutil.AssertNoError(t, transformer.processParameters(bodyMap, "openai"))
+		testutil.AssertEqual(t, true, bodyMap["logprobs"])
+	})
+
+	t.Run("LogprobsCoercedToBool", func(t *testing.T) {
+		bodyMap := map[string]interface{}{"model": "test-model", "logprobs": "true"}
+		testutil.AssertNoError(t, transformer.processParameters(bodyMap, "azure"))
+		testutil.AssertEqual(t, true, bodyMap["logprobs"])
+	})
+}
+
+func TestLogprobsChains(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+	testutil.AssertNoError(t, RegisterBuiltinTransformers(service))
+
+	newRequest := func() map[string]interface{} {
+		return map[string]interface{}{
+			"model":        "test-model",
+			"messages":     []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+			"logprobs":     true,
+			"top_logprobs": float64(2),
+		}
+	}
+
+	for _, provider := range []string{"openai", "anthropic", "gemini"} {
+		t.Run(provider, func(t *testing.T) {
+			result, err := service.GetChainForProvider(provider).TransformRequestIn(ctx, newRequest(), provider)
+			testutil.AssertNoError(t, err)
+
+			body := result
+			if reqConfig, ok := result.(*RequestConfig); ok {
+				body = reqConfig.Body
+			}
+			bodyMap := body.(map[string]interface{})
+			_, hasLogprobs := bodyMap["logprobs"]
+			_, hasTopLogprobs := bodyMap["top_logprobs"]
+			testutil.AssertEqual(t, SupportsLogprobs(provider), hasLogprobs)
+			testutil.AssertEqual(t, SupportsLogprobs(provider), hasTopLogprobs)
+		})
+	}
+
+	t.Run("ResponseUnchanged", func(t *testing.T) {
+		data := `{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},` +
+			`"logprobs":{"content":[{"token":"Hi","logprob":-0.01,"bytes":[72,105],"top_logprobs":[{"token":"Hi","logprob":-0.01,"bytes":[72,105]}]}]},"finish_reason":"stop"}]}`
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(data)),
+		}
+
+		result, err := service.GetChainForProvider("openai").TransformResponseOut(ctx, resp)
+		testutil.AssertNoError(t, err)
+		body, err := io.ReadAll(result.Body)
+		testutil.AssertNoError(t, err)
+
+		var want, got map[string]interface{}
+		testutil.AssertNoError(t, json.Unmarshal([]byte(data), &want))
+		testutil.AssertNoError(t, json.Unmarshal(body, &got))
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Expected response %s, got %s", data, body)
+		}
+	})
+}
+
+func TestParametersCacheControl(t *testing.T) {
+	transformer := NewParametersTransformer()
+
+	newBody := func() map[string]interface{} {
+		cacheControl := map[string]interface{}{"type": "ephemeral"}
+		return map[string]interface{}{
+			"model": "test-model",
+			"system": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Long context", "cache_control": cacheControl},
+			},
+			"messages": []interface{}{
+				map[string]interface{}{
+					"role": "user",
+					"content": []interface{}{
+						map[string]interface{}{"type": "text", "text": "Hello", "cache_control": cacheControl},
+						map[string]interface{}{
+							"type":    "tool_result",
+							"content": []interface{}{map[string]interface{}{"type": "text", "text": "42", "cache_control": cacheControl}},
+						},
+					},
+				},
+			},
+			"tools": []interface{}{
+				map[string]interface{}{"name": "lookup", "cache_control": cacheControl},
+			},
+		}
+	}
+
+	tests := []struct {
+		provider string
+		kept     bool
+	}{
+		{"anthropic", true},
+		{"openai", false},
+		{"groq", false},
+		{"ollama", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			bodyMap := newBody()
+			testutil.AssertTrue(t, HasCacheControl(bodyMap))
+
+			err := transformer.processParameters(bodyMap, tt.provider)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, tt.kept, HasCacheControl(bodyMap))
+		})
+	}
+
+	t.Run("no markers", func(t *testing.T) {
+		testutil.AssertFalse(t, HasCacheControl(map[string]interface{}{"system": "Short context"}))
+		testutil.AssertFalse(t, HasCacheControl("invalid"))
+	})
+}
+
+func TestParametersCompletionCount(t *testing.T) {
+	transformer := NewParametersTransformer()
+
+	tests := []struct {
+		provider string
+		kept     bool
+	}{
+		{"openai", true},
+		{"azure", true},
+		{"mistral", true},
+		{"xai", true},
+		{"groq", false},
+		{"deepseek", false},
+		{"openrouter", false},
+		{"ollama", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			bodyMap := map[string]interface{}{"model": "test-model", "n": float64(3)}
+
+			err := transformer.processParameters(bodyMap, tt.provider)
+			testutil.AssertNoError(t, err)
+
+			_, hasN := bodyMap["n"]
+			testutil.AssertEqual(t, tt.kept, hasN)
+			testutil.AssertEqual(t, tt.kept, SupportsMultipleCompletions(tt.provider))
+		})
+	}
+}
+
+func TestParametersStopSequences(t *testing.T) {
+	transformer := NewParametersTransformer()
+
+	tests := []struct {
+		name     string
+		provider string
+		field    string
+		value    interface{}
+		want     string
+	}{
+		{"SingleStringToOpenAI", "openai", "stop_sequences", "END", "END"},
+		{"ArrayToOpenAI", "openai", "stop_sequences", []interface{}{"a", "b"}, "a,b"},
+		{"OpenAITruncatedToFour", "openai", "stop", []interface{}{"a", "b", "c", "d", "e"}, "a,b,c,d"},
+		{"SingleStringToAnthropic", "anthropic", "stop", "END", "END"},
+		{"ArrayToAnthropicUnlimited", "anthropic", "stop", []interface{}{"a", "b", "c", "d", "e"}, "a,b,c,d,e"},
+		{"DeepSeekAllowsSixteen", "deepseek", "stop", []interface{}{"a", "b", "c", "d", "e"}, "a,b,c,d,e"},
+		{"UnknownProviderUsesStop", "ollama", "stop_sequences", []string{"x", "", "y"}, "x,y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodyMap := map[string]interface{}{"model": "test-model", tt.field: tt.value}
+
+			err := transformer.processParameters(bodyMap, tt.provider)
+			testutil.AssertNoError(t, err)
+
+			wantField := "stop"
+			if field, ok := stopSequenceFields[tt.provider]; ok {
+				wantField = field.name
+			}
+			if wantField != tt.field {
+				_, hasOriginal := bodyMap[tt.field]
+				testutil.AssertEqual(t, false, hasOriginal)
+			}
+			got, _ := bodyMap[wantField].([]string)
+			testutil.AssertEqual(t, tt.want, strings.Join(got, ","))
+		})
+	}
+
+	t.Run("GeminiGenerationConfig", func(t *testing.T) {
+		for _, value := range []interface{}{"END", []interface{}{"a", "b", "c", "d", "e", "f"}} {
+			bodyMap := map[string]interface{}{"model": "gemini-pro", "stop": value, "temperature": 0.5}
+
+			err := transformer.processParameters(bodyMap, "gemini")
+			testutil.AssertNoError(t, err)
+
+			_, hasStop := bodyMap["stop"]
+			testutil.AssertEqual(t, false, hasStop)
+			genConfig := bodyMap["generationConfig"].(map[string]interface{})
+			got := genConfig["stopSequences"].([]string)
+			if _, isString := value.(string); isString {
+				testutil.AssertEqual(t, "END", strings.Join(got, ","))
+			} else {
+				testutil.AssertEqual(t, "a,b,c,d,e", strings.Join(got, ","))
+			}
+		}
+	})
+
+	t.Run("GeminiTransformerOutput", func(t *testing.T) {
+		request, err := NewGeminiTransformer().TransformRequestIn(context.Background(), map[string]interface{}{
+			"messages":       []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+			"stop_sequences": "END",
+		}, "gemini")
+		testutil.AssertNoError(t, err)
+
+		bodyMap := request.(map[string]interface{})
+		testutil.AssertNoError(t, transformer.processParameters(bodyMap, "gemini"))
+		genConfig := bodyMap["generationConfig"].(map[string]interface{})
+		testutil.AssertEqual(t, "END", strings.Join(genConfig["stopSequences"].([]string), ","))
+	})
+
+	t.Run("EmptyStopRemoved", func(t *testing.T) {
+		bodyMap := map[string]interface{}{"model": "test-model", "stop": ""}
+
+		err := transformer.processParameters(bodyMap, "openai")
+		testutil.AssertNoError(t, err)
+
+		_, hasStop := bodyMap["stop"]
+		testutil.AssertEqual(t, false, hasStop)
+	})
+}
+
+func TestParametersUnsupportedParams(t *testing.T) {
+	t.Run("GeminiStripsPenaltiesAndLogitBias", func(t *testing.T) {
+		transformer := NewParametersTransformer()
+		bodyMap := map[string]interface{}{
+			"model":             "gemini-pro",
+			"temperature":       0.7,
+			"frequency_penalty": 0.5,
+			"presence_penalty":  0.5,
+			"logit_bias":        map[string]interface{}{"50256": -100},
+		}
+
+		err := transformer.processParameters(bodyMap, "gemini")
+		testutil.AssertNoError(t, err)
+
+		for _, param := range []string{"frequency_penalty", "presence_penalty", "logit_bias"} {
+			_, exists := bodyMap[param]
+			testutil.AssertEqual(t, false, exists, param)
+		}
+		genConfig := bodyMap["generationConfig"].(map[string]interface{})
+		testutil.AssertEqual(t, 0.7, genConfig["temperature"])
+	})
+
+	t.Run("StrippedBeforeValidation", func(t *testing.T) {
+		transformer := NewParametersTransformer()
+		transformer.SetParameterLimit("vertex", "frequency_penalty", -2, 2)
+		bodyMap := map[string]interface{}{"model": "gemini-pro", "frequency_penalty": 5.0}
+
+		err := transformer.processParameters(bodyMap, "vertex")
+		testutil.AssertNoError(t, err)
+		_, exists := bodyMap["frequency_penalty"]
+		testutil.AssertEqual(t, false, exists)
+	})
+
+	t.Run("OpenAIKeepsParams", func(t *testing.T) {
+		transformer := NewParametersTransformer()
+		bodyMap := map[string]interface{}{"model": "gpt-4", "frequency_penalty": 0.5, "logit_bias": map[string]interface{}{}}
+
+		err := transformer.processParameters(bodyMap, "openai")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, 0.5, bodyMap["frequency_penalty"])
+		_, exists := bodyMap["logit_bias"]
+		testutil.AssertEqual(t, true, exists)
+	})
+
+	t.Run("ConfiguredParams", func(t *testing.T) {
+		service := NewService()
+		transformer := NewParametersTransformer()
+		testutil.AssertNoError(t, service.Register(transformer))
+		service.ConfigureUnsupportedParams([]config.Provider{
+			{Name: "ollama", UnsupportedParams: []string{"seed", "user"}},
+		})
+
+		bodyMap := map[string]interface{}{"model": "llama3", "seed": 42.0, "user": "u1", "temperature": 0.2}
+		err := transformer.processParameters(bodyMap, "ollama")
+		testutil.AssertNoError(t, err)
+		_, hasSeed := bodyMap["seed"]
+		_, hasUser := bodyMap["user"]
+		testutil.AssertEqual(t, false, hasSeed)
+		testutil.AssertEqual(t, false, hasUser)
+		testutil.AssertEqual(t, 0.2, bodyMap["temperature"])
+
+		// Clearing the list restores pass-through
+		service.ConfigureUnsupportedParams([]config.Provider{{Name: "ollama"}})
+		bodyMap = map[string]interface{}{"model": "llama3", "seed": 42.0}
+		testutil.AssertNoError(t, transformer.processParameters(bodyMap, "ollama"))
+		testutil.AssertEqual(t, 42.0, bodyMap["seed"])
+	})
+}
+
+func TestParametersUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		provider     string
+		body         map[string]interface{}
+		wantUser     interface{}
+		wantMetadata bool
+	}{
+		{"OpenAIKeepsUser", "openai", map[string]interface{}{"model": "gpt-4", "user": "u1"}, "u1", false},
+		{"OpenAIFromMetadata", "openai", map[string]interface{}{
+			"model": "gpt-4", "metadata": map[string]interface{}{"user_id": "u2"},
+		}, "u2", true},
+		{"UserWinsOverMetadata", "groq", map[string]interface{}{
+			"model": "llama3", "user": "u1", "metadata": map[string]interface{}{"user_id": "u2"},
+		}, "u1", false},
+		{"GeminiStripsUser", "gemini", map[string]interface{}{"model": "gemini-pro", "user": "u1"}, nil, false},
+		{"MistralStripsUserAndMetadata", "mistral", map[string]interface{}{
+			"model": "mistral-large", "user": "u1", "metadata": map[string]interface{}{"user_id": "u2"},
+		}, nil, false},
+		{"NoUser", "openai", map[string]interface{}{"model": "gpt-4"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformer := NewParametersTransformer()
+			testutil.AssertNoError(t, transformer.processParameters(tt.body, tt.provider))
+			testutil.AssertEqual(t, tt.wantUser, tt.body["user"])
+			// Metadata passes through unless the provider rejects it
+			_, hasMetadata := tt.body["metadata"]
+			testutil.AssertEqual(t, tt.wantMetadata, hasMetadata)
+		})
+	}
+
+	t.Run("AnthropicUserToMetadata", func(t *testing.T) {
+		body := map[string]interface{}{"model": "claude-3-opus", "user": "u1"}
+		testutil.AssertNoError(t, NewParametersTransformer().processParameters(body, "anthropic"))
+		testutil.AssertEqual(t, "u1", body["metadata"].(map[string]interface{})["user_id"])
+		_, hasUser := body["user"]
+		testutil.AssertFalse(t, hasUser)
+	})
+
+	t.Run("AnthropicKeepsOnlyUserID", func(t *testing.T) {
+		body := map[string]interface{}{
+			"model":    "claude-3-opus",
+			"metadata": map[string]interface{}{"user_id": "u2", "session": "s1"},
+		}
+		testutil.AssertNoError(t, NewParametersTransformer().processParameters(body, "anthropic"))
+		metadata := body["metadata"].(map[string]interface{})
+		testutil.AssertEqual(t, 1, len(metadata))
+		testutil.AssertEqual(t, "u2", metadata["user_id"])
+	})
+
+	t.Run("AnthropicDropsEmptyMetadata", func(t *testing.T) {
+		body := map[string]interface{}{"model": "claude-3-opus", "metadata": map[string]interface{}{}}
+		testutil.AssertNoError(t, NewParametersTransformer().processParameters(body, "anthropic"))
+		_, hasMetadata := body["metadata"]
+		testutil.AssertFalse(t, hasMetadata)
+	})
+}
+
+func TestUserChains(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+	testutil.AssertNoError(t, RegisterBuiltinTransformers(service))
+
+	tests := []struct {
+		provider     string
+		wantUser     interface{}
+		wantMetadata interface{}
+	}{
+		{"openai", "u1", map[string]interface{}{"trace": "t1"}},
+		{"anthropic", nil, map[string]interface{}{"user_id": "u1"}},
+		{"groq", "u1", nil},
+		{"gemini", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			request := map[string]interface{}{
+				"model":    "test-model",
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+				"user":     "u1",
+				"metadata": map[string]interface{}{"trace": "t1"},
+			}
+			result, err := service.GetChainForProvider(tt.provider).TransformRequestIn(ctx, request, tt.provider)
+			testutil.AssertNoError(t, err)
+
+			body := result
+			if reqConfig, ok := result.(*RequestConfig); ok {
+				body = reqConfig.Body
+			}
+			bodyMap := body.(map[string]interface{})
+			testutil.AssertEqual(t, tt.wantUser, bodyMap["user"])
+
+			if metadata := bodyMap["metadata"]; !reflect.DeepEqual(tt.wantMetadata, metadata) {
+				t.Errorf("Expected metadata %v, got %v", tt.wantMetadata, metadata)
+			}
+		})
+	}
+}
+
 func TestParametersValidateParameter(t *testing.T) {
 	cfg := testutil.SetupTest(t)
 	_ = cfg
@@ -41,6 +41,26 @@ func RegisterBuiltinTransformers(service *Service) error {
 		return err
 	}
 
+	// Register Vertex AI transformer
+	if err := service.Register(NewVertexTransformer()); err != nil {
+		return err
+	}
+
+	// Register Anthropic stream event transformer
+	if err := service.Register(NewAnthropicStreamTransformer()); err != nil {
+		return err
+	}
+
+	// Register Anthropic error transformer
+	if err := service.Register(NewAnthropicErrorTransformer()); err != nil {
+		return err
+	}
+
+	// Register image content transformer
+	if err := service.Register(NewImageTransformer()); err != nil {
+		return err
+	}
+
 	// Register OpenRouter transformer
 	if err := service.Register(NewOpenRouterTransformer()); err != nil {
 		return err
@@ -71,5 +91,25 @@ func RegisterBuiltinTransformers(service *Service) error {
 		return err
 	}
 
+	// Register Thinking transformer
+	if err := service.Register(NewThinkingTransformer()); err != nil {
+		return err
+	}
+
+	// Register ToolLimits transformer
+	if err := service.Register(NewToolLimitsTransformer()); err != nil {
+		return err
+	}
+
+	// Register Rename transformer
+	if err := service.Register(NewRenameTransformer()); err != nil {
+		return err
+	}
+
+	// Register MergeMessages transformer
+	if err := service.Register(NewMergeMessagesTransformer()); err != nil {
+		return err
+	}
+
 	return nil
 }
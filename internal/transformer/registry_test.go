@@ -27,6 +27,10 @@ func TestGetRegistry(t *testing.T) {
 			"anthropic",
 			"deepseek",
 			"gemini",
+			"vertex",
+			"anthropic-stream",
+			"anthropic-error",
+			"image",
 			"openrouter",
 			"tooluse",
 			"tool",
@@ -102,7 +106,7 @@ func TestRegisterBuiltinTransformers(t *testing.T) {
 		}{
 			{"anthropic", "/v1/messages"},
 			{"openai", "/v1/chat/completions"},
-			{"gemini", "/v1beta/models/:modelAndAction"},
+			{"gemini", "/:version/models/:model:generateContent"},
 			{"deepseek", "/v1/chat/completions"},
 			{"openrouter", "/api/v1/chat/completions"},
 			{"maxtoken", ""},
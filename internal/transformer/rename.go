@@ -0,0 +1,81 @@
+package transformer
+
+import (
+	"context"
+	"sync"
+)
+
+// RenameTransformer renames request body fields using per-provider maps
+type RenameTransformer struct {
+	*BaseTransformer
+	mu      sync.RWMutex
+	renames map[string]map[string]string // provider -> source field -> target field
+}
+
+// NewRenameTransformer creates a new Rename transformer
+func NewRenameTransformer() *RenameTransformer {
+	return &RenameTransformer{
+		BaseTransformer: NewBaseTransformer("rename", ""),
+		renames:         make(map[string]map[string]string),
+	}
+}
+
+// SetProviderRenames sets the field rename map for a provider
+func (t *RenameTransformer) SetProviderRenames(provider string, renames map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(renames) == 0 {
+		delete(t.renames, provider)
+		return
+	}
+
+	copied := make(map[string]string, len(renames))
+	for from, to := range renames {
+		copied[from] = to
+	}
+	t.renames[provider] = copied
+}
+
+// TransformRequestIn renames configured fields in the request body
+func (t *RenameTransformer) TransformRequestIn(ctx context.Context, request interface{}, provider string) (interface{}, error) {
+	t.mu.RLock()
+	renames := t.renames[provider]
+	t.mu.RUnlock()
+
+	if len(renames) == 0 {
+		return request, nil
+	}
+
+	// Handle RequestConfig
+	if reqConfig, ok := request.(*RequestConfig); ok {
+		if bodyMap, ok := reqConfig.Body.(map[string]interface{}); ok {
+			applyRenames(bodyMap, renames)
+		}
+		return reqConfig, nil
+	}
+
+	if bodyMap, ok := request.(map[string]interface{}); ok {
+		applyRenames(bodyMap, renames)
+	}
+	return request, nil
+}
+
+// applyRenames moves fields to their new names. All renames read the original
+// body, so swaps and chains such as a->b, b->c behave predictably.
+func applyRenames(bodyMap map[string]interface{}, renames map[string]string) {
+	moved := make(map[string]interface{}, len(renames))
+	for from, to := range renames {
+		if value, exists := bodyMap[from]; exists {
+			moved[to] = value
+		}
+	}
+
+	for from := range renames {
+		delete(bodyMap, from)
+	}
+
+	for to, value := range moved {
+		bodyMap[to] = value
+	}
+}
@@ -0,0 +1,123 @@
+package transformer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+)
+
+func TestRenameTransformer_TransformRequestIn(t *testing.T) {
+	transformer := NewRenameTransformer()
+	ctx := context.Background()
+
+	if transformer.GetName() != "rename" {
+		t.Errorf("Expected name 'rename', got %s", transformer.GetName())
+	}
+
+	transformer.SetProviderRenames("openai", map[string]string{
+		"max_tokens": "max_completion_tokens",
+		"stop":       "stop_sequences",
+		"user_tag":   "user",
+	})
+
+	t.Run("MultipleRenames", func(t *testing.T) {
+		request := map[string]interface{}{
+			"model":      "o1",
+			"max_tokens": 1024,
+			"stop":       []interface{}{"END"},
+		}
+
+		result, err := transformer.TransformRequestIn(ctx, request, "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		bodyMap := result.(map[string]interface{})
+		if bodyMap["max_completion_tokens"] != 1024 {
+			t.Errorf("Expected max_completion_tokens 1024, got %v", bodyMap["max_completion_tokens"])
+		}
+		if _, exists := bodyMap["max_tokens"]; exists {
+			t.Error("Expected max_tokens to be removed")
+		}
+		if stops, ok := bodyMap["stop_sequences"].([]interface{}); !ok || len(stops) != 1 || stops[0] != "END" {
+			t.Errorf("Expected stop_sequences [END], got %v", bodyMap["stop_sequences"])
+		}
+		if _, exists := bodyMap["stop"]; exists {
+			t.Error("Expected stop to be removed")
+		}
+		if bodyMap["model"] != "o1" {
+			t.Errorf("Expected untouched model, got %v", bodyMap["model"])
+		}
+	})
+
+	t.Run("UnknownSourceFieldsIgnored", func(t *testing.T) {
+		request := map[string]interface{}{"model": "o1", "temperature": 0.5}
+
+		result, _ := transformer.TransformRequestIn(ctx, request, "openai")
+		bodyMap := result.(map[string]interface{})
+		if len(bodyMap) != 2 {
+			t.Errorf("Expected body to be unchanged, got %v", bodyMap)
+		}
+		if _, exists := bodyMap["user"]; exists {
+			t.Error("Expected no target field for a missing source")
+		}
+	})
+
+	t.Run("OtherProviderUnchanged", func(t *testing.T) {
+		request := map[string]interface{}{"max_tokens": 100}
+
+		result, _ := transformer.TransformRequestIn(ctx, request, "groq")
+		if result.(map[string]interface{})["max_tokens"] != 100 {
+			t.Error("Expected renames to apply only to the configured provider")
+		}
+	})
+
+	t.Run("SwapFields", func(t *testing.T) {
+		transformer.SetProviderRenames("swap", map[string]string{"a": "b", "b": "a"})
+
+		result, _ := transformer.TransformRequestIn(ctx, map[string]interface{}{"a": 1, "b": 2}, "swap")
+		bodyMap := result.(map[string]interface{})
+		if bodyMap["a"] != 2 || bodyMap["b"] != 1 {
+			t.Errorf("Expected fields to be swapped, got %v", bodyMap)
+		}
+	})
+
+	t.Run("RequestConfig", func(t *testing.T) {
+		reqConfig := &RequestConfig{Body: map[string]interface{}{"stop": "x"}}
+
+		result, _ := transformer.TransformRequestIn(ctx, reqConfig, "openai")
+		if result.(*RequestConfig).Body.(map[string]interface{})["stop_sequences"] != "x" {
+			t.Error("Expected rename in RequestConfig body")
+		}
+	})
+}
+
+func TestService_ConfigureFieldRenames(t *testing.T) {
+	service := NewService()
+	if err := RegisterBuiltinTransformers(service); err != nil {
+		t.Fatalf("Failed to register transformers: %v", err)
+	}
+
+	service.ConfigureFieldRenames([]config.Provider{
+		{Name: "openai", FieldRenames: map[string]string{"max_tokens": "max_completion_tokens"}},
+	})
+
+	chain := service.GetChainForProvider("openai")
+	result, err := chain.TransformRequestIn(context.Background(), map[string]interface{}{
+		"model":      "o1",
+		"messages":   []interface{}{},
+		"max_tokens": 200,
+	}, "openai")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	bodyMap := result.(map[string]interface{})
+	if _, exists := bodyMap["max_tokens"]; exists {
+		t.Error("Expected max_tokens to be renamed by the provider chain")
+	}
+	if _, exists := bodyMap["max_completion_tokens"]; !exists {
+		t.Errorf("Expected max_completion_tokens in body, got %v", bodyMap)
+	}
+}
@@ -0,0 +1,16 @@
+package transformer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// stableResponseID returns id when set, otherwise derives one from the
+// response data so repeated transformations of the same response agree
+func stableResponseID(id, prefix string, data []byte) string {
+	if id != "" {
+		return id
+	}
+	sum := sha256.Sum256(data)
+	return prefix + hex.EncodeToString(sum[:12])
+}
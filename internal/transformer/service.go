@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/orchestre-dev/ccproxy/internal/config"
@@ -15,23 +16,44 @@ import (
 // cacheEntry represents a cached transformer chain with last access time
 type cacheEntry struct {
 	chain      *TransformerChain
-	lastAccess time.Time
+	lastAccess atomic.Int64 // Unix nanoseconds, updated under the read lock
+}
+
+// newCacheEntry creates a cache entry accessed now
+func newCacheEntry(chain *TransformerChain) *cacheEntry {
+	entry := &cacheEntry{chain: chain}
+	entry.touch()
+	return entry
+}
+
+// touch records an access to the entry
+func (e *cacheEntry) touch() {
+	e.lastAccess.Store(time.Now().UnixNano())
+}
+
+// accessed returns when the entry was last accessed
+func (e *cacheEntry) accessed() time.Time {
+	return time.Unix(0, e.lastAccess.Load())
 }
 
 // Service manages transformers and their lifecycle
 type Service struct {
-	transformers map[string]Transformer
-	chains       map[string]*cacheEntry
-	maxCacheSize int
-	mu           sync.RWMutex
+	transformers  map[string]Transformer
+	chains        map[string]*cacheEntry
+	providerSteps map[string][]Transformer // Configured transformers, added to the provider's default chain
+	mergeMessages map[string]bool          // Providers whose default chain merges repeated roles
+	maxCacheSize  int
+	mu            sync.RWMutex
 }
 
 // NewService creates a new transformer service
 func NewService() *Service {
 	return &Service{
-		transformers: make(map[string]Transformer),
-		chains:       make(map[string]*cacheEntry),
-		maxCacheSize: 100, // Limit to 100 cached chains
+		transformers:  make(map[string]Transformer),
+		chains:        make(map[string]*cacheEntry),
+		providerSteps: make(map[string][]Transformer),
+		mergeMessages: make(map[string]bool),
+		maxCacheSize:  100, // Limit to 100 cached chains
 	}
 }
 
@@ -83,19 +105,129 @@ func (s *Service) CreateChain(configs []config.TransformerConfig) (*TransformerC
 	chain := NewTransformerChain()
 
 	for _, cfg := range configs {
-		transformer, err := s.Get(cfg.Name)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get transformer %s: %w", cfg.Name, err)
-		}
+		var transformer Transformer
+		if cfg.Name == ExecTransformerName {
+			// External commands only run when a provider lists them explicitly
+			execTransformer, err := NewExecTransformer(cfg.Config)
+			if err != nil {
+				return nil, err
+			}
+			transformer = execTransformer
+		} else {
+			var err error
+			transformer, err = s.Get(cfg.Name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get transformer %s: %w", cfg.Name, err)
+			}
 
-		// TODO: Apply transformer-specific configuration from cfg.Config
+			// TODO: Apply transformer-specific configuration from cfg.Config
+		}
 
+		if cfg.BestEffort {
+			transformer = NewBestEffortTransformer(transformer)
+		}
 		chain.Add(transformer)
 	}
 
 	return chain, nil
 }
 
+// ConfigureFieldRenames loads each provider's field rename map into the
+// registered rename transformer
+func (s *Service) ConfigureFieldRenames(providers []config.Provider) {
+	s.mu.RLock()
+	renameTransformer, ok := s.transformers["rename"].(*RenameTransformer)
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	for _, provider := range providers {
+		renameTransformer.SetProviderRenames(provider.Name, provider.FieldRenames)
+	}
+}
+
+// ConfigureMessageMerging adds the mergemessages transformer to the default
+// chain of each provider that opts in
+func (s *Service) ConfigureMessageMerging(providers []config.Provider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merge := make(map[string]bool)
+	for _, provider := range providers {
+		if provider.MergeConsecutiveMessages {
+			merge[provider.Name] = true
+		}
+	}
+	for name := range s.mergeMessages {
+		delete(s.chains, fmt.Sprintf("provider:%s", name))
+	}
+	for name := range merge {
+		delete(s.chains, fmt.Sprintf("provider:%s", name))
+	}
+	s.mergeMessages = merge
+}
+
+// ConfigureUnsupportedParams loads each provider's unsupported parameter
+// list into the registered parameters transformer
+func (s *Service) ConfigureUnsupportedParams(providers []config.Provider) {
+	s.mu.RLock()
+	parametersTransformer, ok := s.transformers["parameters"].(*ParametersTransformer)
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	for _, provider := range providers {
+		parametersTransformer.SetUnsupportedParams(provider.Name, provider.UnsupportedParams)
+	}
+}
+
+// ConfigureToolLimits loads each provider's tool limits into the registered
+// toollimits transformer
+func (s *Service) ConfigureToolLimits(providers []config.Provider) {
+	s.mu.RLock()
+	toolLimitsTransformer, ok := s.transformers["toollimits"].(*ToolLimitsTransformer)
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	for _, provider := range providers {
+		toolLimitsTransformer.SetToolLimits(provider.Name, ToolLimitsFor(&provider))
+	}
+}
+
+// ConfigureProviderChains loads the transformers each provider lists. They
+// run after the provider's default chain, before field renames, and built-in
+// transformers the default chain already has are not added twice.
+func (s *Service) ConfigureProviderChains(providers []config.Provider) error {
+	steps := make(map[string][]Transformer)
+	for _, provider := range providers {
+		if len(provider.Transformers) == 0 {
+			continue
+		}
+
+		chain, err := s.CreateChain(provider.Transformers)
+		if err != nil {
+			return fmt.Errorf("provider %s: %w", provider.Name, err)
+		}
+		steps[provider.Name] = chain.transformers
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.providerSteps {
+		delete(s.chains, fmt.Sprintf("provider:%s", name))
+	}
+	for name := range steps {
+		delete(s.chains, fmt.Sprintf("provider:%s", name))
+	}
+	s.providerSteps = steps
+	return nil
+}
+
 // CreateChainFromNames creates a transformer chain from transformer names
 func (s *Service) CreateChainFromNames(names []string) (*TransformerChain, error) {
 	chain := NewTransformerChain()
@@ -123,8 +255,8 @@ func (s *Service) evictLRU() {
 	oldestTime := time.Now()
 
 	for key, entry := range s.chains {
-		if entry.lastAccess.Before(oldestTime) {
-			oldestTime = entry.lastAccess
+		if accessed := entry.accessed(); accessed.Before(oldestTime) {
+			oldestTime = accessed
 			oldestKey = key
 		}
 	}
@@ -141,7 +273,7 @@ func (s *Service) GetChainForProvider(providerName string) *TransformerChain {
 	entry, exists := s.chains[chainKey]
 	if exists {
 		// Update last access time
-		entry.lastAccess = time.Now()
+		entry.touch()
 		chain := entry.chain
 		s.mu.RUnlock()
 		return chain
@@ -156,19 +288,40 @@ func (s *Service) GetChainForProvider(providerName string) *TransformerChain {
 
 	// Double-check in case another goroutine created it
 	if existingEntry, exists := s.chains[chainKey]; exists {
-		existingEntry.lastAccess = time.Now()
+		existingEntry.touch()
 		return existingEntry.chain
 	}
 
-	// Create default chain with provider-specific transformer and common ones
+	// Evict LRU entries if cache is full
+	s.evictLRU()
+
+	// Cache the new chain
+	chain := s.buildChain(providerName, s.providerSteps[providerName])
+	s.chains[chainKey] = newCacheEntry(chain)
+	return chain
+}
+
+// buildChain creates a provider's default chain with extra transformers added
+// before field renames. The caller holds s.mu.
+func (s *Service) buildChain(providerName string, extra []Transformer) *TransformerChain {
 	chain := NewTransformerChain()
 
+	// Merge repeated roles first, while messages are still in the client's format
+	if mergeTransformer := s.transformers["mergemessages"]; mergeTransformer != nil && s.mergeMessages[providerName] {
+		chain.Add(mergeTransformer)
+	}
+
 	// Add provider-specific transformer if it exists
 	providerTransformer := s.transformers[providerName]
 	if providerTransformer != nil {
 		chain.Add(providerTransformer)
 	}
 
+	// Translate image content for the provider
+	if imageTransformer := s.transformers["image"]; imageTransformer != nil {
+		chain.Add(imageTransformer)
+	}
+
 	// Add common transformers
 	if maxTokenTransformer := s.transformers["maxtoken"]; maxTokenTransformer != nil {
 		chain.Add(maxTokenTransformer)
@@ -178,23 +331,38 @@ func (s *Service) GetChainForProvider(providerName string) *TransformerChain {
 		chain.Add(parametersTransformer)
 	}
 
+	if thinkingTransformer := s.transformers["thinking"]; thinkingTransformer != nil {
+		chain.Add(thinkingTransformer)
+	}
+
 	// Add tool transformer if needed
 	if toolTransformer := s.transformers["tool"]; toolTransformer != nil {
 		chain.Add(toolTransformer)
 	}
 
-	// Evict LRU entries if cache is full
-	s.evictLRU()
+	// Then the provider's configured transformers, skipping those already in
+	// the chain
+	for _, transformer := range extra {
+		if transformer.GetName() == ExecTransformerName || !chain.Has(transformer.GetName()) {
+			chain.Add(transformer)
+		}
+	}
 
-	// Cache the new chain
-	s.chains[chainKey] = &cacheEntry{
-		chain:      chain,
-		lastAccess: time.Now(),
+	// Tool limits count every tool added by the steps above
+	if toolLimitsTransformer := s.transformers["toollimits"]; toolLimitsTransformer != nil {
+		chain.Add(toolLimitsTransformer)
+	}
+
+	// Field renames run last so they see the final request body
+	if renameTransformer := s.transformers["rename"]; renameTransformer != nil {
+		chain.Add(renameTransformer)
 	}
+
 	return chain
 }
 
-// GetOrCreateChain gets or creates a transformer chain for a provider
+// GetOrCreateChain gets or creates a transformer chain for a provider, with
+// the transformers it lists added to its default chain
 func (s *Service) GetOrCreateChain(provider *config.Provider) (*TransformerChain, error) {
 	// First check if chain exists with read lock
 	s.mu.RLock()
@@ -202,15 +370,15 @@ func (s *Service) GetOrCreateChain(provider *config.Provider) (*TransformerChain
 	entry, exists := s.chains[chainKey]
 	if exists {
 		// Update last access time
-		entry.lastAccess = time.Now()
+		entry.touch()
 		chain := entry.chain
 		s.mu.RUnlock()
 		return chain, nil
 	}
 	s.mu.RUnlock()
 
-	// Create new chain (without holding lock)
-	chain, err := s.CreateChain(provider.Transformers)
+	// Create the configured transformers (without holding lock)
+	configured, err := s.CreateChain(provider.Transformers)
 	if err != nil {
 		return nil, err
 	}
@@ -221,7 +389,7 @@ func (s *Service) GetOrCreateChain(provider *config.Provider) (*TransformerChain
 
 	// Double-check in case another goroutine created it
 	if existingEntry, exists := s.chains[chainKey]; exists {
-		existingEntry.lastAccess = time.Now()
+		existingEntry.touch()
 		return existingEntry.chain, nil
 	}
 
@@ -229,10 +397,8 @@ func (s *Service) GetOrCreateChain(provider *config.Provider) (*TransformerChain
 	s.evictLRU()
 
 	// Cache the new chain
-	s.chains[chainKey] = &cacheEntry{
-		chain:      chain,
-		lastAccess: time.Now(),
-	}
+	chain := s.buildChain(provider.Name, configured.transformers)
+	s.chains[chainKey] = newCacheEntry(chain)
 	return chain, nil
 }
 
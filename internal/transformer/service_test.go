@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/orchestre-dev/ccproxy/internal/config"
@@ -315,6 +316,53 @@ func TestService_GetChainForProvider(t *testing.T) {
 	})
 }
 
+func TestService_ConfigureProviderChains(t *testing.T) {
+	chainNames := func(chain *TransformerChain) string {
+		names := make([]string, len(chain.transformers))
+		for i, transformer := range chain.transformers {
+			names[i] = transformer.GetName()
+		}
+		return strings.Join(names, ",")
+	}
+
+	t.Run("ConfiguredTransformersExtendDefault", func(t *testing.T) {
+		service := NewService()
+		testutil.AssertNoError(t, RegisterBuiltinTransformers(service))
+
+		// Prime the default chain cache before configuring
+		testutil.AssertEqual(t, "openai,image,maxtoken,parameters,thinking,tool,toollimits,rename", chainNames(service.GetChainForProvider("openai")))
+
+		err := service.ConfigureProviderChains([]config.Provider{
+			{Name: "openai", Transformers: []config.TransformerConfig{{Name: "tooluse"}, {Name: "maxtoken"}}},
+			{Name: "gemini"},
+		})
+		testutil.AssertNoError(t, err)
+
+		testutil.AssertEqual(t, "openai,image,maxtoken,parameters,thinking,tool,tooluse,toollimits,rename", chainNames(service.GetChainForProvider("openai")))
+		testutil.AssertEqual(t, "gemini,image,maxtoken,parameters,thinking,tool,toollimits,rename", chainNames(service.GetChainForProvider("gemini")))
+
+		// Message merging configured later still applies to the configured chain
+		service.ConfigureMessageMerging([]config.Provider{{Name: "openai", MergeConsecutiveMessages: true}})
+		testutil.AssertEqual(t, "mergemessages,openai,image,maxtoken,parameters,thinking,tool,tooluse,toollimits,rename", chainNames(service.GetChainForProvider("openai")))
+
+		// Reconfiguring without a list restores the default chain
+		testutil.AssertNoError(t, service.ConfigureProviderChains(nil))
+		testutil.AssertEqual(t, "mergemessages,openai,image,maxtoken,parameters,thinking,tool,toollimits,rename", chainNames(service.GetChainForProvider("openai")))
+	})
+
+	t.Run("UnknownTransformer", func(t *testing.T) {
+		service := NewService()
+		testutil.AssertNoError(t, RegisterBuiltinTransformers(service))
+
+		err := service.ConfigureProviderChains([]config.Provider{
+			{Name: "openai", Transformers: []config.TransformerConfig{{Name: "maxtokens"}}},
+		})
+		testutil.AssertError(t, err)
+		testutil.AssertContains(t, err.Error(), "provider openai")
+		testutil.AssertContains(t, err.Error(), "maxtokens")
+	})
+}
+
 func TestService_ConcurrentAccess(t *testing.T) {
 	service := NewService()
 
@@ -0,0 +1,178 @@
+package transformer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// StreamFormat identifies how an upstream streaming response is framed
+type StreamFormat string
+
+const (
+	// StreamFormatSSE is Server-Sent Events (text/event-stream)
+	StreamFormatSSE StreamFormat = "sse"
+	// StreamFormatNDJSON is newline-delimited JSON objects, as sent by Ollama
+	StreamFormatNDJSON StreamFormat = "ndjson"
+	// StreamFormatJSONArray is a single JSON array streamed element by element
+	StreamFormatJSONArray StreamFormat = "json_array"
+)
+
+// ndjsonMediaTypes are the Content-Types used for newline-delimited JSON
+var ndjsonMediaTypes = map[string]bool{
+	"application/x-ndjson":    true,
+	"application/ndjson":      true,
+	"application/jsonl":       true,
+	"application/x-jsonlines": true,
+}
+
+// NewStreamReader detects the format of a streaming response and returns a
+// reader that yields each chunk as an SSEEvent, whatever the upstream framing
+func NewStreamReader(resp *http.Response) (StreamReader, StreamFormat) {
+	buffered := bufio.NewReader(resp.Body)
+	body := &bufferedBody{Reader: buffered, Closer: resp.Body}
+
+	format := DetectStreamFormat(resp.Header.Get("Content-Type"), buffered)
+	switch format {
+	case StreamFormatNDJSON:
+		return NewNDJSONReader(body), format
+	case StreamFormatJSONArray:
+		return NewJSONArrayReader(body), format
+	default:
+		return NewSSEReader(body), format
+	}
+}
+
+// DetectStreamFormat picks a stream format from the Content-Type. When the
+// type is ambiguous it sniffs the first non-whitespace byte of the body,
+// which is left in the buffer for the chosen reader.
+func DetectStreamFormat(contentType string, body *bufio.Reader) StreamFormat {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		mediaType = strings.ToLower(mediaType)
+		if mediaType == "text/event-stream" {
+			return StreamFormatSSE
+		}
+		if ndjsonMediaTypes[mediaType] {
+			return StreamFormatNDJSON
+		}
+	}
+
+	// Fall back to the framing of the body itself
+	for {
+		next, err := body.Peek(1)
+		if err != nil {
+			return StreamFormatSSE
+		}
+		switch next[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = body.ReadByte() // Safe to ignore: byte was just peeked
+			continue
+		case '[':
+			return StreamFormatJSONArray
+		case '{':
+			return StreamFormatNDJSON
+		default:
+			return StreamFormatSSE
+		}
+	}
+}
+
+// bufferedBody closes the original body while reading through its buffer
+type bufferedBody struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// JSONStreamReader implements StreamReader for streams of JSON values,
+// either newline-delimited or wrapped in a single array
+type JSONStreamReader struct {
+	decoder *json.Decoder
+	closer  io.Closer
+	array   bool
+	started bool
+	mu      sync.Mutex // serializes reads
+	closeMu sync.Mutex // guards closed so Close never waits on a blocked read
+	closed  bool
+}
+
+// NewNDJSONReader creates a reader for newline-delimited JSON
+func NewNDJSONReader(r io.ReadCloser) *JSONStreamReader {
+	return &JSONStreamReader{
+		decoder: json.NewDecoder(r),
+		closer:  r,
+	}
+}
+
+// NewJSONArrayReader creates a reader for a streamed JSON array
+func NewJSONArrayReader(r io.ReadCloser) *JSONStreamReader {
+	return &JSONStreamReader{
+		decoder: json.NewDecoder(r),
+		closer:  r,
+		array:   true,
+	}
+}
+
+// ReadEvent reads the next JSON value as a data-only SSE event
+func (r *JSONStreamReader) ReadEvent() (*SSEEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isClosed() {
+		return nil, io.EOF
+	}
+
+	if r.array {
+		if !r.started {
+			token, err := r.decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			if delim, ok := token.(json.Delim); !ok || delim != '[' {
+				return nil, fmt.Errorf("expected JSON array, got %v", token)
+			}
+			r.started = true
+		}
+		if !r.decoder.More() {
+			return nil, io.EOF
+		}
+	}
+
+	var raw json.RawMessage
+	if err := r.decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, raw); err != nil {
+		return nil, err
+	}
+
+	return &SSEEvent{Data: compacted.String()}, nil
+}
+
+// Close closes the reader
+func (r *JSONStreamReader) Close() error {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	return r.closer.Close()
+}
+
+// isClosed reports whether the reader has been closed
+func (r *JSONStreamReader) isClosed() bool {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+	return r.closed
+}
@@ -0,0 +1,111 @@
+package transformer
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestDetectStreamFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        StreamFormat
+	}{
+		{"sse content type", "text/event-stream; charset=utf-8", `{"not": "sniffed"}`, StreamFormatSSE},
+		{"ndjson content type", "application/x-ndjson", "data: not sniffed", StreamFormatNDJSON},
+		{"jsonl content type", "application/jsonl", "", StreamFormatNDJSON},
+		{"json array framing", "application/json", "\n [{\"a\":1}]", StreamFormatJSONArray},
+		{"json object framing", "application/json", "{\"a\":1}\n{\"a\":2}\n", StreamFormatNDJSON},
+		{"sse framing without type", "", "data: {}\n\n", StreamFormatSSE},
+		{"event framing", "text/plain", "event: ping\n\n", StreamFormatSSE},
+		{"empty body", "", "", StreamFormatSSE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bufio.NewReader(strings.NewReader(tt.body))
+			testutil.AssertEqual(t, tt.want, DetectStreamFormat(tt.contentType, body))
+		})
+	}
+}
+
+func TestNewStreamReader(t *testing.T) {
+	readAll := func(t *testing.T, reader StreamReader) string {
+		t.Helper()
+		defer reader.Close()
+		var data []string
+		for {
+			event, err := reader.ReadEvent()
+			if err == io.EOF {
+				return strings.Join(data, "\n")
+			}
+			testutil.AssertNoError(t, err)
+			data = append(data, event.Data)
+		}
+	}
+
+	newResponse := func(contentType, body string) *http.Response {
+		return &http.Response{
+			Header: http.Header{"Content-Type": []string{contentType}},
+			Body:   io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	t.Run("SSE", func(t *testing.T) {
+		reader, format := NewStreamReader(newResponse("text/event-stream", "data: {\"n\":1}\n\ndata: [DONE]\n\n"))
+		testutil.AssertEqual(t, StreamFormatSSE, format)
+		_, ok := reader.(*SSEReader)
+		testutil.AssertTrue(t, ok, "Expected SSE reader")
+		testutil.AssertEqual(t, strings.Join([]string{`{"n":1}`, "[DONE]"}, "\n"), readAll(t, reader))
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		body := "{\"message\":{\"content\":\"Hel\"},\"done\":false}\n\n{\"message\":{\"content\":\"lo\"},\"done\":true}\n"
+		reader, format := NewStreamReader(newResponse("application/x-ndjson", body))
+		testutil.AssertEqual(t, StreamFormatNDJSON, format)
+		_, ok := reader.(*JSONStreamReader)
+		testutil.AssertTrue(t, ok, "Expected JSON stream reader")
+		testutil.AssertEqual(t, strings.Join([]string{
+			`{"message":{"content":"Hel"},"done":false}`,
+			`{"message":{"content":"lo"},"done":true}`,
+		}, "\n"), readAll(t, reader))
+	})
+
+	t.Run("ChunkedJSONArray", func(t *testing.T) {
+		body := "[{\n  \"candidates\": [{\"index\": 0}]\n}\n,\r\n{\"candidates\": [{\"index\": 1}]}]"
+		reader, format := NewStreamReader(newResponse("application/json", body))
+		testutil.AssertEqual(t, StreamFormatJSONArray, format)
+		testutil.AssertEqual(t, strings.Join([]string{
+			`{"candidates":[{"index":0}]}`,
+			`{"candidates":[{"index":1}]}`,
+		}, "\n"), readAll(t, reader))
+	})
+
+	t.Run("ArrivesInPieces", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		go func() {
+			for _, piece := range []string{"[{\"a\":", "1},", "{\"a\":2}", "]"} {
+				pw.Write([]byte(piece))
+			}
+			pw.Close()
+		}()
+
+		reader, format := NewStreamReader(&http.Response{Header: http.Header{}, Body: pr})
+		testutil.AssertEqual(t, StreamFormatJSONArray, format)
+		testutil.AssertEqual(t, strings.Join([]string{`{"a":1}`, `{"a":2}`}, "\n"), readAll(t, reader))
+	})
+
+	t.Run("CloseStopsReading", func(t *testing.T) {
+		reader := NewNDJSONReader(io.NopCloser(strings.NewReader("{\"a\":1}\n")))
+		testutil.AssertNoError(t, reader.Close())
+
+		_, err := reader.ReadEvent()
+		testutil.AssertEqual(t, io.EOF, err)
+	})
+}
@@ -12,10 +12,11 @@ import (
 
 // SSEReader implements StreamReader for Server-Sent Events
 type SSEReader struct {
-	reader *bufio.Reader
-	closer io.Closer
-	mu     sync.Mutex
-	closed bool
+	reader  *bufio.Reader
+	closer  io.Closer
+	mu      sync.Mutex // serializes reads
+	closeMu sync.Mutex // guards closed so Close never waits on a blocked read
+	closed  bool
 }
 
 // NewSSEReader creates a new SSE reader
@@ -31,7 +32,7 @@ func (r *SSEReader) ReadEvent() (*SSEEvent, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.closed {
+	if r.isClosed() {
 		return nil, io.EOF
 	}
 
@@ -73,8 +74,8 @@ func (r *SSEReader) ReadEvent() (*SSEEvent, error) {
 
 // Close closes the reader
 func (r *SSEReader) Close() error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
 
 	if r.closed {
 		return nil
@@ -84,6 +85,13 @@ func (r *SSEReader) Close() error {
 	return r.closer.Close()
 }
 
+// isClosed reports whether the reader has been closed
+func (r *SSEReader) isClosed() bool {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+	return r.closed
+}
+
 // SSEWriter implements StreamWriter for Server-Sent Events
 type SSEWriter struct {
 	writer  io.Writer
@@ -157,6 +165,26 @@ func (w *SSEWriter) WriteEvent(event *SSEEvent) error {
 	return nil
 }
 
+// WriteComment writes an SSE comment line, which clients ignore
+func (w *SSEWriter) WriteComment(text string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return fmt.Errorf("writer is closed")
+	}
+
+	if _, err := fmt.Fprintf(w.writer, ": %s\n\n", text); err != nil {
+		return err
+	}
+
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+
+	return nil
+}
+
 // Flush flushes any buffered data
 func (w *SSEWriter) Flush() error {
 	w.mu.Lock()
@@ -291,6 +291,43 @@ func TestSSEWriter_WriteEvent(t *testing.T) {
 	})
 }
 
+func TestSSEWriter_WriteComment(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewSSEWriter(&buf)
+
+	if err := writer.WriteComment("keepalive"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := writer.WriteEvent(&SSEEvent{Data: "hello"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := ": keepalive\n\ndata: hello\n\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	// Comments are ignored by readers
+	reader := NewSSEReader(io.NopCloser(strings.NewReader(buf.String())))
+	for {
+		event, err := reader.ReadEvent()
+		if err != nil {
+			t.Fatalf("Expected a data event, got error: %v", err)
+		}
+		if event.Data != "" {
+			if event.Data != "hello" {
+				t.Errorf("Expected data 'hello', got %q", event.Data)
+			}
+			break
+		}
+	}
+
+	writer.Close()
+	if err := writer.WriteComment("keepalive"); err == nil {
+		t.Error("Expected error writing to closed writer")
+	}
+}
+
 func TestSSEWriter_Flush(t *testing.T) {
 	var buf bytes.Buffer
 	writer := NewSSEWriter(&buf)
@@ -0,0 +1,101 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// defaultThinkingBudget is the budget used when a request enables thinking without one
+const defaultThinkingBudget = 16000
+
+// ThinkingTransformer strips or translates the thinking field per provider
+type ThinkingTransformer struct {
+	*BaseTransformer
+}
+
+// NewThinkingTransformer creates a new Thinking transformer
+func NewThinkingTransformer() *ThinkingTransformer {
+	return &ThinkingTransformer{
+		BaseTransformer: NewBaseTransformer("thinking", ""),
+	}
+}
+
+// TransformRequestIn rewrites the thinking field into a form the provider accepts
+func (t *ThinkingTransformer) TransformRequestIn(ctx context.Context, request interface{}, provider string) (interface{}, error) {
+	// Handle RequestConfig
+	if reqConfig, ok := request.(*RequestConfig); ok {
+		if bodyMap, ok := reqConfig.Body.(map[string]interface{}); ok {
+			t.processThinking(bodyMap, provider)
+		}
+		return reqConfig, nil
+	}
+
+	// Handle direct body
+	bodyMap, ok := request.(map[string]interface{})
+	if !ok {
+		// Try to convert from other types
+		data, err := json.Marshal(request)
+		if err != nil {
+			return request, nil // Pass through on error
+		}
+		if err := json.Unmarshal(data, &bodyMap); err != nil {
+			return request, nil // Pass through on error
+		}
+	}
+
+	t.processThinking(bodyMap, provider)
+	return bodyMap, nil
+}
+
+// processThinking handles the thinking field for a provider
+func (t *ThinkingTransformer) processThinking(bodyMap map[string]interface{}, provider string) {
+	thinking, exists := bodyMap["thinking"]
+	if !exists {
+		return
+	}
+
+	switch provider {
+	case "anthropic":
+		// Anthropic expects an object with a token budget
+		if !ThinkingEnabled(thinking) {
+			delete(bodyMap, "thinking")
+			return
+		}
+		if _, ok := thinking.(map[string]interface{}); !ok {
+			bodyMap["thinking"] = map[string]interface{}{
+				"type":          "enabled",
+				"budget_tokens": defaultThinkingBudget,
+			}
+		}
+	case "openrouter":
+		// OpenRouter exposes reasoning through its own parameter
+		delete(bodyMap, "thinking")
+		if !ThinkingEnabled(thinking) {
+			return
+		}
+		reasoning := map[string]interface{}{"enabled": true}
+		if thinkingMap, ok := thinking.(map[string]interface{}); ok {
+			if budget, ok := thinkingMap["budget_tokens"]; ok {
+				reasoning = map[string]interface{}{"max_tokens": budget}
+			}
+		}
+		bodyMap["reasoning"] = reasoning
+	default:
+		// Other providers reject unknown fields
+		delete(bodyMap, "thinking")
+	}
+}
+
+// ThinkingEnabled reports whether a thinking field value turns thinking on.
+// It accepts both the boolean shorthand and the Anthropic object form.
+func ThinkingEnabled(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case map[string]interface{}:
+		thinkingType, ok := v["type"].(string)
+		return !ok || thinkingType != "disabled"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,112 @@
+package transformer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestThinkingTransformer_TransformRequestIn(t *testing.T) {
+	transformer := NewThinkingTransformer()
+	ctx := context.Background()
+
+	if transformer.GetName() != "thinking" {
+		t.Errorf("Expected name 'thinking', got %s", transformer.GetName())
+	}
+
+	t.Run("AnthropicTranslatesBoolean", func(t *testing.T) {
+		request := map[string]interface{}{"model": "claude-3-opus", "thinking": true}
+
+		result, err := transformer.TransformRequestIn(ctx, request, "anthropic")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		thinking, ok := result.(map[string]interface{})["thinking"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("Expected thinking object, got %v", result.(map[string]interface{})["thinking"])
+		}
+		if thinking["type"] != "enabled" || thinking["budget_tokens"] != defaultThinkingBudget {
+			t.Errorf("Unexpected thinking object: %v", thinking)
+		}
+	})
+
+	t.Run("AnthropicKeepsObject", func(t *testing.T) {
+		request := map[string]interface{}{
+			"thinking": map[string]interface{}{"type": "enabled", "budget_tokens": 2048},
+		}
+
+		result, _ := transformer.TransformRequestIn(ctx, request, "anthropic")
+		thinking := result.(map[string]interface{})["thinking"].(map[string]interface{})
+		if thinking["budget_tokens"] != 2048 {
+			t.Errorf("Expected budget to be preserved, got %v", thinking["budget_tokens"])
+		}
+	})
+
+	t.Run("AnthropicDropsDisabled", func(t *testing.T) {
+		request := map[string]interface{}{"thinking": false}
+
+		result, _ := transformer.TransformRequestIn(ctx, request, "anthropic")
+		if _, exists := result.(map[string]interface{})["thinking"]; exists {
+			t.Error("Expected disabled thinking to be removed")
+		}
+	})
+
+	t.Run("OpenRouterTranslatesToReasoning", func(t *testing.T) {
+		request := map[string]interface{}{
+			"thinking": map[string]interface{}{"type": "enabled", "budget_tokens": 4000},
+		}
+
+		result, _ := transformer.TransformRequestIn(ctx, request, "openrouter")
+		bodyMap := result.(map[string]interface{})
+		if _, exists := bodyMap["thinking"]; exists {
+			t.Error("Expected thinking to be removed")
+		}
+		reasoning, ok := bodyMap["reasoning"].(map[string]interface{})
+		if !ok || reasoning["max_tokens"] != 4000 {
+			t.Errorf("Expected reasoning max_tokens 4000, got %v", bodyMap["reasoning"])
+		}
+	})
+
+	t.Run("OtherProvidersStrip", func(t *testing.T) {
+		for _, provider := range []string{"openai", "gemini", "groq", "deepseek"} {
+			request := map[string]interface{}{"model": "m", "thinking": true}
+
+			result, _ := transformer.TransformRequestIn(ctx, request, provider)
+			if _, exists := result.(map[string]interface{})["thinking"]; exists {
+				t.Errorf("Expected thinking to be stripped for %s", provider)
+			}
+		}
+	})
+
+	t.Run("RequestConfig", func(t *testing.T) {
+		reqConfig := &RequestConfig{Body: map[string]interface{}{"thinking": true}}
+
+		result, _ := transformer.TransformRequestIn(ctx, reqConfig, "openai")
+		if _, exists := result.(*RequestConfig).Body.(map[string]interface{})["thinking"]; exists {
+			t.Error("Expected thinking to be stripped from RequestConfig body")
+		}
+	})
+}
+
+func TestThinkingEnabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected bool
+	}{
+		{"true", true, true},
+		{"false", false, false},
+		{"enabled object", map[string]interface{}{"type": "enabled", "budget_tokens": 1024}, true},
+		{"disabled object", map[string]interface{}{"type": "disabled"}, false},
+		{"string", "yes", false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ThinkingEnabled(tt.value); got != tt.expected {
+				t.Errorf("ThinkingEnabled(%v) = %v, want %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package transformer
+
+// Tool choice modes shared by every provider's representation
+const (
+	toolChoiceAuto     = "auto"
+	toolChoiceNone     = "none"
+	toolChoiceRequired = "required"
+	toolChoiceTool     = "tool" // Forces the named tool
+)
+
+// toolChoice is a provider-neutral tool_choice
+type toolChoice struct {
+	mode string
+	name string // Tool to call when mode is toolChoiceTool
+}
+
+// parseToolChoice reads a tool_choice in OpenAI form ("auto", "none",
+// "required" or {"type":"function","function":{"name":...}}) or Anthropic form
+// ({"type":"auto"|"any"|"none"} or {"type":"tool","name":...}). It reports
+// false for missing or unrecognized values.
+func parseToolChoice(value interface{}) (toolChoice, bool) {
+	switch v := value.(type) {
+	case string:
+		switch v {
+		case toolChoiceAuto, toolChoiceNone, toolChoiceRequired:
+			return toolChoice{mode: v}, true
+		case "any":
+			return toolChoice{mode: toolChoiceRequired}, true
+		}
+	case map[string]interface{}:
+		choiceType, _ := v["type"].(string)
+		switch choiceType {
+		case "function":
+			if function, ok := v["function"].(map[string]interface{}); ok {
+				if name, ok := function["name"].(string); ok && name != "" {
+					return toolChoice{mode: toolChoiceTool, name: name}, true
+				}
+			}
+		case "tool":
+			if name, ok := v["name"].(string); ok && name != "" {
+				return toolChoice{mode: toolChoiceTool, name: name}, true
+			}
+		case toolChoiceAuto, toolChoiceNone:
+			return toolChoice{mode: choiceType}, true
+		case "any":
+			return toolChoice{mode: toolChoiceRequired}, true
+		}
+	}
+	return toolChoice{}, false
+}
+
+// openAI returns the choice in OpenAI's representation
+func (c toolChoice) openAI() interface{} {
+	if c.mode == toolChoiceTool {
+		return map[string]interface{}{
+			"type":     "function",
+			"function": map[string]interface{}{"name": c.name},
+		}
+	}
+	return c.mode
+}
+
+// anthropic returns the choice in Anthropic's representation
+func (c toolChoice) anthropic() map[string]interface{} {
+	switch c.mode {
+	case toolChoiceTool:
+		return map[string]interface{}{"type": "tool", "name": c.name}
+	case toolChoiceRequired:
+		return map[string]interface{}{"type": "any"}
+	default:
+		return map[string]interface{}{"type": c.mode}
+	}
+}
+
+// gemini returns the choice as a Gemini toolConfig. A forced tool is ANY mode
+// restricted to that one function.
+func (c toolChoice) gemini() map[string]interface{} {
+	config := map[string]interface{}{}
+	switch c.mode {
+	case toolChoiceTool:
+		config["mode"] = "ANY"
+		config["allowedFunctionNames"] = []interface{}{c.name}
+	case toolChoiceRequired:
+		config["mode"] = "ANY"
+	case toolChoiceNone:
+		config["mode"] = "NONE"
+	default:
+		config["mode"] = "AUTO"
+	}
+	return map[string]interface{}{"functionCallingConfig": config}
+}
+
+// sequentialToolCalls reports whether a request allows at most one tool call
+// per turn, through OpenAI's parallel_tool_calls: false or Anthropic's
+// tool_choice.disable_parallel_tool_use
+func sequentialToolCalls(reqMap map[string]interface{}) bool {
+	if parallel, ok := reqMap["parallel_tool_calls"].(bool); ok && !parallel {
+		return true
+	}
+	if choice, ok := reqMap["tool_choice"].(map[string]interface{}); ok {
+		if disable, ok := choice["disable_parallel_tool_use"].(bool); ok && disable {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,305 @@
+package transformer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// toolChoiceRequest builds an OpenAI request with one tool and choice
+func toolChoiceRequest(choice interface{}) map[string]interface{} {
+	request := map[string]interface{}{
+		"model": "test-model",
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": "What's the weather?"},
+		},
+		"tools": []interface{}{
+			map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":       "get_weather",
+					"parameters": map[string]interface{}{"type": "object"},
+				},
+			},
+		},
+	}
+	if choice != nil {
+		request["tool_choice"] = choice
+	}
+	return request
+}
+
+// toolChoiceInputs are the same choices in every accepted form
+var toolChoiceInputs = []struct {
+	name   string
+	choice interface{}
+	mode   string
+}{
+	{"openai auto", "auto", toolChoiceAuto},
+	{"openai none", "none", toolChoiceNone},
+	{"openai required", "required", toolChoiceRequired},
+	{"openai function", map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_weather"}}, toolChoiceTool},
+	{"anthropic auto", map[string]interface{}{"type": "auto"}, toolChoiceAuto},
+	{"anthropic any", map[string]interface{}{"type": "any"}, toolChoiceRequired},
+	{"anthropic tool", map[string]interface{}{"type": "tool", "name": "get_weather"}, toolChoiceTool},
+}
+
+func TestToolChoice_Anthropic(t *testing.T) {
+	want := map[string]interface{}{
+		toolChoiceAuto:     map[string]interface{}{"type": "auto"},
+		toolChoiceNone:     map[string]interface{}{"type": "none"},
+		toolChoiceRequired: map[string]interface{}{"type": "any"},
+		toolChoiceTool:     map[string]interface{}{"type": "tool", "name": "get_weather"},
+	}
+
+	for _, tt := range toolChoiceInputs {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewAnthropicTransformer().TransformRequestIn(context.Background(), toolChoiceRequest(tt.choice), "anthropic")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := result.(map[string]interface{})["tool_choice"]
+			if !reflect.DeepEqual(got, want[tt.mode]) {
+				t.Errorf("Expected tool_choice %v, got %v", want[tt.mode], got)
+			}
+		})
+	}
+}
+
+func TestToolChoice_Gemini(t *testing.T) {
+	want := map[string]interface{}{
+		toolChoiceAuto:     map[string]interface{}{"mode": "AUTO"},
+		toolChoiceNone:     map[string]interface{}{"mode": "NONE"},
+		toolChoiceRequired: map[string]interface{}{"mode": "ANY"},
+		toolChoiceTool:     map[string]interface{}{"mode": "ANY", "allowedFunctionNames": []interface{}{"get_weather"}},
+	}
+
+	for _, tt := range toolChoiceInputs {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewGeminiTransformer().TransformRequestIn(context.Background(), toolChoiceRequest(tt.choice), "gemini")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resultMap := result.(map[string]interface{})
+			toolConfig, ok := resultMap["toolConfig"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("Expected toolConfig, got %v", resultMap)
+			}
+			if got := toolConfig["functionCallingConfig"]; !reflect.DeepEqual(got, want[tt.mode]) {
+				t.Errorf("Expected functionCallingConfig %v, got %v", want[tt.mode], got)
+			}
+			if _, exists := resultMap["tool_choice"]; exists {
+				t.Error("Expected tool_choice to be removed")
+			}
+		})
+	}
+
+	t.Run("no choice", func(t *testing.T) {
+		result, err := NewGeminiTransformer().TransformRequestIn(context.Background(), toolChoiceRequest(nil), "gemini")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, exists := result.(map[string]interface{})["toolConfig"]; exists {
+			t.Error("Expected no toolConfig without a tool_choice")
+		}
+	})
+}
+
+func TestToolChoice_OpenAICompatible(t *testing.T) {
+	want := map[string]interface{}{
+		toolChoiceAuto:     "auto",
+		toolChoiceNone:     "none",
+		toolChoiceRequired: "required",
+		toolChoiceTool:     map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_weather"}},
+	}
+
+	for _, provider := range []string{"openai", "groq", "deepseek"} {
+		for _, tt := range toolChoiceInputs {
+			t.Run(provider+" "+tt.name, func(t *testing.T) {
+				result, err := NewParametersTransformer().TransformRequestIn(context.Background(), toolChoiceRequest(tt.choice), provider)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				got := result.(map[string]interface{})["tool_choice"]
+				if !reflect.DeepEqual(got, want[tt.mode]) {
+					t.Errorf("Expected tool_choice %v, got %v", want[tt.mode], got)
+				}
+			})
+		}
+	}
+}
+
+func TestToolChoice_ToolUsePreservesForcedTool(t *testing.T) {
+	tests := []struct {
+		name   string
+		choice interface{}
+		want   interface{}
+	}{
+		{"no choice", nil, "required"},
+		{"auto", "auto", "required"},
+		{"forced tool", map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_weather"}},
+			map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_weather"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewToolUseTransformer().TransformRequestIn(context.Background(), toolChoiceRequest(tt.choice), "openai")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := result.(map[string]interface{})["tool_choice"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected tool_choice %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseToolChoice_Unrecognized(t *testing.T) {
+	for _, value := range []interface{}{nil, "sometimes", map[string]interface{}{"type": "function"}, 42} {
+		if _, ok := parseToolChoice(value); ok {
+			t.Errorf("Expected %v to be unrecognized", value)
+		}
+	}
+}
+
+// sequentialRequest builds a request with one tool that allows one tool call
+// per turn through parallel_tool_calls
+func sequentialRequest(choice interface{}) map[string]interface{} {
+	request := toolChoiceRequest(choice)
+	request["parallel_tool_calls"] = false
+	return request
+}
+
+func TestParallelToolCalls_Anthropic(t *testing.T) {
+	tests := []struct {
+		name    string
+		request map[string]interface{}
+		want    interface{}
+	}{
+		{"no choice", sequentialRequest(nil),
+			map[string]interface{}{"type": "auto", "disable_parallel_tool_use": true}},
+		{"required", sequentialRequest("required"),
+			map[string]interface{}{"type": "any", "disable_parallel_tool_use": true}},
+		{"forced tool", sequentialRequest(map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_weather"}}),
+			map[string]interface{}{"type": "tool", "name": "get_weather", "disable_parallel_tool_use": true}},
+		{"none", sequentialRequest("none"), map[string]interface{}{"type": "none"}},
+		{"anthropic form", toolChoiceRequest(map[string]interface{}{"type": "any", "disable_parallel_tool_use": true}),
+			map[string]interface{}{"type": "any", "disable_parallel_tool_use": true}},
+		{"parallel allowed", toolChoiceRequest("auto"), map[string]interface{}{"type": "auto"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewAnthropicTransformer().TransformRequestIn(context.Background(), tt.request, "anthropic")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resultMap := result.(map[string]interface{})
+			if got := resultMap["tool_choice"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected tool_choice %v, got %v", tt.want, got)
+			}
+			if _, exists := resultMap["parallel_tool_calls"]; exists {
+				t.Error("Expected parallel_tool_calls to be removed")
+			}
+		})
+	}
+}
+
+func TestParallelToolCalls_OpenAICompatible(t *testing.T) {
+	for _, provider := range []string{"openai", "groq", "openrouter"} {
+		t.Run(provider+" passes through", func(t *testing.T) {
+			for _, parallel := range []bool{false, true} {
+				request := toolChoiceRequest("auto")
+				request["parallel_tool_calls"] = parallel
+				result, err := NewParametersTransformer().TransformRequestIn(context.Background(), request, provider)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if got := result.(map[string]interface{})["parallel_tool_calls"]; got != parallel {
+					t.Errorf("Expected parallel_tool_calls %v, got %v", parallel, got)
+				}
+			}
+		})
+	}
+
+	t.Run("anthropic form", func(t *testing.T) {
+		request := toolChoiceRequest(map[string]interface{}{"type": "any", "disable_parallel_tool_use": true})
+		result, err := NewParametersTransformer().TransformRequestIn(context.Background(), request, "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resultMap := result.(map[string]interface{})
+		if resultMap["tool_choice"] != "required" {
+			t.Errorf("Expected tool_choice required, got %v", resultMap["tool_choice"])
+		}
+		if resultMap["parallel_tool_calls"] != false {
+			t.Errorf("Expected parallel_tool_calls false, got %v", resultMap["parallel_tool_calls"])
+		}
+	})
+
+	t.Run("without tools", func(t *testing.T) {
+		request := sequentialRequest(nil)
+		delete(request, "tools")
+		result, err := NewParametersTransformer().TransformRequestIn(context.Background(), request, "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, exists := result.(map[string]interface{})["parallel_tool_calls"]; exists {
+			t.Error("Expected parallel_tool_calls to be removed without tools")
+		}
+	})
+}
+
+func TestParallelToolCalls_Chains(t *testing.T) {
+	service := NewService()
+	if err := RegisterBuiltinTransformers(service); err != nil {
+		t.Fatalf("Failed to register transformers: %v", err)
+	}
+
+	for _, provider := range []string{"anthropic", "gemini", "openai"} {
+		t.Run(provider, func(t *testing.T) {
+			result, err := service.GetChainForProvider(provider).TransformRequestIn(context.Background(), sequentialRequest(nil), provider)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if config, ok := result.(*RequestConfig); ok {
+				result = config.Body
+			}
+			resultMap := result.(map[string]interface{})
+
+			switch provider {
+			case "anthropic":
+				choice, _ := resultMap["tool_choice"].(map[string]interface{})
+				if choice["disable_parallel_tool_use"] != true {
+					t.Errorf("Expected disable_parallel_tool_use, got tool_choice %v", resultMap["tool_choice"])
+				}
+				if _, exists := resultMap["parallel_tool_calls"]; exists {
+					t.Error("Expected parallel_tool_calls to be removed")
+				}
+			case "gemini":
+				if _, exists := resultMap["parallel_tool_calls"]; exists {
+					t.Error("Expected parallel_tool_calls to be removed")
+				}
+			case "openai":
+				if resultMap["parallel_tool_calls"] != false {
+					t.Errorf("Expected parallel_tool_calls false, got %v", resultMap["parallel_tool_calls"])
+				}
+			}
+		})
+	}
+}
+
+func TestParallelToolCalls_ToolUseKeepsLimit(t *testing.T) {
+	request := toolChoiceRequest(map[string]interface{}{"type": "auto", "disable_parallel_tool_use": true})
+	result, err := NewToolUseTransformer().TransformRequestIn(context.Background(), request, "openai")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resultMap := result.(map[string]interface{})
+	if resultMap["tool_choice"] != "required" {
+		t.Errorf("Expected tool_choice required, got %v", resultMap["tool_choice"])
+	}
+	if resultMap["parallel_tool_calls"] != false {
+		t.Errorf("Expected parallel_tool_calls false, got %v", resultMap["parallel_tool_calls"])
+	}
+}
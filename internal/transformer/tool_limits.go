@@ -0,0 +1,211 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+	"github.com/orchestre-dev/ccproxy/internal/utils"
+)
+
+// builtinMaxTools lists providers that reject requests with more tools
+var builtinMaxTools = map[string]int{
+	"openai": 128,
+	"azure":  128,
+}
+
+// ToolLimits caps the tools a request may send to a provider
+type ToolLimits struct {
+	MaxTools       int  // Most tools per request, 0 uses the built-in limit
+	MaxSchemaBytes int  // Largest serialized tools array, 0 means unlimited
+	Truncate       bool // Drop tools from the end instead of rejecting the request
+}
+
+// ToolLimitsTransformer enforces per-provider tool limits. It runs after the
+// other transformers of the default chain, so tools they add, such as
+// tooluse's ExitTool, count against the limits, and tools are measured in the
+// provider's format: Gemini schemas count after cleanJSONSchema has removed
+// the keywords Gemini does not accept.
+type ToolLimitsTransformer struct {
+	*BaseTransformer
+	mu     sync.RWMutex
+	limits map[string]ToolLimits // provider -> configured tool limits
+}
+
+// NewToolLimitsTransformer creates a new ToolLimits transformer
+func NewToolLimitsTransformer() *ToolLimitsTransformer {
+	return &ToolLimitsTransformer{
+		BaseTransformer: NewBaseTransformer("toollimits", ""),
+		limits:          make(map[string]ToolLimits),
+	}
+}
+
+// SetToolLimits sets the tool limits for a provider, replacing the built-in
+// tool count limit when limits.MaxTools is set
+func (t *ToolLimitsTransformer) SetToolLimits(provider string, limits ToolLimits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if limits == (ToolLimits{}) {
+		delete(t.limits, provider)
+		return
+	}
+	t.limits[provider] = limits
+}
+
+// TransformRequestIn applies the provider's tool limits to the request body
+func (t *ToolLimitsTransformer) TransformRequestIn(ctx context.Context, request interface{}, provider string) (interface{}, error) {
+	t.mu.RLock()
+	limits := t.limits[provider]
+	t.mu.RUnlock()
+
+	// Handle RequestConfig
+	body := request
+	if reqConfig, ok := request.(*RequestConfig); ok {
+		body = reqConfig.Body
+	}
+	if bodyMap, ok := body.(map[string]interface{}); ok {
+		if err := LimitTools(bodyMap, provider, limits); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// ToolLimitsFor returns the tool limits configured for a provider
+func ToolLimitsFor(provider *config.Provider) ToolLimits {
+	return ToolLimits{
+		MaxTools:       provider.MaxTools,
+		MaxSchemaBytes: provider.MaxToolSchemaBytes,
+		Truncate:       provider.ToolLimitStrategy == config.ToolLimitTruncate,
+	}
+}
+
+// LimitTools checks the tool count and serialized tool size of a request
+// against limits, falling back to the provider's built-in tool count limit.
+// Requests over a limit fail with a validation error, or lose tools from the
+// end when limits.Truncate is set. A tool the request forces through
+// tool_choice is moved to the front before truncating, so it is never dropped.
+func LimitTools(bodyMap map[string]interface{}, provider string, limits ToolLimits) error {
+	tools, ok := bodyMap["tools"].([]interface{})
+	if !ok || len(tools) == 0 {
+		return nil
+	}
+
+	if limits.MaxTools == 0 {
+		limits.MaxTools = builtinMaxTools[provider]
+	}
+
+	if limits.MaxTools > 0 && len(tools) > limits.MaxTools {
+		if !limits.Truncate {
+			return ccerrors.NewValidationError(fmt.Sprintf(
+				"request has %d tools but provider %s accepts at most %d", len(tools), provider, limits.MaxTools), nil)
+		}
+		utils.GetLogger().Warnf("Dropping %d of %d tools over the limit of provider %s",
+			len(tools)-limits.MaxTools, len(tools), provider)
+		tools = forcedToolFirst(tools, forcedToolName(bodyMap))[:limits.MaxTools]
+	}
+
+	if limits.MaxSchemaBytes > 0 {
+		_, total := toolSizes(tools)
+		if total > limits.MaxSchemaBytes {
+			if !limits.Truncate {
+				return ccerrors.NewValidationError(fmt.Sprintf(
+					"tool definitions take %d bytes but provider %s accepts at most %d", total, provider, limits.MaxSchemaBytes), nil)
+			}
+
+			tools = forcedToolFirst(tools, forcedToolName(bodyMap))
+			sizes, total := toolSizes(tools)
+
+			kept := len(tools)
+			for kept > 0 && total > limits.MaxSchemaBytes {
+				kept--
+				total -= sizes[kept]
+				if kept > 0 {
+					total-- // The separating comma
+				}
+			}
+			if kept == 0 {
+				return ccerrors.NewValidationError(fmt.Sprintf(
+					"the first tool definition alone exceeds the %d byte limit of provider %s", limits.MaxSchemaBytes, provider), nil)
+			}
+			utils.GetLogger().Warnf("Dropping %d of %d tools over the %d byte limit of provider %s",
+				len(tools)-kept, len(tools), limits.MaxSchemaBytes, provider)
+			tools = tools[:kept]
+		}
+	}
+
+	bodyMap["tools"] = tools
+	return nil
+}
+
+// forcedToolName returns the name of the tool a request forces through
+// tool_choice, or Gemini's toolConfig when it allows a single function, or ""
+func forcedToolName(bodyMap map[string]interface{}) string {
+	if choice, ok := parseToolChoice(bodyMap["tool_choice"]); ok && choice.mode == toolChoiceTool {
+		return choice.name
+	}
+	if toolConfig, ok := bodyMap["toolConfig"].(map[string]interface{}); ok {
+		if callingConfig, ok := toolConfig["functionCallingConfig"].(map[string]interface{}); ok {
+			if names, ok := callingConfig["allowedFunctionNames"].([]interface{}); ok && len(names) == 1 {
+				name, _ := names[0].(string)
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// forcedToolFirst returns tools with the one named name moved to the front,
+// keeping the order of the rest, or tools unchanged when name is not found
+func forcedToolFirst(tools []interface{}, name string) []interface{} {
+	if name == "" {
+		return tools
+	}
+	for i, tool := range tools {
+		if i > 0 && toolName(tool) == name {
+			reordered := make([]interface{}, 0, len(tools))
+			reordered = append(reordered, tool)
+			reordered = append(reordered, tools[:i]...)
+			return append(reordered, tools[i+1:]...)
+		}
+	}
+	return tools
+}
+
+// toolName returns the name of a tool in OpenAI, Anthropic or Gemini format
+func toolName(tool interface{}) string {
+	toolMap, ok := tool.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if function, ok := toolMap["function"].(map[string]interface{}); ok {
+		name, _ := function["name"].(string)
+		return name
+	}
+	if declarations, ok := toolMap["function_declarations"].([]interface{}); ok && len(declarations) == 1 {
+		if declaration, ok := declarations[0].(map[string]interface{}); ok {
+			name, _ := declaration["name"].(string)
+			return name
+		}
+	}
+	name, _ := toolMap["name"].(string)
+	return name
+}
+
+// toolSizes returns the JSON size of each tool and of the whole array
+func toolSizes(tools []interface{}) ([]int, int) {
+	sizes := make([]int, len(tools))
+	total := 2 + len(tools) - 1 // Brackets and commas
+	for i, tool := range tools {
+		data, err := json.Marshal(tool)
+		if err == nil {
+			sizes[i] = len(data)
+		}
+		total += sizes[i]
+	}
+	return sizes, total
+}
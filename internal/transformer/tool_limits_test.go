@@ -0,0 +1,237 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/orchestre-dev/ccproxy/internal/config"
+	ccerrors "github.com/orchestre-dev/ccproxy/internal/errors"
+)
+
+// toolsRequest builds an OpenAI request with count tools, each with a
+// description of descriptionSize bytes
+func toolsRequest(count, descriptionSize int) map[string]interface{} {
+	tools := make([]interface{}, count)
+	for i := range tools {
+		tools[i] = map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        fmt.Sprintf("tool_%d", i),
+				"description": strings.Repeat("x", descriptionSize),
+				"parameters":  map[string]interface{}{"type": "object"},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"model":    "test-model",
+		"messages": []interface{}{map[string]interface{}{"role": "user", "content": "hi"}},
+		"tools":    tools,
+	}
+}
+
+// assertValidationError fails unless err is a validation_error containing want
+func assertValidationError(t *testing.T, err error, want string) {
+	t.Helper()
+	var ccErr *ccerrors.CCProxyError
+	if !errors.As(err, &ccErr) || ccErr.Type != ccerrors.ErrorTypeValidationError {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected error containing %q, got %v", want, err)
+	}
+}
+
+func TestToolLimits_BuiltinCount(t *testing.T) {
+	transformer := NewToolLimitsTransformer()
+
+	_, err := transformer.TransformRequestIn(context.Background(), toolsRequest(129, 10), "openai")
+	assertValidationError(t, err, "request has 129 tools but provider openai accepts at most 128")
+
+	if _, err := transformer.TransformRequestIn(context.Background(), toolsRequest(128, 10), "openai"); err != nil {
+		t.Errorf("Expected 128 tools to fit, got %v", err)
+	}
+	if _, err := transformer.TransformRequestIn(context.Background(), toolsRequest(200, 10), "anthropic"); err != nil {
+		t.Errorf("Expected no built-in limit for anthropic, got %v", err)
+	}
+}
+
+func TestToolLimits_Configured(t *testing.T) {
+	t.Run("count error", func(t *testing.T) {
+		transformer := NewToolLimitsTransformer()
+		transformer.SetToolLimits("groq", ToolLimits{MaxTools: 2})
+
+		_, err := transformer.TransformRequestIn(context.Background(), toolsRequest(3, 10), "groq")
+		assertValidationError(t, err, "accepts at most 2")
+	})
+
+	t.Run("count truncate", func(t *testing.T) {
+		transformer := NewToolLimitsTransformer()
+		transformer.SetToolLimits("openai", ToolLimits{MaxTools: 2, Truncate: true})
+
+		result, err := transformer.TransformRequestIn(context.Background(), toolsRequest(5, 10), "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if tools := result.(map[string]interface{})["tools"].([]interface{}); len(tools) != 2 {
+			t.Errorf("Expected 2 tools, got %d", len(tools))
+		}
+	})
+
+	t.Run("schema size error", func(t *testing.T) {
+		transformer := NewToolLimitsTransformer()
+		transformer.SetToolLimits("openai", ToolLimits{MaxSchemaBytes: 1000})
+
+		_, err := transformer.TransformRequestIn(context.Background(), toolsRequest(3, 500), "openai")
+		assertValidationError(t, err, "but provider openai accepts at most 1000")
+	})
+
+	t.Run("schema size truncate", func(t *testing.T) {
+		transformer := NewToolLimitsTransformer()
+		transformer.SetToolLimits("openai", ToolLimits{MaxSchemaBytes: 1500, Truncate: true})
+
+		result, err := transformer.TransformRequestIn(context.Background(), toolsRequest(3, 500), "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		tools := result.(map[string]interface{})["tools"].([]interface{})
+		if len(tools) != 2 {
+			t.Fatalf("Expected 2 tools, got %d", len(tools))
+		}
+		if _, total := toolSizes(tools); total > 1500 {
+			t.Errorf("Expected tools within 1500 bytes, got %d", total)
+		}
+	})
+
+	t.Run("first tool too large", func(t *testing.T) {
+		transformer := NewToolLimitsTransformer()
+		transformer.SetToolLimits("openai", ToolLimits{MaxSchemaBytes: 100, Truncate: true})
+
+		_, err := transformer.TransformRequestIn(context.Background(), toolsRequest(2, 500), "openai")
+		assertValidationError(t, err, "first tool definition alone exceeds")
+	})
+
+	t.Run("raised builtin limit", func(t *testing.T) {
+		transformer := NewToolLimitsTransformer()
+		transformer.SetToolLimits("openai", ToolLimits{MaxTools: 256})
+
+		if _, err := transformer.TransformRequestIn(context.Background(), toolsRequest(200, 10), "openai"); err != nil {
+			t.Errorf("Expected configured limit to replace the built-in one, got %v", err)
+		}
+	})
+}
+
+func TestToolSizes(t *testing.T) {
+	tools := toolsRequest(3, 50)["tools"].([]interface{})
+	sizes, total := toolSizes(tools)
+
+	data, _ := json.Marshal(tools)
+	if total != len(data) {
+		t.Errorf("Expected total %d, got %d", len(data), total)
+	}
+	if len(sizes) != 3 {
+		t.Errorf("Expected 3 sizes, got %d", len(sizes))
+	}
+}
+
+func TestToolLimits_GeminiChain(t *testing.T) {
+	service := NewService()
+	if err := RegisterBuiltinTransformers(service); err != nil {
+		t.Fatalf("Failed to register transformers: %v", err)
+	}
+	service.ConfigureToolLimits([]config.Provider{{Name: "gemini", MaxTools: 3, ToolLimitStrategy: config.ToolLimitTruncate}})
+
+	result, err := service.GetChainForProvider("gemini").TransformRequestIn(context.Background(), toolsRequest(10, 10), "gemini")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reqConfig, ok := result.(*RequestConfig); ok {
+		result = reqConfig.Body
+	}
+	if tools := result.(map[string]interface{})["tools"].([]interface{}); len(tools) != 3 {
+		t.Errorf("Expected 3 Gemini tools, got %d", len(tools))
+	}
+}
+
+func TestToolLimits_KeepsForcedTool(t *testing.T) {
+	t.Run("count truncate", func(t *testing.T) {
+		transformer := NewToolLimitsTransformer()
+		transformer.SetToolLimits("openai", ToolLimits{MaxTools: 2, Truncate: true})
+
+		request := toolsRequest(5, 10)
+		request["tool_choice"] = map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "tool_4"}}
+		result, err := transformer.TransformRequestIn(context.Background(), request, "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		tools := result.(map[string]interface{})["tools"].([]interface{})
+		if len(tools) != 2 || toolName(tools[0]) != "tool_4" || toolName(tools[1]) != "tool_0" {
+			t.Errorf("Expected tool_4 kept ahead of tool_0, got %v", tools)
+		}
+	})
+
+	t.Run("schema size truncate", func(t *testing.T) {
+		transformer := NewToolLimitsTransformer()
+		transformer.SetToolLimits("openai", ToolLimits{MaxSchemaBytes: 1500, Truncate: true})
+
+		request := toolsRequest(3, 500)
+		request["tool_choice"] = map[string]interface{}{"type": "tool", "name": "tool_2"}
+		result, err := transformer.TransformRequestIn(context.Background(), request, "openai")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		tools := result.(map[string]interface{})["tools"].([]interface{})
+		if len(tools) != 2 || toolName(tools[0]) != "tool_2" {
+			t.Errorf("Expected tool_2 to be kept, got %v", tools)
+		}
+	})
+
+	t.Run("gemini chain", func(t *testing.T) {
+		service := NewService()
+		if err := RegisterBuiltinTransformers(service); err != nil {
+			t.Fatalf("Failed to register transformers: %v", err)
+		}
+		service.ConfigureToolLimits([]config.Provider{{Name: "gemini", MaxTools: 2, ToolLimitStrategy: config.ToolLimitTruncate}})
+
+		request := toolsRequest(5, 10)
+		request["tool_choice"] = map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "tool_3"}}
+		result, err := service.GetChainForProvider("gemini").TransformRequestIn(context.Background(), request, "gemini")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if reqConfig, ok := result.(*RequestConfig); ok {
+			result = reqConfig.Body
+		}
+		tools := result.(map[string]interface{})["tools"].([]interface{})
+		if len(tools) != 2 || toolName(tools[0]) != "tool_3" {
+			t.Errorf("Expected tool_3 to be kept, got %v", tools)
+		}
+	})
+}
+
+func TestToolLimits_CountsToolUseExitTool(t *testing.T) {
+	service := NewService()
+	if err := RegisterBuiltinTransformers(service); err != nil {
+		t.Fatalf("Failed to register transformers: %v", err)
+	}
+	providers := []config.Provider{{Name: "openai", Transformers: []config.TransformerConfig{{Name: "tooluse"}}}}
+	if err := service.ConfigureProviderChains(providers); err != nil {
+		t.Fatalf("Failed to configure chains: %v", err)
+	}
+
+	_, err := service.GetChainForProvider("openai").TransformRequestIn(context.Background(), toolsRequest(128, 10), "openai")
+	assertValidationError(t, err, "request has 129 tools but provider openai accepts at most 128")
+
+	service.ConfigureToolLimits([]config.Provider{{Name: "openai", MaxTools: 3, ToolLimitStrategy: config.ToolLimitTruncate}})
+	result, err := service.GetChainForProvider("openai").TransformRequestIn(context.Background(), toolsRequest(5, 10), "openai")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tools := result.(map[string]interface{})["tools"].([]interface{})
+	if len(tools) != 3 || toolName(tools[0]) != "ExitTool" {
+		t.Errorf("Expected 3 tools led by ExitTool, got %v", tools)
+	}
+}
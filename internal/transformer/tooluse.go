@@ -69,12 +69,17 @@ func (t *ToolUseTransformer) TransformRequestIn(ctx context.Context, request int
 		},
 	}
 
-	// Add ExitTool to tools
-	tools = append(tools, exitTool)
-	reqMap["tools"] = tools
-
-	// Set tool_choice to required
-	reqMap["tool_choice"] = "required"
+	// Add ExitTool first, so truncating to a provider's tool limit keeps it
+	reqMap["tools"] = append([]interface{}{exitTool}, tools...)
+
+	// Require a tool call, keeping an explicitly forced tool and any limit
+	// to one tool call per turn that the replaced tool_choice carried
+	if choice, ok := parseToolChoice(reqMap["tool_choice"]); !ok || choice.mode != toolChoiceTool {
+		if sequentialToolCalls(reqMap) {
+			reqMap["parallel_tool_calls"] = false
+		}
+		reqMap["tool_choice"] = toolChoiceRequired
+	}
 
 	return reqMap, nil
 }
@@ -189,13 +194,14 @@ func (t *ToolUseTransformer) transformStreamingResponse(ctx context.Context, res
 		ProtoMajor:    response.ProtoMajor,
 		ProtoMinor:    response.ProtoMinor,
 		Header:        response.Header.Clone(),
-		Body:          pr,
+		Body:          &pipeBody{PipeReader: pr, upstream: reader},
 		ContentLength: -1,
 		Request:       response.Request,
 	}
 
 	// Start transformation in goroutine
 	go func() {
+		defer reader.Close()
 		defer pw.Close()
 		writer := NewSSEWriter(pw)
 
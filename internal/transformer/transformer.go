@@ -1,8 +1,12 @@
 package transformer
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net/http"
+
+	"github.com/orchestre-dev/ccproxy/internal/utils"
 )
 
 // Transformer defines the interface for request/response transformations
@@ -132,29 +136,68 @@ func (c *TransformerChain) Add(transformer Transformer) {
 	c.transformers = append(c.transformers, transformer)
 }
 
-// TransformRequestIn applies all transformers' TransformRequestIn in order
+// Has reports whether the chain contains a transformer with the given name
+func (c *TransformerChain) Has(name string) bool {
+	for _, t := range c.transformers {
+		if t.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TransformRequestIn applies all transformers' TransformRequestIn in order.
+// A failing best-effort transformer is skipped with a warning.
 func (c *TransformerChain) TransformRequestIn(ctx context.Context, request interface{}, provider string) (interface{}, error) {
 	result := request
 	for _, t := range c.transformers {
-		var err error
-		result, err = t.TransformRequestIn(ctx, result, provider)
+		if !isBestEffort(t) {
+			var err error
+			result, err = t.TransformRequestIn(ctx, result, provider)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Work on a copy so a failure leaves the request untouched
+		transformed, err := t.TransformRequestIn(ctx, copyRequest(result), provider)
 		if err != nil {
-			return nil, err
+			utils.GetLogger().Warnf("Best-effort transformer %s failed on request for %s, skipping it: %v", t.GetName(), provider, err)
+			continue
 		}
+		result = transformed
 	}
 	return result, nil
 }
 
-// TransformResponseOut applies all transformers' TransformResponseOut in reverse order
+// TransformResponseOut applies all transformers' TransformResponseOut in
+// reverse order. A failing best-effort transformer is skipped with a warning.
 func (c *TransformerChain) TransformResponseOut(ctx context.Context, response *http.Response) (*http.Response, error) {
 	result := response
 	// Apply in reverse order for responses
 	for i := len(c.transformers) - 1; i >= 0; i-- {
-		var err error
-		result, err = c.transformers[i].TransformResponseOut(ctx, result)
+		t := c.transformers[i]
+		if !isBestEffort(t) {
+			var err error
+			result, err = t.TransformResponseOut(ctx, result)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Keep the body so it can be replayed when the transformer fails
+		body, replayable := bufferResponse(result)
+		transformed, err := t.TransformResponseOut(ctx, result)
 		if err != nil {
-			return nil, err
+			utils.GetLogger().Warnf("Best-effort transformer %s failed on response, skipping it: %v", t.GetName(), err)
+			if replayable {
+				result.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			continue
 		}
+		result = transformed
 	}
 	return result, nil
 }
@@ -201,6 +201,11 @@ func LogResponse(statusCode int, duration float64, fields map[string]interface{}
 	}
 }
 
+// LogAccess logs a structured access record for a completed request
+func LogAccess(fields map[string]interface{}) {
+	GetLogger().WithFields(logrus.Fields(fields)).WithField("type", "access").Info("HTTP access")
+}
+
 // LogRouting logs routing decisions
 func LogRouting(model, provider, reason string) {
 	GetLogger().WithFields(logrus.Fields{
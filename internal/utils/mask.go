@@ -2,7 +2,8 @@ package utils
 
 import "strings"
 
-// MaskAPIKey masks an API key for safe display
+// MaskAPIKey masks an API key for safe display, keeping only the last 4
+// characters so keys can still be told apart
 func MaskAPIKey(key string) string {
 	if key == "" {
 		return "(not set)"
@@ -12,6 +13,5 @@ func MaskAPIKey(key string) string {
 		return strings.Repeat("*", len(key))
 	}
 
-	// Show first 4 and last 4 characters
-	return key[:4] + strings.Repeat("*", len(key)-8) + key[len(key)-4:]
+	return "****" + key[len(key)-4:]
 }
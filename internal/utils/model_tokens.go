@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Tokens added per message and per request for chat formatting, as counted
+// by OpenAI for its chat models
+const (
+	messageOverheadTokens = 3
+	replyPrimingTokens    = 3
+)
+
+// TokenCounter counts the input tokens of a request body for one family of
+// models
+type TokenCounter interface {
+	CountRequest(bodyMap map[string]interface{}) (int, error)
+}
+
+// TokenCounterFunc adapts a function to the TokenCounter interface
+type TokenCounterFunc func(bodyMap map[string]interface{}) (int, error)
+
+// CountRequest calls f
+func (f TokenCounterFunc) CountRequest(bodyMap map[string]interface{}) (int, error) {
+	return f(bodyMap)
+}
+
+var (
+	tokenCountersMu sync.RWMutex
+	tokenCounters   = make(map[string]TokenCounter) // Registered counters by provider
+)
+
+// RegisterTokenCounter sets the counter used for every model of a provider,
+// replacing the built-in choice. A nil counter restores the built-in choice.
+func RegisterTokenCounter(provider string, counter TokenCounter) {
+	tokenCountersMu.Lock()
+	defer tokenCountersMu.Unlock()
+
+	if counter == nil {
+		delete(tokenCounters, provider)
+		return
+	}
+	tokenCounters[provider] = counter
+}
+
+// TokenCounterFor returns the counter for a model served by a provider, or nil
+// when nothing is more accurate than the CountRequestTokens heuristic. OpenAI
+// models are counted with tiktoken wherever they are served, while Anthropic
+// and Gemini models use character ratios that match their tokenizers.
+func TokenCounterFor(provider, model string) TokenCounter {
+	tokenCountersMu.RLock()
+	counter, ok := tokenCounters[provider]
+	tokenCountersMu.RUnlock()
+	if ok {
+		return counter
+	}
+
+	// Aggregators such as OpenRouter prefix the model with its vendor
+	name := strings.ToLower(model)
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	if encoding := openAIEncoding(name); encoding != "" {
+		return tiktokenCounter{encoding: encoding}
+	}
+	switch {
+	case strings.HasPrefix(name, "claude"), provider == "anthropic":
+		return ratioCounter{charsPerToken: 3.5}
+	case strings.HasPrefix(name, "gemini"), provider == "gemini", provider == "vertex":
+		return ratioCounter{charsPerToken: 4}
+	case provider == "openai", provider == "azure":
+		// Azure deployments and fine-tunes have arbitrary names
+		return tiktokenCounter{encoding: "cl100k_base"}
+	}
+	return nil
+}
+
+// CountRequestTokensFor counts a request's tokens for the model it is sent to,
+// falling back to the CountRequestTokens heuristic when the model has no
+// tokenizer or it cannot be loaded
+func CountRequestTokensFor(provider, model string, bodyMap map[string]interface{}) int {
+	counter := TokenCounterFor(provider, model)
+	if counter == nil {
+		return CountRequestTokens(bodyMap)
+	}
+
+	count, err := counter.CountRequest(bodyMap)
+	if err != nil {
+		GetLogger().Debugf("Token counter for %s unavailable, estimating: %v", model, err)
+		return CountRequestTokens(bodyMap)
+	}
+	return count
+}
+
+// openAIEncoding returns the tiktoken encoding of an OpenAI model, or "" for
+// models from other vendors
+func openAIEncoding(model string) string {
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4", "chatgpt-"} {
+		if strings.HasPrefix(model, prefix) {
+			return "o200k_base"
+		}
+	}
+	for _, prefix := range []string{"gpt-4", "gpt-3.5", "text-embedding-"} {
+		if strings.HasPrefix(model, prefix) {
+			return "cl100k_base"
+		}
+	}
+	return ""
+}
+
+// tiktokenCounter counts tokens with an OpenAI BPE encoding
+type tiktokenCounter struct {
+	encoding string
+}
+
+// CountRequest encodes every text of the request
+func (c tiktokenCounter) CountRequest(bodyMap map[string]interface{}) (int, error) {
+	enc, err := getEncoding(c.encoding)
+	if err != nil {
+		return 0, err
+	}
+
+	texts, messages := requestTexts(bodyMap)
+	count := messages*messageOverheadTokens + replyPrimingTokens
+	for _, text := range texts {
+		count += len(enc.Encode(text, nil, nil))
+	}
+	return count, nil
+}
+
+// ratioCounter approximates a tokenizer by its average characters per token
+type ratioCounter struct {
+	charsPerToken float64
+}
+
+// CountRequest divides the character count of every text by the ratio
+func (c ratioCounter) CountRequest(bodyMap map[string]interface{}) (int, error) {
+	texts, messages := requestTexts(bodyMap)
+	chars := 0
+	for _, text := range texts {
+		chars += utf8.RuneCountInString(text)
+	}
+	return int(float64(chars)/c.charsPerToken+0.5) + messages*messageOverheadTokens + replyPrimingTokens, nil
+}
+
+// requestTexts collects the texts of a request that count towards its input
+// tokens: the system prompt, message contents, tool calls and results, and
+// tool definitions. It also returns the number of messages.
+func requestTexts(bodyMap map[string]interface{}) ([]string, int) {
+	var texts []string
+	add := func(content interface{}) {
+		texts = appendContentTexts(texts, content)
+	}
+
+	if system, ok := bodyMap["system"]; ok {
+		add(system)
+	}
+
+	messages, _ := bodyMap["messages"].([]interface{})
+	for _, msg := range messages {
+		msgMap, ok := msg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		add(msgMap["content"])
+		// OpenAI assistant tool calls sit beside the content
+		if toolCalls, ok := msgMap["tool_calls"]; ok {
+			texts = append(texts, jsonText(toolCalls))
+		}
+	}
+
+	if tools, ok := bodyMap["tools"].([]interface{}); ok {
+		for _, tool := range tools {
+			texts = append(texts, jsonText(tool))
+		}
+	}
+	return texts, len(messages)
+}
+
+// appendContentTexts appends the texts of a string or of content blocks
+func appendContentTexts(texts []string, content interface{}) []string {
+	switch v := content.(type) {
+	case nil:
+	case string:
+		texts = append(texts, v)
+	case []interface{}:
+		for _, block := range v {
+			blockMap, ok := block.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch blockMap["type"] {
+			case "text":
+				// System blocks may split their text into parts
+				switch text := blockMap["text"].(type) {
+				case string:
+					texts = append(texts, text)
+				case []interface{}:
+					for _, part := range text {
+						if str, ok := part.(string); ok {
+							texts = append(texts, str)
+						}
+					}
+				}
+			case "tool_use":
+				name, _ := blockMap["name"].(string)
+				texts = append(texts, name, jsonText(blockMap["input"]))
+			case "tool_result":
+				texts = appendContentTexts(texts, blockMap["content"])
+			case "image", "image_url":
+				// Image tokens depend on the image size, which is unknown here
+			default:
+				texts = append(texts, jsonText(blockMap))
+			}
+		}
+	default:
+		texts = append(texts, jsonText(v))
+	}
+	return texts
+}
+
+// jsonText returns the JSON encoding of value, or "" when it has none
+func jsonText(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
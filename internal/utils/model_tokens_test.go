@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
+)
+
+func TestTokenCounterFor(t *testing.T) {
+	tests := []struct {
+		provider string
+		model    string
+		want     TokenCounter
+	}{
+		{"openai", "gpt-4o-mini", tiktokenCounter{encoding: "o200k_base"}},
+		{"openrouter", "openai/o3-mini", tiktokenCounter{encoding: "o200k_base"}},
+		{"azure", "gpt-4-turbo", tiktokenCounter{encoding: "cl100k_base"}},
+		{"azure", "my-deployment", tiktokenCounter{encoding: "cl100k_base"}},
+		{"", "claude-3-5-sonnet-20241022", ratioCounter{charsPerToken: 3.5}},
+		{"openrouter", "anthropic/claude-3.5-sonnet", ratioCounter{charsPerToken: 3.5}},
+		{"vertex", "custom-tuned", ratioCounter{charsPerToken: 4}},
+		{"gemini", "gemini-1.5-pro", ratioCounter{charsPerToken: 4}},
+		{"groq", "llama-3.3-70b-versatile", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.model, func(t *testing.T) {
+			testutil.AssertEqual(t, tt.want, TokenCounterFor(tt.provider, tt.model))
+		})
+	}
+}
+
+func TestRatioCounter(t *testing.T) {
+	bodyMap := map[string]interface{}{
+		"system": strings.Repeat("s", 70),
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": strings.Repeat("u", 35)},
+			map[string]interface{}{"role": "assistant", "content": []interface{}{
+				map[string]interface{}{"type": "text", "text": strings.Repeat("a", 35)},
+				map[string]interface{}{"type": "image", "source": map[string]interface{}{"data": strings.Repeat("x", 1000)}},
+			}},
+		},
+	}
+
+	count, err := ratioCounter{charsPerToken: 3.5}.CountRequest(bodyMap)
+	testutil.AssertNoError(t, err)
+	// 140 characters at 3.5 per token, plus formatting for two messages.
+	// Image data is not text and does not count.
+	testutil.AssertEqual(t, 40+2*messageOverheadTokens+replyPrimingTokens, count)
+}
+
+func TestRequestTexts(t *testing.T) {
+	bodyMap := map[string]interface{}{
+		"messages": []interface{}{
+			map[string]interface{}{"role": "assistant", "content": []interface{}{
+				map[string]interface{}{"type": "tool_use", "name": "lookup", "input": map[string]interface{}{"q": "weather"}},
+			}},
+			map[string]interface{}{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "tool_result", "content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "sunny"},
+				}},
+			}},
+			map[string]interface{}{"role": "assistant", "content": nil, "tool_calls": []interface{}{
+				map[string]interface{}{"id": "call_1"},
+			}},
+		},
+		"tools": []interface{}{map[string]interface{}{"name": "lookup"}},
+	}
+
+	texts, messages := requestTexts(bodyMap)
+	testutil.AssertEqual(t, 3, messages)
+	testutil.AssertEqual(t, strings.Join([]string{
+		"lookup", `{"q":"weather"}`, "sunny", `[{"id":"call_1"}]`, `{"name":"lookup"}`,
+	}, "|"), strings.Join(texts, "|"))
+}
+
+func TestCountRequestTokensFor(t *testing.T) {
+	bodyMap := map[string]interface{}{
+		"messages": []interface{}{map[string]interface{}{"role": "user", "content": strings.Repeat("w", 400)}},
+	}
+
+	t.Run("HeuristicWithoutTokenizer", func(t *testing.T) {
+		testutil.AssertEqual(t, CountRequestTokens(bodyMap), CountRequestTokensFor("groq", "llama3-8b", bodyMap))
+	})
+
+	t.Run("RegisteredCounter", func(t *testing.T) {
+		RegisterTokenCounter("custom", TokenCounterFunc(func(map[string]interface{}) (int, error) {
+			return 7, nil
+		}))
+		defer RegisterTokenCounter("custom", nil)
+
+		testutil.AssertEqual(t, 7, CountRequestTokensFor("custom", "any-model", bodyMap))
+	})
+
+	t.Run("HeuristicWhenCounterFails", func(t *testing.T) {
+		RegisterTokenCounter("custom", TokenCounterFunc(func(map[string]interface{}) (int, error) {
+			return 0, errors.New("tokenizer unavailable")
+		}))
+		defer RegisterTokenCounter("custom", nil)
+
+		testutil.AssertEqual(t, CountRequestTokens(bodyMap), CountRequestTokensFor("custom", "any-model", bodyMap))
+	})
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"encoding/json"
 	"strings"
 )
 
@@ -33,6 +34,100 @@ func CountRequestTokens(bodyMap map[string]interface{}) int {
 	return tokenCount
 }
 
+// EstimateContentTokens estimates the tokens in message or system content at
+// 1 token per 4 characters. Content blocks are measured by their JSON encoding
+// so tool calls and results count too.
+func EstimateContentTokens(content interface{}) int {
+	switch v := content.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v) / 4
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return 0
+		}
+		return len(data) / 4
+	}
+}
+
+// CountToolRoundtrips counts the completed tool roundtrips in a conversation.
+// A roundtrip is a turn that returns tool results, either as an Anthropic user
+// message with tool_result blocks or as a run of OpenAI "tool" role messages.
+func CountToolRoundtrips(bodyMap map[string]interface{}) int {
+	messages, ok := bodyMap["messages"].([]interface{})
+	if !ok {
+		return 0
+	}
+
+	roundtrips := 0
+	inToolRun := false
+	for _, msg := range messages {
+		msgMap, ok := msg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		role, _ := msgMap["role"].(string)
+		if role == "tool" {
+			// Consecutive tool messages answer the same assistant turn
+			if !inToolRun {
+				roundtrips++
+			}
+			inToolRun = true
+			continue
+		}
+		inToolRun = false
+
+		if role != "user" {
+			continue
+		}
+		if blocks, ok := msgMap["content"].([]interface{}); ok {
+			for _, block := range blocks {
+				if blockMap, ok := block.(map[string]interface{}); ok && blockMap["type"] == "tool_result" {
+					roundtrips++
+					break
+				}
+			}
+		}
+	}
+
+	return roundtrips
+}
+
+// ExtractUserText concatenates the text of all user messages, covering both
+// plain string content and Anthropic text blocks
+func ExtractUserText(bodyMap map[string]interface{}) string {
+	messages, ok := bodyMap["messages"].([]interface{})
+	if !ok {
+		return ""
+	}
+
+	var parts []string
+	for _, msg := range messages {
+		msgMap, ok := msg.(map[string]interface{})
+		if !ok || msgMap["role"] != "user" {
+			continue
+		}
+
+		switch content := msgMap["content"].(type) {
+		case string:
+			parts = append(parts, content)
+		case []interface{}:
+			for _, block := range content {
+				if blockMap, ok := block.(map[string]interface{}); ok && blockMap["type"] == "text" {
+					if text, ok := blockMap["text"].(string); ok {
+						parts = append(parts, text)
+					}
+				}
+			}
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
 // CountResponseTokens estimates token count for a response
 func CountResponseTokens(content string) int {
 	// Remove common formatting
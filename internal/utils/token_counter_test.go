@@ -110,6 +110,32 @@ func TestCountRequestTokens(t *testing.T) {
 	}
 }
 
+func TestEstimateContentTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		content interface{}
+		want    int
+	}{
+		{name: "nil", content: nil, want: 0},
+		{name: "string", content: "abcdefgh", want: 2},
+		{
+			name: "blocks",
+			content: []interface{}{
+				map[string]interface{}{"type": "text", "text": "hello"},
+			},
+			want: len(`[{"text":"hello","type":"text"}]`) / 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateContentTokens(tt.content); got != tt.want {
+				t.Errorf("EstimateContentTokens() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCountResponseTokens(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -279,3 +305,117 @@ func BenchmarkCountResponseTokens(b *testing.B) {
 		CountResponseTokens(content)
 	}
 }
+
+func TestCountToolRoundtrips(t *testing.T) {
+	tests := []struct {
+		name     string
+		bodyMap  map[string]interface{}
+		expected int
+	}{
+		{
+			name:     "no messages",
+			bodyMap:  map[string]interface{}{},
+			expected: 0,
+		},
+		{
+			name: "plain conversation",
+			bodyMap: map[string]interface{}{
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": "Hello"},
+					map[string]interface{}{"role": "assistant", "content": "Hi"},
+				},
+			},
+			expected: 0,
+		},
+		{
+			name: "anthropic tool results",
+			bodyMap: map[string]interface{}{
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": "List files"},
+					map[string]interface{}{"role": "assistant", "content": []interface{}{
+						map[string]interface{}{"type": "tool_use", "id": "t1", "name": "ls"},
+					}},
+					map[string]interface{}{"role": "user", "content": []interface{}{
+						map[string]interface{}{"type": "tool_result", "tool_use_id": "t1", "content": "a.go"},
+					}},
+					map[string]interface{}{"role": "assistant", "content": []interface{}{
+						map[string]interface{}{"type": "tool_use", "id": "t2", "name": "cat"},
+						map[string]interface{}{"type": "tool_use", "id": "t3", "name": "cat"},
+					}},
+					map[string]interface{}{"role": "user", "content": []interface{}{
+						map[string]interface{}{"type": "tool_result", "tool_use_id": "t2", "content": "x"},
+						map[string]interface{}{"type": "tool_result", "tool_use_id": "t3", "content": "y"},
+					}},
+				},
+			},
+			expected: 2,
+		},
+		{
+			name: "openai tool messages",
+			bodyMap: map[string]interface{}{
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": "Weather?"},
+					map[string]interface{}{"role": "assistant", "tool_calls": []interface{}{}},
+					map[string]interface{}{"role": "tool", "tool_call_id": "c1", "content": "sunny"},
+					map[string]interface{}{"role": "tool", "tool_call_id": "c2", "content": "warm"},
+					map[string]interface{}{"role": "assistant", "tool_calls": []interface{}{}},
+					map[string]interface{}{"role": "tool", "tool_call_id": "c3", "content": "windy"},
+				},
+			},
+			expected: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if count := CountToolRoundtrips(tt.bodyMap); count != tt.expected {
+				t.Errorf("Expected %d roundtrips, got %d", tt.expected, count)
+			}
+		})
+	}
+}
+
+func TestExtractUserText(t *testing.T) {
+	tests := []struct {
+		name     string
+		bodyMap  map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "no messages",
+			bodyMap:  map[string]interface{}{},
+			expected: "",
+		},
+		{
+			name: "string content skips other roles",
+			bodyMap: map[string]interface{}{
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": "Translate this"},
+					map[string]interface{}{"role": "assistant", "content": "Sure"},
+					map[string]interface{}{"role": "user", "content": "into French"},
+				},
+			},
+			expected: "Translate this\ninto French",
+		},
+		{
+			name: "text blocks only",
+			bodyMap: map[string]interface{}{
+				"messages": []interface{}{
+					map[string]interface{}{"role": "user", "content": []interface{}{
+						map[string]interface{}{"type": "text", "text": "Describe"},
+						map[string]interface{}{"type": "image", "source": map[string]interface{}{}},
+						map[string]interface{}{"type": "tool_result", "content": "ignored"},
+						map[string]interface{}{"type": "text", "text": "this image"},
+					}},
+				},
+			},
+			expected: "Describe\nthis image",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.AssertEqual(t, tt.expected, ExtractUserText(tt.bodyMap))
+		})
+	}
+}
@@ -9,25 +9,64 @@ import (
 )
 
 var (
-	encoder     *tiktoken.Tiktoken
-	encoderOnce sync.Once
-	encoderErr  error
+	encodingsMu sync.Mutex
+	encodings   = make(map[string]*loadedEncoding)
 )
 
+// loadedEncoding loads a tiktoken encoding once and caches it, or the error
+// loading it, so an unavailable encoding is not retried on every request
+type loadedEncoding struct {
+	once sync.Once
+	enc  *tiktoken.Tiktoken
+	err  error
+}
+
+// getEncoding returns the named tiktoken encoding, loading it on first use.
+// Loading can download the encoding, so it happens outside encodingsMu and
+// only callers of the same encoding wait for it.
+func getEncoding(name string) (*tiktoken.Tiktoken, error) {
+	encodingsMu.Lock()
+	loaded, ok := encodings[name]
+	if !ok {
+		loaded = &loadedEncoding{}
+		encodings[name] = loaded
+	}
+	encodingsMu.Unlock()
+
+	loaded.once.Do(func() {
+		loaded.enc, loaded.err = tiktoken.GetEncoding(name)
+		if loaded.err != nil {
+			loaded.err = fmt.Errorf("failed to load %s encoding: %w", name, loaded.err)
+		}
+	})
+	return loaded.enc, loaded.err
+}
+
+// PreloadEncodings loads the tiktoken encodings of OpenAI models, so the
+// first requests counted with them do not wait for a download
+func PreloadEncodings() {
+	var wg sync.WaitGroup
+	for _, name := range []string{"cl100k_base", "o200k_base"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if _, err := getEncoding(name); err != nil {
+				GetLogger().Warnf("Tokenizer unavailable, token counts will be estimated: %v", err)
+			}
+		}(name)
+	}
+	wg.Wait()
+}
+
 // InitTokenizer initializes the tiktoken encoder with cl100k_base encoding
 func InitTokenizer() error {
-	encoderOnce.Do(func() {
-		encoder, encoderErr = tiktoken.GetEncoding("cl100k_base")
-	})
-	return encoderErr
+	_, err := getEncoding("cl100k_base")
+	return err
 }
 
 // GetEncoder returns the initialized encoder
 func GetEncoder() (*tiktoken.Tiktoken, error) {
-	if err := InitTokenizer(); err != nil {
-		return nil, err
-	}
-	return encoder, nil
+	return getEncoding("cl100k_base")
 }
 
 // CountTokens counts the number of tokens in a string
@@ -102,19 +141,14 @@ func CountMessageTokens(params *MessageCreateParams) (int, error) {
 
 	tokenCount := 0
 
-	// Count tokens in messages
+	// Count tokens in messages and the system prompt
+	var texts []string
 	for _, message := range params.Messages {
-		count, err := countMessageContentTokens(enc, message.Content)
-		if err != nil {
-			return 0, fmt.Errorf("failed to count message tokens: %w", err)
-		}
-		tokenCount += count
+		texts = appendContentTexts(texts, message.Content)
 	}
-
-	// Count tokens in system prompt
-	if params.System != nil {
-		count := countSystemTokens(enc, params.System)
-		tokenCount += count
+	texts = appendContentTexts(texts, params.System)
+	for _, text := range texts {
+		tokenCount += len(enc.Encode(text, nil, nil))
 	}
 
 	// Count tokens in tools
@@ -140,105 +174,3 @@ func CountMessageTokens(params *MessageCreateParams) (int, error) {
 
 	return tokenCount, nil
 }
-
-// countMessageContentTokens counts tokens in message content
-func countMessageContentTokens(enc *tiktoken.Tiktoken, content interface{}) (int, error) {
-	tokenCount := 0
-
-	switch c := content.(type) {
-	case string:
-		// Simple string content
-		tokens := enc.Encode(c, nil, nil)
-		tokenCount += len(tokens)
-
-	case []interface{}:
-		// Array of content objects
-		for _, item := range c {
-			itemMap, ok := item.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			contentType, ok := itemMap["type"].(string)
-			if !ok {
-				continue
-			}
-
-			switch contentType {
-			case "text":
-				if text, ok := itemMap["text"].(string); ok {
-					tokens := enc.Encode(text, nil, nil)
-					tokenCount += len(tokens)
-				}
-
-			case "tool_use":
-				if input, ok := itemMap["input"]; ok {
-					inputJSON, err := json.Marshal(input)
-					if err != nil {
-						return 0, fmt.Errorf("failed to marshal tool input: %w", err)
-					}
-					tokens := enc.Encode(string(inputJSON), nil, nil)
-					tokenCount += len(tokens)
-				}
-
-			case "tool_result":
-				if content, ok := itemMap["content"]; ok {
-					var text string
-					if str, ok := content.(string); ok {
-						text = str
-					} else {
-						contentJSON, err := json.Marshal(content)
-						if err != nil {
-							return 0, fmt.Errorf("failed to marshal tool result: %w", err)
-						}
-						text = string(contentJSON)
-					}
-					tokens := enc.Encode(text, nil, nil)
-					tokenCount += len(tokens)
-				}
-			}
-		}
-	}
-
-	return tokenCount, nil
-}
-
-// countSystemTokens counts tokens in system content
-func countSystemTokens(enc *tiktoken.Tiktoken, system interface{}) int {
-	tokenCount := 0
-
-	switch s := system.(type) {
-	case string:
-		// Simple string system prompt
-		tokens := enc.Encode(s, nil, nil)
-		tokenCount += len(tokens)
-
-	case []interface{}:
-		// Array of system content objects
-		for _, item := range s {
-			itemMap, ok := item.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			if itemMap["type"] != "text" {
-				continue
-			}
-
-			// Handle text which can be string or array
-			if text, ok := itemMap["text"].(string); ok {
-				tokens := enc.Encode(text, nil, nil)
-				tokenCount += len(tokens)
-			} else if textArray, ok := itemMap["text"].([]interface{}); ok {
-				for _, textPart := range textArray {
-					if str, ok := textPart.(string); ok {
-						tokens := enc.Encode(str, nil, nil)
-						tokenCount += len(tokens)
-					}
-				}
-			}
-		}
-	}
-
-	return tokenCount
-}
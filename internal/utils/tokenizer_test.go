@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	testutil "github.com/orchestre-dev/ccproxy/internal/testing"
 )
@@ -612,3 +614,42 @@ func BenchmarkCountMessageTokensComplex(b *testing.B) {
 		CountMessageTokens(params)
 	}
 }
+
+func TestGetEncodingLoadsOutsideLock(t *testing.T) {
+	// An encoding still loading must not hold up lookups of another one
+	loading := &loadedEncoding{}
+	loaded := &loadedEncoding{err: errors.New("unavailable")}
+	loaded.once.Do(func() {})
+
+	encodingsMu.Lock()
+	encodings["test_loading"] = loading
+	encodings["test_loaded"] = loaded
+	encodingsMu.Unlock()
+	defer func() {
+		encodingsMu.Lock()
+		delete(encodings, "test_loading")
+		delete(encodings, "test_loaded")
+		encodingsMu.Unlock()
+	}()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go loading.once.Do(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	defer close(release)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := getEncoding("test_loaded")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		testutil.AssertError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Lookup waited for another encoding to load")
+	}
+}